@@ -0,0 +1,55 @@
+package activitylog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/c9845/output"
+)
+
+// This file handles conditional requests for the activity log reports. The reports
+// are refreshed often but rarely change, so returning a 304 Not Modified when the
+// browser already has the data saves resending the full payload.
+
+// dataFoundWithETag sends data the same as output.DataFound but also sets an ETag
+// header. If the request's If-None-Match header matches the ETag, a 304 Not Modified
+// is returned without a body instead.
+//
+// The ETag is a hash of the data, not the full response body, since the response
+// body includes a timestamp that changes on every request.
+//
+// Any other headers, such as Cache-Control, should be set before calling this.
+func dataFoundWithETag(data any, w http.ResponseWriter, r *http.Request) {
+	j, err := json.Marshal(data)
+	if err != nil {
+		output.Error(err, "Could not encode data.", w)
+		return
+	}
+
+	hash := sha256.Sum256(j)
+	etag := `"` + hex.EncodeToString(hash[:]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	output.DataFound(data, w)
+}
+
+// etagMatches checks if an If-None-Match header value matches an ETag. The header
+// may contain a list of ETags, weak ETags, or "*".
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
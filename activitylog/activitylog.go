@@ -160,7 +160,7 @@ func OverTimeOfDay(w http.ResponseWriter, r *http.Request) {
 	//
 	//Note slight differences in queries, especially around CAST statements.
 	const timeBracketSizeMinutes = "10" //string for easier concatting in query.
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(r.Context())
 	q := `
 		WITH t AS (
 			SELECT
@@ -255,7 +255,7 @@ func OverTimeOfDay(w http.ResponseWriter, r *http.Request) {
 	//recently purged).
 	w.Header().Set("Cache-Control", "no-transform,public,max-age="+strconv.Itoa(30))
 
-	output.DataFound(reportData, w)
+	dataFoundWithETag(reportData, w, r)
 }
 
 // MaxAndAvgMonthlyDuration retrieves the maximum and average duration of times it
@@ -310,7 +310,7 @@ func MaxAndAvgMonthlyDuration(w http.ResponseWriter, r *http.Request) {
 	//a while to load (lots of activities) and doesn't change frequently.
 	w.Header().Set("Cache-Control", "no-transform,public,max-age="+strconv.Itoa(30))
 
-	output.DataFound(data, w)
+	dataFoundWithETag(data, w, r)
 }
 
 // LatestRequestsDuration gets the duration it took the app/server to respond to the
@@ -430,5 +430,5 @@ func DurationByEndpoint(w http.ResponseWriter, r *http.Request) {
 	//a while to load (lots of activities) and doesn't change frequently.
 	w.Header().Set("Cache-Control", "no-transform,public,max-age="+strconv.Itoa(60))
 
-	output.DataFound(data, w)
+	dataFoundWithETag(data, w, r)
 }
@@ -0,0 +1,138 @@
+package apikeys
+
+import (
+	"database/sql"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles restricting the IP addresses an API key can be used from. This is
+// useful when an integration makes requests from a fixed set of IP addresses, so that
+// a leaked API key cannot be used from anywhere else.
+//
+// The list of allowed IPs is stored as a comma separated list of IP addresses and CIDR
+// ranges. A blank list allows an API key to be used from any IP address.
+
+// SetAllowedIPs saves the list of IP addresses and CIDR ranges an API key can be used
+// from. Provide a blank list to allow an API key to be used from any IP address.
+func SetAllowedIPs(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	allowedIPs := r.FormValue("allowedIPs")
+
+	//Validate.
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which API key you want to set allowed IPs for.", w)
+		return
+	}
+
+	normalized, errMsg := normalizeAllowedIPs(allowedIPs)
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Make sure API key exists and is active.
+	cols := sqldb.Columns{db.TableAPIKeys + ".*"}
+	a, err := db.GetAPIKeyByID(r.Context(), id, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("Could not find API key.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up API key.", w)
+		return
+	}
+	if !a.Active {
+		output.ErrorInputInvalid("This API key has been revoked.", w)
+		return
+	}
+
+	//Save.
+	a.AllowedIPs = normalized
+	err = a.SaveAllowedIPs(r.Context())
+	if err != nil {
+		output.Error(err, "Could not save allowed IPs for API key.", w)
+		return
+	}
+
+	output.UpdateOKWithData(a.AllowedIPs, w)
+}
+
+// normalizeAllowedIPs validates a list of IP addresses and CIDR ranges, separated by
+// commas, spaces, or newlines, and returns the list formatted for saving. Single IP
+// addresses are saved as-is and CIDR ranges are saved with their host bits removed.
+func normalizeAllowedIPs(raw string) (normalized string, errMsg string) {
+	fields := strings.FieldsFunc(raw, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\n' || c == '\r' || c == '\t'
+	})
+
+	list := make([]string, 0, len(fields))
+	for _, f := range fields {
+		p, err := parseAllowedIP(f)
+		if err != nil {
+			return "", "\"" + f + "\" is not a valid IP address or CIDR range."
+		}
+
+		s := p.String()
+		if p.IsSingleIP() {
+			s = p.Addr().String()
+		}
+		list = append(list, s)
+	}
+
+	return strings.Join(list, ","), ""
+}
+
+// parseAllowedIP parses an IP address or CIDR range. An IP address is returned as a
+// prefix that only contains the IP address.
+func parseAllowedIP(s string) (p netip.Prefix, err error) {
+	if strings.Contains(s, "/") {
+		p, err = netip.ParsePrefix(s)
+		if err != nil {
+			return
+		}
+
+		return p.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// IPAllowed checks if an IP address is in an API key's list of allowed IPs. A blank
+// list allows any IP address. An IP address that cannot be parsed is never allowed
+// unless the list is blank.
+func IPAllowed(allowedIPs, ip string) bool {
+	if strings.TrimSpace(allowedIPs) == "" {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, s := range strings.Split(allowedIPs, ",") {
+		p, err := parseAllowedIP(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -58,6 +58,17 @@ func GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//Get the apps each key is restricted to.
+	for i, k := range keys {
+		apps, err := db.GetAPIKeyApps(r.Context(), k.ID)
+		if err != nil {
+			output.Error(err, "Could not look up apps for api key.", w)
+			return
+		}
+
+		keys[i].Apps = apps
+	}
+
 	output.DataFound(keys, w)
 }
 
@@ -246,6 +257,82 @@ func Update(w http.ResponseWriter, r *http.Request) {
 	output.UpdateOK(w)
 }
 
+// AssignApp adds an app to the list of apps an API key can create licenses for. This
+// list is only used if the API key's RestrictToApps field is true.
+func AssignApp(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	apiKeyID, _ := strconv.ParseInt(r.FormValue("apiKeyID"), 10, 64)
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	if apiKeyID < 1 {
+		output.ErrorInputInvalid("Could not determine which API key you want to assign an app to.", w)
+		return
+	}
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to assign.", w)
+		return
+	}
+
+	//Check if app is already assigned to this key.
+	_, err := db.GetAPIKeyApp(r.Context(), apiKeyID, appID)
+	if err == nil {
+		output.ErrorAlreadyExists("This app is already assigned to this API key.", w)
+		return
+	} else if err != sql.ErrNoRows {
+		output.Error(err, "Could not verify if this app is already assigned to this API key.", w)
+		return
+	}
+
+	//Get user ID of logged in user who is assigning the app.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Save.
+	a := db.APIKeyApp{
+		CreatedByUserID: loggedInUserID,
+		APIKeyID:        apiKeyID,
+		AppID:           appID,
+	}
+	err = a.Insert(r.Context())
+	if err != nil {
+		output.Error(err, "Could not assign app to API key.", w)
+		return
+	}
+
+	output.InsertOK(a.ID, w)
+}
+
+// UnassignApp removes an app from the list of apps an API key can create licenses
+// for.
+func UnassignApp(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	apiKeyID, _ := strconv.ParseInt(r.FormValue("apiKeyID"), 10, 64)
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	if apiKeyID < 1 {
+		output.ErrorInputInvalid("Could not determine which API key you want to unassign an app from.", w)
+		return
+	}
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to unassign.", w)
+		return
+	}
+
+	//Remove.
+	err := db.DeleteAPIKeyApp(r.Context(), apiKeyID, appID)
+	if err != nil {
+		output.Error(err, "Could not unassign app from API key.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
 type apiKeyContextKeyType string
 
 // APIKeyContextKey is the name of the key that stores an API key's ID in the request
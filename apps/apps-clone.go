@@ -0,0 +1,157 @@
+package apps
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/keypairs"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles cloning an app. This is used when creating a new app that is
+// similar to an existing app so that the existing app's settings and custom fields
+// don't need to be re-entered. Licenses and key pairs are not copied; a new key pair
+// can optionally be generated for the new app.
+
+// clonedKeyPairName is the name of the key pair generated for a cloned app.
+const clonedKeyPairName = "Default"
+
+// Clone creates a new app with the settings and active custom fields of an existing
+// app. The new app, its custom fields, and the optional key pair are saved in one
+// transaction so that a partial clone cannot occur.
+func Clone(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+	name := strings.TrimSpace(r.FormValue("name"))
+	generateKeyPair, _ := strconv.ParseBool(r.FormValue("generateKeyPair"))
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to clone.", w)
+		return
+	}
+
+	source, err := db.GetAppByID(r.Context(), appID)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The app you want to clone does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up app to clone.", w)
+		return
+	}
+
+	//Get user who is cloning this app.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Build the new app from the existing app's settings. Validate() makes sure the
+	//name is not already used.
+	a := source
+	a.ID = 0
+	a.DatetimeCreated = ""
+	a.DatetimeModified = ""
+	a.CreatedByUserID = loggedInUserID
+	a.Active = true
+	a.Name = name
+
+	errMsg, err := a.Validate(r.Context())
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate data about this app.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Look up the custom fields to copy.
+	fields, err := db.GetCustomFieldsDefined(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields to clone.", w)
+		return
+	}
+
+	//Generate the new key pair, if needed, before starting the transaction since
+	//generating some key pair types is slow. The new key pair uses the same
+	//algorithm as the existing app's default key pair.
+	var k db.KeyPair
+	if generateKeyPair {
+		algo := licensefile.KeyPairAlgoED25519
+		existing, err := db.GetDefaultKeyPair(r.Context(), appID)
+		if err == nil {
+			algo = existing.AlgorithmType
+		} else if err != sql.ErrNoRows {
+			output.Error(err, "Could not look up default key pair of app to clone.", w)
+			return
+		}
+
+		k = db.KeyPair{
+			CreatedByUserID: loggedInUserID,
+			Active:          true,
+			Name:            clonedKeyPairName,
+			AlgorithmType:   algo,
+			IsDefault:       true,
+		}
+
+		errMsg, err := keypairs.GenerateKeys(&k)
+		if err != nil {
+			output.Error(err, errMsg, w)
+			return
+		}
+	}
+
+	//Save.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save cloned app (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = a.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save cloned app (2).", w)
+		return
+	}
+
+	for _, f := range fields {
+		f.ID = 0
+		f.AppID = a.ID
+		f.CreatedByUserID = loggedInUserID
+
+		err = f.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save custom field "+f.Name+" for cloned app.", w)
+			return
+		}
+	}
+
+	if generateKeyPair {
+		k.AppID = a.ID
+		err = k.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save key pair for cloned app.", w)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save cloned app (3).", w)
+		return
+	}
+
+	output.InsertOK(a.ID, w)
+}
@@ -0,0 +1,344 @@
+package apps
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/keypairs"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles exporting an app's configuration as a JSON document and importing
+// the document to recreate the app in another instance of this app. This is used for
+// documenting an app's setup for disaster recovery and for promoting an app's setup
+// from one environment to another, for example staging to production.
+//
+// The document includes the app's settings, active custom fields, active features,
+// and the public keys of the app's active key pairs. Private keys are never exported.
+// Licenses are not exported. When importing, key pairs are not recreated since the
+// private keys are not included; a new key pair can optionally be generated, or an
+// existing key pair can be imported separately with its private key. The exported
+// public keys can be used to confirm the correct key pair was imported.
+
+// appExportFormatVersion is the version of the exported document. This is incremented
+// if the document changes in a way that older versions of this app cannot import.
+const appExportFormatVersion = 1
+
+// appExport is the configuration of an app as exported and imported.
+type appExport struct {
+	FormatVersion int
+	Exported      string //YYYY-MM-DD HH:MM:SS, in UTC.
+
+	App          db.App
+	CustomFields []db.CustomFieldDefined
+	Features     []db.AppFeature
+	KeyPairs     []exportedKeyPair
+}
+
+// exportedKeyPair is the public information about one of an app's key pairs. This is
+// a separate type, rather than db.KeyPair, so that a private key can never be
+// exported.
+type exportedKeyPair struct {
+	Name          string
+	AlgorithmType licensefile.KeyPairAlgoType
+	PublicKey     string
+	IsDefault     bool
+	Compromised   bool
+}
+
+// importedKeyPairName is the name of the key pair generated for an imported app.
+const importedKeyPairName = "Default"
+
+// importedCoSigningKeyPairName is the name of each additional key pair generated for
+// an imported app that requires more than one signature. A number is appended so
+// each name is unique.
+const importedCoSigningKeyPairName = "Co-Signing"
+
+// Export returns an app's configuration as a JSON document that can be imported to
+// recreate the app. The document is returned as a file download.
+func Export(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to export.", w)
+		return
+	}
+
+	a, err := db.GetAppByID(r.Context(), appID)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The app you want to export does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up app to export.", w)
+		return
+	}
+
+	//Look up the app's configuration.
+	fields, err := db.GetCustomFieldsDefined(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields to export.", w)
+		return
+	}
+
+	features, err := db.GetAppFeatures(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not look up features to export.", w)
+		return
+	}
+
+	kk, err := db.GetKeyPairs(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not look up key pairs to export.", w)
+		return
+	}
+
+	exportedKeyPairs := make([]exportedKeyPair, 0, len(kk))
+	for _, k := range kk {
+		exportedKeyPairs = append(exportedKeyPairs, exportedKeyPair{
+			Name:          k.Name,
+			AlgorithmType: k.AlgorithmType,
+			PublicKey:     k.PublicKey,
+			IsDefault:     k.IsDefault,
+			Compromised:   k.Compromised,
+		})
+	}
+
+	//Build the document. Nil slices are replaced so that the document always has
+	//arrays, not nulls, making it easier to read and edit.
+	if fields == nil {
+		fields = []db.CustomFieldDefined{}
+	}
+	if features == nil {
+		features = []db.AppFeature{}
+	}
+
+	doc := appExport{
+		FormatVersion: appExportFormatVersion,
+		Exported:      timestamps.YMDHMS(),
+		App:           a,
+		CustomFields:  fields,
+		Features:      features,
+		KeyPairs:      exportedKeyPairs,
+	}
+
+	j, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		output.Error(err, "Could not build app export.", w)
+		return
+	}
+
+	//Return the document as a file.
+	filename := strings.ReplaceAll(a.Name, "\"", "") + " - config.json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.Write(j)
+}
+
+// Import creates a new app from a document returned by Export(). The new app, its
+// custom fields, its features, and the optional key pairs are saved in one transaction
+// so that a partial import cannot occur. When key pairs are generated, one key pair is
+// generated for each signature the app requires so that licenses can be created for
+// the app right away.
+//
+// The app's name can be changed when importing, for example if an app with the same
+// name already exists.
+func Import(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	raw := r.FormValue("data")
+	name := strings.TrimSpace(r.FormValue("name"))
+	generateKeyPair, _ := strconv.ParseBool(r.FormValue("generateKeyPair"))
+
+	//Parse the document.
+	var doc appExport
+	err := json.Unmarshal([]byte(raw), &doc)
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse the app export. Make sure you provided the file exactly as it was exported.", w)
+		return
+	}
+	if doc.FormatVersion < 1 || doc.FormatVersion > appExportFormatVersion {
+		output.ErrorInputInvalid("The app export is from an unsupported version of this app.", w)
+		return
+	}
+
+	//Get user who is importing this app.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Build the new app from the exported settings. Validate() makes sure the name
+	//is not already used. Data specific to the exporting instance is cleared.
+	a := doc.App
+	a.ID = 0
+	a.DatetimeCreated = ""
+	a.DatetimeModified = ""
+	a.CreatedByUserID = loggedInUserID
+	a.Active = true
+	a.LastAppLicenseNumber = 0
+	if name != "" {
+		a.Name = name
+	}
+
+	errMsg, err := a.Validate(r.Context())
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate data about this app.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Make sure the document doesn't have duplicate custom fields or features. This
+	//is normally checked in Validate() against the fields and features already saved
+	//for an app, but the new app doesn't have any saved yet.
+	fieldNames := make(map[string]bool, len(doc.CustomFields))
+	for _, f := range doc.CustomFields {
+		n := strings.TrimSpace(f.Name)
+		if fieldNames[n] {
+			output.ErrorInputInvalid("The custom field "+n+" is included more than once.", w)
+			return
+		}
+		fieldNames[n] = true
+	}
+
+	featureKeys := make(map[string]bool, len(doc.Features))
+	for _, f := range doc.Features {
+		k := strings.TrimSpace(f.FeatureKey)
+		if featureKeys[k] {
+			output.ErrorInputInvalid("The feature "+k+" is included more than once.", w)
+			return
+		}
+		featureKeys[k] = true
+	}
+
+	//Generate the new key pairs, if needed, before starting the transaction since
+	//generating some key pair types is slow. An app that requires more than one
+	//signature needs a key pair for each signature, the first key pair is the default
+	//and the others co-sign each license. The new key pairs use the same algorithm
+	//as the exported app's default key pair, as long as the algorithm is allowed in
+	//this instance.
+	var kk []db.KeyPair
+	if generateKeyPair {
+		algo := keypairs.DefaultAlgorithm()
+		for _, ek := range doc.KeyPairs {
+			if ek.IsDefault && ek.AlgorithmType.Valid() == nil && keypairs.MeetsMinimum(ek.AlgorithmType) {
+				algo = ek.AlgorithmType
+				break
+			}
+		}
+
+		for i := 0; i < a.RequiredSignatures; i++ {
+			k := db.KeyPair{
+				CreatedByUserID: loggedInUserID,
+				Active:          true,
+				Name:            importedKeyPairName,
+				AlgorithmType:   algo,
+				IsDefault:       i == 0,
+			}
+			if i > 0 {
+				k.Name = importedCoSigningKeyPairName + " " + strconv.Itoa(i)
+			}
+
+			errMsg, err := keypairs.GenerateKeys(&k)
+			if err != nil {
+				output.Error(err, errMsg, w)
+				return
+			}
+
+			kk = append(kk, k)
+		}
+	}
+
+	//Save.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save imported app (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = a.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save imported app (2).", w)
+		return
+	}
+
+	//Validate and save each custom field and feature. These are validated now,
+	//rather than before the transaction, since the ID of the new app is needed.
+	//The exported order of the custom fields is kept since each field is added to
+	//the end of the order.
+	for _, f := range doc.CustomFields {
+		f.ID = 0
+		f.AppID = a.ID
+		f.CreatedByUserID = loggedInUserID
+		f.Active = true
+
+		errMsg, err := f.Validate(r.Context())
+		if err != nil {
+			output.Error(err, "Could not validate custom field "+f.Name+".", w)
+			return
+		} else if errMsg != "" {
+			output.ErrorInputInvalid("Custom field "+f.Name+": "+errMsg, w)
+			return
+		}
+
+		err = f.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save custom field "+f.Name+" for imported app.", w)
+			return
+		}
+	}
+
+	for _, f := range doc.Features {
+		f.ID = 0
+		f.AppID = a.ID
+		f.CreatedByUserID = loggedInUserID
+
+		errMsg, err := f.Validate(r.Context())
+		if err != nil {
+			output.Error(err, "Could not validate feature "+f.Name+".", w)
+			return
+		} else if errMsg != "" {
+			output.ErrorInputInvalid("Feature "+f.Name+": "+errMsg, w)
+			return
+		}
+
+		err = f.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save feature "+f.Name+" for imported app.", w)
+			return
+		}
+	}
+
+	for _, k := range kk {
+		k.AppID = a.ID
+		err = k.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save key pair "+k.Name+" for imported app.", w)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save imported app (3).", w)
+		return
+	}
+
+	output.InsertOK(a.ID, w)
+}
@@ -0,0 +1,165 @@
+package apps
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles managing the catalog of features for an app. Features are chosen
+// when a license is created and the key of each enabled feature is stored in the
+// license file.
+
+// GetFeatures returns the list of features for an app. You can optionally filter by
+// active features only.
+func GetFeatures(w http.ResponseWriter, r *http.Request) {
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+	activeOnly, _ := strconv.ParseBool(r.FormValue("activeOnly"))
+
+	if appID < 1 {
+		output.ErrorInputInvalid("Cannot determine which app you want to get features for.", w)
+		return
+	}
+
+	items, err := db.GetAppFeatures(r.Context(), appID, activeOnly)
+	if err != nil {
+		output.Error(err, "Could not get list of features.", w)
+		return
+	}
+
+	output.DataFound(items, w)
+}
+
+// AddFeature saves a new feature for an app.
+func AddFeature(w http.ResponseWriter, r *http.Request) {
+	//Get input data.
+	raw := r.FormValue("data")
+
+	//Parse data into struct.
+	var f db.AppFeature
+	err := json.Unmarshal([]byte(raw), &f)
+	if err != nil {
+		output.Error(err, "Could not parse data to add feature.", w)
+		return
+	}
+
+	//Make sure this isn't being called with an already existing feature.
+	if f.ID != 0 {
+		output.ErrorAlreadyExists("Could not determine if you are adding or updating a feature.", w)
+		return
+	}
+
+	//Validate.
+	errMsg, err := f.Validate(r.Context())
+	if err != nil {
+		output.Error(err, "Could not validate data about this feature.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Get user who is adding this feature.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	f.CreatedByUserID = loggedInUserID
+
+	//Save.
+	err = f.Insert(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save feature.", w)
+		return
+	}
+
+	output.InsertOK(f.ID, w)
+}
+
+// UpdateFeature saves changes to an existing feature. Only the name of a feature can
+// be changed since the key is stored in licenses that were already created.
+func UpdateFeature(w http.ResponseWriter, r *http.Request) {
+	//Get input data.
+	raw := r.FormValue("data")
+
+	//Parse data into struct.
+	var input db.AppFeature
+	err := json.Unmarshal([]byte(raw), &input)
+	if err != nil {
+		output.Error(err, "Could not parse data to update feature.", w)
+		return
+	}
+
+	//Make sure this isn't being called to add a feature.
+	if input.ID < 1 {
+		output.ErrorInputInvalid("Could not determine which feature you are updating.", w)
+		return
+	}
+
+	//Look up the existing feature so that the key cannot be changed.
+	features, err := db.GetAppFeatures(r.Context(), input.AppID, true)
+	if err != nil {
+		output.Error(err, "Could not look up feature.", w)
+		return
+	}
+
+	var f db.AppFeature
+	for _, existing := range features {
+		if existing.ID == input.ID {
+			f = existing
+			break
+		}
+	}
+	if f.ID == 0 {
+		output.ErrorInputInvalid("Could not find the feature you are updating.", w)
+		return
+	}
+	f.Name = input.Name
+
+	//Validate.
+	errMsg, err := f.Validate(r.Context())
+	if err != nil {
+		output.Error(err, "Could not validate data about this feature.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Save.
+	err = f.Update(r.Context())
+	if err != nil {
+		output.Error(err, "Could not update feature.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// DeleteFeature marks a feature as inactive. The feature will no longer be available
+// when creating a license. Licenses that were already created with the feature are
+// not changed.
+func DeleteFeature(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which feature you want to delete.", w)
+		return
+	}
+
+	f := db.AppFeature{
+		ID: id,
+	}
+	err := f.Delete(r.Context())
+	if err != nil {
+		output.Error(err, "Could not delete feature.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
@@ -0,0 +1,46 @@
+package apps
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles versioning the schema of the data in an app's licenses, mostly
+// what each custom field means. When an app's custom fields change in a way that is
+// not backwards compatible, for example a field is renamed or its meaning changes,
+// an administrator bumps the app's license schema version. Licenses created for the
+// app afterwards include the new version in the signed data so that third-party apps
+// can read a license's custom fields based on the version the license was created
+// with.
+//
+// The version is 0, and not included in license files, until it is first bumped so
+// that existing licenses, and their signatures, are not affected.
+
+// BumpLicenseSchemaVersion increments an app's license schema version. The new version
+// is returned.
+func BumpLicenseSchemaVersion(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to bump the license schema version for.", w)
+		return
+	}
+
+	//Save.
+	version, err := db.BumpLicenseSchemaVersion(r.Context(), appID)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The app you want to bump the license schema version for does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not bump license schema version.", w)
+		return
+	}
+
+	output.UpdateOKWithData(version, w)
+}
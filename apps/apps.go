@@ -14,6 +14,7 @@ import (
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
 )
 
 // Add saves a new app.
@@ -57,9 +58,23 @@ func Add(w http.ResponseWriter, r *http.Request) {
 	a.CreatedByUserID = loggedInUserID
 
 	//Save.
-	err = a.Insert(r.Context())
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
 	if err != nil {
-		output.Error(err, "Could not save app.", w)
+		output.Error(err, "Could not save app (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = a.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save app (2).", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save app (3).", w)
 		return
 	}
 
@@ -0,0 +1,55 @@
+package appsettings
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles the announcement shown at the top of each page to logged-in
+// users, for example to notify users of upcoming maintenance. Only one announcement
+// can be set at a time.
+//
+// The announcement is saved with the other app settings but is set separately so that
+// it can be changed while the app is in maintenance mode.
+
+// SetAnnouncement saves the announcement and whether or not it is shown.
+func SetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	active, _ := strconv.ParseBool(r.FormValue("active"))
+	text := r.FormValue("text")
+	severity := r.FormValue("severity")
+
+	//Validate.
+	a := db.AppSettings{
+		AnnouncementActive:   active,
+		AnnouncementText:     text,
+		AnnouncementSeverity: severity,
+	}
+	errMsg := a.ValidateAnnouncement()
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	loggedInUserData, err := users.GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Save.
+	err = a.UpdateAnnouncement(r.Context())
+	if err != nil {
+		output.Error(err, "Could not save announcement.", w)
+		return
+	}
+
+	log.Println("appsettings.SetAnnouncement", "Announcement set to", active, "by", loggedInUserData.Username)
+
+	output.UpdateOK(w)
+}
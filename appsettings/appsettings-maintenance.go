@@ -0,0 +1,43 @@
+package appsettings
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles turning maintenance mode on or off at runtime. While in
+// maintenance mode, requests that make changes are rejected, see middleware.Maintenance.
+//
+// Unlike other app settings, maintenance mode is not saved to the database. The
+// MaintenanceMode field in the config file is used when the app starts.
+
+// MaintenanceMode returns whether or not the app is in maintenance mode.
+func MaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	output.DataFound(config.MaintenanceMode(), w)
+}
+
+// SetMaintenanceMode turns maintenance mode on or off.
+func SetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	//Get input.
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		output.ErrorInputInvalid("Could not determine if maintenance mode should be turned on or off.", w)
+		return
+	}
+
+	loggedInUserData, err := users.GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	config.SetMaintenanceMode(enabled)
+	log.Println("appsettings.SetMaintenanceMode", "Maintenance mode set to", enabled, "by", loggedInUserData.Username)
+
+	output.UpdateOKWithData(enabled, w)
+}
@@ -0,0 +1,92 @@
+/*
+Package backup handles backing up the database. A backup can be made on demand from
+the admin tools page or automatically on a schedule set in the config file. Automatic
+backups that are older than the retention set in the config file are deleted.
+*/
+package backup
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// result is the data returned when a backup is made.
+type result struct {
+	Path string //absolute path to the backup file.
+	Size int64  //size of the backup file in bytes.
+}
+
+// Backup handles making a backup of the database on demand. This is done from the
+// admin tools page. The backup is saved to the directory set in the config file.
+func Backup(w http.ResponseWriter, r *http.Request) {
+	path, size, err := db.Backup(r.Context(), config.Data().BackupPath)
+	if err != nil {
+		output.Error(err, "Could not back up the database.", w)
+		return
+	}
+
+	log.Println("backup.Backup", "database backed up to", path)
+
+	output.InsertOKWithData(result{Path: path, Size: size}, w)
+}
+
+// StartScheduler backs up the database on a ticker based on the interval set in the
+// config file. After each backup, backups older than the retention set in the config
+// file are deleted. This does nothing if automatic backups are disabled.
+//
+// This should be called in a goroutine since it never returns when automatic backups
+// are enabled.
+func StartScheduler() {
+	cfg := config.Data()
+	if cfg.BackupIntervalHours <= 0 {
+		return
+	}
+
+	log.Printf("Backing up database every %d hours to: %s", cfg.BackupIntervalHours, cfg.BackupPath)
+
+	ticker := time.NewTicker(time.Duration(cfg.BackupIntervalHours) * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runScheduled(cfg.BackupPath, cfg.BackupRetentionDays)
+	}
+}
+
+// runScheduled makes a backup and prunes old backups. Errors are logged, not returned,
+// since there is nothing to return them to and we want the next scheduled backup to
+// still run.
+func runScheduled(dir string, retentionDays int) {
+	//Skip while the app is in maintenance mode. An administrator can still back up
+	//the database manually during maintenance.
+	if config.MaintenanceMode() {
+		log.Println("backup.runScheduled", "skipped since app is in maintenance mode")
+		return
+	}
+
+	path, size, err := db.Backup(context.Background(), dir)
+	if err != nil {
+		log.Println("backup.runScheduled", "could not back up database", err)
+		return
+	}
+	log.Println("backup.runScheduled", "database backed up to", path, size, "bytes")
+
+	//Keep backups forever.
+	if retentionDays < 0 {
+		return
+	}
+
+	deleted, err := db.PruneBackups(dir, time.Duration(retentionDays)*24*time.Hour)
+	if err != nil {
+		log.Println("backup.runScheduled", "could not delete old backups", err)
+		return
+	}
+	for _, d := range deleted {
+		log.Println("backup.runScheduled", "deleted old backup", d)
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+import "sync/atomic"
+
+// maintenanceMode is whether or not the app is in read-only maintenance mode. While
+// in maintenance mode, requests that make changes are rejected.
+//
+// This is set in Read() from the MaintenanceMode field in the config file and can be
+// changed at runtime by an administrator. A change at runtime is not saved to the
+// config file, so the config file's value is used again when the app restarts.
+var maintenanceMode atomic.Bool
+
+// MaintenanceMode returns whether or not the app is in maintenance mode.
+func MaintenanceMode() bool {
+	return maintenanceMode.Load()
+}
+
+// SetMaintenanceMode turns maintenance mode on or off.
+func SetMaintenanceMode(on bool) {
+	maintenanceMode.Store(on)
+}
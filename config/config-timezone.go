@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"strconv"
 	"time"
 )
@@ -24,9 +25,22 @@ func GetLocation() *time.Location {
 // This is typically used in SQL queries when comparing or returning the
 // DatetimeCreated column which is stored in the UTC timezone.
 func GetTimezoneOffsetForSQLite() (s string) {
-	//Get the location based on the config Timezone.
-	loc := GetLocation()
+	return timezoneOffsetForSQLite(GetLocation())
+}
+
+// GetTimezoneOffsetForSQLiteFromContext returns the offset to the timezone saved in
+// the context, or the Timezone set in the config file if no timezone was saved, usable
+// in datetime() "-5 hours" format.
+//
+// This is used so that dates and times are displayed in the timezone of the user
+// viewing them, if the user has chosen a timezone.
+func GetTimezoneOffsetForSQLiteFromContext(ctx context.Context) (s string) {
+	return timezoneOffsetForSQLite(GetLocationFromContext(ctx))
+}
 
+// timezoneOffsetForSQLite returns the offset of the location versus UTC in datetime()
+// "-5 hours" format.
+func timezoneOffsetForSQLite(loc *time.Location) (s string) {
 	//Get the current time in the location so we can determine the offset of the
 	//location versus UTC.
 	now := time.Now().In(loc)
@@ -38,3 +52,27 @@ func GetTimezoneOffsetForSQLite() (s string) {
 	s = strconv.Itoa(offsetHours) + " hours"
 	return
 }
+
+type locationContextKeyType string
+
+// locationContextKey is the name of the key that stores the timezone of the user
+// making a request in the request context.
+const locationContextKey locationContextKeyType = "location"
+
+// SetLocationInContext saves a timezone to the context. This is used to save the
+// timezone chosen by the user making a request so that dates and times are displayed
+// in the user's timezone.
+func SetLocationInContext(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationContextKey, loc)
+}
+
+// GetLocationFromContext returns the timezone saved in the context. If no timezone
+// was saved, the timezone from the config file is returned.
+func GetLocationFromContext(ctx context.Context) *time.Location {
+	loc, ok := ctx.Value(locationContextKey).(*time.Location)
+	if !ok || loc == nil {
+		return GetLocation()
+	}
+
+	return loc
+}
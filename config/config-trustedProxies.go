@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies is the list of IP ranges parsed from the TrustedProxies field in the
+// config file. This is set in Read() and used to determine which requests can provide
+// the client's IP address via the X-Forwarded-For header.
+var trustedProxies []netip.Prefix
+
+// parseTrustedProxies parses a list of IP addresses and CIDR ranges. A single IP
+// address is treated as a range containing only that address.
+func parseTrustedProxies(list []string) (pp []netip.Prefix, err error) {
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if strings.Contains(s, "/") {
+			p, innerErr := netip.ParsePrefix(s)
+			if innerErr != nil {
+				return nil, fmt.Errorf("config: TrustedProxies contains an invalid CIDR range %q", s)
+			}
+
+			pp = append(pp, p.Masked())
+			continue
+		}
+
+		a, innerErr := netip.ParseAddr(s)
+		if innerErr != nil {
+			return nil, fmt.Errorf("config: TrustedProxies contains an invalid IP address %q", s)
+		}
+
+		a = a.Unmap()
+		pp = append(pp, netip.PrefixFrom(a, a.BitLen()))
+	}
+
+	return
+}
+
+// IsTrustedProxy returns true if an IP address is one of the TrustedProxies in the
+// config file.
+func IsTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -27,17 +27,24 @@ package config
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/c9845/licensekeys/v3/licensefile"
 	"github.com/c9845/licensekeys/v3/version"
 	"gopkg.in/yaml.v2"
 
@@ -50,6 +57,10 @@ import (
 // DefaultConfigFileName is the typical name of the config file.
 const DefaultConfigFileName = "licensekeys.conf"
 
+// DefaultInitialUserUsername is the username of the administrator user created when
+// the database is deployed if InitialUserUsername is not set in the config file.
+const DefaultInitialUserUsername = "admin@example.com"
+
 // File defines the list of configuration fields. The value for each field will be set
 // by a default or read from a config file. The config file is typically stored in the
 // same directory as the executable.
@@ -61,19 +72,98 @@ type File struct {
 	DBPath        string `yaml:"DBPath"`        //The path the the database file.
 	DBJournalMode string `yaml:"DBJournalMode"` //Sets the mode for writing to the database file; delete or wal.
 
+	AppSettingsCacheSeconds int `yaml:"AppSettingsCacheSeconds"` //How long app settings are cached in memory before being looked up again. Changes made in the app are seen immediately. 0 disables caching.
+
+	BackupPath          string `yaml:"BackupPath"`          //The path to the directory where database backups are saved.
+	BackupIntervalHours int    `yaml:"BackupIntervalHours"` //How often to back up the database automatically. 0 disables automatic backups.
+	BackupRetentionDays int    `yaml:"BackupRetentionDays"` //How long automatic backups are kept before being deleted. -1 keeps backups forever.
+
 	WebFilesStore string `yaml:"WebFilesStore"` //Where HTML, CSS, and JS will be sourced and served from; on-disk, on-disk-memory, or embedded.
 	WebFilesPath  string `yaml:"WebFilesPath"`  //The absolute path to the directory storing the app's HTML, CSS and JS files.
 	UseLocalFiles bool   `yaml:"UseLocalFiles"` //Serve third-party CSS and JS files from this app's files or from an internet CDN.
 	Host          string `yaml:"Host"`          //The host the app listens on. Default is 127.0.0.1, aka localhost. Set to server's IP, or 0.0.0.0, to be able to access app directly on host:port without a proxy.
 	Port          int    `yaml:"Port"`          //The port the app serves on. An HTTPS terminating proxy should redirect port 80 here.
+	BaseURLPath   string `yaml:"BaseURLPath"`   //The path the app is served under when a proxy serves the app at a subpath, i.e.: /licenses for example.com/licenses/. Blank when the app is served at the root.
+	ExternalURL   string `yaml:"ExternalURL"`   //The scheme and host users and customers use to reach the app, i.e.: https://licenses.example.com. Used to build links that are opened outside of the app, such as license download links, and to verify passkeys. Does not include BaseURLPath.
+
+	MaxRequestBodyMB int `yaml:"MaxRequestBodyMB"` //The largest request body, in megabytes, that will be accepted. Requests with larger bodies are rejected to prevent memory exhaustion.
+
+	TrustedProxies []string `yaml:"TrustedProxies"` //The IP addresses, or CIDR ranges, of the proxies in front of this app. The client's IP address is only read from the X-Forwarded-For header for requests from these proxies.
+
+	MaintenanceMode bool `yaml:"MaintenanceMode"` //Start the app in read-only maintenance mode where requests that make changes are rejected. Can be toggled at runtime by an administrator.
+
+	ReadHeaderTimeoutSeconds int `yaml:"ReadHeaderTimeoutSeconds"` //The time allowed to read a request's headers. Prevents slow clients from holding connections open.
+	ReadTimeoutSeconds       int `yaml:"ReadTimeoutSeconds"`       //The time allowed to read an entire request, including the body.
+	WriteTimeoutSeconds      int `yaml:"WriteTimeoutSeconds"`      //The time allowed to write a response, measured from when the request's headers were read.
+	IdleTimeoutSeconds       int `yaml:"IdleTimeoutSeconds"`       //The time an idle keep-alive connection is kept open waiting for the next request.
+
+	TLSCertPath     string `yaml:"TLSCertPath"`     //The absolute path to the TLS certificate file. If this and TLSKeyPath are set, the app serves HTTPS directly instead of relying on a terminating proxy.
+	TLSKeyPath      string `yaml:"TLSKeyPath"`      //The absolute path to the TLS private key file.
+	TLSRedirectPort int    `yaml:"TLSRedirectPort"` //The port to listen on for HTTP requests that will be redirected to HTTPS. 0 disables the redirect. Only used when TLS is enabled.
+
+	CookieSecure   string `yaml:"CookieSecure"`   //When the session and 2FA cookies are marked Secure, so they are only sent over HTTPS; auto, always, or never. auto marks cookies Secure when TLS is enabled. Use always when a terminating proxy serves the app over HTTPS.
+	CookieSameSite string `yaml:"CookieSameSite"` //The SameSite attribute of the session and 2FA cookies; lax, strict, or none. none requires cookies to be Secure.
+
+	APIAllowedOrigins               []string `yaml:"APIAllowedOrigins"`               //The origins, i.e.: https://example.com, browser-based clients can call the public API from. CORS headers are only sent for these origins.
+	APIIdempotencyKeyRetentionHours int      `yaml:"APIIdempotencyKeyRetentionHours"` //How long an Idempotency-Key provided when creating a license via the public API is remembered. A repeated request with the same key within this time returns the original license.
+
+	APIFailedAuthThreshold     int `yaml:"APIFailedAuthThreshold"`     //The number of requests to the public API with an invalid API key, from an IP address within APIFailedAuthWindowMinutes, after which the IP address is blocked. 0 disables blocking.
+	APIFailedAuthWindowMinutes int `yaml:"APIFailedAuthWindowMinutes"` //The period of time requests with an invalid API key are counted within.
+	APIFailedAuthBlockMinutes  int `yaml:"APIFailedAuthBlockMinutes"`  //How long an IP address is blocked from the public API for.
+
+	LicenseSeatsFieldName   string `yaml:"LicenseSeatsFieldName"`   //The name of the custom field, an integer, that sets the maximum number of seats for a floating license.
+	LicenseSeatLeaseMinutes int    `yaml:"LicenseSeatLeaseMinutes"` //How long a checked out seat is held before it is reclaimed unless the client renews the lease.
+
+	LicenseActivationsFieldName string `yaml:"LicenseActivationsFieldName"` //The name of the custom field, an integer, that sets the maximum number of machines a license can be activated on.
+
+	LicenseNoteCategories []string `yaml:"LicenseNoteCategories"` //The categories a license note can be given, i.e.: support or billing. LicenseNoteCategoryUncategorized is always allowed.
+
+	SigningConcurrency int `yaml:"SigningConcurrency"` //The maximum number of licenses signed and verified at the same time during bulk operations. 0 uses GOMAXPROCS.
+
+	MaxLicenseDurationDays int `yaml:"MaxLicenseDurationDays"` //The furthest, in days from today, a license's expiration date can be set when creating, renewing, or extending a license. 0 means no limit.
+
+	DownloadLinkSecret        string `yaml:"DownloadLinkSecret"`        //The key used to sign links customers can use to download a license without logging in. If not provided, a random key is used and links become invalid when the app restarts.
+	DownloadLinkLifetimeHours int    `yaml:"DownloadLinkLifetimeHours"` //The time a license download link is valid for.
+
+	AttachmentsPath        string   `yaml:"AttachmentsPath"`        //The path to the directory where files attached to licenses are saved.
+	AttachmentMaxSizeMB    int      `yaml:"AttachmentMaxSizeMB"`    //The largest file, in megabytes, that can be attached to a license.
+	AttachmentContentTypes []string `yaml:"AttachmentContentTypes"` //The types of files, i.e.: application/pdf, that can be attached to a license. The type is detected from the file's contents.
+
+	NotificationWebhookURL string   `yaml:"NotificationWebhookURL"` //The Slack or Microsoft Teams compatible incoming webhook URL messages are posted to when certain events occur. If not provided, notifications are disabled.
+	NotificationEvents     []string `yaml:"NotificationEvents"`     //The events that cause a notification to be posted.
+
+	SMTPHost     string `yaml:"SMTPHost"`     //The host of the SMTP server used to send email. If not provided, email is not sent.
+	SMTPPort     int    `yaml:"SMTPPort"`     //The port of the SMTP server. STARTTLS is used if the server supports it.
+	SMTPUsername string `yaml:"SMTPUsername"` //The username to authenticate to the SMTP server with. If not provided, authentication is not used.
+	SMTPPassword string `yaml:"SMTPPassword"` //The password to authenticate to the SMTP server with.
+	SMTPFrom     string `yaml:"SMTPFrom"`     //The email address email is sent from.
+
+	ActivityReportIntervalDays int      `yaml:"ActivityReportIntervalDays"` //How often a report of license activity is emailed. 0 disables the report.
+	ActivityReportRecipients   []string `yaml:"ActivityReportRecipients"`   //The email addresses the report of license activity is sent to.
+
+	InitialUserUsername string `yaml:"InitialUserUsername"` //The username, an email address, of the administrator user created when the database is deployed.
+
+	AllowedUserEmailDomains []string `yaml:"AllowedUserEmailDomains"` //The email domains, i.e.: example.com, a user's username can be at. If not provided, any domain is allowed.
 
 	LoginLifetimeHours        float64 `yaml:"LoginLifetimeHours"`        //The time a user will remain logged in for.
 	TwoFactorAuthLifetimeDays int     `yaml:"TwoFactorAuthLifetimeDays"` //The time between when a 2FA token will be required. -1 requires it upon each login.
+	UserLoginRetentionDays    int     `yaml:"UserLoginRetentionDays"`    //How long inactive or expired user logins are kept before being deleted. 0 keeps user logins forever.
+
+	TwoFactorAuthDigits        int    `yaml:"TwoFactorAuthDigits"`        //The number of digits in each 2FA token, 6 to 8. Only used for newly enrolled users.
+	TwoFactorAuthPeriodSeconds int    `yaml:"TwoFactorAuthPeriodSeconds"` //How often a new 2FA token is generated. Only used for newly enrolled users.
+	TwoFactorAuthAlgorithm     string `yaml:"TwoFactorAuthAlgorithm"`     //The hash algorithm used to generate 2FA tokens; SHA1, SHA256, or SHA512. Only used for newly enrolled users.
+
+	PasskeyRPID string `yaml:"PasskeyRPID"` //The domain passkeys are registered to, the WebAuthn relying party ID. Must be the host of ExternalURL or a parent domain of it. If not provided, the host of ExternalURL is used.
+
+	FailedLoginAlertThreshold     int `yaml:"FailedLoginAlertThreshold"`     //The number of failed logins for a user, within FailedLoginAlertWindowMinutes, that records a security event and posts a notification. 0 disables alerting.
+	FailedLoginAlertWindowMinutes int `yaml:"FailedLoginAlertWindowMinutes"` //The period of time failed logins are counted within.
 
 	Timezone                string `yaml:"Timezone"`                //Timezone in IANA format for displaying dates and times.
 	MinPasswordLength       int    `yaml:"MinPasswordLength"`       //The shortest length a new password can be.
 	PrivateKeyEncryptionKey string `yaml:"PrivateKeyEncryptionKey"` //The key used to encrypt/decrypt the private keys stored in the db. This was if the db is compromised, the keys cannot be used. If not provided, private keys are stored in plaintext. Must be 16, 24, or 32 characters.
 
+	MinimumKeyPairAlgorithm licensefile.KeyPairAlgoType `yaml:"MinimumKeyPairAlgorithm"` //The weakest key pair algorithm that can be used to generate key pairs and sign new licenses. Existing licenses still verify. If not provided, any algorithm can be used.
+
 	//undocumented, not for end-user usage
 	//Make sure each of these fields is in nonPublishedFields to prevent logging.
 	Development bool `yaml:"Development"` //shows header in app that app is in development, uses non minified CSS & JSS, enabled some debugging, extra logging, etc.
@@ -94,6 +184,10 @@ var nonPublishedFields = []string{
 // Use the Data() func to get the data for use elsewhere.
 var parsedConfig File
 
+// loaded is set once the config has been read, validated, and saved to parsedConfig.
+// This is used to report the config's status in healthchecks.
+var loaded bool
+
 // Stuff used for validation.
 const (
 	portMin = 1024
@@ -104,6 +198,37 @@ const (
 
 	WebFilesStoreOnDisk   = "on-disk"
 	WebFilesStoreEmbedded = "embedded"
+
+	CookieSecureAuto   = "auto"
+	CookieSecureAlways = "always"
+	CookieSecureNever  = "never"
+
+	CookieSameSiteLax    = "lax"
+	CookieSameSiteStrict = "strict"
+	CookieSameSiteNone   = "none"
+
+	TwoFactorAuthAlgorithmSHA1   = "SHA1"
+	TwoFactorAuthAlgorithmSHA256 = "SHA256"
+	TwoFactorAuthAlgorithmSHA512 = "SHA512"
+
+	//The range of 2FA token lengths and periods allowed. RFC 4226 requires at
+	//least 6 digits and allows up to 8. RFC 6238 recommends a 30 second period,
+	//the range allows for stricter, or more lenient, policies without making
+	//tokens either impossible to type in time or valid for too long.
+	twoFactorAuthDigitsMin        = 6
+	twoFactorAuthDigitsMax        = 8
+	twoFactorAuthPeriodSecondsMin = 15
+	twoFactorAuthPeriodSecondsMax = 300
+
+	NotificationEventLicenseCreated  = "license-created"
+	NotificationEventLicenseDisabled = "license-disabled"
+	NotificationEventAdminUserAdded  = "admin-user-added"
+	NotificationEventFailedLogins    = "failed-logins"
+
+	//LicenseNoteCategoryUncategorized is the category of notes added without a
+	//category, including notes added automatically and notes added before categories
+	//existed.
+	LicenseNoteCategoryUncategorized = "uncategorized"
 )
 
 var (
@@ -111,6 +236,27 @@ var (
 		DBJournalModeRollback,
 		DBJournalModeWAL,
 	}
+
+	validNotificationEvents = []string{
+		NotificationEventLicenseCreated,
+		NotificationEventLicenseDisabled,
+		NotificationEventAdminUserAdded,
+		NotificationEventFailedLogins,
+	}
+
+	defaultLicenseNoteCategories = []string{
+		LicenseNoteCategoryUncategorized,
+		"support",
+		"billing",
+		"renewal",
+	}
+
+	defaultAttachmentContentTypes = []string{
+		"application/pdf",
+		"image/png",
+		"image/jpeg",
+		"text/plain",
+	}
 )
 
 // Errors.
@@ -132,23 +278,104 @@ func newDefaultConfig() (f File, err error) {
 	//(like "did not find expected hexdecimal number" when path string is surrounded
 	//by double quotes, on Windows).
 	dbPath := filepath.ToSlash(filepath.Join(workingDir, "licensekeys.db"))
+	backupPath := filepath.ToSlash(filepath.Join(workingDir, "backups"))
+	attachmentsPath := filepath.ToSlash(filepath.Join(workingDir, "attachments"))
 
 	f = File{
 		DBPath:        dbPath,                //
 		DBJournalMode: DBJournalModeRollback, //DELETE is more safe and easier to use in Docker (see Dockerfile).
 
+		AppSettingsCacheSeconds: 10, //short so that changes made directly to the database are seen quickly.
+
+		BackupPath:          backupPath, //
+		BackupIntervalHours: 0,          //automatic backups are disabled by default.
+		BackupRetentionDays: 30,         //just a safe default.
+
 		WebFilesStore: WebFilesStoreEmbedded, //embedded means less files to distribute
 		WebFilesPath:  "",                    //not needed for default embedded file, not set to make config file cleaner and less confusing.
 		UseLocalFiles: true,                  //prefer our distributed files, prevents issues with CDNs.
 		Host:          "127.0.0.1",           //Listen within localhost only.
 		Port:          8007,                  //
+		BaseURLPath:   "",                    //served at root by default.
+		ExternalURL:   "",                    //unknown, depends on how the app is deployed.
+
+		MaxRequestBodyMB: 10, //large enough for importing batches of licenses.
+
+		TrustedProxies: []string{"127.0.0.1", "::1"}, //a proxy on the same server, the same as the default Host.
+
+		MaintenanceMode: false, //
+
+		ReadHeaderTimeoutSeconds: 10,  //plenty for any real client.
+		ReadTimeoutSeconds:       30,  //large enough for uploading batches of licenses to import.
+		WriteTimeoutSeconds:      120, //large enough for slow requests, i.e.: backing up the database.
+		IdleTimeoutSeconds:       120, //
+
+		TLSCertPath:     "", //TLS is disabled by default, a terminating proxy is expected.
+		TLSKeyPath:      "", //
+		TLSRedirectPort: 0,  //no redirect by default.
+
+		CookieSecure:   CookieSecureAuto,  //secure when serving HTTPS directly, a terminating proxy may serve the app over http.
+		CookieSameSite: CookieSameSiteLax, //strict breaks browsing from history in chrome.
+
+		APIAllowedOrigins:               []string{}, //public API cannot be called from browsers on other origins by default.
+		APIIdempotencyKeyRetentionHours: 24,         //long enough to cover any reasonable retrying of a request.
+
+		APIFailedAuthThreshold:     20, //high enough that a misconfigured client won't be blocked immediately.
+		APIFailedAuthWindowMinutes: 15, //
+		APIFailedAuthBlockMinutes:  15, //
+
+		LicenseSeatsFieldName:   "MaxSeats", //
+		LicenseSeatLeaseMinutes: 15,         //short enough that seats from crashed clients are reclaimed quickly.
+
+		LicenseActivationsFieldName: "MaxActivations", //
+
+		LicenseNoteCategories: slices.Clone(defaultLicenseNoteCategories), //
+
+		SigningConcurrency: 0, //set to GOMAXPROCS when validated.
+
+		MaxLicenseDurationDays: 0, //no limit by default, existing licenses may have long durations.
+
+		DownloadLinkSecret:        "", //random key generated when a default config is created.
+		DownloadLinkLifetimeHours: 72, //long enough for a customer to receive and use an emailed link.
+
+		AttachmentsPath:        attachmentsPath,                             //
+		AttachmentMaxSizeMB:    5,                                           //large enough for a signed contract.
+		AttachmentContentTypes: slices.Clone(defaultAttachmentContentTypes), //documents and images.
+
+		NotificationWebhookURL: "",                                    //notifications are disabled by default.
+		NotificationEvents:     slices.Clone(validNotificationEvents), //all events, noisy events can be removed.
+
+		SMTPHost:     "",  //email is not sent by default.
+		SMTPPort:     587, //submission port, most servers support STARTTLS on this port.
+		SMTPUsername: "",  //
+		SMTPPassword: "",  //
+		SMTPFrom:     "",  //
+
+		ActivityReportIntervalDays: 0,          //report is disabled by default.
+		ActivityReportRecipients:   []string{}, //
+
+		InitialUserUsername: DefaultInitialUserUsername, //
+
+		AllowedUserEmailDomains: []string{}, //any domain is allowed by default.
 
 		LoginLifetimeHours:        1,  //just a safe default.
 		TwoFactorAuthLifetimeDays: 14, //just a safe default.
+		UserLoginRetentionDays:    0,  //keep user logins forever, as was done before this setting existed.
+
+		TwoFactorAuthDigits:        6,                          //what authenticator apps expect by default.
+		TwoFactorAuthPeriodSeconds: 30,                         //recommended by RFC 6238.
+		TwoFactorAuthAlgorithm:     TwoFactorAuthAlgorithmSHA1, //some authenticator apps only support SHA1.
+
+		PasskeyRPID: "", //use host of ExternalURL.
+
+		FailedLoginAlertThreshold:     10, //
+		FailedLoginAlertWindowMinutes: 15, //
 
 		Timezone:                "UTC", //tried using time.Local.String() but this returns "Local" as the timezone which doesn't have much meaning when displayed in the GUI.
 		MinPasswordLength:       10,    //the shortest we allow, same as set in pwds package.
 		PrivateKeyEncryptionKey: "",    //no encryption by default
+
+		MinimumKeyPairAlgorithm: "", //any algorithm can be used by default.
 	}
 	return
 }
@@ -219,6 +446,9 @@ func Read(path string, print bool) (err error) {
 		//security.
 		cfg.PrivateKeyEncryptionKey = getRandomEncryptionKey()
 
+		//Get a random key to sign license download links with.
+		cfg.DownloadLinkSecret = getRandomEncryptionKey()
+
 		//Save the default config to the file noted in the provided path.
 		innerErr = cfg.write(path)
 		if innerErr != nil {
@@ -274,6 +504,14 @@ func Read(path string, print bool) (err error) {
 	}
 	tzLoc = loc
 
+	//Handle trusted proxies configuration. This was already validated.
+	trustedProxies, _ = parseTrustedProxies(cfg.TrustedProxies)
+
+	//Set the initial maintenance mode state. This can be changed at runtime.
+	SetMaintenanceMode(cfg.MaintenanceMode)
+
+	loaded = err == nil
+
 	//Print the config, if needed, as it was sanitized and validated. This logs out
 	//the config as it was understood by the app and some changes may have been made
 	//(for example, user provided an invalid value for a field and a default value
@@ -366,6 +604,8 @@ func (conf *File) validate() (err error) {
 	//Clean all paths, regardless of if they are used.
 	conf.DBPath = filepath.Clean(filepath.ToSlash(strings.TrimSpace(conf.DBPath)))
 	conf.WebFilesPath = filepath.Clean(filepath.ToSlash(strings.TrimSpace(conf.WebFilesPath)))
+	conf.BackupPath = filepath.Clean(filepath.ToSlash(strings.TrimSpace(conf.BackupPath)))
+	conf.AttachmentsPath = filepath.Clean(filepath.ToSlash(strings.TrimSpace(conf.AttachmentsPath)))
 
 	//Clean results in empty paths ("") being returned as ".". This is annoying to
 	//deal with; we just want blank strings if the input from the config file field
@@ -376,6 +616,12 @@ func (conf *File) validate() (err error) {
 	if conf.WebFilesPath == "." {
 		conf.WebFilesPath = ""
 	}
+	if conf.BackupPath == "." {
+		conf.BackupPath = ""
+	}
+	if conf.AttachmentsPath == "." {
+		conf.AttachmentsPath = ""
+	}
 
 	//Database related.
 	conf.DBPath = filepath.FromSlash(strings.TrimSpace(conf.DBPath))
@@ -395,6 +641,237 @@ func (conf *File) validate() (err error) {
 		conf.DBJournalMode = defaults.DBJournalMode
 	}
 
+	if conf.AppSettingsCacheSeconds < 0 {
+		log.Println("WARNING! (config) AppSettingsCacheSeconds is invalid. The value must be 0 or greater. Disabling caching of app settings.")
+		conf.AppSettingsCacheSeconds = 0
+	}
+
+	//Backup related.
+	conf.BackupPath = filepath.FromSlash(conf.BackupPath)
+	if conf.BackupPath == "" {
+		conf.BackupPath = filepath.FromSlash(defaults.BackupPath)
+	}
+
+	if conf.BackupIntervalHours < 0 {
+		conf.BackupIntervalHours = defaults.BackupIntervalHours
+		log.Println("WARNING! (config) BackupIntervalHours is invalid. The value must be 0 or greater. Disabling automatic backups.")
+	}
+
+	if conf.BackupRetentionDays == 0 {
+		conf.BackupRetentionDays = defaults.BackupRetentionDays
+	} else if conf.BackupRetentionDays < 0 {
+		//Special case. If a negative number is provided, automatic backups are never
+		//deleted.
+		//
+		//_ = "" to remove "empty branch" staticcheck linter warning. This branch
+		//is here just for the comments to explain why <0 is a special case.
+		_ = ""
+	}
+
+	//Public API related. Origins are compared exactly to the Origin header sent by
+	//browsers, which never includes a trailing slash or path.
+	origins := []string{}
+	for _, o := range conf.APIAllowedOrigins {
+		o = strings.TrimSuffix(strings.TrimSpace(o), "/")
+		if o == "" {
+			continue
+		}
+		if o == "*" {
+			log.Println("WARNING! (config) APIAllowedOrigins contains \"*\", the public API can be called from browsers on any origin.")
+		}
+
+		origins = append(origins, o)
+	}
+	conf.APIAllowedOrigins = origins
+
+	if conf.APIIdempotencyKeyRetentionHours == 0 {
+		conf.APIIdempotencyKeyRetentionHours = defaults.APIIdempotencyKeyRetentionHours
+	} else if conf.APIIdempotencyKeyRetentionHours < 0 {
+		conf.APIIdempotencyKeyRetentionHours = defaults.APIIdempotencyKeyRetentionHours
+		log.Printf("WARNING! (config) APIIdempotencyKeyRetentionHours is invalid. The value must be greater than 0. Defaulting to %d.", conf.APIIdempotencyKeyRetentionHours)
+	}
+
+	if conf.APIFailedAuthThreshold < 0 {
+		log.Println("WARNING! (config) APIFailedAuthThreshold is invalid. The value must be 0 or greater. Disabling blocking of IP addresses.")
+		conf.APIFailedAuthThreshold = 0
+	}
+	if conf.APIFailedAuthWindowMinutes == 0 {
+		conf.APIFailedAuthWindowMinutes = defaults.APIFailedAuthWindowMinutes
+	} else if conf.APIFailedAuthWindowMinutes < 0 {
+		conf.APIFailedAuthWindowMinutes = defaults.APIFailedAuthWindowMinutes
+		log.Printf("WARNING! (config) APIFailedAuthWindowMinutes is invalid. The value must be greater than 0. Defaulting to %d.", conf.APIFailedAuthWindowMinutes)
+	}
+	if conf.APIFailedAuthBlockMinutes == 0 {
+		conf.APIFailedAuthBlockMinutes = defaults.APIFailedAuthBlockMinutes
+	} else if conf.APIFailedAuthBlockMinutes < 0 {
+		conf.APIFailedAuthBlockMinutes = defaults.APIFailedAuthBlockMinutes
+		log.Printf("WARNING! (config) APIFailedAuthBlockMinutes is invalid. The value must be greater than 0. Defaulting to %d.", conf.APIFailedAuthBlockMinutes)
+	}
+
+	//License seats related.
+	conf.LicenseSeatsFieldName = strings.TrimSpace(conf.LicenseSeatsFieldName)
+	if conf.LicenseSeatsFieldName == "" {
+		conf.LicenseSeatsFieldName = defaults.LicenseSeatsFieldName
+	}
+
+	if conf.LicenseSeatLeaseMinutes == 0 {
+		conf.LicenseSeatLeaseMinutes = defaults.LicenseSeatLeaseMinutes
+	} else if conf.LicenseSeatLeaseMinutes < 0 {
+		conf.LicenseSeatLeaseMinutes = defaults.LicenseSeatLeaseMinutes
+		log.Printf("WARNING! (config) LicenseSeatLeaseMinutes is invalid. The value must be greater than 0. Defaulting to %d.", conf.LicenseSeatLeaseMinutes)
+	}
+
+	//License activations related.
+	conf.LicenseActivationsFieldName = strings.TrimSpace(conf.LicenseActivationsFieldName)
+	if conf.LicenseActivationsFieldName == "" {
+		conf.LicenseActivationsFieldName = defaults.LicenseActivationsFieldName
+	}
+
+	//License notes related. Categories are lowercased so that matching a note's
+	//category is case insensitive. The uncategorized category is always included so
+	//that notes without a category can always be saved.
+	if conf.LicenseNoteCategories == nil {
+		conf.LicenseNoteCategories = defaults.LicenseNoteCategories
+	} else {
+		categories := []string{LicenseNoteCategoryUncategorized}
+		for _, c := range conf.LicenseNoteCategories {
+			c = strings.ToLower(strings.TrimSpace(c))
+			if c == "" || slices.Contains(categories, c) {
+				continue
+			}
+
+			categories = append(categories, c)
+		}
+		conf.LicenseNoteCategories = categories
+	}
+
+	//License signing related.
+	if conf.SigningConcurrency == 0 {
+		conf.SigningConcurrency = runtime.GOMAXPROCS(0)
+	} else if conf.SigningConcurrency < 0 {
+		conf.SigningConcurrency = runtime.GOMAXPROCS(0)
+		log.Printf("WARNING! (config) SigningConcurrency is invalid. The value must be 0 or greater. Defaulting to %d.", conf.SigningConcurrency)
+	}
+
+	//License duration related.
+	if conf.MaxLicenseDurationDays < 0 {
+		conf.MaxLicenseDurationDays = defaults.MaxLicenseDurationDays
+		log.Printf("WARNING! (config) MaxLicenseDurationDays is invalid. The value must be 0 or greater. Defaulting to %d.", conf.MaxLicenseDurationDays)
+	}
+
+	//License download link related.
+	if conf.DownloadLinkSecret == "" {
+		conf.DownloadLinkSecret = getRandomEncryptionKey()
+		log.Println("WARNING! (config) DownloadLinkSecret is not set. A random key will be used and license download links will become invalid when the app restarts.")
+	}
+
+	if conf.DownloadLinkLifetimeHours == 0 {
+		conf.DownloadLinkLifetimeHours = defaults.DownloadLinkLifetimeHours
+	} else if conf.DownloadLinkLifetimeHours < 0 {
+		conf.DownloadLinkLifetimeHours = defaults.DownloadLinkLifetimeHours
+		log.Printf("WARNING! (config) DownloadLinkLifetimeHours is invalid. The value must be greater than 0. Defaulting to %d.", conf.DownloadLinkLifetimeHours)
+	}
+
+	//License attachment related.
+	conf.AttachmentsPath = filepath.FromSlash(conf.AttachmentsPath)
+	if conf.AttachmentsPath == "" {
+		conf.AttachmentsPath = filepath.FromSlash(defaults.AttachmentsPath)
+	}
+
+	if conf.AttachmentMaxSizeMB == 0 {
+		conf.AttachmentMaxSizeMB = defaults.AttachmentMaxSizeMB
+	} else if conf.AttachmentMaxSizeMB < 0 {
+		conf.AttachmentMaxSizeMB = defaults.AttachmentMaxSizeMB
+		log.Printf("WARNING! (config) AttachmentMaxSizeMB is invalid. The value must be greater than 0. Defaulting to %d.", conf.AttachmentMaxSizeMB)
+	}
+
+	if conf.AttachmentContentTypes == nil {
+		conf.AttachmentContentTypes = defaults.AttachmentContentTypes
+	} else {
+		//An empty list is allowed and prevents files from being attached.
+		types := []string{}
+		for _, t := range conf.AttachmentContentTypes {
+			t = strings.ToLower(strings.TrimSpace(t))
+			mediaType, _, innerErr := mime.ParseMediaType(t)
+			if innerErr != nil || mediaType != t {
+				log.Printf("WARNING! (config) AttachmentContentTypes contains an invalid type %q, ignoring. Types must be provided without parameters, i.e.: application/pdf.", t)
+				continue
+			}
+
+			types = append(types, t)
+		}
+		conf.AttachmentContentTypes = types
+	}
+
+	//Notification related. An invalid webhook URL disables notifications instead of
+	//causing the app to exit since notifications are not critical.
+	conf.NotificationWebhookURL = strings.TrimSpace(conf.NotificationWebhookURL)
+	if conf.NotificationWebhookURL != "" {
+		u, innerErr := url.Parse(conf.NotificationWebhookURL)
+		if innerErr != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			conf.NotificationWebhookURL = ""
+			log.Println("WARNING! (config) NotificationWebhookURL is invalid. The value must be an http or https URL. Disabling notifications.")
+		}
+	}
+
+	if conf.NotificationEvents == nil {
+		conf.NotificationEvents = defaults.NotificationEvents
+	} else {
+		events := []string{}
+		for _, e := range conf.NotificationEvents {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if !slices.Contains(validNotificationEvents, e) {
+				log.Printf("WARNING! (config) NotificationEvents contains an invalid event %q, ignoring. Valid events are %s.", e, strings.Join(validNotificationEvents, ", "))
+				continue
+			}
+
+			events = append(events, e)
+		}
+		conf.NotificationEvents = events
+	}
+
+	//Email related. Invalid settings disable sending email, instead of causing the
+	//app to exit, since email is not critical.
+	conf.SMTPHost = strings.TrimSpace(conf.SMTPHost)
+	conf.SMTPUsername = strings.TrimSpace(conf.SMTPUsername)
+	conf.SMTPFrom = strings.TrimSpace(conf.SMTPFrom)
+
+	if conf.SMTPPort == 0 {
+		conf.SMTPPort = defaults.SMTPPort
+	} else if conf.SMTPPort < 1 || conf.SMTPPort > portMax {
+		conf.SMTPPort = defaults.SMTPPort
+		log.Printf("WARNING! (config) SMTPPort is invalid. The value must be between 1 and %d. Defaulting to %d.", portMax, conf.SMTPPort)
+	}
+
+	if conf.SMTPHost != "" {
+		if _, innerErr := mail.ParseAddress(conf.SMTPFrom); innerErr != nil {
+			conf.SMTPHost = ""
+			log.Println("WARNING! (config) SMTPFrom is invalid. The value must be an email address. Disabling email.")
+		}
+	}
+
+	if conf.ActivityReportIntervalDays < 0 {
+		conf.ActivityReportIntervalDays = 0
+		log.Println("WARNING! (config) ActivityReportIntervalDays is invalid. The value must be 0 or greater. Disabling activity report.")
+	}
+
+	recipients := []string{}
+	for _, r := range conf.ActivityReportRecipients {
+		r = strings.TrimSpace(r)
+		if _, innerErr := mail.ParseAddress(r); innerErr != nil {
+			log.Printf("WARNING! (config) ActivityReportRecipients contains an invalid email address %q, ignoring.", r)
+			continue
+		}
+
+		recipients = append(recipients, r)
+	}
+	conf.ActivityReportRecipients = recipients
+
+	if conf.ActivityReportIntervalDays > 0 && len(conf.ActivityReportRecipients) == 0 {
+		conf.ActivityReportIntervalDays = 0
+		log.Println("WARNING! (config) ActivityReportRecipients is empty. Disabling activity report.")
+	}
+
 	//Web server settings.
 	switch conf.WebFilesStore {
 	case WebFilesStoreOnDisk:
@@ -427,6 +904,157 @@ func (conf *File) validate() (err error) {
 		log.Printf("WARNING! (config) Port is invalid. The value must be between %d and %d. Defaulting to %d.", portMin, portMax, conf.Port)
 	}
 
+	//BaseURLPath is stored with a leading slash and without a trailing slash so that
+	//it can be prepended to paths that start with a slash.
+	conf.BaseURLPath = strings.Trim(strings.TrimSpace(conf.BaseURLPath), "/")
+	if conf.BaseURLPath != "" {
+		conf.BaseURLPath = "/" + conf.BaseURLPath
+		if strings.ContainsAny(conf.BaseURLPath, "?#% ") || strings.Contains(conf.BaseURLPath, "//") {
+			return errors.New("config: BaseURLPath is invalid, it must be a path such as /licenses")
+		}
+	}
+
+	//ExternalURL is stored without a trailing slash so that paths, which start with a
+	//slash, can be appended to it. The host is lowercased so that ExternalURL matches
+	//the origin a browser reports, which is used to verify passkeys.
+	conf.ExternalURL = strings.TrimRight(strings.TrimSpace(conf.ExternalURL), "/")
+	if conf.ExternalURL != "" {
+		u, innerErr := url.Parse(conf.ExternalURL)
+		if innerErr != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return errors.New("config: ExternalURL is invalid, it must be a scheme and host such as https://licenses.example.com")
+		}
+		conf.ExternalURL = u.Scheme + "://" + strings.ToLower(u.Host)
+	}
+
+	//PasskeyRPID must be the host of ExternalURL, or a parent domain of it, otherwise
+	//browsers will refuse to use passkeys.
+	conf.PasskeyRPID = strings.ToLower(strings.TrimSpace(conf.PasskeyRPID))
+	if conf.PasskeyRPID != "" {
+		if conf.ExternalURL == "" {
+			return errors.New("config: PasskeyRPID requires ExternalURL to be set")
+		}
+
+		u, _ := url.Parse(conf.ExternalURL)
+		if host := u.Hostname(); host != conf.PasskeyRPID && !strings.HasSuffix(host, "."+conf.PasskeyRPID) {
+			return errors.New("config: PasskeyRPID is invalid, it must be the host of ExternalURL or a parent domain of it")
+		}
+	}
+
+	if conf.MaxRequestBodyMB == 0 {
+		conf.MaxRequestBodyMB = defaults.MaxRequestBodyMB
+	} else if conf.MaxRequestBodyMB < 0 {
+		conf.MaxRequestBodyMB = defaults.MaxRequestBodyMB
+		log.Printf("WARNING! (config) MaxRequestBodyMB is invalid. The value must be greater than 0. Defaulting to %d.", conf.MaxRequestBodyMB)
+	}
+
+	//Proxies are used to determine the client's IP address for rate limiting and
+	//API key IP restrictions, so an invalid value is an error instead of ignored.
+	if conf.TrustedProxies == nil {
+		conf.TrustedProxies = defaults.TrustedProxies
+	}
+	if _, innerErr := parseTrustedProxies(conf.TrustedProxies); innerErr != nil {
+		return innerErr
+	}
+
+	if conf.AttachmentMaxSizeMB >= conf.MaxRequestBodyMB {
+		log.Printf("WARNING! (config) AttachmentMaxSizeMB is not less than MaxRequestBodyMB. Attachments larger than about %d MB will be rejected.", conf.MaxRequestBodyMB)
+	}
+
+	if conf.ReadHeaderTimeoutSeconds == 0 {
+		conf.ReadHeaderTimeoutSeconds = defaults.ReadHeaderTimeoutSeconds
+	} else if conf.ReadHeaderTimeoutSeconds < 0 {
+		conf.ReadHeaderTimeoutSeconds = defaults.ReadHeaderTimeoutSeconds
+		log.Printf("WARNING! (config) ReadHeaderTimeoutSeconds is invalid. The value must be greater than 0. Defaulting to %d.", conf.ReadHeaderTimeoutSeconds)
+	}
+
+	if conf.ReadTimeoutSeconds == 0 {
+		conf.ReadTimeoutSeconds = defaults.ReadTimeoutSeconds
+	} else if conf.ReadTimeoutSeconds < 0 {
+		conf.ReadTimeoutSeconds = defaults.ReadTimeoutSeconds
+		log.Printf("WARNING! (config) ReadTimeoutSeconds is invalid. The value must be greater than 0. Defaulting to %d.", conf.ReadTimeoutSeconds)
+	}
+
+	if conf.WriteTimeoutSeconds == 0 {
+		conf.WriteTimeoutSeconds = defaults.WriteTimeoutSeconds
+	} else if conf.WriteTimeoutSeconds < 0 {
+		conf.WriteTimeoutSeconds = defaults.WriteTimeoutSeconds
+		log.Printf("WARNING! (config) WriteTimeoutSeconds is invalid. The value must be greater than 0. Defaulting to %d.", conf.WriteTimeoutSeconds)
+	}
+
+	if conf.IdleTimeoutSeconds == 0 {
+		conf.IdleTimeoutSeconds = defaults.IdleTimeoutSeconds
+	} else if conf.IdleTimeoutSeconds < 0 {
+		conf.IdleTimeoutSeconds = defaults.IdleTimeoutSeconds
+		log.Printf("WARNING! (config) IdleTimeoutSeconds is invalid. The value must be greater than 0. Defaulting to %d.", conf.IdleTimeoutSeconds)
+	}
+
+	conf.TLSCertPath = strings.TrimSpace(conf.TLSCertPath)
+	conf.TLSKeyPath = strings.TrimSpace(conf.TLSKeyPath)
+	if conf.TLSCertPath != "" && conf.TLSKeyPath == "" {
+		return errors.New("config: TLSKeyPath must be provided when TLSCertPath is provided")
+	} else if conf.TLSCertPath == "" && conf.TLSKeyPath != "" {
+		return errors.New("config: TLSCertPath must be provided when TLSKeyPath is provided")
+	} else if conf.TLSEnabled() {
+		_, innerErr := tls.LoadX509KeyPair(conf.TLSCertPath, conf.TLSKeyPath)
+		if innerErr != nil {
+			return fmt.Errorf("config: TLSCertPath and TLSKeyPath could not be loaded as a certificate and key pair %w", innerErr)
+		}
+	}
+
+	if conf.TLSRedirectPort != 0 {
+		if !conf.TLSEnabled() {
+			conf.TLSRedirectPort = defaults.TLSRedirectPort
+			log.Println("WARNING! (config) TLSRedirectPort is ignored since TLS is not enabled.")
+		} else if conf.TLSRedirectPort < 1 || conf.TLSRedirectPort > portMax || conf.TLSRedirectPort == conf.Port {
+			conf.TLSRedirectPort = defaults.TLSRedirectPort
+			log.Printf("WARNING! (config) TLSRedirectPort is invalid. The value must be between 1 and %d and different from Port. Disabling redirect.", portMax)
+		}
+	}
+
+	//Cookie related. These are security settings so an invalid value is an error
+	//instead of silently using a default.
+	conf.CookieSecure = strings.ToLower(strings.TrimSpace(conf.CookieSecure))
+	switch conf.CookieSecure {
+	case "":
+		conf.CookieSecure = defaults.CookieSecure
+	case CookieSecureAuto, CookieSecureAlways, CookieSecureNever:
+	default:
+		return errors.New("config: CookieSecure is invalid, it must be one of auto, always, or never")
+	}
+
+	conf.CookieSameSite = strings.ToLower(strings.TrimSpace(conf.CookieSameSite))
+	switch conf.CookieSameSite {
+	case "":
+		conf.CookieSameSite = defaults.CookieSameSite
+	case CookieSameSiteLax, CookieSameSiteStrict, CookieSameSiteNone:
+	default:
+		return errors.New("config: CookieSameSite is invalid, it must be one of lax, strict, or none")
+	}
+
+	if conf.CookieSameSite == CookieSameSiteNone && !conf.CookieSecureEnabled() {
+		return errors.New("config: CookieSameSite none requires cookies to be Secure, set CookieSecure to always or enable TLS")
+	}
+
+	//Users related. The username is validated as an email when the initial user is
+	//created since it is only used when deploying the database.
+	conf.InitialUserUsername = strings.ToLower(strings.TrimSpace(conf.InitialUserUsername))
+	if conf.InitialUserUsername == "" {
+		conf.InitialUserUsername = defaults.InitialUserUsername
+	}
+
+	//Domains are lowercased since usernames are lowercased. A leading @ is removed so
+	//that either "example.com" or "@example.com" can be provided.
+	domains := []string{}
+	for _, d := range conf.AllowedUserEmailDomains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || slices.Contains(domains, d) {
+			continue
+		}
+
+		domains = append(domains, d)
+	}
+	conf.AllowedUserEmailDomains = domains
+
 	//User login/sessions related.
 	if conf.LoginLifetimeHours <= 0 {
 		conf.LoginLifetimeHours = defaults.LoginLifetimeHours
@@ -443,6 +1071,43 @@ func (conf *File) validate() (err error) {
 		_ = ""
 	}
 
+	if conf.UserLoginRetentionDays < 0 {
+		log.Println("WARNING! (config) UserLoginRetentionDays is invalid. The value must be 0 or greater. Disabling automatic deletion of user logins.")
+		conf.UserLoginRetentionDays = 0
+	}
+
+	//2FA token parameters. These are security settings so an invalid value is an
+	//error rather than silently using a default.
+	if conf.TwoFactorAuthDigits == 0 {
+		conf.TwoFactorAuthDigits = defaults.TwoFactorAuthDigits
+	} else if conf.TwoFactorAuthDigits < twoFactorAuthDigitsMin || conf.TwoFactorAuthDigits > twoFactorAuthDigitsMax {
+		return fmt.Errorf("config: TwoFactorAuthDigits is invalid, it must be between %d and %d", twoFactorAuthDigitsMin, twoFactorAuthDigitsMax)
+	}
+
+	if conf.TwoFactorAuthPeriodSeconds == 0 {
+		conf.TwoFactorAuthPeriodSeconds = defaults.TwoFactorAuthPeriodSeconds
+	} else if conf.TwoFactorAuthPeriodSeconds < twoFactorAuthPeriodSecondsMin || conf.TwoFactorAuthPeriodSeconds > twoFactorAuthPeriodSecondsMax {
+		return fmt.Errorf("config: TwoFactorAuthPeriodSeconds is invalid, it must be between %d and %d", twoFactorAuthPeriodSecondsMin, twoFactorAuthPeriodSecondsMax)
+	}
+
+	conf.TwoFactorAuthAlgorithm = strings.ToUpper(strings.TrimSpace(conf.TwoFactorAuthAlgorithm))
+	switch conf.TwoFactorAuthAlgorithm {
+	case "":
+		conf.TwoFactorAuthAlgorithm = defaults.TwoFactorAuthAlgorithm
+	case TwoFactorAuthAlgorithmSHA1, TwoFactorAuthAlgorithmSHA256, TwoFactorAuthAlgorithmSHA512:
+	default:
+		return errors.New("config: TwoFactorAuthAlgorithm is invalid, it must be one of SHA1, SHA256, or SHA512")
+	}
+
+	if conf.FailedLoginAlertThreshold < 0 {
+		log.Println("WARNING! (config) FailedLoginAlertThreshold is invalid. The value must be 0 or greater. Disabling failed login alerting.")
+		conf.FailedLoginAlertThreshold = 0
+	}
+	if conf.FailedLoginAlertWindowMinutes < 1 {
+		conf.FailedLoginAlertWindowMinutes = defaults.FailedLoginAlertWindowMinutes
+		log.Printf("WARNING! (config) FailedLoginAlertWindowMinutes is invalid. The value must be greater than 0. Defaulting to %d.", conf.FailedLoginAlertWindowMinutes)
+	}
+
 	//Misc.
 	conf.Timezone = strings.TrimSpace(conf.Timezone)
 	if conf.Timezone == "" {
@@ -465,6 +1130,17 @@ func (conf *File) validate() (err error) {
 		return
 	}
 
+	//An invalid minimum is an error, not a warning, since ignoring it would allow
+	//weaker key pairs than intended to be used.
+	conf.MinimumKeyPairAlgorithm = licensefile.KeyPairAlgoType(strings.TrimSpace(string(conf.MinimumKeyPairAlgorithm)))
+	if conf.MinimumKeyPairAlgorithm != "" {
+		err = conf.MinimumKeyPairAlgorithm.Valid()
+		if err != nil {
+			err = errors.New("config: MinimumKeyPairAlgorithm is invalid, " + err.Error())
+			return
+		}
+	}
+
 	return
 }
 
@@ -492,12 +1168,73 @@ func (conf File) print(path string) {
 	}
 }
 
+// TLSEnabled returns true if the app should serve HTTPS directly. TLS is enabled when
+// both a certificate and key path are provided.
+func (conf File) TLSEnabled() bool {
+	return conf.TLSCertPath != "" && conf.TLSKeyPath != ""
+}
+
+// CookieSecureEnabled returns true if the session and 2FA cookies should be marked
+// Secure. With CookieSecure set to auto, cookies are only Secure when serving HTTPS
+// directly since a terminating proxy may serve the app over http.
+func (conf File) CookieSecureEnabled() bool {
+	switch conf.CookieSecure {
+	case CookieSecureAlways:
+		return true
+	case CookieSecureNever:
+		return false
+	default:
+		return conf.TLSEnabled()
+	}
+}
+
+// CookieSameSiteMode returns the SameSite attribute to use for the session and 2FA
+// cookies.
+func (conf File) CookieSameSiteMode() http.SameSite {
+	switch conf.CookieSameSite {
+	case CookieSameSiteStrict:
+		return http.SameSiteStrictMode
+	case CookieSameSiteNone:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// SMTPEnabled returns true if email can be sent. Email is enabled when an SMTP host
+// is provided.
+func (conf File) SMTPEnabled() bool {
+	return conf.SMTPHost != ""
+}
+
+// URL returns the path p prefixed with BaseURLPath. p should start with a slash. Use
+// this when building links or redirects to pages within the app.
+func (conf File) URL(p string) string {
+	return conf.BaseURLPath + p
+}
+
+// AbsoluteURL returns the path p prefixed with ExternalURL and BaseURLPath. p should
+// start with a slash. Use this when building links that are opened outside of the
+// app. A blank string is returned if ExternalURL is not set.
+func (conf File) AbsoluteURL(p string) string {
+	if conf.ExternalURL == "" {
+		return ""
+	}
+
+	return conf.ExternalURL + conf.URL(p)
+}
+
 // Data returns the parsed config file data. This is used in other packages to use
 // config file data.
 func Data() File {
 	return parsedConfig
 }
 
+// Loaded returns true if the config was read and validated successfully.
+func Loaded() bool {
+	return loaded
+}
+
 // getRandomEncryptionKey returns a string used for encrypting the private key of a key
 // pair.
 func getRandomEncryptionKey() (encKey string) {
@@ -18,6 +18,7 @@ import (
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
 )
 
 // This file specifically deals with creating and managing the fields defined for an
@@ -64,9 +65,23 @@ func Add(w http.ResponseWriter, r *http.Request) {
 	cfd.CreatedByUserID = loggedInUserID
 
 	//Save.
-	err = cfd.Insert(r.Context())
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
 	if err != nil {
-		output.Error(err, "Could not save field.", w)
+		output.Error(err, "Could not save field (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = cfd.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save field (2).", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save field (3).", w)
 		return
 	}
 
@@ -134,6 +149,60 @@ func Update(w http.ResponseWriter, r *http.Request) {
 	output.UpdateOK(w)
 }
 
+// Reorder sets the order fields are shown in when creating a license. The IDs of the
+// app's active fields are provided as a JSON array in the order the fields should be
+// shown. Every active field must be provided so that the order is complete.
+func Reorder(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+	raw := r.FormValue("ids")
+
+	var ids []int64
+	err := json.Unmarshal([]byte(raw), &ids)
+	if err != nil {
+		output.Error(err, "Could not parse the order of custom fields.", w)
+		return
+	}
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Cannot determine which app you want to reorder custom fields for.", w)
+		return
+	}
+
+	fields, err := db.GetCustomFieldsDefined(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not get list of fields.", w)
+		return
+	}
+
+	if len(ids) != len(fields) {
+		output.ErrorInputInvalid("The order must include each of the app's custom fields. Please reload the page and try again.", w)
+		return
+	}
+
+	remaining := make(map[int64]bool, len(fields))
+	for _, f := range fields {
+		remaining[f.ID] = true
+	}
+	for _, id := range ids {
+		if !remaining[id] {
+			output.ErrorInputInvalid("The order includes a custom field that is not for this app or is listed more than once. Please reload the page and try again.", w)
+			return
+		}
+		delete(remaining, id)
+	}
+
+	//Save.
+	err = db.ReorderCustomFieldsDefined(r.Context(), appID, ids)
+	if err != nil {
+		output.Error(err, "Could not save the order of custom fields.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
 // DeleteDefined marks a custom field as inactive. The field will no longer be available
 // when creating a license. The field will still be displayed when viewing already
 // created licenses.
@@ -156,3 +225,27 @@ func DeleteDefined(w http.ResponseWriter, r *http.Request) {
 
 	output.UpdateOK(w)
 }
+
+// purgeResult is the data returned when deleted custom fields are purged.
+type purgeResult struct {
+	Deleted int64                             //the number of fields permanently removed.
+	Skipped []db.ReferencedCustomFieldDefined //deleted fields that were used in a license and were not removed.
+}
+
+// PurgeDefined permanently removes custom fields that were deleted and were never used
+// in a license. This is a housekeeping tool since deleted fields are otherwise kept
+// forever. Deleted fields that were used in a license are kept since they are needed
+// to rebuild the license, these fields are returned so the user knows why they were
+// not removed.
+func PurgeDefined(w http.ResponseWriter, r *http.Request) {
+	deleted, skipped, err := db.PurgeCustomFieldsDefined(r.Context())
+	if err != nil {
+		output.Error(err, "Could not purge deleted custom fields.", w)
+		return
+	}
+
+	output.UpdateOKWithData(purgeResult{
+		Deleted: deleted,
+		Skipped: skipped,
+	}, w)
+}
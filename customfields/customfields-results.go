@@ -20,6 +20,7 @@ func GetResults(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("The license ID provided is invalid.", w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
 
 	cols := sqldb.Columns{db.TableLicenses + ".Active"}
 	_, err := db.GetLicense(r.Context(), licenseID, cols)
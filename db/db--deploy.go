@@ -12,6 +12,9 @@ var DeployQueries = []string{
 	createTableUsers,
 	createTableAuthorizedBrowsers,
 	createTableUserLogins,
+	createTableUserPasskeys,
+	createTableUserFailedLogins,
+	createTableSecurityEvents,
 
 	createTableAPIKeys,
 	createTableActivityLog,
@@ -21,10 +24,17 @@ var DeployQueries = []string{
 	createTableKeyPairs,
 	createTableCustomFieldsDefined,
 	createTableCustomFieldResults,
+	createTableAppFeatures,
 	createTableLicenses,
 	createTableDownloadHistory,
 	createTableLicenseNotes,
+	createTableLicenseAttachments,
+	createTableLicenseTemplates,
 	createTableRenewalRelationships,
+	createTableTransferRelationships,
+	createTableLicenseSeats,
+	createTableLicenseActivations,
+	createTableAPIKeyApps,
 }
 
 var DeployFuncs = []sqldb.QueryFunc{
@@ -21,6 +21,7 @@ var createIndexes = []string{
 
 	createIndexActivityLogTimestampCreated,
 	createIndexActivityLogDatetimeCreated,
+	createIndexActivityLogLicenseID,
 	createIndexAPIKeysK,
 	createIndexAPIKeysActive,
 	createIndexAuthorizedBrowsersRemoteIP,
@@ -30,4 +31,20 @@ var createIndexes = []string{
 	createIndexUserLoginsDatetimeCreated,
 	createIndexUsersUsername,
 	createIndexUsersActive,
+	createIndexLicensesPublicID,
+	createIndexLicensesCompanyName,
+	createIndexLicensesFriendlyIDUnique,
+	createIndexAPIKeyAppsAPIKeyIDAppID,
+	createIndexUserPasskeysUserID,
+	createIndexUserPasskeysCredentialID,
+	createIndexLicenseSeatsLicenseIDClientID,
+	createIndexLicenseActivationsLicenseIDMachineID,
+	createIndexUserFailedLoginsUserIDDatetimeCreated,
+	createIndexSecurityEventsDatetimeCreated,
+	createIndexAppFeaturesAppID,
+	createIndexLicensesAppLicenseNumber,
+	createIndexLicenseAttachmentsLicenseID,
+	createIndexLicenseTemplatesAppID,
+	createIndexLicensesIdempotencyKeyUnique,
+	createIndexLicensesScheduledDisableDate,
 }
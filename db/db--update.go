@@ -1,3 +1,70 @@
 package db
 
-var UpdateQueries = []string{}
+// UpdateQueries is the list of queries to update an already deployed database schema.
+// These are run when the --update-db flag is provided. Errors for queries that have
+// already been run (i.e.: duplicate columns) are ignored, see main.go.
+var UpdateQueries = []string{
+	updateLicensesAddExpireDatetime,
+	updateLicensesAddPublicID,
+	updateLicensesSetPublicID,
+	updateUsersAddAuditor,
+	updateAPIKeysAddRestrictToApps,
+	createTableAPIKeyApps,
+	createTableUserPasskeys,
+	updateAppsAddFileHeaderText,
+	createTableTransferRelationships,
+	updateAppSettingsAddRequireDisableReason,
+	updateUsersAddTimezone,
+	updateLicensesAddImported,
+	createTableLicenseSeats,
+	updateCustomFieldsDefinedAddTextValidationRegex,
+	updateAppsAddFriendlyIDFormat,
+	updateAppsAddFriendlyIDPrefix,
+	updateLicensesAddFriendlyID,
+	updateCustomFieldsDefinedAddCannotExceedLicenseExpiration,
+	updateKeyPairsAddCompromised,
+	updateAppsAddRequiredSignatures,
+	updateLicensesAddSignatures,
+	updateAPIKeysAddAllowedIPs,
+	createTableLicenseActivations,
+	updateLicensesAddOrderReference,
+	updateLicensesAddInternalNotes,
+	updateLicensesAddValidFrom,
+	createTableUserFailedLogins,
+	createTableSecurityEvents,
+	createTableAppFeatures,
+	updateLicensesAddFeatures,
+	updateLicensesAddSignatureAlgorithm,
+	updateLicensesAddFormatVersion,
+	updateAppsAddAppLicenseNumbering,
+	updateAppsAddLastAppLicenseNumber,
+	updateLicensesAddAppLicenseNumber,
+	createTableLicenseAttachments,
+	updateAppSettingsAddAnnouncementActive,
+	updateAppSettingsAddAnnouncementText,
+	updateAppSettingsAddAnnouncementSeverity,
+	createTableLicenseTemplates,
+	updateLicensesAddLastHeartbeat,
+	updateLicensesAddHeartbeatMachineID,
+	updateAppSettingsAddDismissInitialUserWarning,
+	updateCustomFieldsDefinedAddSortOrder,
+	updateLicensesAddIdempotencyKey,
+	updateUserLoginsAddImpersonatingUserID,
+	updateActivityLogAddImpersonatorUserID,
+	updateAppSettingsAddRequire2FAForLicenseCreation,
+	updateAppsAddLicenseSchemaVersion,
+	updateLicensesAddSchemaVersion,
+	updateLicenseNotesAddCategory,
+	updateUsersAddTwoFactorAuthDigits,
+	updateUsersAddTwoFactorAuthPeriodSeconds,
+	updateUsersAddTwoFactorAuthAlgorithm,
+	updateAppsAddLicenseEmailSubject,
+	updateAppsAddLicenseEmailBody,
+	updateLicensesAddScheduledDisableDate,
+	updateLicensesAddScheduledDisableNote,
+	updateLicensesAddScheduledDisableByUserID,
+	updateLicensesClearDuplicateIdempotencyKeys,
+	updateLicensesDropIdempotencyKeyIndex,
+	updateActivityLogAddLicenseID,
+	updateUsersAddTwoFactorAuthLastTimeStep,
+}
@@ -36,10 +36,19 @@ type ActivityLog struct {
 	CreatedByUserID   null.Int
 	CreatedByAPIKeyID null.Int
 
+	//The administrator who made the request while viewing the app as the user in
+	//CreatedByUserID. Null if the request was not made while impersonating a user.
+	ImpersonatorUserID null.Int
+
+	//The license the request was made for. 0 if the request was not made for a
+	//single license. Set via SetActivityLogLicenseID() by the handler.
+	LicenseID int64
+
 	//JOINed fields
-	Username          string
-	APIKeyDescription string
-	APIKeyK           string //the actual api key
+	Username             string
+	ImpersonatorUsername string
+	APIKeyDescription    string
+	APIKeyK              string //the actual api key
 
 	//Calculated fields
 	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
@@ -61,19 +70,58 @@ const (
 			
 			CreatedByUserID INTEGER DEFAULT NULL,
 			CreatedByAPIKeyID INTEGER DEFAULT NULL,
+			ImpersonatorUserID INTEGER DEFAULT NULL,
+			LicenseID INTEGER NOT NULL DEFAULT 0,
 			
 			FOREIGN KEY(CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
-			FOREIGN KEY(CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID)
+			FOREIGN KEY(CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
+			FOREIGN KEY(ImpersonatorUserID) REFERENCES ` + TableUsers + `(ID)
 		)
 	`
 
 	//indexes
 	createIndexActivityLogTimestampCreated = `CREATE INDEX IF NOT EXISTS ` + TableActivityLog + `__TimestampCreated_idx ON ` + TableActivityLog + ` (TimestampCreated)`
 	createIndexActivityLogDatetimeCreated  = `CREATE INDEX IF NOT EXISTS ` + TableActivityLog + `__DatetimeCreated_idx ON ` + TableActivityLog + ` (DatetimeCreated)`
+	createIndexActivityLogLicenseID        = `CREATE INDEX IF NOT EXISTS ` + TableActivityLog + `__LicenseID_idx ON ` + TableActivityLog + ` (LicenseID) WHERE LicenseID > 0`
 
 	//updates
+	updateActivityLogAddImpersonatorUserID = `ALTER TABLE ` + TableActivityLog + ` ADD COLUMN ImpersonatorUserID INTEGER DEFAULT NULL REFERENCES ` + TableUsers + `(ID)`
+	updateActivityLogAddLicenseID          = `ALTER TABLE ` + TableActivityLog + ` ADD COLUMN LicenseID INTEGER NOT NULL DEFAULT 0`
 )
 
+type activityLogLicenseIDContextKeyType string
+
+// activityLogLicenseIDContextKey is the name of the key that stores the ID of the
+// license a request was made for in the request context. The value is a pointer so
+// that a handler can set the ID and the activity logging middleware can read it once
+// the handler returns.
+const activityLogLicenseIDContextKey activityLogLicenseIDContextKeyType = "activity-log-license-id"
+
+// WithActivityLogLicenseID returns a copy of ctx that a handler can record the ID of
+// the license a request was made for in via SetActivityLogLicenseID().
+func WithActivityLogLicenseID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, activityLogLicenseIDContextKey, new(int64))
+}
+
+// SetActivityLogLicenseID records the ID of the license a request was made for so
+// that the request's activity log entry can be looked up by license. This does
+// nothing if activity logging is not enabled for the request.
+func SetActivityLogLicenseID(ctx context.Context, licenseID int64) {
+	if id, ok := ctx.Value(activityLogLicenseIDContextKey).(*int64); ok {
+		*id = licenseID
+	}
+}
+
+// GetActivityLogLicenseID returns the ID of the license recorded via
+// SetActivityLogLicenseID(). 0 is returned if a license ID was not recorded.
+func GetActivityLogLicenseID(ctx context.Context) (licenseID int64) {
+	if id, ok := ctx.Value(activityLogLicenseIDContextKey).(*int64); ok {
+		return *id
+	}
+
+	return 0
+}
+
 // Insert saves a log entry to the database for an action performed by a user or via
 // an API key.
 func (a *ActivityLog) Insert(ctx context.Context) (err error) {
@@ -88,6 +136,7 @@ func (a *ActivityLog) Insert(ctx context.Context) (err error) {
 		"DatetimeCreated",
 		"TimestampCreated",
 		"Referrer",
+		"LicenseID",
 	}
 	b := sqldb.Bindvars{
 		a.Method,
@@ -99,12 +148,18 @@ func (a *ActivityLog) Insert(ctx context.Context) (err error) {
 		timestamps.YMDHMS(),
 		time.Now().UnixNano(),
 		a.Referrer,
+		a.LicenseID,
 	}
 
 	//Add fields based on if this action was caused by user or API.
 	if a.CreatedByUserID.Int64 > 0 {
 		cols = append(cols, "CreatedByUserID")
 		b = append(b, a.CreatedByUserID.Int64)
+
+		if a.ImpersonatorUserID.Int64 > 0 {
+			cols = append(cols, "ImpersonatorUserID")
+			b = append(b, a.ImpersonatorUserID.Int64)
+		}
 	} else if a.CreatedByAPIKeyID.Int64 != 0 {
 		cols = append(cols, "CreatedByAPIKeyID")
 		b = append(b, a.CreatedByAPIKeyID.Int64)
@@ -152,7 +207,7 @@ func GetActivityLog(ctx context.Context, userID, apiKeyID int64, endpoint, searc
 	}
 
 	//Build columns.
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
 	cols := sqldb.Columns{
 		TableActivityLog + ".ID",
 		TableActivityLog + ".Method",
@@ -162,6 +217,7 @@ func GetActivityLog(ctx context.Context, userID, apiKeyID int64, endpoint, searc
 		TableActivityLog + ".Referrer",
 		TableActivityLog + ".DatetimeCreated",
 		"IFNULL(" + TableUsers + ".Username, '') AS Username",
+		"IFNULL(impersonator.Username, '') AS ImpersonatorUsername",
 		"IFNULL(" + TableAPIKeys + ".K, '') AS APIKeyK",
 		"IFNULL(" + TableAPIKeys + ".Description, '') AS APIKeyDescription",
 
@@ -177,6 +233,7 @@ func GetActivityLog(ctx context.Context, userID, apiKeyID int64, endpoint, searc
 		SELECT ` + colString + ` 
 		FROM ` + TableActivityLog + `
 		LEFT JOIN ` + TableUsers + ` ON ` + TableUsers + `.ID=` + TableActivityLog + `.CreatedByUserID
+		LEFT JOIN ` + TableUsers + ` AS impersonator ON impersonator.ID=` + TableActivityLog + `.ImpersonatorUserID
 		LEFT JOIN ` + TableAPIKeys + ` ON ` + TableAPIKeys + `.ID=` + TableActivityLog + `.CreatedByAPIKeyID
 	`
 
@@ -228,6 +285,48 @@ func GetActivityLog(ctx context.Context, userID, apiKeyID int64, endpoint, searc
 	return
 }
 
+// GetActivityLogForLicense looks up the activities that were made for a license.
+// Results are returned oldest first.
+func GetActivityLogForLicense(ctx context.Context, licenseID int64) (aa []ActivityLog, err error) {
+	//Build columns.
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	cols := sqldb.Columns{
+		TableActivityLog + ".ID",
+		TableActivityLog + ".Method",
+		TableActivityLog + ".TimeDuration",
+		TableActivityLog + ".URL",
+		TableActivityLog + ".RemoteIP",
+		TableActivityLog + ".PostFormValues",
+		TableActivityLog + ".Referrer",
+		TableActivityLog + ".DatetimeCreated",
+		"IFNULL(" + TableUsers + ".Username, '') AS Username",
+		"IFNULL(impersonator.Username, '') AS ImpersonatorUsername",
+		"IFNULL(" + TableAPIKeys + ".Description, '') AS APIKeyDescription",
+
+		`datetime(` + TableActivityLog + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
+	}
+	colString, err := cols.ForSelect()
+	if err != nil {
+		return
+	}
+
+	//Build query.
+	q := `
+		SELECT ` + colString + ` 
+		FROM ` + TableActivityLog + `
+		LEFT JOIN ` + TableUsers + ` ON ` + TableUsers + `.ID=` + TableActivityLog + `.CreatedByUserID
+		LEFT JOIN ` + TableUsers + ` AS impersonator ON impersonator.ID=` + TableActivityLog + `.ImpersonatorUserID
+		LEFT JOIN ` + TableAPIKeys + ` ON ` + TableAPIKeys + `.ID=` + TableActivityLog + `.CreatedByAPIKeyID
+		WHERE ` + TableActivityLog + `.LicenseID = ?
+		ORDER BY ` + TableActivityLog + `.TimestampCreated ASC
+	`
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &aa, q, licenseID)
+	return
+}
+
 // ClearActivityLog deletes rows from the activity log table prior to a given date
 func ClearActivityLog(ctx context.Context, date string) (rowsDeleted int64, err error) {
 	q := `
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestGetActivityLogForLicense(t *testing.T) {
+	//A config is needed to convert the datetime of each activity to the configured
+	//timezone.
+	err := config.Read(filepath.Join(t.TempDir(), "licensekeys.conf"), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	c := newTestDB(t)
+	sqldb.Use(c)
+	ctx := context.Background()
+
+	//Save activities for licenses whose IDs contain each other, and an activity not
+	//made for any license that references the license's ID in its request data.
+	activities := []ActivityLog{
+		{URL: "/api/licenses/", PostFormValues: `{"id":1}`, LicenseID: 1},
+		{URL: "/api/licenses/", PostFormValues: `{"id":11}`, LicenseID: 11},
+		{URL: "/api/licenses/", PostFormValues: `{"id":21}`, LicenseID: 21},
+		{URL: "/api/apps/", PostFormValues: `{"id":1}`},
+	}
+	for _, a := range activities {
+		a.CreatedByUserID = null.IntFrom(1)
+		err = a.Insert(ctx)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	aa, err := GetActivityLogForLicense(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(aa) != 1 {
+		t.Fatal("expected 1 activity for license, got", len(aa))
+		return
+	}
+	if aa[0].URL != "/api/licenses/" || aa[0].PostFormValues != `{"id":1}` {
+		t.Fatal("unexpected activity returned for license", aa[0])
+		return
+	}
+}
+
+func TestActivityLogLicenseIDContext(t *testing.T) {
+	//Setting the license ID without the context being prepared for activity logging
+	//does nothing.
+	ctx := context.Background()
+	SetActivityLogLicenseID(ctx, 1)
+	if id := GetActivityLogLicenseID(ctx); id != 0 {
+		t.Fatal("expected no license ID, got", id)
+		return
+	}
+
+	//The license ID set by a handler is readable using the same context.
+	ctx = WithActivityLogLicenseID(ctx)
+	SetActivityLogLicenseID(ctx, 5)
+	if id := GetActivityLogLicenseID(ctx); id != 5 {
+		t.Fatal("expected license ID 5, got", id)
+		return
+	}
+}
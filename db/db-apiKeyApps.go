@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/sqldb/v3"
+)
+
+//This table stores the apps an API key is allowed to create licenses for. This is
+//only used when an API key's RestrictToApps field is true, otherwise an API key can
+//create licenses for any app.
+
+// TableAPIKeyApps is the name of the table.
+const TableAPIKeyApps = "api_key_apps"
+
+// APIKeyApp is used to interact with the table.
+type APIKeyApp struct {
+	ID              int64
+	DatetimeCreated string
+	CreatedByUserID int64
+
+	APIKeyID int64
+	AppID    int64
+
+	//JOINed fields
+	AppName string
+}
+
+const (
+	createTableAPIKeyApps = `
+		CREATE TABLE IF NOT EXISTS ` + TableAPIKeyApps + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			CreatedByUserID INTEGER NOT NULL,
+
+			APIKeyID INTEGER NOT NULL,
+			AppID INTEGER NOT NULL,
+
+			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
+			FOREIGN KEY (APIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
+			FOREIGN KEY (AppID) REFERENCES ` + TableApps + `(ID)
+		)
+	`
+
+	createIndexAPIKeyAppsAPIKeyIDAppID = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableAPIKeyApps + `__APIKeyID_AppID_idx ON ` + TableAPIKeyApps + ` (APIKeyID, AppID)`
+)
+
+// GetAPIKeyApps looks up the apps an API key is allowed to create licenses for.
+func GetAPIKeyApps(ctx context.Context, apiKeyID int64) (aa []APIKeyApp, err error) {
+	q := `
+		SELECT
+			` + TableAPIKeyApps + `.*,
+			` + TableApps + `.Name AS AppName
+		FROM ` + TableAPIKeyApps + `
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID=` + TableAPIKeyApps + `.AppID
+		WHERE
+			(` + TableAPIKeyApps + `.APIKeyID = ?)
+		ORDER BY ` + TableApps + `.Name ASC
+	`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &aa, q, apiKeyID)
+	return
+}
+
+// GetAPIKeyApp looks up if an API key is allowed to create licenses for an app. This
+// only checks the api_key_apps table, the API key's RestrictToApps field must be
+// checked separately.
+func GetAPIKeyApp(ctx context.Context, apiKeyID, appID int64) (a APIKeyApp, err error) {
+	q := `
+		SELECT ` + TableAPIKeyApps + `.*
+		FROM ` + TableAPIKeyApps + `
+		WHERE
+			(` + TableAPIKeyApps + `.APIKeyID = ?)
+			AND
+			(` + TableAPIKeyApps + `.AppID = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &a, q, apiKeyID, appID)
+	return
+}
+
+// Insert saves a new app an API key is allowed to create licenses for.
+func (a *APIKeyApp) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"CreatedByUserID",
+		"APIKeyID",
+		"AppID",
+	}
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableAPIKeyApps + `(` + colString + `) VALUES (` + valString + `)`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(
+		ctx,
+
+		a.CreatedByUserID,
+		a.APIKeyID,
+		a.AppID,
+	)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	a.ID = id
+	return
+}
+
+// DeleteAPIKeyApp removes an app from the list of apps an API key is allowed to
+// create licenses for.
+func DeleteAPIKeyApp(ctx context.Context, apiKeyID, appID int64) (err error) {
+	q := `
+		DELETE FROM ` + TableAPIKeyApps + `
+		WHERE
+			(APIKeyID = ?)
+			AND
+			(AppID = ?)
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, apiKeyID, appID)
+	return
+}
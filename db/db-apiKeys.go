@@ -25,11 +25,19 @@ type APIKey struct {
 	Description string //so user can identify what the api key is used for
 	K           string //the actual api key
 
+	//Permissions.
+	RestrictToApps bool //if true, key can only create licenses for apps listed in api_key_apps, otherwise key can create licenses for any app.
+
+	//AllowedIPs is a comma separated list of IP addresses and CIDR ranges the key can
+	//be used from. If blank, the key can be used from any IP address.
+	AllowedIPs string
+
 	//JOINed fields
 	CreatedByUsername string
 
 	//Calculated fields
-	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
+	DatetimeCreatedInTZ string      //DatetimeCreated converted to timezone per config file.
+	Apps                []APIKeyApp //apps key can create licenses for, only used when RestrictToApps is true.
 }
 
 const (
@@ -44,18 +52,24 @@ const (
 			Description TEXT NOT NULL,
 			K TEXT NOT NULL,
 
+			RestrictToApps INTEGER NOT NULL DEFAULT 0,
+			AllowedIPs TEXT NOT NULL DEFAULT '',
+
 			FOREIGN KEY(CreatedByUserID) REFERENCES ` + TableUsers + `(ID)
 		)
 	`
 
 	createIndexAPIKeysK      = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableAPIKeys + `__K_idx ON ` + TableAPIKeys + ` (K)`
 	createIndexAPIKeysActive = `CREATE INDEX IF NOT EXISTS ` + TableAPIKeys + `__Active_idx ON ` + TableAPIKeys + ` (Active)`
+
+	updateAPIKeysAddRestrictToApps = `ALTER TABLE ` + TableAPIKeys + ` ADD COLUMN RestrictToApps INTEGER NOT NULL DEFAULT 0`
+	updateAPIKeysAddAllowedIPs     = `ALTER TABLE ` + TableAPIKeys + ` ADD COLUMN AllowedIPs TEXT NOT NULL DEFAULT ''`
 )
 
 // GetAPIKeys looks up a list of API keys.
 func GetAPIKeys(ctx context.Context, activeOnly bool) (aa []APIKey, err error) {
 	//Gather columns.
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
 	cols := sqldb.Columns{
 		TableAPIKeys + ".*",
 		TableUsers + ".Username AS CreatedByUsername",
@@ -106,6 +120,25 @@ func GetAPIKeyByKey(ctx context.Context, key string, columns sqldb.Columns) (a A
 	return
 }
 
+// GetAPIKeyByID looks up an API key's data by its ID.
+func GetAPIKeyByID(ctx context.Context, id int64, columns sqldb.Columns) (a APIKey, err error) {
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableAPIKeys + `
+		WHERE 
+			(ID = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &a, q, id)
+	return
+}
+
 // GetAPIKeyByDescription looks up an API key by its Description. This is used when
 // adding a new API key to verify a key with the same description doesn't already
 // exist and is active.
@@ -130,6 +163,7 @@ func (a *APIKey) Insert(ctx context.Context) (err error) {
 		"CreatedByUserID",
 		"Description",
 		"K",
+		"RestrictToApps",
 	}
 	colString, valString, err := cols.ForInsert()
 	if err != nil {
@@ -151,6 +185,7 @@ func (a *APIKey) Insert(ctx context.Context) (err error) {
 		a.CreatedByUserID,
 		a.Description,
 		a.K,
+		a.RestrictToApps,
 	)
 	if err != nil {
 		return
@@ -199,6 +234,7 @@ func (a *APIKey) Update(ctx context.Context) (err error) {
 	cols := sqldb.Columns{
 		"DatetimeModified",
 		"Description",
+		"RestrictToApps",
 	}
 
 	colString, err := cols.ForUpdate()
@@ -219,6 +255,37 @@ func (a *APIKey) Update(ctx context.Context) (err error) {
 
 		timestamps.YMDHMS(),
 		a.Description,
+		a.RestrictToApps,
+
+		a.ID,
+	)
+	return
+}
+
+// SaveAllowedIPs saves the list of IP addresses and CIDR ranges an API key can be used
+// from. The list should have already been validated.
+func (a *APIKey) SaveAllowedIPs(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableAPIKeys + ` 
+		SET 
+			DatetimeModified = ?,
+			AllowedIPs = ?
+		WHERE 
+			(ID = ?)
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(
+		ctx,
+
+		timestamps.YMDHMS(),
+		a.AllowedIPs,
 
 		a.ID,
 	)
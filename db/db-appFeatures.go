@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+//This table stores the catalog of features for each app. When a license is created,
+//the features enabled for the license are chosen from this catalog and the key of
+//each feature is stored in the license file. Third-party apps check if a feature is
+//enabled using licensefile.File.HasFeature().
+//
+//Features are a simpler alternative to boolean custom fields for enabling
+//functionality since features are a typed list in the license file rather than a
+//set of arbitrarily named Metadata values.
+
+// TableAppFeatures is the name of the table.
+const TableAppFeatures = "app_features"
+
+// AppFeature is used to interact with the table.
+type AppFeature struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+	CreatedByUserID  int64
+	Active           bool
+
+	AppID      int64  //what app this feature is for.
+	Name       string //human readable name shown in the GUI.
+	FeatureKey string //the value stored in the license file, see appFeatureKeyRegex.
+}
+
+const (
+	createTableAppFeatures = `
+		CREATE TABLE IF NOT EXISTS ` + TableAppFeatures + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+			CreatedByUserID INTEGER NOT NULL,
+			Active INTEGER NOT NULL DEFAULT 1,
+
+			AppID INTEGER NOT NULL,
+			Name TEXT NOT NULL,
+			FeatureKey TEXT NOT NULL,
+
+			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
+			FOREIGN KEY (AppID) REFERENCES ` + TableApps + `(ID)
+		)
+	`
+
+	createIndexAppFeaturesAppID = `CREATE INDEX IF NOT EXISTS ` + TableAppFeatures + `__AppID_idx ON ` + TableAppFeatures + ` (AppID)`
+)
+
+// appFeatureKeyRegex is the format of a feature's key. Keys are limited to lowercase
+// characters so that third-party apps don't have to deal with case when checking if
+// a feature is enabled and so that keys can be easily stored as a list.
+var appFeatureKeyRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// Validate handles sanitizing and validation of a feature.
+func (f *AppFeature) Validate(ctx context.Context) (errMsg string, err error) {
+	//Sanitize.
+	f.Name = strings.TrimSpace(f.Name)
+	f.FeatureKey = strings.TrimSpace(f.FeatureKey)
+
+	//Validate.
+	if f.AppID < 1 {
+		errMsg = "Could not determine which app you are adding a feature for. Please refresh and try again."
+		return
+	}
+	if f.Name == "" {
+		errMsg = "You must provide a name for this feature."
+		return
+	}
+	if !appFeatureKeyRegex.MatchString(f.FeatureKey) {
+		errMsg = "The key must be 1 to 64 lowercase letters, numbers, underscores, periods, or hyphens, starting with a letter or number."
+		return
+	}
+
+	//Make sure the key isn't already used by another active feature for this app.
+	existing, err := GetAppFeatureByKey(ctx, f.AppID, f.FeatureKey)
+	if err == sql.ErrNoRows {
+		err = nil
+	} else if err != nil {
+		return
+	} else if existing.ID != f.ID {
+		errMsg = "Another feature already uses this key."
+		return
+	}
+
+	return
+}
+
+// GetAppFeatureByKey looks up an active feature by its key for a given app.
+func GetAppFeatureByKey(ctx context.Context, appID int64, key string) (f AppFeature, err error) {
+	q := `
+		SELECT ` + TableAppFeatures + `.*
+		FROM ` + TableAppFeatures + `
+		WHERE
+			(AppID = ?)
+			AND
+			(FeatureKey = ?)
+			AND
+			(Active = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &f, q, appID, key, true)
+	return
+}
+
+// Insert saves a feature. You should have already called Validate().
+//
+// A transaction is optional, pass nil if you don't have one. A transaction is used
+// when features are saved along with a new app, for example when importing an app.
+func (f *AppFeature) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
+	//use tx if given, otherwise generate a tx
+	txProvided := true
+	if tx == nil {
+		c := sqldb.Connection()
+		tx, err = c.BeginTxx(ctx, nil)
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		txProvided = false
+	}
+
+	cols := sqldb.Columns{
+		"CreatedByUserID",
+		"Active",
+		"AppID",
+		"Name",
+		"FeatureKey",
+	}
+	b := sqldb.Bindvars{
+		f.CreatedByUserID,
+		true,
+		f.AppID,
+		f.Name,
+		f.FeatureKey,
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableAppFeatures + `(` + colString + `) VALUES (` + valString + `)`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	f.ID = id
+	f.Active = true
+
+	//finish tx if we generated it in this func
+	if !txProvided {
+		err = tx.Commit()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// GetAppFeatures returns the list of features for an app optionally filtered by active
+// features only.
+func GetAppFeatures(ctx context.Context, appID int64, activeOnly bool) (ff []AppFeature, err error) {
+	q := `
+		SELECT ` + TableAppFeatures + `.*
+		FROM ` + TableAppFeatures + `
+		WHERE (` + TableAppFeatures + `.AppID = ?)
+	`
+	b := sqldb.Bindvars{appID}
+
+	if activeOnly {
+		q += ` AND (` + TableAppFeatures + `.Active = ?)`
+		b = append(b, activeOnly)
+	}
+
+	q += ` ORDER BY ` + TableAppFeatures + `.Active DESC, ` + TableAppFeatures + `.Name COLLATE NOCASE ASC`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ff, q, b...)
+	return
+}
+
+// Update saves changes to a feature. You should have already called Validate().
+//
+// Only the name can be changed. The key cannot be changed since it is stored in
+// licenses that were already created.
+func (f *AppFeature) Update(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableAppFeatures + `
+		SET
+			DatetimeModified = ?,
+			Name = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, timestamps.YMDHMS(), f.Name, f.ID)
+	return
+}
+
+// Delete marks a feature as inactive.
+func (f *AppFeature) Delete(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableAppFeatures + `
+		SET
+			Active = ?,
+			DatetimeModified = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, false, timestamps.YMDHMS(), f.ID)
+	return
+}
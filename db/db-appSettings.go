@@ -5,7 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/sqldb/v3"
 	"github.com/jmoiron/sqlx"
@@ -27,6 +34,18 @@ type AppSettings struct {
 	Allow2FactorAuth      bool //if 2 factor authentication can be used
 	Force2FactorAuth      bool //if all users are required to have 2 factor auth enabled prior to logging in (check if at least one user has 2fa enabled first to prevent lock out!)
 	ForceSingleSession    bool //user can only be logged into the app in one browser at a time. used as a security tool.
+	RequireDisableReason  bool //a note must be provided explaining why a license is being disabled.
+
+	DismissInitialUserWarning bool //hide the warning shown when the initial user is still active, unless the user is still the unchanged default user.
+
+	Require2FAForLicenseCreation bool //users enrolled in 2fa tokens, or with a passkey, must provide a token or use their passkey each time they create a license, even though they are logged in.
+
+	//An announcement shown at the top of each page to logged-in users, for example
+	//to notify users of upcoming maintenance. These are set separately from the
+	//other app settings, see UpdateAnnouncement().
+	AnnouncementActive   bool   //whether or not the announcement is shown.
+	AnnouncementText     string //plain text, HTML is escaped when shown.
+	AnnouncementSeverity string //one of AnnouncementSeverities, used to style the announcement.
 }
 
 const (
@@ -39,15 +58,52 @@ const (
 			AllowAPIAccess INTEGER NOT NULL DEFAULT 1,
 			Allow2FactorAuth INTEGER NOT NULL DEFAULT 0,
 			Force2FactorAuth INTEGER NOT NULL DEFAULT 0,
-			ForceSingleSession INTEGER NOT NULL DEFAULT 1
+			ForceSingleSession INTEGER NOT NULL DEFAULT 1,
+			RequireDisableReason INTEGER NOT NULL DEFAULT 0,
+			DismissInitialUserWarning INTEGER NOT NULL DEFAULT 0,
+			Require2FAForLicenseCreation INTEGER NOT NULL DEFAULT 0,
+			AnnouncementActive INTEGER NOT NULL DEFAULT 0,
+			AnnouncementText TEXT NOT NULL DEFAULT '',
+			AnnouncementSeverity TEXT NOT NULL DEFAULT 'info'
 		)
 	`
+
+	//Existing databases default to requiring a reason since, prior to this setting
+	//being added, a note was always required when disabling a license.
+	updateAppSettingsAddRequireDisableReason = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN RequireDisableReason INTEGER NOT NULL DEFAULT 1`
+
+	updateAppSettingsAddAnnouncementActive   = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN AnnouncementActive INTEGER NOT NULL DEFAULT 0`
+	updateAppSettingsAddAnnouncementText     = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN AnnouncementText TEXT NOT NULL DEFAULT ''`
+	updateAppSettingsAddAnnouncementSeverity = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN AnnouncementSeverity TEXT NOT NULL DEFAULT 'info'`
+
+	updateAppSettingsAddDismissInitialUserWarning = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN DismissInitialUserWarning INTEGER NOT NULL DEFAULT 0`
+
+	updateAppSettingsAddRequire2FAForLicenseCreation = `ALTER TABLE ` + TableAppSettings + ` ADD COLUMN Require2FAForLicenseCreation INTEGER NOT NULL DEFAULT 0`
 )
 
+// Severities of an announcement. These match Bootstrap alert classes.
+const (
+	AnnouncementSeverityInfo    = "info"
+	AnnouncementSeverityWarning = "warning"
+	AnnouncementSeverityDanger  = "danger"
+)
+
+// AnnouncementSeverities is the list of valid severities.
+var AnnouncementSeverities = []string{
+	AnnouncementSeverityInfo,
+	AnnouncementSeverityWarning,
+	AnnouncementSeverityDanger,
+}
+
+// announcementMaxLength is the maximum number of characters in an announcement. An
+// announcement is shown on every page so it should be short.
+const announcementMaxLength = 500
+
 func insertInitialAppSettings(c *sqlx.DB) (err error) {
-	//check if initial data already exists
+	//check if initial data already exists, skipping the cache since the cached
+	//settings could be from a different database.
 	ctx := context.Background()
-	_, err = GetAppSettings(ctx)
+	_, err = getAppSettingsFromDB(ctx)
 	if err == nil {
 		log.Println("insertInitialAppSettings...already exists")
 		return
@@ -61,6 +117,12 @@ func insertInitialAppSettings(c *sqlx.DB) (err error) {
 		"Allow2FactorAuth",
 		"Force2FactorAuth",
 		"ForceSingleSession",
+		"RequireDisableReason",
+		"DismissInitialUserWarning",
+		"Require2FAForLicenseCreation",
+		"AnnouncementActive",
+		"AnnouncementText",
+		"AnnouncementSeverity",
 	}
 	colString, valString, err := cols.ForInsert()
 	if err != nil {
@@ -83,6 +145,12 @@ func insertInitialAppSettings(c *sqlx.DB) (err error) {
 		true,  //Allow2FactorAuth
 		false, //Force2FactorAuth
 		false, //ForceSingleSession
+		false, //RequireDisableReason
+		false, //DismissInitialUserWarning
+		false, //Require2FAForLicenseCreation
+		false, //AnnouncementActive
+		"",    //AnnouncementText
+		AnnouncementSeverityInfo,
 	)
 	return
 }
@@ -98,8 +166,16 @@ var (
 	ErrNoUser2FAEnabled = errors.New("no user has 2 factor auth enabled")
 )
 
-// GetAppSettings looks up the current app settings used for this app.
+// GetAppSettings looks up the current app settings used for this app. The app
+// settings are cached, see AppSettingsCacheSeconds in the config file.
 func GetAppSettings(ctx context.Context) (a AppSettings, err error) {
+	ttl := time.Duration(config.Data().AppSettingsCacheSeconds) * time.Second
+	return cachedAppSettings.get(ctx, ttl, getAppSettingsFromDB)
+}
+
+// getAppSettingsFromDB looks up the current app settings from the database, skipping
+// the cache.
+func getAppSettingsFromDB(ctx context.Context) (a AppSettings, err error) {
 	//get data from database
 	q := `
 		SELECT *
@@ -114,6 +190,9 @@ func GetAppSettings(ctx context.Context) (a AppSettings, err error) {
 
 // Update updates the saved app settings to the given values.
 func (a *AppSettings) Update(ctx context.Context) (err error) {
+	//Make sure the changes are seen immediately, even if saving fails partway.
+	defer cachedAppSettings.invalidate()
+
 	//If forcing 2FA is turned on, make sure 2FA is enabled too. Also have to make
 	//sure at least one user has 2FA enabled first otherwise every user will be
 	//locked out of the app!
@@ -150,6 +229,9 @@ func (a *AppSettings) Update(ctx context.Context) (err error) {
 		"Allow2FactorAuth",
 		"Force2FactorAuth",
 		"ForceSingleSession",
+		"RequireDisableReason",
+		"DismissInitialUserWarning",
+		"Require2FAForLicenseCreation",
 	}
 	colString, err := cols.ForUpdate()
 	if err != nil {
@@ -175,7 +257,86 @@ func (a *AppSettings) Update(ctx context.Context) (err error) {
 		a.Allow2FactorAuth,
 		a.Force2FactorAuth,
 		a.ForceSingleSession,
+		a.RequireDisableReason,
+		a.DismissInitialUserWarning,
+		a.Require2FAForLicenseCreation,
 	)
 
 	return
 }
+
+// ValidateAnnouncement handles sanitizing and validation of the announcement.
+//
+// Control characters, other than newlines, are removed. The text is otherwise stored
+// as provided and is always shown as plain text.
+func (a *AppSettings) ValidateAnnouncement() (errMsg string) {
+	//Sanitize.
+	a.AnnouncementText = strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(a.AnnouncementText, "\r\n", "\n"))
+	a.AnnouncementText = strings.TrimSpace(a.AnnouncementText)
+	a.AnnouncementSeverity = strings.ToLower(strings.TrimSpace(a.AnnouncementSeverity))
+
+	//Validate.
+	if a.AnnouncementActive && a.AnnouncementText == "" {
+		errMsg = "You must provide the text of the announcement."
+		return
+	}
+	if utf8.RuneCountInString(a.AnnouncementText) > announcementMaxLength {
+		errMsg = "The announcement must be at most " + strconv.Itoa(announcementMaxLength) + " characters."
+		return
+	}
+	if !slices.Contains(AnnouncementSeverities, a.AnnouncementSeverity) {
+		errMsg = "Please choose a valid severity for the announcement."
+		return
+	}
+
+	return
+}
+
+// UpdateAnnouncement saves changes to the announcement. You should have already
+// called ValidateAnnouncement().
+//
+// The announcement is saved separately from the other app settings so that it can be
+// changed while the app is in maintenance mode.
+func (a *AppSettings) UpdateAnnouncement(ctx context.Context) (err error) {
+	//Make sure the changes are seen immediately, even if saving fails partway.
+	defer cachedAppSettings.invalidate()
+
+	cols := sqldb.Columns{
+		"DatetimeModified",
+		"AnnouncementActive",
+		"AnnouncementText",
+		"AnnouncementSeverity",
+	}
+	colString, err := cols.ForUpdate()
+	if err != nil {
+		return
+	}
+
+	q := `UPDATE ` + TableAppSettings + ` SET ` + colString
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(
+		ctx,
+
+		timestamps.YMDHMS(),
+
+		a.AnnouncementActive,
+		a.AnnouncementText,
+		a.AnnouncementSeverity,
+	)
+	return
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//App settings are looked up on nearly every request, for example when building each
+//page and when logging in, but rarely change. The app settings are cached in memory
+//for a short time, set by AppSettingsCacheSeconds in the config file, to reduce the
+//number of queries. The cache is invalidated whenever app settings are saved so that
+//changes are seen immediately. Changes made directly to the database, outside of
+//this app, are seen once the cache expires.
+
+// appSettingsCache stores the most recently looked up app settings.
+type appSettingsCache struct {
+	mu       sync.RWMutex
+	settings AppSettings
+	expires  time.Time
+
+	//generation is incremented each time the cache is invalidated. This prevents a
+	//lookup that started before app settings were saved from caching the old app
+	//settings after the cache was invalidated.
+	generation uint64
+}
+
+// cachedAppSettings is the cache used by GetAppSettings().
+var cachedAppSettings = &appSettingsCache{}
+
+// get returns the cached app settings. If the cache is empty or expired, load is
+// called and the result is cached for ttl. A ttl of 0 disables caching.
+func (c *appSettingsCache) get(ctx context.Context, ttl time.Duration, load func(context.Context) (AppSettings, error)) (a AppSettings, err error) {
+	if ttl <= 0 {
+		return load(ctx)
+	}
+
+	c.mu.RLock()
+	if time.Now().Before(c.expires) {
+		a = c.settings
+		c.mu.RUnlock()
+		return
+	}
+	generation := c.generation
+	c.mu.RUnlock()
+
+	a, err = load(ctx)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.settings = a
+		c.expires = time.Now().Add(ttl)
+	}
+	c.mu.Unlock()
+
+	return
+}
+
+// invalidate clears the cache so that the next lookup gets the app settings from the
+// database.
+func (c *appSettingsCache) invalidate() {
+	c.mu.Lock()
+	c.generation++
+	c.expires = time.Time{}
+	c.mu.Unlock()
+}
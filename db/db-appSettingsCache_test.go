@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingLoader returns a func that loads app settings, without a database, and
+// counts how many times it was called. delay simulates the time taken by a query.
+func countingLoader(calls *int64, delay time.Duration) func(context.Context) (AppSettings, error) {
+	return func(ctx context.Context) (AppSettings, error) {
+		n := atomic.AddInt64(calls, 1)
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		return AppSettings{ID: n}, nil
+	}
+}
+
+func TestAppSettingsCache(t *testing.T) {
+	ctx := context.Background()
+	c := &appSettingsCache{}
+
+	var calls int64
+	load := countingLoader(&calls, 0)
+
+	//First lookup loads, second lookup is cached.
+	a, err := c.get(ctx, time.Minute, load)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	b, err := c.get(ctx, time.Minute, load)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if calls != 1 || a.ID != b.ID {
+		t.Fatal("expected second lookup to be cached, loaded", calls, "times")
+		return
+	}
+
+	//Invalidating loads again so that saved changes are seen immediately.
+	c.invalidate()
+	a, err = c.get(ctx, time.Minute, load)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if calls != 2 || a.ID != 2 {
+		t.Fatal("expected lookup after invalidating to load, loaded", calls, "times")
+		return
+	}
+
+	//Expired cache loads again.
+	c.invalidate()
+	_, err = c.get(ctx, time.Nanosecond, load)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	time.Sleep(time.Millisecond)
+	_, err = c.get(ctx, time.Nanosecond, load)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if calls != 4 {
+		t.Fatal("expected expired cache to load, loaded", calls, "times")
+		return
+	}
+
+	//A ttl of 0 disables caching.
+	calls = 0
+	for i := 0; i < 3; i++ {
+		_, err = c.get(ctx, 0, load)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	if calls != 3 {
+		t.Fatal("expected each lookup to load when caching is disabled, loaded", calls, "times")
+		return
+	}
+}
+
+func TestAppSettingsCacheError(t *testing.T) {
+	ctx := context.Background()
+	c := &appSettingsCache{}
+
+	errLoad := errors.New("load failed")
+	_, err := c.get(ctx, time.Minute, func(context.Context) (AppSettings, error) {
+		return AppSettings{}, errLoad
+	})
+	if !errors.Is(err, errLoad) {
+		t.Fatal("expected load error to be returned, got", err)
+		return
+	}
+
+	//Errors are not cached.
+	var calls int64
+	_, err = c.get(ctx, time.Minute, countingLoader(&calls, 0))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if calls != 1 {
+		t.Fatal("expected lookup after an error to load, loaded", calls, "times")
+		return
+	}
+}
+
+func TestAppSettingsCacheInvalidateDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	c := &appSettingsCache{}
+
+	//Invalidate while a lookup is in progress, as if app settings were saved while
+	//the old app settings were being read. The old app settings must not be cached.
+	_, err := c.get(ctx, time.Minute, func(context.Context) (AppSettings, error) {
+		c.invalidate()
+		return AppSettings{ID: 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	var calls int64
+	_, err = c.get(ctx, time.Minute, countingLoader(&calls, 0))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if calls != 1 {
+		t.Fatal("expected app settings read before invalidating to not be cached")
+		return
+	}
+}
+
+// BenchmarkAppSettingsCache compares the number of lookups from the database, per
+// request, with and without caching when many requests are handled concurrently.
+// The loads/op metric is the number of simulated database queries per lookup.
+func BenchmarkAppSettingsCache(b *testing.B) {
+	ctx := context.Background()
+
+	for _, bm := range []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"uncached", 0},
+		{"cached", 10 * time.Second},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := &appSettingsCache{}
+
+			var calls int64
+			load := countingLoader(&calls, 50*time.Microsecond)
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, err := c.get(ctx, bm.ttl, load)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+
+			b.ReportMetric(float64(calls)/float64(b.N), "loads/op")
+		})
+	}
+}
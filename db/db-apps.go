@@ -3,11 +3,15 @@ package db
 import (
 	"context"
 	"database/sql"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/c9845/licensekeys/v3/licensefile"
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
 )
 
 //Apps stores the applications you generate license keys for.
@@ -42,7 +46,53 @@ type App struct {
 	// - {ext} is replaced with the file format's extension prepended by a period (ex: .json);
 	// - {appName} is replaced with the app's name, in lowercase and with spaces replaced by underscores.
 	// - {licenseID} is replaced with the license's ID.
+	// - {company} is replaced with the company the license was issued to.
+	// - {expireDate} is replaced with the license's expiration date (YYYY-MM-DD).
+	//Placeholders with blank values are removed and the filename is sanitized for use
+	//on common filesystems.
 	DownloadFilename string
+
+	//FileHeaderText is optional human readable text, for example legal text or support
+	//contact info, written as comment lines above the data in a license file. This is
+	//not signed so it can be changed without invalidating existing licenses.
+	FileHeaderText string
+
+	//FriendlyIDFormat and FriendlyIDPrefix define the optional human friendly
+	//identifier given to each license created for this app. A friendly ID is easier to
+	//read over the phone than the PublicID. When set, the friendly ID is included in
+	//the license file and is therefore signed. Changing these only affects licenses
+	//created afterwards.
+	FriendlyIDFormat friendlyIDFormat
+	FriendlyIDPrefix string
+
+	//RequiredSignatures is the number of key pairs that must sign each license created
+	//for this app. When more than 1, the license is signed by the chosen key pair plus
+	//other active key pairs for the app (co-signing) so that a single leaked private
+	//key cannot be used to create a valid license.
+	RequiredSignatures int
+
+	//AppLicenseNumbering enables numbering the licenses created for this app
+	//sequentially starting at 1, independent of the license ID which is shared by all
+	//apps. LastAppLicenseNumber is the number given to the most recently created
+	//license and is incremented each time a license is created. The license number is
+	//not included in the license file.
+	AppLicenseNumbering  bool
+	LastAppLicenseNumber int64
+
+	//LicenseSchemaVersion is the version of the app's license data schema, meaning
+	//what each custom field means, and is saved in each license created for this app.
+	//This is incremented by an administrator when custom fields change in a way that
+	//is not backwards compatible so that third-party apps can read a license's
+	//custom fields accordingly. 0 means the schema is not versioned and the version
+	//is not included in license files.
+	LicenseSchemaVersion int
+
+	//LicenseEmailSubject and LicenseEmailBody are the optional templates used when
+	//emailing a license to a customer, so that the email can be branded per app. The
+	//placeholders in LicenseEmailPlaceholders are replaced with the license's data.
+	//When blank, DefaultLicenseEmailSubject and DefaultLicenseEmailBody are used.
+	LicenseEmailSubject string
+	LicenseEmailBody    string
 }
 
 const (
@@ -60,12 +110,124 @@ const (
 			ShowLicenseID INTEGER NOT NULL DEFAULT 1,
 			ShowAppName INTEGER NOT NULL DEFAULT 1,
 			DownloadFilename TEXT NOT NULL,
+			FileHeaderText TEXT NOT NULL DEFAULT '',
+			FriendlyIDFormat TEXT NOT NULL DEFAULT '',
+			FriendlyIDPrefix TEXT NOT NULL DEFAULT '',
+			RequiredSignatures INTEGER NOT NULL DEFAULT 1,
+			AppLicenseNumbering INTEGER NOT NULL DEFAULT 0,
+			LastAppLicenseNumber INTEGER NOT NULL DEFAULT 0,
+			LicenseSchemaVersion INTEGER NOT NULL DEFAULT 0,
+			LicenseEmailSubject TEXT NOT NULL DEFAULT '',
+			LicenseEmailBody TEXT NOT NULL DEFAULT '',
 
 			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID)
 		)
 	`
+
+	updateAppsAddFileHeaderText   = `ALTER TABLE ` + TableApps + ` ADD COLUMN FileHeaderText TEXT NOT NULL DEFAULT ''`
+	updateAppsAddFriendlyIDFormat = `ALTER TABLE ` + TableApps + ` ADD COLUMN FriendlyIDFormat TEXT NOT NULL DEFAULT ''`
+	updateAppsAddFriendlyIDPrefix = `ALTER TABLE ` + TableApps + ` ADD COLUMN FriendlyIDPrefix TEXT NOT NULL DEFAULT ''`
+
+	updateAppsAddRequiredSignatures = `ALTER TABLE ` + TableApps + ` ADD COLUMN RequiredSignatures INTEGER NOT NULL DEFAULT 1`
+
+	updateAppsAddAppLicenseNumbering  = `ALTER TABLE ` + TableApps + ` ADD COLUMN AppLicenseNumbering INTEGER NOT NULL DEFAULT 0`
+	updateAppsAddLastAppLicenseNumber = `ALTER TABLE ` + TableApps + ` ADD COLUMN LastAppLicenseNumber INTEGER NOT NULL DEFAULT 0`
+
+	updateAppsAddLicenseSchemaVersion = `ALTER TABLE ` + TableApps + ` ADD COLUMN LicenseSchemaVersion INTEGER NOT NULL DEFAULT 0`
+
+	updateAppsAddLicenseEmailSubject = `ALTER TABLE ` + TableApps + ` ADD COLUMN LicenseEmailSubject TEXT NOT NULL DEFAULT ''`
+	updateAppsAddLicenseEmailBody    = `ALTER TABLE ` + TableApps + ` ADD COLUMN LicenseEmailBody TEXT NOT NULL DEFAULT ''`
+)
+
+// MaxRequiredSignatures is the most key pairs that can be required to sign a license.
+// This is limited since each signature increases the size of the license file and the
+// time it takes to create and verify a license.
+const MaxRequiredSignatures = 5
+
+// Define the placeholders that can be used in an app's license email templates.
+const (
+	LicenseEmailPlaceholderCompany    = "{company}"    //the company the license was issued to.
+	LicenseEmailPlaceholderAppName    = "{appName}"    //the app's name.
+	LicenseEmailPlaceholderExpireDate = "{expireDate}" //the license's expiration date, YYYY-MM-DD.
+	LicenseEmailPlaceholderLicenseID  = "{licenseID}"  //the license's ID.
+)
+
+// LicenseEmailPlaceholders is the list of placeholders that can be used in an app's
+// license email templates.
+var LicenseEmailPlaceholders = []string{
+	LicenseEmailPlaceholderCompany,
+	LicenseEmailPlaceholderAppName,
+	LicenseEmailPlaceholderExpireDate,
+	LicenseEmailPlaceholderLicenseID,
+}
+
+// The templates used when an app's license email templates are blank.
+const (
+	DefaultLicenseEmailSubject = "Your {appName} license"
+	DefaultLicenseEmailBody    = `Hello,
+
+Your license for {appName}, issued to {company}, is attached. License ID {licenseID} is valid until {expireDate}.
+
+Save the attached file where {appName} can read it. Please contact us if you have any questions.`
+)
+
+// The maximum lengths of an app's license email templates.
+const (
+	licenseEmailSubjectMaxLength = 200
+	licenseEmailBodyMaxLength    = 10000
+)
+
+// licenseEmailPlaceholderRegex matches a placeholder in a license email template.
+var licenseEmailPlaceholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+// validateLicenseEmailTemplate checks that each placeholder in a license email
+// template is known and that no braces are left unmatched, which is typically a typo
+// in a placeholder. An errMsg is returned describing the first problem found.
+func validateLicenseEmailTemplate(t string) (errMsg string) {
+	for _, p := range licenseEmailPlaceholderRegex.FindAllString(t, -1) {
+		if !slices.Contains(LicenseEmailPlaceholders, p) {
+			return "The placeholder " + p + " is not supported. Use one of " + strings.Join(LicenseEmailPlaceholders, ", ") + "."
+		}
+	}
+
+	if strings.ContainsAny(licenseEmailPlaceholderRegex.ReplaceAllString(t, ""), "{}") {
+		return "A placeholder is missing an opening or closing brace."
+	}
+
+	return ""
+}
+
+// Define the formats of friendly IDs given to licenses.
+type friendlyIDFormat string
+
+const (
+	FriendlyIDFormatNone       = friendlyIDFormat("")           //licenses are not given a friendly ID.
+	FriendlyIDFormatSequential = friendlyIDFormat("Sequential") //prefix plus the license's ID, ex.: ACME-10001.
+	FriendlyIDFormatCode       = friendlyIDFormat("Code")       //prefix plus a short random code, ex.: ACME-7KQ2M9XD.
 )
 
+var friendlyIDFormats = []friendlyIDFormat{
+	FriendlyIDFormatNone,
+	FriendlyIDFormatSequential,
+	FriendlyIDFormatCode,
+}
+
+// Valid checks if a provided friendly ID format is one of our supported formats.
+func (f friendlyIDFormat) Valid() bool {
+	for _, v := range friendlyIDFormats {
+		if v == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// friendlyIDPrefixRegex is the set of characters allowed in a friendly ID prefix. This
+// is limited so that friendly IDs are easy to read aloud and safe to use in URLs and
+// filenames.
+var friendlyIDPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{0,16}$`)
+
 // Validate is used to validate a struct's data before adding or saving changes. This also
 // handles sanitizing.
 func (a *App) Validate(ctx context.Context) (errMsg string, err error) {
@@ -73,6 +235,10 @@ func (a *App) Validate(ctx context.Context) (errMsg string, err error) {
 	a.Name = strings.TrimSpace(a.Name)
 	a.DownloadFilename = strings.TrimSpace(a.DownloadFilename)
 	a.DownloadFilename = strings.ReplaceAll(a.DownloadFilename, " ", "_")
+	a.FileHeaderText = strings.TrimSpace(strings.ReplaceAll(a.FileHeaderText, "\r\n", "\n"))
+	a.FriendlyIDPrefix = strings.TrimSpace(a.FriendlyIDPrefix)
+	a.LicenseEmailSubject = strings.TrimSpace(a.LicenseEmailSubject)
+	a.LicenseEmailBody = strings.TrimSpace(strings.ReplaceAll(a.LicenseEmailBody, "\r\n", "\n"))
 
 	//Validate
 	if a.Name == "" {
@@ -83,6 +249,10 @@ func (a *App) Validate(ctx context.Context) (errMsg string, err error) {
 		errMsg = "The default license period cannot be less than 0 days."
 		return
 	}
+	if a.LicenseSchemaVersion < 0 {
+		errMsg = "The license schema version cannot be less than 0."
+		return
+	}
 
 	err = a.FileFormat.Valid()
 	if err != nil {
@@ -95,6 +265,45 @@ func (a *App) Validate(ctx context.Context) (errMsg string, err error) {
 		return
 	}
 
+	if !a.FriendlyIDFormat.Valid() {
+		errMsg = "Please choose a license friendly ID format from the provided options."
+		return
+	}
+	if !friendlyIDPrefixRegex.MatchString(a.FriendlyIDPrefix) {
+		errMsg = "The license friendly ID prefix can only contain letters, numbers, dashes, and underscores, and must be at most 16 characters."
+		return
+	}
+	if a.FriendlyIDFormat == FriendlyIDFormatNone {
+		a.FriendlyIDPrefix = ""
+	}
+
+	if a.RequiredSignatures < 1 || a.RequiredSignatures > MaxRequiredSignatures {
+		errMsg = "The number of required signatures must be between 1 and " + strconv.Itoa(MaxRequiredSignatures) + "."
+		return
+	}
+
+	//The subject cannot span lines since it is used as an email header.
+	if strings.ContainsAny(a.LicenseEmailSubject, "\r\n") {
+		errMsg = "The license email subject must be a single line."
+		return
+	}
+	if len(a.LicenseEmailSubject) > licenseEmailSubjectMaxLength {
+		errMsg = "The license email subject must be at most " + strconv.Itoa(licenseEmailSubjectMaxLength) + " characters."
+		return
+	}
+	if len(a.LicenseEmailBody) > licenseEmailBodyMaxLength {
+		errMsg = "The license email body must be at most " + strconv.Itoa(licenseEmailBodyMaxLength) + " characters."
+		return
+	}
+	if msg := validateLicenseEmailTemplate(a.LicenseEmailSubject); msg != "" {
+		errMsg = "License email subject: " + msg
+		return
+	}
+	if msg := validateLicenseEmailTemplate(a.LicenseEmailBody); msg != "" {
+		errMsg = "License email body: " + msg
+		return
+	}
+
 	//Check if an app with this name already exists. We don't want duplicate app names.
 	//This uses the ID to handle if we are updating an app (ID is > 0) where the same
 	//name would be allowed as long as the IDs match (updating "this" app).
@@ -142,7 +351,7 @@ func GetAppByID(ctx context.Context, id int64) (a App, err error) {
 }
 
 // Insert saves an app. You should have already called Validate().
-func (a *App) Insert(ctx context.Context) (err error) {
+func (a *App) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	cols := sqldb.Columns{
 		"CreatedByUserID",
 		"Active",
@@ -152,6 +361,14 @@ func (a *App) Insert(ctx context.Context) (err error) {
 		"ShowLicenseID",
 		"ShowAppName",
 		"DownloadFilename",
+		"FileHeaderText",
+		"FriendlyIDFormat",
+		"FriendlyIDPrefix",
+		"RequiredSignatures",
+		"AppLicenseNumbering",
+		"LicenseSchemaVersion",
+		"LicenseEmailSubject",
+		"LicenseEmailBody",
 	}
 	b := sqldb.Bindvars{
 		a.CreatedByUserID,
@@ -162,6 +379,14 @@ func (a *App) Insert(ctx context.Context) (err error) {
 		a.ShowLicenseID,
 		a.ShowAppName,
 		a.DownloadFilename,
+		a.FileHeaderText,
+		a.FriendlyIDFormat,
+		a.FriendlyIDPrefix,
+		a.RequiredSignatures,
+		a.AppLicenseNumbering,
+		a.LicenseSchemaVersion,
+		a.LicenseEmailSubject,
+		a.LicenseEmailBody,
 	}
 	colString, valString, err := cols.ForInsert()
 	if err != nil {
@@ -169,8 +394,7 @@ func (a *App) Insert(ctx context.Context) (err error) {
 	}
 
 	q := `INSERT INTO ` + TableApps + `(` + colString + `) VALUES (` + valString + `)`
-	c := sqldb.Connection()
-	stmt, err := c.PrepareContext(ctx, q)
+	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
 		return
 	}
@@ -227,6 +451,13 @@ func (a *App) Update(ctx context.Context) (err error) {
 		"ShowLicenseID",
 		"ShowAppName",
 		"DownloadFilename",
+		"FileHeaderText",
+		"FriendlyIDFormat",
+		"FriendlyIDPrefix",
+		"RequiredSignatures",
+		"AppLicenseNumbering",
+		"LicenseEmailSubject",
+		"LicenseEmailBody",
 	}
 	colString, err := cols.ForUpdate()
 	if err != nil {
@@ -252,8 +483,59 @@ func (a *App) Update(ctx context.Context) (err error) {
 		a.ShowLicenseID,
 		a.ShowAppName,
 		a.DownloadFilename,
+		a.FileHeaderText,
+		a.FriendlyIDFormat,
+		a.FriendlyIDPrefix,
+		a.RequiredSignatures,
+		a.AppLicenseNumbering,
+		a.LicenseEmailSubject,
+		a.LicenseEmailBody,
 
 		a.ID,
 	)
 	return
 }
+
+// BumpLicenseSchemaVersion increments an app's license schema version and returns
+// the new version. Licenses created for the app afterwards include the new version.
+func BumpLicenseSchemaVersion(ctx context.Context, appID int64) (version int, err error) {
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	q := `
+		UPDATE ` + TableApps + `
+		SET
+			DatetimeModified = ?,
+			LicenseSchemaVersion = LicenseSchemaVersion + 1
+		WHERE ID = ?
+	`
+	res, err := tx.ExecContext(ctx, q, timestamps.YMDHMS(), appID)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return
+	} else if rows == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+
+	q = `
+		SELECT ` + TableApps + `.LicenseSchemaVersion
+		FROM ` + TableApps + `
+		WHERE ID = ?
+	`
+	err = tx.GetContext(ctx, &version, q, appID)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c9845/sqldb/v3"
+)
+
+//This file handles backing up the database to a file. Backups are made with VACUUM
+//INTO which reads the database in a single transaction, so the backup is consistent
+//even while other requests are writing to the database and when the database is in
+//WAL mode (uncommitted data in the WAL file is not included).
+
+// Backup file naming. The timestamp is used so that backups sort in the order they
+// were created and so that old backups can be identified for pruning.
+const (
+	backupFilePrefix     = "licensekeys-"
+	backupFileSuffix     = ".db"
+	backupFileTimeFormat = "20060102-150405"
+)
+
+// Backup saves a copy of the database to a new file in the given directory. The path
+// to, and size of, the backup file is returned.
+func Backup(ctx context.Context, dir string) (path string, size int64, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+
+	path = filepath.Join(dir, backupFilePrefix+time.Now().UTC().Format(backupFileTimeFormat)+backupFileSuffix)
+
+	//VACUUM INTO fails if the file already exists, which would only happen if two
+	//backups were made within the same second.
+	_, err = os.Stat(path)
+	if err == nil {
+		err = os.ErrExist
+		return
+	} else if !os.IsNotExist(err) {
+		return
+	}
+
+	q := `VACUUM INTO ?`
+
+	c := sqldb.Connection()
+	_, err = c.ExecContext(ctx, q, path)
+	if err != nil {
+		return
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	size = fi.Size()
+	return
+}
+
+// PruneBackups deletes backup files in the given directory that were created more
+// than maxAge ago. Only files named like a backup created by Backup() are deleted. The
+// paths to the deleted files are returned.
+func PruneBackups(dir string, maxAge time.Duration) (deleted []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-maxAge)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupFilePrefix) || !strings.HasSuffix(name, backupFileSuffix) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, backupFilePrefix), backupFileSuffix)
+		created, innerErr := time.Parse(backupFileTimeFormat, ts)
+		if innerErr != nil {
+			//Not a backup file created by this app, ignore it.
+			continue
+		}
+		if created.After(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		err = os.Remove(path)
+		if err != nil {
+			return
+		}
+
+		deleted = append(deleted, path)
+	}
+
+	return
+}
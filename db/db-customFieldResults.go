@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"errors"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -115,7 +116,7 @@ func (f *CustomFieldResult) Insert(ctx context.Context, tx *sqlx.Tx) (err error)
 	case CustomFieldTypeDecimal:
 		cols = append(cols, "DecimalValue")
 		b = append(b, f.DecimalValue)
-	case CustomFieldTypeText:
+	case CustomFieldTypeText, CustomFieldTypeEmail, CustomFieldTypeURL, CustomFieldTypeFileHash:
 		cols = append(cols, "TextValue")
 		b = append(b, f.TextValue)
 	case CustomFieldTypeBoolean:
@@ -176,7 +177,11 @@ func GetCustomFieldResults(ctx context.Context, licenseID int64) (ff []CustomFie
 //
 // The input provided results are modified with data from the defined field to use
 // when saving the results to the database.
-func (results MultiCustomFieldResult) Validate(ctx context.Context, appID int64, viaAPI bool) (errMsg string, err error) {
+//
+// licenseExpireDate, YYYY-MM-DD, is the expiration date of the license the results
+// are for. This is used to make sure a date field's value does not exceed the
+// license's expiration when the defined field requires it.
+func (results MultiCustomFieldResult) Validate(ctx context.Context, appID int64, licenseExpireDate string, viaAPI bool) (errMsg string, err error) {
 	//Look up fields defined for app.
 	definedFields, err := GetCustomFieldsDefined(ctx, appID, true)
 	if err != nil {
@@ -262,8 +267,50 @@ func (results MultiCustomFieldResult) Validate(ctx context.Context, appID int64,
 			}
 
 		case CustomFieldTypeText:
-			//blank values are acceptable for text fields
+			//blank values are acceptable for text fields, unless the field has a
+			//validation regex that the blank value doesn't match.
+			matchingResult.TextValue = null.StringFrom(strings.TrimSpace(matchingResult.TextValue.String))
+
+			if definedField.TextValidationRegex != "" {
+				rx, innerErr := regexp.Compile(definedField.TextValidationRegex)
+				if innerErr != nil {
+					errMsg = "The validation regex for the " + definedField.Name + " field is invalid."
+					err = innerErr
+					return
+				}
+
+				if !rx.MatchString(matchingResult.TextValue.String) {
+					errMsg = "The value for the " + definedField.Name + " field is not in the required format."
+					return
+				}
+			}
+
+		case CustomFieldTypeEmail:
+			//blank values are acceptable, like text fields, but a provided value
+			//must be an email address.
+			matchingResult.TextValue = null.StringFrom(strings.TrimSpace(matchingResult.TextValue.String))
+			if matchingResult.TextValue.String != "" && !validEmail(matchingResult.TextValue.String) {
+				errMsg = "The value for the " + definedField.Name + " field must be a valid email address."
+				return
+			}
+
+		case CustomFieldTypeURL:
+			//blank values are acceptable, like text fields, but a provided value
+			//must be a URL.
 			matchingResult.TextValue = null.StringFrom(strings.TrimSpace(matchingResult.TextValue.String))
+			if matchingResult.TextValue.String != "" && !validURL(matchingResult.TextValue.String) {
+				errMsg = "The value for the " + definedField.Name + " field must be a valid URL starting with http:// or https://."
+				return
+			}
+
+		case CustomFieldTypeFileHash:
+			//a value is required since a license with a file hash field is meant to
+			//be bound to a specific build.
+			matchingResult.TextValue = null.StringFrom(strings.ToLower(strings.TrimSpace(matchingResult.TextValue.String)))
+			if !validSHA256(matchingResult.TextValue.String) {
+				errMsg = "The value for the " + definedField.Name + " field must be a SHA-256 hash, 64 hexadecimal characters."
+				return
+			}
 
 		case CustomFieldTypeBoolean:
 			//default to false
@@ -292,6 +339,10 @@ func (results MultiCustomFieldResult) Validate(ctx context.Context, appID int64,
 				errMsg = "The date for the " + definedField.Name + " field must be in the future."
 				return
 			}
+			if definedField.CannotExceedLicenseExpiration && licenseExpireDate != "" && matchingResult.DateValue.String > licenseExpireDate {
+				errMsg = "The date for the " + definedField.Name + " field cannot be after the license's expiration date, " + licenseExpireDate + "."
+				return
+			}
 
 		default:
 			//This should never occur since we are looping through defined custom
@@ -2,12 +2,18 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"net/mail"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
 	"golang.org/x/exp/slices"
 	"gopkg.in/guregu/null.v3"
 )
@@ -51,6 +57,22 @@ type CustomFieldDefined struct {
 	NumberMaxValue     null.Float  //""
 	MultiChoiceOptions null.String //semicolon separated list of options
 
+	//TextValidationRegex is an optional regular expression a text field's value must
+	//match, for example to enforce the format of a customer code. Use ^ and $ to
+	//match the entire value. The regex uses Go's syntax, which is mostly compatible
+	//with the regex syntax used in the GUI for validating in the browser.
+	TextValidationRegex string
+
+	//CannotExceedLicenseExpiration requires a date field's value to be on or before
+	//the license's expiration date, for example for a "feature enabled until" field.
+	//This is only used for date fields.
+	CannotExceedLicenseExpiration bool
+
+	//SortOrder is the position of this field when creating a license, lower values
+	//are shown first. Fields with the same value are sorted by name. New fields are
+	//added to the end of the order.
+	SortOrder int64
+
 	//When saving a license, we retrieve the defined fields for an app
 	//and set the value for each field using the same list of objects
 	//returned just for ease of use and not changing types. Therefore,
@@ -90,11 +112,18 @@ const (
 			NumberMinValue REAL DEFAULT NULL,
 			NumberMaxValue REAL DEFAULT NULL,
 			MultiChoiceOptions TEXT DEFAULT NULL,
+			TextValidationRegex TEXT NOT NULL DEFAULT '',
+			CannotExceedLicenseExpiration INTEGER NOT NULL DEFAULT 0,
+			SortOrder INTEGER NOT NULL DEFAULT 0,
 
 			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
 			FOREIGN KEY (AppID) REFERENCES ` + TableApps + `(ID)
 		)
 	`
+
+	updateCustomFieldsDefinedAddTextValidationRegex           = `ALTER TABLE ` + TableCustomFieldDefined + ` ADD COLUMN TextValidationRegex TEXT NOT NULL DEFAULT ''`
+	updateCustomFieldsDefinedAddCannotExceedLicenseExpiration = `ALTER TABLE ` + TableCustomFieldDefined + ` ADD COLUMN CannotExceedLicenseExpiration INTEGER NOT NULL DEFAULT 0`
+	updateCustomFieldsDefinedAddSortOrder                     = `ALTER TABLE ` + TableCustomFieldDefined + ` ADD COLUMN SortOrder INTEGER NOT NULL DEFAULT 0`
 )
 
 // Define the types of custom fields this app supports.
@@ -107,6 +136,9 @@ const (
 	CustomFieldTypeBoolean     = customFieldType("Boolean")
 	CustomFieldTypeMultiChoice = customFieldType("Multi-Choice")
 	CustomFieldTypeDate        = customFieldType("Date")
+	CustomFieldTypeEmail       = customFieldType("Email")
+	CustomFieldTypeURL         = customFieldType("URL")
+	CustomFieldTypeFileHash    = customFieldType("File Hash")
 )
 
 var customFieldTypes = []customFieldType{
@@ -116,6 +148,9 @@ var customFieldTypes = []customFieldType{
 	CustomFieldTypeBoolean,
 	CustomFieldTypeMultiChoice,
 	CustomFieldTypeDate,
+	CustomFieldTypeEmail,
+	CustomFieldTypeURL,
+	CustomFieldTypeFileHash,
 }
 
 // multiSeparator is the character used to split options for a multichoice field when
@@ -124,6 +159,38 @@ var customFieldTypes = []customFieldType{
 // creates/saves a license.
 const multiSeparator = ";"
 
+// validEmail checks if s is a single email address without a display name, i.e.:
+// "user@example.com", not "User <user@example.com>".
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return false
+	}
+
+	return addr.Address == s
+}
+
+// validURL checks if s is an absolute http or https URL.
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validSHA256 checks if s is a hex encoded SHA-256 hash, 64 hexadecimal characters.
+// s should already be lowercased.
+func validSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 // Valid checks if a provided field type is one of our supported types. This is used
 // for validation purposes.
 func (c customFieldType) Valid() bool {
@@ -143,6 +210,10 @@ func (cfd *CustomFieldDefined) Validate(ctx context.Context) (errMsg string, err
 	cfd.Name = strings.TrimSpace(cfd.Name)
 	cfd.Instructions = strings.TrimSpace(cfd.Instructions)
 
+	if cfd.Type != CustomFieldTypeDate {
+		cfd.CannotExceedLicenseExpiration = false
+	}
+
 	//Validate
 	if cfd.Name == "" {
 		errMsg = "You must provide a name for this field."
@@ -172,6 +243,48 @@ func (cfd *CustomFieldDefined) Validate(ctx context.Context) (errMsg string, err
 			return
 		}
 
+		cfd.TextValidationRegex = strings.TrimSpace(cfd.TextValidationRegex)
+		if cfd.TextValidationRegex != "" {
+			rx, innerErr := regexp.Compile(cfd.TextValidationRegex)
+			if innerErr != nil {
+				errMsg = "The validation regex is invalid. " + innerErr.Error()
+				return
+			}
+
+			if !rx.MatchString(cfd.TextDefaultValue.String) {
+				errMsg = "The default value does not match the validation regex."
+				return
+			}
+		}
+
+	case CustomFieldTypeEmail, CustomFieldTypeURL:
+		//A default is optional since there usually isn't a sensible default
+		//email address or URL. The format is validated, not a regex.
+		cfd.TextDefaultValue.String = strings.TrimSpace(cfd.TextDefaultValue.String)
+		cfd.TextValidationRegex = ""
+
+		if cfd.TextDefaultValue.String != "" {
+			if cfd.Type == CustomFieldTypeEmail && !validEmail(cfd.TextDefaultValue.String) {
+				errMsg = "The default value must be a valid email address."
+				return
+			}
+			if cfd.Type == CustomFieldTypeURL && !validURL(cfd.TextDefaultValue.String) {
+				errMsg = "The default value must be a valid URL starting with http:// or https://."
+				return
+			}
+		}
+
+	case CustomFieldTypeFileHash:
+		//A default is optional since the hash is usually specific to the build a
+		//license is for. Hashes are stored lowercase so that comparing is simpler.
+		cfd.TextDefaultValue.String = strings.ToLower(strings.TrimSpace(cfd.TextDefaultValue.String))
+		cfd.TextValidationRegex = ""
+
+		if cfd.TextDefaultValue.String != "" && !validSHA256(cfd.TextDefaultValue.String) {
+			errMsg = "The default value must be a SHA-256 hash, 64 hexadecimal characters."
+			return
+		}
+
 	case CustomFieldTypeBoolean:
 		//nothing to do here
 
@@ -268,7 +381,19 @@ func GetFieldByName(ctx context.Context, appID int64, name string) (cfd CustomFi
 }
 
 // Insert saves a defined field. You should have already called Validate().
-func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
+//
+// The field is added to the end of the app's sort order.
+func (cfd *CustomFieldDefined) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		SELECT IFNULL(MAX(SortOrder), 0) + 1
+		FROM ` + TableCustomFieldDefined + `
+		WHERE AppID = ?
+	`
+	err = tx.GetContext(ctx, &cfd.SortOrder, q, cfd.AppID)
+	if err != nil {
+		return
+	}
+
 	cols := sqldb.Columns{
 		"CreatedByUserID",
 		"Active",
@@ -276,6 +401,7 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 		"Type",
 		"Name",
 		"Instructions",
+		"SortOrder",
 	}
 	b := sqldb.Bindvars{
 		cfd.CreatedByUserID,
@@ -284,6 +410,7 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 		cfd.Type,
 		cfd.Name,
 		cfd.Instructions,
+		cfd.SortOrder,
 	}
 
 	switch cfd.Type {
@@ -295,9 +422,9 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 		cols = append(cols, "DecimalDefaultValue", "NumberMinValue", "NumberMaxValue")
 		b = append(b, cfd.DecimalDefaultValue.Float64, cfd.NumberMinValue.Float64, cfd.NumberMaxValue.Float64)
 
-	case CustomFieldTypeText:
-		cols = append(cols, "TextDefaultValue")
-		b = append(b, cfd.TextDefaultValue)
+	case CustomFieldTypeText, CustomFieldTypeEmail, CustomFieldTypeURL, CustomFieldTypeFileHash:
+		cols = append(cols, "TextDefaultValue", "TextValidationRegex")
+		b = append(b, cfd.TextDefaultValue, cfd.TextValidationRegex)
 
 	case CustomFieldTypeBoolean:
 		cols = append(cols, "BoolDefaultValue")
@@ -308,8 +435,8 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 		b = append(b, cfd.MultiChoiceDefaultValue, cfd.MultiChoiceOptions)
 
 	case CustomFieldTypeDate:
-		cols = append(cols, "DateDefaultIncrement")
-		b = append(b, cfd.DateDefaultIncrement)
+		cols = append(cols, "DateDefaultIncrement", "CannotExceedLicenseExpiration")
+		b = append(b, cfd.DateDefaultIncrement, cfd.CannotExceedLicenseExpiration)
 
 	default:
 		err = errors.New("unknown custom field type, this should have been caught by Validate() " + string(cfd.Type))
@@ -321,9 +448,8 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 		return
 	}
 
-	q := `INSERT INTO ` + TableCustomFieldDefined + `(` + colString + `) VALUES (` + valString + `)`
-	c := sqldb.Connection()
-	stmt, err := c.PrepareContext(ctx, q)
+	q = `INSERT INTO ` + TableCustomFieldDefined + `(` + colString + `) VALUES (` + valString + `)`
+	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
 		return
 	}
@@ -340,6 +466,7 @@ func (cfd *CustomFieldDefined) Insert(ctx context.Context) (err error) {
 }
 
 // GetCustomFieldsDefined returns the list of fields for an app optionally filtered by active fields only.
+// Fields are returned in the sort order set for the app.
 func GetCustomFieldsDefined(ctx context.Context, appID int64, activeOnly bool) (cc []CustomFieldDefined, err error) {
 	//base query
 	q := `
@@ -366,7 +493,7 @@ func GetCustomFieldsDefined(ctx context.Context, appID int64, activeOnly bool) (
 	}
 
 	//complete query
-	q += ` ORDER BY ` + TableCustomFieldDefined + `.Active DESC, ` + TableCustomFieldDefined + `.Name COLLATE NOCASE ASC`
+	q += ` ORDER BY ` + TableCustomFieldDefined + `.Active DESC, ` + TableCustomFieldDefined + `.SortOrder ASC, ` + TableCustomFieldDefined + `.Name COLLATE NOCASE ASC`
 
 	//run query
 	c := sqldb.Connection()
@@ -396,9 +523,9 @@ func (cfd *CustomFieldDefined) Update(ctx context.Context) (err error) {
 		cols = append(cols, "DecimalDefaultValue", "NumberMinValue", "NumberMaxValue")
 		b = append(b, cfd.DecimalDefaultValue.Float64, cfd.NumberMinValue.Float64, cfd.NumberMaxValue.Float64)
 
-	case CustomFieldTypeText:
-		cols = append(cols, "TextDefaultValue")
-		b = append(b, cfd.TextDefaultValue)
+	case CustomFieldTypeText, CustomFieldTypeEmail, CustomFieldTypeURL, CustomFieldTypeFileHash:
+		cols = append(cols, "TextDefaultValue", "TextValidationRegex")
+		b = append(b, cfd.TextDefaultValue, cfd.TextValidationRegex)
 
 	case CustomFieldTypeBoolean:
 		cols = append(cols, "BoolDefaultValue")
@@ -409,8 +536,8 @@ func (cfd *CustomFieldDefined) Update(ctx context.Context) (err error) {
 		b = append(b, cfd.MultiChoiceDefaultValue, cfd.MultiChoiceOptions)
 
 	case CustomFieldTypeDate:
-		cols = append(cols, "DateDefaultIncrement")
-		b = append(b, cfd.DateDefaultIncrement)
+		cols = append(cols, "DateDefaultIncrement", "CannotExceedLicenseExpiration")
+		b = append(b, cfd.DateDefaultIncrement, cfd.CannotExceedLicenseExpiration)
 
 	default:
 		err = errors.New("unknown custom field type, this should have been caught by Validate() " + string(cfd.Type))
@@ -467,3 +594,123 @@ func (cfd *CustomFieldDefined) Delete(ctx context.Context) (err error) {
 	)
 	return
 }
+
+// ReorderCustomFieldsDefined sets the sort order of an app's fields. The fields are
+// given in the order they should be shown, the first field is shown first. Each ID
+// must be a field for the app.
+func ReorderCustomFieldsDefined(ctx context.Context, appID int64, ids []int64) (err error) {
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	q := `
+		UPDATE ` + TableCustomFieldDefined + `
+		SET
+			SortOrder = ?,
+			DatetimeModified = ?
+		WHERE 
+			(ID = ?)
+			AND
+			(AppID = ?)
+	`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	now := timestamps.YMDHMS()
+	for i, id := range ids {
+		res, innerErr := stmt.ExecContext(ctx, i+1, now, id, appID)
+		if innerErr != nil {
+			return innerErr
+		}
+
+		n, innerErr := res.RowsAffected()
+		if innerErr != nil {
+			return innerErr
+		} else if n != 1 {
+			return errors.New("custom field is not for this app")
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// ReferencedCustomFieldDefined is a deleted custom field that cannot be purged because
+// licenses have results for the field.
+type ReferencedCustomFieldDefined struct {
+	ID         int64
+	AppID      int64
+	AppName    string
+	Name       string
+	NumResults int64 //the number of results, one per license, for this field.
+}
+
+// PurgeCustomFieldsDefined permanently removes deleted custom fields that were never
+// used in a license. Deleted fields that have results are not removed since the
+// results are needed to rebuild the licenses the field was used in, these fields are
+// returned instead.
+func PurgeCustomFieldsDefined(ctx context.Context) (rowsDeleted int64, skipped []ReferencedCustomFieldDefined, err error) {
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	//Get the fields that can't be removed, for reporting.
+	q := `
+		SELECT
+			` + TableCustomFieldDefined + `.ID,
+			` + TableCustomFieldDefined + `.AppID,
+			` + TableApps + `.Name AS AppName,
+			` + TableCustomFieldDefined + `.Name,
+			COUNT(` + TableCustomFieldResults + `.ID) AS NumResults
+		FROM ` + TableCustomFieldDefined + `
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableCustomFieldDefined + `.AppID
+		JOIN ` + TableCustomFieldResults + ` ON ` + TableCustomFieldResults + `.CustomFieldDefinedID = ` + TableCustomFieldDefined + `.ID
+		WHERE ` + TableCustomFieldDefined + `.Active = ?
+		GROUP BY ` + TableCustomFieldDefined + `.ID
+		ORDER BY ` + TableApps + `.Name COLLATE NOCASE ASC, ` + TableCustomFieldDefined + `.Name COLLATE NOCASE ASC
+	`
+	err = tx.SelectContext(ctx, &skipped, q, false)
+	if err != nil {
+		return
+	}
+
+	//Remove the fields that were never used.
+	q = `
+		DELETE FROM ` + TableCustomFieldDefined + `
+		WHERE
+			(Active = ?)
+			AND
+			NOT EXISTS (
+				SELECT 1
+				FROM ` + TableCustomFieldResults + `
+				WHERE ` + TableCustomFieldResults + `.CustomFieldDefinedID = ` + TableCustomFieldDefined + `.ID
+			)
+	`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, false)
+	if err != nil {
+		return
+	}
+
+	rowsDeleted, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
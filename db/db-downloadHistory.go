@@ -99,7 +99,7 @@ func (h *DownloadHistory) Insert(ctx context.Context) (err error) {
 
 // GetHistory returns the download history for a license.
 func GetHistory(ctx context.Context, licenseID int64, orderBy string) (hh []DownloadHistory, err error) {
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
 	q := `
 		SELECT 
 			` + TableDownloadHistory + `.*,
@@ -6,9 +6,11 @@ import (
 	"log"
 	"strings"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/licensefile"
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
 )
 
 //This table stores the public/private keypair used for generating license signatures.
@@ -52,6 +54,13 @@ type KeyPair struct {
 	//an app can be set as default, obviously. If the default keypair
 	//is deleted another is not set automatically.
 	IsDefault bool
+
+	//Compromised is set when the private key is known, or suspected, to have been
+	//leaked. A compromised keypair cannot be used to sign new licenses and cannot
+	//be set as the default keypair. Licenses already signed with the keypair can
+	//still be downloaded and verified. This cannot be undone, create a new keypair
+	//instead.
+	Compromised bool
 }
 
 const (
@@ -70,11 +79,14 @@ const (
 			AlgorithmType TEXT NOT NULL,
 			PrivateKeyEncrypted INTEGER NOT NULL,
 			IsDefault INTEGER NOT NULL DEFAULT 0,
+			Compromised INTEGER NOT NULL DEFAULT 0,
 
 			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
 			FOREIGN KEY (AppID) REFERENCES ` + TableApps + `(ID)
 		)
 	`
+
+	updateKeyPairsAddCompromised = `ALTER TABLE ` + TableKeyPairs + ` ADD COLUMN Compromised INTEGER NOT NULL DEFAULT 0`
 )
 
 // GetKeyPairByName looks up a key pair by its name.
@@ -105,6 +117,13 @@ func (k *KeyPair) Validate(ctx context.Context) (errMsg string, err error) {
 		return
 	}
 
+	//Make sure the algorithm isn't weaker than the minimum set in the config file.
+	minimum := config.Data().MinimumKeyPairAlgorithm
+	if minimum != "" && !k.AlgorithmType.AtLeast(minimum) {
+		errMsg = "The algorithm chosen is weaker than the minimum allowed algorithm, " + string(minimum) + ". Please choose a stronger algorithm."
+		return
+	}
+
 	//Make sure an active keypair with this name doesn't already exist for this app.
 	existing, err := GetKeyPairByName(ctx, k.Name)
 	if err == sql.ErrNoRows {
@@ -121,7 +140,7 @@ func (k *KeyPair) Validate(ctx context.Context) (errMsg string, err error) {
 
 // Insert saves a key pair.
 // You should have already called Validate().
-func (k *KeyPair) Insert(ctx context.Context) (err error) {
+func (k *KeyPair) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	cols := sqldb.Columns{
 		"CreatedByUserID",
 		"Active",
@@ -150,8 +169,7 @@ func (k *KeyPair) Insert(ctx context.Context) (err error) {
 	}
 
 	q := `INSERT INTO ` + TableKeyPairs + `(` + colString + `) VALUES (` + valString + `)`
-	c := sqldb.Connection()
-	stmt, err := c.PrepareContext(ctx, q)
+	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
 		return
 	}
@@ -241,6 +259,36 @@ func (k *KeyPair) Delete(ctx context.Context) (err error) {
 	return
 }
 
+// MarkCompromised marks a key pair as compromised. This also marks the key pair as
+// non-default since a compromised key pair cannot be used to sign new licenses.
+func (k *KeyPair) MarkCompromised(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableKeyPairs + ` 
+		SET 
+			Compromised = ?,
+			DatetimeModified = ?,
+			IsDefault = ?
+		WHERE ID = ?
+	`
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(
+		ctx,
+
+		true,
+		timestamps.YMDHMS(),
+		false,
+
+		k.ID,
+	)
+	return
+}
+
 // GetKeyPairByID looks up a key pair by its ID.
 func GetKeyPairByID(ctx context.Context, id int64) (k KeyPair, err error) {
 	q := `
@@ -322,6 +370,24 @@ func (k *KeyPair) SetIsDefault(ctx context.Context) (err error) {
 	return
 }
 
+// GetEncryptedKeyPairs returns the active key pairs, for all apps, whose private key
+// is encrypted.
+func GetEncryptedKeyPairs(ctx context.Context) (kk []KeyPair, err error) {
+	q := `
+		SELECT ` + TableKeyPairs + `.* 
+		FROM ` + TableKeyPairs + `
+		WHERE
+			(` + TableKeyPairs + `.Active = ?)
+			AND
+			(` + TableKeyPairs + `.PrivateKeyEncrypted = ?)
+		ORDER BY ` + TableKeyPairs + `.ID ASC
+	`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &kk, q, true, true)
+	return
+}
+
 // GetDefaultKeyPair retuns the default key pair for an app.
 func GetDefaultKeyPair(ctx context.Context, appID int64) (k KeyPair, err error) {
 	//Base query.
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+//This table keeps track of the machines a license has been activated on. A client
+//activates a license, identified by a client provided machine ID, each time it
+//verifies the license remotely. Each machine is recorded once per license, repeated
+//activations from the same machine just update the existing record, so that the
+//number of records is the number of machines using the license. This is used to
+//detect a license being shared by limiting the number of machines a license can be
+//activated on.
+
+// TableLicenseActivations is the name of the table.
+const TableLicenseActivations = "license_activations"
+
+// LicenseActivationMachineIDMaxLength is the maximum number of characters in the
+// machine ID provided when activating a license. This matches the maximum for a
+// heartbeat since the same machine ID is typically used for both.
+const LicenseActivationMachineIDMaxLength = LicenseHeartbeatMachineIDMaxLength
+
+// LicenseActivation is used to interact with the table.
+type LicenseActivation struct {
+	ID               int64
+	DatetimeCreated  string //when the license was first activated on the machine.
+	DatetimeModified string //when the license was last activated on the machine.
+
+	//a license can only be activated via an api call.
+	CreatedByAPIKeyID int64
+
+	LicenseID       int64  //the license that was activated.
+	MachineID       string //identifier provided by the client that activated the license.
+	ActivationCount int64  //the number of times the license was activated on the machine.
+
+	//JOINed fields
+	CreatedByAPIKeyDescription string
+
+	//Calculated fields
+	DatetimeCreatedInTZ  string //DatetimeCreated converted to timezone per config file.
+	DatetimeModifiedInTZ string // " " " "
+}
+
+const (
+	createTableLicenseActivations = `
+		CREATE TABLE IF NOT EXISTS ` + TableLicenseActivations + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+
+			CreatedByAPIKeyID INTEGER NOT NULL,
+
+			LicenseID INTEGER NOT NULL,
+			MachineID TEXT NOT NULL,
+			ActivationCount INTEGER NOT NULL DEFAULT 1,
+
+			FOREIGN KEY (CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
+			FOREIGN KEY (LicenseID) REFERENCES ` + TableLicenses + `(ID)
+		)
+	`
+
+	createIndexLicenseActivationsLicenseIDMachineID = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableLicenseActivations + `__LicenseID_MachineID_idx ON ` + TableLicenseActivations + ` (LicenseID, MachineID)`
+)
+
+// Insert saves a new activation.
+func (a *LicenseActivation) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
+	cols := sqldb.Columns{
+		"DatetimeCreated",
+		"DatetimeModified",
+		"CreatedByAPIKeyID",
+		"LicenseID",
+		"MachineID",
+		"ActivationCount",
+	}
+	b := sqldb.Bindvars{
+		a.DatetimeCreated,
+		a.DatetimeCreated,
+		a.CreatedByAPIKeyID,
+		a.LicenseID,
+		a.MachineID,
+		1,
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableLicenseActivations + `(` + colString + `) VALUES (` + valString + `)`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	a.ID = id
+	a.DatetimeModified = a.DatetimeCreated
+	a.ActivationCount = 1
+	return
+}
+
+// Reactivate records another activation of a license on a machine the license was
+// already activated on.
+func (a *LicenseActivation) Reactivate(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		UPDATE ` + TableLicenseActivations + `
+		SET
+			DatetimeModified = ?,
+			ActivationCount = ActivationCount + 1
+		WHERE ID = ?
+	`
+	b := sqldb.Bindvars{
+		a.DatetimeModified,
+		a.ID,
+	}
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	a.ActivationCount++
+	return
+}
+
+// GetLicenseActivation looks up the activation of a license on a machine.
+func GetLicenseActivation(ctx context.Context, tx *sqlx.Tx, licenseID int64, machineID string) (a LicenseActivation, err error) {
+	q := `
+		SELECT
+			` + TableLicenseActivations + `.ID,
+			` + TableLicenseActivations + `.DatetimeCreated,
+			` + TableLicenseActivations + `.DatetimeModified,
+			` + TableLicenseActivations + `.CreatedByAPIKeyID,
+			` + TableLicenseActivations + `.LicenseID,
+			` + TableLicenseActivations + `.MachineID,
+			` + TableLicenseActivations + `.ActivationCount
+		FROM ` + TableLicenseActivations + `
+		WHERE
+			(` + TableLicenseActivations + `.LicenseID = ?)
+			AND
+			(` + TableLicenseActivations + `.MachineID = ?)
+	`
+	err = tx.GetContext(ctx, &a, q, licenseID, machineID)
+	return
+}
+
+// CountLicenseActivations returns the number of machines a license has been activated
+// on.
+func CountLicenseActivations(ctx context.Context, tx *sqlx.Tx, licenseID int64) (count int64, err error) {
+	q := `
+		SELECT COUNT(` + TableLicenseActivations + `.ID)
+		FROM ` + TableLicenseActivations + `
+		WHERE ` + TableLicenseActivations + `.LicenseID = ?
+	`
+	err = tx.GetContext(ctx, &count, q, licenseID)
+	return
+}
+
+// GetLicenseActivations returns the machines a license has been activated on, most
+// recently activated first.
+func GetLicenseActivations(ctx context.Context, licenseID int64) (aa []LicenseActivation, err error) {
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	q := `
+		SELECT
+			` + TableLicenseActivations + `.*,
+			IFNULL(` + TableAPIKeys + `.Description, '') AS CreatedByAPIKeyDescription,
+
+			datetime(` + TableLicenseActivations + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ,
+			datetime(` + TableLicenseActivations + `.DatetimeModified, '` + offset + `') AS DatetimeModifiedInTZ
+		FROM ` + TableLicenseActivations + `
+		LEFT JOIN ` + TableAPIKeys + ` ON ` + TableAPIKeys + `.ID = ` + TableLicenseActivations + `.CreatedByAPIKeyID
+		WHERE
+			(` + TableLicenseActivations + `.LicenseID = ?)
+		ORDER BY ` + TableLicenseActivations + `.DatetimeModified DESC
+	`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &aa, q, licenseID)
+	return
+}
+
+// DeleteLicenseActivations deletes every activation of a license. This is used to
+// reset a license's activations, for example when a customer replaces their machines,
+// so that the license can be activated on new machines. The number of deleted
+// activations is returned.
+func DeleteLicenseActivations(ctx context.Context, licenseID int64) (deleted int64, err error) {
+	q := `
+		DELETE FROM ` + TableLicenseActivations + `
+		WHERE LicenseID = ?
+	`
+
+	c := sqldb.Connection()
+	res, err := c.ExecContext(ctx, q, licenseID)
+	if err != nil {
+		return
+	}
+
+	deleted, err = res.RowsAffected()
+	return
+}
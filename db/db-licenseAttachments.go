@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/sqldb/v3"
+)
+
+//This table stores data about files attached to a license, for example a signed
+//contract. The files themselves are saved on disk in the directory set by
+//AttachmentsPath in the config file. Attachments are for internal reference only and
+//are never included in a license file.
+
+// TableLicenseAttachments is the name of the table.
+const TableLicenseAttachments = "license_attachments"
+
+// LicenseAttachment is used to interact with the table.
+type LicenseAttachment struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+	CreatedByUserID  int64
+	Active           bool
+
+	LicenseID      int64
+	Filename       string //name of the file when it was uploaded, used when downloading.
+	StoredFilename string //name of the file on disk, randomly generated, within AttachmentsPath.
+	ContentType    string //detected from the file's contents when uploaded.
+	Size           int64  //bytes.
+
+	//Calculated fields
+	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
+
+	//JOINed fields
+	CreatedByUsername string
+}
+
+const (
+	createTableLicenseAttachments = `
+		CREATE TABLE IF NOT EXISTS ` + TableLicenseAttachments + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+			CreatedByUserID INTEGER NOT NULL,
+			Active INTEGER NOT NULL DEFAULT 1,
+
+			LicenseID INTEGER NOT NULL,
+			Filename TEXT NOT NULL,
+			StoredFilename TEXT NOT NULL,
+			ContentType TEXT NOT NULL,
+			Size INTEGER NOT NULL,
+
+			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
+			FOREIGN KEY (LicenseID) REFERENCES ` + TableLicenses + `(ID)
+		)
+	`
+
+	createIndexLicenseAttachmentsLicenseID = `CREATE INDEX IF NOT EXISTS ` + TableLicenseAttachments + `__LicenseID_idx ON ` + TableLicenseAttachments + ` (LicenseID)`
+)
+
+// Insert saves data about an attachment. The file should have already been saved
+// to disk.
+func (a *LicenseAttachment) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"CreatedByUserID",
+		"Active",
+		"LicenseID",
+		"Filename",
+		"StoredFilename",
+		"ContentType",
+		"Size",
+	}
+	b := sqldb.Bindvars{
+		a.CreatedByUserID,
+		true,
+		a.LicenseID,
+		a.Filename,
+		a.StoredFilename,
+		a.ContentType,
+		a.Size,
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableLicenseAttachments + `(` + colString + `) VALUES (` + valString + `)`
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	a.ID = id
+	a.Active = true
+	return
+}
+
+// GetLicenseAttachments looks up the active attachments for a license.
+func GetLicenseAttachments(ctx context.Context, licenseID int64) (aa []LicenseAttachment, err error) {
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	q := `
+		SELECT
+			` + TableLicenseAttachments + `.*,
+			` + TableUsers + `.Username AS CreatedByUsername,
+
+			datetime(` + TableLicenseAttachments + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ
+		FROM ` + TableLicenseAttachments + `
+		JOIN ` + TableUsers + ` ON ` + TableUsers + `.ID=` + TableLicenseAttachments + `.CreatedByUserID
+		WHERE
+			(` + TableLicenseAttachments + `.LicenseID = ?)
+			AND
+			(` + TableLicenseAttachments + `.Active = ?)
+		ORDER BY ` + TableLicenseAttachments + `.DatetimeCreated DESC
+	`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &aa, q, licenseID, true)
+	return
+}
+
+// GetLicenseAttachmentByID looks up an active attachment by its ID.
+func GetLicenseAttachmentByID(ctx context.Context, id int64) (a LicenseAttachment, err error) {
+	q := `
+		SELECT ` + TableLicenseAttachments + `.*
+		FROM ` + TableLicenseAttachments + `
+		WHERE
+			(ID = ?)
+			AND
+			(Active = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &a, q, id, true)
+	return
+}
+
+// Delete marks an attachment as inactive. The file should be removed from disk
+// separately.
+func (a *LicenseAttachment) Delete(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableLicenseAttachments + `
+		SET
+			Active = ?,
+			DatetimeModified = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, false, timestamps.YMDHMS(), a.ID)
+	return
+}
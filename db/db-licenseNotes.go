@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	"github.com/c9845/licensekeys/v3/config"
@@ -32,6 +33,11 @@ type LicenseNote struct {
 	LicenseID int64
 	Note      string
 
+	//Category is used for reporting on notes. This must be one of the categories set
+	//in the config file. Notes added without a category, including notes added
+	//automatically, are given config.LicenseNoteCategoryUncategorized.
+	Category string
+
 	//Calculated fields
 	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
 
@@ -53,24 +59,35 @@ const (
 
 			LicenseID INTEGER NOT NULL,
 			Note TEXT NOT NULL,
+			Category TEXT NOT NULL DEFAULT '` + config.LicenseNoteCategoryUncategorized + `',
 
 			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
 			FOREIGN KEY (CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
 			FOREIGN KEY (LicenseID) REFERENCES ` + TableLicenses + `(ID)
 		)
 	`
+
+	updateLicenseNotesAddCategory = `ALTER TABLE ` + TableLicenseNotes + ` ADD COLUMN Category TEXT NOT NULL DEFAULT '` + config.LicenseNoteCategoryUncategorized + `'`
 )
 
 // Validate handles sanitizing and validation before a note is saved.
 func (n *LicenseNote) Validate() (errMsg string) {
 	//Sanitize.
 	n.Note = strings.TrimSpace(n.Note)
+	n.Category = strings.ToLower(strings.TrimSpace(n.Category))
+	if n.Category == "" {
+		n.Category = config.LicenseNoteCategoryUncategorized
+	}
 
 	//Validate.
 	if n.Note == "" {
 		errMsg = "You must provide a note."
 		return
 	}
+	if !slices.Contains(config.Data().LicenseNoteCategories, n.Category) {
+		errMsg = "Please choose a category from the provided options."
+		return
+	}
 	if n.LicenseID == 0 {
 		errMsg = "Could not determine what license this note is for."
 		return
@@ -101,14 +118,21 @@ func (n *LicenseNote) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 		txProvided = false
 	}
 
+	//Notes added automatically don't set a category.
+	if n.Category == "" {
+		n.Category = config.LicenseNoteCategoryUncategorized
+	}
+
 	//Build columns.
 	cols := sqldb.Columns{
 		"LicenseID",
 		"Note",
+		"Category",
 	}
 	b := sqldb.Bindvars{
 		n.LicenseID,
 		n.Note,
+		n.Category,
 	}
 
 	if n.CreatedByUserID.Int64 > 0 {
@@ -150,9 +174,10 @@ func (n *LicenseNote) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	return
 }
 
-// GetNotes looks up the notes for a license.
-func GetNotes(ctx context.Context, licenseID int64, orderBy string) (nn []LicenseNote, err error) {
-	offset := config.GetTimezoneOffsetForSQLite()
+// GetNotes looks up the notes for a license. The notes can optionally be filtered by
+// category.
+func GetNotes(ctx context.Context, licenseID int64, category, orderBy string) (nn []LicenseNote, err error) {
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
 	q := `
 		SELECT 
 			` + TableLicenseNotes + `.*,
@@ -168,13 +193,18 @@ func GetNotes(ctx context.Context, licenseID int64, orderBy string) (nn []Licens
 			AND
 			(` + TableLicenseNotes + `.Active = ?)
 	`
-	q += orderBy
-
 	b := sqldb.Bindvars{
 		licenseID,
 		true, //Active
 	}
 
+	if category != "" {
+		q += ` AND (` + TableLicenseNotes + `.Category = ?)`
+		b = append(b, category)
+	}
+
+	q += orderBy
+
 	c := sqldb.Connection()
 	err = c.SelectContext(ctx, &nn, q, b...)
 
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+//This table keeps track of the seats of a license that are currently in use, for
+//floating licenses. A seat is checked out by a client, identified by a client
+//provided ID (i.e.: a machine ID), and is held until the client checks the seat back
+//in or the seat's lease expires. Clients renew their lease by checking out the seat
+//again before the lease expires. Expired leases are deleted periodically.
+
+// TableLicenseSeats is the name of the table.
+const TableLicenseSeats = "license_seats"
+
+// LicenseSeat is used to interact with the table.
+type LicenseSeat struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+
+	//a seat can only be checked out via an api call.
+	CreatedByAPIKeyID int64
+
+	LicenseID    int64  //the license the seat is checked out from.
+	ClientID     string //identifier provided by the client that checked out the seat.
+	LeaseExpires string //YYYY-MM-DD HH:MM:SS, UTC, when the seat is no longer in use unless the lease is renewed.
+}
+
+const (
+	createTableLicenseSeats = `
+		CREATE TABLE IF NOT EXISTS ` + TableLicenseSeats + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+
+			CreatedByAPIKeyID INTEGER NOT NULL,
+
+			LicenseID INTEGER NOT NULL,
+			ClientID TEXT NOT NULL,
+			LeaseExpires TEXT NOT NULL,
+
+			FOREIGN KEY (CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
+			FOREIGN KEY (LicenseID) REFERENCES ` + TableLicenses + `(ID)
+		)
+	`
+
+	createIndexLicenseSeatsLicenseIDClientID = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableLicenseSeats + `__LicenseID_ClientID_idx ON ` + TableLicenseSeats + ` (LicenseID, ClientID)`
+)
+
+// leaseTimeFormat is the format LeaseExpires is stored in. This format sorts, and
+// compares, correctly as a string.
+const leaseTimeFormat = "2006-01-02 15:04:05"
+
+// LeaseExpiresAt returns the LeaseExpires value for a lease that ends after the given
+// duration from now.
+func LeaseExpiresAt(d time.Duration) string {
+	return time.Now().UTC().Add(d).Format(leaseTimeFormat)
+}
+
+// Insert saves a new checked out seat.
+func (s *LicenseSeat) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
+	cols := sqldb.Columns{
+		"DatetimeCreated",
+		"DatetimeModified",
+		"CreatedByAPIKeyID",
+		"LicenseID",
+		"ClientID",
+		"LeaseExpires",
+	}
+	b := sqldb.Bindvars{
+		s.DatetimeCreated,
+		s.DatetimeCreated,
+		s.CreatedByAPIKeyID,
+		s.LicenseID,
+		s.ClientID,
+		s.LeaseExpires,
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableLicenseSeats + `(` + colString + `) VALUES (` + valString + `)`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	s.ID = id
+	return
+}
+
+// RenewLease updates a checked out seat's lease expiration.
+func (s *LicenseSeat) RenewLease(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		UPDATE ` + TableLicenseSeats + `
+		SET
+			DatetimeModified = ?,
+			LeaseExpires = ?
+		WHERE ID = ?
+	`
+	b := sqldb.Bindvars{
+		s.DatetimeModified,
+		s.LeaseExpires,
+		s.ID,
+	}
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, b...)
+	return
+}
+
+// GetLicenseSeat looks up a seat checked out by a client, even if the seat's lease
+// has expired but the seat has not been deleted yet.
+func GetLicenseSeat(ctx context.Context, tx *sqlx.Tx, licenseID int64, clientID string) (s LicenseSeat, err error) {
+	q := `
+		SELECT ` + TableLicenseSeats + `.*
+		FROM ` + TableLicenseSeats + `
+		WHERE
+			(` + TableLicenseSeats + `.LicenseID = ?)
+			AND
+			(` + TableLicenseSeats + `.ClientID = ?)
+	`
+	err = tx.GetContext(ctx, &s, q, licenseID, clientID)
+	return
+}
+
+// CountLicenseSeatsInUse returns the number of seats of a license that have a lease
+// that has not expired.
+func CountLicenseSeatsInUse(ctx context.Context, tx *sqlx.Tx, licenseID int64) (inUse int64, err error) {
+	q := `
+		SELECT COUNT(` + TableLicenseSeats + `.ID)
+		FROM ` + TableLicenseSeats + `
+		WHERE
+			(` + TableLicenseSeats + `.LicenseID = ?)
+			AND
+			(` + TableLicenseSeats + `.LeaseExpires > ?)
+	`
+	err = tx.GetContext(ctx, &inUse, q, licenseID, LeaseExpiresAt(0))
+	return
+}
+
+// DeleteLicenseSeat deletes a seat checked out by a client. This is used when a
+// client checks a seat back in.
+func DeleteLicenseSeat(ctx context.Context, tx *sqlx.Tx, licenseID int64, clientID string) (err error) {
+	q := `
+		DELETE FROM ` + TableLicenseSeats + `
+		WHERE
+			(LicenseID = ?)
+			AND
+			(ClientID = ?)
+	`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, licenseID, clientID)
+	return
+}
+
+// DeleteExpiredLicenseSeats deletes seats whose lease has expired. This reclaims
+// seats from clients that stopped renewing their lease without checking the seat
+// back in, for example if the client crashed. The number of deleted seats is
+// returned.
+func DeleteExpiredLicenseSeats(ctx context.Context) (deleted int64, err error) {
+	q := `
+		DELETE FROM ` + TableLicenseSeats + `
+		WHERE LeaseExpires <= ?
+	`
+
+	c := sqldb.Connection()
+	res, err := c.ExecContext(ctx, q, LeaseExpiresAt(0))
+	if err != nil {
+		return
+	}
+
+	deleted, err = res.RowsAffected()
+	return
+}
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+//This table stores templates used to create licenses for standard products. A
+//template saves the app, key pair, license period, custom field values, and features
+//so that only the customer's details need to be provided when creating a license
+//from the template. Anything provided when creating the license overrides the
+//template, and the license is validated the same as any other license.
+
+// TableLicenseTemplates is the name of the table.
+const TableLicenseTemplates = "license_templates"
+
+// LicenseTemplate is used to interact with the table.
+type LicenseTemplate struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+	CreatedByUserID  int64
+	Active           bool
+
+	Name         string //human readable name shown in the GUI.
+	AppID        int64
+	KeyPairID    int64  //0 to use the app's default key pair when the license is created.
+	DurationDays int    //days until a license expires, 0 to use the app's DaysToExpiration.
+	CustomFields string //JSON encoded MultiCustomFieldResult, only the fields with a value saved.
+	Features     string //feature keys, stored the same as License.Features.
+
+	//JOINed fields
+	AppName string
+}
+
+const (
+	createTableLicenseTemplates = `
+		CREATE TABLE IF NOT EXISTS ` + TableLicenseTemplates + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+			CreatedByUserID INTEGER NOT NULL,
+			Active INTEGER NOT NULL DEFAULT 1,
+
+			Name TEXT NOT NULL,
+			AppID INTEGER NOT NULL,
+			KeyPairID INTEGER NOT NULL DEFAULT 0,
+			DurationDays INTEGER NOT NULL DEFAULT 0,
+			CustomFields TEXT NOT NULL DEFAULT '',
+			Features TEXT NOT NULL DEFAULT '',
+
+			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
+			FOREIGN KEY (AppID) REFERENCES ` + TableApps + `(ID)
+		)
+	`
+
+	createIndexLicenseTemplatesAppID = `CREATE INDEX IF NOT EXISTS ` + TableLicenseTemplates + `__AppID_idx ON ` + TableLicenseTemplates + ` (AppID)`
+)
+
+// licenseTemplateNameMaxLength is the maximum number of characters in a template's
+// name.
+const licenseTemplateNameMaxLength = 100
+
+// Validate handles sanitizing and validation of a template. The custom field values
+// are checked against the fields defined for the app but the values themselves are
+// validated when a license is created from the template since the defined fields may
+// be changed after the template is saved.
+func (t *LicenseTemplate) Validate(ctx context.Context) (errMsg string, err error) {
+	//Sanitize.
+	t.Name = strings.TrimSpace(t.Name)
+
+	//Validate.
+	if t.Name == "" {
+		errMsg = "You must provide a name for this template."
+		return
+	}
+	if len(t.Name) > licenseTemplateNameMaxLength {
+		errMsg = "The name must be at most " + strconv.Itoa(licenseTemplateNameMaxLength) + " characters."
+		return
+	}
+	if t.DurationDays < 0 {
+		errMsg = "The license period cannot be negative. Use 0 to use the app's default license period."
+		return
+	}
+
+	//Make sure the app and key pair can be used to create licenses.
+	if t.AppID < 1 {
+		errMsg = "Could not determine which app this template is for."
+		return
+	}
+	a, err := GetAppByID(ctx, t.AppID)
+	if err != nil {
+		return
+	}
+	if !a.Active {
+		errMsg = "This app is not active. You cannot create a template for it."
+		return
+	}
+
+	if t.KeyPairID > 0 {
+		kp, innerErr := GetKeyPairByID(ctx, t.KeyPairID)
+		if innerErr != nil {
+			err = innerErr
+			return
+		}
+		if kp.AppID != t.AppID {
+			errMsg = "The key pair chosen is not for this app."
+			return
+		}
+		if !kp.Active || kp.Compromised {
+			errMsg = "The key pair chosen cannot be used to create licenses. Please choose a different key pair."
+			return
+		}
+	}
+
+	//Make sure the name isn't already used by another active template.
+	existing, err := GetLicenseTemplateByName(ctx, t.Name)
+	if err == sql.ErrNoRows {
+		err = nil
+	} else if err != nil {
+		return
+	} else if existing.ID != t.ID {
+		errMsg = "Another template already uses this name."
+		return
+	}
+
+	//Keep only the value for each custom field's type and make sure each field is
+	//defined for the app.
+	if strings.TrimSpace(t.CustomFields) == "" {
+		t.CustomFields = ""
+		return
+	}
+
+	var provided MultiCustomFieldResult
+	err = json.Unmarshal([]byte(t.CustomFields), &provided)
+	if err != nil {
+		errMsg = "Could not parse the custom field values for this template."
+		return
+	}
+
+	definedFields, err := GetCustomFieldsDefined(ctx, t.AppID, true)
+	if err != nil {
+		return
+	}
+
+	var results MultiCustomFieldResult
+	for _, p := range provided {
+		var found bool
+		for _, d := range definedFields {
+			if d.ID != p.CustomFieldDefinedID {
+				continue
+			}
+
+			r := CustomFieldResult{
+				CustomFieldDefinedID: d.ID,
+				CustomFieldType:      d.Type,
+				CustomFieldName:      d.Name,
+			}
+			switch d.Type {
+			case CustomFieldTypeInteger:
+				r.IntegerValue = p.IntegerValue
+			case CustomFieldTypeDecimal:
+				r.DecimalValue = p.DecimalValue
+			case CustomFieldTypeText, CustomFieldTypeEmail, CustomFieldTypeURL, CustomFieldTypeFileHash:
+				r.TextValue = null.StringFrom(strings.TrimSpace(p.TextValue.String))
+			case CustomFieldTypeBoolean:
+				r.BoolValue = p.BoolValue
+			case CustomFieldTypeMultiChoice:
+				r.MultiChoiceValue = p.MultiChoiceValue
+			case CustomFieldTypeDate:
+				r.DateValue = p.DateValue
+			}
+
+			results = append(results, r)
+			found = true
+			break
+		}
+
+		if !found {
+			errMsg = "One of the custom fields provided is not defined for this app. Please refresh and try again."
+			return
+		}
+	}
+
+	if len(results) == 0 {
+		t.CustomFields = ""
+		return
+	}
+
+	b, err := json.Marshal(results)
+	if err != nil {
+		return
+	}
+	t.CustomFields = string(b)
+
+	return
+}
+
+// GetLicenseTemplateByName looks up an active template by its name.
+func GetLicenseTemplateByName(ctx context.Context, name string) (t LicenseTemplate, err error) {
+	q := `
+		SELECT ` + TableLicenseTemplates + `.*
+		FROM ` + TableLicenseTemplates + `
+		WHERE
+			(Name = ? COLLATE NOCASE)
+			AND
+			(Active = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &t, q, name, true)
+	return
+}
+
+// Insert saves a template. You should have already called Validate().
+func (t *LicenseTemplate) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"CreatedByUserID",
+		"Active",
+		"Name",
+		"AppID",
+		"KeyPairID",
+		"DurationDays",
+		"CustomFields",
+		"Features",
+	}
+	b := sqldb.Bindvars{
+		t.CreatedByUserID,
+		true,
+		t.Name,
+		t.AppID,
+		t.KeyPairID,
+		t.DurationDays,
+		t.CustomFields,
+		t.Features,
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableLicenseTemplates + `(` + colString + `) VALUES (` + valString + `)`
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	t.ID = id
+	t.Active = true
+	return
+}
+
+// GetLicenseTemplates returns the list of active templates, optionally filtered by
+// app.
+func GetLicenseTemplates(ctx context.Context, appID int64) (tt []LicenseTemplate, err error) {
+	q := `
+		SELECT
+			` + TableLicenseTemplates + `.*,
+			` + TableApps + `.Name AS AppName
+		FROM ` + TableLicenseTemplates + `
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableLicenseTemplates + `.AppID
+		WHERE (` + TableLicenseTemplates + `.Active = ?)
+	`
+	b := sqldb.Bindvars{true}
+
+	if appID > 0 {
+		q += ` AND (` + TableLicenseTemplates + `.AppID = ?)`
+		b = append(b, appID)
+	}
+
+	q += ` ORDER BY ` + TableLicenseTemplates + `.Name COLLATE NOCASE ASC`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &tt, q, b...)
+	return
+}
+
+// GetLicenseTemplateByID looks up a single active template.
+func GetLicenseTemplateByID(ctx context.Context, id int64) (t LicenseTemplate, err error) {
+	q := `
+		SELECT
+			` + TableLicenseTemplates + `.*,
+			` + TableApps + `.Name AS AppName
+		FROM ` + TableLicenseTemplates + `
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableLicenseTemplates + `.AppID
+		WHERE
+			(` + TableLicenseTemplates + `.ID = ?)
+			AND
+			(` + TableLicenseTemplates + `.Active = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &t, q, id, true)
+	return
+}
+
+// Delete marks a template as inactive. Licenses already created from the template are
+// not changed.
+func (t *LicenseTemplate) Delete(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableLicenseTemplates + `
+		SET
+			Active = ?,
+			DatetimeModified = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, false, timestamps.YMDHMS(), t.ID)
+	return
+}
@@ -2,14 +2,18 @@ package db
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/sqldb/v3"
 	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v3"
@@ -36,6 +40,22 @@ type License struct {
 	DatetimeModified string
 	Active           bool
 
+	//PublicID is a random, non-sequential, identifier for a license. This can be
+	//provided to customers so they can check the status of their license without
+	//exposing the sequential license ID.
+	PublicID string
+
+	//FriendlyID is an optional, human friendly, identifier for a license. This is
+	//easier to read over the phone than the PublicID. The format is defined per app
+	//and the ID is generated when a license is saved. This is blank if the app does
+	//not define a format or the license was imported.
+	FriendlyID string
+
+	//AppLicenseNumber is the license's sequential number within its app, starting at
+	//1, if the app has AppLicenseNumbering enabled. This is 0 otherwise. This is only
+	//for human use and is not included in the license file.
+	AppLicenseNumber int64
+
 	//a license can be created by a user or via an api call.
 	CreatedByUserID   null.Int
 	CreatedByAPIKeyID null.Int
@@ -49,6 +69,44 @@ type License struct {
 	IssueDate      string //yyyy-mm-dd, set by server, UTC timezone.
 	IssueTimestamp int64  //unix timestamp in seconds
 	ExpireDate     string //yyyy-mm-dd, set by input type=date in GUI so timezone is dependent on user's location.
+	ExpireDatetime string //optional, RFC3339 in UTC, for licenses that expire at a specific time. ExpireDate is set to the date part of this.
+	ValidFrom      string //optional, yyyy-mm-dd, the date before which the license cannot be used. Must be before ExpireDate.
+
+	//Features is the keys of the app's features enabled for this license, newline
+	//separated and sorted, see AppFeature. The keys are stored, not IDs, since the
+	//keys are what is signed in the license file.
+	Features string
+
+	//Internal reference data, for cross-referencing a license with other systems.
+	//These are never included in the license file and are not signed, so they can be
+	//changed after a license is created.
+	OrderReference string //optional, an internal order or quote number.
+	InternalNotes  string //optional, notes only shown within this app.
+
+	//LastHeartbeat is when a client last reported, via the heartbeat API endpoint,
+	//that it is using the license. This is only recorded for apps that opt-in to
+	//sending heartbeats, and only the latest heartbeat is kept, to detect dormant or
+	//possibly cracked installs. This is blank if a heartbeat was never received. This
+	//has no effect on whether the license can be used.
+	LastHeartbeat          string //yyyy-mm-dd hh:mm:ss, UTC timezone.
+	LastHeartbeatMachineID string //optional, identifier provided by the client that sent the heartbeat.
+
+	//IdempotencyKey is the value of the Idempotency-Key header provided when this
+	//license was created via the public API. This is used to return this license,
+	//instead of creating a duplicate license, when a request is retried with the same
+	//key. Keys are scoped per API key. This is blank for licenses not created via the
+	//public API or when a key was not provided.
+	IdempotencyKey string
+
+	//ScheduledDisableDate is the date, yyyy-mm-dd in the config file's timezone, on
+	//which this license will be disabled automatically. ScheduledDisableNote is the
+	//reason provided when the disable was scheduled and ScheduledDisableByUserID is
+	//who scheduled it, used for the note saved when the license is disabled. These
+	//are blank if a disable is not scheduled. Unlike the expiration date, these are
+	//not part of the license file, so the license is still valid until it is disabled.
+	ScheduledDisableDate     string
+	ScheduledDisableNote     string
+	ScheduledDisableByUserID int64
 
 	//The signature generated using the private key from the keypair. This is
 	//generated once when the license is first created using the the common
@@ -58,12 +116,36 @@ type License struct {
 	//the license ID.
 	Signature string
 
+	//Signatures is every signature, newline separated, when the license was co-signed
+	//by more than one key pair, per the app's RequiredSignatures. The first signature
+	//is the same as Signature. This is blank for licenses signed by one key pair.
+	Signatures string
+
+	//SignatureAlgorithm and FormatVersion are the key pair algorithm and version of
+	//the signing scheme set in the license file when it was signed. These are part
+	//of the signed data so they must be set in the license file when it is rebuilt
+	//for downloading. These are blank for licenses signed before these fields were
+	//added to the license file.
+	SignatureAlgorithm licensefile.KeyPairAlgoType
+	FormatVersion      int
+
+	//SchemaVersion is the app's LicenseSchemaVersion when the license was created.
+	//This is part of the signed data so it must be set in the license file when it
+	//is rebuilt for downloading. 0 means the app's schema was not versioned.
+	SchemaVersion int
+
 	//This is set to true ONLY after a license's data is saved, the signature
 	//is created, and we reread the signed license file and check the signature
 	//with the public key. This is used to ensure that a license can actually
 	//be verified as authentic with the respective public key.
 	Verified bool
 
+	//Imported is true if the license was imported from another system instead of
+	//being created by this app. The signature was provided during the import, not
+	//generated by this app, and Verified is set based on checking the provided
+	//signature with the key pair's public key.
+	Imported bool
+
 	//These fields are copied from the app's details when the license
 	//is created.
 	AppName       string
@@ -75,17 +157,23 @@ type License struct {
 	Expired             bool   //true if Expire date is greater than current date
 	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
 	IssueDateInTZ       string // " " " "
+	LastHeartbeatInTZ   string // " " " "
 	Timezone            string //extra data for above fields for displaying in GUI.
 
 	//JOINed fields
 	KeyPairAlgoType            licensefile.KeyPairAlgoType
+	KeyPairName                string
 	CreatedByUsername          null.String
 	CreatedByAPIKeyDescription null.String
 	AppID                      int64
 	AppFileFormat              licensefile.FileFormat
 	AppDownloadFilename        string
+	AppFileHeaderText          string
+	AppLicenseSchemaVersion    int
 	RenewedFromLicenseID       null.Int
 	RenewedToLicenseID         null.Int
+	TransferredFromLicenseID   null.Int
+	TransferredToLicenseID     null.Int
 }
 
 const (
@@ -95,6 +183,9 @@ const (
 			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
 			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
 			Active INTEGER NOT NULL DEFAULT 1,
+			PublicID TEXT NOT NULL DEFAULT '',
+			FriendlyID TEXT NOT NULL DEFAULT '',
+			AppLicenseNumber INTEGER NOT NULL DEFAULT 0,
 			
 			CreatedByUserID INTEGER DEFAULT NULL,
 			CreatedByAPIKeyID INTEGER DEFAULT NULL,
@@ -107,9 +198,29 @@ const (
 			IssueDate TEXT NOT NULL,
 			IssueTimestamp INT NOT NULL,
 			ExpireDate TEXT NOT NULL,
+			ExpireDatetime TEXT NOT NULL DEFAULT '',
+			ValidFrom TEXT NOT NULL DEFAULT '',
+			Features TEXT NOT NULL DEFAULT '',
+
+			OrderReference TEXT NOT NULL DEFAULT '',
+			InternalNotes TEXT NOT NULL DEFAULT '',
+
+			LastHeartbeat TEXT NOT NULL DEFAULT '',
+			LastHeartbeatMachineID TEXT NOT NULL DEFAULT '',
+
+			IdempotencyKey TEXT NOT NULL DEFAULT '',
+
+			ScheduledDisableDate TEXT NOT NULL DEFAULT '',
+			ScheduledDisableNote TEXT NOT NULL DEFAULT '',
+			ScheduledDisableByUserID INTEGER NOT NULL DEFAULT 0,
 
 			Signature TEXT NOT NULL,
+			Signatures TEXT NOT NULL DEFAULT '',
+			SignatureAlgorithm TEXT NOT NULL DEFAULT '',
+			FormatVersion INTEGER NOT NULL DEFAULT 0,
+			SchemaVersion INTEGER NOT NULL DEFAULT 0,
 			Verified INTEGER NOT NULL DEFAULT 0,
+			Imported INTEGER NOT NULL DEFAULT 0,
 
 			AppName TEXT NOT NULL,
 			FileFormat TEXT NOT NULL,
@@ -121,8 +232,64 @@ const (
 			FOREIGN KEY (KeyPairID) REFERENCES ` + TableKeyPairs + `(ID)
 		)
 	`
+
+	//Searching licenses by company name uses a case-insensitive match. SQLite can only
+	//use this index for exact, or starts-with, matches, not contains matches.
+	createIndexLicensesCompanyName = `CREATE INDEX IF NOT EXISTS ` + TableLicenses + `__CompanyName_idx ON ` + TableLicenses + ` (CompanyName COLLATE NOCASE)`
+
+	//Friendly IDs are unique across all apps, which also makes them unique per app.
+	//Licenses without a friendly ID are excluded.
+	createIndexLicensesFriendlyIDUnique = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableLicenses + `__FriendlyID_idx ON ` + TableLicenses + ` (FriendlyID) WHERE FriendlyID != ''`
+
+	createIndexLicensesPublicID = `CREATE INDEX IF NOT EXISTS ` + TableLicenses + `__PublicID_idx ON ` + TableLicenses + ` (PublicID)`
+
+	createIndexLicensesAppLicenseNumber = `CREATE INDEX IF NOT EXISTS ` + TableLicenses + `__AppLicenseNumber_idx ON ` + TableLicenses + ` (AppLicenseNumber) WHERE AppLicenseNumber > 0`
+
+	//Idempotency keys are unique per API key so that two concurrent requests with the
+	//same key cannot both create a license. Licenses without an idempotency key are
+	//excluded. Keys are cleared from licenses older than the retention window before
+	//a key is reused, see ReleaseIdempotencyKey().
+	createIndexLicensesIdempotencyKeyUnique = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableLicenses + `__IdempotencyKey_unique_idx ON ` + TableLicenses + ` (CreatedByAPIKeyID, IdempotencyKey) WHERE IdempotencyKey != ''`
+
+	//Scheduled disables are looked up by date. Licenses without a scheduled disable
+	//are excluded.
+	createIndexLicensesScheduledDisableDate = `CREATE INDEX IF NOT EXISTS ` + TableLicenses + `__ScheduledDisableDate_idx ON ` + TableLicenses + ` (ScheduledDisableDate) WHERE ScheduledDisableDate != ''`
 )
 
+const (
+	updateLicensesAddExpireDatetime           = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN ExpireDatetime TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddPublicID                 = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN PublicID TEXT NOT NULL DEFAULT ''`
+	updateLicensesSetPublicID                 = `UPDATE ` + TableLicenses + ` SET PublicID = lower(hex(randomblob(16))) WHERE PublicID = ''`
+	updateLicensesAddImported                 = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN Imported INTEGER NOT NULL DEFAULT 0`
+	updateLicensesAddFriendlyID               = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN FriendlyID TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddSignatures               = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN Signatures TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddOrderReference           = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN OrderReference TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddInternalNotes            = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN InternalNotes TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddValidFrom                = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN ValidFrom TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddFeatures                 = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN Features TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddSignatureAlgorithm       = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN SignatureAlgorithm TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddFormatVersion            = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN FormatVersion INTEGER NOT NULL DEFAULT 0`
+	updateLicensesAddAppLicenseNumber         = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN AppLicenseNumber INTEGER NOT NULL DEFAULT 0`
+	updateLicensesAddLastHeartbeat            = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN LastHeartbeat TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddHeartbeatMachineID       = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN LastHeartbeatMachineID TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddIdempotencyKey           = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN IdempotencyKey TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddSchemaVersion            = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN SchemaVersion INTEGER NOT NULL DEFAULT 0`
+	updateLicensesAddScheduledDisableDate     = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN ScheduledDisableDate TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddScheduledDisableNote     = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN ScheduledDisableNote TEXT NOT NULL DEFAULT ''`
+	updateLicensesAddScheduledDisableByUserID = `ALTER TABLE ` + TableLicenses + ` ADD COLUMN ScheduledDisableByUserID INTEGER NOT NULL DEFAULT 0`
+
+	//Idempotency keys used to only be indexed, not unique. Clear the key from all but
+	//the first license created with each key, and remove the old index, so that the
+	//unique index can be created.
+	updateLicensesClearDuplicateIdempotencyKeys = `UPDATE ` + TableLicenses + ` SET IdempotencyKey = '' WHERE IdempotencyKey != '' AND ID NOT IN (SELECT MIN(ID) FROM ` + TableLicenses + ` WHERE IdempotencyKey != '' GROUP BY CreatedByAPIKeyID, IdempotencyKey)`
+	updateLicensesDropIdempotencyKeyIndex       = `DROP INDEX IF EXISTS ` + TableLicenses + `__IdempotencyKey_idx`
+)
+
+// LicenseExpiredColumn is the column used to calculate if a license is expired when
+// looking up licenses. This handles licenses that expire at a specific time as well
+// as licenses that only have an expiration date.
+const LicenseExpiredColumn = "julianday(IFNULL(NULLIF(" + TableLicenses + ".ExpireDatetime, ''), " + TableLicenses + ".ExpireDate)) < julianday('now') AS Expired"
+
 // setLicenseIDStartingValue sets the starting value that the ID will auto increment from
 func setLicenseIDStartingValue(c *sqlx.DB) error {
 	const startingValue = 10000
@@ -167,6 +334,117 @@ func setLicenseIDStartingValue(c *sqlx.DB) error {
 	return nil
 }
 
+// newLicensePublicID generates a random identifier for a license. This is used
+// instead of the license's ID when a license is referenced publicly so that licenses
+// cannot be enumerated easily.
+func newLicensePublicID() (id string, err error) {
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+
+	id = hex.EncodeToString(b)
+	return
+}
+
+// friendlyIDCodeLength is the number of characters in the random code of a friendly
+// ID using the FriendlyIDFormatCode format.
+const friendlyIDCodeLength = 8
+
+// friendlyIDCodeAlphabet is the set of characters used in the random code of a
+// friendly ID. This is the Crockford base32 alphabet which excludes characters that
+// are easily confused when read aloud (I, L, O, U).
+const friendlyIDCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// friendlyIDCodeAttempts is the number of times a random code is generated when
+// looking for a code that isn't already used.
+const friendlyIDCodeAttempts = 5
+
+// newFriendlyIDCode generates a random code for a friendly ID.
+func newFriendlyIDCode() (code string, err error) {
+	b := make([]byte, friendlyIDCodeLength)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+
+	for i := range b {
+		b[i] = friendlyIDCodeAlphabet[int(b[i])%len(friendlyIDCodeAlphabet)]
+	}
+
+	code = string(b)
+	return
+}
+
+// saveFriendlyID generates and saves the friendly ID for a license per the format
+// defined by the license's app. This must be called after the license is saved since
+// the sequential format uses the license's ID.
+func (l *License) saveFriendlyID(ctx context.Context, tx *sqlx.Tx) (err error) {
+	//Look up the app's friendly ID format.
+	q := `
+		SELECT ` + TableApps + `.*
+		FROM ` + TableApps + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.AppID = ` + TableApps + `.ID
+		WHERE ` + TableKeyPairs + `.ID = ?
+	`
+	var a App
+	err = tx.GetContext(ctx, &a, q, l.KeyPairID)
+	if err != nil {
+		return
+	}
+
+	//Generate the friendly ID.
+	switch a.FriendlyIDFormat {
+	case FriendlyIDFormatSequential:
+		l.FriendlyID = a.FriendlyIDPrefix + strconv.FormatInt(l.ID, 10)
+
+	case FriendlyIDFormatCode:
+		//Codes are random so make sure the code isn't already used. The unique index
+		//on FriendlyID guarantees uniqueness, this just retries instead of failing
+		//when a collision occurs.
+		q = `SELECT COUNT(ID) FROM ` + TableLicenses + ` WHERE FriendlyID = ?`
+		for i := 0; i < friendlyIDCodeAttempts; i++ {
+			code, innerErr := newFriendlyIDCode()
+			if innerErr != nil {
+				return innerErr
+			}
+
+			var count int64
+			innerErr = tx.GetContext(ctx, &count, q, a.FriendlyIDPrefix+code)
+			if innerErr != nil {
+				return innerErr
+			}
+			if count == 0 {
+				l.FriendlyID = a.FriendlyIDPrefix + code
+				break
+			}
+		}
+		if l.FriendlyID == "" {
+			return errors.New("could not generate unique friendly ID")
+		}
+
+	default:
+		//App doesn't use friendly IDs.
+		return
+	}
+
+	//Save the friendly ID.
+	q = `
+		UPDATE ` + TableLicenses + `
+		SET FriendlyID = ?
+		WHERE ID = ?
+	`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, l.FriendlyID, l.ID)
+	return
+}
+
 // Validate handle sanitizing and validation of the license data. This only
 // handle the common fields, not custom fields.
 func (l *License) Validate(ctx context.Context) (errMsg string, err error) {
@@ -176,6 +454,13 @@ func (l *License) Validate(ctx context.Context) (errMsg string, err error) {
 	l.PhoneNumber = strings.TrimSpace(l.PhoneNumber)
 	l.Email = strings.TrimSpace(l.Email)
 	l.ExpireDate = strings.TrimSpace(l.ExpireDate)
+	l.ExpireDatetime = strings.TrimSpace(l.ExpireDatetime)
+	l.ValidFrom = strings.TrimSpace(l.ValidFrom)
+
+	errMsg = l.ValidateMetadata()
+	if errMsg != "" {
+		return
+	}
 
 	//Determine the parent app or keypair used to create this license with.
 	//Either the key pair ID or app ID must be provided. If the app ID is provided,
@@ -225,26 +510,133 @@ func (l *License) Validate(ctx context.Context) (errMsg string, err error) {
 		errMsg = "You must provide an email address."
 		return
 	}
+
+	//If a more precise expiration was provided, set the expiration date to match so
+	//that the expiration is validated the same as when only a date is provided. The
+	//expiration datetime is stored in UTC for easiest comparison.
+	var expires time.Time
+	if l.ExpireDatetime != "" {
+		expDatetime, innerErr := time.Parse(time.RFC3339, l.ExpireDatetime)
+		if innerErr != nil {
+			errMsg = "The expiration time must be provided in RFC3339 format (YYYY-MM-DDTHH:MM:SSZ)."
+			return
+		}
+
+		expires = expDatetime.UTC()
+		l.ExpireDatetime = expires.Format(time.RFC3339)
+		l.ExpireDate = expires.Format("2006-01-02")
+	}
+
+	//Use the app's default license period if an expiration was not provided. This
+	//is mostly for licenses created via the API since the GUI already fills in the
+	//default expiration date when an app is chosen.
+	if l.ExpireDate == "" {
+		l.ExpireDate, err = defaultExpireDate(ctx, l.KeyPairID)
+		if err != nil {
+			return
+		}
+	}
 	if l.ExpireDate == "" {
 		errMsg = "You must provide an expiration date for the license."
 		return
 	}
 
-	//Make sure expiration date is in the future.
-	expDate, err := time.Parse("2006-01-02", l.ExpireDate)
+	//Make sure expiration is in the future. The expiration datetime is used, if
+	//provided, since it is more precise than the date.
+	if expires.IsZero() {
+		expires, err = time.Parse("2006-01-02", l.ExpireDate)
+		if err != nil {
+			return
+		}
+	}
+	if !expires.After(time.Now()) {
+		errMsg = "The expiration date must be in the future."
+		return
+	}
+
+	errMsg = ValidateMaxLicenseDuration(ctx, l.ExpireDate)
+	if errMsg != "" {
+		return
+	}
+
+	errMsg = l.validateValidFrom()
+	return
+}
+
+// ValidateMaxLicenseDuration checks that an expiration date, in YYYY-MM-DD format, is
+// not further from today than allowed by the MaxLicenseDurationDays config field. This
+// prevents accidentally issuing a license that is valid for much longer than intended
+// due to a typo in the expiration date. "Today" is calculated in the timezone of the
+// user making the request, the same as the default expiration date.
+//
+// This is used when creating, renewing, and extending a license.
+func ValidateMaxLicenseDuration(ctx context.Context, expireDate string) (errMsg string) {
+	maxDays := config.Data().MaxLicenseDurationDays
+	if maxDays < 1 {
+		return
+	}
+
+	today := time.Now().In(config.GetLocationFromContext(ctx))
+	maxDate := today.AddDate(0, 0, maxDays).Format("2006-01-02")
+	if expireDate > maxDate {
+		return "The expiration date cannot be more than " + strconv.Itoa(maxDays) + " days from today, on or before " + maxDate + "."
+	}
+
+	return
+}
+
+// defaultExpireDate returns the expiration date for a license using the default
+// license period, DaysToExpiration, of the app the key pair belongs to. The date is
+// calculated from "today" in the timezone of the user making the request, matching
+// the date the GUI would fill in. Blank is returned if the app does not have a
+// default license period.
+func defaultExpireDate(ctx context.Context, keyPairID int64) (expireDate string, err error) {
+	kp, err := GetKeyPairByID(ctx, keyPairID)
 	if err != nil {
 		return
 	}
-	if !expDate.After(time.Now()) {
-		errMsg = "The expiration date must be in the future."
+
+	a, err := GetAppByID(ctx, kp.AppID)
+	if err != nil {
+		return
+	}
+	if a.DaysToExpiration < 1 {
 		return
 	}
 
+	today := time.Now().In(config.GetLocationFromContext(ctx))
+	expireDate = today.AddDate(0, 0, a.DaysToExpiration).Format("2006-01-02")
+	return
+}
+
+// validateValidFrom validates the optional date a license is valid from. This must
+// be called after the expiration date has been validated and set.
+func (l *License) validateValidFrom() (errMsg string) {
+	if l.ValidFrom == "" {
+		return
+	}
+
+	validFrom, err := time.Parse("2006-01-02", l.ValidFrom)
+	if err != nil {
+		return "The valid from date must be provided in YYYY-MM-DD format."
+	}
+
+	expDate, err := time.Parse("2006-01-02", l.ExpireDate)
+	if err != nil {
+		return "The expiration date must be provided in YYYY-MM-DD format."
+	}
+	if !validFrom.Before(expDate) {
+		return "The valid from date must be before the expiration date."
+	}
+
 	return
 }
 
 // Insert saves a license. You should have already called Validate().
 //
+// The license's friendly ID is generated per the app's format, except for imported
+// licenses since the friendly ID would not be included in the provided signature.
+//
 // After Insert() is called, you still need to validate the license and update the
 // Signature and Verified fields. These fields are set to blank/false to prevent a
 // license from being used until after it has been verified. Verification performs a
@@ -254,9 +646,18 @@ func (l *License) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 		return errors.New("cannot determine how license is being added")
 	}
 
+	//Generate the public ID for the license.
+	publicID, err := newLicensePublicID()
+	if err != nil {
+		return
+	}
+	l.PublicID = publicID
+	l.FriendlyID = ""
+
 	cols := sqldb.Columns{
 		"DatetimeCreated",
 		"Active",
+		"PublicID",
 
 		"KeyPairID",
 		"CompanyName",
@@ -266,9 +667,19 @@ func (l *License) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 		"IssueDate",
 		"IssueTimestamp",
 		"ExpireDate",
+		"ExpireDatetime",
+		"ValidFrom",
+		"Features",
+		"SchemaVersion",
+
+		"OrderReference",
+		"InternalNotes",
+
+		"IdempotencyKey",
 
 		"Signature", //always "" when license is first saved until data is verified
 		"Verified",  //always false when license is first saved until data is read back from db and checked
+		"Imported",
 
 		"AppName",
 		"FileFormat",
@@ -278,6 +689,7 @@ func (l *License) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	b := sqldb.Bindvars{
 		l.DatetimeCreated,
 		true, //Active
+		l.PublicID,
 
 		l.KeyPairID,
 		l.CompanyName,
@@ -287,9 +699,19 @@ func (l *License) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 		l.IssueDate,
 		l.IssueTimestamp,
 		l.ExpireDate,
+		l.ExpireDatetime,
+		l.ValidFrom,
+		l.Features,
+		l.SchemaVersion,
+
+		l.OrderReference,
+		l.InternalNotes,
+
+		l.IdempotencyKey,
 
 		"",    //Signature
 		false, //Verified
+		l.Imported,
 
 		l.AppName,
 		l.FileFormat,
@@ -323,15 +745,81 @@ func (l *License) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	}
 
 	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
 	l.ID = id
+
+	//Number the license within its app, if the app uses app license numbers.
+	err = l.saveAppLicenseNumber(ctx, tx)
+	if err != nil {
+		return
+	}
+
+	//Generate the friendly ID now that the license ID is known.
+	if !l.Imported {
+		err = l.saveFriendlyID(ctx, tx)
+	}
 	return
 }
 
-// SaveSignature updates a saved license by saving the generated signature.
+// saveAppLicenseNumber increments the counter of the license's app and saves the new
+// value as the license's AppLicenseNumber. This is done in the same transaction the
+// license is saved in so that two licenses cannot be given the same number. Nothing
+// is done if the app doesn't have AppLicenseNumbering enabled.
+func (l *License) saveAppLicenseNumber(ctx context.Context, tx *sqlx.Tx) (err error) {
+	//Increment the app's counter.
+	q := `
+		UPDATE ` + TableApps + `
+		SET LastAppLicenseNumber = LastAppLicenseNumber + 1
+		WHERE 
+			(ID = (SELECT AppID FROM ` + TableKeyPairs + ` WHERE ID = ?))
+			AND
+			(AppLicenseNumbering = ?)
+	`
+	res, err := tx.ExecContext(ctx, q, l.KeyPairID, true)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return
+	}
+
+	//Get the new value of the counter.
+	q = `
+		SELECT ` + TableApps + `.LastAppLicenseNumber
+		FROM ` + TableApps + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.AppID = ` + TableApps + `.ID
+		WHERE ` + TableKeyPairs + `.ID = ?
+	`
+	err = tx.GetContext(ctx, &l.AppLicenseNumber, q, l.KeyPairID)
+	if err != nil {
+		return
+	}
+
+	//Save the number.
+	q = `
+		UPDATE ` + TableLicenses + `
+		SET AppLicenseNumber = ?
+		WHERE ID = ?
+	`
+	_, err = tx.ExecContext(ctx, q, l.AppLicenseNumber, l.ID)
+	return
+}
+
+// SaveSignature updates a saved license by saving the generated signature, and all
+// signatures if the license was co-signed, along with the signature algorithm and
+// format version set in the license file when it was signed.
 func (l *License) SaveSignature(ctx context.Context, tx *sqlx.Tx) (err error) {
 	q := `
 		UPDATE ` + TableLicenses + ` 
-		SET Signature = ?
+		SET 
+			Signature = ?,
+			Signatures = ?,
+			SignatureAlgorithm = ?,
+			FormatVersion = ?
 		WHERE ID = ?
 	`
 
@@ -341,7 +829,148 @@ func (l *License) SaveSignature(ctx context.Context, tx *sqlx.Tx) (err error) {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, l.Signature, l.ID)
+	_, err = stmt.ExecContext(ctx, l.Signature, l.Signatures, l.SignatureAlgorithm, l.FormatVersion, l.ID)
+	return
+}
+
+// Max lengths of a license's internal reference data.
+const (
+	LicenseOrderReferenceMaxLength = 100
+	LicenseInternalNotesMaxLength  = 5000
+)
+
+// ValidateMetadata handles sanitizing and validation of a license's internal
+// reference data. This is called by Validate() and should be called before calling
+// SaveMetadata() since this data can be changed after a license is created.
+func (l *License) ValidateMetadata() (errMsg string) {
+	l.OrderReference = strings.TrimSpace(l.OrderReference)
+	l.InternalNotes = strings.TrimSpace(l.InternalNotes)
+
+	if len(l.OrderReference) > LicenseOrderReferenceMaxLength {
+		return "The order reference must be at most " + strconv.Itoa(LicenseOrderReferenceMaxLength) + " characters."
+	}
+	if len(l.InternalNotes) > LicenseInternalNotesMaxLength {
+		return "The internal notes must be at most " + strconv.Itoa(LicenseInternalNotesMaxLength) + " characters."
+	}
+
+	return
+}
+
+// SaveMetadata updates a saved license's internal reference data. This does not
+// change the license file or signature since this data is never included in the
+// license file.
+func (l *License) SaveMetadata(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + ` 
+		SET 
+			DatetimeModified = ?,
+			OrderReference = ?,
+			InternalNotes = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, timestamps.YMDHMS(), l.OrderReference, l.InternalNotes, l.ID)
+	return
+}
+
+// LicenseIdempotencyKeyMaxLength is the maximum number of characters in the
+// Idempotency-Key header provided when creating a license via the public API.
+const LicenseIdempotencyKeyMaxLength = 255
+
+// GetLicenseByIdempotencyKey looks up the license created via the public API, by the
+// given API key, with the given idempotency key. Only licenses created on or after
+// createdSince, a yyyy-mm-dd hh:mm:ss UTC timestamp, are returned so that keys are
+// only remembered for a limited time. Licenses that failed verification are skipped
+// since they were never returned to the client.
+//
+// sql.ErrNoRows is returned if a matching license does not exist.
+func GetLicenseByIdempotencyKey(ctx context.Context, apiKeyID int64, key, createdSince string) (l License, err error) {
+	q := `
+		SELECT ID
+		FROM ` + TableLicenses + `
+		WHERE 
+			(CreatedByAPIKeyID = ?)
+			AND
+			(IdempotencyKey = ?)
+			AND
+			(DatetimeCreated >= ?)
+			AND
+			(Verified = ?)
+		ORDER BY ID DESC
+		LIMIT 1
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &l, q, apiKeyID, key, createdSince, true)
+	return
+}
+
+// ReleaseIdempotencyKey clears the given idempotency key, for the given API key, from
+// licenses created before createdBefore, a yyyy-mm-dd hh:mm:ss UTC timestamp. This is
+// called before saving a new license with the key since keys must be unique but are
+// only remembered for a limited time.
+func ReleaseIdempotencyKey(ctx context.Context, tx *sqlx.Tx, apiKeyID int64, key, createdBefore string) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + `
+		SET IdempotencyKey = ''
+		WHERE 
+			(CreatedByAPIKeyID = ?)
+			AND
+			(IdempotencyKey = ?)
+			AND
+			(DatetimeCreated < ?)
+	`
+
+	_, err = tx.ExecContext(ctx, q, apiKeyID, key, createdBefore)
+	return
+}
+
+// ClearIdempotencyKey clears the idempotency key from a saved license. This is used
+// when a license failed verification, and was therefore never returned to the
+// client, so that the client can retry with the same key.
+func (l *License) ClearIdempotencyKey(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + `
+		SET IdempotencyKey = ''
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	_, err = c.ExecContext(ctx, q, l.ID)
+	return
+}
+
+// LicenseHeartbeatMachineIDMaxLength is the maximum number of characters in the
+// machine ID provided with a heartbeat.
+const LicenseHeartbeatMachineIDMaxLength = 200
+
+// SaveHeartbeat records when a client last reported using a saved license. This
+// overwrites the previous heartbeat, only the latest heartbeat is kept. This does not
+// update DatetimeModified since the license itself isn't being changed.
+func (l *License) SaveHeartbeat(ctx context.Context) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + ` 
+		SET 
+			LastHeartbeat = ?,
+			LastHeartbeatMachineID = ?
+		WHERE ID = ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, l.LastHeartbeat, l.LastHeartbeatMachineID, l.ID)
 	return
 }
 
@@ -370,9 +999,63 @@ func (l *License) MarkVerified(ctx context.Context) (err error) {
 	return
 }
 
-// GetLicenses looks up a list of licenses optionally filtered by app and active
-// licenses only.
-func GetLicenses(ctx context.Context, appID, limit int64, activeOnly bool, columns sqldb.Columns) (ll []License, err error) {
+// SaveExpiration updates a saved license's expiration within a transaction. This is
+// used when a license's expiration is extended in place, where the new expiration,
+// the new signature, and the result of verifying it must be saved together.
+func (l *License) SaveExpiration(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + ` 
+		SET 
+			DatetimeModified = ?,
+			ExpireDate = ?,
+			ExpireDatetime = ?
+		WHERE ID = ?
+	`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, timestamps.YMDHMS(), l.ExpireDate, l.ExpireDatetime, l.ID)
+	return
+}
+
+// SaveVerified updates a saved license's Verified field within a transaction. This is
+// used when a license is rebuilt and re-signed, where the new signature and the
+// result of verifying it must be saved together.
+func (l *License) SaveVerified(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + ` 
+		SET Verified = ?
+		WHERE ID = ?
+	`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, l.Verified, l.ID)
+	return
+}
+
+// GetLicenses looks up a list of licenses optionally filtered by app, active licenses
+// only, friendly ID, and app license number. The friendly ID is matched exactly, but
+// case-insensitively.
+//
+// If search is provided, only licenses where the company name, contact name, email,
+// phone number, friendly ID, order reference, or internal notes contain the search term, case-insensitively, are
+// returned. Licenses are ordered by relevance, where a field starting with the search
+// term ranks higher than a field only containing the search term, and then by most
+// recent.
+//
+// If expireStart or expireEnd, as yyyy-mm-dd, is provided, only licenses expiring on
+// or after the start date and on or before the end date are returned. Licenses are
+// then ordered by expiration date, soonest first, instead of by relevance.
+func GetLicenses(ctx context.Context, appID, limit int64, activeOnly bool, friendlyID string, appLicenseNumber int64, search, expireStart, expireEnd string, columns sqldb.Columns) (ll []License, err error) {
 	//Build query.
 	cols, err := columns.ForSelect()
 	if err != nil {
@@ -401,13 +1084,80 @@ func GetLicenses(ctx context.Context, appID, limit int64, activeOnly bool, colum
 		wheres = append(wheres, w)
 		b = append(b, activeOnly)
 	}
+	friendlyID = strings.TrimSpace(friendlyID)
+	if friendlyID != "" {
+		w := `(` + TableLicenses + `.FriendlyID = ? COLLATE NOCASE)`
+		wheres = append(wheres, w)
+		b = append(b, friendlyID)
+	}
+	if appLicenseNumber > 0 {
+		w := `(` + TableLicenses + `.AppLicenseNumber = ?)`
+		wheres = append(wheres, w)
+		b = append(b, appLicenseNumber)
+	}
+
+	//Expiration dates are stored as yyyy-mm-dd so a string comparison works. Either
+	//end of the range can be used alone.
+	if expireStart != "" {
+		w := `(` + TableLicenses + `.ExpireDate >= ?)`
+		wheres = append(wheres, w)
+		b = append(b, expireStart)
+	}
+	if expireEnd != "" {
+		w := `(` + TableLicenses + `.ExpireDate <= ?)`
+		wheres = append(wheres, w)
+		b = append(b, expireEnd)
+	}
+
+	//LIKE is case-insensitive for ASCII characters in SQLite. The search term is
+	//escaped so that % and _ are matched literally.
+	//
+	//A FTS5 virtual table is not used since FTS5 requires building the SQLite
+	//library with a build tag that isn't used by default. A contains match requires
+	//scanning the licenses table, which is fast enough for the number of licenses
+	//that are typically issued.
+	search = strings.TrimSpace(search)
+	searchFields := []string{
+		TableLicenses + `.CompanyName`,
+		TableLicenses + `.ContactName`,
+		TableLicenses + `.Email`,
+		TableLicenses + `.PhoneNumber`,
+		TableLicenses + `.FriendlyID`,
+		TableLicenses + `.OrderReference`,
+		TableLicenses + `.InternalNotes`,
+	}
+	escaped := escapeLike(search)
+	if search != "" {
+		ors := []string{}
+		for _, f := range searchFields {
+			ors = append(ors, f+` LIKE ? ESCAPE '\'`)
+			b = append(b, "%"+escaped+"%")
+		}
+
+		w := `(` + strings.Join(ors, " OR ") + `)`
+		wheres = append(wheres, w)
+	}
 
 	if len(wheres) > 0 {
 		where := " WHERE " + strings.Join(wheres, " AND ")
 		q += where
 	}
 
-	q += ` ORDER BY ` + TableLicenses + `.ID DESC`
+	//When filtering by expiration, licenses are returned soonest expiring first
+	//since that is what a user is most likely looking for.
+	if expireStart != "" || expireEnd != "" {
+		q += ` ORDER BY ` + TableLicenses + `.ExpireDate ASC, ` + TableLicenses + `.ID ASC`
+	} else if search != "" {
+		prefixes := []string{}
+		for _, f := range searchFields {
+			prefixes = append(prefixes, f+` LIKE ? ESCAPE '\'`)
+			b = append(b, escaped+"%")
+		}
+
+		q += ` ORDER BY (CASE WHEN ` + strings.Join(prefixes, " OR ") + ` THEN 0 ELSE 1 END) ASC, ` + TableLicenses + `.ID DESC`
+	} else {
+		q += ` ORDER BY ` + TableLicenses + `.ID DESC`
+	}
 	q += ` LIMIT ` + strconv.FormatInt(limit, 10)
 
 	//Run query.
@@ -420,6 +1170,151 @@ func GetLicenses(ctx context.Context, appID, limit int64, activeOnly bool, colum
 	return
 }
 
+// escapeLike escapes the wildcard characters in a value used in a LIKE clause so that
+// the characters are matched literally. The LIKE clause must use ESCAPE '\'.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// GetLicensesByCompanyName looks up the list of licenses issued to a company. The
+// company name is matched exactly, but case-insensitively, since the company name is
+// free-form text provided when each license is created.
+func GetLicensesByCompanyName(ctx context.Context, companyName string, activeOnly bool, columns sqldb.Columns) (ll []License, err error) {
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableLicenses + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID=` + TableLicenses + `.KeyPairID
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID=` + TableKeyPairs + `.AppID
+		WHERE (` + TableLicenses + `.CompanyName = ? COLLATE NOCASE)
+	`
+	b := sqldb.Bindvars{companyName}
+
+	if activeOnly {
+		q += ` AND (` + TableLicenses + `.Active = ?)`
+		b = append(b, activeOnly)
+	}
+
+	q += ` ORDER BY ` + TableLicenses + `.ID ASC`
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ll, q, b...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// GetActiveLicensesByFilter looks up the active licenses issued to a company, for an
+// app, or both. The company name is matched the same as GetLicensesByCompanyName. A
+// blank company name or an app ID of 0 is not filtered on, but at least one filter
+// must be provided so that every license isn't returned by accident.
+func GetActiveLicensesByFilter(ctx context.Context, companyName string, appID int64, columns sqldb.Columns) (ll []License, err error) {
+	if companyName == "" && appID < 1 {
+		err = errors.New("no filter provided")
+		return
+	}
+
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableLicenses + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID=` + TableLicenses + `.KeyPairID
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID=` + TableKeyPairs + `.AppID
+		WHERE (` + TableLicenses + `.Active = ?)
+	`
+	b := sqldb.Bindvars{true}
+
+	if companyName != "" {
+		q += ` AND (` + TableLicenses + `.CompanyName = ? COLLATE NOCASE)`
+		b = append(b, companyName)
+	}
+	if appID > 0 {
+		q += ` AND (` + TableKeyPairs + `.AppID = ?)`
+		b = append(b, appID)
+	}
+
+	q += ` ORDER BY ` + TableLicenses + `.ID ASC`
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ll, q, b...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// StreamLicensesCreatedBetween calls fn with each license created between startDate
+// and endDate, inclusive, optionally filtered by app. Dates are yyyy-mm-dd and are
+// compared in the timezone from the context, the same as the activity log. Licenses
+// are read one at a time, rather than all at once, so that a large range doesn't
+// load every license into memory. If fn returns an error, no more licenses are read
+// and the error is returned.
+func StreamLicensesCreatedBetween(ctx context.Context, appID int64, startDate, endDate string, columns sqldb.Columns, fn func(l License) error) (err error) {
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	q := `
+		SELECT ` + cols + ` 
+		FROM ` + TableLicenses + ` 
+		LEFT JOIN ` + TableUsers + ` ON ` + TableUsers + `.ID = ` + TableLicenses + `.CreatedByUserID 
+		LEFT JOIN ` + TableAPIKeys + ` ON ` + TableAPIKeys + `.ID = ` + TableLicenses + `.CreatedByAPIKeyID 
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID = ` + TableLicenses + `.KeyPairID 
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableKeyPairs + `.AppID 
+		WHERE (DATE(datetime(` + TableLicenses + `.DatetimeCreated, '` + offset + `')) BETWEEN ? AND ?)
+	`
+	b := sqldb.Bindvars{startDate, endDate}
+
+	if appID > 0 {
+		q += ` AND (` + TableKeyPairs + `.AppID = ?)`
+		b = append(b, appID)
+	}
+
+	q += ` ORDER BY ` + TableLicenses + `.ID ASC`
+
+	//Run query.
+	c := sqldb.Connection()
+	rows, err := c.QueryxContext(ctx, q, b...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l License
+		err = rows.StructScan(&l)
+		if err != nil {
+			return
+		}
+
+		err = fn(l)
+		if err != nil {
+			return
+		}
+	}
+
+	return rows.Err()
+}
+
 // GetLicense looks up a single license's data.
 func GetLicense(ctx context.Context, licenseID int64, columns sqldb.Columns) (l License, err error) {
 	//Build query.
@@ -438,6 +1333,8 @@ func GetLicense(ctx context.Context, licenseID int64, columns sqldb.Columns) (l
 		
 		LEFT JOIN ` + TableRenewalRelationships + ` AS rrFrom ON rrFrom.FromLicenseID = ` + TableLicenses + `.ID
 		LEFT JOIN ` + TableRenewalRelationships + ` AS rrTo   ON rrTo.ToLicenseID = ` + TableLicenses + `.ID
+		LEFT JOIN ` + TableTransferRelationships + ` AS trFrom ON trFrom.FromLicenseID = ` + TableLicenses + `.ID
+		LEFT JOIN ` + TableTransferRelationships + ` AS trTo   ON trTo.ToLicenseID = ` + TableLicenses + `.ID
 		
 		WHERE ` + TableLicenses + `.ID = ?
 	`
@@ -452,16 +1349,84 @@ func GetLicense(ctx context.Context, licenseID int64, columns sqldb.Columns) (l
 	return
 }
 
+// GetLicenseByPublicID looks up a single license's data by its public ID.
+func GetLicenseByPublicID(ctx context.Context, publicID string, columns sqldb.Columns) (l License, err error) {
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableLicenses + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID = ` + TableLicenses + `.KeyPairID
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableKeyPairs + `.AppID
+		WHERE ` + TableLicenses + `.PublicID = ?
+	`
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &l, q, publicID)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// GetLicensesByPublicIDs looks up the licenses with the provided public IDs. Public
+// IDs that don't match a license are ignored, so fewer licenses may be returned than
+// public IDs provided.
+func GetLicensesByPublicIDs(ctx context.Context, publicIDs []string, columns sqldb.Columns) (ll []License, err error) {
+	if len(publicIDs) == 0 {
+		return
+	}
+
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableLicenses + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID = ` + TableLicenses + `.KeyPairID
+		JOIN ` + TableApps + ` ON ` + TableApps + `.ID = ` + TableKeyPairs + `.AppID
+		WHERE ` + TableLicenses + `.PublicID IN (?)
+	`
+	q, args, err := sqlx.In(q, publicIDs)
+	if err != nil {
+		return
+	}
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ll, c.Rebind(q), args...)
+	return
+}
+
 // DisableLicense marks a license as inactive. We use a transaction for this
 // since we typically will add a note about why the license as disabled as well.
+//
+// DatetimeModified is set so that we know when the license was disabled, see
+// GetLicenseActivityByApp(). Any scheduled disable is cleared since it is no longer
+// needed.
 func DisableLicense(ctx context.Context, licenseID int64, tx *sqlx.Tx) (err error) {
 	q := `
 		UPDATE ` + TableLicenses + `
-		SET Active = ?
+		SET 
+			Active = ?,
+			DatetimeModified = ?,
+			ScheduledDisableDate = '',
+			ScheduledDisableNote = '',
+			ScheduledDisableByUserID = 0
 		WHERE ID = ?
 	`
 	b := sqldb.Bindvars{
 		false,
+		timestamps.YMDHMS(),
 		licenseID,
 	}
 
@@ -474,3 +1439,184 @@ func DisableLicense(ctx context.Context, licenseID int64, tx *sqlx.Tx) (err erro
 	_, err = stmt.ExecContext(ctx, b...)
 	return
 }
+
+// DisableScheduledLicense disables a license whose scheduled disable date has arrived,
+// within a transaction. The license is only disabled if it is still active and still
+// scheduled to be disabled on scheduledDate, and scheduledDate is on or before today,
+// so that a license whose scheduled disable was canceled or rescheduled after it was
+// looked up is not disabled. disabled is false if the license was not disabled.
+func DisableScheduledLicense(ctx context.Context, licenseID int64, scheduledDate, today string, tx *sqlx.Tx) (disabled bool, err error) {
+	q := `
+		UPDATE ` + TableLicenses + `
+		SET 
+			Active = ?,
+			DatetimeModified = ?,
+			ScheduledDisableDate = '',
+			ScheduledDisableNote = '',
+			ScheduledDisableByUserID = 0
+		WHERE 
+			ID = ?
+			AND Active = ?
+			AND ScheduledDisableDate != ''
+			AND ScheduledDisableDate = ?
+			AND ScheduledDisableDate <= ?
+	`
+	b := sqldb.Bindvars{
+		false,
+		timestamps.YMDHMS(),
+		licenseID,
+		true,
+		scheduledDate,
+		today,
+	}
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	disabled = rows == 1
+	return
+}
+
+// SaveScheduledDisable updates a saved license's scheduled disable date, note, and who
+// scheduled it within a transaction. A blank date, with a blank note and a 0 user ID,
+// cancels a scheduled disable. A transaction is used since scheduling and canceling
+// are also recorded as a license note.
+func (l *License) SaveScheduledDisable(ctx context.Context, tx *sqlx.Tx) (err error) {
+	q := `
+		UPDATE ` + TableLicenses + ` 
+		SET 
+			ScheduledDisableDate = ?,
+			ScheduledDisableNote = ?,
+			ScheduledDisableByUserID = ?
+		WHERE ID = ?
+	`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, l.ScheduledDisableDate, l.ScheduledDisableNote, l.ScheduledDisableByUserID, l.ID)
+	return
+}
+
+// GetLicensesDueForScheduledDisable looks up the active licenses with a scheduled
+// disable date on or before the given date, yyyy-mm-dd.
+func GetLicensesDueForScheduledDisable(ctx context.Context, date string, columns sqldb.Columns) (ll []License, err error) {
+	//Build query.
+	cols, err := columns.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + cols + `
+		FROM ` + TableLicenses + `
+		WHERE 
+			(` + TableLicenses + `.Active = ?)
+			AND 
+			(` + TableLicenses + `.ScheduledDisableDate != '')
+			AND
+			(` + TableLicenses + `.ScheduledDisableDate <= ?)
+		ORDER BY ` + TableLicenses + `.ID ASC
+	`
+	b := sqldb.Bindvars{
+		true,
+		date,
+	}
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ll, q, b...)
+	return
+}
+
+// LicenseActivity is a summary of the licenses for an app, used for reporting.
+type LicenseActivity struct {
+	AppID        int64
+	AppName      string
+	Created      int64 //licenses created since the provided datetime, includes renewals.
+	Renewed      int64 //licenses renewed since the provided datetime.
+	Disabled     int64 //licenses disabled since the provided datetime.
+	ExpiringSoon int64 //active licenses expiring between today and the provided date.
+	Active       int64 //active, non-expired, licenses.
+}
+
+// GetLicenseActivityByApp summarizes the licenses for each active app. since is a
+// YYYY-MM-DD HH:MM:SS datetime, in UTC, that activity is counted from. expiringBy
+// is a YYYY-MM-DD date that licenses expiring on or before are counted as expiring
+// soon.
+func GetLicenseActivityByApp(ctx context.Context, since, expiringBy string) (la []LicenseActivity, err error) {
+	today := timestamps.YMD()
+
+	//Each count uses a subquery, joined to the app via the license's key pair, so
+	//that apps without any activity are still returned.
+	licensesForApp := `
+		FROM ` + TableLicenses + `
+		JOIN ` + TableKeyPairs + ` ON ` + TableKeyPairs + `.ID = ` + TableLicenses + `.KeyPairID
+		WHERE (` + TableKeyPairs + `.AppID = ` + TableApps + `.ID)
+	`
+
+	q := `
+		SELECT
+			` + TableApps + `.ID AS AppID,
+			` + TableApps + `.Name AS AppName,
+			(
+				SELECT COUNT(` + TableLicenses + `.ID) ` + licensesForApp + `
+				AND (` + TableLicenses + `.DatetimeCreated >= ?)
+			) AS Created,
+			(
+				SELECT COUNT(` + TableLicenses + `.ID) ` + licensesForApp + `
+				AND ` + TableLicenses + `.ID IN (
+					SELECT ToLicenseID 
+					FROM ` + TableRenewalRelationships + `
+					WHERE DatetimeCreated >= ?
+				)
+			) AS Renewed,
+			(
+				SELECT COUNT(` + TableLicenses + `.ID) ` + licensesForApp + `
+				AND (` + TableLicenses + `.Active = ?)
+				AND (` + TableLicenses + `.DatetimeModified >= ?)
+			) AS Disabled,
+			(
+				SELECT COUNT(` + TableLicenses + `.ID) ` + licensesForApp + `
+				AND (` + TableLicenses + `.Active = ?)
+				AND (` + TableLicenses + `.ExpireDate >= ?)
+				AND (` + TableLicenses + `.ExpireDate <= ?)
+			) AS ExpiringSoon,
+			(
+				SELECT COUNT(` + TableLicenses + `.ID) ` + licensesForApp + `
+				AND (` + TableLicenses + `.Active = ?)
+				AND (` + TableLicenses + `.ExpireDate >= ?)
+			) AS Active
+		FROM ` + TableApps + `
+		WHERE (` + TableApps + `.Active = ?)
+		ORDER BY ` + TableApps + `.Name ASC
+	`
+	b := sqldb.Bindvars{
+		since,
+		since,
+		false, since,
+		true, today, expiringBy,
+		true, today,
+		true,
+	}
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &la, q, b...)
+	return
+}
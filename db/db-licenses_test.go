@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+)
+
+// newTestDB deploys the schema to a new SQLite database file and returns the
+// connection to it.
+func newTestDB(t *testing.T) *sqldb.Config {
+	c := &sqldb.Config{
+		Type:       sqldb.DBTypeSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "test.db"),
+		SQLitePragmas: []string{
+			"PRAGMA busy_timeout = 5000",
+			"PRAGMA journal_mode = WAL",
+		},
+		MapperFunc:    sqldb.DefaultMapperFunc,
+		LoggingLevel:  sqldb.LogLevelNone,
+		DeployQueries: DeployQueries,
+	}
+
+	err := c.DeploySchema(&sqldb.DeploySchemaOptions{CloseConnection: false})
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+// insertIdempotentLicense saves a license created by an API key with the given
+// idempotency key. The license is saved as imported so that an app and key pair do
+// not need to exist to generate a friendly ID.
+func insertIdempotentLicense(ctx context.Context, c *sqldb.Config, key, datetimeCreated string) error {
+	return WithRetryTx(ctx, c.Connection(), func(tx *sqlx.Tx) error {
+		l := License{
+			KeyPairID:         1,
+			CreatedByAPIKeyID: null.IntFrom(1),
+			IdempotencyKey:    key,
+			DatetimeCreated:   datetimeCreated,
+			Imported:          true,
+		}
+		return l.Insert(ctx, tx)
+	})
+}
+
+func TestLicenseIdempotencyKeyConcurrent(t *testing.T) {
+	c := newTestDB(t)
+	ctx := context.Background()
+
+	//Save licenses with the same idempotency key at the same time, as if a client
+	//sent the same request multiple times concurrently.
+	const requests = 5
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, requests)
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = insertIdempotentLicense(ctx, c, "duplicate", "2026-01-01 00:00:00")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	//Only one license should have been saved, the others should have failed because
+	//the key was already used.
+	saved := 0
+	for _, err := range errs {
+		if err == nil {
+			saved++
+		} else if !IsUniqueViolation(err) {
+			t.Fatal("expected unique violation for duplicate key, got", err)
+			return
+		}
+	}
+	if saved != 1 {
+		t.Fatal("expected exactly 1 license to be saved, got", saved)
+		return
+	}
+
+	var count int
+	err := c.Connection().Get(&count, `SELECT COUNT(ID) FROM `+TableLicenses+` WHERE IdempotencyKey = 'duplicate'`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if count != 1 {
+		t.Fatal("expected 1 license with key, got", count)
+		return
+	}
+}
+
+func TestReleaseIdempotencyKey(t *testing.T) {
+	c := newTestDB(t)
+	ctx := context.Background()
+
+	err := insertIdempotentLicense(ctx, c, "reused", "2026-01-01 00:00:00")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Releasing keys used before the license was created does nothing, so the key
+	//cannot be reused.
+	err = WithRetryTx(ctx, c.Connection(), func(tx *sqlx.Tx) error {
+		return ReleaseIdempotencyKey(ctx, tx, 1, "reused", "2025-12-31 00:00:00")
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = insertIdempotentLicense(ctx, c, "reused", "2026-01-02 00:00:00")
+	if !IsUniqueViolation(err) {
+		t.Fatal("expected unique violation for key still within retention window, got", err)
+		return
+	}
+
+	//Releasing keys used after the license was created allows the key to be reused.
+	err = WithRetryTx(ctx, c.Connection(), func(tx *sqlx.Tx) error {
+		return ReleaseIdempotencyKey(ctx, tx, 1, "reused", "2026-01-02 00:00:00")
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = insertIdempotentLicense(ctx, c, "reused", "2026-01-02 00:00:00")
+	if err != nil {
+		t.Fatal("expected key to be reusable after being released, got", err)
+		return
+	}
+}
+
+func TestDisableScheduledLicense(t *testing.T) {
+	c := newTestDB(t)
+	ctx := context.Background()
+
+	err := insertIdempotentLicense(ctx, c, "scheduled", "2026-01-01 00:00:00")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	const licenseID = 1
+
+	//disable tries to disable the license as scheduled for the given date, as if
+	//the license was looked up when it was scheduled for that date.
+	disable := func(scheduledDate, today string) (disabled bool) {
+		err := WithRetryTx(ctx, c.Connection(), func(tx *sqlx.Tx) (err error) {
+			disabled, err = DisableScheduledLicense(ctx, licenseID, scheduledDate, today, tx)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	schedule := func(date string) {
+		err := WithRetryTx(ctx, c.Connection(), func(tx *sqlx.Tx) error {
+			l := License{ID: licenseID, ScheduledDisableDate: date}
+			return l.SaveScheduledDisable(ctx, tx)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//A license that isn't scheduled to be disabled, i.e. the scheduled disable was
+	//canceled after the license was looked up, is not disabled.
+	if disable("2026-02-01", "2026-02-01") {
+		t.Fatal("expected license without scheduled disable to not be disabled")
+		return
+	}
+
+	//A license that was rescheduled after it was looked up is not disabled.
+	schedule("2026-03-01")
+	if disable("2026-02-01", "2026-02-01") {
+		t.Fatal("expected rescheduled license to not be disabled")
+		return
+	}
+
+	//A license is not disabled before its scheduled date.
+	if disable("2026-03-01", "2026-02-01") {
+		t.Fatal("expected license to not be disabled before scheduled date")
+		return
+	}
+
+	//A license is disabled once its scheduled date arrives, and only once.
+	if !disable("2026-03-01", "2026-03-01") {
+		t.Fatal("expected license to be disabled on scheduled date")
+		return
+	}
+	if disable("2026-03-01", "2026-03-01") {
+		t.Fatal("expected already disabled license to not be disabled again")
+		return
+	}
+
+	var active bool
+	err = c.Connection().Get(&active, `SELECT Active FROM `+TableLicenses+` WHERE ID = ?`, licenseID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if active {
+		t.Fatal("expected license to be inactive")
+		return
+	}
+}
+
+func TestLicenseValidateExpireDatetime(t *testing.T) {
+	err := config.Read(filepath.Join(t.TempDir(), "licensekeys.conf"), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	ctx := context.Background()
+
+	newLicense := func(expireDatetime, validFrom string) License {
+		return License{
+			KeyPairID:      1,
+			CompanyName:    "ACME Dynamite Corp.",
+			ContactName:    "Wyle E. Coyote",
+			PhoneNumber:    "555-555-5555",
+			Email:          "coyote@example.com",
+			ExpireDatetime: expireDatetime,
+			ValidFrom:      validFrom,
+		}
+	}
+
+	//The expiration datetime is saved in UTC and sets the expiration date.
+	expires := time.Now().Add(48 * time.Hour).In(time.FixedZone("UTC-5", -5*60*60)).Truncate(time.Second)
+	l := newLicense(expires.Format(time.RFC3339), "")
+	errMsg, err := l.Validate(ctx)
+	if err != nil || errMsg != "" {
+		t.Fatal("expected valid license", errMsg, err)
+		return
+	}
+	if l.ExpireDatetime != expires.UTC().Format(time.RFC3339) {
+		t.Fatal("expected expiration datetime in UTC, got", l.ExpireDatetime)
+		return
+	}
+	if l.ExpireDate != expires.UTC().Format("2006-01-02") {
+		t.Fatal("expected expiration date to match expiration datetime, got", l.ExpireDate)
+		return
+	}
+
+	//The expiration datetime, not just the date, must be in the future.
+	l = newLicense(time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), "")
+	errMsg, err = l.Validate(ctx)
+	if err != nil || errMsg == "" {
+		t.Fatal("expected past expiration datetime to be invalid", errMsg, err)
+		return
+	}
+
+	//An expiration datetime that cannot be parsed is an input error.
+	l = newLicense("tomorrow", "")
+	errMsg, err = l.Validate(ctx)
+	if err != nil || errMsg == "" {
+		t.Fatal("expected unparsable expiration datetime to be an input error", errMsg, err)
+		return
+	}
+
+	//The valid from date is compared to the expiration date set from the expiration
+	//datetime, the same as when only an expiration date is provided.
+	l = newLicense(expires.Format(time.RFC3339), expires.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+	errMsg, err = l.Validate(ctx)
+	if err != nil || errMsg == "" {
+		t.Fatal("expected valid from after expiration to be invalid", errMsg, err)
+		return
+	}
+}
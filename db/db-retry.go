@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//This file handles retrying queries that fail because the database is busy. SQLite
+//only allows one writer at a time and, under concurrent writes (bulk operations,
+//background jobs), a query can fail with SQLITE_BUSY or SQLITE_LOCKED even though
+//the busy_timeout pragma is set. For example, SQLite returns SQLITE_BUSY immediately,
+//without waiting, when waiting could cause a deadlock between two transactions.
+//
+//Mutating queries can opt in to retrying by wrapping the query in WithRetry(), or by
+//wrapping the entire transaction in WithRetryTx(). Queries run in a transaction must
+//not be retried on their own since a busy error can cause SQLite to roll back the
+//transaction, so rerunning a single query would run it outside of the transaction.
+
+// ErrDatabaseBusy is returned when a query could not be run, after retrying, because
+// the database was busy.
+var ErrDatabaseBusy = errors.New("db: database is busy, please try again")
+
+// Retry settings. The delay before each retry doubles, starting at retryBaseDelay,
+// with random jitter added so that concurrent writers don't retry in lockstep.
+const (
+	retryAttempts  = 5
+	retryBaseDelay = 25 * time.Millisecond
+)
+
+// WithRetry runs fn, retrying if fn returns an error because the database is busy or
+// locked. Any other error is returned immediately. If fn still fails because the
+// database is busy after all attempts, ErrDatabaseBusy is returned wrapping the last
+// error.
+//
+// fn should only run a single query, or queries that are safe to rerun, since fn is
+// called again in its entirety when retrying. fn should not run queries inside a
+// transaction, use WithRetryTx() instead.
+func WithRetry(ctx context.Context, fn func() error) (err error) {
+	for attempt := 0; attempt < retryAttempts; attempt++ {
+		if attempt > 0 {
+			delay := retryBaseDelay << (attempt - 1)
+			delay += rand.N(retryBaseDelay)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		err = fn()
+		if !IsBusy(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %w", ErrDatabaseBusy, err)
+}
+
+// WithRetryTx starts a transaction, runs fn with it, and commits the transaction,
+// retrying the entire transaction if starting it, fn, or committing fails because the
+// database is busy or locked. The transaction is rolled back if fn returns an error.
+//
+// fn is called again in its entirety when retrying so it must not have side effects
+// outside of the transaction that would be a problem if repeated.
+func WithRetryTx(ctx context.Context, c *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	return WithRetry(ctx, func() error {
+		tx, err := c.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
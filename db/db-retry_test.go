@@ -0,0 +1,324 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+// newContentionTestDB creates a SQLite database file and returns two separate
+// connections to it so that one connection can hold a lock while the other tries to
+// write. busy_timeout is disabled so that a locked database returns an error
+// immediately instead of SQLite waiting for the lock.
+func newContentionTestDB(t *testing.T) (holder, writer *sqldb.Config) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	err := os.WriteFile(path, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	connect := func() *sqldb.Config {
+		c := &sqldb.Config{
+			Type:       sqldb.DBTypeSQLite,
+			SQLitePath: path,
+			SQLitePragmas: []string{
+				"PRAGMA busy_timeout = 0",
+				"PRAGMA journal_mode = DELETE",
+			},
+			MapperFunc:   sqldb.DefaultMapperFunc,
+			LoggingLevel: sqldb.LogLevelNone,
+		}
+		err := c.Connect()
+		if err != nil {
+			t.Fatal(err)
+			return nil
+		}
+		c.Connection().SetMaxOpenConns(1)
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	holder = connect()
+	writer = connect()
+
+	_, err = holder.Connection().Exec(`CREATE TABLE t (ID INTEGER PRIMARY KEY, Value TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	return
+}
+
+func TestWithRetryContention(t *testing.T) {
+	holder, writer := newContentionTestDB(t)
+	ctx := context.Background()
+
+	//Hold a write lock on the database.
+	tx, err := holder.Connection().BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO t (Value) VALUES ('holder')`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Without retrying, the write fails immediately.
+	_, err = writer.Connection().Exec(`INSERT INTO t (Value) VALUES ('writer')`)
+	if !IsBusy(err) {
+		t.Fatal("expected busy error while lock is held, got", err)
+		return
+	}
+
+	//Release the lock shortly, while the writer is retrying.
+	go func() {
+		time.Sleep(2 * retryBaseDelay)
+		tx.Commit()
+	}()
+
+	calls := 0
+	err = WithRetry(ctx, func() error {
+		calls++
+		_, innerErr := writer.Connection().ExecContext(ctx, `INSERT INTO t (Value) VALUES ('writer')`)
+		return innerErr
+	})
+	if err != nil {
+		t.Fatal("expected write to succeed after retrying, got", err)
+		return
+	}
+	if calls < 2 {
+		t.Fatal("expected write to be retried, called", calls, "times")
+		return
+	}
+
+	var count int
+	err = writer.Connection().Get(&count, `SELECT COUNT(ID) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if count != 2 {
+		t.Fatal("expected 2 rows, got", count)
+		return
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	holder, writer := newContentionTestDB(t)
+	ctx := context.Background()
+
+	//Hold a write lock on the database for longer than retrying takes.
+	tx, err := holder.Connection().BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO t (Value) VALUES ('holder')`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	calls := 0
+	err = WithRetry(ctx, func() error {
+		calls++
+		_, innerErr := writer.Connection().ExecContext(ctx, `INSERT INTO t (Value) VALUES ('writer')`)
+		return innerErr
+	})
+	if !errors.Is(err, ErrDatabaseBusy) {
+		t.Fatal("expected ErrDatabaseBusy, got", err)
+		return
+	}
+	if calls != retryAttempts {
+		t.Fatal("expected", retryAttempts, "attempts, got", calls)
+		return
+	}
+}
+
+func TestWithRetryOtherError(t *testing.T) {
+	errOther := errors.New("some other error")
+
+	calls := 0
+	err := WithRetry(context.Background(), func() error {
+		calls++
+		return errOther
+	})
+	if err != errOther {
+		t.Fatal("expected error to be returned as is, got", err)
+		return
+	}
+	if calls != 1 {
+		t.Fatal("expected no retries for non-busy error, called", calls, "times")
+		return
+	}
+}
+
+// lockDB starts a transaction on holder that holds a write lock on the database until
+// the returned transaction is committed or rolled back.
+func lockDB(t *testing.T, holder *sqldb.Config) *sqlx.Tx {
+	tx, err := holder.Connection().BeginTxx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	_, err = tx.Exec(`INSERT INTO t (Value) VALUES ('holder')`)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+
+	return tx
+}
+
+func TestWithRetryContextCanceled(t *testing.T) {
+	holder, writer := newContentionTestDB(t)
+	lockDB(t, holder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := WithRetry(ctx, func() error {
+		calls++
+		_, innerErr := writer.Connection().Exec(`INSERT INTO t (Value) VALUES ('writer')`)
+		return innerErr
+	})
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+		return
+	}
+	if calls != 1 {
+		t.Fatal("expected retrying to stop when context is canceled, called", calls, "times")
+		return
+	}
+}
+
+func TestWithRetryTx(t *testing.T) {
+	holder, writer := newContentionTestDB(t)
+	ctx := context.Background()
+
+	//Hold a write lock on the database and release it shortly, while the writer's
+	//transaction is retrying.
+	tx := lockDB(t, holder)
+	go func() {
+		time.Sleep(2 * retryBaseDelay)
+		tx.Commit()
+	}()
+
+	//Run a transaction with multiple statements. The first statement fails while the
+	//lock is held, which causes the entire transaction to be rerun.
+	calls := 0
+	err := WithRetryTx(ctx, writer.Connection(), func(tx *sqlx.Tx) error {
+		calls++
+
+		_, innerErr := tx.ExecContext(ctx, `INSERT INTO t (Value) VALUES ('writer 1')`)
+		if innerErr != nil {
+			return innerErr
+		}
+
+		_, innerErr = tx.ExecContext(ctx, `INSERT INTO t (Value) VALUES ('writer 2')`)
+		return innerErr
+	})
+	if err != nil {
+		t.Fatal("expected transaction to succeed after retrying, got", err)
+		return
+	}
+	if calls < 2 {
+		t.Fatal("expected transaction to be retried, called", calls, "times")
+		return
+	}
+
+	//Each statement in the transaction should have been saved exactly once.
+	var count int
+	err = writer.Connection().Get(&count, `SELECT COUNT(ID) FROM t WHERE Value LIKE 'writer%'`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if count != 2 {
+		t.Fatal("expected 2 rows from transaction, got", count)
+		return
+	}
+}
+
+func TestWithRetryTxRollback(t *testing.T) {
+	_, writer := newContentionTestDB(t)
+	ctx := context.Background()
+
+	errOther := errors.New("some other error")
+
+	calls := 0
+	err := WithRetryTx(ctx, writer.Connection(), func(tx *sqlx.Tx) error {
+		calls++
+
+		_, innerErr := tx.ExecContext(ctx, `INSERT INTO t (Value) VALUES ('writer')`)
+		if innerErr != nil {
+			return innerErr
+		}
+
+		return errOther
+	})
+	if err != errOther {
+		t.Fatal("expected error to be returned as is, got", err)
+		return
+	}
+	if calls != 1 {
+		t.Fatal("expected no retries for non-busy error, called", calls, "times")
+		return
+	}
+
+	//The transaction should have been rolled back.
+	var count int
+	err = writer.Connection().Get(&count, `SELECT COUNT(ID) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if count != 0 {
+		t.Fatal("expected transaction to be rolled back, got", count, "rows")
+		return
+	}
+}
+
+func TestIsBusy(t *testing.T) {
+	holder, writer := newContentionTestDB(t)
+
+	_, constraintErr := writer.Connection().Exec(`INSERT INTO t (ID, Value) VALUES (1, 'a'), (1, 'b')`)
+
+	lockDB(t, holder)
+	_, busyErr := writer.Connection().Exec(`INSERT INTO t (Value) VALUES ('writer')`)
+
+	tt := []struct {
+		name   string
+		err    error
+		isBusy bool
+	}{
+		{"nil", nil, false},
+		{"locked", busyErr, true},
+		{"constraint", constraintErr, false},
+		{"not from sqlite", errors.New("database is locked"), false},
+	}
+
+	for _, tc := range tt {
+		if IsBusy(tc.err) != tc.isBusy {
+			t.Fatal("IsBusy mismatch", tc.name, tc.err, tc.isBusy)
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/sqldb/v3"
+)
+
+//This table stores security related events, for example when a user has failed to
+//log in too many times within a short period of time. Events are shown to
+//administrators so that possible attacks on the app can be investigated.
+
+// TableSecurityEvents is the name of the table.
+const TableSecurityEvents = "security_events"
+
+// Types of security events.
+const (
+	SecurityEventFailedLoginThreshold = "failed-login-threshold"
+	SecurityEventImpersonationStarted = "impersonation-started"
+	SecurityEventImpersonationEnded   = "impersonation-ended"
+)
+
+// SecurityEvent is used to interact with the table.
+type SecurityEvent struct {
+	ID              int64
+	DatetimeCreated string
+	EventType       string
+	UserID          int64  //the user the event is about.
+	RemoteIP        string //the IP address of the request that caused the event.
+	Details         string //a description of the event.
+
+	//JOINed fields
+	Username string
+
+	//Calculated fields
+	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
+}
+
+const (
+	createTableSecurityEvents = `
+		CREATE TABLE IF NOT EXISTS ` + TableSecurityEvents + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			EventType TEXT NOT NULL,
+			UserID INTEGER NOT NULL,
+			RemoteIP TEXT NOT NULL,
+			Details TEXT NOT NULL,
+
+			FOREIGN KEY(UserID) REFERENCES ` + TableUsers + `(ID)
+		)
+	`
+
+	createIndexSecurityEventsDatetimeCreated = `CREATE INDEX IF NOT EXISTS ` + TableSecurityEvents + `__DatetimeCreated_idx ON ` + TableSecurityEvents + ` (DatetimeCreated)`
+)
+
+// Insert saves a security event.
+func (e *SecurityEvent) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"EventType",
+		"UserID",
+		"RemoteIP",
+		"Details",
+	}
+	b := sqldb.Bindvars{
+		e.EventType,
+		e.UserID,
+		e.RemoteIP,
+		e.Details,
+	}
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableSecurityEvents + `(` + colString + `) VALUES (` + valString + `)`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	e.ID = id
+	return
+}
+
+// CountSecurityEventsSince returns the number of events of the given type for a user
+// since the given datetime, in YYYY-MM-DD HH:MM:SS format in UTC.
+func CountSecurityEventsSince(ctx context.Context, eventType string, userID int64, since string) (count int64, err error) {
+	q := `
+		SELECT COUNT(ID)
+		FROM ` + TableSecurityEvents + `
+		WHERE
+			(EventType = ?)
+			AND
+			(UserID = ?)
+			AND
+			(DatetimeCreated >= ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &count, q, eventType, userID, since)
+	return
+}
+
+// GetSecurityEvents looks up the most recent security events.
+func GetSecurityEvents(ctx context.Context, numRows uint16) (ee []SecurityEvent, err error) {
+	const defaultMaxRows uint16 = 200
+
+	//Build columns.
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	cols := sqldb.Columns{
+		TableSecurityEvents + `.ID`,
+		TableSecurityEvents + `.DatetimeCreated`,
+		TableSecurityEvents + `.EventType`,
+		TableSecurityEvents + `.UserID`,
+		TableSecurityEvents + `.RemoteIP`,
+		TableSecurityEvents + `.Details`,
+
+		`IFNULL(` + TableUsers + `.Username, '') AS Username`,
+
+		`datetime(` + TableSecurityEvents + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
+	}
+
+	colString, err := cols.ForSelect()
+	if err != nil {
+		return
+	}
+
+	//Build query.
+	q := `
+		SELECT ` + colString + `
+		FROM ` + TableSecurityEvents + `
+		LEFT JOIN ` + TableUsers + ` ON ` + TableUsers + `.ID=` + TableSecurityEvents + `.UserID
+		ORDER BY ` + TableSecurityEvents + `.DatetimeCreated DESC, ` + TableSecurityEvents + `.ID DESC
+		LIMIT
+	`
+	if numRows > 0 {
+		q += strconv.FormatInt(int64(numRows), 10)
+	} else {
+		q += strconv.FormatInt(int64(defaultMaxRows), 10)
+	}
+
+	//Run query.
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ee, q)
+
+	return
+}
@@ -0,0 +1,20 @@
+//go:build !modernc
+
+package db
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteErrorCode returns the extended SQLite result code from an error returned by
+// the mattn/go-sqlite3 library. False is returned if err did not come from SQLite.
+func sqliteErrorCode(err error) (code int, ok bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, false
+	}
+
+	return int(sqliteErr.ExtendedCode), true
+}
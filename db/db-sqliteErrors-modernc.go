@@ -0,0 +1,20 @@
+//go:build modernc
+
+package db
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+)
+
+// sqliteErrorCode returns the extended SQLite result code from an error returned by
+// the modernc.org/sqlite library. False is returned if err did not come from SQLite.
+func sqliteErrorCode(err error) (code int, ok bool) {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, false
+	}
+
+	return sqliteErr.Code(), true
+}
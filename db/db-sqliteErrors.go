@@ -0,0 +1,41 @@
+package db
+
+//This file handles checking errors returned by SQLite. Errors are checked using the
+//SQLite result code, not the error message, since the message is not part of the
+//SQLite API and differs between the supported SQLite libraries. The library used is
+//chosen with the modernc build tag, see sqldb, so sqliteErrorCode() is implemented
+//once per library.
+//
+//https://www.sqlite.org/rescode.html
+
+// SQLite result codes. The primary result code is stored in the lowest 8 bits of an
+// extended result code.
+const (
+	sqliteBusy             = 5
+	sqliteLocked           = 6
+	sqliteConstraint       = 19
+	sqliteConstraintUnique = sqliteConstraint | (8 << 8)
+)
+
+// IsBusy returns true if err was caused by the database being busy or locked by
+// another connection.
+func IsBusy(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	if !ok {
+		return false
+	}
+
+	primary := code & 0xff
+	return primary == sqliteBusy || primary == sqliteLocked
+}
+
+// IsUniqueViolation returns true if err was caused by a query violating a UNIQUE
+// constraint or index.
+func IsUniqueViolation(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	if !ok {
+		return false
+	}
+
+	return code == sqliteConstraintUnique
+}
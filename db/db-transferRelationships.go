@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+)
+
+//This table keeps a record of the relationship that arises from transferring a
+//license to a different company. This stores the "from" to "to" relationship.
+
+// TableTransferRelationships is the name of the table.
+const TableTransferRelationships = "transfer_relationships"
+
+// TransferRelationship is used to interact with the table.
+type TransferRelationship struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+	Active           bool
+
+	//a license can be transferred by a user or via an api call.
+	CreatedByUserID   null.Int
+	CreatedByAPIKeyID null.Int
+
+	FromLicenseID int64
+	ToLicenseID   int64
+}
+
+const (
+	createTableTransferRelationships = `
+		CREATE TABLE IF NOT EXISTS ` + TableTransferRelationships + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+			Active INTEGER NOT NULL DEFAULT 1,
+
+			CreatedByUserID INTEGER DEFAULT NULL,
+			CreatedByAPIKeyID INTEGER DEFAULT NULL,
+
+			FromLicenseID INTEGER NOT NULL,
+			ToLicenseID INTEGER NOT NULL,
+
+			FOREIGN KEY (CreatedByUserID) REFERENCES ` + TableUsers + `(ID),
+			FOREIGN KEY (CreatedByAPIKeyID) REFERENCES ` + TableAPIKeys + `(ID),
+			FOREIGN KEY (FromLicenseID) REFERENCES ` + TableLicenses + `(ID),
+			FOREIGN KEY (ToLicenseID) REFERENCES ` + TableLicenses + `(ID)
+		)
+	`
+)
+
+// Insert saves a new transfer relationship.
+func (t *TransferRelationship) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
+	cols := sqldb.Columns{
+		"DatetimeCreated",
+		"FromLicenseID",
+		"ToLicenseID",
+	}
+	b := sqldb.Bindvars{
+		t.DatetimeCreated,
+		t.FromLicenseID,
+		t.ToLicenseID,
+	}
+
+	if t.CreatedByUserID.Int64 > 0 {
+		cols = append(cols, "CreatedByUserID")
+		b = append(b, t.CreatedByUserID.Int64)
+	} else {
+		cols = append(cols, "CreatedByAPIKeyID")
+		b = append(b, t.CreatedByAPIKeyID.Int64)
+	}
+
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableTransferRelationships + `(` + colString + `) VALUES ( ` + valString + `)`
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	t.ID = id
+	return
+}
+
+// GetTransferRelationshipByFromID looks up a transfer relationship's data by the
+// from license's ID.
+func GetTransferRelationshipByFromID(ctx context.Context, fromLicenseID int64) (t TransferRelationship, err error) {
+	q := `
+		SELECT 
+			` + TableTransferRelationships + `.*
+		FROM ` + TableTransferRelationships + ` 
+		WHERE (` + TableTransferRelationships + `.FromLicenseID = ?)
+	`
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &t, q, fromLicenseID)
+	return
+}
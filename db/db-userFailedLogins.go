@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/sqldb/v3"
+)
+
+//This table stores each failed login attempt for a user. Unlike the bad password
+//attempts counter stored for each user, which is only used to slow down repeated
+//login attempts, each attempt is stored with a timestamp so that the number of
+//attempts within a window of time can be calculated. This is used to detect an
+//account being brute forced, see users.recordFailedLogin().
+
+// TableUserFailedLogins is the name of the table.
+const TableUserFailedLogins = "user_failed_logins"
+
+// UserFailedLogin is used to interact with the table.
+type UserFailedLogin struct {
+	ID              int64
+	DatetimeCreated string
+	UserID          int64
+	RemoteIP        string
+	UserAgent       string
+	Reason          string //what was invalid, the password, 2FA token, or passkey.
+}
+
+const (
+	createTableUserFailedLogins = `
+		CREATE TABLE IF NOT EXISTS ` + TableUserFailedLogins + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			UserID INTEGER NOT NULL,
+			RemoteIP TEXT NOT NULL,
+			UserAgent TEXT NOT NULL,
+			Reason TEXT NOT NULL,
+
+			FOREIGN KEY(UserID) REFERENCES ` + TableUsers + `(ID)
+		)
+	`
+
+	createIndexUserFailedLoginsUserIDDatetimeCreated = `CREATE INDEX IF NOT EXISTS ` + TableUserFailedLogins + `__UserID_DatetimeCreated_idx ON ` + TableUserFailedLogins + ` (UserID, DatetimeCreated)`
+)
+
+// Insert saves a failed login attempt.
+func (f *UserFailedLogin) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"UserID",
+		"RemoteIP",
+		"UserAgent",
+		"Reason",
+	}
+	b := sqldb.Bindvars{
+		f.UserID,
+		f.RemoteIP,
+		f.UserAgent,
+		f.Reason,
+	}
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableUserFailedLogins + `(` + colString + `) VALUES (` + valString + `)`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	f.ID = id
+	return
+}
+
+// CountUserFailedLoginsSince returns the number of failed login attempts for a user
+// since the given datetime, in YYYY-MM-DD HH:MM:SS format in UTC.
+func CountUserFailedLoginsSince(ctx context.Context, userID int64, since string) (count int64, err error) {
+	q := `
+		SELECT COUNT(ID)
+		FROM ` + TableUserFailedLogins + `
+		WHERE
+			(UserID = ?)
+			AND
+			(DatetimeCreated >= ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &count, q, userID, since)
+	return
+}
+
+// PurgeUserFailedLogins deletes failed login attempts prior to a given date.
+func PurgeUserFailedLogins(ctx context.Context, date string) (rowsDeleted int64, err error) {
+	q := `
+		DELETE FROM ` + TableUserFailedLogins + `
+		WHERE DatetimeCreated < ?
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, date)
+	if err != nil {
+		return
+	}
+
+	rowsDeleted, err = res.RowsAffected()
+	return
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"strconv"
 	"time"
 
@@ -43,11 +44,18 @@ type UserLogin struct {
 	//visits a new page on the app.
 	Expiration int64
 
+	//The user an administrator is viewing the app as, for troubleshooting what a user
+	//can see. The session still belongs to the administrator, UserID, but permissions
+	//are checked using this user. 0 if the administrator is not impersonating a user.
+	ImpersonatingUserID int64
+
 	//JOINed fields
 	Username string
 
 	//Calculated fields
-	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
+	DatetimeCreatedInTZ  string //DatetimeCreated converted to timezone per config file.
+	DatetimeModifiedInTZ string // " " " "
+	Current              bool   //true if this is the session the request was made with.
 }
 
 const (
@@ -63,6 +71,7 @@ const (
 			CookieValue TEXT NOT NULL,
 			Active INTEGER NOT NULL DEFAULT 1,
 			Expiration INTEGER NOT NULL DEFAULT 0,
+			ImpersonatingUserID INTEGER NOT NULL DEFAULT 0,
 
 			FOREIGN KEY(UserID) REFERENCES ` + TableUsers + `(ID)
 		)
@@ -70,6 +79,8 @@ const (
 
 	createIndexUserLoginsValueUnique     = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableUserLogins + `__CookieValue_idx ON ` + TableUserLogins + ` (CookieValue)`
 	createIndexUserLoginsDatetimeCreated = `CREATE INDEX IF NOT EXISTS ` + TableUserLogins + `__DatetimeCreated_idx ON ` + TableUserLogins + ` (DatetimeCreated)`
+
+	updateUserLoginsAddImpersonatingUserID = `ALTER TABLE ` + TableUserLogins + ` ADD COLUMN ImpersonatingUserID INTEGER NOT NULL DEFAULT 0`
 )
 
 // Insert saves an entry to the database for a user logging in to the app.
@@ -169,6 +180,102 @@ func DisableLoginsForUser(ctx context.Context, userID int64) (err error) {
 	return
 }
 
+// GetActiveLoginsForUser looks up the sessions for a user that are active and not
+// expired. The most recently used sessions are listed first. DatetimeModified is
+// updated each time a session's expiration is extended so it is used as when the
+// session was last used.
+func GetActiveLoginsForUser(ctx context.Context, userID int64) (ll []UserLogin, err error) {
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	cols := sqldb.Columns{
+		TableUserLogins + `.ID`,
+		TableUserLogins + `.UserID`,
+		TableUserLogins + `.DatetimeCreated`,
+		TableUserLogins + `.DatetimeModified`,
+		TableUserLogins + `.RemoteIP`,
+		TableUserLogins + `.UserAgent`,
+		TableUserLogins + `.TwoFATokenProvided`,
+		TableUserLogins + `.CookieValue`,
+		TableUserLogins + `.Active`,
+		TableUserLogins + `.Expiration`,
+
+		`datetime(` + TableUserLogins + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
+		`datetime(` + TableUserLogins + `.DatetimeModified, '` + offset + `') AS DatetimeModifiedInTZ`,
+	}
+	colString, err := cols.ForSelect()
+	if err != nil {
+		return
+	}
+
+	q := `
+		SELECT ` + colString + `
+		FROM ` + TableUserLogins + `
+		WHERE
+			(UserID = ?)
+			AND
+			(Active = ?)
+			AND
+			(Expiration > ?)
+		ORDER BY DatetimeModified DESC
+	`
+	b := sqldb.Bindvars{
+		userID,
+		true,
+		time.Now().Unix(),
+	}
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &ll, q, b...)
+	return
+}
+
+// DisableLogin disables one session for a user. UserID is required so that a user
+// can only disable their own sessions. sql.ErrNoRows is returned if the session does
+// not exist, does not belong to the user, or is already inactive.
+func DisableLogin(ctx context.Context, loginID, userID int64) (err error) {
+	c := sqldb.Connection()
+	q := `
+		UPDATE ` + TableUserLogins + `
+		SET 
+			Active = ?,
+			DatetimeModified = ?
+		WHERE
+			(ID = ?)
+			AND
+			(UserID = ?)
+			AND
+			(Active = ?)
+	`
+	b := sqldb.Bindvars{
+		false,
+		timestamps.YMDHMS(),
+
+		loginID,
+		userID,
+		true,
+	}
+
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return
+}
+
 // ExtendLoginExpiration updates the expiration timestamp for a user's login. This is
 // used to reset the time a session will expire to keep users logged in if they are
 // active within the app.
@@ -199,6 +306,51 @@ func (u *UserLogin) ExtendLoginExpiration(ctx context.Context, newExpiration int
 	return
 }
 
+// SetImpersonatingUser sets the user an administrator is viewing the app as for a
+// session. Set userID to 0 to stop impersonating. Only active sessions are updated so
+// that impersonation cannot be started on a session that was logged out.
+func SetImpersonatingUser(ctx context.Context, loginID, userID int64) (err error) {
+	c := sqldb.Connection()
+	q := `
+		UPDATE ` + TableUserLogins + `
+		SET 
+			ImpersonatingUserID = ?,
+			DatetimeModified = ?
+		WHERE
+			(ID = ?)
+			AND
+			(Active = ?)
+	`
+	b := sqldb.Bindvars{
+		userID,
+		timestamps.YMDHMS(),
+
+		loginID,
+		true,
+	}
+
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return
+}
+
 // GetUserLogins looks up successful logins. This defaults to looking up the last 200
 // rows if not limit is provided. You can optionally filter by userID or get logins
 // for all users if userID is 0.
@@ -206,7 +358,7 @@ func GetUserLogins(ctx context.Context, userID int64, numRows uint16) (uu []User
 	const defaultMaxRows uint16 = 200
 
 	//Build columns.
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
 	cols := sqldb.Columns{
 		TableUserLogins + `.ID`,
 		TableUserLogins + `.UserID`,
@@ -282,3 +434,40 @@ func ClearUserLogins(ctx context.Context, date string) (rowsDeleted int64, err e
 
 	return
 }
+
+// PurgeUserLogins deletes inactive or expired rows from the user logins table prior
+// to a given date. Active, non-expired, logins are never deleted, no matter how old,
+// so that users are not logged out.
+func PurgeUserLogins(ctx context.Context, date string) (rowsDeleted int64, err error) {
+	q := `
+		DELETE FROM ` + TableUserLogins + ` 
+		WHERE 
+			(DatetimeCreated < ?)
+			AND
+			(
+				(Active = ?)
+				OR
+				(Expiration <= ?)
+			)
+	`
+	b := sqldb.Bindvars{
+		date,
+		false,
+		time.Now().Unix(),
+	}
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	rowsDeleted, err = res.RowsAffected()
+	return
+}
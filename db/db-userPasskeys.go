@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/sqldb/v3"
+)
+
+//This table stores the WebAuthn credentials (passkeys or hardware security keys) a
+//user has registered for 2 Factor Authentication. A user can register more than one
+//passkey, for example a phone and a hardware key as a backup.
+
+// TableUserPasskeys is the name of the table.
+const TableUserPasskeys = "user_passkeys"
+
+// UserPasskey is used to interact with the table.
+type UserPasskey struct {
+	ID               int64
+	DatetimeCreated  string
+	DatetimeModified string
+	Active           bool
+	UserID           int64
+
+	Name         string //so user can identify which authenticator this is.
+	CredentialID string //base64url encoded, as provided by the browser.
+	PublicKey    string //base64url encoded PKIX, ASN.1 DER, public key.
+	Algorithm    int64  //COSE algorithm identifier.
+	SignCount    int64  //last signature counter provided by authenticator.
+	LastUsed     string //datetime passkey was last used to log in.
+
+	//Calculated fields
+	DatetimeCreatedInTZ string //DatetimeCreated converted to timezone per config file.
+}
+
+const (
+	createTableUserPasskeys = `
+		CREATE TABLE IF NOT EXISTS ` + TableUserPasskeys + `(
+			ID INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			DatetimeCreated TEXT DEFAULT CURRENT_TIMESTAMP,
+			DatetimeModified TEXT DEFAULT CURRENT_TIMESTAMP,
+			Active INTEGER NOT NULL DEFAULT 1,
+			UserID INTEGER NOT NULL,
+
+			Name TEXT NOT NULL,
+			CredentialID TEXT NOT NULL,
+			PublicKey TEXT NOT NULL,
+			Algorithm INTEGER NOT NULL,
+			SignCount INTEGER NOT NULL DEFAULT 0,
+			LastUsed TEXT NOT NULL DEFAULT '',
+
+			FOREIGN KEY(UserID) REFERENCES ` + TableUsers + ` (ID)
+		)
+	`
+
+	createIndexUserPasskeysUserID       = `CREATE INDEX IF NOT EXISTS ` + TableUserPasskeys + `__UserID_idx ON ` + TableUserPasskeys + ` (UserID)`
+	createIndexUserPasskeysCredentialID = `CREATE UNIQUE INDEX IF NOT EXISTS ` + TableUserPasskeys + `__CredentialID_idx ON ` + TableUserPasskeys + ` (CredentialID)`
+)
+
+// Insert saves a passkey for a user.
+func (p *UserPasskey) Insert(ctx context.Context) (err error) {
+	cols := sqldb.Columns{
+		"UserID",
+		"Name",
+		"CredentialID",
+		"PublicKey",
+		"Algorithm",
+		"SignCount",
+	}
+	b := sqldb.Bindvars{
+		p.UserID,
+		p.Name,
+		p.CredentialID,
+		p.PublicKey,
+		p.Algorithm,
+		p.SignCount,
+	}
+	colString, valString, err := cols.ForInsert()
+	if err != nil {
+		return
+	}
+
+	q := `INSERT INTO ` + TableUserPasskeys + `(` + colString + `) VALUES (` + valString + `)`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, b...)
+	if err != nil {
+		return
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	p.ID = id
+	return
+}
+
+// GetUserPasskeys looks up the passkeys registered for a user.
+func GetUserPasskeys(ctx context.Context, userID int64, activeOnly bool) (pp []UserPasskey, err error) {
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(ctx)
+	q := `
+		SELECT
+			` + TableUserPasskeys + `.*,
+			datetime(` + TableUserPasskeys + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ
+		FROM ` + TableUserPasskeys + `
+		WHERE
+			(UserID = ?)
+	`
+	b := sqldb.Bindvars{userID}
+
+	if activeOnly {
+		q += ` AND (Active = ?)`
+		b = append(b, true)
+	}
+
+	q += ` ORDER BY DatetimeCreated ASC`
+
+	c := sqldb.Connection()
+	err = c.SelectContext(ctx, &pp, q, b...)
+	return
+}
+
+// GetUserPasskeyByCredentialID looks up an active passkey for a user by the
+// credential ID provided by the browser.
+func GetUserPasskeyByCredentialID(ctx context.Context, userID int64, credentialID string) (p UserPasskey, err error) {
+	q := `
+		SELECT *
+		FROM ` + TableUserPasskeys + `
+		WHERE
+			(UserID = ?)
+			AND
+			(CredentialID = ?)
+			AND
+			(Active = ?)
+	`
+
+	c := sqldb.Connection()
+	err = c.GetContext(ctx, &p, q, userID, credentialID, true)
+	return
+}
+
+// UpdatePasskeyUsed saves the signature counter and time a passkey was last used to
+// log in.
+func UpdatePasskeyUsed(ctx context.Context, id, signCount int64) (err error) {
+	q := `
+		UPDATE ` + TableUserPasskeys + `
+		SET
+			SignCount = ?,
+			LastUsed = ?
+		WHERE
+			(ID = ?)
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, signCount, timestamps.YMDHMS(), id)
+	return
+}
+
+// DisableUserPasskey marks a passkey as inactive so it can no longer be used to log
+// in. The user ID is required to make sure a passkey is only removed from the user
+// it belongs to.
+func DisableUserPasskey(ctx context.Context, id, userID int64) (err error) {
+	q := `
+		UPDATE ` + TableUserPasskeys + `
+		SET
+			DatetimeModified = ?,
+			Active = ?
+		WHERE
+			(ID = ?)
+			AND
+			(UserID = ?)
+	`
+
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, timestamps.YMDHMS(), false, id, userID)
+	return
+}
@@ -4,15 +4,17 @@ package db
 
 import (
 	"context"
-	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/licensekeys/v3/users/pwds"
 	"github.com/c9845/sqldb/v3"
@@ -22,10 +24,6 @@ import (
 // TableUsers is the name of the table
 const TableUsers = "users"
 
-// InitialUserUsername is the username of the default user that is created when
-// --deploy-db is run for the first time.
-const InitialUserUsername = "admin@example.com"
-
 // InitialUserPassword is populated by insertInitialUser() for the default user when
 // the database is deployed. This value is then logged out when --deploy-db is done
 // running.
@@ -35,6 +33,11 @@ const InitialUserUsername = "admin@example.com"
 // user.
 var InitialUserPassword = ""
 
+// InitialUserProvidedPassword is the password to use for the default user instead of
+// a random password. This is set from the --initial-password flag. Since the password
+// was provided, it is not logged out and InitialUserPassword is not populated.
+var InitialUserProvidedPassword = ""
+
 // User is used to interact with the table
 type User struct {
 	//Basics.
@@ -52,6 +55,7 @@ type User struct {
 	Administrator  bool //can user create other users, apps, signing details, etc.
 	CreateLicenses bool //can user create licenses.
 	ViewLicenses   bool //can user view and download licenses.
+	Auditor        bool //can user view activity log, user logins, and app settings, but not change anything.
 
 	//2 factor auth stuff
 	//Using "Two" not "2" since golang struct fields must start with letter.
@@ -59,6 +63,17 @@ type User struct {
 	TwoFactorAuthSecret      string `json:"-"` //the shared secret used to validate 2fa tokens
 	TwoFactorAuthBadAttempts uint8  `json:"-"` //the number of bad 2fa tokens provides, increases the time taken to verify tokens to reduce impact of brute forcing 2fa tokens
 
+	//The parameters used to generate 2fa tokens, saved when the user enrolls so that
+	//changing the config file doesn't break 2fa for users that already enrolled.
+	TwoFactorAuthDigits        int    `json:"-"` //the number of digits in each token.
+	TwoFactorAuthPeriodSeconds int    `json:"-"` //how long each token is valid for.
+	TwoFactorAuthAlgorithm     string `json:"-"` //SHA1, SHA256, or SHA512.
+
+	TwoFactorAuthLastTimeStep int64 `json:"-"` //the time step of the last accepted 2fa token, tokens for this or an earlier time step are rejected so a token cannot be reused.
+
+	//Display preferences.
+	Timezone string //IANA timezone for displaying dates and times to this user, blank uses the config file's timezone.
+
 	//Have to use different field names since struct tags block using Password field.
 	//Only used when sending data into app; adding user or updating password.
 	PasswordInput1 string
@@ -83,10 +98,17 @@ const (
 			Administrator INTEGER NOT NULL DEFAULT 0,
 			CreateLicenses INTEGER NOT NULL DEFAULT 0,
 			ViewLicenses INTEGER NOT NULL DEFAULT 0,
+			Auditor INTEGER NOT NULL DEFAULT 0,
 			
 			TwoFactorAuthEnabled INTEGER NOT NULL DEFAULT 0,
 			TwoFactorAuthSecret TEXT NOT NULL DEFAULT '',
-			TwoFactorAuthBadAttempts INTEGER NOT NULL DEFAULT 0
+			TwoFactorAuthBadAttempts INTEGER NOT NULL DEFAULT 0,
+			TwoFactorAuthDigits INTEGER NOT NULL DEFAULT 6,
+			TwoFactorAuthPeriodSeconds INTEGER NOT NULL DEFAULT 30,
+			TwoFactorAuthAlgorithm TEXT NOT NULL DEFAULT 'SHA1',
+			TwoFactorAuthLastTimeStep INTEGER NOT NULL DEFAULT 0,
+
+			Timezone TEXT NOT NULL DEFAULT ''
 		)
 	`
 
@@ -94,10 +116,21 @@ const (
 	createIndexUsersActive   = `CREATE INDEX IF NOT EXISTS ` + TableUsers + `__Active_idx ON ` + TableUsers + ` (Active)`
 )
 
+const (
+	updateUsersAddAuditor  = `ALTER TABLE ` + TableUsers + ` ADD COLUMN Auditor INTEGER NOT NULL DEFAULT 0`
+	updateUsersAddTimezone = `ALTER TABLE ` + TableUsers + ` ADD COLUMN Timezone TEXT NOT NULL DEFAULT ''`
+
+	updateUsersAddTwoFactorAuthDigits        = `ALTER TABLE ` + TableUsers + ` ADD COLUMN TwoFactorAuthDigits INTEGER NOT NULL DEFAULT 6`
+	updateUsersAddTwoFactorAuthPeriodSeconds = `ALTER TABLE ` + TableUsers + ` ADD COLUMN TwoFactorAuthPeriodSeconds INTEGER NOT NULL DEFAULT 30`
+	updateUsersAddTwoFactorAuthAlgorithm     = `ALTER TABLE ` + TableUsers + ` ADD COLUMN TwoFactorAuthAlgorithm TEXT NOT NULL DEFAULT 'SHA1'`
+	updateUsersAddTwoFactorAuthLastTimeStep  = `ALTER TABLE ` + TableUsers + ` ADD COLUMN TwoFactorAuthLastTimeStep INTEGER NOT NULL DEFAULT 0`
+)
+
 func insertInitialUser(c *sqlx.DB) (err error) {
 	//Check if the default initial user already exists.
 	ctx := context.Background()
-	_, err = GetUserByUsername(ctx, InitialUserUsername, sqldb.Columns{"ID"})
+	username := config.Data().InitialUserUsername
+	_, err = GetUserByUsername(ctx, username, sqldb.Columns{"ID"})
 	if err == nil {
 		log.Println("insertInitialUser...skipping, default initial user already exists")
 		return
@@ -108,7 +141,7 @@ func insertInitialUser(c *sqlx.DB) (err error) {
 	//Default user doesn't exist. Check if other users exist. This handles if the
 	//default initial user's username was changed so we don't recreate the default
 	//user for no reason.
-	uu, err := GetUsers(ctx, true)
+	uu, _, err := GetUsers(ctx, true, "", "", 0, 0)
 	if err != nil {
 		return
 	} else if len(uu) > 0 {
@@ -117,20 +150,27 @@ func insertInitialUser(c *sqlx.DB) (err error) {
 	}
 
 	//No users exist in the database, as expected for an initial deploy of the app.
-	//Create the default initial user.
-	b := make([]byte, 21)
-	_, err = rand.Read(b)
-	if err == nil {
-		InitialUserPassword = base64.StdEncoding.EncodeToString(b)
+	//Create the default initial user, using the provided password if one was given.
+	password := InitialUserProvidedPassword
+	if password != "" {
+		minLength := config.Data().MinPasswordLength
+		if len(password) < minLength {
+			return fmt.Errorf("insertInitialUser: provided password must be at least %d characters", minLength)
+		}
 
 	} else {
-		log.Println("insertInitialUser...failed creating random password for default initial user, falling back to less-random password", err)
+		InitialUserPassword, err = pwds.Random()
+		if err != nil {
+			log.Println("insertInitialUser...failed creating random password for default initial user, falling back to less-random password", err)
 
-		now := time.Now().UnixNano()
-		InitialUserPassword = strconv.FormatInt(now, 10)
+			now := time.Now().UnixNano()
+			InitialUserPassword = strconv.FormatInt(now, 10)
+		}
+
+		password = InitialUserPassword
 	}
 
-	hashedPwd, err := pwds.Create(InitialUserPassword)
+	hashedPwd, err := pwds.Create(password)
 	if err != nil {
 		return
 	}
@@ -140,31 +180,122 @@ func insertInitialUser(c *sqlx.DB) (err error) {
 		Administrator:   true,
 		CreateLicenses:  true,
 		ViewLicenses:    true,
+		Auditor:         true,
 		CreatedByUserID: 0, //since this user is the initial user, no one created it
-		Username:        InitialUserUsername,
+		Username:        username,
 		Password:        hashedPwd,
 	}
 
-	err = u.Insert(ctx)
+	//Make sure the username, which may have been set in the config file, is a valid
+	//email address.
+	errMsg, err := u.Validate(ctx)
+	if err != nil {
+		return
+	} else if errMsg != "" {
+		return errors.New("insertInitialUser: " + errMsg)
+	}
+
+	tx, err := c.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	err = u.Insert(ctx, tx)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
 	return
 }
 
-// GetUsers looks up a list of users.
-func GetUsers(ctx context.Context, activeOnly bool) (uu []User, err error) {
-	q := `
-		SELECT *
-		FROM ` + TableUsers + ` 
-	`
+// User roles used to filter the list of users. These match the access control
+// permission fields of User.
+const (
+	UserRoleAdministrator  = "admin"
+	UserRoleCreateLicenses = "create"
+	UserRoleViewLicenses   = "view"
+	UserRoleAuditor        = "auditor"
+)
+
+// userRoleColumns maps each role to the column that stores the permission.
+var userRoleColumns = map[string]string{
+	UserRoleAdministrator:  "Administrator",
+	UserRoleCreateLicenses: "CreateLicenses",
+	UserRoleViewLicenses:   "ViewLicenses",
+	UserRoleAuditor:        "Auditor",
+}
+
+// ValidUserRole checks if a role used to filter the list of users is valid. A blank
+// role is valid and means the list isn't filtered by role.
+func ValidUserRole(role string) bool {
+	if role == "" {
+		return true
+	}
+
+	_, ok := userRoleColumns[role]
+	return ok
+}
 
+// GetUsers looks up a list of users. The list can be filtered by a search term,
+// matched against the username (an email address), and by a role. A limit of 0
+// returns all matching users, otherwise limit and offset are used for pagination.
+//
+// The total number of users matching the filters, ignoring limit and offset, is
+// returned for building pagination.
+func GetUsers(ctx context.Context, activeOnly bool, search, role string, limit, offset int64) (uu []User, total int64, err error) {
+	wheres := []string{}
 	b := sqldb.Bindvars{}
 	if activeOnly {
-		q += `WHERE (Active = ?)`
+		w := `(Active = ?)`
+		wheres = append(wheres, w)
 		b = append(b, true)
 	}
 
-	q += ` ORDER BY Active DESC, Username ASC`
+	search = strings.TrimSpace(search)
+	if search != "" {
+		w := `(Username LIKE ? ESCAPE '\')`
+		wheres = append(wheres, w)
+		b = append(b, "%"+escapeLike(search)+"%")
+	}
+
+	if role != "" {
+		col, ok := userRoleColumns[role]
+		if !ok {
+			err = errors.New("invalid role")
+			return
+		}
+
+		w := `(` + col + ` = ?)`
+		wheres = append(wheres, w)
+		b = append(b, true)
+	}
 
+	where := ""
+	if len(wheres) > 0 {
+		where = " WHERE " + strings.Join(wheres, " AND ")
+	}
+
+	//Get the total count for pagination.
 	c := sqldb.Connection()
+	q := `SELECT COUNT(ID) FROM ` + TableUsers + where
+	err = c.GetContext(ctx, &total, q, b...)
+	if err != nil {
+		return
+	}
+
+	//Get the users.
+	q = `
+		SELECT *
+		FROM ` + TableUsers + `
+	` + where + ` ORDER BY Active DESC, Username ASC`
+
+	if limit > 0 {
+		q += ` LIMIT ? OFFSET ?`
+		b = append(b, limit, offset)
+	}
+
 	err = c.SelectContext(ctx, &uu, q, b...)
 	return
 }
@@ -242,6 +373,17 @@ func (u *User) Validate(ctx context.Context) (errMsg string, err error) {
 		return
 	}
 
+	//Make sure the username is at an allowed domain. This is only checked for new
+	//usernames, either a new user or an existing user's changed username, so that
+	//existing users can still be updated if the allowed domains change. A username
+	//that isn't new was found above, and is the user being updated.
+	if err == sql.ErrNoRows {
+		errMsg = validateUserEmailDomain(u.Username)
+		if errMsg != "" {
+			return errMsg, nil
+		}
+	}
+
 	//Make sure any related permissions are set properly. Ex.: If a user has a "Write"
 	//permission, make sure the related "Read" permission is also assigned.
 	//
@@ -254,6 +396,7 @@ func (u *User) Validate(ctx context.Context) (errMsg string, err error) {
 	if u.Administrator {
 		u.CreateLicenses = true
 		u.ViewLicenses = true
+		u.Auditor = true
 	}
 	if u.CreateLicenses {
 		u.ViewLicenses = true
@@ -262,8 +405,25 @@ func (u *User) Validate(ctx context.Context) (errMsg string, err error) {
 	return "", nil
 }
 
+// validateUserEmailDomain checks if a username, an email address, is at one of the
+// AllowedUserEmailDomains in the config file. Domains are matched exactly, so
+// subdomains must be listed separately. Any domain is allowed if no domains are set.
+func validateUserEmailDomain(username string) (errMsg string) {
+	allowed := config.Data().AllowedUserEmailDomains
+	if len(allowed) == 0 {
+		return ""
+	}
+
+	_, domain, _ := strings.Cut(username, "@")
+	if slices.Contains(allowed, domain) {
+		return ""
+	}
+
+	return "The username must be an email address at one of the allowed domains: " + strings.Join(allowed, ", ") + "."
+}
+
 // Insert saves a user to the database.
-func (u *User) Insert(ctx context.Context) (err error) {
+func (u *User) Insert(ctx context.Context, tx *sqlx.Tx) (err error) {
 	cols := sqldb.Columns{
 		"Username",
 		"Password",
@@ -272,6 +432,7 @@ func (u *User) Insert(ctx context.Context) (err error) {
 		"Administrator",
 		"CreateLicenses",
 		"ViewLicenses",
+		"Auditor",
 	}
 	b := sqldb.Bindvars{
 		u.Username,
@@ -281,6 +442,7 @@ func (u *User) Insert(ctx context.Context) (err error) {
 		u.Administrator,
 		u.CreateLicenses,
 		u.ViewLicenses,
+		u.Auditor,
 	}
 	colString, valString, err := cols.ForInsert()
 	if err != nil {
@@ -288,8 +450,7 @@ func (u *User) Insert(ctx context.Context) (err error) {
 	}
 
 	q := `INSERT INTO ` + TableUsers + `(` + colString + `) VALUES (` + valString + `)`
-	c := sqldb.Connection()
-	stmt, err := c.PrepareContext(ctx, q)
+	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
 		return
 	}
@@ -313,6 +474,7 @@ func (u *User) Update(ctx context.Context) (err error) {
 		"Administrator",
 		"CreateLicenses",
 		"ViewLicenses",
+		"Auditor",
 	}
 	colString, err := cols.ForUpdate()
 	if err != nil {
@@ -335,6 +497,7 @@ func (u *User) Update(ctx context.Context) (err error) {
 		u.Administrator,
 		u.CreateLicenses,
 		u.ViewLicenses,
+		u.Auditor,
 
 		u.ID,
 	)
@@ -368,13 +531,45 @@ func SetNewPassword(ctx context.Context, userID int64, passwordHash string) (err
 	return
 }
 
-// Save2FASecret saves the secret shared secret for 2fa to the database for a user.
-// This does not enable 2fa since the user still needs to verify a 2fa token the first
-// time a secret/qr code is shown to them.
-func Save2FASecret(ctx context.Context, userID int64, secret string) (err error) {
+// SetTimezone sets the timezone dates and times are displayed in for a given user ID.
+// A blank timezone uses the timezone from the config file.
+func SetTimezone(ctx context.Context, userID int64, timezone string) (err error) {
+	q := `
+		UPDATE ` + TableUsers + `
+		SET 
+			Timezone = ?
+		WHERE 
+			ID = ?
+	`
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(
+		ctx,
+
+		timezone,
+
+		userID,
+	)
+	return
+}
+
+// Save2FASecret saves the secret shared secret for 2fa to the database for a user,
+// along with the parameters used to generate tokens from the secret. This does not
+// enable 2fa since the user still needs to verify a 2fa token the first time a
+// secret/qr code is shown to them.
+func Save2FASecret(ctx context.Context, userID int64, secret string, digits, periodSeconds int, algorithm string) (err error) {
 	q := `
 		UPDATE ` + TableUsers + `
-		SET TwoFactorAuthSecret = ?
+		SET 
+			TwoFactorAuthSecret = ?,
+			TwoFactorAuthDigits = ?,
+			TwoFactorAuthPeriodSeconds = ?,
+			TwoFactorAuthAlgorithm = ?
 		WHERE ID = ?
 	`
 	c := sqldb.Connection()
@@ -388,6 +583,9 @@ func Save2FASecret(ctx context.Context, userID int64, secret string) (err error)
 		ctx,
 
 		secret,
+		digits,
+		periodSeconds,
+		algorithm,
 
 		userID,
 	)
@@ -466,6 +664,46 @@ func Set2FABadAttempts(ctx context.Context, userID int64, badValue uint8) error
 	return err
 }
 
+// Use2FATimeStep saves the time step of an accepted 2fa token for a user. This only
+// saves the time step if it is after the time step of the last accepted token, and
+// used is false otherwise, so that a token cannot be used more than once, even by
+// concurrent requests.
+func Use2FATimeStep(ctx context.Context, userID, timeStep int64) (used bool, err error) {
+	q := `
+		UPDATE ` + TableUsers + `
+		SET TwoFactorAuthLastTimeStep = ?
+		WHERE 
+			ID = ?
+			AND TwoFactorAuthLastTimeStep < ?
+	`
+	c := sqldb.Connection()
+	stmt, err := c.PrepareContext(ctx, q)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(
+		ctx,
+
+		timeStep,
+
+		userID,
+		timeStep,
+	)
+	if err != nil {
+		return
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	used = rows == 1
+	return
+}
+
 // SetPasswordBadAttempts sets the value for the bad password attempts for a user.
 // This is used to either (a) reset the value upon a good password or (b) increment
 // the value (up to the max) for bad passwords. The new badValue should have already
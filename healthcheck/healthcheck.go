@@ -0,0 +1,175 @@
+/*
+Package healthcheck handles reporting the health of this app for infrastructure
+monitoring and orchestration tools.
+
+Liveness and readiness are reported separately. Liveness reports that the app is
+running and able to respond to requests, a failed liveness check means the app should
+be restarted. Readiness reports that the app's dependencies are working and the app
+can handle requests, a failed readiness check means requests should not be sent to
+the app until the dependency is fixed.
+
+The lightweight /healthcheck/ endpoint, used by load balancers, is handled separately
+and does not check any dependencies.
+*/
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/keypairs"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// checkTimeout is the longest a single readiness check can take before it is
+// considered failed. This prevents a hung dependency from hanging the healthcheck.
+const checkTimeout = 5 * time.Second
+
+// Statuses returned for the app overall and for each check.
+const (
+	StatusOK       = "ok"       //app, or check, is healthy.
+	StatusDegraded = "degraded" //a non-critical check failed, the app can still handle most requests.
+	StatusDown     = "down"     //a critical check failed, the app cannot handle requests.
+)
+
+// msgTypeHealthcheck is the Type of the response.
+const msgTypeHealthcheck = "healthcheck"
+
+// check is the result of checking one dependency.
+type check struct {
+	Name       string
+	Status     string
+	Critical   bool   //if this check fails, the app is not ready.
+	Message    string //why the check failed, blank if OK.
+	DurationMS int64  //how long the check took.
+}
+
+// result is the data returned from a liveness or readiness check.
+type result struct {
+	Status          string
+	MaintenanceMode bool
+	Checks          []check `json:",omitempty"`
+}
+
+// Live reports that the app is running and able to respond to requests. No
+// dependencies are checked since a failed dependency will not be fixed by restarting
+// the app.
+func Live(w http.ResponseWriter, r *http.Request) {
+	send(w, result{
+		Status:          StatusOK,
+		MaintenanceMode: config.MaintenanceMode(),
+	})
+}
+
+// Ready reports if the app's dependencies are working. A 503 status is returned if a
+// critical check failed. A 200 status is returned if only non-critical checks failed,
+// with the Status set to degraded, since the app can still handle most requests.
+func Ready(w http.ResponseWriter, r *http.Request) {
+	res := result{
+		Status:          StatusOK,
+		MaintenanceMode: config.MaintenanceMode(),
+		Checks: []check{
+			runCheck(r.Context(), "config", true, checkConfig),
+			runCheck(r.Context(), "database", true, checkDatabase),
+
+			//Private keys that cannot be decrypted prevent licenses from being created
+			//but downloading and verifying licenses still works, therefore this isn't
+			//critical.
+			runCheck(r.Context(), "keyPairDecryption", false, checkKeyPairDecryption),
+		},
+	}
+
+	for _, c := range res.Checks {
+		if c.Status == StatusOK {
+			continue
+		}
+
+		if c.Critical {
+			res.Status = StatusDown
+			break
+		}
+		res.Status = StatusDegraded
+	}
+
+	send(w, res)
+}
+
+// runCheck runs a check with a timeout and records how long the check took. A check
+// returns a description of the problem, or blank if the dependency is healthy.
+func runCheck(ctx context.Context, name string, critical bool, fn func(context.Context) string) (c check) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	problem := fn(ctx)
+
+	c = check{
+		Name:       name,
+		Status:     StatusOK,
+		Critical:   critical,
+		Message:    problem,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if problem != "" {
+		c.Status = StatusDown
+	}
+
+	return
+}
+
+// checkConfig checks that the config was read and validated.
+func checkConfig(ctx context.Context) (problem string) {
+	if !config.Loaded() {
+		return "Config has not been loaded."
+	}
+
+	return ""
+}
+
+// checkDatabase checks that the database is connected and can be queried.
+func checkDatabase(ctx context.Context) (problem string) {
+	c := sqldb.Connection()
+	if c == nil {
+		return "Database is not connected."
+	}
+
+	var one int
+	err := c.GetContext(ctx, &one, "SELECT 1")
+	if err != nil {
+		return "Could not query database: " + err.Error()
+	}
+
+	return ""
+}
+
+// checkKeyPairDecryption checks that the private key encryption key can decrypt the
+// stored private keys. No details about the key material are returned.
+func checkKeyPairDecryption(ctx context.Context) (problem string) {
+	ok, problem, err := keypairs.EncryptionKeyOK(ctx)
+	if err != nil {
+		return "Could not look up key pairs: " + err.Error()
+	} else if !ok {
+		return problem
+	}
+
+	return ""
+}
+
+// send writes the result with a 503 status if the app is down so that tools which
+// only look at the status code work as expected.
+func send(w http.ResponseWriter, res result) {
+	code := http.StatusOK
+	if res.Status == StatusDown {
+		code = http.StatusServiceUnavailable
+	}
+
+	p := output.Payload{
+		OK:   res.Status != StatusDown,
+		Type: msgTypeHealthcheck,
+		Data: res,
+	}
+	output.Send(p, w, code)
+}
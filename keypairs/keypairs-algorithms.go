@@ -0,0 +1,57 @@
+package keypairs
+
+import (
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/licensefile"
+)
+
+// This file handles limiting the key pair algorithms that can be used per the
+// MinimumKeyPairAlgorithm field in the config file. This is a policy control so that
+// new licenses are not signed with weaker key pairs once stronger key pairs are in
+// use. Existing licenses, and licenses signed with weaker key pairs before the
+// minimum was set, still verify since verifying doesn't check the minimum.
+
+// AllowedAlgorithms returns the key pair algorithms that can be used to generate a key
+// pair.
+func AllowedAlgorithms() (aa []licensefile.KeyPairAlgoType) {
+	for _, a := range licensefile.KeyPairAlgoTypes() {
+		if MeetsMinimum(a) {
+			aa = append(aa, a)
+		}
+	}
+
+	return
+}
+
+// DefaultAlgorithm returns the algorithm chosen by default when generating a key pair.
+// This is licensefile.DefaultKeyPairAlgo unless it is weaker than the minimum, in which
+// case the minimum is used.
+func DefaultAlgorithm() licensefile.KeyPairAlgoType {
+	if MeetsMinimum(licensefile.DefaultKeyPairAlgo) {
+		return licensefile.DefaultKeyPairAlgo
+	}
+
+	return config.Data().MinimumKeyPairAlgorithm
+}
+
+// MeetsMinimum returns true if an algorithm is at least as strong as the minimum set
+// in the config file. True is always returned if a minimum is not set.
+func MeetsMinimum(a licensefile.KeyPairAlgoType) bool {
+	minimum := config.Data().MinimumKeyPairAlgorithm
+	if minimum == "" {
+		return true
+	}
+
+	return a.AtLeast(minimum)
+}
+
+// MinimumErrMsg returns the error message shown when a key pair cannot be used to
+// sign a license since its algorithm is weaker than the minimum. Blank is returned if
+// the key pair's algorithm meets the minimum.
+func MinimumErrMsg(a licensefile.KeyPairAlgoType) (errMsg string) {
+	if MeetsMinimum(a) {
+		return
+	}
+
+	return "This key pair uses " + string(a) + " which is weaker than the minimum allowed algorithm, " + string(config.Data().MinimumKeyPairAlgorithm) + ". An administrator must generate a key pair using a stronger algorithm and set it as the app's default key pair."
+}
@@ -0,0 +1,115 @@
+package keypairs
+
+import (
+	"context"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles checking that the PrivateKeyEncryptionKey in the config file can
+// decrypt the stored private keys. If the encryption key is changed by mistake, key
+// pairs cannot be used to sign licenses but nothing reports this until a user tries
+// to create a license. Checking at startup, and on demand, catches this sooner.
+
+// encryptionKeyResult is the result of trying to decrypt each encrypted private key.
+// No key material is included.
+type encryptionKeyResult struct {
+	OK        bool //true if every encrypted private key could be decrypted.
+	Checked   int  //number of active key pairs with an encrypted private key.
+	Decrypted int  //number of private keys that were decrypted successfully.
+	Error     string
+}
+
+// CheckEncryptionKey reports if the PrivateKeyEncryptionKey in the config file can
+// decrypt each active key pair's encrypted private key.
+func CheckEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	result, err := checkEncryptionKey(r.Context())
+	if err != nil {
+		output.Error(err, "Could not check private key encryption key.", w)
+		return
+	}
+
+	output.DataFound(result, w)
+}
+
+// LogEncryptionKeyCheck checks if the PrivateKeyEncryptionKey in the config file can
+// decrypt each active key pair's encrypted private key and logs a warning if not.
+// This is called when the app starts. The app is not stopped since other parts of the
+// app can still be used and the config file may be being fixed.
+func LogEncryptionKeyCheck() {
+	result, err := checkEncryptionKey(context.Background())
+	if err != nil {
+		log.Println("WARNING! keypairs.LogEncryptionKeyCheck", "could not check private key encryption key", err)
+		return
+	}
+
+	if !result.OK {
+		log.Println("*********************************************")
+		log.Println("WARNING! Private keys could not be decrypted.")
+		log.Printf(" %s", result.Error)
+		log.Printf(" %d of %d encrypted private keys were decrypted.", result.Decrypted, result.Checked)
+		log.Println(" Licenses cannot be created with key pairs whose private key cannot be decrypted.")
+		log.Println("*********************************************")
+	}
+}
+
+// EncryptionKeyOK reports if the PrivateKeyEncryptionKey in the config file can
+// decrypt each active key pair's encrypted private key. If not, a description of the
+// problem is returned. This is used for the readiness healthcheck.
+func EncryptionKeyOK(ctx context.Context) (ok bool, problem string, err error) {
+	result, err := checkEncryptionKey(ctx)
+	if err != nil {
+		return
+	}
+
+	return result.OK, result.Error, nil
+}
+
+// checkEncryptionKey tries to decrypt each active key pair's encrypted private key. The
+// decrypted private keys are discarded. The error from decrypting is not included in
+// the result, just a description, so that nothing about the key material can be
+// leaked.
+func checkEncryptionKey(ctx context.Context) (result encryptionKeyResult, err error) {
+	kk, err := db.GetEncryptedKeyPairs(ctx)
+	if err != nil {
+		return
+	}
+
+	result.Checked = len(kk)
+	if len(kk) == 0 {
+		result.OK = true
+		return
+	}
+
+	encKey := config.Data().PrivateKeyEncryptionKey
+	if encKey == "" {
+		result.Error = "Private keys are encrypted but PrivateKeyEncryptionKey is not set in the config file."
+		return
+	}
+
+	for _, k := range kk {
+		pk, innerErr := hex.DecodeString(k.PrivateKey)
+		if innerErr != nil {
+			continue
+		}
+
+		_, innerErr = DecryptPrivateKey(encKey, pk)
+		if innerErr != nil {
+			continue
+		}
+
+		result.Decrypted++
+	}
+
+	result.OK = result.Decrypted == result.Checked
+	if !result.OK {
+		result.Error = "Some private keys could not be decrypted. PrivateKeyEncryptionKey may have been changed."
+	}
+
+	return
+}
@@ -0,0 +1,218 @@
+package keypairs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"golang.org/x/crypto/scrypt"
+)
+
+// This file handles exporting a keypair's private key. The private key is encrypted
+// with a passphrase provided by the admin exporting the key so the private key is
+// never exported in plaintext. The passphrase is never stored, the recipient of the
+// exported key must be provided the passphrase separately to decrypt the key.
+//
+// The exported private key is a PEM block with the encrypted key as the block's bytes.
+// The encrypted key is encrypted using AES-256-GCM with a key derived from the
+// passphrase using scrypt. The scrypt parameters and salt are stored in the PEM
+// block's headers. The nonce is included at the start of the encrypted key, the same
+// as when private keys are encrypted for storing in the database.
+
+// Details for encrypting an exported private key.
+const (
+	exportPEMType = "LICENSEKEYS ENCRYPTED PRIVATE KEY"
+
+	exportScryptN       = 32768
+	exportScryptR       = 8
+	exportScryptP       = 1
+	exportScryptKeyLen  = 32 //AES-256
+	exportScryptSaltLen = 16
+)
+
+// Errors.
+var (
+	// ErrInvalidExport is returned when an exported private key cannot be decrypted
+	// because it is not formatted correctly.
+	ErrInvalidExport = errors.New("keypairs: invalid exported private key")
+)
+
+// exportResult is the data returned when a private key is exported.
+type exportResult struct {
+	KeyPairID  int64
+	Filename   string //suggested name to save the exported private key as.
+	PrivateKey string //PEM encoded, encrypted private key.
+}
+
+// Export returns a keypair's private key encrypted with a passphrase. This is used when
+// an admin needs a copy of a private key outside of this app.
+//
+// The passphrase is not saved anywhere, including the activity log.
+func Export(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	passphrase := r.FormValue("passphrase")
+
+	//Validate.
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which key pair you want to export.", w)
+		return
+	}
+	minLength := config.Data().MinPasswordLength
+	if len(passphrase) < minLength {
+		output.ErrorInputInvalid("The passphrase must be at least "+strconv.Itoa(minLength)+" characters long.", w)
+		return
+	}
+
+	//Look up the key pair.
+	kp, err := db.GetKeyPairByID(r.Context(), id)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The key pair ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up key pair.", w)
+		return
+	}
+
+	//Decrypt the private key, if needed.
+	privateKey := []byte(kp.PrivateKey)
+	if kp.PrivateKeyEncrypted {
+		encKey := config.Data().PrivateKeyEncryptionKey
+
+		pk, err := hex.DecodeString(kp.PrivateKey)
+		if err != nil {
+			output.Error(err, "Could not decrypt private key to export (1).", w)
+			return
+		}
+
+		decryptedPrivKey, err := DecryptPrivateKey(encKey, pk)
+		if err != nil {
+			output.Error(err, "Could not decrypt private key to export (2).", w)
+			return
+		}
+		privateKey = decryptedPrivKey
+	}
+
+	//Encrypt the private key for export.
+	exported, err := encryptForExport(passphrase, privateKey)
+	if err != nil {
+		output.Error(err, "Could not encrypt private key for export.", w)
+		return
+	}
+
+	output.DataFound(exportResult{
+		KeyPairID:  kp.ID,
+		Filename:   "keypair-" + strconv.FormatInt(kp.ID, 10) + "-private.pem",
+		PrivateKey: string(exported),
+	}, w)
+}
+
+// encryptForExport encrypts a private key with a passphrase and returns the encrypted
+// private key as a PEM block.
+func encryptForExport(passphrase string, unencryptedPrivateKey []byte) (exported []byte, err error) {
+	salt := make([]byte, exportScryptSaltLen)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, exportScryptN, exportScryptR, exportScryptP, exportScryptKeyLen)
+	if err != nil {
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, aesgcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return
+	}
+
+	//the nonce will be included at the beginning of the encrypted private key
+	encrypted := aesgcm.Seal(nonce, nonce, unencryptedPrivateKey, nil)
+
+	b := &pem.Block{
+		Type: exportPEMType,
+		Headers: map[string]string{
+			"Cipher":   "AES-256-GCM",
+			"KDF":      "scrypt",
+			"Salt":     hex.EncodeToString(salt),
+			"Scrypt-N": strconv.Itoa(exportScryptN),
+			"Scrypt-R": strconv.Itoa(exportScryptR),
+			"Scrypt-P": strconv.Itoa(exportScryptP),
+		},
+		Bytes: encrypted,
+	}
+
+	exported = pem.EncodeToMemory(b)
+	return
+}
+
+// DecryptExportedPrivateKey decrypts a private key that was exported with a passphrase.
+// This is provided for recipients of an exported private key that are using Go.
+func DecryptExportedPrivateKey(passphrase string, exported []byte) (unencryptedPrivateKey []byte, err error) {
+	b, _ := pem.Decode(exported)
+	if b == nil || b.Type != exportPEMType {
+		return nil, ErrInvalidExport
+	}
+
+	salt, err := hex.DecodeString(b.Headers["Salt"])
+	if err != nil {
+		return nil, ErrInvalidExport
+	}
+	n, err := strconv.Atoi(b.Headers["Scrypt-N"])
+	if err != nil {
+		return nil, ErrInvalidExport
+	}
+	r, err := strconv.Atoi(b.Headers["Scrypt-R"])
+	if err != nil {
+		return nil, ErrInvalidExport
+	}
+	p, err := strconv.Atoi(b.Headers["Scrypt-P"])
+	if err != nil {
+		return nil, ErrInvalidExport
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, exportScryptKeyLen)
+	if err != nil {
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+
+	//get the nonce from the beginning of the encrypted private key
+	l := aesgcm.NonceSize()
+	if len(b.Bytes) < l {
+		return nil, ErrInvalidExport
+	}
+	nonce, encrypted := b.Bytes[:l], b.Bytes[l:]
+	unencryptedPrivateKey, err = aesgcm.Open(nil, nonce, encrypted, nil)
+	return
+}
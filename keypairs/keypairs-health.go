@@ -0,0 +1,129 @@
+package keypairs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/output"
+)
+
+// This file handles checking that each app's default key pair can be used to sign
+// licenses. This catches key pairs that became unusable, most commonly because the
+// PrivateKeyEncryptionKey in the config file was changed after private keys were
+// encrypted, before a user tries to create a license and gets an error.
+
+// healthResult is the result of checking an app's default key pair. No key material
+// is included.
+type healthResult struct {
+	AppID         int64
+	AppName       string
+	KeyPairID     int64 //0 if the app does not have a default key pair.
+	KeyPairName   string
+	AlgorithmType licensefile.KeyPairAlgoType
+	OK            bool
+	Error         string //why the check failed, blank if OK.
+}
+
+// Health checks each active app's default key pair by decrypting the private key, if
+// needed, and signing and verifying a throwaway license. A result is returned for each
+// app noting if the check passed or why it failed.
+func Health(w http.ResponseWriter, r *http.Request) {
+	apps, err := db.GetApps(r.Context(), true)
+	if err != nil {
+		output.Error(err, "Could not look up apps.", w)
+		return
+	}
+
+	results := make([]healthResult, 0, len(apps))
+	for _, a := range apps {
+		results = append(results, checkHealth(r.Context(), a))
+	}
+
+	output.DataFound(results, w)
+}
+
+// checkHealth checks an app's default key pair. The error returned by a failed step
+// is not included in the result, just a description of the step, so that nothing
+// about the key material can be leaked.
+func checkHealth(ctx context.Context, a db.App) (h healthResult) {
+	h = healthResult{
+		AppID:   a.ID,
+		AppName: a.Name,
+	}
+
+	kp, err := db.GetDefaultKeyPair(ctx, a.ID)
+	if err == sql.ErrNoRows {
+		h.Error = "App does not have a default key pair."
+		return
+	} else if err != nil {
+		h.Error = "Could not look up default key pair."
+		return
+	}
+
+	h.KeyPairID = kp.ID
+	h.KeyPairName = kp.Name
+	h.AlgorithmType = kp.AlgorithmType
+
+	//Decrypt the private key, if needed.
+	privateKey := []byte(kp.PrivateKey)
+	if kp.PrivateKeyEncrypted {
+		encKey := config.Data().PrivateKeyEncryptionKey
+		if encKey == "" {
+			h.Error = "Private key is encrypted but PrivateKeyEncryptionKey is not set in the config file."
+			return
+		}
+
+		pk, err := hex.DecodeString(kp.PrivateKey)
+		if err != nil {
+			h.Error = "Could not decode encrypted private key."
+			return
+		}
+
+		decryptedPrivKey, err := DecryptPrivateKey(encKey, pk)
+		if err != nil {
+			h.Error = "Could not decrypt private key. PrivateKeyEncryptionKey may have been changed."
+			return
+		}
+		privateKey = decryptedPrivKey
+	}
+
+	//Make sure the keys are PEM encoded since signing and verifying assume this.
+	if block, _ := pem.Decode(privateKey); block == nil {
+		h.Error = "Private key is not PEM encoded."
+		return
+	}
+	if block, _ := pem.Decode([]byte(kp.PublicKey)); block == nil {
+		h.Error = "Public key is not PEM encoded."
+		return
+	}
+
+	//Sign and verify a throwaway license.
+	f := licensefile.File{
+		AppName:     a.Name,
+		CompanyName: "Key Pair Health Check",
+		IssueDate:   "2006-01-02",
+		ExpireDate:  "2006-01-02",
+	}
+	f.SetFileFormat(licensefile.FileFormatJSON)
+
+	err = f.Sign(privateKey, kp.AlgorithmType)
+	if err != nil {
+		h.Error = "Could not sign with private key."
+		return
+	}
+
+	err = f.VerifySignature([]byte(kp.PublicKey), kp.AlgorithmType)
+	if err != nil {
+		h.Error = "Could not verify signature with public key. Private and public keys may not match."
+		return
+	}
+
+	h.OK = true
+	return
+}
@@ -0,0 +1,334 @@
+package keypairs
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles importing a key pair that was generated outside of this app, for
+// example when migrating from another licensing system or when a key pair was
+// generated on an offline machine. The imported key pair is stored the same as a key
+// pair generated by this app.
+//
+// The private and public keys can be provided PEM encoded or as hex encoded DER. The
+// keys are parsed and re-encoded in the same format used when a key pair is generated
+// so that signing and verifying work the same no matter where the key pair came from.
+// A private key exported from this app, see Export(), can also be imported by
+// providing the passphrase it was exported with.
+
+// Import saves a key pair using a provided private and public key. The keys are
+// checked to make sure they match the chosen algorithm and each other, by signing and
+// verifying a throwaway license, before the key pair is saved.
+//
+// The private key is not saved in the activity log.
+func Import(w http.ResponseWriter, r *http.Request) {
+	//Get input data.
+	raw := r.FormValue("data")
+	privateKeyInput := strings.TrimSpace(r.FormValue("privateKey"))
+	publicKeyInput := strings.TrimSpace(r.FormValue("publicKey"))
+	passphrase := r.FormValue("passphrase") //only needed for a private key exported from this app.
+
+	//Parse data into struct.
+	var k db.KeyPair
+	err := json.Unmarshal([]byte(raw), &k)
+	if err != nil {
+		output.Error(err, "Could not parse data to import key pair.", w)
+		return
+	}
+
+	//Make sure this isn't being called with an already existing key pair.
+	if k.ID != 0 {
+		output.ErrorAlreadyExists("Could not determine if you are adding or updating an key pair.", w)
+		return
+	}
+
+	//Validate.
+	if k.AlgorithmType.Valid() != nil {
+		output.ErrorInputInvalid("Please choose an algorithm from the provided options.", w)
+		return
+	}
+	if privateKeyInput == "" {
+		output.ErrorInputInvalid("You must provide the private key.", w)
+		return
+	}
+	if publicKeyInput == "" {
+		output.ErrorInputInvalid("You must provide the public key.", w)
+		return
+	}
+
+	errMsg, err := k.Validate(r.Context())
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate data to save key pair.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Parse the keys and make sure they match each other.
+	privateKey, publicKey, errMsg := parseImportedKeys(k.AlgorithmType, privateKeyInput, publicKeyInput, passphrase)
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Get user who is importing this key pair.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	k.CreatedByUserID = loggedInUserID
+
+	//Set the keys, encrypting the private key if needed.
+	errMsg, err = setKeys(&k, privateKey, publicKey)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	}
+
+	//Save.
+	errMsg, err = insert(r.Context(), &k)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	}
+
+	//Return full data for new key pair, the same as when a key pair is generated.
+	output.InsertOKWithData(k, w)
+}
+
+// parseImportedKeys parses the provided private and public keys, checks that they are
+// for the chosen algorithm, and checks that they match each other. The keys are
+// returned PEM encoded in the same format used when a key pair is generated.
+//
+// Only an error message is returned since any error is the result of bad input.
+func parseImportedKeys(algo licensefile.KeyPairAlgoType, privateKeyInput, publicKeyInput, passphrase string) (privateKey, publicKey []byte, errMsg string) {
+	//Decrypt the private key if it was exported from this app.
+	if b, _ := pem.Decode([]byte(privateKeyInput)); b != nil && b.Type == exportPEMType {
+		if passphrase == "" {
+			errMsg = "This private key was exported from this app. You must provide the passphrase it was exported with."
+			return
+		}
+
+		decrypted, err := DecryptExportedPrivateKey(passphrase, []byte(privateKeyInput))
+		if err != nil {
+			errMsg = "Could not decrypt the private key. Please check the passphrase."
+			return
+		}
+		privateKeyInput = string(decrypted)
+	}
+
+	//Parse the keys.
+	privateDER, ok := decodeKeyInput(privateKeyInput)
+	if !ok {
+		errMsg = "Could not decode the private key. The private key must be PEM or hex encoded."
+		return
+	}
+	publicDER, ok := decodeKeyInput(publicKeyInput)
+	if !ok {
+		errMsg = "Could not decode the public key. The public key must be PEM or hex encoded."
+		return
+	}
+
+	priv, ok := parsePrivateKey(privateDER)
+	if !ok {
+		errMsg = "Could not parse the private key. Encrypted private keys are not supported."
+		return
+	}
+	pub, ok := parsePublicKey(publicDER)
+	if !ok {
+		errMsg = "Could not parse the public key."
+		return
+	}
+
+	//Make sure the keys are for the chosen algorithm.
+	if keyAlgorithm(priv) != algo {
+		errMsg = "The private key is not a " + string(algo) + " key."
+		return
+	}
+	if keyAlgorithm(pub) != algo {
+		errMsg = "The public key is not a " + string(algo) + " key."
+		return
+	}
+
+	//Encode the keys the same as when a key pair is generated.
+	privateKey, publicKey, err := encodeKeys(priv, pub)
+	if err != nil {
+		errMsg = "Could not encode the private and public keys."
+		return
+	}
+
+	//Make sure the keys match by signing and verifying a throwaway license.
+	f := licensefile.File{
+		AppName:     "Key Pair Import",
+		CompanyName: "Key Pair Import",
+		IssueDate:   "2006-01-02",
+		ExpireDate:  "2006-01-02",
+	}
+	f.SetFileFormat(licensefile.FileFormatJSON)
+
+	err = f.Sign(privateKey, algo)
+	if err != nil {
+		errMsg = "Could not sign with the private key."
+		return
+	}
+
+	err = f.VerifySignature(publicKey, algo)
+	if err != nil {
+		errMsg = "The private and public keys do not match. Please make sure both keys are from the same key pair."
+		return
+	}
+
+	return
+}
+
+// decodeKeyInput returns the DER bytes of a PEM or hex encoded key.
+func decodeKeyInput(s string) (der []byte, ok bool) {
+	if b, _ := pem.Decode([]byte(s)); b != nil {
+		return b.Bytes, true
+	}
+
+	//Hex can be copied with line breaks or spaces, remove them.
+	s = strings.Join(strings.Fields(s), "")
+	s = strings.TrimPrefix(s, "0x")
+
+	der, err := hex.DecodeString(s)
+	if err != nil || len(der) == 0 {
+		return nil, false
+	}
+
+	return der, true
+}
+
+// parsePrivateKey parses a DER encoded private key in any of the common formats.
+func parsePrivateKey(der []byte) (key any, ok bool) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return k, true
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, true
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, true
+	}
+
+	return nil, false
+}
+
+// parsePublicKey parses a DER encoded public key in any of the common formats.
+func parsePublicKey(der []byte) (key any, ok bool) {
+	if k, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return k, true
+	}
+	if k, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return k, true
+	}
+
+	return nil, false
+}
+
+// keyAlgorithm returns the algorithm of a parsed private or public key. Blank is
+// returned if the key is not for a supported algorithm.
+func keyAlgorithm(key any) licensefile.KeyPairAlgoType {
+	switch k := key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return licensefile.KeyPairAlgoED25519
+
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve)
+
+	case *rsa.PrivateKey:
+		return rsaAlgorithm(k.N.BitLen())
+	case *rsa.PublicKey:
+		return rsaAlgorithm(k.N.BitLen())
+	}
+
+	return ""
+}
+
+// ecdsaAlgorithm returns the algorithm for an ECDSA curve.
+func ecdsaAlgorithm(c elliptic.Curve) licensefile.KeyPairAlgoType {
+	switch c {
+	case elliptic.P256():
+		return licensefile.KeyPairAlgoECDSAP256
+	case elliptic.P384():
+		return licensefile.KeyPairAlgoECDSAP384
+	case elliptic.P521():
+		return licensefile.KeyPairAlgoECDSAP521
+	}
+
+	return ""
+}
+
+// rsaAlgorithm returns the algorithm for an RSA key size.
+func rsaAlgorithm(bits int) licensefile.KeyPairAlgoType {
+	switch bits {
+	case 2048:
+		return licensefile.KeyPairAlgoRSA2048
+	case 4096:
+		return licensefile.KeyPairAlgoRSA4096
+	}
+
+	return ""
+}
+
+// encodeKeys PEM encodes a parsed private and public key in the same format used by
+// licensefile.GenerateKeyPair(). The keys must already be checked to be for a
+// supported algorithm.
+func encodeKeys(priv, pub any) (privateKey, publicKey []byte, err error) {
+	var privateDER, publicDER []byte
+	switch k := priv.(type) {
+	case ed25519.PrivateKey:
+		privateDER, err = x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return
+		}
+		publicDER, err = x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return
+		}
+
+	case *ecdsa.PrivateKey:
+		privateDER, err = x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return
+		}
+		publicDER, err = x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return
+		}
+
+	case *rsa.PrivateKey:
+		privateDER = x509.MarshalPKCS1PrivateKey(k)
+		publicDER = x509.MarshalPKCS1PublicKey(pub.(*rsa.PublicKey))
+	}
+
+	privateKey = pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateDER,
+	})
+	publicKey = pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicDER,
+	})
+	return
+}
@@ -0,0 +1,140 @@
+package keypairs
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/output"
+	"github.com/gorilla/mux"
+)
+
+// This file handles exposing an app's public keys publicly, without logging in. This
+// is used by third-party apps to fetch the current set of valid public keys when key
+// pairs are rotated so that licenses signed with an old or new key pair can both be
+// verified. See licensefile.VerifyAny().
+
+// publicKey is the non-sensitive data about a key pair that is exposed publicly.
+type publicKey struct {
+	KeyPairID     int64
+	Name          string
+	PublicKey     string
+	AlgorithmType licensefile.KeyPairAlgoType
+	IsDefault     bool
+}
+
+// PublicKeys returns the public keys for each active key pair for an app.
+func PublicKeys(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(mux.Vars(r)["appID"], 10, 64)
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to look up public keys for.", w)
+		return
+	}
+
+	a, err := db.GetAppByID(r.Context(), appID)
+	if err == sql.ErrNoRows || (err == nil && !a.Active) {
+		output.ErrorInputInvalid("The app ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up app.", w)
+		return
+	}
+
+	//Look up the key pairs.
+	kk, err := db.GetKeyPairs(r.Context(), appID, true)
+	if err != nil {
+		output.Error(err, "Could not get list of public keys.", w)
+		return
+	}
+
+	pp := make([]publicKey, 0, len(kk))
+	for _, k := range kk {
+		pp = append(pp, publicKey{
+			KeyPairID:     k.ID,
+			Name:          k.Name,
+			PublicKey:     k.PublicKey,
+			AlgorithmType: k.AlgorithmType,
+			IsDefault:     k.IsDefault,
+		})
+	}
+
+	output.DataFound(pp, w)
+}
+
+// defaultPublicKey is the data returned about an app's default key pair.
+type defaultPublicKey struct {
+	KeyPairID     int64
+	PublicKey     string //hex encoded DER.
+	AlgorithmType licensefile.KeyPairAlgoType
+}
+
+// DefaultPublicKey returns the public key of an app's default key pair. This is used
+// to fetch the public key to embed in an app's code, for example from a CI pipeline,
+// without copying the public key from the GUI.
+//
+// By default, the public key is returned hex encoded, along with the key pair's ID
+// and algorithm, as JSON. If the "format" query parameter is "pem", the PEM encoded
+// public key is returned as plain text instead.
+func DefaultPublicKey(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	appID, _ := strconv.ParseInt(mux.Vars(r)["appID"], 10, 64)
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+
+	//Validate.
+	if appID < 1 {
+		output.ErrorInputInvalid("Could not determine which app you want to look up the public key for.", w)
+		return
+	}
+	if format != "" && format != "hex" && format != "pem" {
+		output.ErrorInputInvalid("Invalid format provided. Format must be hex or pem.", w)
+		return
+	}
+
+	a, err := db.GetAppByID(r.Context(), appID)
+	if err == sql.ErrNoRows || (err == nil && !a.Active) {
+		output.ErrorInputInvalid("The app ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up app.", w)
+		return
+	}
+
+	//Look up the default key pair.
+	k, err := db.GetDefaultKeyPair(r.Context(), appID)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("This app does not have a default key pair.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up default key pair.", w)
+		return
+	}
+
+	//Return the PEM encoded public key as is.
+	if format == "pem" {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write([]byte(k.PublicKey))
+		return
+	}
+
+	//Return the hex encoded public key.
+	block, _ := pem.Decode([]byte(k.PublicKey))
+	if block == nil {
+		output.Error(errors.New("could not decode pem"), "Could not decode public key.", w)
+		return
+	}
+
+	output.DataFound(defaultPublicKey{
+		KeyPairID:     k.ID,
+		PublicKey:     hex.EncodeToString(block.Bytes),
+		AlgorithmType: k.AlgorithmType,
+	}, w)
+}
@@ -1,17 +1,20 @@
 /*
 Package keypairs handles the public-private keypairs defined for your apps that are
 used to sign your license data to create license keys. Keypairs are used to sign your
-data for authenticity purposes. The private key should never leave this app while the
-public key can be exported for placement in your app's code.
+data for authenticity purposes. The private key should never leave this app, unless
+exported encrypted with a passphrase by an administrator, while the public key can be
+exported for placement in your app's code.
 */
 package keypairs
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -22,6 +25,7 @@ import (
 	"github.com/c9845/licensekeys/v3/licensefile"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
 )
 
 // Add saves a new keypair. The keypair data provided is used to generate a new keypair
@@ -66,12 +70,43 @@ func Add(w http.ResponseWriter, r *http.Request) {
 	k.CreatedByUserID = loggedInUserID
 
 	//Generate the key pair public and private key.
-	privateKey, publicKey, err := licensefile.GenerateKeyPair(k.AlgorithmType)
+	errMsg, err = GenerateKeys(&k)
 	if err != nil {
-		output.Error(err, "Could not generate key pair.", w)
+		output.Error(err, errMsg, w)
 		return
 	}
 
+	//Save.
+	errMsg, err = insert(r.Context(), &k)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	}
+
+	//Return full data for new key pair. We need this to show the public key and set
+	//the "Set As Default" gui state correctly.
+	output.InsertOKWithData(k, w)
+}
+
+// GenerateKeys generates the private and public keys for a key pair using the key
+// pair's AlgorithmType. The private key is encrypted if an encryption key is provided
+// in the config file.
+//
+// An error message is returned, along with an error, to display to the user.
+func GenerateKeys(k *db.KeyPair) (errMsg string, err error) {
+	privateKey, publicKey, err := licensefile.GenerateKeyPair(k.AlgorithmType)
+	if err != nil {
+		return "Could not generate key pair.", err
+	}
+
+	return setKeys(k, privateKey, publicKey)
+}
+
+// setKeys sets the private and public keys for a key pair, encrypting the private key
+// if an encryption key is provided in the config file. The keys must be PEM encoded.
+//
+// An error message is returned, along with an error, to display to the user.
+func setKeys(k *db.KeyPair, privateKey, publicKey []byte) (errMsg string, err error) {
 	//Set data for saving to db. We save the private & public keys as strings in the
 	//database just for ease of use. We could store as BLOB (sqlite) instead but string
 	//works fine. Plus, we can inspecte the private and public keys in the database
@@ -92,35 +127,51 @@ func Add(w http.ResponseWriter, r *http.Request) {
 		encryptionKey := config.Data().PrivateKeyEncryptionKey
 		encryptedPrivateKey, err := encryptPrivateKey(encryptionKey, privateKey)
 		if err != nil {
-			output.Error(err, "Could not save key pair. The private key could not be encrypted. Please contact an administrator.", w)
-			return
+			return "Could not save key pair. The private key could not be encrypted. Please contact an administrator.", err
 		}
 
 		k.PrivateKey = hex.EncodeToString(encryptedPrivateKey)
 		k.PrivateKeyEncrypted = true
 	}
 
+	return
+}
+
+// insert saves a new key pair. The key pair is marked as the default if it is the only
+// active key pair for the app.
+//
+// An error message is returned, along with an error, to display to the user.
+func insert(ctx context.Context, k *db.KeyPair) (errMsg string, err error) {
 	//Check if this will be the only active license for this app, and if it is, mark
 	//it as the default.
-	kps, err := db.GetKeyPairs(r.Context(), k.AppID, true)
+	kps, err := db.GetKeyPairs(ctx, k.AppID, true)
 	if err != nil {
 		//No returning error since this isn't an end of the world scenario.
-		log.Println("keypairs.Add", "could not look up existing keypairs to set default", err)
+		log.Println("keypairs.insert", "could not look up existing keypairs to set default", err)
 	}
 	if len(kps) == 0 {
 		k.IsDefault = true
 	}
 
 	//Save.
-	err = k.Insert(r.Context())
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(ctx, nil)
 	if err != nil {
-		output.Error(err, "Could not save key pair.", w)
-		return
+		return "Could not save key pair (1).", err
 	}
+	defer tx.Rollback()
 
-	//Return full data for new key pair. We need this to show the public key and set
-	//the "Set As Default" gui state correctly.
-	output.InsertOKWithData(k, w)
+	err = k.Insert(ctx, tx)
+	if err != nil {
+		return "Could not save key pair (2).", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "Could not save key pair (3).", err
+	}
+
+	return
 }
 
 // encryptPrivateKey encrypts a private key with the encryption key provided in the
@@ -196,6 +247,10 @@ func DecryptPrivateKey(encryptionKey string, encryptedPrivateKey []byte) (unecry
 	//get the nonce from the beginning of the encrypted private key
 	//reset the encrypted private key to not include the nonce
 	l := aesgcm.NonceSize()
+	if len(encryptedPrivateKey) < l {
+		err = errors.New("encrypted private key is too short")
+		return
+	}
 	nonce, encryptedPrivateKey := encryptedPrivateKey[:l], encryptedPrivateKey[l:]
 	unecryptedPrivKey, err = aesgcm.Open(nil, nonce, encryptedPrivateKey, nil)
 	return
@@ -253,12 +308,21 @@ func Default(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//Make sure the keypair isn't compromised since a compromised keypair cannot be
+	//used to sign new licenses.
+	k, err := db.GetKeyPairByID(r.Context(), id)
+	if err != nil {
+		output.Error(err, "Could not look up key pair.", w)
+		return
+	}
+	if k.Compromised {
+		output.ErrorInputInvalid("This key pair is marked as compromised and cannot be set as the default.", w)
+		return
+	}
+
 	//Set the default keypair. This will also set all other keypairs as non-default
 	//to make sure only one keypair is marked as default for the app.
-	k := db.KeyPair{
-		ID: id,
-	}
-	err := k.SetIsDefault(r.Context())
+	err = k.SetIsDefault(r.Context())
 	if err != nil {
 		output.Error(err, "Could not set key pair as default.", w)
 		return
@@ -266,3 +330,34 @@ func Default(w http.ResponseWriter, r *http.Request) {
 
 	output.UpdateOK(w)
 }
+
+// MarkCompromised marks a keypair as compromised. The keypair will no longer be
+// available for use to sign a license and cannot be set as the default. Licenses
+// already signed with the keypair can still be downloaded and verified.
+func MarkCompromised(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which key pair you want to mark as compromised.", w)
+		return
+	}
+
+	k, err := db.GetKeyPairByID(r.Context(), id)
+	if err != nil {
+		output.Error(err, "Could not look up key pair.", w)
+		return
+	}
+	if k.Compromised {
+		output.ErrorInputInvalid("This key pair is already marked as compromised.", w)
+		return
+	}
+
+	err = k.MarkCompromised(r.Context())
+	if err != nil {
+		output.Error(err, "Could not mark key pair as compromised.", w)
+		return
+	}
+
+	log.Printf("keypairs.MarkCompromised: key pair %d (%s) for app %d marked as compromised", k.ID, k.Name, k.AppID)
+	output.UpdateOK(w)
+}
@@ -2,7 +2,11 @@ package keypairs
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/pem"
 	"testing"
+
+	"github.com/c9845/licensekeys/v3/licensefile"
 )
 
 func TestEncryptPrivateKey(t *testing.T) {
@@ -46,3 +50,121 @@ func TestDecryptPrivateKey(t *testing.T) {
 		return
 	}
 }
+
+func TestEncryptForExport(t *testing.T) {
+	passphrase := "correct horse battery staple"
+	unencryptedData := []byte("This is a private key.")
+
+	exported, err := encryptForExport(passphrase, unencryptedData)
+	if err != nil {
+		t.Fatal("Error with encryption.", err)
+		return
+	}
+	if bytes.Contains(exported, unencryptedData) {
+		t.Fatal("exported data contains unencrypted data")
+		return
+	}
+
+	//decrypt
+	decryptedData, err := DecryptExportedPrivateKey(passphrase, exported)
+	if err != nil {
+		t.Fatal("Error with decryption.", err)
+		return
+	}
+	if !bytes.Equal(decryptedData, unencryptedData) {
+		t.Fatal("mismatch via byte compare")
+		return
+	}
+
+	//decrypt with wrong passphrase
+	_, err = DecryptExportedPrivateKey("wrong passphrase", exported)
+	if err == nil {
+		t.Fatal("Error expected when decrypting with wrong passphrase.")
+		return
+	}
+
+	//decrypt non-exported data
+	_, err = DecryptExportedPrivateKey(passphrase, unencryptedData)
+	if err != ErrInvalidExport {
+		t.Fatal("ErrInvalidExport expected but got", err)
+		return
+	}
+}
+
+func TestParseImportedKeys(t *testing.T) {
+	algos := []licensefile.KeyPairAlgoType{
+		licensefile.KeyPairAlgoED25519,
+		licensefile.KeyPairAlgoECDSAP256,
+		licensefile.KeyPairAlgoRSA2048,
+	}
+
+	for _, algo := range algos {
+		private, public, err := licensefile.GenerateKeyPair(algo)
+		if err != nil {
+			t.Fatal(algo, "Error generating key pair.", err)
+			return
+		}
+
+		//PEM encoded.
+		privateKey, publicKey, errMsg := parseImportedKeys(algo, string(private), string(public), "")
+		if errMsg != "" {
+			t.Fatal(algo, "Error importing PEM encoded keys.", errMsg)
+			return
+		}
+		if !bytes.Equal(privateKey, private) || !bytes.Equal(publicKey, public) {
+			t.Fatal(algo, "Imported keys do not match generated keys.")
+			return
+		}
+
+		//Hex encoded.
+		privateBlock, _ := pem.Decode(private)
+		publicBlock, _ := pem.Decode(public)
+		_, _, errMsg = parseImportedKeys(algo, hex.EncodeToString(privateBlock.Bytes), hex.EncodeToString(publicBlock.Bytes), "")
+		if errMsg != "" {
+			t.Fatal(algo, "Error importing hex encoded keys.", errMsg)
+			return
+		}
+
+		//Mismatched keys.
+		_, otherPublic, err := licensefile.GenerateKeyPair(algo)
+		if err != nil {
+			t.Fatal(algo, "Error generating key pair.", err)
+			return
+		}
+		_, _, errMsg = parseImportedKeys(algo, string(private), string(otherPublic), "")
+		if errMsg == "" {
+			t.Fatal(algo, "Error expected when importing mismatched keys.")
+			return
+		}
+	}
+
+	//Wrong algorithm.
+	private, public, err := licensefile.GenerateKeyPair(licensefile.KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal("Error generating key pair.", err)
+		return
+	}
+	_, _, errMsg := parseImportedKeys(licensefile.KeyPairAlgoECDSAP384, string(private), string(public), "")
+	if errMsg == "" {
+		t.Fatal("Error expected when importing keys for the wrong algorithm.")
+		return
+	}
+
+	//Exported from this app.
+	passphrase := "correct horse battery staple"
+	exported, err := encryptForExport(passphrase, private)
+	if err != nil {
+		t.Fatal("Error with encryption.", err)
+		return
+	}
+	_, _, errMsg = parseImportedKeys(licensefile.KeyPairAlgoECDSAP256, string(exported), string(public), passphrase)
+	if errMsg != "" {
+		t.Fatal("Error importing exported private key.", errMsg)
+		return
+	}
+	_, _, errMsg = parseImportedKeys(licensefile.KeyPairAlgoECDSAP256, string(exported), string(public), "wrong passphrase")
+	if errMsg == "" {
+		t.Fatal("Error expected when importing exported private key with wrong passphrase.")
+		return
+	}
+}
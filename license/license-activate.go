@@ -0,0 +1,141 @@
+package license
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/keypairs"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/output"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles offline activation of a license for customers whose machines
+// cannot reach the internet. The customer's app generates a request code, which
+// embeds the license ID, a machine ID, and a nonce, and the customer sends the code
+// to you. The request code is pasted in here and a response code, signed with the
+// license's private key, is returned. The response only verifies in the customer's
+// app for the same machine and request. See licensefile.ActivationRequest.
+
+// activateResult is the data returned when a license is activated offline.
+type activateResult struct {
+	LicenseID    int64
+	MachineID    string
+	ResponseCode string
+}
+
+// ActivateOffline validates a request code and the license it is for and returns a
+// signed response code. A note is saved to the license recording the machine the
+// license was activated on.
+func ActivateOffline(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	requestCode := strings.TrimSpace(r.FormValue("requestCode"))
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64) //optional, used to make sure the request code is for the license being viewed in the GUI.
+
+	//Validate.
+	if requestCode == "" {
+		output.ErrorInputInvalid("You must provide the request code.", w)
+		return
+	}
+
+	ar, err := licensefile.ParseActivationRequest(requestCode)
+	if err != nil {
+		output.ErrorInputInvalid("The request code provided is invalid. Please make sure the entire code was copied.", w)
+		return
+	}
+	if licenseID > 0 && ar.LicenseID != licenseID {
+		output.ErrorInputInvalid("The request code provided is for license "+strconv.FormatInt(ar.LicenseID, 10)+", not this license.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), ar.LicenseID)
+
+	//Make sure the license can be used. The same checks are used as when
+	//downloading a license since an activated license should be usable.
+	l, _, errMsg, err := getDownloadableLicense(r.Context(), ar.LicenseID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Get key pair data to sign the response. The response is signed with the same
+	//key pair as the license so the customer's app can verify both with the same
+	//public key.
+	kp, err := db.GetKeyPairByID(r.Context(), l.KeyPairID)
+	if err != nil {
+		output.Error(err, "Could not look up signature details.", w)
+		return
+	}
+	errMsg = checkSigningKeyPair(kp, "this license", "activated")
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Decrypt the private key, if needed.
+	privateKey := []byte(kp.PrivateKey)
+	if kp.PrivateKeyEncrypted {
+		encKey := config.Data().PrivateKeyEncryptionKey
+
+		pk, err := hex.DecodeString(kp.PrivateKey)
+		if err != nil {
+			output.Error(err, "Could not decrypt private key to sign activation (1).", w)
+			return
+		}
+
+		decryptedPrivKey, err := keypairs.DecryptPrivateKey(encKey, pk)
+		if err != nil {
+			output.Error(err, "Could not decrypt private key to sign activation (2).", w)
+			return
+		}
+		privateKey = decryptedPrivKey
+	}
+
+	//Sign and verify the response.
+	responseCode, err := licensefile.SignActivation(ar, privateKey, kp.AlgorithmType)
+	if err != nil {
+		output.Error(err, "Could not generate activation response.", w)
+		return
+	}
+
+	err = ar.VerifyResponse(responseCode, []byte(kp.PublicKey), kp.AlgorithmType)
+	if err != nil {
+		output.Error(err, "The activation response could not be verified. Please ask an administrator to investigate this error.", w)
+		return
+	}
+
+	//Save a note about the activation.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	n := db.LicenseNote{
+		LicenseID: l.ID,
+		Note:      "License activated offline for machine " + ar.MachineID + ".",
+	}
+	if userID > 0 {
+		n.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		n.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = n.Insert(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save note about activation.", w)
+		return
+	}
+
+	output.InsertOKWithData(activateResult{
+		LicenseID:    l.ID,
+		MachineID:    ar.MachineID,
+		ResponseCode: responseCode,
+	}, w)
+}
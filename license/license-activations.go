@@ -0,0 +1,258 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles limiting the number of machines a license can be activated on to
+// detect a license being shared. A client activates a license, with a machine ID,
+// each time it verifies the license remotely. Each machine is recorded once per
+// license. Once a license has been activated on the maximum number of machines,
+// activations from new machines are rejected while machines the license was already
+// activated on can continue to activate the license.
+//
+// The maximum number of machines for a license is set by a custom field, see
+// LicenseActivationsFieldName in the config file. Licenses without this field can be
+// activated on any number of machines, but activations are still recorded.
+//
+// An administrator can reset a license's activations, for example when a customer
+// replaces their machines.
+
+// errMaxActivationsReached is returned when a license is activated on a new machine
+// but the license has already been activated on the maximum number of machines.
+var errMaxActivationsReached = errors.New("max activations reached")
+
+// activationResult is the data returned when a license is activated.
+type activationResult struct {
+	PublicID        string
+	MachineID       string
+	ActivationCount int64 //the number of times the license has been activated on this machine.
+	Activations     int64 //the number of machines the license has been activated on.
+	MaxActivations  int64 //the maximum number of machines, 0 if unlimited.
+}
+
+// Activate records the activation of a license on a machine. If the license was
+// already activated on the machine, the activation is recorded again. If the license
+// has been activated on the maximum number of machines, an error is returned for new
+// machines.
+func Activate(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	publicID := strings.TrimSpace(r.FormValue("publicID"))
+	machineID := strings.TrimSpace(r.FormValue("machineID"))
+
+	//Validate.
+	if publicID == "" {
+		output.ErrorInputInvalid("Could not determine which license you want to activate.", w)
+		return
+	}
+	if machineID == "" {
+		output.ErrorInputInvalid("You must provide an identifier for the machine activating the license.", w)
+		return
+	}
+	if len(machineID) > db.LicenseActivationMachineIDMaxLength {
+		output.ErrorInputInvalid("The machine ID must be at most "+strconv.Itoa(db.LicenseActivationMachineIDMaxLength)+" characters.", w)
+		return
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	l, errMsg, err := getPublicLicense(r.Context(), publicID, apiKeyID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+	if !l.Active {
+		output.ErrorInputInvalid("This license has been disabled.", w)
+		return
+	}
+	if !l.Verified {
+		output.ErrorInputInvalid("This license is not verified and cannot be used.", w)
+		return
+	}
+	if l.Expired {
+		output.ErrorInputInvalid("This license is expired.", w)
+		return
+	}
+
+	maxActivations, _, err := getMaxActivations(r.Context(), l.ID)
+	if err != nil {
+		output.Error(err, "Could not look up the maximum number of activations for this license.", w)
+		return
+	}
+
+	//Start transaction so that the number of activations cannot change between
+	//checking the count and recording the activation.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not activate license (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	now := timestamps.YMDHMS()
+
+	//Record another activation if the license was already activated on this
+	//machine. Otherwise, make sure the license hasn't been activated on the maximum
+	//number of machines yet.
+	a, err := db.GetLicenseActivation(r.Context(), tx, l.ID, machineID)
+	if err != nil && err != sql.ErrNoRows {
+		output.Error(err, "Could not activate license (2).", w)
+		return
+	}
+
+	if a.ID > 0 {
+		a.DatetimeModified = now
+		err = a.Reactivate(r.Context(), tx)
+	} else {
+		count, innerErr := db.CountLicenseActivations(r.Context(), tx, l.ID)
+		if innerErr != nil {
+			output.Error(innerErr, "Could not activate license (3).", w)
+			return
+		}
+		if maxActivations > 0 && count >= maxActivations {
+			output.Error(errMaxActivationsReached, "This license has already been activated on the maximum number of machines ("+strconv.FormatInt(maxActivations, 10)+"). Please contact support to reset this license's activations.", w)
+			return
+		}
+
+		a = db.LicenseActivation{
+			DatetimeCreated:   now,
+			CreatedByAPIKeyID: apiKeyID,
+			LicenseID:         l.ID,
+			MachineID:         machineID,
+		}
+		err = a.Insert(r.Context(), tx)
+	}
+	if err != nil {
+		output.Error(err, "Could not activate license (4).", w)
+		return
+	}
+
+	count, err := db.CountLicenseActivations(r.Context(), tx, l.ID)
+	if err != nil {
+		output.Error(err, "Could not activate license (5).", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not activate license (6).", w)
+		return
+	}
+
+	output.DataFound(activationResult{
+		PublicID:        publicID,
+		MachineID:       machineID,
+		ActivationCount: a.ActivationCount,
+		Activations:     count,
+		MaxActivations:  maxActivations,
+	}, w)
+}
+
+// Activations returns the machines a license has been activated on.
+func Activations(w http.ResponseWriter, r *http.Request) {
+	//Make sure a license ID was provided and it is valid.
+	licenseID, _ := strconv.ParseInt(r.FormValue("licenseID"), 10, 64)
+	if licenseID < 1 {
+		output.ErrorInputInvalid("The license ID provided is invalid.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	_, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	aa, err := db.GetLicenseActivations(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not look up license activations.", w)
+		return
+	}
+
+	output.DataFound(aa, w)
+}
+
+// ResetActivations deletes every activation of a license so that the license can be
+// activated on new machines. A note is saved to the license recording the reset.
+func ResetActivations(w http.ResponseWriter, r *http.Request) {
+	//Get input.
+	licenseID, _ := strconv.ParseInt(r.FormValue("licenseID"), 10, 64)
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to reset activations for.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	_, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	//Delete the activations.
+	deleted, err := db.DeleteLicenseActivations(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not reset license activations.", w)
+		return
+	}
+
+	//Save a note about the reset.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	n := db.LicenseNote{
+		CreatedByUserID: null.IntFrom(loggedInUserID),
+		LicenseID:       licenseID,
+		Note:            "License activations were reset. " + strconv.FormatInt(deleted, 10) + " machine(s) removed.",
+	}
+	err = n.Insert(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save note about resetting activations.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// getMaxActivations returns the maximum number of machines a license can be activated
+// on from the license's custom field result named per the config file. found is false
+// if the license does not have a result for the field, meaning the license can be
+// activated on any number of machines.
+func getMaxActivations(ctx context.Context, licenseID int64) (maxActivations int64, found bool, err error) {
+	return getIntegerFieldResult(ctx, licenseID, config.Data().LicenseActivationsFieldName)
+}
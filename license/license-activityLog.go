@@ -0,0 +1,33 @@
+package license
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles looking up the activity log entries for a single license. This is
+// used when investigating a disputed license so that every request that touched the
+// license can be reviewed without searching through the entire activity log.
+
+// ActivityLog returns the activity log entries for requests that were made for a
+// license. Entries are returned oldest first.
+func ActivityLog(w http.ResponseWriter, r *http.Request) {
+	//Make sure a license ID was provided and it is valid.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if licenseID < 1 {
+		output.ErrorInputInvalid("The license ID provided is invalid.", w)
+		return
+	}
+
+	//Look up the activities.
+	aa, err := db.GetActivityLogForLicense(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not look up activity log for license.", w)
+		return
+	}
+
+	output.DataFound(aa, w)
+}
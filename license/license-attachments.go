@@ -0,0 +1,302 @@
+package license
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles files attached to a license, for example a signed contract.
+// Attachments are for internal reference only. They are saved on disk, in the
+// directory set by AttachmentsPath in the config file, and are never included in a
+// license file so they do not affect a license's signature.
+
+// maxAttachmentFilenameLength is the longest name of an uploaded file that is kept.
+// Longer names are truncated.
+const maxAttachmentFilenameLength = 200
+
+// Attachments gets the list of files attached to a license.
+func Attachments(w http.ResponseWriter, r *http.Request) {
+	//Make sure a license ID was provided and it is valid.
+	licenseID, _ := strconv.ParseInt(r.FormValue("licenseID"), 10, 64)
+	if licenseID < 1 {
+		output.ErrorInputInvalid("The license ID provided is invalid.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	_, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	//Look up the attachments.
+	aa, err := db.GetLicenseAttachments(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not look up license attachments.", w)
+		return
+	}
+
+	output.DataFound(aa, w)
+}
+
+// AddAttachment saves a file uploaded for a license. The file must be smaller than
+// AttachmentMaxSizeMB and be one of the AttachmentContentTypes set in the config
+// file. The type of file is detected from the file's contents since the type sent by
+// a browser is based on the file's extension.
+func AddAttachment(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Data()
+	if len(cfg.AttachmentContentTypes) == 0 {
+		output.ErrorInputInvalid("Attaching files is disabled. Set AttachmentContentTypes in the config file.", w)
+		return
+	}
+
+	//Make sure a license ID was provided and it is valid.
+	licenseID, _ := strconv.ParseInt(r.FormValue("licenseID"), 10, 64)
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to attach a file to.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	_, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	//Get the uploaded file.
+	file, header, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		output.ErrorInputInvalid("You must choose a file to attach.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not read uploaded file.", w)
+		return
+	}
+	defer file.Close()
+
+	maxSize := int64(cfg.AttachmentMaxSizeMB) << 20
+	if header.Size > maxSize {
+		output.ErrorInputInvalid("The file is too large. The maximum size is "+strconv.Itoa(cfg.AttachmentMaxSizeMB)+" MB.", w)
+		return
+	} else if header.Size == 0 {
+		output.ErrorInputInvalid("The file is empty.", w)
+		return
+	}
+
+	//Detect the type of file. DetectContentType only looks at the first 512 bytes.
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		output.Error(err, "Could not read uploaded file.", w)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if err != nil || !slices.Contains(cfg.AttachmentContentTypes, contentType) {
+		output.ErrorInputInvalid("This type of file, "+contentType+", cannot be attached. Allowed types are "+strings.Join(cfg.AttachmentContentTypes, ", ")+".", w)
+		return
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		output.Error(err, "Could not read uploaded file.", w)
+		return
+	}
+
+	//Get user who is attaching this file.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Save the file to disk. A random name is used so that the uploaded name cannot
+	//be used to write outside of the attachments directory.
+	storedFilename, err := randomAttachmentFilename()
+	if err != nil {
+		output.Error(err, "Could not save file.", w)
+		return
+	}
+
+	diskPath := attachmentPath(cfg.AttachmentsPath, licenseID, storedFilename)
+	err = writeAttachment(diskPath, file)
+	if err != nil {
+		output.Error(err, "Could not save file.", w)
+		return
+	}
+
+	//Save data about the file.
+	a := db.LicenseAttachment{
+		CreatedByUserID: loggedInUserID,
+		LicenseID:       licenseID,
+		Filename:        cleanAttachmentFilename(header.Filename),
+		StoredFilename:  storedFilename,
+		ContentType:     contentType,
+		Size:            header.Size,
+	}
+	err = a.Insert(r.Context())
+	if err != nil {
+		os.Remove(diskPath)
+		output.Error(err, "Could not save file.", w)
+		return
+	}
+
+	output.InsertOK(a.ID, w)
+}
+
+// DownloadAttachment serves a file attached to a license. The file is always served
+// as a download, not displayed in the browser, with the name it was uploaded with.
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which attachment you want to download.", w)
+		return
+	}
+
+	a, err := db.GetLicenseAttachmentByID(r.Context(), id)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The attachment does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up attachment.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), a.LicenseID)
+
+	f, err := os.Open(attachmentPath(config.Data().AttachmentsPath, a.LicenseID, a.StoredFilename))
+	if err != nil {
+		output.Error(err, "Could not open attachment.", w)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		output.Error(err, "Could not open attachment.", w)
+		return
+	}
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	http.ServeContent(w, r, a.Filename, fi.ModTime(), f)
+}
+
+// DeleteAttachment removes a file attached to a license. The data about the file is
+// kept, marked as inactive, for auditing.
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which attachment you want to delete.", w)
+		return
+	}
+
+	a, err := db.GetLicenseAttachmentByID(r.Context(), id)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The attachment does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up attachment.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), a.LicenseID)
+
+	err = a.Delete(r.Context())
+	if err != nil {
+		output.Error(err, "Could not delete attachment.", w)
+		return
+	}
+
+	//The attachment is already hidden from the GUI, so failing to remove the file is
+	//only logged.
+	err = os.Remove(attachmentPath(config.Data().AttachmentsPath, a.LicenseID, a.StoredFilename))
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("license.DeleteAttachment", "could not remove file", err)
+	}
+
+	output.UpdateOK(w)
+}
+
+// attachmentPath returns the path to an attachment on disk. Attachments are stored in
+// a directory per license to keep the number of files in a directory reasonable.
+func attachmentPath(dir string, licenseID int64, storedFilename string) string {
+	return filepath.Join(dir, strconv.FormatInt(licenseID, 10), storedFilename)
+}
+
+// randomAttachmentFilename returns a random name to save an attachment as on disk.
+func randomAttachmentFilename() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// writeAttachment saves an uploaded file to disk, creating the directory if needed.
+// The file is removed if it cannot be completely written.
+func writeAttachment(path string, src io.Reader) (err error) {
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return
+}
+
+// cleanAttachmentFilename returns the name of an uploaded file suitable for saving
+// and using when the file is downloaded. Browsers only send the name of the file but
+// any directories, in either Windows or Unix format, are removed just in case.
+func cleanAttachmentFilename(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+
+	if len(name) > maxAttachmentFilenameLength {
+		name = strings.ToValidUTF8(name[:maxAttachmentFilenameLength], "")
+	}
+
+	return name
+}
@@ -0,0 +1,170 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/keypairs"
+	"github.com/c9845/licensekeys/v3/licensefile"
+)
+
+// This file handles co-signing a license with more than one key pair. An app can
+// require that each license is signed by more than one key pair, see RequiredSignatures,
+// so that a single leaked private key cannot be used to create a valid license.
+//
+// The key pair chosen when creating a license provides the first signature, which is
+// also stored in the license's Signature field for backwards compatibility. The other
+// signatures are provided by the app's other active, non-compromised, key pairs that
+// meet the minimum key pair algorithm set in the config file.
+
+// getCoSigningKeyPairs returns the key pairs, other than the key pair chosen to sign a
+// license, used to co-sign a license for an app. An errMsg is returned if the app does
+// not have enough key pairs to meet its required number of signatures.
+func getCoSigningKeyPairs(ctx context.Context, a db.App, kp db.KeyPair) (kk []db.KeyPair, errMsg string, err error) {
+	needed := a.RequiredSignatures - 1
+	if needed < 1 {
+		return
+	}
+
+	all, err := db.GetKeyPairs(ctx, a.ID, true)
+	if err != nil {
+		return
+	}
+
+	for _, k := range all {
+		if k.ID == kp.ID || k.Compromised || !keypairs.MeetsMinimum(k.AlgorithmType) {
+			continue
+		}
+
+		kk = append(kk, k)
+		if len(kk) == needed {
+			return
+		}
+	}
+
+	errMsg = "This app requires " + strconv.Itoa(a.RequiredSignatures) + " signatures but does not have enough active key pairs. Please add more key pairs or lower the number of required signatures."
+	return nil, errMsg, nil
+}
+
+// licenseSigningKeys are the keys used to sign a license and to verify the license's
+// signatures. The key pair chosen for the license is first, followed by any co-signing
+// key pairs, so that the chosen key pair's signature is stored in Signature.
+type licenseSigningKeys struct {
+	signing   []licensefile.SigningKey
+	verifying []licensefile.VerifyingKey
+}
+
+// getLicenseSigningKeys returns the keys to sign a license for an app with, using the
+// license's key pair and, if the app requires more than one signature, the app's
+// co-signing key pairs. An errMsg is returned if the app does not have enough key
+// pairs to meet its required number of signatures.
+//
+// This is used whenever an existing license is re-signed, i.e.: renewed or extended,
+// so that co-signatures are never dropped.
+func getLicenseSigningKeys(ctx context.Context, a db.App, kp db.KeyPair) (keys licenseSigningKeys, errMsg string, err error) {
+	coSigners, errMsg, err := getCoSigningKeyPairs(ctx, a, kp)
+	if err != nil {
+		errMsg = "Could not look up key pairs to co-sign license."
+		return
+	} else if errMsg != "" {
+		return
+	}
+
+	keys, err = newLicenseSigningKeys(kp, coSigners)
+	if err != nil {
+		errMsg = "Could not decrypt private key to sign license data."
+		return
+	}
+
+	return
+}
+
+// newLicenseSigningKeys decrypts the private keys, if needed, of the key pair chosen
+// for a license and the co-signing key pairs.
+func newLicenseSigningKeys(kp db.KeyPair, coSigners []db.KeyPair) (keys licenseSigningKeys, err error) {
+	for _, k := range append([]db.KeyPair{kp}, coSigners...) {
+		pk, innerErr := decryptPrivateKey(k)
+		if innerErr != nil {
+			return keys, innerErr
+		}
+
+		keys.signing = append(keys.signing, licensefile.SigningKey{PrivateKey: pk, KeyPairAlgo: k.AlgorithmType})
+		keys.verifying = append(keys.verifying, licensefile.VerifyingKey{PublicKey: []byte(k.PublicKey), KeyPairAlgo: k.AlgorithmType})
+	}
+
+	return
+}
+
+// sign signs the license file with each key.
+func (keys licenseSigningKeys) sign(f *licensefile.File) error {
+	return f.SignMulti(keys.signing...)
+}
+
+// verify checks that each of the license file's signatures is valid. See
+// writeReadVerify().
+func (keys licenseSigningKeys) verify(f licensefile.File) error {
+	if len(keys.verifying) > 1 {
+		return writeReadVerifyMulti(f, keys.verifying)
+	}
+
+	return writeReadVerify(f, keys.verifying[0].KeyPairAlgo, keys.verifying[0].PublicKey)
+}
+
+// decryptPrivateKey returns a key pair's private key, decrypting it if needed, for
+// signing a license.
+func decryptPrivateKey(kp db.KeyPair) (privateKey []byte, err error) {
+	if !kp.PrivateKeyEncrypted {
+		return []byte(kp.PrivateKey), nil
+	}
+
+	pk, err := hex.DecodeString(kp.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	return keypairs.DecryptPrivateKey(config.Data().PrivateKeyEncryptionKey, pk)
+}
+
+// writeReadVerifyMulti is the same as writeReadVerify but checks that each of a
+// co-signed license's signatures is valid.
+func writeReadVerifyMulti(f licensefile.File, keys []licensefile.VerifyingKey) (err error) {
+	fileFormat := f.FileFormat()
+
+	b := bytes.Buffer{}
+	err = f.Write(&b)
+	if err != nil {
+		return
+	}
+
+	reread, err := licensefile.Unmarshal(b.Bytes(), fileFormat)
+	if err != nil {
+		return
+	}
+
+	_, err = reread.VerifyMulti(keys, len(keys))
+	if err == licensefile.ErrNotEnoughSignatures {
+		err = licensefile.ErrBadSignature
+	}
+	return
+}
+
+// joinSignatures returns the signatures for storing in the database. This is blank
+// if a license was not co-signed.
+func joinSignatures(sigs []string) string {
+	return strings.Join(sigs, "\n")
+}
+
+// splitSignatures returns the signatures stored in the database for setting in a
+// license file. This returns nil if a license was not co-signed.
+func splitSignatures(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
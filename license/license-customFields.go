@@ -0,0 +1,168 @@
+package license
+
+import (
+	"context"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file specifically deals with building custom field results from values that
+// weren't provided via the GUI. This is used when creating a license via the API,
+// where values are provided as field-name:value pairs, and when renewing a license,
+// where values are carried over from the renewed-from license.
+
+// setCustomFieldResultValue sets the value for a custom field result based on the
+// field's type. The value is provided as parsed from JSON, therefore numbers are
+// always float64. False is returned if the value is not the correct type for the
+// field.
+func setCustomFieldResultValue(c *db.CustomFieldResult, definedField db.CustomFieldDefined, value interface{}) (ok bool) {
+	switch definedField.Type {
+	case db.CustomFieldTypeInteger:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		c.IntegerValue = null.IntFrom(int64(v))
+	case db.CustomFieldTypeDecimal:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		c.DecimalValue = null.FloatFrom(v)
+	case db.CustomFieldTypeText, db.CustomFieldTypeEmail, db.CustomFieldTypeURL, db.CustomFieldTypeFileHash:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+		c.TextValue = null.StringFrom(v)
+	case db.CustomFieldTypeBoolean:
+		v, ok := value.(bool)
+		if !ok {
+			return false
+		}
+		c.BoolValue = null.BoolFrom(v)
+	case db.CustomFieldTypeMultiChoice:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+		c.MultiChoiceValue = null.StringFrom(v)
+	case db.CustomFieldTypeDate:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+		c.DateValue = null.StringFrom(v)
+	default:
+		//This will never be hit because we looked up defined fields from db and
+		//these should always have valid types (unless db was modified manually).
+	}
+
+	return true
+}
+
+// setCustomFieldResultDefault sets the value for a custom field result to the
+// default value set for the defined field.
+func setCustomFieldResultDefault(c *db.CustomFieldResult, definedField db.CustomFieldDefined) {
+	switch definedField.Type {
+	case db.CustomFieldTypeInteger:
+		c.IntegerValue = null.IntFrom(definedField.IntegerDefaultValue.Int64)
+	case db.CustomFieldTypeDecimal:
+		c.DecimalValue = null.FloatFrom(definedField.DecimalDefaultValue.Float64)
+	case db.CustomFieldTypeText, db.CustomFieldTypeEmail, db.CustomFieldTypeURL, db.CustomFieldTypeFileHash:
+		c.TextValue = null.StringFrom(definedField.TextDefaultValue.String)
+	case db.CustomFieldTypeBoolean:
+		c.BoolValue = null.BoolFrom(definedField.BoolDefaultValue.Bool)
+	case db.CustomFieldTypeMultiChoice:
+		c.MultiChoiceValue = null.StringFrom(definedField.MultiChoiceDefaultValue.String)
+	case db.CustomFieldTypeDate:
+		now := time.Now()
+		add := now.AddDate(0, 0, int(definedField.DateDefaultIncrement.Int64))
+		c.DateValue = null.StringFrom(add.Format("2006-01-02"))
+	default:
+		//This will never be hit because we looked up defined fields from db and
+		//these should always have valid types (unless db was modified manually).
+	}
+}
+
+// carryOverCustomFieldResults builds the custom field results for a renewed license
+// from the results saved for the renewed-from license. A result is built for each
+// field currently defined for the app:
+//   - If an override value was provided for the field, by the field's name, it is
+//     used.
+//   - Otherwise, if the renewed-from license has a result for the field, its value is
+//     carried over.
+//   - Otherwise, the field was defined after the renewed-from license was created,
+//     and the field's default value is used.
+//
+// The names of any results from the renewed-from license for fields that are no
+// longer defined are returned so they can be noted. The returned results still need
+// to be validated since carried over values may no longer be valid for the field.
+//
+// If an override value is not the correct type for its field, the field's name is
+// returned as invalidOverride.
+func carryOverCustomFieldResults(ctx context.Context, appID int64, fromResults []db.CustomFieldResult, overrides map[string]interface{}) (results db.MultiCustomFieldResult, droppedFields []string, invalidOverride string, err error) {
+	definedFields, err := db.GetCustomFieldsDefined(ctx, appID, true)
+	if err != nil {
+		return
+	}
+
+	for _, definedField := range definedFields {
+		c := db.CustomFieldResult{
+			CustomFieldDefinedID: definedField.ID,
+			CustomFieldType:      definedField.Type,
+			CustomFieldName:      definedField.Name,
+		}
+
+		if value, ok := overrides[definedField.Name]; ok {
+			if !setCustomFieldResultValue(&c, definedField, value) {
+				invalidOverride = definedField.Name
+				return
+			}
+
+			results = append(results, c)
+			continue
+		}
+
+		carriedOver := false
+		for _, from := range fromResults {
+			if from.CustomFieldDefinedID != definedField.ID {
+				continue
+			}
+
+			c.IntegerValue = from.IntegerValue
+			c.DecimalValue = from.DecimalValue
+			c.TextValue = from.TextValue
+			c.BoolValue = from.BoolValue
+			c.MultiChoiceValue = from.MultiChoiceValue
+			c.DateValue = from.DateValue
+			carriedOver = true
+			break
+		}
+
+		if !carriedOver {
+			setCustomFieldResultDefault(&c, definedField)
+		}
+
+		results = append(results, c)
+	}
+
+	//Find results for fields that are no longer defined.
+	for _, from := range fromResults {
+		found := false
+		for _, definedField := range definedFields {
+			if from.CustomFieldDefinedID == definedField.ID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			droppedFields = append(droppedFields, from.CustomFieldName)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,243 @@
+package license
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles comparing two licenses, typically a license and the license it
+// was renewed or transferred to, so that users can see exactly what changed. The
+// differences are returned as a list of rows for rendering as a side-by-side table.
+
+// Types of changes for a field between the two licenses being compared.
+const (
+	diffChangeNone    = "unchanged"
+	diffChangeAdded   = "added"   //field only has a value in the "to" license.
+	diffChangeRemoved = "removed" //field only has a value in the "from" license.
+	diffChangeChanged = "changed"
+)
+
+// Relationships between the two licenses being compared.
+const (
+	diffRelationshipRenewal  = "renewal"
+	diffRelationshipTransfer = "transfer"
+)
+
+// diffField is one row in the comparison table.
+type diffField struct {
+	Name   string
+	From   string //value in the "from" license, blank if field is not set.
+	To     string //value in the "to" license, blank if field is not set.
+	Change string //see diffChange... constants.
+}
+
+// diffResult is the data returned when comparing two licenses.
+type diffResult struct {
+	FromLicenseID int64
+	ToLicenseID   int64
+
+	//Relationship is how the "to" license was created from the "from" license, if
+	//the licenses are related. This is blank if the licenses are not directly
+	//related.
+	Relationship string
+
+	CommonFields []diffField
+	CustomFields []diffField
+}
+
+// Diff compares two licenses and returns the differences in the common fields and
+// custom field results. All fields are returned, even unchanged fields, so that the
+// GUI can show the licenses side-by-side.
+//
+// Custom field results are matched by the defined field they were saved for, not by
+// name, since a defined field's name can be changed between the two licenses being
+// created.
+func Diff(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	fromID, _ := strconv.ParseInt(r.FormValue("fromID"), 10, 64)
+	toID, _ := strconv.ParseInt(r.FormValue("toID"), 10, 64)
+
+	//Validate.
+	if fromID < 1 || toID < 1 {
+		output.ErrorInputInvalid("You must provide the IDs of the two licenses to compare.", w)
+		return
+	}
+	if fromID == toID {
+		output.ErrorInputInvalid("You must provide two different licenses to compare.", w)
+		return
+	}
+
+	//Look up licenses.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".*",
+		"rrFrom.ToLicenseID AS RenewedToLicenseID",
+		"trFrom.ToLicenseID AS TransferredToLicenseID",
+	}
+	from, err := db.GetLicense(r.Context(), fromID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The from license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up from license.", w)
+		return
+	}
+
+	to, err := db.GetLicense(r.Context(), toID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The to license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up to license.", w)
+		return
+	}
+
+	//Look up custom field results.
+	fromResults, err := db.GetCustomFieldResults(r.Context(), fromID)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields for from license.", w)
+		return
+	}
+
+	toResults, err := db.GetCustomFieldResults(r.Context(), toID)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields for to license.", w)
+		return
+	}
+
+	//Build diff.
+	d := diffResult{
+		FromLicenseID: fromID,
+		ToLicenseID:   toID,
+		CommonFields:  diffCommonFields(from, to),
+		CustomFields:  diffCustomFields(fromResults, toResults),
+	}
+
+	if from.RenewedToLicenseID.Int64 == toID {
+		d.Relationship = diffRelationshipRenewal
+	} else if from.TransferredToLicenseID.Int64 == toID {
+		d.Relationship = diffRelationshipTransfer
+	}
+
+	output.DataFound(d, w)
+}
+
+// diffCommonFields compares the common, non-custom field, details of two licenses.
+func diffCommonFields(from, to db.License) (ff []diffField) {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"App", from.AppName, to.AppName},
+		{"Company Name", from.CompanyName, to.CompanyName},
+		{"Contact Name", from.ContactName, to.ContactName},
+		{"Phone Number", from.PhoneNumber, to.PhoneNumber},
+		{"Email", from.Email, to.Email},
+		{"Issue Date", from.IssueDate, to.IssueDate},
+		{"Expire Date", from.ExpireDate, to.ExpireDate},
+		{"Expire Datetime", from.ExpireDatetime, to.ExpireDatetime},
+		{"Valid From", from.ValidFrom, to.ValidFrom},
+		{"Features", strings.Join(splitFeatures(from.Features), ", "), strings.Join(splitFeatures(to.Features), ", ")},
+		{"Friendly ID", from.FriendlyID, to.FriendlyID},
+		{"Key Pair ID", strconv.FormatInt(from.KeyPairID, 10), strconv.FormatInt(to.KeyPairID, 10)},
+		{"File Format", string(from.FileFormat), string(to.FileFormat)},
+		{"Show License ID", strconv.FormatBool(from.ShowLicenseID), strconv.FormatBool(to.ShowLicenseID)},
+		{"Show App Name", strconv.FormatBool(from.ShowAppName), strconv.FormatBool(to.ShowAppName)},
+		{"Active", strconv.FormatBool(from.Active), strconv.FormatBool(to.Active)},
+	}
+
+	for _, f := range fields {
+		ff = append(ff, diffField{
+			Name:   f.name,
+			From:   f.from,
+			To:     f.to,
+			Change: diffChange(f.from, f.to),
+		})
+	}
+
+	return
+}
+
+// diffCustomFields compares the custom field results of two licenses. Fields are
+// returned in the order of the "from" license's results, followed by any fields only
+// in the "to" license.
+func diffCustomFields(from, to []db.CustomFieldResult) (ff []diffField) {
+	toByDefinedID := make(map[int64]db.CustomFieldResult, len(to))
+	for _, t := range to {
+		toByDefinedID[t.CustomFieldDefinedID] = t
+	}
+
+	for _, f := range from {
+		df := diffField{
+			Name: f.CustomFieldName,
+			From: customFieldResultString(f),
+		}
+
+		if t, ok := toByDefinedID[f.CustomFieldDefinedID]; ok {
+			df.To = customFieldResultString(t)
+			df.Change = diffChange(df.From, df.To)
+			delete(toByDefinedID, f.CustomFieldDefinedID)
+		} else {
+			df.Change = diffChangeRemoved
+		}
+
+		ff = append(ff, df)
+	}
+
+	//Use the "to" results, not the map, to keep the order of the fields.
+	for _, t := range to {
+		if _, ok := toByDefinedID[t.CustomFieldDefinedID]; !ok {
+			continue
+		}
+
+		ff = append(ff, diffField{
+			Name:   t.CustomFieldName,
+			To:     customFieldResultString(t),
+			Change: diffChangeAdded,
+		})
+	}
+
+	return
+}
+
+// diffChange returns how a value changed between two licenses.
+func diffChange(from, to string) string {
+	switch {
+	case from == to:
+		return diffChangeNone
+	case from == "":
+		return diffChangeAdded
+	case to == "":
+		return diffChangeRemoved
+	default:
+		return diffChangeChanged
+	}
+}
+
+// customFieldResultString returns the value of a custom field result, based on the
+// field's type, as a string for displaying.
+func customFieldResultString(f db.CustomFieldResult) string {
+	switch f.CustomFieldType {
+	case db.CustomFieldTypeInteger:
+		return strconv.FormatInt(f.IntegerValue.Int64, 10)
+	case db.CustomFieldTypeDecimal:
+		return strconv.FormatFloat(f.DecimalValue.Float64, 'f', -1, 64)
+	case db.CustomFieldTypeText, db.CustomFieldTypeEmail, db.CustomFieldTypeURL, db.CustomFieldTypeFileHash:
+		return f.TextValue.String
+	case db.CustomFieldTypeBoolean:
+		return strconv.FormatBool(f.BoolValue.Bool)
+	case db.CustomFieldTypeMultiChoice:
+		return f.MultiChoiceValue.String
+	case db.CustomFieldTypeDate:
+		return f.DateValue.String
+	default:
+		//This should never be hit since we validated field types when they were
+		//saved/defined.
+		return ""
+	}
+}
@@ -0,0 +1,120 @@
+package license
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles disabling many licenses at once, for example when a contract
+// with a customer is terminated. Licenses are chosen by company name, app, or both.
+// A filter is always required so that every license cannot be disabled by accident.
+
+// disableBulkResult is the data returned when licenses are disabled in bulk.
+type disableBulkResult struct {
+	Count      int
+	LicenseIDs []int64
+}
+
+// DisableBulk marks each active license matching the provided company name and/or
+// app as inactive. A note explaining why the licenses are being disabled is always
+// required, regardless of the RequireDisableReason app setting, since the same note
+// is saved to each license. All licenses are disabled in a single transaction so
+// either every license is disabled or none are.
+func DisableBulk(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	companyName := strings.TrimSpace(r.FormValue("companyName"))
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+	note := strings.TrimSpace(r.FormValue("note"))
+
+	//Validate.
+	if companyName == "" && appID < 1 {
+		output.ErrorInputInvalid("You must provide a company name, an app, or both to choose which licenses to disable.", w)
+		return
+	}
+	if note == "" {
+		output.ErrorInputInvalid("A reason is required to disable licenses. Please provide a note describing why you are disabling these licenses.", w)
+		return
+	}
+
+	//Look up the licenses to disable.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".CompanyName",
+		db.TableLicenses + ".AppName",
+	}
+	lics, err := db.GetActiveLicensesByFilter(r.Context(), companyName, appID, cols)
+	if err != nil {
+		output.Error(err, "Could not look up licenses to disable.", w)
+		return
+	}
+	if len(lics) == 0 {
+		output.ErrorInputInvalid("No active licenses match the filter provided.", w)
+		return
+	}
+
+	//Get info about who or what is disabling these licenses.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Mark each license as inactive and save a note.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not mark licenses as disabled and save notes (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(lics))
+	for _, l := range lics {
+		err = db.DisableLicense(r.Context(), l.ID, tx)
+		if err != nil {
+			output.Error(err, "Could not mark license "+strconv.FormatInt(l.ID, 10)+" as disabled.", w)
+			return
+		}
+
+		n := db.LicenseNote{
+			LicenseID: l.ID,
+			Note:      note + " (License was disabled in bulk).",
+		}
+		if userID > 0 {
+			n.CreatedByUserID = null.IntFrom(userID)
+		} else if apiKeyID > 0 {
+			n.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+		}
+
+		err = n.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not add note about disabled license "+strconv.FormatInt(l.ID, 10)+".", w)
+			return
+		}
+
+		ids = append(ids, l.ID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not mark licenses as disabled and save notes (2).", w)
+		return
+	}
+
+	for _, l := range lics {
+		notifyLicenseEvent(r.Context(), config.NotificationEventLicenseDisabled, l, userID, apiKeyID)
+	}
+
+	output.UpdateOKWithData(disableBulkResult{
+		Count:      len(ids),
+		LicenseIDs: ids,
+	}, w)
+}
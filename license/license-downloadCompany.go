@@ -0,0 +1,114 @@
+package license
+
+import (
+	"archive/zip"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file specifically deals with downloading all of the licenses for a company at
+// once. This is useful for customers that have many licenses (different apps,
+// renewals) and want all of their license files at the same time.
+
+// DownloadCompany retrieves all active licenses for a company and returns them as a
+// single zip file. Each license file is rebuilt the same way as when a single license
+// is downloaded. Expired and unverified licenses are skipped since they cannot be
+// downloaded individually either.
+//
+// The zip file is written directly to w as each license file is built, rather than
+// building the zip file in memory first, since a company may have many licenses.
+func DownloadCompany(w http.ResponseWriter, r *http.Request) {
+	//Validate.
+	companyName := strings.TrimSpace(r.FormValue("companyName"))
+	if companyName == "" {
+		output.ErrorInputInvalid("You must provide the company name to download licenses for.", w)
+		return
+	}
+
+	//Look up licenses for the company.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Verified",
+		db.LicenseExpiredColumn,
+	}
+	ll, err := db.GetLicensesByCompanyName(r.Context(), companyName, true, cols)
+	if err != nil {
+		output.Error(err, "Could not look up licenses for company.", w)
+		return
+	}
+
+	downloadable := []db.License{}
+	for _, l := range ll {
+		if l.Expired || !l.Verified {
+			continue
+		}
+
+		downloadable = append(downloadable, l)
+	}
+	if len(downloadable) == 0 {
+		output.ErrorInputInvalid("No downloadable licenses exist for this company.", w)
+		return
+	}
+
+	//Determine who is downloading the licenses for saving download history.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Start the zip file. After this point, errors cannot be returned as JSON since
+	//the response has already been started.
+	filename := strings.ReplaceAll(companyName, "\"", "") + " - licenses.zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	usedFilenames := make(map[string]bool, len(downloadable))
+	for _, d := range downloadable {
+		//Build the license file the same as when a single license is downloaded. A
+		//license that can no longer be downloaded, i.e. it was disabled after the
+		//licenses were looked up, is skipped.
+		l, f, errMsg, err := getDownloadableLicense(r.Context(), d.ID)
+		if err != nil {
+			log.Println("license.DownloadCompany", errMsg, d.ID, err)
+			return
+		} else if errMsg != "" {
+			log.Println("license.DownloadCompany", "skipping license", d.ID, errMsg)
+			continue
+		}
+
+		//Determine the filename for this license within the zip. The app's download
+		//filename may not include an identifier for the license, so make sure each
+		//filename is unique to prevent overwriting a license when the zip is
+		//extracted. The public ID is used since, unlike the license ID, it does not
+		//reveal how many licenses have been created.
+		filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+		if usedFilenames[filename] {
+			filename = l.PublicID + "-" + filename
+		}
+		usedFilenames[filename] = true
+
+		//Write the license file to the zip.
+		fw, err := zw.Create(filename)
+		if err != nil {
+			log.Println("license.DownloadCompany", "could not create file in zip", l.ID, err)
+			return
+		}
+
+		err = f.Write(fw)
+		if err != nil {
+			log.Println("license.DownloadCompany", "could not write license to zip", l.ID, err)
+			return
+		}
+
+		saveDownloadHistory(r.Context(), l.ID, userID, apiKeyID)
+	}
+}
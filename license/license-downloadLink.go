@@ -0,0 +1,151 @@
+package license
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles links that customers can use to download their license without
+// logging in. A link is typically emailed to a customer. Each link is signed, using
+// the DownloadLinkSecret from the config file, so that a link cannot be altered to
+// download a different license, and each link expires.
+//
+// A link is scoped to a license and the license's app. A link is only valid for a
+// license that can be downloaded, i.e.: a disabled license cannot be downloaded even
+// if a link was generated before the license was disabled.
+
+// downloadLinkPath is the path to the public handler that serves a license via a
+// download link.
+const downloadLinkPath = "/download-link/"
+
+// downloadLink is the data returned when a download link is generated.
+type downloadLink struct {
+	URL     string
+	Expires string //YYYY-MM-DD HH:MM:SS, UTC.
+}
+
+// CreateDownloadLink generates a signed, expiring link that can be used to download a
+// license without logging in.
+func CreateDownloadLink(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to create a download link for.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	//The link is opened outside of the app, typically from an email, so it must use
+	//the URL the app is reachable at, not the host this request was made to.
+	if config.Data().ExternalURL == "" {
+		output.ErrorInputInvalid("Download links cannot be created because ExternalURL is not set in the config file. Please ask an administrator to set it.", w)
+		return
+	}
+
+	//Make sure the license can be downloaded.
+	l, _, errMsg, err := getDownloadableLicense(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Build the link.
+	lifetime := time.Duration(config.Data().DownloadLinkLifetimeHours) * time.Hour
+	expires := time.Now().UTC().Add(lifetime).Truncate(time.Second)
+
+	q := url.Values{}
+	q.Set("id", strconv.FormatInt(l.ID, 10))
+	q.Set("app", strconv.FormatInt(l.AppID, 10))
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", signDownloadLink(l.ID, l.AppID, expires.Unix()))
+
+	output.InsertOKWithData(downloadLink{
+		URL:     config.Data().AbsoluteURL(downloadLinkPath) + "?" + q.Encode(),
+		Expires: expires.Format("2006-01-02 15:04:05"),
+	}, w)
+}
+
+// signDownloadLink returns the signature of a download link. The signature is an
+// HMAC, using the DownloadLinkSecret from the config file, of the license ID, app ID,
+// and the link's expiration as a unix timestamp.
+func signDownloadLink(licenseID, appID, expires int64) string {
+	msg := strconv.FormatInt(licenseID, 10) + ":" + strconv.FormatInt(appID, 10) + ":" + strconv.FormatInt(expires, 10)
+
+	mac := hmac.New(sha256.New, []byte(config.Data().DownloadLinkSecret))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeDownloadLink validates a download link and, if the link is valid, writes the
+// license file to w. An error message is returned if the link is invalid, or expired,
+// or the license cannot be downloaded, and nothing is written to w.
+//
+// This does not write errors to w so that the caller can show an error page since a
+// download link is opened in a browser by a customer, not called via the API. Once
+// writing the license file has started an error page cannot be shown, so an error
+// writing the license file is only logged.
+func ServeDownloadLink(w http.ResponseWriter, r *http.Request) (errMsg string, err error) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	appID, _ := strconv.ParseInt(r.FormValue("app"), 10, 64)
+	expires, _ := strconv.ParseInt(r.FormValue("expires"), 10, 64)
+	sig := r.FormValue("sig")
+
+	//Validate. The signature is checked before the expiration so that an altered
+	//link is reported as invalid, not expired.
+	if licenseID < 1 || appID < 1 || expires < 1 || sig == "" {
+		errMsg = "This download link is invalid."
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	expected := signDownloadLink(licenseID, appID, expires)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		errMsg = "This download link is invalid."
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		errMsg = "This download link has expired. Please ask for a new link."
+		return
+	}
+
+	//Look up the license and make sure the license is for the app the link was
+	//generated for.
+	l, f, errMsg, err := getDownloadableLicense(r.Context(), licenseID)
+	if err != nil || errMsg != "" {
+		return
+	}
+	if l.AppID != appID {
+		errMsg = "This download link is invalid."
+		return
+	}
+
+	//Save download history. The license wasn't downloaded by a user or API key.
+	saveDownloadHistory(r.Context(), licenseID, 0, 0)
+
+	//Write out the license file.
+	setDownloadFilename(w, l)
+	innerErr := f.Write(w)
+	if innerErr != nil {
+		log.Println("license.ServeDownloadLink", "could not write license", licenseID, innerErr)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,241 @@
+package license
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/reports"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles building the email sent to a customer with their license. Each
+// app can define its own subject and body templates, see db.App, so that resellers
+// can brand the email for each product. Placeholders in the templates are replaced
+// with the license's data. The default templates are used when an app's templates
+// are blank.
+//
+// The templates are validated when an app is saved so that an email is never built
+// with an unknown or malformed placeholder. PreviewEmail() shows the email built for
+// an existing license so that an app's templates can be checked. EmailLicense() sends
+// the email, with the license file attached, using the SMTP settings from the config
+// file.
+
+// licenseEmailData is the subject and body of the email for a license.
+type licenseEmailData struct {
+	Subject string
+	Body    string
+}
+
+// buildLicenseEmail returns the subject and body of the email for a license using
+// the app's templates, or the defaults if the app's templates are blank. The license
+// must have been looked up with its ID, CompanyName, and ExpireDate.
+func buildLicenseEmail(a db.App, l db.License) (e licenseEmailData) {
+	subject := a.LicenseEmailSubject
+	if subject == "" {
+		subject = db.DefaultLicenseEmailSubject
+	}
+
+	body := a.LicenseEmailBody
+	if body == "" {
+		body = db.DefaultLicenseEmailBody
+	}
+
+	r := strings.NewReplacer(
+		db.LicenseEmailPlaceholderCompany, l.CompanyName,
+		db.LicenseEmailPlaceholderAppName, a.Name,
+		db.LicenseEmailPlaceholderExpireDate, l.ExpireDate,
+		db.LicenseEmailPlaceholderLicenseID, strconv.FormatInt(l.ID, 10),
+	)
+
+	//The company name is user provided so line breaks are removed from the subject
+	//since it is used as an email header.
+	e.Subject = strings.Join(strings.Fields(r.Replace(subject)), " ")
+	e.Body = r.Replace(body)
+	return
+}
+
+// PreviewEmail returns the subject and body of the email for a license, as built from
+// the license's app's templates. This is used to check an app's templates with real
+// license data.
+func PreviewEmail(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to preview the email for.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	//Look up the license and app.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".CompanyName",
+		db.TableLicenses + ".ExpireDate",
+		db.TableApps + ".ID AS AppID",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	a, err := db.GetAppByID(r.Context(), l.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
+
+	output.DataFound(buildLicenseEmail(a, l), w)
+}
+
+// EmailLicense emails a license to the license's contact. The email is built from the
+// license's app's templates and the license file is attached. This requires email to
+// be enabled in the config file.
+func EmailLicense(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to email.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	cfg := config.Data()
+	if !cfg.SMTPEnabled() {
+		output.ErrorInputInvalid("Email is not enabled. Set SMTPHost in the config file.", w)
+		return
+	}
+
+	//Look up the license, making sure it can be downloaded since the license file is
+	//attached to the email.
+	l, f, errMsg, err := getDownloadableLicense(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+	if strings.TrimSpace(l.Email) == "" {
+		output.ErrorInputInvalid("This license does not have an email address to send the license to.", w)
+		return
+	}
+
+	a, err := db.GetAppByID(r.Context(), l.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
+
+	//Build and send the email.
+	filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+	msg, err := buildLicenseEmail(a, l).message(cfg.SMTPFrom, l.Email, filename, f)
+	if err != nil {
+		output.Error(err, "Could not build the email.", w)
+		return
+	}
+
+	err = reports.Send(cfg, []string{l.Email}, msg)
+	if err != nil {
+		output.Error(err, "Could not send the email. Please check the SMTP settings in the config file.", w)
+		return
+	}
+
+	//Save download history since the license file was delivered to the customer.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+	saveDownloadHistory(r.Context(), licenseID, userID, apiKeyID)
+
+	output.UpdateOK(w)
+}
+
+// message builds the email, with the license file attached, ready to be sent. The
+// body is quoted-printable encoded since it is user provided and may contain long
+// lines or non-ASCII characters.
+func (e licenseEmailData) message(from, to, filename string, f licensefile.File) (msg []byte, err error) {
+	var license bytes.Buffer
+	err = f.Write(&license)
+	if err != nil {
+		return
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	//Body of the email. Line endings are normalized since the template is entered in
+	//the browser and email requires CRLF.
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return
+	}
+	text := strings.ReplaceAll(strings.ReplaceAll(e.Body, "\r\n", "\n"), "\n", "\r\n")
+	qw := quotedprintable.NewWriter(pw)
+	_, err = qw.Write([]byte(text))
+	if err != nil {
+		return
+	}
+	err = qw.Close()
+	if err != nil {
+		return
+	}
+
+	//License file.
+	pw, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/octet-stream", map[string]string{"name": filename})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return
+	}
+	encoded := base64.StdEncoding.EncodeToString(license.Bytes())
+	for len(encoded) > 76 {
+		fmt.Fprintf(pw, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(pw, "%s\r\n", encoded)
+
+	err = mw.Close()
+	if err != nil {
+		return
+	}
+
+	var m bytes.Buffer
+	fmt.Fprintf(&m, "From: %s\r\n", from)
+	fmt.Fprintf(&m, "To: %s\r\n", to)
+	fmt.Fprintf(&m, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", e.Subject))
+	fmt.Fprintf(&m, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&m, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&m, "Content-Type: multipart/mixed; boundary=%s\r\n", mw.Boundary())
+	fmt.Fprintf(&m, "\r\n")
+	m.Write(body.Bytes())
+
+	return m.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package license
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"testing"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+)
+
+func TestLicenseEmailMessage(t *testing.T) {
+	a := db.App{
+		Name:                "Test App",
+		LicenseEmailSubject: "Your {appName} license for {company}",
+		LicenseEmailBody:    "Hello {company},\nYour license expires on {expireDate}.",
+	}
+	l := db.License{
+		ID:          5,
+		CompanyName: "ACME Dynamite Corp. Ünïcode",
+		ExpireDate:  "2030-01-01",
+	}
+
+	f := licensefile.File{
+		CompanyName: l.CompanyName,
+		ExpireDate:  l.ExpireDate,
+	}
+	f.SetFileFormat(licensefile.FileFormatJSON)
+
+	var license bytes.Buffer
+	err := f.Write(&license)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	msg, err := buildLicenseEmail(a, l).message("licenses@example.com", "coyote@example.com", "license.json", f)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The subject is encoded since it can contain non-ASCII characters.
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if subject != "Your Test App license for ACME Dynamite Corp. Ünïcode" {
+		t.Fatal("unexpected subject", subject)
+		return
+	}
+
+	//The body is built from the template and the license file is attached.
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if string(body) != "Hello ACME Dynamite Corp. Ünïcode,\r\nYour license expires on 2030-01-01." {
+		t.Fatalf("unexpected body %q", body)
+		return
+	}
+
+	p, err = mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p.FileName() != "license.json" {
+		t.Fatal("unexpected attachment filename", p.FileName())
+		return
+	}
+	attached, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !bytes.Equal(attached, license.Bytes()) {
+		t.Fatal("attached license does not match license file")
+		return
+	}
+}
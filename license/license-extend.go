@@ -0,0 +1,239 @@
+package license
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles extending a license's expiration in place. This is used for
+// goodwill extensions where a new license, with a new ID, should not be issued. This
+// differs from renewing a license since no new license is created and no renewal
+// relationship is saved.
+//
+// Since the expiration date is part of the signed data, the license file is rebuilt
+// and re-signed. Previously distributed copies of the license file remain valid until
+// their original expiration date; the customer must download the license again to
+// get the extended expiration.
+
+// extendResult is the data returned when a license's expiration is extended.
+type extendResult struct {
+	LicenseID   int64
+	ExpireDate  string //YYYY-MM-DD
+	Fingerprint string //SHA-256 hash of the signed license file data, see licensefile.File.SigningPayload(), hex encoded.
+}
+
+// Extend updates a license's expiration date, rebuilds and re-signs the license
+// file, and verifies the new signature. The new expiration, new signature, and a note
+// recording the old and new expiration dates are saved in one transaction. Nothing is
+// saved if the re-signed license cannot be verified.
+func Extend(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	newExpireDateStr := strings.TrimSpace(r.FormValue("newExpireDate"))
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to extend.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+	if newExpireDateStr == "" {
+		output.ErrorInputInvalid("You must provide the new expiration date.", w)
+		return
+	}
+	newExpireDate, err := time.Parse("2006-01-02", newExpireDateStr)
+	if err != nil {
+		output.ErrorInputInvalid("You must provide a new expiration date in YYYY-MM-DD format.", w)
+		return
+	}
+	if !newExpireDate.After(time.Now()) {
+		output.ErrorInputInvalid("The new expiration date must be in the future.", w)
+		return
+	}
+	errMsg := db.ValidateMaxLicenseDuration(r.Context(), newExpireDateStr)
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Look up the license's data. The same columns are used as when rebuilding a
+	//license so that the re-signed file matches the file that will be downloaded.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".*",
+		db.TableApps + ".ID AS AppID",
+		db.TableApps + ".Name AS AppName",
+		db.TableApps + ".FileHeaderText AS AppFileHeaderText",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+	if !l.Active {
+		output.ErrorInputInvalid("This license has been disabled and cannot be extended.", w)
+		return
+	}
+
+	existingExpireDate, err := time.Parse("2006-01-02", l.ExpireDate)
+	if err != nil {
+		output.Error(err, "Could not confirm if new expiration date is after existing license's expiration date.", w)
+		return
+	}
+	if !newExpireDate.After(existingExpireDate) {
+		output.ErrorInputInvalid("The new expiration date must be after the license's current expiration date, "+l.ExpireDate+".", w)
+		return
+	}
+
+	cfr, err := db.GetCustomFieldResults(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields for license.", w)
+		return
+	}
+
+	//Get key pair data to sign the license file.
+	kp, err := db.GetKeyPairByID(r.Context(), l.KeyPairID)
+	if err != nil {
+		output.Error(err, "Could not look up signature details.", w)
+		return
+	}
+	errMsg = checkSigningKeyPair(kp, "this license", "extended")
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Set the new expiration. Extended licenses only use a date, not a specific time,
+	//the same as renewed licenses.
+	oldExpiration := l.ExpireDate
+	if l.ExpireDatetime != "" {
+		oldExpiration = l.ExpireDatetime
+	}
+	l.ExpireDate = newExpireDateStr
+	l.ExpireDatetime = ""
+
+	//Build the license file.
+	f, err := buildLicense(l, cfr)
+	if err != nil {
+		output.Error(err, "Could not build license.", w)
+		return
+	}
+
+	//Get the keys to sign the license with. The license is co-signed, the same as
+	//when a license is created, if the app requires more than one signature.
+	a, err := db.GetAppByID(r.Context(), l.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
+
+	keys, errMsg, err := getLicenseSigningKeys(r.Context(), a, kp)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Sign and verify the license file. Unlike when a license is rebuilt, a license
+	//that fails verification is not saved so that the existing, valid, license is
+	//left unchanged.
+	err = keys.sign(&f)
+	if err != nil {
+		output.Error(err, "Could not generate signature.", w)
+		return
+	}
+
+	err = keys.verify(f)
+	if err != nil {
+		output.Error(err, "The extended license could not be verified and was not saved. Please ask an administrator to investigate this error.", w)
+		return
+	}
+
+	//Calculate the fingerprint from the exact bytes that were signed.
+	payload, _, err := f.SigningPayload(kp.AlgorithmType)
+	if err != nil {
+		output.Error(err, "Could not calculate license fingerprint.", w)
+		return
+	}
+	sum := sha256.Sum256(payload)
+
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Save the new expiration, signature, and a note.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save extended license (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = l.SaveExpiration(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save extended license (2).", w)
+		return
+	}
+
+	l.Signature = f.Signature
+	l.Signatures = joinSignatures(f.Signatures)
+	l.SignatureAlgorithm = f.Algorithm
+	l.FormatVersion = f.FormatVersion
+	err = l.SaveSignature(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save extended license (3).", w)
+		return
+	}
+
+	l.Verified = true
+	err = l.SaveVerified(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save extended license (4).", w)
+		return
+	}
+
+	n := db.LicenseNote{
+		LicenseID: licenseID,
+		Note:      "License expiration extended from " + oldExpiration + " to " + l.ExpireDate + ". The license file was re-signed. Fingerprint: " + hex.EncodeToString(sum[:]) + ".",
+	}
+	if userID > 0 {
+		n.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		n.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = n.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about extended license.", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not complete saving of extended license.", w)
+		return
+	}
+
+	output.UpdateOKWithData(extendResult{
+		LicenseID:   licenseID,
+		ExpireDate:  l.ExpireDate,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}, w)
+}
@@ -0,0 +1,73 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+)
+
+// This file handles the features enabled for a license. Features are chosen from the
+// app's catalog of features, see db.AppFeature, and the key of each enabled feature
+// is stored in the license file's Features field.
+
+// parseFeatures parses and validates the list of feature keys provided when creating
+// a license and returns the keys for storing in the database. The raw value is a JSON
+// array of feature keys. Each key must be an active feature of the app. Keys are
+// deduplicated and sorted so that the signed data doesn't depend on the order the
+// features were provided in.
+func parseFeatures(ctx context.Context, appID int64, raw string) (features, errMsg string, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return
+	}
+
+	var keys []string
+	err = json.Unmarshal([]byte(raw), &keys)
+	if err != nil {
+		errMsg = "Could not parse the list of features. Features must be provided as a list of feature keys."
+		return
+	}
+
+	validated := []string{}
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" || slices.Contains(validated, k) {
+			continue
+		}
+
+		_, innerErr := db.GetAppFeatureByKey(ctx, appID, k)
+		if innerErr == sql.ErrNoRows {
+			errMsg = "The feature \"" + k + "\" does not exist for this app."
+			return
+		} else if innerErr != nil {
+			err = innerErr
+			return
+		}
+
+		validated = append(validated, k)
+	}
+
+	slices.Sort(validated)
+	features = joinFeatures(validated)
+	return
+}
+
+// joinFeatures returns the feature keys for storing in the database. This is blank
+// if a license does not have any features enabled.
+func joinFeatures(keys []string) string {
+	return strings.Join(keys, "\n")
+}
+
+// splitFeatures returns the feature keys stored in the database for setting in a
+// license file. This returns nil if a license does not have any features enabled.
+func splitFeatures(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,76 @@
+package license
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/output"
+)
+
+// This file handles heartbeats sent by clients to report that a license is still in
+// use. Sending heartbeats is opt-in, an app's integration chooses whether or not to
+// call the heartbeat endpoint. Only the latest heartbeat is kept per license, so the
+// number of heartbeats sent doesn't grow the database. The last heartbeat is shown
+// with the license's details to help find dormant installs, or installs that are
+// still in use after a license was disabled or expired, which may be cracked.
+//
+// A heartbeat never affects whether a license can be used. Heartbeats are recorded
+// for disabled and expired licenses as well since that is exactly what we want to
+// know about.
+
+// heartbeatResult is the data returned when a heartbeat is recorded.
+type heartbeatResult struct {
+	PublicID      string
+	MachineID     string
+	LastHeartbeat string //yyyy-mm-dd hh:mm:ss, UTC timezone.
+}
+
+// Heartbeat records that a client is using a license.
+func Heartbeat(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	publicID := strings.TrimSpace(r.FormValue("publicID"))
+	machineID := strings.TrimSpace(r.FormValue("machineID")) //optional
+
+	//Validate.
+	if publicID == "" {
+		output.ErrorInputInvalid("Could not determine which license you are sending a heartbeat for.", w)
+		return
+	}
+	if len(machineID) > db.LicenseHeartbeatMachineIDMaxLength {
+		output.ErrorInputInvalid("The machine ID must be at most "+strconv.Itoa(db.LicenseHeartbeatMachineIDMaxLength)+" characters.", w)
+		return
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	l, errMsg, err := getPublicLicense(r.Context(), publicID, apiKeyID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Save.
+	l.LastHeartbeat = timestamps.YMDHMS()
+	l.LastHeartbeatMachineID = machineID
+	err = l.SaveHeartbeat(r.Context())
+	if err != nil {
+		output.Error(err, "Could not record heartbeat.", w)
+		return
+	}
+
+	output.DataFound(heartbeatResult{
+		PublicID:      publicID,
+		MachineID:     machineID,
+		LastHeartbeat: l.LastHeartbeat,
+	}, w)
+}
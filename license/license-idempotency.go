@@ -0,0 +1,148 @@
+package license
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles idempotency keys used when creating a license via the public API.
+// A network error can cause a client to retry a request that already created a
+// license, creating a duplicate license. A client can provide a unique value in the
+// Idempotency-Key header, and reuse the same value when retrying, so that the license
+// created by the first request is returned instead of creating a new license.
+//
+// Keys are scoped per API key, two API keys can use the same key without conflict.
+// Keys are only remembered for the time set in the config file, after which the same
+// key will create a new license.
+//
+// Keys are unique per API key in the database. If two requests with the same key are
+// handled at the same time, both pass the check in replayIdempotentAdd() but only one
+// can save its license. The other request fails with a unique constraint error and
+// then replays the license saved by the first request.
+
+// idempotencyKeyHeader is the header a client provides the idempotency key in.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader is set on the response when the original license is
+// returned for a repeated request.
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// How long, and how often, to look up the license saved by a concurrent request with
+// the same idempotency key. The license is only returned once it has been verified,
+// which happens just after it is saved.
+const (
+	idempotencyConflictWait     = 5 * time.Second
+	idempotencyConflictInterval = 100 * time.Millisecond
+)
+
+// getIdempotencyKey returns the idempotency key provided with a request. Blank is
+// returned if a key was not provided.
+func getIdempotencyKey(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+}
+
+// idempotencyKeyCreatedSince returns the yyyy-mm-dd hh:mm:ss UTC timestamp of the
+// start of the retention window. Keys used before this are no longer remembered.
+func idempotencyKeyCreatedSince() string {
+	retention := time.Duration(config.Data().APIIdempotencyKeyRetentionHours) * time.Hour
+	return time.Now().UTC().Add(-retention).Format("2006-01-02 15:04:05")
+}
+
+// replayIdempotentAdd checks if a license was already created by the API key with the
+// request's idempotency key. If so, the response that created the license is repeated
+// and true is returned so that a new license is not created. False is returned if a
+// key wasn't provided or the key hasn't been seen within the retention window.
+//
+// If an error occurs, the error is written to the response and true is returned
+// since a response has been written.
+func replayIdempotentAdd(w http.ResponseWriter, r *http.Request) (handled bool) {
+	key := getIdempotencyKey(r)
+	if key == "" {
+		return false
+	}
+
+	if len(key) > db.LicenseIdempotencyKeyMaxLength {
+		output.ErrorInputInvalid("The "+idempotencyKeyHeader+" header must be at most "+strconv.Itoa(db.LicenseIdempotencyKeyMaxLength)+" characters.", w)
+		return true
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return true
+	}
+	if apiKeyID < 1 {
+		return false
+	}
+
+	//Look up a license created with this key, within the retention window.
+	original, err := db.GetLicenseByIdempotencyKey(r.Context(), apiKeyID, key, idempotencyKeyCreatedSince())
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		output.Error(err, "Could not look up license by "+idempotencyKeyHeader+".", w)
+		return true
+	}
+
+	//Repeat the response, the same as Add(), using the original license.
+	db.SetActivityLogLicenseID(r.Context(), original.ID)
+	w.Header().Set(idempotentReplayedHeader, "true")
+
+	if r.FormValue("returnLicenseFile") == "true" {
+		l, f, errMsg, err := getDownloadableLicense(r.Context(), original.ID)
+		if err != nil {
+			output.Error(err, errMsg, w)
+			return true
+		} else if errMsg != "" {
+			output.ErrorInputInvalid(errMsg, w)
+			return true
+		}
+
+		filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+		w.Header().Add("Content-Disposition", "inline; filename=\""+filename+"\"")
+
+		err = f.Write(w)
+		if err != nil {
+			output.Error(err, "Could not return license file.", w)
+			return true
+		}
+
+		saveDownloadHistory(r.Context(), l.ID, 0, apiKeyID)
+		return true
+	}
+
+	output.InsertOK(original.ID, w)
+	return true
+}
+
+// replayConflictingIdempotentAdd replays the license saved by a concurrent request
+// with the same idempotency key. This is called when saving a license failed because
+// another request already saved a license with the key. Since the other request may
+// not have verified its license yet, the lookup is repeated for a short time.
+//
+// False is returned, and nothing is written to the response, if the other request's
+// license was not found.
+func replayConflictingIdempotentAdd(w http.ResponseWriter, r *http.Request) (handled bool) {
+	deadline := time.Now().Add(idempotencyConflictWait)
+	for {
+		if replayIdempotentAdd(w, r) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(idempotencyConflictInterval):
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package license
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles importing licenses that were created by another system, for
+// example when migrating to this app. Each imported license includes the signature
+// that was generated by the other system. The signature is not regenerated, instead
+// the signature is checked with the public key of the referenced key pair. This
+// requires that the key pair used by the other system was imported into this app
+// first.
+//
+// Licenses that fail verification are still saved, but are not marked as verified,
+// so that they cannot be downloaded and can be investigated. A note is added to
+// each of these licenses.
+//
+// Licenses are saved one at a time, but verified concurrently using the signing pool
+// since verifying is the slow part of importing a large batch of licenses.
+//
+// Note that if an app is set to show the license ID in license files, imported
+// licenses will fail verification since the license ID assigned by this app will not
+// match the license ID that was signed by the other system.
+
+// importRecord is the data provided for each license being imported.
+type importRecord struct {
+	KeyPairID      int64
+	CompanyName    string
+	ContactName    string
+	PhoneNumber    string
+	Email          string
+	IssueDate      string //yyyy-mm-dd
+	IssueTimestamp int64  //unix timestamp in seconds
+	ExpireDate     string //yyyy-mm-dd
+	ExpireDatetime string //optional, RFC3339 in UTC.
+	ValidFrom      string //optional, yyyy-mm-dd
+	Features       []string
+	Signature      string
+
+	//Algorithm and FormatVersion are provided if the license was signed with them
+	//set, since they are part of the signed data. They are blank for licenses signed
+	//before these fields were added to the license file.
+	Algorithm     licensefile.KeyPairAlgoType
+	FormatVersion int
+
+	//SchemaVersion is provided if the license was signed with a license schema
+	//version, since it is part of the signed data.
+	SchemaVersion int
+
+	//Fields are the custom field results, keyed by field name, that were signed
+	//with the license. Only the fields provided are saved since adding default
+	//values for missing fields would change the signed data.
+	Fields map[string]interface{}
+}
+
+// importResult is the result of importing each license. Error is set if the license
+// could not be saved. A license that was saved but failed verification will have a
+// LicenseID and Verified set to false.
+type importResult struct {
+	Index     int //position of the record in the provided list.
+	LicenseID int64
+	Verified  bool
+	Error     string
+}
+
+// importedLicense is a license that was saved and is waiting to be verified.
+type importedLicense struct {
+	index   int //position of the record in the provided list.
+	license db.License
+	file    licensefile.File
+	keyPair db.KeyPair
+}
+
+// Import saves a batch of licenses that were created by another system. Each license
+// is saved separately so that one bad record does not prevent the other licenses
+// from being imported.
+func Import(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	rawLicenses := r.FormValue("licenses")
+	var records []importRecord
+	err := json.Unmarshal([]byte(rawLicenses), &records)
+	if err != nil {
+		output.Error(err, "Could not parse licenses to import.", w)
+		return
+	}
+
+	//Validate.
+	if len(records) == 0 {
+		output.ErrorInputInvalid("You must provide at least one license to import.", w)
+		return
+	}
+
+	//Get info about who is importing these licenses.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Require the user to provide their 2FA token again, if needed, since importing
+	//licenses creates licenses.
+	if !checkLicenseCreationStepUp(w, r, userID) {
+		return
+	}
+
+	//Save each license.
+	results := make([]importResult, len(records))
+	imported := make([]importedLicense, 0, len(records))
+	for i, rec := range records {
+		results[i].Index = i
+
+		imp, errMsg, err := importLicense(r, rec, userID, apiKeyID)
+		if err != nil || errMsg != "" {
+			results[i].Error = importErrorString(errMsg, err)
+			continue
+		}
+
+		imp.index = i
+		imported = append(imported, imp)
+	}
+
+	//Verify the provided signatures, the same as when a license is created.
+	verifyErrs, _ := getSigningPool().run(len(imported), func(i int) error {
+		imp := imported[i]
+		return writeReadVerify(imp.file, imp.keyPair.AlgorithmType, []byte(imp.keyPair.PublicKey))
+	})
+
+	//Mark each license as verified, or add a note explaining why it isn't.
+	for i, imp := range imported {
+		res := &results[imp.index]
+		res.LicenseID = imp.license.ID
+
+		errMsg, err := finishImport(r, imp, verifyErrs[i])
+		if err != nil || errMsg != "" {
+			res.Error = importErrorString(errMsg, err)
+			continue
+		}
+
+		res.Verified = true
+	}
+
+	output.InsertOKWithData(results, w)
+}
+
+// importErrorString combines the errMsg and err returned when importing a license
+// into the error returned for the license.
+func importErrorString(errMsg string, err error) string {
+	if err != nil && errMsg != "" {
+		return errMsg + " " + err.Error()
+	} else if err != nil {
+		return err.Error()
+	}
+
+	return errMsg
+}
+
+// importLicense validates and saves one imported license and builds the license
+// file for verifying the provided signature. An errMsg is returned if the provided
+// data is invalid, err is returned for any other errors.
+func importLicense(r *http.Request, rec importRecord, userID, apiKeyID int64) (imp importedLicense, errMsg string, err error) {
+	//Validate.
+	rec.CompanyName = strings.TrimSpace(rec.CompanyName)
+	rec.Signature = strings.TrimSpace(rec.Signature)
+	if rec.KeyPairID < 1 {
+		errMsg = "You must provide the key pair used to sign this license."
+		return
+	}
+	if rec.CompanyName == "" {
+		errMsg = "You must provide the company name for which this license is for."
+		return
+	}
+	if rec.Signature == "" {
+		errMsg = "You must provide the license's signature."
+		return
+	}
+	if _, innerErr := time.Parse("2006-01-02", rec.IssueDate); innerErr != nil {
+		errMsg = "The issue date must be provided in YYYY-MM-DD format."
+		return
+	}
+	if rec.ExpireDatetime != "" {
+		expDatetime, innerErr := time.Parse(time.RFC3339, rec.ExpireDatetime)
+		if innerErr != nil {
+			errMsg = "The expiration time must be provided in RFC3339 format (YYYY-MM-DDTHH:MM:SSZ)."
+			return
+		}
+		rec.ExpireDatetime = expDatetime.UTC().Format(time.RFC3339)
+		rec.ExpireDate = expDatetime.UTC().Format("2006-01-02")
+	}
+	if _, innerErr := time.Parse("2006-01-02", rec.ExpireDate); innerErr != nil {
+		errMsg = "The expiration date must be provided in YYYY-MM-DD format."
+		return
+	}
+	if rec.ValidFrom != "" {
+		if _, innerErr := time.Parse("2006-01-02", rec.ValidFrom); innerErr != nil {
+			errMsg = "The valid from date must be provided in YYYY-MM-DD format."
+			return
+		}
+	}
+
+	if rec.FormatVersion < 0 || rec.FormatVersion > licensefile.CurrentFormatVersion {
+		errMsg = "The format version is not supported."
+		return
+	}
+
+	if rec.SchemaVersion < 0 {
+		errMsg = "The schema version cannot be negative."
+		return
+	}
+
+	//Get key pair data. We need this to look up the app and to verify the provided
+	//signature.
+	kp, err := db.GetKeyPairByID(r.Context(), rec.KeyPairID)
+	if err == sql.ErrNoRows {
+		return imp, "The key pair ID provided does not exist.", nil
+	} else if err != nil {
+		errMsg = "Could not look up key pair."
+		return
+	}
+	if rec.Algorithm != "" && rec.Algorithm != kp.AlgorithmType {
+		errMsg = "The algorithm does not match the key pair's algorithm."
+		return
+	}
+
+	//Get app data. We need this for the file format and what is shown in the
+	//license file.
+	a, err := db.GetAppByID(r.Context(), kp.AppID)
+	if err != nil {
+		errMsg = "Could not look up app details this license is for."
+		return
+	}
+
+	//Make sure the API key, if licenses are being imported via the API, is allowed
+	//to create licenses for this app.
+	if apiKeyID > 0 {
+		allowed, innerErr := apiKeyAllowedApp(r.Context(), apiKeyID, a.ID)
+		if innerErr != nil {
+			err = innerErr
+			errMsg = "Could not determine if this API key can create licenses for this app."
+			return
+		} else if !allowed {
+			errMsg = "This API key is not allowed to create licenses for this app."
+			return
+		}
+	}
+
+	//Build the custom field results from the provided fields. Each provided field
+	//must be defined for the app.
+	definedFields, err := db.GetCustomFieldsDefined(r.Context(), a.ID, true)
+	if err != nil {
+		errMsg = "Could not look up app's custom fields."
+		return
+	}
+
+	var fields db.MultiCustomFieldResult
+	for name, value := range rec.Fields {
+		found := false
+		for _, definedField := range definedFields {
+			if definedField.Name != name {
+				continue
+			}
+
+			c := db.CustomFieldResult{
+				CustomFieldDefinedID: definedField.ID,
+				CustomFieldType:      definedField.Type,
+				CustomFieldName:      definedField.Name,
+			}
+			if !setCustomFieldResultValue(&c, definedField, value) {
+				errMsg = "The value for the " + name + " field is not the correct type."
+				return
+			}
+
+			fields = append(fields, c)
+			found = true
+			break
+		}
+
+		if !found {
+			errMsg = "The " + name + " field is not defined for this app."
+			return
+		}
+	}
+
+	//Build the license. The app related data is copied from the app, the same as
+	//when a license is created.
+	datetimeCreated := timestamps.YMDHMS()
+	l := db.License{
+		DatetimeCreated: datetimeCreated,
+		KeyPairID:       kp.ID,
+		CompanyName:     rec.CompanyName,
+		ContactName:     strings.TrimSpace(rec.ContactName),
+		PhoneNumber:     strings.TrimSpace(rec.PhoneNumber),
+		Email:           strings.TrimSpace(rec.Email),
+		IssueDate:       rec.IssueDate,
+		IssueTimestamp:  rec.IssueTimestamp,
+		ExpireDate:      rec.ExpireDate,
+		ExpireDatetime:  rec.ExpireDatetime,
+		ValidFrom:       rec.ValidFrom,
+		Features:        joinFeatures(rec.Features),
+		Signature:       rec.Signature,
+		Imported:        true,
+
+		SignatureAlgorithm: rec.Algorithm,
+		FormatVersion:      rec.FormatVersion,
+		SchemaVersion:      rec.SchemaVersion,
+
+		AppName:           a.Name,
+		AppFileHeaderText: a.FileHeaderText,
+		FileFormat:        a.FileFormat,
+		ShowLicenseID:     a.ShowLicenseID,
+		ShowAppName:       a.ShowAppName,
+	}
+	if userID > 0 {
+		l.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		l.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	//Start transaction since we are saving multiple things.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		errMsg = "Could not save imported license (1)."
+		return
+	}
+	defer tx.Rollback()
+
+	err = l.Insert(r.Context(), tx)
+	if err != nil {
+		errMsg = "Could not save imported license (2)."
+		return
+	}
+
+	for _, f := range fields {
+		f.CreatedByUserID = l.CreatedByUserID
+		f.CreatedByAPIKeyID = l.CreatedByAPIKeyID
+		f.LicenseID = l.ID
+		f.DatetimeCreated = datetimeCreated
+
+		err = f.Insert(r.Context(), tx)
+		if err != nil {
+			errMsg = "Could not save field \"" + f.CustomFieldName + "\" therefore license could not be saved."
+			return
+		}
+	}
+
+	err = l.SaveSignature(r.Context(), tx)
+	if err != nil {
+		errMsg = "Could not save signature."
+		return
+	}
+
+	//Build the license file, with the provided signature, for verifying.
+	f, err := buildLicense(l, fields)
+	if err != nil {
+		errMsg = "Could not build license for verification."
+		return
+	}
+	f.Signature = l.Signature
+
+	err = tx.Commit()
+	if err != nil {
+		errMsg = "Could not complete saving of imported license."
+		return
+	}
+
+	imp.license = l
+	imp.file = f
+	imp.keyPair = kp
+	return
+}
+
+// finishImport marks an imported license as verified or, if the license's signature
+// could not be verified, adds a note to the license explaining why.
+func finishImport(r *http.Request, imp importedLicense, verifyErr error) (errMsg string, err error) {
+	l := imp.license
+
+	if verifyErr != nil {
+		//Flag the license with a note explaining why it is not verified.
+		note := "Imported license's signature could not be verified with the public key."
+		if verifyErr != licensefile.ErrBadSignature {
+			note = "Imported license could not be verified: " + verifyErr.Error()
+		}
+
+		n := db.LicenseNote{
+			LicenseID:         l.ID,
+			Note:              note,
+			CreatedByUserID:   l.CreatedByUserID,
+			CreatedByAPIKeyID: l.CreatedByAPIKeyID,
+		}
+		err = n.Insert(r.Context(), nil)
+		if err != nil {
+			errMsg = "Signature could not be verified. Could not add note about failed verification."
+			return
+		}
+
+		return "Signature could not be verified.", nil
+	}
+
+	//Mark the license as verified.
+	l.Verified = true
+	err = l.MarkVerified(r.Context())
+	if err != nil {
+		errMsg = "Could not mark license as valid."
+		return
+	}
+
+	recordLicenseCreated(l.CreatedByAPIKeyID.Int64)
+
+	return "", nil
+}
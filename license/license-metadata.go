@@ -0,0 +1,59 @@
+package license
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles updating a license's internal reference data, such as an order
+// or quote number, used to cross-reference a license with other systems. This data is
+// never included in the license file, so it can be changed at any time without
+// re-signing the license.
+
+// UpdateMetadata saves a license's order reference and internal notes.
+func UpdateMetadata(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("licenseID"), 10, 64)
+	l := db.License{
+		ID:             licenseID,
+		OrderReference: r.FormValue("orderReference"),
+		InternalNotes:  r.FormValue("internalNotes"),
+	}
+
+	//Validate.
+	if l.ID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to update.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), l.ID)
+
+	errMsg := l.ValidateMetadata()
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	_, err := db.GetLicense(r.Context(), l.ID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	//Save.
+	err = l.SaveMetadata(r.Context())
+	if err != nil {
+		output.Error(err, "Could not save order reference and internal notes.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/output"
@@ -25,6 +27,7 @@ func Notes(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("The license ID provided is invalid.", w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
 
 	cols := sqldb.Columns{db.TableLicenses + ".Active"}
 	_, err := db.GetLicense(r.Context(), licenseID, cols)
@@ -36,9 +39,15 @@ func Notes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Look up the notes.
+	//Look up the notes, optionally for a single category.
+	category := strings.ToLower(strings.TrimSpace(r.FormValue("category")))
+	if category != "" && !slices.Contains(config.Data().LicenseNoteCategories, category) {
+		output.ErrorInputInvalid("The category provided is not valid.", w)
+		return
+	}
+
 	orderBy := "ORDER BY " + db.TableLicenseNotes + ".DatetimeCreated DESC"
-	hh, err := db.GetNotes(r.Context(), licenseID, orderBy)
+	hh, err := db.GetNotes(r.Context(), licenseID, category, orderBy)
 	if err != nil {
 		output.Error(err, "Could not look up license notes.", w)
 		return
@@ -60,19 +69,6 @@ func AddNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Sanitize.
-	n.Note = strings.TrimSpace(n.Note)
-
-	//Validate.
-	if n.LicenseID < 1 {
-		output.ErrorInputInvalid("Could not determine which license you want to add a note for.", w)
-		return
-	}
-	if n.Note == "" {
-		output.ErrorInputInvalid("You must provide a note.", w)
-		return
-	}
-
 	//Get user who is adding this note.
 	loggedInUserID, err := users.GetUserIDFromRequest(r)
 	if err != nil {
@@ -81,6 +77,15 @@ func AddNote(w http.ResponseWriter, r *http.Request) {
 	}
 	n.CreatedByUserID = null.IntFrom(loggedInUserID)
 
+	//Validate. A category is optional, notes without a category are given the
+	//uncategorized category.
+	errMsg := n.Validate()
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), n.LicenseID)
+
 	//Save.
 	err = n.Insert(r.Context(), nil)
 	if err != nil {
@@ -0,0 +1,487 @@
+package license
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/version"
+)
+
+// This file serves an OpenAPI 3 document describing the public API. This allows
+// partners integrating with the public API to generate clients rather than building
+// requests from the help documentation.
+//
+// The document is handwritten, below, rather than generated from the handlers. When
+// a public API endpoint is added or changed, this document must be updated to match.
+// The list of endpoints must match the list in main.go and middleware-externalAPI.go.
+
+// openAPIDocument is the root of an OpenAPI 3 document. Only the parts of the
+// specification needed to describe the public API are defined.
+type openAPIDocument struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       openAPIInfo                     `json:"info"`
+	Servers    []openAPIServer                 `json:"servers"`
+	Security   []map[string][]string           `json:"security"`
+	Paths      map[string]map[string]openAPIOp `json:"paths"`
+	Components openAPIComponents               `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+// openAPIOp is an operation, an HTTP method, on a path.
+type openAPIOp struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+// openAPIParameter is a query string or header parameter.
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string        `json:"description"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+// openAPISchema describes a value. Ref is used to refer to a schema defined in the
+// document's components.
+type openAPISchema struct {
+	Ref         string                   `json:"$ref,omitempty"`
+	Type        string                   `json:"type,omitempty"`
+	Format      string                   `json:"format,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]openAPISchema `json:"properties,omitempty"`
+	Items       *openAPISchema           `json:"items,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+	AllOf       []openAPISchema          `json:"allOf,omitempty"`
+	OneOf       []openAPISchema          `json:"oneOf,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description"`
+}
+
+// OpenAPI serves the OpenAPI document describing the public API. This is accessible
+// without an API key so that the document can be fetched by code generation tools.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(buildOpenAPIDocument(config.Data().BaseURLPath))
+}
+
+// buildOpenAPIDocument builds the OpenAPI document for the public API. basePath is
+// the path the app is served under, if the app is served behind a proxy at a subpath.
+func buildOpenAPIDocument(basePath string) (d openAPIDocument) {
+	d = openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "License Keys Public API",
+			Description: "Create and manage licenses from other software tools. The public API must be enabled in the App Settings and requests must provide an API key.",
+			Version:     version.V,
+		},
+		Servers: []openAPIServer{
+			{URL: basePath + "/api/v1"},
+		},
+		Security: []map[string][]string{
+			{"apiKey": {}},
+		},
+		Paths: map[string]map[string]openAPIOp{
+			"/licenses/add/": {
+				"post": {
+					OperationID: "addLicense",
+					Summary:     "Create a license",
+					Description: "Create a new license for an app. One of appID, keyPairID, or templateID must be provided. If the app ID is provided, the app's default key pair is used.",
+					Parameters: []openAPIParameter{
+						{Name: "Idempotency-Key", In: "header", Description: "A unique value, such as a UUID, identifying this request. If a request with the same value was already made with this API key recently, the license created by that request is returned, with the Idempotent-Replayed header set, instead of creating a new license. Reuse the same value when retrying a request.", Schema: openAPISchema{Type: "string"}},
+					},
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"appID":             {Type: "integer", Format: "int64", Description: "The app to create a license for, the default key pair for this app will be used."},
+							"keyPairID":         {Type: "integer", Format: "int64", Description: "The key pair to create the license with, overrides appID."},
+							"templateID":        {Type: "integer", Format: "int64", Description: "A license template to fill in the app, key pair, expiration, custom fields, and features from. Anything else provided overrides the template."},
+							"companyName":       {Type: "string", Description: "The name of the company you are creating the license for."},
+							"contactName":       {Type: "string", Description: "The name of the person you are creating the license for."},
+							"phoneNumber":       {Type: "string", Description: "The company and/or contact's phone number."},
+							"email":             {Type: "string", Description: "The company and/or contact's email address."},
+							"expireDate":        {Type: "string", Format: "date", Description: "The date the license will expire, in YYYY-MM-DD format. If not provided, the app's default license period is added to today's date."},
+							"expireDatetime":    {Type: "string", Format: "date-time", Description: "The exact time the license will expire, in RFC3339 format. Overrides expireDate."},
+							"validFrom":         {Type: "string", Format: "date", Description: "The date the license can start being used, in YYYY-MM-DD format. Must be before the expiration date."},
+							"fields":            {Type: "string", Description: "A URL encoded JSON object of custom field names and values. Fields not provided use the template's value, if a template is used, or the field's default value."},
+							"features":          {Type: "string", Description: "A JSON array of the keys of the features to enable in the license."},
+							"orderReference":    {Type: "string", Description: "An internal order or quote number. Not included in the license file."},
+							"internalNotes":     {Type: "string", Description: "Internal notes about the license. Not included in the license file."},
+							"returnLicenseFile": {Type: "boolean", Description: "If true, the license file is returned instead of the new license's ID."},
+						},
+						Required: []string{"companyName", "contactName", "phoneNumber", "email"},
+					}),
+					Responses: licenseIDOrFileResponses("The new license's ID, or the license file if returnLicenseFile is true."),
+				},
+			},
+			"/licenses/download/": {
+				"get": {
+					OperationID: "downloadLicense",
+					Summary:     "Download a license file",
+					Parameters: []openAPIParameter{
+						{Name: "id", In: "query", Description: "The ID of the license to download.", Required: true, Schema: openAPISchema{Type: "integer", Format: "int64"}},
+						{Name: "detached", In: "query", Description: "Return only the license's signature, with the algorithm and key ID needed to verify it, as its own file.", Schema: openAPISchema{Type: "boolean"}},
+						{Name: "payload", In: "query", Description: "Return only the license's data that was signed, without the signature, for verifying with a detached signature.", Schema: openAPISchema{Type: "boolean"}},
+						{Name: "encoding", In: "query", Description: "Set to compact to return the license file gzipped and base64 encoded on a single line, decode with licensefile.FromCompact().", Schema: openAPISchema{Type: "string"}},
+					},
+					Responses: map[string]openAPIResponse{
+						"200":     licenseFileResponse("The license file, compact license file, or the detached signature or payload if requested."),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/renew/": {
+				"post": {
+					OperationID: "renewLicense",
+					Summary:     "Renew a license",
+					Description: "Copy an existing license's data to a new license with a further in-the-future expiration date.",
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"id":                {Type: "integer", Format: "int64", Description: "The ID of the license to renew."},
+							"newExpireDate":     {Type: "string", Format: "date", Description: "The date the new license will expire, in YYYY-MM-DD format."},
+							"fields":            {Type: "string", Description: "A URL encoded JSON object of custom field names and values to override the existing license's values."},
+							"returnLicenseFile": {Type: "boolean", Description: "If true, the license file is returned instead of the new license's ID."},
+						},
+						Required: []string{"id", "newExpireDate"},
+					}),
+					Responses: licenseIDOrFileResponses("The new license's ID, or the license file if returnLicenseFile is true."),
+				},
+			},
+			"/licenses/disable/": {
+				"post": {
+					OperationID: "disableLicense",
+					Summary:     "Disable a license",
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"id":   {Type: "integer", Format: "int64", Description: "The ID of the license to disable."},
+							"note": {Type: "string", Description: "Why the license is being disabled. Required if the RequireDisableReason App Setting is enabled."},
+						},
+						Required: []string{"id"},
+					}),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The license was disabled.", openAPISchema{Ref: "#/components/schemas/Response"}),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/checkout/": {
+				"post": {
+					OperationID: "checkOutSeat",
+					Summary:     "Check out a license seat",
+					Description: "Claim, or renew the lease of, a seat of a floating license.",
+					RequestBody: formBody(seatRequestSchema()),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The seat check out result.", dataSchema("#/components/schemas/SeatResult")),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/checkin/": {
+				"post": {
+					OperationID: "checkInSeat",
+					Summary:     "Check in a license seat",
+					Description: "Release a seat of a floating license so another client can check it out.",
+					RequestBody: formBody(seatRequestSchema()),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The seat check in result.", dataSchema("#/components/schemas/SeatResult")),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/activate/": {
+				"post": {
+					OperationID: "activateLicense",
+					Summary:     "Activate a license",
+					Description: "Record the activation of a license on a machine. Activations from new machines are rejected once the license's maximum number of activations is reached.",
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"publicID":  {Type: "string", Description: "The public ID of the license to activate."},
+							"machineID": {Type: "string", Description: "An identifier for the machine."},
+						},
+						Required: []string{"publicID", "machineID"},
+					}),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The activation result.", dataSchema("#/components/schemas/ActivationResult")),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/heartbeat/": {
+				"post": {
+					OperationID: "licenseHeartbeat",
+					Summary:     "Send a license heartbeat",
+					Description: "Record that a client is using a license. Only the latest heartbeat is kept. This does not affect whether the license can be used.",
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"publicID":  {Type: "string", Description: "The public ID of the license."},
+							"machineID": {Type: "string", Description: "An optional identifier for the machine."},
+						},
+						Required: []string{"publicID"},
+					}),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The recorded heartbeat.", dataSchema("#/components/schemas/HeartbeatResult")),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+			"/licenses/status-batch/": {
+				"post": {
+					OperationID: "licenseStatusBatch",
+					Summary:     "Look up the status of many licenses",
+					Description: "Return the state of up to " + strconv.Itoa(statusBatchMaxSize) + " licenses at once. No customer details are returned. A license that does not exist, or that the API key cannot access, is returned with Found set to false.",
+					RequestBody: formBody(openAPISchema{
+						Type: "object",
+						Properties: map[string]openAPISchema{
+							"publicIDs": {Type: "string", Description: "A JSON array of the public IDs of the licenses."},
+						},
+						Required: []string{"publicIDs"},
+					}),
+					Responses: map[string]openAPIResponse{
+						"200":     jsonResponse("The status of each license, in the order the public IDs were provided.", dataSchema("#/components/schemas/LicenseStatusList")),
+						"503":     maintenanceResponse(),
+						"default": errorResponse(),
+					},
+				},
+			},
+		},
+		Components: openAPIComponents{
+			Schemas: map[string]openAPISchema{
+				"Response": {
+					Type:        "object",
+					Description: "All JSON responses use this format.",
+					Properties: map[string]openAPISchema{
+						"OK":        {Type: "boolean", Description: "False if an error occurred."},
+						"Type":      {Type: "string", Description: "A short description of the response, \"error\" if OK is false."},
+						"Datetime":  {Type: "string", Format: "date-time", Description: "When the response was generated."},
+						"Data":      {Description: "The returned data, format is dependent on the endpoint."},
+						"ErrorData": {Ref: "#/components/schemas/ErrorData"},
+					},
+					Required: []string{"OK", "Type", "Datetime"},
+				},
+				"ErrorData": {
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"Error":   {Type: "string", Description: "The error that occurred."},
+						"Message": {Type: "string", Description: "A human readable description of the error."},
+					},
+				},
+				"SeatResult": {
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"PublicID":     {Type: "string"},
+						"ClientID":     {Type: "string"},
+						"CheckedOut":   {Type: "boolean", Description: "True if the client holds a seat after this request."},
+						"InUse":        {Type: "integer", Format: "int64", Description: "The number of seats in use."},
+						"MaxSeats":     {Type: "integer", Format: "int64", Description: "The maximum number of seats."},
+						"LeaseExpires": {Type: "string", Description: "When the client's seat will be reclaimed unless renewed, YYYY-MM-DD HH:MM:SS in UTC."},
+					},
+				},
+				"ActivationResult": {
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"PublicID":        {Type: "string"},
+						"MachineID":       {Type: "string"},
+						"ActivationCount": {Type: "integer", Format: "int64", Description: "The number of times the license has been activated on this machine."},
+						"Activations":     {Type: "integer", Format: "int64", Description: "The number of machines the license has been activated on."},
+						"MaxActivations":  {Type: "integer", Format: "int64", Description: "The maximum number of machines, 0 if unlimited."},
+					},
+				},
+				"HeartbeatResult": {
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"PublicID":      {Type: "string"},
+						"MachineID":     {Type: "string"},
+						"LastHeartbeat": {Type: "string", Description: "When the heartbeat was recorded, YYYY-MM-DD HH:MM:SS in UTC."},
+					},
+				},
+				"LicenseStatus": {
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"PublicID":            {Type: "string"},
+						"Found":               {Type: "boolean", Description: "False if the license does not exist or the API key cannot access it."},
+						"Active":              {Type: "boolean", Description: "False if the license was disabled."},
+						"Expired":             {Type: "boolean"},
+						"ExpireDate":          {Type: "string", Format: "date", Description: "YYYY-MM-DD in UTC."},
+						"DaysUntilExpiration": {Type: "integer", Description: "Whole days until the license expires, negative once expired."},
+					},
+				},
+				"LicenseStatusList": {
+					Type:  "array",
+					Items: &openAPISchema{Ref: "#/components/schemas/LicenseStatus"},
+				},
+			},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"apiKey": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "An API key, created in the app, provided in the Authorization header using the Bearer scheme.",
+				},
+			},
+		},
+	}
+
+	return
+}
+
+// formBody returns a request body for an endpoint that accepts form encoded data.
+func formBody(s openAPISchema) *openAPIRequestBody {
+	return &openAPIRequestBody{
+		Required: true,
+		Content: map[string]openAPIMediaType{
+			"application/x-www-form-urlencoded": {Schema: s},
+		},
+	}
+}
+
+// jsonResponse returns a response with a JSON body.
+func jsonResponse(description string, s openAPISchema) openAPIResponse {
+	return openAPIResponse{
+		Description: description,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: s},
+		},
+	}
+}
+
+// dataSchema returns the schema of a response where the Data field is the given
+// schema.
+func dataSchema(ref string) openAPISchema {
+	return openAPISchema{
+		AllOf: []openAPISchema{
+			{Ref: "#/components/schemas/Response"},
+			{
+				Type: "object",
+				Properties: map[string]openAPISchema{
+					"Data": {Ref: ref},
+				},
+			},
+		},
+	}
+}
+
+// licenseFileResponse returns a response where the license file is returned.
+func licenseFileResponse(description string) openAPIResponse {
+	return openAPIResponse{
+		Description: description,
+		Headers: map[string]openAPIHeader{
+			"Content-Disposition": {
+				Description: "The license file's suggested filename.",
+				Schema:      openAPISchema{Type: "string"},
+			},
+		},
+		Content: map[string]openAPIMediaType{
+			"application/octet-stream": {Schema: openAPISchema{Type: "string", Format: "binary"}},
+		},
+	}
+}
+
+// licenseIDOrFileResponses returns the responses for endpoints that create a license
+// and return either the new license's ID or the license file.
+func licenseIDOrFileResponses(description string) map[string]openAPIResponse {
+	ok := licenseFileResponse(description)
+	ok.Content["application/json"] = openAPIMediaType{
+		Schema: openAPISchema{
+			AllOf: []openAPISchema{
+				{Ref: "#/components/schemas/Response"},
+				{
+					Type: "object",
+					Properties: map[string]openAPISchema{
+						"Data": {Type: "integer", Format: "int64", Description: "The new license's ID."},
+					},
+				},
+			},
+		},
+	}
+
+	return map[string]openAPIResponse{
+		"200":     ok,
+		"503":     maintenanceResponse(),
+		"default": errorResponse(),
+	}
+}
+
+// seatRequestSchema returns the request body schema for checking out or checking in
+// a license seat.
+func seatRequestSchema() openAPISchema {
+	return openAPISchema{
+		Type: "object",
+		Properties: map[string]openAPISchema{
+			"publicID": {Type: "string", Description: "The public ID of the license."},
+			"clientID": {Type: "string", Description: "An identifier for the client, for example a machine ID."},
+		},
+		Required: []string{"publicID", "clientID"},
+	}
+}
+
+// maintenanceResponse returns the response sent when a request that makes changes is
+// rejected because the app is in maintenance mode.
+func maintenanceResponse() openAPIResponse {
+	r := jsonResponse("The app is in maintenance mode, retry the request later.", openAPISchema{Ref: "#/components/schemas/Response"})
+	r.Headers = map[string]openAPIHeader{
+		"Retry-After": {
+			Description: "The number of seconds to wait before retrying.",
+			Schema:      openAPISchema{Type: "integer"},
+		},
+	}
+	return r
+}
+
+// errorResponse returns the response sent when an error occurs.
+func errorResponse() openAPIResponse {
+	return jsonResponse("An error occurred, see ErrorData.", openAPISchema{Ref: "#/components/schemas/Response"})
+}
@@ -0,0 +1,152 @@
+package license
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/output"
+)
+
+// This file specifically deals with previewing a license before it is created. This
+// lets a user see exactly what a license file will contain before a real license is
+// created and signed.
+
+// preview is the data returned when previewing a license.
+type preview struct {
+	Preview     bool                   //always true, so a preview is never confused with a real license.
+	FileFormat  licensefile.FileFormat //
+	File        string                 //the license file, signed with an ephemeral key, as it would be written.
+	Fingerprint string                 //SHA-256 hash of the license file's data, without the signature, hex encoded.
+}
+
+// signingPayloadPreview is the data returned when previewing the bytes that are
+// signed when creating a license.
+type signingPayloadPreview struct {
+	Preview       bool                        //always true, so a preview is never confused with a real license.
+	FileFormat    licensefile.FileFormat      //
+	Algorithm     licensefile.KeyPairAlgoType //the key pair algorithm the license would be signed with.
+	FormatVersion int                         //
+	Payload       string                      //the exact bytes that are hashed and signed, hex encoded.
+	Fingerprint   string                      //SHA-256 hash of Payload, hex encoded.
+	HashAlgorithm string                      //the hash algorithm used before signing per Algorithm, i.e.: SHA-512.
+	Hash          string                      //the hash of Payload using HashAlgorithm, hex encoded. This is what is signed.
+}
+
+// Preview runs the same validation and license file building as Add but never saves
+// anything to the database. The license file is signed with ephemeral key pairs,
+// generated just for this preview, so the private keys for the app's key pairs are
+// never used. The signatures in the returned file are therefore not valid for the
+// app's public keys.
+//
+// The license ID and friendly ID are not known until a license is saved, therefore
+// the license ID in the previewed file will always be 0 if the app shows the license
+// ID, and the friendly ID is never included.
+func Preview(w http.ResponseWriter, r *http.Request) {
+	f, nl, ok := buildPreviewLicense(w, r)
+	if !ok {
+		return
+	}
+
+	//Calculate the fingerprint before the file is signed.
+	unsigned, err := f.Marshal()
+	if err != nil {
+		output.Error(err, "Could not build license for preview.", w)
+		return
+	}
+	sum := sha256.Sum256(unsigned)
+
+	//Sign with ephemeral key pairs of the same algorithms, one per key pair the license
+	//would be signed with, so the previewed file has signatures of the correct format
+	//and length.
+	signingKeys := make([]licensefile.SigningKey, 0, 1+len(nl.CoSigners))
+	for _, kp := range append([]db.KeyPair{nl.KeyPair}, nl.CoSigners...) {
+		privateKey, _, err := licensefile.GenerateKeyPair(kp.AlgorithmType)
+		if err != nil {
+			output.Error(err, "Could not generate preview key pair.", w)
+			return
+		}
+
+		signingKeys = append(signingKeys, licensefile.SigningKey{PrivateKey: privateKey, KeyPairAlgo: kp.AlgorithmType})
+	}
+
+	err = f.SignMulti(signingKeys...)
+	if err != nil {
+		output.Error(err, "Could not generate preview signature.", w)
+		return
+	}
+
+	//Write the file, including the header, as it would be downloaded.
+	signed := bytes.Buffer{}
+	err = f.Write(&signed)
+	if err != nil {
+		output.Error(err, "Could not build license for preview.", w)
+		return
+	}
+
+	p := preview{
+		Preview:     true,
+		FileFormat:  nl.App.FileFormat,
+		File:        signed.String(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	output.DataFound(p, w)
+}
+
+// PreviewSigningPayload returns the exact bytes that would be hashed and signed when
+// creating a license, hex encoded, along with the hash. This is used by integrators
+// debugging signature mismatches so that they can reproduce the hash with their own
+// tooling. The same validation and license file building as Preview is used, and
+// nothing is saved or signed.
+//
+// The issue date and timestamp are set when the request is made, and the license ID
+// is always 0, therefore the bytes will differ from a license created later with the
+// same data.
+func PreviewSigningPayload(w http.ResponseWriter, r *http.Request) {
+	f, nl, ok := buildPreviewLicense(w, r)
+	if !ok {
+		return
+	}
+	kp := nl.KeyPair
+
+	payload, hash, err := f.SigningPayload(kp.AlgorithmType)
+	if err != nil {
+		output.Error(err, "Could not build signing payload for preview.", w)
+		return
+	}
+	sum := sha256.Sum256(payload)
+
+	p := signingPayloadPreview{
+		Preview:       true,
+		FileFormat:    nl.App.FileFormat,
+		Algorithm:     kp.AlgorithmType,
+		FormatVersion: licensefile.CurrentFormatVersion,
+		Payload:       hex.EncodeToString(payload),
+		Fingerprint:   hex.EncodeToString(sum[:]),
+		HashAlgorithm: kp.AlgorithmType.HashAlgorithm(),
+		Hash:          hex.EncodeToString(hash),
+	}
+	output.DataFound(p, w)
+}
+
+// buildPreviewLicense runs the same validation and license file building as Add, for
+// a license that is being previewed, and returns the unsigned license file. The key
+// pairs the license would be signed with, and the app the license is for, are also
+// returned. If false is returned, a response has already been sent.
+func buildPreviewLicense(w http.ResponseWriter, r *http.Request) (f licensefile.File, nl newLicense, ok bool) {
+	nl, ok = prepareNewLicense(w, r)
+	if !ok {
+		return
+	}
+
+	f, err := buildLicense(nl.License, nl.Fields)
+	if err != nil {
+		output.Error(err, "Could not build license for preview.", w)
+		return f, nl, false
+	}
+
+	return f, nl, true
+}
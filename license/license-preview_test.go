@@ -0,0 +1,225 @@
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/sqldb/v3"
+)
+
+// newTestDB deploys the schema to a new SQLite database file, using a new default
+// config file, and sets it as the database used by the app. An app, with a key pair,
+// feature, and template, is saved and the template's ID is returned.
+func newTestDB(t *testing.T) (templateID int64) {
+	err := config.Read(filepath.Join(t.TempDir(), "licensekeys.conf"), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	c := &sqldb.Config{
+		Type:       sqldb.DBTypeSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "test.db"),
+		SQLitePragmas: []string{
+			"PRAGMA busy_timeout = 5000",
+		},
+		MapperFunc:    sqldb.DefaultMapperFunc,
+		LoggingLevel:  sqldb.LogLevelNone,
+		DeployQueries: db.DeployQueries,
+		DeployFuncs:   db.DeployFuncs,
+	}
+	sqldb.Use(c)
+
+	err = sqldb.DeploySchema(&sqldb.DeploySchemaOptions{CloseConnection: false})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	t.Cleanup(func() { sqldb.Close() })
+
+	ctx := context.Background()
+	tx, err := sqldb.Connection().BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Rollback()
+
+	a := db.App{
+		CreatedByUserID:      1,
+		Active:               true,
+		Name:                 "Test App",
+		DaysToExpiration:     30,
+		FileFormat:           licensefile.FileFormatJSON,
+		ShowLicenseID:        true,
+		ShowAppName:          true,
+		DownloadFilename:     "license.txt",
+		FileHeaderText:       "Test App License",
+		RequiredSignatures:   1,
+		LicenseSchemaVersion: 2,
+	}
+	err = a.Insert(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	private, public, err := licensefile.GenerateKeyPair(licensefile.KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	kp := db.KeyPair{
+		CreatedByUserID: 1,
+		Active:          true,
+		AppID:           a.ID,
+		Name:            "Test Key Pair",
+		PrivateKey:      string(private),
+		PublicKey:       string(public),
+		AlgorithmType:   licensefile.KeyPairAlgoED25519,
+		IsDefault:       true,
+	}
+	err = kp.Insert(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	feature := db.AppFeature{
+		CreatedByUserID: 1,
+		Active:          true,
+		AppID:           a.ID,
+		Name:            "Pro",
+		FeatureKey:      "pro",
+	}
+	err = feature.Insert(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	tmpl := db.LicenseTemplate{
+		CreatedByUserID: 1,
+		Active:          true,
+		Name:            "Test Template",
+		AppID:           a.ID,
+		DurationDays:    365,
+		Features:        "pro",
+	}
+	err = tmpl.Insert(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	return tmpl.ID
+}
+
+// newLicenseRequest returns a request to preview or create a license, as a user in
+// the GUI, from a template.
+func newLicenseRequest(t *testing.T, path string, templateID int64) *http.Request {
+	licenseData := `{"CompanyName":"ACME Dynamite Corp.","ContactName":"Wyle E. Coyote","PhoneNumber":"555-555-5555","Email":"coyote@example.com"}`
+
+	form := url.Values{}
+	form.Set("licenseData", licenseData)
+	form.Set("templateID", strconv.FormatInt(templateID, 10))
+	form.Set("returnLicenseFile", "true")
+
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(context.WithValue(r.Context(), users.UserIDContextKey, int64(1)))
+}
+
+// comparableLicense unmarshals a license file and clears the fields that are only
+// known once a license is saved, or that change each time a license is built, so that
+// a previewed license can be compared to a created license.
+func comparableLicense(t *testing.T, contents string) string {
+	f, err := licensefile.Unmarshal([]byte(contents), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return ""
+	}
+
+	f.LicenseID = 0
+	f.FriendlyID = ""
+	f.IssueTimestamp = 0
+	f.Signature = ""
+	f.Signatures = nil
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+		return ""
+	}
+
+	return string(b)
+}
+
+func TestPreviewMatchesAdd(t *testing.T) {
+	templateID := newTestDB(t)
+
+	//Preview the license.
+	w := httptest.NewRecorder()
+	Preview(w, newLicenseRequest(t, "/api/licenses/preview/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("preview failed", w.Code, w.Body.String())
+		return
+	}
+
+	var previewResponse struct {
+		Data preview
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &previewResponse)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	previewed := previewResponse.Data.File
+
+	//Create the license, returning the license file.
+	w = httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created := w.Body.String()
+
+	//The previewed license should be the same as the created license.
+	if comparableLicense(t, previewed) != comparableLicense(t, created) {
+		t.Fatalf("previewed license does not match created license\npreview: %s\ncreated: %s", previewed, created)
+		return
+	}
+
+	//The header should be the same too since it is written above the license data.
+	previewHeader, _, _ := strings.Cut(previewed, "{")
+	createdHeader, _, _ := strings.Cut(created, "{")
+	if previewHeader != createdHeader {
+		t.Fatalf("previewed header does not match created header\npreview: %q\ncreated: %q", previewHeader, createdHeader)
+		return
+	}
+
+	//Make sure the template was used, otherwise both could match just because
+	//neither used the template.
+	if !strings.Contains(created, `"pro"`) {
+		t.Fatal("expected license to include the template's features", created)
+		return
+	}
+}
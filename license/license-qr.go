@@ -0,0 +1,155 @@
+package license
+
+import (
+	"database/sql"
+	"fmt"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles returning a license as a QR code. This is used for activating a
+// license on mobile devices by scanning the QR code instead of typing the license's
+// public ID.
+//
+// By default, the QR code encodes the URL of the license's public verification page,
+// which includes the license's public ID. Scanning the QR code with a phone's camera
+// will open the verification page, and an app can parse the public ID from the URL.
+// Alternatively, the QR code can encode just the public ID.
+
+// Sizes, in pixels, of the QR code image.
+const (
+	qrCodeDefaultSize = 256
+	qrCodeMinSize     = 64
+	qrCodeMaxSize     = 1024
+)
+
+// QR code image formats.
+const (
+	qrCodeFormatPNG = "png"
+	qrCodeFormatSVG = "svg"
+)
+
+// QRCode returns a QR code image for a license.
+func QRCode(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	publicIDOnly, _ := strconv.ParseBool(r.FormValue("publicIDOnly"))
+
+	size := qrCodeDefaultSize
+	if s := r.FormValue("size"); s != "" {
+		size, _ = strconv.Atoi(s)
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+	if format == "" {
+		format = qrCodeFormatPNG
+	}
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("The license ID provided is invalid.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+	if size < qrCodeMinSize || size > qrCodeMaxSize {
+		output.ErrorInputInvalid("The size must be between "+strconv.Itoa(qrCodeMinSize)+" and "+strconv.Itoa(qrCodeMaxSize)+" pixels.", w)
+		return
+	}
+	if format != qrCodeFormatPNG && format != qrCodeFormatSVG {
+		output.ErrorInputInvalid("The format must be either "+qrCodeFormatPNG+" or "+qrCodeFormatSVG+".", w)
+		return
+	}
+
+	//Look up the license to make sure it exists.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".PublicID",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	//Build the QR code.
+	content := l.PublicID
+	if !publicIDOnly {
+		content = verifyURL(r, l.PublicID)
+	}
+
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		output.Error(err, "Could not build QR code.", w)
+		return
+	}
+
+	//Return the QR code.
+	switch format {
+	case qrCodeFormatSVG:
+		w.Header().Set("Content-Type", "image/svg+xml")
+		writeSVG(w, code, size)
+
+	default:
+		scaled, err := barcode.Scale(code, size, size)
+		if err != nil {
+			output.Error(err, "Could not resize QR code.", w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		err = png.Encode(w, scaled)
+		if err != nil {
+			output.Error(err, "Could not present QR code.", w)
+			return
+		}
+	}
+}
+
+// verifyURL returns the URL to the public verification page for a license, based on
+// the host the request was made to.
+func verifyURL(r *http.Request, publicID string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + config.Data().URL("/verify/"+publicID+"/")
+}
+
+// qrCodeSVGQuietZone is the number of blank modules around an SVG QR code. A blank
+// border is required by scanners to find the QR code.
+const qrCodeSVGQuietZone = 4
+
+// writeSVG writes a QR code as an SVG image. Each dark module of the QR code is drawn
+// as a square, and the image is scaled to the requested size via the viewBox.
+func writeSVG(w http.ResponseWriter, code barcode.Barcode, size int) {
+	b := code.Bounds()
+	modules := b.Dx()
+	q := qrCodeSVGQuietZone
+	full := modules + 2*q
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%d %d %d %d" shape-rendering="crispEdges">`, size, size, -q, -q, full, full)
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="#fff"/>`, -q, -q, full, full)
+	fmt.Fprint(w, `<path fill="#000" d="`)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if code.At(x, y) == color.Black {
+				fmt.Fprintf(w, "M%d %dh1v1h-1z", x-b.Min.X, y-b.Min.Y)
+			}
+		}
+	}
+	fmt.Fprint(w, `"/></svg>`)
+}
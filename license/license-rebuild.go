@@ -0,0 +1,190 @@
+package license
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles rebuilding a license's file and signature from the license's
+// stored data. This is used when an administrator suspects the downloadable license
+// file is wrong, for example after a schema change, but the license's stored data is
+// correct.
+
+// rebuildResult is the data returned when a license is rebuilt.
+type rebuildResult struct {
+	LicenseID   int64
+	Fingerprint string //SHA-256 hash of the signed license file data, see licensefile.File.SigningPayload(), hex encoded.
+	Verified    bool
+}
+
+// Rebuild rebuilds a license's file from the license's stored common data and custom
+// field results, signs it with the license's key pair, and verifies the new signature
+// with the key pair's public key. The new signature, and if it was verified, are saved
+// along with a note about the rebuild in one transaction.
+//
+// Note that the new signature will differ from the signature in previously
+// distributed copies of the license file, for algorithms where signing is not
+// deterministic, however previously distributed copies of the license file can still
+// be verified.
+func Rebuild(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	//Validate.
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to rebuild.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	//Look up the license's data. The same columns are used as when downloading a
+	//license so that the rebuilt file matches the file that will be downloaded.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".*",
+		db.TableApps + ".ID AS AppID",
+		db.TableApps + ".Name AS AppName",
+		db.TableApps + ".FileHeaderText AS AppFileHeaderText",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+
+	cfr, err := db.GetCustomFieldResults(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, "Could not look up custom fields for license.", w)
+		return
+	}
+
+	//Get key pair data to sign the license file.
+	kp, err := db.GetKeyPairByID(r.Context(), l.KeyPairID)
+	if err != nil {
+		output.Error(err, "Could not look up signature details.", w)
+		return
+	}
+	errMsg := checkSigningKeyPair(kp, "this license", "rebuilt")
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Build the license file.
+	f, err := buildLicense(l, cfr)
+	if err != nil {
+		output.Error(err, "Could not build license.", w)
+		return
+	}
+
+	//Get the keys to sign the license with. The license is co-signed, the same as
+	//when a license is created, if the app requires more than one signature.
+	a, err := db.GetAppByID(r.Context(), l.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
+
+	keys, errMsg, err := getLicenseSigningKeys(r.Context(), a, kp)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Sign the license file.
+	err = keys.sign(&f)
+	if err != nil {
+		output.Error(err, "Could not generate signature.", w)
+		return
+	}
+
+	//Calculate the fingerprint from the exact bytes that were signed.
+	payload, _, err := f.SigningPayload(kp.AlgorithmType)
+	if err != nil {
+		output.Error(err, "Could not calculate license fingerprint.", w)
+		return
+	}
+	sum := sha256.Sum256(payload)
+
+	//Verify the rebuilt license file. A license that fails verification is still
+	//saved, but is marked as not verified so it cannot be downloaded.
+	verifyErr := keys.verify(f)
+
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Save the new signature, verification status, and a note.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save rebuilt license (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	l.Signature = f.Signature
+	l.Signatures = joinSignatures(f.Signatures)
+	l.SignatureAlgorithm = f.Algorithm
+	l.FormatVersion = f.FormatVersion
+	err = l.SaveSignature(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save rebuilt license (2).", w)
+		return
+	}
+
+	l.Verified = verifyErr == nil
+	err = l.SaveVerified(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save rebuilt license (3).", w)
+		return
+	}
+
+	note := "License file was rebuilt and re-signed. Fingerprint: " + hex.EncodeToString(sum[:]) + "."
+	if verifyErr != nil {
+		note += " The rebuilt license could not be verified: " + verifyErr.Error() + "."
+	}
+
+	n := db.LicenseNote{
+		LicenseID: licenseID,
+		Note:      note,
+	}
+	if userID > 0 {
+		n.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		n.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = n.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about rebuilt license.", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not complete saving of rebuilt license.", w)
+		return
+	}
+
+	output.UpdateOKWithData(rebuildResult{
+		LicenseID:   licenseID,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Verified:    l.Verified,
+	}, w)
+}
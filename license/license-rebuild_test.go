@@ -0,0 +1,122 @@
+package license
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/sqldb/v3"
+)
+
+// newRebuildRequest returns a request to rebuild a license, as a user in the GUI.
+func newRebuildRequest(licenseID int64) *http.Request {
+	form := url.Values{}
+	form.Set("id", strconv.FormatInt(licenseID, 10))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/licenses/rebuild/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(context.WithValue(r.Context(), users.UserIDContextKey, int64(1)))
+}
+
+func TestRebuildFingerprint(t *testing.T) {
+	templateID := newTestDB(t)
+
+	//Create the license.
+	w := httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Mark the license as created before license file format versions were added.
+	//The rebuilt license is signed with the current format version.
+	q := `UPDATE ` + db.TableLicenses + ` SET FormatVersion = ? WHERE ID = ?`
+	_, err = sqldb.Connection().ExecContext(context.Background(), q, 0, created.LicenseID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Rebuild the license.
+	w = httptest.NewRecorder()
+	Rebuild(w, newRebuildRequest(created.LicenseID))
+	if w.Code != http.StatusOK {
+		t.Fatal("rebuild failed", w.Code, w.Body.String())
+		return
+	}
+
+	var resp struct {
+		Data rebuildResult
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The fingerprint must be calculated from the bytes that were signed, which
+	//includes the algorithm and format version set when signing.
+	payload, err := created.Payload()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	sum := sha256.Sum256(payload)
+	if resp.Data.Fingerprint != hex.EncodeToString(sum[:]) {
+		t.Fatal("fingerprint does not match signed data", resp.Data.Fingerprint, hex.EncodeToString(sum[:]))
+		return
+	}
+}
+
+func TestRebuildInactiveKeyPair(t *testing.T) {
+	templateID := newTestDB(t)
+
+	//Create the license.
+	w := httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Deactivate the key pair the license was signed with.
+	q := `UPDATE ` + db.TableKeyPairs + ` SET Active = ?`
+	_, err = sqldb.Connection().ExecContext(context.Background(), q, false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Rebuilding must be rejected since the key pair is no longer active.
+	w = httptest.NewRecorder()
+	Rebuild(w, newRebuildRequest(created.LicenseID))
+	if w.Code == http.StatusOK {
+		t.Fatal("rebuild should have failed")
+		return
+	}
+	if !strings.Contains(w.Body.String(), "This key pair used for this license is no longer active. This license cannot be rebuilt.") {
+		t.Fatal("unexpected error", w.Body.String())
+		return
+	}
+}
@@ -0,0 +1,276 @@
+package license
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/sqldb/v3"
+)
+
+// newRenewRequest returns a request to renew a license, as a user in the GUI,
+// returning the renewed license's file.
+func newRenewRequest(licenseID int64) *http.Request {
+	form := url.Values{}
+	form.Set("id", strconv.FormatInt(licenseID, 10))
+	form.Set("newExpireDate", time.Now().AddDate(2, 0, 0).Format("2006-01-02"))
+	form.Set("returnLicenseFile", "true")
+
+	r := httptest.NewRequest(http.MethodPost, "/api/licenses/renew/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(context.WithValue(r.Context(), users.UserIDContextKey, int64(1)))
+}
+
+func TestRenewCoSigned(t *testing.T) {
+	templateID := newTestDB(t)
+	ctx := context.Background()
+
+	//Require each license for the app to be signed by 2 key pairs.
+	tmpl, err := db.GetLicenseTemplateByID(ctx, templateID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	a, err := db.GetAppByID(ctx, tmpl.AppID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	private, public, err := licensefile.GenerateKeyPair(licensefile.KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	kp := db.KeyPair{
+		CreatedByUserID: 1,
+		Active:          true,
+		AppID:           a.ID,
+		Name:            "Co-Signing Key Pair",
+		PrivateKey:      string(private),
+		PublicKey:       string(public),
+		AlgorithmType:   licensefile.KeyPairAlgoED25519,
+	}
+	tx, err := sqldb.Connection().BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = kp.Insert(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	a.RequiredSignatures = 2
+	err = a.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	kk, err := db.GetKeyPairs(ctx, a.ID, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	var keys []licensefile.VerifyingKey
+	for _, k := range kk {
+		keys = append(keys, licensefile.VerifyingKey{PublicKey: []byte(k.PublicKey), KeyPairAlgo: k.AlgorithmType})
+	}
+
+	//Create the license.
+	w := httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Renew the license. The renewed license must be co-signed the same as the
+	//created license.
+	w = httptest.NewRecorder()
+	Renew(w, newRenewRequest(created.LicenseID))
+	if w.Code != http.StatusOK {
+		t.Fatal("renew failed", w.Code, w.Body.String())
+		return
+	}
+	renewed, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if renewed.LicenseID == created.LicenseID {
+		t.Fatal("expected a new license to be created")
+		return
+	}
+
+	valid, err := renewed.VerifyMulti(keys, 2)
+	if err != nil {
+		t.Fatal("renewed license is not co-signed", err)
+		return
+	}
+	if valid != 2 {
+		t.Fatal("expected 2 valid signatures, got", valid)
+		return
+	}
+}
+
+func TestRenewImported(t *testing.T) {
+	templateID := newTestDB(t)
+	ctx := context.Background()
+
+	//Give each license for the app a friendly ID.
+	tmpl, err := db.GetLicenseTemplateByID(ctx, templateID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	a, err := db.GetAppByID(ctx, tmpl.AppID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	a.FriendlyIDFormat = db.FriendlyIDFormatSequential
+	a.FriendlyIDPrefix = "ACME-"
+	err = a.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Create the license and mark it as imported. Imported licenses are not given
+	//a friendly ID.
+	w := httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	q := `UPDATE ` + db.TableLicenses + ` SET Imported = ?, FriendlyID = ? WHERE ID = ?`
+	_, err = sqldb.Connection().ExecContext(ctx, q, true, "", created.LicenseID)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Renew the license. The renewed license was created by this app so it must not
+	//be marked as imported and must be given a friendly ID.
+	w = httptest.NewRecorder()
+	Renew(w, newRenewRequest(created.LicenseID))
+	if w.Code != http.StatusOK {
+		t.Fatal("renew failed", w.Code, w.Body.String())
+		return
+	}
+	renewed, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	cols := sqldb.Columns{
+		db.TableLicenses + ".Imported",
+		db.TableLicenses + ".FriendlyID",
+	}
+	l, err := db.GetLicense(ctx, renewed.LicenseID, cols)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if l.Imported {
+		t.Fatal("renewed license should not be marked as imported")
+		return
+	}
+	if l.FriendlyID != "ACME-"+strconv.FormatInt(renewed.LicenseID, 10) {
+		t.Fatal("renewed license was not given a friendly ID", l.FriendlyID)
+		return
+	}
+}
+
+func TestRenew2FAStepUp(t *testing.T) {
+	templateID := newTestDB(t)
+	ctx := context.Background()
+
+	//Create the license before requiring 2FA.
+	w := httptest.NewRecorder()
+	Add(w, newLicenseRequest(t, "/api/licenses/add/", templateID))
+	if w.Code != http.StatusOK {
+		t.Fatal("add failed", w.Code, w.Body.String())
+		return
+	}
+	created, err := licensefile.Unmarshal(w.Body.Bytes(), licensefile.FileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Require 2FA when creating licenses and enroll the user in 2FA.
+	as, err := db.GetAppSettings(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	as.Allow2FactorAuth = true
+	as.Require2FAForLicenseCreation = true
+	err = as.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	q := `UPDATE ` + db.TableUsers + ` SET TwoFactorAuthEnabled = ? WHERE ID = ?`
+	_, err = sqldb.Connection().ExecContext(ctx, q, true, 1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Renew the license without a 2FA token. The user must be asked for a token and
+	//the license must not be renewed.
+	w = httptest.NewRecorder()
+	Renew(w, newRenewRequest(created.LicenseID))
+	if w.Code != http.StatusForbidden {
+		t.Fatal("expected 2FA step-up", w.Code, w.Body.String())
+		return
+	}
+	if !strings.Contains(w.Body.String(), users.MsgType2FAStepUpRequired) {
+		t.Fatal("expected 2FA step-up response", w.Body.String())
+		return
+	}
+
+	var count int
+	q = `SELECT COUNT(ID) FROM ` + db.TableLicenses
+	err = sqldb.Connection().GetContext(ctx, &count, q)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if count != 1 {
+		t.Fatal("license should not have been renewed", count)
+		return
+	}
+}
@@ -0,0 +1,168 @@
+package license
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles downloading a report of the licenses created within a date range.
+// This is used for reconciling the licenses issued each month, for example by a
+// finance team, and is separate from the list of licenses since it is not limited to
+// a number of rows and includes who created each license.
+//
+// The report is streamed as CSV, one license at a time, so that a large range does
+// not need to be loaded into memory. Totals by app are written after the licenses.
+
+// reportHeader is the first row of the report.
+var reportHeader = []string{
+	"License ID",
+	"Public ID",
+	"Created",
+	"Company",
+	"App",
+	"Key Pair",
+	"Created By User",
+	"Created By API Key",
+	"Active",
+	"Expire Date",
+}
+
+// reportTotal is the number of licenses created for an app within the report's range.
+type reportTotal struct {
+	appName string
+	total   int
+	active  int
+}
+
+// Report returns the licenses created within a date range, optionally filtered by
+// app, as a CSV file. Dates are yyyy-mm-dd and are in the user's timezone, the same
+// as the activity log.
+//
+// Since the report is streamed, an error that occurs after the report has started to
+// be sent cannot be returned as an error response. Instead, the error is logged and
+// the report is ended with an error row so that the report isn't mistaken as complete.
+func Report(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	startDate := strings.TrimSpace(r.FormValue("start"))
+	endDate := strings.TrimSpace(r.FormValue("end"))
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	startDateParsed, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse start date. Date must be in YYYY-MM-DD format.", w)
+		return
+	}
+	endDateParsed, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse end date. Date must be in YYYY-MM-DD format.", w)
+		return
+	}
+	if startDateParsed.After(endDateParsed) {
+		output.ErrorInputInvalid("Start date must be before end date.", w)
+		return
+	}
+	if appID < 0 {
+		//No error, just use default "don't filter by app".
+		appID = 0
+	}
+
+	//Build the report.
+	filename := "licenses-" + startDate + "-to-" + endDate + ".csv"
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(r.Context())
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".PublicID",
+		db.TableLicenses + ".CompanyName",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".ExpireDate",
+		db.TableApps + ".ID AS AppID",
+		db.TableApps + ".Name AS AppName",
+		db.TableKeyPairs + ".Name AS KeyPairName",
+		db.TableUsers + ".Username AS CreatedByUsername",
+		db.TableAPIKeys + ".Description AS CreatedByAPIKeyDescription",
+		`datetime(` + db.TableLicenses + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
+	}
+
+	totals := map[int64]*reportTotal{}
+	err = db.StreamLicensesCreatedBetween(r.Context(), appID, startDate, endDate, cols, func(l db.License) error {
+		t, ok := totals[l.AppID]
+		if !ok {
+			t = &reportTotal{appName: l.AppName}
+			totals[l.AppID] = t
+		}
+		t.total++
+		if l.Active {
+			t.active++
+		}
+
+		return cw.Write([]string{
+			strconv.FormatInt(l.ID, 10),
+			l.PublicID,
+			l.DatetimeCreatedInTZ,
+			reportCell(l.CompanyName),
+			reportCell(l.AppName),
+			reportCell(l.KeyPairName),
+			reportCell(l.CreatedByUsername.String),
+			reportCell(l.CreatedByAPIKeyDescription.String),
+			strconv.FormatBool(l.Active),
+			l.ExpireDate,
+		})
+	})
+	if err != nil {
+		log.Println("license.Report", "could not build report", err)
+		cw.Write([]string{"ERROR: the report is incomplete, please try again."})
+		cw.Flush()
+		return
+	}
+
+	//Write the totals by app, sorted by app name.
+	tt := make([]*reportTotal, 0, len(totals))
+	for _, t := range totals {
+		tt = append(tt, t)
+	}
+	sort.Slice(tt, func(i, j int) bool {
+		return tt[i].appName < tt[j].appName
+	})
+
+	cw.Write(nil)
+	cw.Write([]string{"App", "Total Licenses", "Active Licenses"})
+	for _, t := range tt {
+		cw.Write([]string{
+			reportCell(t.appName),
+			strconv.Itoa(t.total),
+			strconv.Itoa(t.active),
+		})
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Println("license.Report", "could not send report", err)
+	}
+}
+
+// reportCell prevents user provided text from being interpreted as a formula when
+// the report is opened in a spreadsheet app, a.k.a CSV injection.
+func reportCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
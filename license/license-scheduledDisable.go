@@ -0,0 +1,340 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles disabling a license at a future date. This is used, for example,
+// when a customer cancels their subscription but has already paid through the end of
+// the month. The license stays usable, and downloadable, until the scheduled date and
+// is then disabled the same as if a user had disabled it.
+//
+// This is different from the expiration date since the expiration date is part of the
+// signed license file. A scheduled disable does not change the license file, nor does
+// disabling a license, so a license does not need to be re-signed when it is
+// disabled.
+//
+// Scheduled dates are in the config file's timezone since licenses are disabled by a
+// background task, not in response to a user's request. Scheduling, canceling, and
+// disabling are each recorded as a license note.
+
+// scheduledDisableInterval is how often licenses with a scheduled disable date that
+// has arrived are disabled. Dates are yyyy-mm-dd so this does not need to be frequent.
+const scheduledDisableInterval = time.Hour
+
+// ScheduleDisable sets the date on which a license will be disabled automatically. A
+// note explaining why the license is being disabled is required if the
+// RequireDisableReason app setting is enabled, the same as when disabling a license
+// immediately. The date must be in the future, use Disable() to disable a license now.
+//
+// A license that already has a scheduled disable date can be rescheduled by providing
+// a new date.
+func ScheduleDisable(w http.ResponseWriter, r *http.Request) {
+	//Get inputs and validate.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	date := strings.TrimSpace(r.FormValue("date"))
+	note := strings.TrimSpace(r.FormValue("note"))
+
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to schedule to be disabled.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	dateParsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse the date to disable the license on. Date must be in YYYY-MM-DD format.", w)
+		return
+	}
+
+	today, _ := time.Parse("2006-01-02", scheduledDisableToday())
+	if !dateParsed.After(today) {
+		output.ErrorInputInvalid("The date to disable the license on must be in the future. To disable the license now, disable it instead of scheduling it to be disabled.", w)
+		return
+	}
+
+	//Check if a reason for disabling the license is required.
+	as, err := db.GetAppSettings(r.Context())
+	if err != nil {
+		output.Error(err, "Could not determine if a reason is required to disable a license.", w)
+		return
+	}
+	if as.RequireDisableReason && note == "" {
+		output.ErrorInputInvalid("A reason is required to disable a license. Please provide a note describing why you are disabling this license.", w)
+		return
+	}
+
+	//Check if this license is already disabled.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".ScheduledDisableDate",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not verify if license is already disabled.", w)
+		return
+	}
+	if !l.Active {
+		output.ErrorInputInvalid("This license has already been disabled.", w)
+		return
+	}
+
+	//Get info about who is scheduling this license to be disabled.
+	userID, _, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Save the scheduled date and a note.
+	previousDate := l.ScheduledDisableDate
+	l.ScheduledDisableDate = date
+	l.ScheduledDisableNote = note
+	l.ScheduledDisableByUserID = userID
+
+	n := db.LicenseNote{
+		LicenseID:       licenseID,
+		Note:            "License was scheduled to be disabled on " + date + ".",
+		CreatedByUserID: null.IntFrom(userID),
+	}
+	if previousDate != "" {
+		n.Note = "Scheduled disable was changed from " + previousDate + " to " + date + "."
+	}
+	if note != "" {
+		n.Note = note + " (" + strings.TrimSuffix(n.Note, ".") + ")."
+	}
+
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not schedule license to be disabled (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = l.SaveScheduledDisable(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save date to disable license on.", w)
+		return
+	}
+
+	err = n.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about scheduled disable.", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not schedule license to be disabled (2).", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// CancelScheduledDisable removes the scheduled disable date from a license so that it
+// will not be disabled automatically. This must be done before the scheduled date
+// arrives, afterwards the license has already been disabled.
+func CancelScheduledDisable(w http.ResponseWriter, r *http.Request) {
+	//Get inputs and validate.
+	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if licenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to cancel the scheduled disable for.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	//Check if this license is scheduled to be disabled.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".ScheduledDisableDate",
+	}
+	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up license data.", w)
+		return
+	}
+	if !l.Active {
+		output.ErrorInputInvalid("This license has already been disabled.", w)
+		return
+	}
+	if l.ScheduledDisableDate == "" {
+		output.ErrorInputInvalid("This license is not scheduled to be disabled.", w)
+		return
+	}
+
+	//Get info about who is canceling the scheduled disable.
+	userID, _, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Remove the scheduled date and save a note.
+	n := db.LicenseNote{
+		LicenseID:       licenseID,
+		Note:            "Scheduled disable on " + l.ScheduledDisableDate + " was canceled.",
+		CreatedByUserID: null.IntFrom(userID),
+	}
+
+	l.ScheduledDisableDate = ""
+	l.ScheduledDisableNote = ""
+	l.ScheduledDisableByUserID = 0
+
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not cancel scheduled disable (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = l.SaveScheduledDisable(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not remove date to disable license on.", w)
+		return
+	}
+
+	err = n.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about canceled scheduled disable.", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not cancel scheduled disable (2).", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// scheduledDisableToday returns today's date, as yyyy-mm-dd, in the config file's
+// timezone. This is the date scheduled disable dates are compared against.
+func scheduledDisableToday() string {
+	return time.Now().In(config.GetLocation()).Format("2006-01-02")
+}
+
+// StartScheduledDisabler disables licenses whose scheduled disable date has arrived,
+// once immediately and then on a ticker. Running immediately handles dates that
+// arrived while this app was not running.
+//
+// This should be called in a goroutine since it never returns.
+func StartScheduledDisabler() {
+	disableScheduled(context.Background())
+
+	ticker := time.NewTicker(scheduledDisableInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		disableScheduled(context.Background())
+	}
+}
+
+// disableScheduled disables each license whose scheduled disable date has arrived. An
+// error disabling one license is logged and does not stop other licenses from being
+// disabled. The license will be retried the next time this runs.
+func disableScheduled(ctx context.Context) {
+	//Skip while the app is in maintenance mode since changes should not be made. The
+	//licenses will be disabled the next time this runs after maintenance mode is
+	//turned off.
+	if config.MaintenanceMode() {
+		log.Println("license.disableScheduled", "skipped since app is in maintenance mode")
+		return
+	}
+
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".CompanyName",
+		db.TableLicenses + ".AppName",
+		db.TableLicenses + ".ScheduledDisableDate",
+		db.TableLicenses + ".ScheduledDisableNote",
+		db.TableLicenses + ".ScheduledDisableByUserID",
+	}
+	today := scheduledDisableToday()
+	lics, err := db.GetLicensesDueForScheduledDisable(ctx, today, cols)
+	if err != nil {
+		log.Println("license.disableScheduled", "could not look up licenses to disable", err)
+		return
+	}
+
+	for _, l := range lics {
+		disabled, err := disableScheduledOne(ctx, l, today)
+		if err != nil {
+			log.Println("license.disableScheduled", "could not disable license", l.ID, err)
+			continue
+		} else if !disabled {
+			log.Println("license.disableScheduled", "skipped license", l.ID, "since its scheduled disable was changed")
+			continue
+		}
+
+		log.Println("license.disableScheduled", "disabled license", l.ID, "as scheduled for", l.ScheduledDisableDate)
+	}
+}
+
+// disableScheduledOne disables a license whose scheduled disable date has arrived and
+// saves a note, in the same transaction, the same as Disable(). The note is credited
+// to the user who scheduled the disable.
+//
+// The license is only disabled if its scheduled disable date is still the date it was
+// looked up with, since the scheduled disable may have been canceled or rescheduled
+// since. disabled is false, and nothing is saved, if the license was not disabled.
+func disableScheduledOne(ctx context.Context, l db.License, today string) (disabled bool, err error) {
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	disabled, err = db.DisableScheduledLicense(ctx, l.ID, l.ScheduledDisableDate, today, tx)
+	if err != nil || !disabled {
+		return
+	}
+
+	n := db.LicenseNote{
+		LicenseID: l.ID,
+		Note:      "License was disabled as scheduled for " + l.ScheduledDisableDate + ".",
+	}
+	if l.ScheduledDisableNote != "" {
+		n.Note = l.ScheduledDisableNote + " (License was disabled as scheduled for " + l.ScheduledDisableDate + ")."
+	}
+	if l.ScheduledDisableByUserID > 0 {
+		n.CreatedByUserID = null.IntFrom(l.ScheduledDisableByUserID)
+	}
+
+	err = n.Insert(ctx, tx)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+
+	notifyLicenseEvent(ctx, config.NotificationEventLicenseDisabled, l, l.ScheduledDisableByUserID, 0)
+	return
+}
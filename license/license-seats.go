@@ -0,0 +1,333 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles tracking the seats of a floating license that are in use. A client
+// checks out a seat when it starts and checks the seat back in when it stops. Each
+// checked out seat has a lease that the client must renew, by checking out the seat
+// again, before the lease expires. Expired leases are reclaimed so that seats from
+// clients that crashed, or otherwise never checked their seat back in, are not held
+// forever.
+//
+// The maximum number of seats for a license is set by a custom field, see
+// LicenseSeatsFieldName in the config file.
+
+// seatResult is the data returned when a seat is checked out or checked in.
+type seatResult struct {
+	PublicID     string
+	ClientID     string
+	CheckedOut   bool   //true if the client holds a seat after this request.
+	InUse        int64  //number of seats in use after this request.
+	MaxSeats     int64  //the maximum number of seats for the license.
+	LeaseExpires string //YYYY-MM-DD HH:MM:SS, UTC, when the client's seat will be reclaimed unless renewed.
+}
+
+// seatSweepInterval is how often expired seat leases are reclaimed.
+const seatSweepInterval = time.Minute
+
+// CheckOut claims a seat of a license for a client. If the client already holds a
+// seat, the seat's lease is renewed. If all seats are in use, a seat is not checked
+// out and CheckedOut will be false in the returned data.
+func CheckOut(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	publicID := strings.TrimSpace(r.FormValue("publicID"))
+	clientID := strings.TrimSpace(r.FormValue("clientID"))
+
+	//Validate.
+	if publicID == "" {
+		output.ErrorInputInvalid("Could not determine which license you want to check out a seat from.", w)
+		return
+	}
+	if clientID == "" {
+		output.ErrorInputInvalid("You must provide an identifier for the client checking out a seat.", w)
+		return
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	l, errMsg, err := getPublicLicense(r.Context(), publicID, apiKeyID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+	if !l.Active {
+		output.ErrorInputInvalid("This license has been disabled.", w)
+		return
+	}
+	if !l.Verified {
+		output.ErrorInputInvalid("This license is not verified and cannot be used.", w)
+		return
+	}
+	if l.Expired {
+		output.ErrorInputInvalid("This license is expired.", w)
+		return
+	}
+
+	maxSeats, found, err := getMaxSeats(r.Context(), l.ID)
+	if err != nil {
+		output.Error(err, "Could not look up the maximum number of seats for this license.", w)
+		return
+	} else if !found {
+		output.ErrorInputInvalid("This license does not have a "+config.Data().LicenseSeatsFieldName+" field so seats cannot be checked out.", w)
+		return
+	}
+
+	//Start transaction so that the count of seats in use cannot change between
+	//checking the count and checking out a seat.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not check out seat (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	res := seatResult{
+		PublicID: publicID,
+		ClientID: clientID,
+		MaxSeats: maxSeats,
+	}
+
+	now := timestamps.YMDHMS()
+	leaseExpires := db.LeaseExpiresAt(time.Duration(config.Data().LicenseSeatLeaseMinutes) * time.Minute)
+
+	//Renew the lease if the client already holds a seat. An expired lease that
+	//hasn't been reclaimed yet is treated as a new checkout so that the seat limit
+	//is enforced.
+	seat, err := db.GetLicenseSeat(r.Context(), tx, l.ID, clientID)
+	if err != nil && err != sql.ErrNoRows {
+		output.Error(err, "Could not check out seat (2).", w)
+		return
+	}
+	holdsSeat := err == nil && seat.LeaseExpires > now
+
+	if !holdsSeat {
+		inUse, err := db.CountLicenseSeatsInUse(r.Context(), tx, l.ID)
+		if err != nil {
+			output.Error(err, "Could not check out seat (3).", w)
+			return
+		}
+		if inUse >= maxSeats {
+			res.InUse = inUse
+			output.DataFound(res, w)
+			return
+		}
+	}
+
+	if seat.ID > 0 {
+		seat.DatetimeModified = now
+		seat.LeaseExpires = leaseExpires
+		err = seat.RenewLease(r.Context(), tx)
+	} else {
+		seat = db.LicenseSeat{
+			DatetimeCreated:   now,
+			CreatedByAPIKeyID: apiKeyID,
+			LicenseID:         l.ID,
+			ClientID:          clientID,
+			LeaseExpires:      leaseExpires,
+		}
+		err = seat.Insert(r.Context(), tx)
+	}
+	if err != nil {
+		output.Error(err, "Could not check out seat (4).", w)
+		return
+	}
+
+	inUse, err := db.CountLicenseSeatsInUse(r.Context(), tx, l.ID)
+	if err != nil {
+		output.Error(err, "Could not check out seat (5).", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not check out seat (6).", w)
+		return
+	}
+
+	res.CheckedOut = true
+	res.InUse = inUse
+	res.LeaseExpires = leaseExpires
+	output.DataFound(res, w)
+}
+
+// CheckIn releases a seat of a license held by a client. Checking in a seat that the
+// client doesn't hold is not an error so that clients can always check in a seat
+// when stopping.
+func CheckIn(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	publicID := strings.TrimSpace(r.FormValue("publicID"))
+	clientID := strings.TrimSpace(r.FormValue("clientID"))
+
+	//Validate.
+	if publicID == "" {
+		output.ErrorInputInvalid("Could not determine which license you want to check in a seat to.", w)
+		return
+	}
+	if clientID == "" {
+		output.ErrorInputInvalid("You must provide an identifier for the client checking in a seat.", w)
+		return
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	l, errMsg, err := getPublicLicense(r.Context(), publicID, apiKeyID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	maxSeats, _, err := getMaxSeats(r.Context(), l.ID)
+	if err != nil {
+		output.Error(err, "Could not look up the maximum number of seats for this license.", w)
+		return
+	}
+
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not check in seat (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = db.DeleteLicenseSeat(r.Context(), tx, l.ID, clientID)
+	if err != nil {
+		output.Error(err, "Could not check in seat (2).", w)
+		return
+	}
+
+	inUse, err := db.CountLicenseSeatsInUse(r.Context(), tx, l.ID)
+	if err != nil {
+		output.Error(err, "Could not check in seat (3).", w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not check in seat (4).", w)
+		return
+	}
+
+	output.DataFound(seatResult{
+		PublicID: publicID,
+		ClientID: clientID,
+		InUse:    inUse,
+		MaxSeats: maxSeats,
+	}, w)
+}
+
+// getPublicLicense looks up a license by its public ID, for example the license a seat
+// is being checked out from or checked in to, and makes sure the API key is allowed
+// to access licenses for the license's app.
+func getPublicLicense(ctx context.Context, publicID string, apiKeyID int64) (l db.License, errMsg string, err error) {
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".Verified",
+		db.TableApps + ".ID AS AppID",
+		db.LicenseExpiredColumn,
+	}
+	l, err = db.GetLicenseByPublicID(ctx, publicID, cols)
+	if err == sql.ErrNoRows {
+		return l, "The license provided does not exist.", nil
+	} else if err != nil {
+		errMsg = "Could not look up license."
+		return
+	}
+	db.SetActivityLogLicenseID(ctx, l.ID)
+
+	if apiKeyID > 0 {
+		allowed, innerErr := apiKeyAllowedApp(ctx, apiKeyID, l.AppID)
+		if innerErr != nil {
+			err = innerErr
+			errMsg = "Could not determine if this API key can access licenses for this app."
+			return
+		} else if !allowed {
+			errMsg = "This API key is not allowed to access licenses for this app."
+			return
+		}
+	}
+
+	return
+}
+
+// getMaxSeats returns the maximum number of seats for a license from the license's
+// custom field result named per the config file. found is false if the license does
+// not have a result for the field, meaning the license is not a floating license.
+func getMaxSeats(ctx context.Context, licenseID int64) (maxSeats int64, found bool, err error) {
+	return getIntegerFieldResult(ctx, licenseID, config.Data().LicenseSeatsFieldName)
+}
+
+// getIntegerFieldResult returns the value of a license's integer custom field result
+// with the given name. found is false if the license does not have a result for the
+// field.
+func getIntegerFieldResult(ctx context.Context, licenseID int64, name string) (value int64, found bool, err error) {
+	results, err := db.GetCustomFieldResults(ctx, licenseID)
+	if err != nil {
+		return
+	}
+
+	for _, r := range results {
+		if r.CustomFieldName != name || r.CustomFieldType != db.CustomFieldTypeInteger {
+			continue
+		}
+
+		return r.IntegerValue.Int64, true, nil
+	}
+
+	return
+}
+
+// StartSeatSweeper reclaims seats with expired leases on a ticker.
+//
+// This should be called in a goroutine since it never returns.
+func StartSeatSweeper() {
+	ticker := time.NewTicker(seatSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		//Skip while the app is in maintenance mode since changes should not be made.
+		//This is not logged since it runs often.
+		if config.MaintenanceMode() {
+			continue
+		}
+
+		deleted, err := db.DeleteExpiredLicenseSeats(context.Background())
+		if err != nil {
+			log.Println("license.StartSeatSweeper", "could not reclaim expired seats", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Println("license.StartSeatSweeper", "reclaimed", strconv.FormatInt(deleted, 10), "expired seats")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package license
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/c9845/licensekeys/v3/config"
+)
+
+// This file handles limiting how many licenses are signed and verified at the same
+// time during bulk operations, for example importing licenses. Signing and verifying
+// is CPU heavy, so running an unbounded number at once under a large bulk operation
+// can starve the rest of the app. Single license operations, such as creating one
+// license, do not use the pool.
+//
+// One pool is shared by all bulk operations so that the limit applies to the app as
+// a whole, not to each request.
+
+// signingPool runs functions with a maximum number running at the same time.
+type signingPool struct {
+	sem chan struct{}
+}
+
+// newSigningPool returns a pool that runs at most size functions at once. A size
+// less than 1 is treated as 1.
+func newSigningPool(size int) *signingPool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &signingPool{
+		sem: make(chan struct{}, size),
+	}
+}
+
+var (
+	sharedSigningPool     *signingPool
+	sharedSigningPoolOnce sync.Once
+)
+
+// getSigningPool returns the pool shared by all bulk operations, sized per the
+// config file.
+func getSigningPool() *signingPool {
+	sharedSigningPoolOnce.Do(func() {
+		sharedSigningPool = newSigningPool(config.Data().SigningConcurrency)
+	})
+
+	return sharedSigningPool
+}
+
+// run calls fn once for each index from 0 to n-1 and waits for all calls to finish.
+//
+// The returned errs are in the same order as the indexes, with a nil for each call
+// that succeeded, so that each error can be matched up to the input it was for. err
+// is each non-nil error joined together, prefixed with the index, or nil if every
+// call succeeded. One call failing does not stop the other calls from running.
+func (p *signingPool) run(n int, fn func(i int) error) (errs []error, err error) {
+	errs = make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		p.sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-p.sem
+				wg.Done()
+			}()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	joined := []error{}
+	for i, e := range errs {
+		if e != nil {
+			joined = append(joined, fmt.Errorf("%d: %w", i, e))
+		}
+	}
+
+	return errs, errors.Join(joined...)
+}
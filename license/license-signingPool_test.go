@@ -0,0 +1,134 @@
+package license
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigningPoolRunOrder(t *testing.T) {
+	p := newSigningPool(4)
+
+	//Later indexes finish first so results would be out of order if they were
+	//collected as each call finished.
+	const n = 20
+	results := make([]int, n)
+	errs, err := p.run(n, func(i int) error {
+		time.Sleep(time.Duration(n-i) * time.Millisecond)
+		results[i] = i * 2
+		return nil
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+		return
+	}
+	if len(errs) != n {
+		t.Fatal("wrong number of errors returned", len(errs))
+		return
+	}
+
+	for i, r := range results {
+		if r != i*2 {
+			t.Fatal("result out of order at index", i, r)
+			return
+		}
+		if errs[i] != nil {
+			t.Fatal("unexpected error at index", i, errs[i])
+			return
+		}
+	}
+}
+
+func TestSigningPoolRunErrors(t *testing.T) {
+	p := newSigningPool(3)
+
+	errOdd := errors.New("odd")
+	errs, err := p.run(10, func(i int) error {
+		if i%2 == 1 {
+			return errOdd
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("error expected")
+		return
+	}
+	if !errors.Is(err, errOdd) {
+		t.Fatal("joined error should wrap each error", err)
+		return
+	}
+
+	for i, e := range errs {
+		if i%2 == 1 && e != errOdd {
+			t.Fatal("error expected at index", i, e)
+			return
+		} else if i%2 == 0 && e != nil {
+			t.Fatal("no error expected at index", i, e)
+			return
+		}
+	}
+
+	//Joined error should note which index each error is for.
+	for _, i := range []int{1, 3, 5, 7, 9} {
+		want := strconv.Itoa(i) + ": odd"
+		if !slices.Contains(strings.Split(err.Error(), "\n"), want) {
+			t.Fatal("joined error missing", want, err)
+			return
+		}
+	}
+}
+
+func TestSigningPoolRunConcurrency(t *testing.T) {
+	const size = 3
+	p := newSigningPool(size)
+
+	var running, maxRunning int32
+	_, err := p.run(30, func(i int) error {
+		r := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+				break
+			}
+		}
+
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+		return
+	}
+
+	if maxRunning > size {
+		t.Fatal("too many calls ran at once", maxRunning)
+		return
+	}
+	if maxRunning < 2 {
+		t.Fatal("calls did not run concurrently", maxRunning)
+		return
+	}
+}
+
+func TestNewSigningPool(t *testing.T) {
+	p := newSigningPool(0)
+	if cap(p.sem) != 1 {
+		t.Fatal("size less than 1 should be treated as 1", cap(p.sem))
+		return
+	}
+
+	errs, err := p.run(0, func(i int) error {
+		t.Fatal("fn should not be called")
+		return nil
+	})
+	if err != nil || len(errs) != 0 {
+		t.Fatal("nothing should be returned when no calls are made", errs, err)
+		return
+	}
+}
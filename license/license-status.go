@@ -0,0 +1,145 @@
+package license
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles looking up the status of many licenses at once. This is used by
+// integrations, such as a partner's dashboard, that need to refresh the state of many
+// licenses without making a request per license. Only the state of each license is
+// returned, no customer details, so that this endpoint cannot be used to collect data
+// about customers.
+
+// statusBatchMaxSize is the most public IDs that can be looked up in one request.
+// This prevents a single request from scanning an excessive number of licenses.
+const statusBatchMaxSize = 100
+
+// statusResult is the state of a single license. A license that does not exist, or
+// that the API key is not allowed to access, is returned with Found set to false.
+// These are not differentiated so that the existence of licenses for other apps
+// cannot be determined.
+type statusResult struct {
+	PublicID            string
+	Found               bool
+	Active              bool   //false if the license was disabled.
+	Expired             bool   //
+	ExpireDate          string //yyyy-mm-dd, UTC timezone.
+	DaysUntilExpiration int    //negative once the license is expired.
+}
+
+// StatusBatch returns the state of each license with the provided public IDs. The
+// public IDs are provided as a JSON array of strings. Results are returned in the
+// same order as the public IDs were provided.
+func StatusBatch(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	raw := r.FormValue("publicIDs")
+
+	var publicIDs []string
+	err := json.Unmarshal([]byte(raw), &publicIDs)
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse the list of public IDs. The public IDs must be provided as a JSON array of strings.", w)
+		return
+	}
+
+	//Validate.
+	for i, p := range publicIDs {
+		publicIDs[i] = strings.TrimSpace(p)
+	}
+	if len(publicIDs) == 0 {
+		output.ErrorInputInvalid("You must provide at least one public ID.", w)
+		return
+	}
+	if len(publicIDs) > statusBatchMaxSize {
+		output.ErrorInputInvalid("You can look up at most "+strconv.Itoa(statusBatchMaxSize)+" licenses at once.", w)
+		return
+	}
+
+	_, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+
+	//Look up the licenses.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".PublicID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".ExpireDate",
+		db.TableLicenses + ".ExpireDatetime",
+		db.TableApps + ".ID AS AppID",
+		db.LicenseExpiredColumn,
+	}
+	lics, err := db.GetLicensesByPublicIDs(r.Context(), publicIDs, cols)
+	if err != nil {
+		output.Error(err, "Could not look up licenses.", w)
+		return
+	}
+
+	//Check which apps the API key can access. This is cached per app since many
+	//licenses will be for the same app.
+	allowedApps := map[int64]bool{}
+	found := map[string]db.License{}
+	for _, l := range lics {
+		allowed, ok := allowedApps[l.AppID]
+		if !ok && apiKeyID > 0 {
+			allowed, err = apiKeyAllowedApp(r.Context(), apiKeyID, l.AppID)
+			if err != nil {
+				output.Error(err, "Could not determine if this API key can access licenses for this app.", w)
+				return
+			}
+			allowedApps[l.AppID] = allowed
+		} else if !ok {
+			allowed = true
+		}
+
+		if allowed {
+			found[l.PublicID] = l
+		}
+	}
+
+	//Build results.
+	now := time.Now().UTC()
+	results := make([]statusResult, 0, len(publicIDs))
+	for _, p := range publicIDs {
+		l, ok := found[p]
+		if !ok {
+			results = append(results, statusResult{PublicID: p})
+			continue
+		}
+
+		results = append(results, statusResult{
+			PublicID:            p,
+			Found:               true,
+			Active:              l.Active,
+			Expired:             l.Expired,
+			ExpireDate:          l.ExpireDate,
+			DaysUntilExpiration: daysUntilExpiration(l, now),
+		})
+	}
+
+	output.DataFound(results, w)
+}
+
+// daysUntilExpiration returns the number of whole days until a license expires. This
+// uses the more precise ExpireDatetime, if set, the same as the Expired column. A
+// negative value is returned once a license is expired.
+func daysUntilExpiration(l db.License, now time.Time) int {
+	exp, err := time.Parse(time.RFC3339, l.ExpireDatetime)
+	if err != nil {
+		exp, err = time.Parse("2006-01-02", l.ExpireDate)
+		if err != nil {
+			return 0
+		}
+	}
+
+	return int(math.Floor(exp.Sub(now).Hours() / 24))
+}
@@ -0,0 +1,244 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles templates used to create licenses for standard products. A
+// template saves the app, key pair, license period, custom field values, and
+// features. When a license is created with a templateID, anything not provided in
+// the request is filled in from the template and the license is validated as usual.
+
+// Templates returns the list of templates, optionally filtered by app.
+func Templates(w http.ResponseWriter, r *http.Request) {
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	tt, err := db.GetLicenseTemplates(r.Context(), appID)
+	if err != nil {
+		output.Error(err, "Could not look up templates.", w)
+		return
+	}
+
+	output.DataFound(tt, w)
+}
+
+// AddTemplate saves a new template.
+func AddTemplate(w http.ResponseWriter, r *http.Request) {
+	//Get input data.
+	raw := r.FormValue("data")
+
+	//Parse data into struct.
+	var t db.LicenseTemplate
+	err := json.Unmarshal([]byte(raw), &t)
+	if err != nil {
+		output.Error(err, "Could not parse data to add template.", w)
+		return
+	}
+
+	//Make sure this isn't being called with an already existing template.
+	if t.ID != 0 {
+		output.ErrorAlreadyExists("Could not determine if you are adding or updating a template.", w)
+		return
+	}
+
+	//Validate.
+	errMsg, err := t.Validate(r.Context())
+	if err != nil {
+		output.Error(err, "Could not validate data about this template.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	t.Features, errMsg, err = parseFeatures(r.Context(), t.AppID, r.FormValue("features"))
+	if err != nil {
+		output.Error(err, "Could not validate features for this template.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Get user who is adding this template.
+	loggedInUserID, err := users.GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	t.CreatedByUserID = loggedInUserID
+
+	//Save.
+	err = t.Insert(r.Context())
+	if err != nil {
+		output.Error(err, "Could not save template.", w)
+		return
+	}
+
+	output.InsertOK(t.ID, w)
+}
+
+// DeleteTemplate marks a template as inactive. The template can no longer be used to
+// create licenses. Licenses already created from the template are not changed.
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which template you want to delete.", w)
+		return
+	}
+
+	t := db.LicenseTemplate{
+		ID: id,
+	}
+	err := t.Delete(r.Context())
+	if err != nil {
+		output.Error(err, "Could not delete template.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// AppliedTemplate is the data used to fill in the GUI when creating a license from a
+// template.
+type AppliedTemplate struct {
+	License      db.License                //only AppID, KeyPairID, and ExpireDate are set.
+	CustomFields db.MultiCustomFieldResult //only the fields with a value saved in the template.
+	Features     []string
+}
+
+// ApplyTemplate returns the data to fill in when creating a license from a template.
+// The key pair and expiration date are resolved from the app's defaults if they are
+// not set in the template.
+func ApplyTemplate(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id < 1 {
+		output.ErrorInputInvalid("Could not determine which template you want to use.", w)
+		return
+	}
+
+	t, errMsg, err := getTemplate(r.Context(), id)
+	if err != nil {
+		output.Error(err, "Could not look up template.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	var l db.License
+	applyTemplate(r.Context(), t, &l)
+
+	fields, err := templateCustomFields(t, nil)
+	if err != nil {
+		output.Error(err, "Could not parse custom fields from template.", w)
+		return
+	}
+
+	//Resolve the defaults so the GUI shows what will actually be used.
+	if l.KeyPairID < 1 {
+		kp, err := db.GetDefaultKeyPair(r.Context(), t.AppID)
+		if err != nil && err != sql.ErrNoRows {
+			output.Error(err, "Could not look up default key pair.", w)
+			return
+		}
+		l.KeyPairID = kp.ID
+	}
+	if l.ExpireDate == "" {
+		a, err := db.GetAppByID(r.Context(), t.AppID)
+		if err != nil {
+			output.Error(err, "Could not look up app details.", w)
+			return
+		}
+		if a.DaysToExpiration > 0 {
+			today := time.Now().In(config.GetLocationFromContext(r.Context()))
+			l.ExpireDate = today.AddDate(0, 0, a.DaysToExpiration).Format("2006-01-02")
+		}
+	}
+
+	output.DataFound(AppliedTemplate{
+		License:      l,
+		CustomFields: fields,
+		Features:     splitFeatures(t.Features),
+	}, w)
+}
+
+// getTemplate looks up a template for creating a license.
+func getTemplate(ctx context.Context, templateID int64) (t db.LicenseTemplate, errMsg string, err error) {
+	t, err = db.GetLicenseTemplateByID(ctx, templateID)
+	if err == sql.ErrNoRows {
+		err = nil
+		errMsg = "The template could not be found. It may have been deleted."
+		return
+	}
+
+	return
+}
+
+// applyTemplate fills in the app, key pair, and expiration of a license from a
+// template if they were not provided. The license must still be validated.
+func applyTemplate(ctx context.Context, t db.LicenseTemplate, l *db.License) {
+	if l.KeyPairID < 1 && l.AppID < 1 {
+		l.AppID = t.AppID
+		l.KeyPairID = t.KeyPairID
+	}
+
+	if l.ExpireDate == "" && l.ExpireDatetime == "" && t.DurationDays > 0 {
+		today := time.Now().In(config.GetLocationFromContext(ctx))
+		l.ExpireDate = today.AddDate(0, 0, t.DurationDays).Format("2006-01-02")
+	}
+}
+
+// templateCustomFields returns the custom field values saved in a template if no
+// fields were provided. Otherwise, the provided fields are returned as-is so that
+// the request overrides the template.
+func templateCustomFields(t db.LicenseTemplate, provided db.MultiCustomFieldResult) (fields db.MultiCustomFieldResult, err error) {
+	if len(provided) > 0 || t.CustomFields == "" {
+		return provided, nil
+	}
+
+	err = json.Unmarshal([]byte(t.CustomFields), &fields)
+	return
+}
+
+// templateFeatures returns the features saved in a template as a JSON array, the
+// same as is provided when creating a license, if no features were provided.
+// Otherwise, the provided features are returned as-is.
+func templateFeatures(t db.LicenseTemplate, provided string) (string, error) {
+	if strings.TrimSpace(provided) != "" || t.Features == "" {
+		return provided, nil
+	}
+
+	b, err := json.Marshal(splitFeatures(t.Features))
+	return string(b), err
+}
+
+// setCustomFieldResultFromTemplate sets the value for a custom field result to the
+// value saved in a template, if the template has a value for the field. The value is
+// not used if the field's type was changed after the template was saved.
+func setCustomFieldResultFromTemplate(c *db.CustomFieldResult, definedField db.CustomFieldDefined, templateFields db.MultiCustomFieldResult) {
+	for _, tf := range templateFields {
+		if tf.CustomFieldDefinedID != definedField.ID || tf.CustomFieldType != definedField.Type {
+			continue
+		}
+
+		c.IntegerValue = tf.IntegerValue
+		c.DecimalValue = tf.DecimalValue
+		c.TextValue = tf.TextValue
+		c.BoolValue = tf.BoolValue
+		c.MultiChoiceValue = tf.MultiChoiceValue
+		c.DateValue = tf.DateValue
+		return
+	}
+}
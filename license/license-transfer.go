@@ -0,0 +1,388 @@
+package license
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/timestamps"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+	"gopkg.in/guregu/null.v3"
+)
+
+// This file handles transferring a license from one company to another. For example,
+// when a customer is acquired or sells the product the license is for.
+
+// transferResult is the data returned when a license is transferred.
+type transferResult struct {
+	FromLicenseID int64 //the original license, now disabled.
+	ToLicenseID   int64 //the new license for the new company.
+}
+
+// Transfer creates a new license from an existing license, just with new company and
+// contact details. This creates a copy of the existing license's common data, including
+// the expiration, and custom field results. The transfer relationship is also saved to
+// link the licenses together.
+//
+// The original license is disabled so it cannot be mistakenly downloaded.
+func Transfer(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	fromLicenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	companyName := strings.TrimSpace(r.FormValue("companyName"))
+	contactName := strings.TrimSpace(r.FormValue("contactName"))
+	phoneNumber := strings.TrimSpace(r.FormValue("phoneNumber"))
+	email := strings.TrimSpace(r.FormValue("email"))
+
+	//Validate.
+	if fromLicenseID < 1 {
+		output.ErrorInputInvalid("Could not determine which license you want to transfer.", w)
+		return
+	}
+	db.SetActivityLogLicenseID(r.Context(), fromLicenseID)
+
+	//Look up existing license data so we can confirm it hasn't been disabled or
+	//expired. The app ID is needed to look up the app's custom fields and to check if
+	//an API key is allowed to create licenses for the app.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".*",
+		db.TableApps + ".ID AS AppID",
+		db.LicenseExpiredColumn,
+	}
+	fromLicense, err := db.GetLicense(r.Context(), fromLicenseID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The license ID provided does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up existing license's data.", w)
+		return
+	}
+	if !fromLicense.Active {
+		output.ErrorInputInvalid("This license has been disabled and cannot be transferred.", w)
+		return
+	}
+	if fromLicense.Expired {
+		output.ErrorInputInvalid("This license is expired and cannot be transferred.", w)
+		return
+	}
+
+	//Make sure this license hasn't already been transferred. A license can only be
+	//transferred once, the new license should be transferred instead.
+	_, err = db.GetTransferRelationshipByFromID(r.Context(), fromLicenseID)
+	if err != nil && err != sql.ErrNoRows {
+		output.Error(err, "Could not determine if this license has already been transferred.", w)
+		return
+	} else if err == nil {
+		output.ErrorInputInvalid("This license has already been transferred.", w)
+		return
+	}
+
+	//Build the custom field results for the transferred license. Results are carried
+	//over from the existing license as-is.
+	fromResults, err := db.GetCustomFieldResults(r.Context(), fromLicenseID)
+	if err != nil {
+		output.Error(err, "Could not look up existing license's custom field results.", w)
+		return
+	}
+
+	ff, droppedFields, _, err := carryOverCustomFieldResults(r.Context(), fromLicense.AppID, fromResults, nil)
+	if err != nil {
+		output.Error(err, "Could not build custom field results for transferred license.", w)
+		return
+	}
+
+	//Revalidate the custom field results since a field's rules may have changed
+	//since the existing license was created.
+	errMsg, err := ff.Validate(r.Context(), fromLicense.AppID, fromLicense.ExpireDate, false)
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate custom field results for transferred license.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid("A custom field value from the existing license is no longer valid, renew the license instead. "+errMsg, w)
+		return
+	}
+
+	//Get a copy of the "from" license's data to use for the "to" license.
+	toLicense := fromLicense
+
+	//Unset created-by fields from old/copy-from license.
+	toLicense.CreatedByAPIKeyID = null.IntFrom(0)
+	toLicense.CreatedByUserID = null.IntFrom(0)
+
+	//The "to" license is created, and signed, by this app even if the "from" license
+	//was imported.
+	toLicense.Imported = false
+
+	//Get info about who or what is transferring this license.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+	if userID > 0 {
+		toLicense.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		toLicense.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	//Make sure the API key, if this license is being transferred via the API, is
+	//allowed to create licenses for this app.
+	if apiKeyID > 0 {
+		allowed, err := apiKeyAllowedApp(r.Context(), apiKeyID, toLicense.AppID)
+		if err != nil {
+			output.Error(err, "Could not determine if this API key can create licenses for this app.", w)
+			return
+		} else if !allowed {
+			output.ErrorInputInvalid("This API key is not allowed to create licenses for this app.", w)
+			return
+		}
+	}
+
+	//Require the user to provide their 2FA token again, if needed, since transferring a
+	//license creates a new license.
+	if !checkLicenseCreationStepUp(w, r, userID) {
+		return
+	}
+
+	//Get DatetimeCreated value. This way we will have the exact same value for the
+	//license, custom field results, and transfer relationship.
+	datetimeCreated := timestamps.YMDHMS()
+
+	//Modify existing license data for new license. The expiration is carried over,
+	//so the new company and contact details are validated the same as when a license
+	//is created.
+	toLicense.ID = 0                             //this will be populated with the new license's ID in Insert().
+	toLicense.CompanyName = companyName          //
+	toLicense.ContactName = contactName          //
+	toLicense.PhoneNumber = phoneNumber          //
+	toLicense.Email = email                      //
+	toLicense.DatetimeModified = ""              //license hasn't been modified, so unset this to reduce confusion.
+	toLicense.IssueDate = timestamps.YMD()       //
+	toLicense.IssueTimestamp = time.Now().Unix() //
+	toLicense.Signature = ""                     //will be set later...
+	toLicense.IdempotencyKey = ""                //only set for licenses created via the API, see AddViaAPI().
+	toLicense.Verified = false                   //will be set later...
+	toLicense.DatetimeCreated = datetimeCreated
+
+	errMsg, err = toLicense.Validate(r.Context())
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate transferred license.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Start transaction since we are saving multiple things.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save transferred license (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	//Save common data. This will get us the license ID which we need to save the
+	//custom field results and possible for use in the license if required per the
+	//app's details.
+	err = toLicense.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save transferred license (2).", w)
+		return
+	}
+
+	//Save each custom field result.
+	for _, f := range ff {
+		if userID > 0 {
+			f.CreatedByUserID = null.IntFrom(userID)
+			f.CreatedByAPIKeyID = null.IntFrom(0) //unset from old/copy-from license just in case.
+		} else if apiKeyID > 0 {
+			f.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+			f.CreatedByUserID = null.IntFrom(0) //unset from old/copy-from license just in case.
+		}
+
+		f.LicenseID = toLicense.ID
+		f.DatetimeCreated = datetimeCreated
+
+		innerErr := f.Insert(r.Context(), tx)
+		if innerErr != nil {
+			output.Error(innerErr, "Could not save field \""+f.CustomFieldName+"\" therefore license could not be saved.", w)
+			return
+		}
+	}
+
+	//Create the transfer relationship.
+	relationship := db.TransferRelationship{
+		FromLicenseID:   fromLicenseID,
+		ToLicenseID:     toLicense.ID,
+		DatetimeCreated: datetimeCreated,
+	}
+
+	if userID > 0 {
+		relationship.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		relationship.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = relationship.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save transfer relationship.", w)
+		return
+	}
+
+	//Save a note to the transferred license noting where it came from and listing
+	//any custom fields that were not carried over since they are no longer defined
+	//for the app.
+	toNote := "License was transferred from license " + strconv.FormatInt(fromLicenseID, 10) + " (" + fromLicense.CompanyName + ")."
+	if len(droppedFields) > 0 {
+		toNote += " Custom fields were not carried over since they are no longer defined: " + strings.Join(droppedFields, ", ") + "."
+	}
+
+	tn := db.LicenseNote{
+		LicenseID: toLicense.ID,
+		Note:      toNote,
+	}
+	if userID > 0 {
+		tn.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		tn.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = tn.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about transferred-from license.", w)
+		return
+	}
+
+	//Disable the "transferred-from" license so that it cannot be mistakenly
+	//downloaded.
+	err = db.DisableLicense(r.Context(), fromLicenseID, tx)
+	if err != nil {
+		output.Error(err, "Could not mark transferred-from license as disabled.", w)
+		return
+	}
+
+	//Save a note to the "transferred-from" license explaining that it is disabled
+	//because it was transferred to a new license.
+	n := db.LicenseNote{
+		LicenseID: fromLicenseID,
+		Note:      "License was disabled because it was transferred to license " + strconv.FormatInt(toLicense.ID, 10) + " (" + toLicense.CompanyName + ").",
+	}
+	if userID > 0 {
+		n.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		n.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
+
+	err = n.Insert(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not add note about transferred-from license.", w)
+		return
+	}
+
+	//
+	//All the db queries needed to copy data have occured, the transferred license
+	//now exists. However, we still need to perform the other "after inserting a
+	//license" stuff.
+	//
+
+	//Get key pair data. We need this to get the private key info to sign the license
+	//file.
+	kp, err := db.GetKeyPairByID(r.Context(), toLicense.KeyPairID)
+	if err != nil {
+		output.Error(err, "Could not look up signature details.", w)
+		return
+	}
+	errMsg = checkSigningKeyPair(kp, "the original license", "transferred")
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Create the transferred license file.
+	f, err := buildLicense(toLicense, ff)
+	if err != nil {
+		output.Error(err, "Could not build license for signing and verification.", w)
+		return
+	}
+
+	//Get the keys to sign the license with. The license is co-signed, the same as
+	//when a license is created, if the app requires more than one signature.
+	a, err := db.GetAppByID(r.Context(), toLicense.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
+
+	keys, errMsg, err := getLicenseSigningKeys(r.Context(), a, kp)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Sign the license file.
+	err = keys.sign(&f)
+	if err != nil {
+		output.Error(err, "Could not generate signature.", w)
+		return
+	}
+
+	//Save the signature
+	toLicense.Signature = f.Signature
+	toLicense.Signatures = joinSignatures(f.Signatures)
+	toLicense.SignatureAlgorithm = f.Algorithm
+	toLicense.FormatVersion = f.FormatVersion
+	err = toLicense.SaveSignature(r.Context(), tx)
+	if err != nil {
+		output.Error(err, "Could not save signature.", w)
+		return
+	}
+
+	//Commit now to save the license even though we don't know if it is can be
+	//successfully validated with the public key.
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not complete saving of transferred license.", w)
+		return
+	}
+
+	//Verify the just created license data and signature.
+	err = keys.verify(f)
+	if err == licensefile.ErrBadSignature {
+		output.Error(licensefile.ErrBadSignature, "Transferred license could not be verified and therefore cannot be used. Please contact an administrator and have them investigate this error.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "An error occured while trying to verify the license. Please ask an administrator to investigate this error.", w)
+		return
+	}
+
+	//Mark the license as verified.
+	toLicense.Verified = true
+	err = toLicense.MarkVerified(r.Context())
+	if err != nil {
+		output.Error(err, "Could not mark license as valid.", w)
+		return
+	}
+
+	recordLicenseCreated(apiKeyID)
+	notifyLicenseEvent(r.Context(), config.NotificationEventLicenseCreated, toLicense, userID, apiKeyID)
+
+	output.InsertOKWithData(transferResult{
+		FromLicenseID: fromLicenseID,
+		ToLicenseID:   toLicense.ID,
+	}, w)
+}
@@ -0,0 +1,86 @@
+package license
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file specifically deals with checking the status of a license by its public
+// ID. This is used for the public, non-authenticated, license verification page so
+// that customers can confirm their license is valid without contacting us.
+
+// PublicStatus is the non-sensitive status of a license. This is the only data about
+// a license that is shown publicly. Company and contact details and custom field
+// values are never included.
+type PublicStatus struct {
+	PublicID       string
+	Found          bool   //false if no license exists with the public ID.
+	ValidSignature bool   //true if the stored signature verifies with the key pair's public key.
+	Active         bool   //false if the license has been disabled.
+	Expired        bool   //true if the license's expiration date is in the past.
+	ExpireDate     string //yyyy-mm-dd
+}
+
+// GetPublicStatus looks up a license by its public ID and returns its non-sensitive
+// status. The license file is rebuilt and the stored signature is verified against
+// the key pair's public key, the same as a third-party app would do.
+//
+// A license not existing is not an error, check the Found field.
+func GetPublicStatus(ctx context.Context, publicID string) (s PublicStatus, err error) {
+	publicID = strings.TrimSpace(publicID)
+	s.PublicID = publicID
+	if publicID == "" {
+		return
+	}
+
+	//Look up the license.
+	cols := sqldb.Columns{
+		db.TableLicenses + ".*",
+		db.LicenseExpiredColumn,
+	}
+	l, err := db.GetLicenseByPublicID(ctx, publicID, cols)
+	if err == sql.ErrNoRows {
+		return s, nil
+	} else if err != nil {
+		return
+	}
+
+	db.SetActivityLogLicenseID(ctx, l.ID)
+
+	s.Found = true
+	s.Active = l.Active
+	s.Expired = l.Expired
+	s.ExpireDate = l.ExpireDate
+
+	//A license that was never verified when it was created cannot have a valid
+	//signature.
+	if !l.Verified {
+		return
+	}
+
+	//Rebuild the license file and verify the stored signature.
+	kp, err := db.GetKeyPairByID(ctx, l.KeyPairID)
+	if err != nil {
+		return
+	}
+
+	cfr, err := db.GetCustomFieldResults(ctx, l.ID)
+	if err != nil {
+		return
+	}
+
+	f, err := buildLicense(l, cfr)
+	if err != nil {
+		return
+	}
+	f.Signature = l.Signature
+	f.Signatures = splitSignatures(l.Signatures)
+
+	//An invalid signature is a status, not an error.
+	s.ValidSignature = writeReadVerify(f, kp.AlgorithmType, []byte(kp.PublicKey)) == nil
+	return
+}
@@ -0,0 +1,282 @@
+package license
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/output"
+)
+
+// This file handles verifying many license files at once against the public keys of
+// the key pairs stored in this app. This is used as a testing and integration aid,
+// for example by a QA harness that generates many license files and needs to confirm
+// each one would be accepted by the app it is for, without writing verification code
+// for each key pair.
+//
+// The license files are provided as-is, the same as they would be read by a client
+// app, and are not looked up in the database. Each file is checked against every key
+// pair, of the chosen app or of all apps, that uses the same algorithm as the file's
+// signature. The key pair that verifies the signature is returned. Inactive key pairs
+// are checked as well since licenses signed with an inactive key pair are still valid.
+//
+// Verifying is done concurrently using the signing pool since verifying is the slow
+// part of checking a large batch of license files.
+
+// verifyBatchMaxSize is the most license files that can be verified in one request.
+// This prevents a single request from using an excessive amount of CPU.
+const verifyBatchMaxSize = 100
+
+// verifyBatchFile is a license file provided for verifying.
+type verifyBatchFile struct {
+	filename string //only set for uploaded files.
+	data     []byte
+}
+
+// verifyBatchResult is the result of verifying one license file. Error is set if the
+// file could not be read or its signature could not be verified. The key pair and
+// app are only set if a key pair verified the signature.
+type verifyBatchResult struct {
+	Index    int    //position of the file in the provided list.
+	Filename string //only set for uploaded files.
+
+	Valid      bool
+	Expired    bool
+	ExpireDate string //yyyy-mm-dd, as given in the file.
+
+	//ValidSignatures is the number of valid signatures for a co-signed license,
+	//checked against the key pairs of the app the license is for.
+	ValidSignatures int
+
+	KeyPairID   int64
+	KeyPairName string
+	AppID       int64
+	AppName     string
+
+	Error string
+}
+
+// verifyBatchKeyPair is a key pair, and the app it is for, that a license file can be
+// verified against.
+type verifyBatchKeyPair struct {
+	keyPair db.KeyPair
+	app     db.App
+}
+
+// VerifyBatch verifies a batch of license files against the public keys stored in
+// this app and returns the result for each file, in the same order as the files were
+// provided.
+//
+// The license files can be provided as a JSON array in the "files" form value, where
+// each element is either the contents of a license file as a string or a JSON license
+// file as an object, or as multiple uploaded files named "files". An "appID" can be
+// provided to only check against the key pairs of one app.
+func VerifyBatch(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	files, errMsg, err := getVerifyBatchFiles(r)
+	if err != nil {
+		output.Error(err, "Could not read the license files to verify.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
+
+	//Validate.
+	if len(files) == 0 {
+		output.ErrorInputInvalid("You must provide at least one license file to verify.", w)
+		return
+	}
+	if len(files) > verifyBatchMaxSize {
+		output.ErrorInputInvalid("You can verify at most "+strconv.Itoa(verifyBatchMaxSize)+" license files at once.", w)
+		return
+	}
+
+	//Look up the key pairs to verify against.
+	var apps []db.App
+	if appID > 0 {
+		a, err := db.GetAppByID(r.Context(), appID)
+		if err == sql.ErrNoRows {
+			output.ErrorInputInvalid("The app you chose does not exist.", w)
+			return
+		} else if err != nil {
+			output.Error(err, "Could not look up app.", w)
+			return
+		}
+
+		apps = append(apps, a)
+	} else {
+		apps, err = db.GetApps(r.Context(), false)
+		if err != nil {
+			output.Error(err, "Could not look up apps.", w)
+			return
+		}
+	}
+
+	var candidates []verifyBatchKeyPair
+	for _, a := range apps {
+		kk, err := db.GetKeyPairs(r.Context(), a.ID, false)
+		if err != nil {
+			output.Error(err, "Could not look up key pairs for "+a.Name+".", w)
+			return
+		}
+
+		for _, k := range kk {
+			candidates = append(candidates, verifyBatchKeyPair{keyPair: k, app: a})
+		}
+	}
+
+	//Verify each file. Errors are saved to each file's result rather than returned
+	//so that one bad file does not hide the results of the other files.
+	results := make([]verifyBatchResult, len(files))
+	getSigningPool().run(len(files), func(i int) error {
+		results[i] = verifyBatchOne(files[i], candidates)
+		results[i].Index = i
+		return nil
+	})
+
+	output.DataFound(results, w)
+}
+
+// getVerifyBatchFiles reads the license files provided in a request, either as
+// uploaded files or as a JSON array. An errMsg is returned if the provided data is
+// invalid, err is returned for any other errors.
+func getVerifyBatchFiles(r *http.Request) (files []verifyBatchFile, errMsg string, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		//The size of the request was already limited by middleware.MaxBodySize.
+		err = r.ParseMultipartForm(32 << 20)
+		if err != nil {
+			return
+		}
+
+		for _, header := range r.MultipartForm.File["files"] {
+			f, innerErr := header.Open()
+			if innerErr != nil {
+				return nil, "", innerErr
+			}
+
+			b, innerErr := io.ReadAll(f)
+			f.Close()
+			if innerErr != nil {
+				return nil, "", innerErr
+			}
+
+			files = append(files, verifyBatchFile{
+				filename: header.Filename,
+				data:     b,
+			})
+		}
+
+		//Fall back to the JSON array if no files were uploaded.
+		if len(files) > 0 {
+			return
+		}
+	}
+
+	//Each element is either the contents of a license file, as a string, or a JSON
+	//license file as an object.
+	var raw []json.RawMessage
+	innerErr := json.Unmarshal([]byte(r.FormValue("files")), &raw)
+	if innerErr != nil {
+		errMsg = "Could not parse the list of license files. The license files must be uploaded or provided as a JSON array."
+		return
+	}
+
+	for _, rm := range raw {
+		var s string
+		if json.Unmarshal(rm, &s) == nil {
+			files = append(files, verifyBatchFile{data: []byte(s)})
+		} else {
+			files = append(files, verifyBatchFile{data: rm})
+		}
+	}
+
+	return
+}
+
+// verifyBatchOne verifies one license file against the key pairs that use the same
+// algorithm as the file's signature.
+func verifyBatchOne(vf verifyBatchFile, candidates []verifyBatchKeyPair) (res verifyBatchResult) {
+	res.Filename = vf.filename
+
+	//Read the file.
+	f, err := licensefile.Unmarshal(vf.data, licensefile.DetectFileFormat(vf.data))
+	if err != nil {
+		res.Error = "Could not read license file. " + err.Error()
+		return
+	}
+	res.ExpireDate = f.ExpireDate
+
+	//Find the key pair that verifies the signature.
+	algo := f.SignatureAlgorithm()
+	var (
+		publicKeys [][]byte
+		matches    []verifyBatchKeyPair
+	)
+	for _, c := range candidates {
+		if c.keyPair.AlgorithmType != algo {
+			continue
+		}
+
+		publicKeys = append(publicKeys, []byte(c.keyPair.PublicKey))
+		matches = append(matches, c)
+	}
+
+	idx, err := f.VerifyAny(publicKeys, algo)
+	if errors.Is(err, licensefile.ErrNoPublicKeys) {
+		res.Error = "No key pair uses the " + string(algo) + " algorithm this license was signed with."
+		return
+	} else if err != nil {
+		res.Error = "No key pair could verify this license's signature."
+		return
+	}
+
+	match := matches[idx]
+	res.KeyPairID = match.keyPair.ID
+	res.KeyPairName = match.keyPair.Name
+	res.AppID = match.app.ID
+	res.AppName = match.app.Name
+
+	//A co-signed license must also have enough valid signatures from the app's key
+	//pairs, the same as the app would require when the license was created.
+	if len(f.Signatures) > 1 {
+		var keys []licensefile.VerifyingKey
+		for _, c := range candidates {
+			if c.app.ID != match.app.ID {
+				continue
+			}
+
+			keys = append(keys, licensefile.VerifyingKey{
+				PublicKey:   []byte(c.keyPair.PublicKey),
+				KeyPairAlgo: c.keyPair.AlgorithmType,
+			})
+		}
+
+		res.ValidSignatures, err = f.VerifyMulti(keys, match.app.RequiredSignatures)
+		if err != nil {
+			res.Error = "This license does not have enough valid signatures. " + err.Error()
+			return
+		}
+	}
+
+	res.Valid = true
+
+	//Only check expiration once the signature is verified, otherwise the expiration
+	//date is untrustworthy.
+	res.Expired, err = f.Expired()
+	if err != nil {
+		res.Error = "Could not determine if this license is expired. " + err.Error()
+		return
+	}
+
+	return
+}
@@ -6,14 +6,15 @@ package license
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"log"
 	"math"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -23,10 +24,13 @@ import (
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/licensekeys/v3/keypairs"
 	"github.com/c9845/licensekeys/v3/licensefile"
+	"github.com/c9845/licensekeys/v3/metrics"
+	"github.com/c9845/licensekeys/v3/notifications"
 	"github.com/c9845/licensekeys/v3/timestamps"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/output"
 	"github.com/c9845/sqldb/v3"
+	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -45,7 +49,16 @@ import (
 // Note that when adding a license via the public API, the request can have either the
 // AppID or KeyPairID. If the AppID is provided, then the default key pair's ID is used.
 // If the KeyPairID is provided, we simply use the parent app's ID.
+//
+// If the request provides an Idempotency-Key header that was already used by this API
+// key, the license created by the earlier request is returned instead of creating a
+// new license. This allows for safely retrying a request.
 func AddViaAPI(w http.ResponseWriter, r *http.Request) {
+	//Check if this request is a retry of a request that already created a license.
+	if replayIdempotentAdd(w, r) {
+		return
+	}
+
 	//Read input data and build license object.
 	appID, _ := strconv.ParseInt(r.FormValue("appID"), 10, 64)
 	keyPairID, _ := strconv.ParseInt(r.FormValue("keyPairID"), 10, 64)
@@ -58,6 +71,12 @@ func AddViaAPI(w http.ResponseWriter, r *http.Request) {
 		PhoneNumber: r.FormValue("phoneNumber"),
 		Email:       r.FormValue("email"),
 		ExpireDate:  r.FormValue("expireDate"),
+
+		ExpireDatetime: r.FormValue("expireDatetime"),
+		ValidFrom:      r.FormValue("validFrom"),
+
+		OrderReference: r.FormValue("orderReference"),
+		InternalNotes:  r.FormValue("internalNotes"),
 	}
 	encoded, err := json.Marshal(l)
 	if err != nil {
@@ -74,8 +93,26 @@ func AddViaAPI(w http.ResponseWriter, r *http.Request) {
 	//If user provided an app's ID, then we don't have to do anything. When the license
 	//data is validated in Add(), the default key pair's ID will be retrieved based on
 	//the provided app ID.
-	if keyPairID < 1 && l.AppID < 1 {
-		output.ErrorInputInvalid("Missing appID and keyPairID. One of these must be provided.", w)
+	//
+	//If a template was chosen and neither an app or key pair was provided, the
+	//template's app is used. The template's custom field values are used in place of
+	//the default value for any field not provided.
+	var tmpl db.LicenseTemplate
+	templateID, _ := strconv.ParseInt(r.FormValue("templateID"), 10, 64)
+	if templateID > 0 {
+		var errMsg string
+		tmpl, errMsg, err = getTemplate(r.Context(), templateID)
+		if err != nil {
+			output.Error(err, "Could not look up template.", w)
+			return
+		} else if errMsg != "" {
+			output.ErrorInputInvalid(errMsg, w)
+			return
+		}
+	}
+
+	if keyPairID < 1 && l.AppID < 1 && tmpl.ID < 1 {
+		output.ErrorInputInvalid("Missing appID and keyPairID. One of these, or a templateID, must be provided.", w)
 		return
 	}
 	if l.KeyPairID > 0 {
@@ -85,6 +122,14 @@ func AddViaAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		l.AppID = kp.AppID
+	} else if l.AppID < 1 {
+		l.AppID = tmpl.AppID
+	}
+
+	templateFields, err := templateCustomFields(tmpl, nil)
+	if err != nil {
+		output.Error(err, "Could not parse custom fields from template.", w)
+		return
 	}
 
 	//Look up custom fields defined for app. We need these to get field name to match
@@ -128,53 +173,22 @@ func AddViaAPI(w http.ResponseWriter, r *http.Request) {
 		//Find the matching value provided via the API request.
 		matchFound := false
 		for key, value := range object {
-			matchFound = true
-
 			if definedField.Name == key {
-				switch definedField.Type {
-				case db.CustomFieldTypeInteger:
-					c.IntegerValue = null.IntFrom(int64(value.(float64)))
-				case db.CustomFieldTypeDecimal:
-					c.DecimalValue = null.FloatFrom(value.(float64))
-				case db.CustomFieldTypeText:
-					c.TextValue = null.StringFrom(value.(string))
-				case db.CustomFieldTypeBoolean:
-					c.BoolValue = null.BoolFrom(value.(bool))
-				case db.CustomFieldTypeMultiChoice:
-					c.MultiChoiceValue = null.StringFrom(value.(string))
-				case db.CustomFieldTypeDate:
-					c.DateValue = null.StringFrom(value.(string))
-				default:
-					//This will never be hit because we looked up defined fields from
-					//db and these should always have valid types (unless db was
-					//modified manually).
+				matchFound = true
+
+				if !setCustomFieldResultValue(&c, definedField, value) {
+					output.ErrorInputInvalid("The value for the "+definedField.Name+" field is not the correct type.", w)
+					return
 				}
 			}
 		} //end for: find matching value for field name.
 
-		//Handle if no matching field was provided. In this case, we will just use the
-		//default value set for the field.
+		//Handle if no matching field was provided. In this case, we will use the
+		//template's value for the field, if a template was chosen and has a value for
+		//the field, or the default value set for the field.
 		if !matchFound {
-			switch definedField.Type {
-			case db.CustomFieldTypeInteger:
-				c.IntegerValue = null.IntFrom(definedField.IntegerDefaultValue.Int64)
-			case db.CustomFieldTypeDecimal:
-				c.DecimalValue = null.FloatFrom(definedField.DecimalDefaultValue.Float64)
-			case db.CustomFieldTypeText:
-				c.TextValue = null.StringFrom(definedField.TextDefaultValue.String)
-			case db.CustomFieldTypeBoolean:
-				c.BoolValue = null.BoolFrom(definedField.BoolDefaultValue.Bool)
-			case db.CustomFieldTypeMultiChoice:
-				c.MultiChoiceValue = null.StringFrom(definedField.MultiChoiceDefaultValue.String)
-			case db.CustomFieldTypeDate:
-				now := time.Now()
-				add := now.AddDate(0, 0, int(definedField.DateDefaultIncrement.Int64))
-				c.DateValue = null.StringFrom(add.Format("2006-01-02"))
-			default:
-				//This will never be hit because we looked up defined fields from db
-				//and these should always have valid types (unless db was modified
-				//manually).
-			}
+			setCustomFieldResultDefault(&c, definedField)
+			setCustomFieldResultFromTemplate(&c, definedField, templateFields)
 
 			// log.Println("license.AddViaAPI", "using default", definedField.Name)
 		} //end if: use default value for not provided field
@@ -197,21 +211,52 @@ func AddViaAPI(w http.ResponseWriter, r *http.Request) {
 	Add(w, r)
 }
 
-// Add saves the data used to create a license. First, we handle saving the common
-// license data, then we handle saving the custom field results, then we generate the
-// license file and sign it, we update the saved license data with the signature, and
-// finally we verify the license file by creating it, rereading it, and checking the
-// signature with the public key.
-func Add(w http.ResponseWriter, r *http.Request) {
+// newLicense is the validated data, and the looked up details, used to create a new
+// license. This is returned by prepareNewLicense().
+type newLicense struct {
+	License   db.License
+	Fields    db.MultiCustomFieldResult
+	KeyPair   db.KeyPair
+	App       db.App
+	CoSigners []db.KeyPair //additional key pairs to sign the license with, if the app requires more than one signature.
+}
+
+// prepareNewLicense parses and validates the data provided to create a license, looks
+// up the key pair and app the license is for, and sets the license data that is based
+// on the app. This is used by both Add and Preview so that a previewed license file
+// is built exactly the same way as a created license file. If false is returned, a
+// response has already been sent.
+//
+// Nothing specific to who is creating the license, or to saving the license, is
+// handled here.
+func prepareNewLicense(w http.ResponseWriter, r *http.Request) (nl newLicense, ok bool) {
 	//Parse and validate main license data.
 	rawCommonData := r.FormValue("licenseData")
 	var l db.License
 	err := json.Unmarshal([]byte(rawCommonData), &l)
 	if err != nil {
-		output.Error(err, "Could not parse license data to create license.", w)
+		output.Error(err, "Could not parse license data.", w)
 		return
 	}
 
+	//Fill in the license from a template, if one was chosen. Anything provided in
+	//the request overrides the template.
+	var tmpl db.LicenseTemplate
+	templateID, _ := strconv.ParseInt(r.FormValue("templateID"), 10, 64)
+	if templateID > 0 {
+		var errMsg string
+		tmpl, errMsg, err = getTemplate(r.Context(), templateID)
+		if err != nil {
+			output.Error(err, "Could not look up template.", w)
+			return
+		} else if errMsg != "" {
+			output.ErrorInputInvalid(errMsg, w)
+			return
+		}
+
+		applyTemplate(r.Context(), tmpl, &l)
+	}
+
 	errMsg, err := l.Validate(r.Context())
 	if err != nil && errMsg != "" {
 		output.Error(err, errMsg, w)
@@ -237,6 +282,15 @@ func Add(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("This key pair is not active. Please choose an active key pair for signing this license.", w)
 		return
 	}
+	if kp.Compromised {
+		output.ErrorInputInvalid("This key pair is marked as compromised. Please choose a different key pair for signing this license.", w)
+		return
+	}
+	errMsg = keypairs.MinimumErrMsg(kp.AlgorithmType)
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
 
 	//Get app data. We need this for the file format, signature hash algorithm and the
 	//encoding type.
@@ -249,18 +303,42 @@ func Add(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("This app is not active. You cannot create a license for it.", w)
 		return
 	}
+	if tmpl.ID > 0 && tmpl.AppID != a.ID {
+		output.ErrorInputInvalid("The key pair chosen is not for the template's app.", w)
+		return
+	}
+
+	//Get the key pairs to co-sign the license with, if the app requires more than one
+	//signature.
+	coSigners, errMsg, err := getCoSigningKeyPairs(r.Context(), a, kp)
+	if err != nil {
+		output.Error(err, "Could not look up key pairs to co-sign license.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
 
 	//Parse and validate custom fields. This isn't done immediately after validating
 	//main license data because we need the app's ID.
 	rawCustomFields := r.FormValue("customFields")
 	var fields db.MultiCustomFieldResult
-	err = json.Unmarshal([]byte(rawCustomFields), &fields)
-	if err != nil {
-		output.Error(err, "Could not parse custom fields to create license.", w)
-		return
+	if rawCustomFields != "" || tmpl.ID < 1 {
+		err = json.Unmarshal([]byte(rawCustomFields), &fields)
+		if err != nil {
+			output.Error(err, "Could not parse custom fields.", w)
+			return
+		}
+	}
+	if tmpl.ID > 0 {
+		fields, err = templateCustomFields(tmpl, fields)
+		if err != nil {
+			output.Error(err, "Could not parse custom fields from template.", w)
+			return
+		}
 	}
 
-	errMsg, err = fields.Validate(r.Context(), a.ID, false)
+	errMsg, err = fields.Validate(r.Context(), a.ID, l.ExpireDate, false)
 	if err != nil && errMsg != "" {
 		output.Error(err, errMsg, w)
 		return
@@ -272,124 +350,209 @@ func Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Get info about who or what is creating this license.
-	userID, apiKeyID, err := getCreatedBy(r)
+	//Parse and validate the features enabled for this license.
+	rawFeatures, err := templateFeatures(tmpl, r.FormValue("features"))
 	if err != nil {
-		output.Error(err, "Could not determine who made this request.", w)
+		output.Error(err, "Could not parse features from template.", w)
 		return
 	}
-	if userID > 0 {
-		l.CreatedByUserID = null.IntFrom(userID)
-	} else if apiKeyID > 0 {
-		l.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	l.Features, errMsg, err = parseFeatures(r.Context(), a.ID, rawFeatures)
+	if err != nil {
+		output.Error(err, "Could not validate features for this license.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
 	}
 
-	//Set the license creation timestamps and some other data. We save some app
+	//Set the license issue timestamps and some other data. We save some app
 	//related data in case the app's details are changed in the future since once a
 	//license is created, and the signature is created, we need the same details to
 	//download a valid license any time in the future.
 	l.IssueDate = timestamps.YMD()
 	l.IssueTimestamp = time.Now().Unix()
 	l.AppName = a.Name
+	l.AppFileHeaderText = a.FileHeaderText
 	l.FileFormat = a.FileFormat
 	l.ShowLicenseID = a.ShowLicenseID
 	l.ShowAppName = a.ShowAppName
+	l.SchemaVersion = a.LicenseSchemaVersion
 
-	//Get DatetimeCreated value. This way we will have the exact same value for the
-	//license, custom field results, etc.
-	datetimeCreated := timestamps.YMDHMS()
-	l.DatetimeCreated = datetimeCreated
+	nl = newLicense{
+		License:   l,
+		Fields:    fields,
+		KeyPair:   kp,
+		App:       a,
+		CoSigners: coSigners,
+	}
+	return nl, true
+}
 
-	//Start transaction since we are saving multiple things.
-	c := sqldb.Connection()
-	tx, err := c.BeginTxx(r.Context(), nil)
-	if err != nil {
-		output.Error(err, "Could not save license data (1).", w)
+// Add saves the data used to create a license. First, we handle saving the common
+// license data, then we handle saving the custom field results, then we generate the
+// license file and sign it, we update the saved license data with the signature, and
+// finally we verify the license file by creating it, rereading it, and checking the
+// signature with the public key.
+func Add(w http.ResponseWriter, r *http.Request) {
+	//Parse and validate the license data.
+	nl, ok := prepareNewLicense(w, r)
+	if !ok {
 		return
 	}
-	defer tx.Rollback()
+	l, fields, kp, a, coSigners := nl.License, nl.Fields, nl.KeyPair, nl.App, nl.CoSigners
 
-	//Save main license data. This will get us the license ID which we need to save
-	//the custom field results and possible for use in the license if required per
-	//the app's details.
-	err = l.Insert(r.Context(), tx)
+	//Get info about who or what is creating this license.
+	userID, apiKeyID, err := getCreatedBy(r)
 	if err != nil {
-		output.Error(err, "Could not save license data (2).", w)
+		output.Error(err, "Could not determine who made this request.", w)
 		return
 	}
+	if userID > 0 {
+		l.CreatedByUserID = null.IntFrom(userID)
+	} else if apiKeyID > 0 {
+		l.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+	}
 
-	//Save custom field results.
-	for _, field := range fields {
-		if userID > 0 {
-			field.CreatedByUserID = null.IntFrom(userID)
-		} else if apiKeyID > 0 {
-			field.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
-		}
-
-		field.LicenseID = l.ID
-		field.DatetimeCreated = datetimeCreated
+	//Save the idempotency key, if one was provided, so that a retry of this request
+	//returns this license. Keys are only used for licenses created via the API.
+	l.IdempotencyKey = ""
+	if apiKeyID > 0 {
+		l.IdempotencyKey = getIdempotencyKey(r)
+	}
 
-		innerErr := field.Insert(r.Context(), tx)
-		if innerErr != nil {
-			output.Error(innerErr, "Could not save field \""+field.CustomFieldName+"\" therefore license could not be saved.", w)
+	//Make sure the API key, if this license is being created via the API, is allowed
+	//to create licenses for this app.
+	if apiKeyID > 0 {
+		allowed, err := apiKeyAllowedApp(r.Context(), apiKeyID, a.ID)
+		if err != nil {
+			output.Error(err, "Could not determine if this API key can create licenses for this app.", w)
+			return
+		} else if !allowed {
+			output.ErrorInputInvalid("This API key is not allowed to create licenses for this app.", w)
 			return
 		}
 	}
 
-	//Create the new license file.
-	f, err := buildLicense(l, fields)
-	if err != nil {
-		output.Error(err, "Could not build license for signing and verification.", w)
+	//Require the user to provide their 2FA token again, if needed, since creating a
+	//license is sensitive.
+	if !checkLicenseCreationStepUp(w, r, userID) {
 		return
 	}
 
-	//Decrypt the private key, if needed.
-	privateKey := []byte(kp.PrivateKey)
-	if kp.PrivateKeyEncrypted {
-		encKey := config.Data().PrivateKeyEncryptionKey
+	//Get DatetimeCreated value. This way we will have the exact same value for the
+	//license, custom field results, etc.
+	datetimeCreated := timestamps.YMDHMS()
+	l.DatetimeCreated = datetimeCreated
 
-		pk, err := hex.DecodeString(kp.PrivateKey)
+	//Decrypt the private keys, if needed. If the license is co-signed, each key pair
+	//provides a signature with the chosen key pair's signature first.
+	keys, err := newLicenseSigningKeys(kp, coSigners)
+	if err != nil {
+		output.Error(err, "Could not decrypt private key to sign license data.", w)
+		return
+	}
+
+	//Save the license, in a transaction since we are saving multiple things. The
+	//entire transaction is retried if the database is busy, so everything in it must
+	//be safe to rerun. errMsg is set to the message to show the user if saving fails.
+	var f licensefile.File
+	errMsg := "Could not start saving license data."
+	err = db.WithRetryTx(r.Context(), sqldb.Connection(), func(tx *sqlx.Tx) (err error) {
+		//Allow the idempotency key to be reused if it was last used before the
+		//retention window. Keys must be unique, see license-idempotency.go.
+		if l.IdempotencyKey != "" {
+			err = db.ReleaseIdempotencyKey(r.Context(), tx, apiKeyID, l.IdempotencyKey, idempotencyKeyCreatedSince())
+			if err != nil {
+				errMsg = "Could not save license data."
+				return
+			}
+		}
+
+		//Save main license data. This will get us the license ID which we need to
+		//save the custom field results and possible for use in the license if
+		//required per the app's details.
+		err = l.Insert(r.Context(), tx)
 		if err != nil {
-			output.Error(err, "Could not decrypt private key to sign license data (1).", w)
+			errMsg = "Could not save license data."
 			return
 		}
 
-		decryptedPrivKey, err := keypairs.DecryptPrivateKey(encKey, pk)
+		//Save custom field results.
+		for _, field := range fields {
+			if userID > 0 {
+				field.CreatedByUserID = null.IntFrom(userID)
+			} else if apiKeyID > 0 {
+				field.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+			}
+
+			field.LicenseID = l.ID
+			field.DatetimeCreated = datetimeCreated
+
+			err = field.Insert(r.Context(), tx)
+			if err != nil {
+				errMsg = "Could not save field \"" + field.CustomFieldName + "\" therefore license could not be saved."
+				return
+			}
+		}
+
+		//Create the new license file.
+		f, err = buildLicense(l, fields)
 		if err != nil {
-			output.Error(err, "Could not decrypt private key to sign license data (2).", w)
+			errMsg = "Could not build license for signing and verification."
 			return
 		}
-		privateKey = decryptedPrivKey
-	}
 
-	//Sign the license file.
-	err = f.Sign(privateKey, kp.AlgorithmType)
-	if err != nil {
-		output.Error(err, "Could not generate signature.", w)
-		return
-	}
+		//Sign the license file.
+		err = keys.sign(&f)
+		if err != nil {
+			errMsg = "Could not generate signature."
+			return
+		}
 
-	//Save the signature
-	l.Signature = f.Signature
-	err = l.SaveSignature(r.Context(), tx)
-	if err != nil {
-		output.Error(err, "Could not save signature.", w)
+		//Save the signature
+		l.Signature = f.Signature
+		l.Signatures = joinSignatures(f.Signatures)
+		l.SignatureAlgorithm = f.Algorithm
+		l.FormatVersion = f.FormatVersion
+		err = l.SaveSignature(r.Context(), tx)
+		if err != nil {
+			errMsg = "Could not save signature."
+			return
+		}
+
+		//The transaction is committed now to save the license even though we don't
+		//know if it is can be successfully validated with the public key.
+		errMsg = "Could not complete saving of new license."
 		return
-	}
+	})
+	if l.IdempotencyKey != "" && db.IsUniqueViolation(err) {
+		//Another request with the same idempotency key saved a license first. Return
+		//that license instead, the same as if this request was a retry.
+		if replayConflictingIdempotentAdd(w, r) {
+			return
+		}
 
-	//Commit now to save the license even though we don't know if it is can be
-	//successfully validated with the public key.
-	err = tx.Commit()
-	if err != nil {
-		output.Error(err, "Could not complete saving of new license.", w)
+		output.Error(err, "A license is already being created with this "+idempotencyKeyHeader+". Please try again.", w)
+		return
+	} else if err != nil {
+		output.Error(err, errMsg, w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), l.ID)
 
 	//Verify the just created license data and signature. This "writes" out the
 	//complete license file with signature and then "reads" it like a third-party app
 	//would to verify the signature with a public key. This is done to confirm the
 	//signature is valid.
-	err = writeReadVerify(f, kp.AlgorithmType, []byte(kp.PublicKey))
+	err = keys.verify(f)
+	if err != nil && l.IdempotencyKey != "" {
+		//The license was never returned, so allow the request to be retried with the
+		//same idempotency key.
+		innerErr := l.ClearIdempotencyKey(r.Context())
+		if innerErr != nil {
+			log.Println("license.Add", "could not clear idempotency key from unverified license", l.ID, innerErr)
+		}
+	}
 	if err == licensefile.ErrBadSignature {
 		output.Error(licensefile.ErrBadSignature, "License could not be verified and therefore cannot be used. Please contact an administrator and have them investigate this error.", w)
 		return
@@ -406,12 +569,15 @@ func Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordLicenseCreated(apiKeyID)
+	notifyLicenseEvent(r.Context(), config.NotificationEventLicenseCreated, l, userID, apiKeyID)
+
 	//Check if user wants the actual license returned. This is typically only for
 	//public API requests and is done so that a second request to get the license file
 	//isn't needed.
 	if r.FormValue("returnLicenseFile") == "true" {
 		//Set suggested filename.
-		filename := replaceFilenamePlaceholders(a.DownloadFilename, l.ID, a.Name, a.FileFormat)
+		filename := replaceFilenamePlaceholders(a.DownloadFilename, l, a.Name, a.FileFormat)
 		w.Header().Add("Content-Disposition", "inline; filename=\""+filename+"\"")
 
 		err = f.Write(w)
@@ -459,20 +625,52 @@ func All(w http.ResponseWriter, r *http.Request) {
 	}
 
 	activeOnly, _ := strconv.ParseBool(r.FormValue("activeOnly"))
+	friendlyID := strings.TrimSpace(r.FormValue("friendlyID"))
+	appLicenseNumber, _ := strconv.ParseInt(r.FormValue("appLicenseNumber"), 10, 64)
+	search := strings.TrimSpace(r.FormValue("search"))
+	expireStart := strings.TrimSpace(r.FormValue("expireStart"))
+	expireEnd := strings.TrimSpace(r.FormValue("expireEnd"))
+
+	//Validate expiration date range, if provided. Either end of the range can be
+	//provided alone to look up licenses expiring on or after, or on or before, a date.
+	var expireStartParsed, expireEndParsed time.Time
+	if expireStart != "" {
+		var err error
+		expireStartParsed, err = time.Parse("2006-01-02", expireStart)
+		if err != nil {
+			output.Error(err, "Could not parse expiration start date.", w)
+			return
+		}
+	}
+	if expireEnd != "" {
+		var err error
+		expireEndParsed, err = time.Parse("2006-01-02", expireEnd)
+		if err != nil {
+			output.Error(err, "Could not parse expiration end date.", w)
+			return
+		}
+	}
+	if expireStart != "" && expireEnd != "" && expireStartParsed.After(expireEndParsed) {
+		output.ErrorInputInvalid("Expiration start date must be before end date.", w)
+		return
+	}
 
 	//Look up licenses.
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(r.Context())
 	cols := sqldb.Columns{
 		db.TableLicenses + ".ID",
 		db.TableLicenses + ".DatetimeCreated",
+		db.TableLicenses + ".FriendlyID",
+		db.TableLicenses + ".AppLicenseNumber",
 		db.TableLicenses + ".AppName",
 		db.TableLicenses + ".CompanyName",
 		db.TableLicenses + ".IssueDate",
 		db.TableLicenses + ".ExpireDate",
 		db.TableLicenses + ".Verified",
 		db.TableLicenses + ".Active",
+		db.TableLicenses + ".ScheduledDisableDate",
 
-		"julianday(" + db.TableLicenses + ".ExpireDate) < julianday('now') AS Expired",
+		db.LicenseExpiredColumn,
 
 		//Note the mismatch table and column. This is because we want "what license
 		//was this license renewed TO" and "what license was this license renewed
@@ -483,7 +681,7 @@ func All(w http.ResponseWriter, r *http.Request) {
 		//Convert dates to timezone in config file which is more applicable to users.
 		`datetime(` + db.TableLicenses + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
 	}
-	lics, err := db.GetLicenses(r.Context(), appID, limit, activeOnly, cols)
+	lics, err := db.GetLicenses(r.Context(), appID, limit, activeOnly, friendlyID, appLicenseNumber, search, expireStart, expireEnd, cols)
 	if err != nil {
 		output.Error(err, "Could not look up list of licenses.", w)
 		return
@@ -499,25 +697,29 @@ func One(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("The license ID provided is invalid.", w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
 
-	offset := config.GetTimezoneOffsetForSQLite()
+	offset := config.GetTimezoneOffsetForSQLiteFromContext(r.Context())
 	cols := sqldb.Columns{
 		db.TableLicenses + ".*",
 		db.TableUsers + ".Username AS CreatedByUsername",
 		db.TableAPIKeys + ".Description AS CreatedByAPIKeyDescription",
 		db.TableKeyPairs + ".AlgorithmType AS KeyPairAlgoType",
 
-		"julianday(" + db.TableLicenses + ".ExpireDate) < julianday('now') AS Expired",
+		db.LicenseExpiredColumn,
 
 		//Note the mismatch table and column. This is because we want "what license
 		//was this license renewed TO" and "what license was this license renewed
 		//"FROM".
 		"rrFrom.ToLicenseID AS RenewedToLicenseID",
 		"rrTo.FromLicenseID AS RenewedFromLicenseID",
+		"trFrom.ToLicenseID AS TransferredToLicenseID",
+		"trTo.FromLicenseID AS TransferredFromLicenseID",
 
 		//Convert dates to timezone in config file which is more applicable to users.
 		`datetime(` + db.TableLicenses + `.DatetimeCreated, '` + offset + `') AS DatetimeCreatedInTZ`,
 		`DATE(datetime(` + db.TableLicenses + `.DatetimeCreated, '` + offset + `')) AS IssueDateInTZ`,
+		`IFNULL(datetime(NULLIF(` + db.TableLicenses + `.LastHeartbeat, ''), '` + offset + `'), '') AS LastHeartbeatInTZ`,
 	}
 	l, err := db.GetLicense(r.Context(), licenseID, cols)
 	if err == sql.ErrNoRows {
@@ -528,13 +730,17 @@ func One(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Get timezone from config file for displaying in GUI to provide users a bit more
-	//context about what DatetimeCreated is.
-	l.Timezone = config.Data().Timezone
+	//Get timezone, chosen by the user or from the config file, for displaying in GUI
+	//to provide users a bit more context about what DatetimeCreated is.
+	l.Timezone = config.GetLocationFromContext(r.Context()).String()
 
 	output.DataFound(l, w)
 }
 
+// encodingCompact is the value of the encoding option when downloading a license to
+// return the license file gzipped and base64 encoded, see licensefile.WriteCompact().
+const encodingCompact = "compact"
+
 // Download retrieves the license data as a text file. This file is complete, is is
 // signed, and is the license you would distribute for use in your apps.
 //
@@ -547,97 +753,207 @@ func Download(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("The license ID provided is invalid.", w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	encoding := strings.TrimSpace(r.FormValue("encoding"))
+	if encoding != "" && encoding != encodingCompact {
+		output.ErrorInputInvalid("The encoding provided is invalid. The only supported encoding is "+encodingCompact+".", w)
+		return
+	}
+
+	l, f, errMsg, err := getDownloadableLicense(r.Context(), licenseID)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
+
+	//Save download history.
+	userID, apiKeyID, err := getCreatedBy(r)
+	if err != nil {
+		output.Error(err, "Could not determine who made this request.", w)
+		return
+	}
+	saveDownloadHistory(r.Context(), licenseID, userID, apiKeyID)
+
+	//Diagnostic info.
+	d, _ := f.ExpiresIn()
+	w.Header().Add("X-Days-Until-Expired", strconv.FormatFloat(math.Floor(d.Hours()/24), 'f', 0, 64))
+
+	//Return the signature, or the signed data, as its own file if requested. This is
+	//used by customers who store the license data separately from the signature.
+	if r.FormValue("detached") == "true" || r.FormValue("payload") == "true" {
+		writeDetached(w, r, l, f)
+		return
+	}
+
+	//Return the license file gzipped and base64 encoded, on a single line, if
+	//requested. This is used by embedded clients that prefer a compact license.
+	if encoding == encodingCompact {
+		if r.FormValue("display") == "true" {
+			w.Header().Add("Content-Type", "text/plain")
+		} else {
+			filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+			w.Header().Add("Content-Disposition", "attachment; filename=\""+filename+".compact\"")
+		}
+
+		err = f.WriteCompact(w)
+		if err != nil {
+			output.Error(err, "Could not present license.", w)
+			return
+		}
+		return
+	}
+
+	//If the license file is just being displayed, a rarely used by helpful diagnostic
+	//function in the GUI, don't mark the returned data as a file for the browser to
+	//download. But, add the correct content type for the browser.
+	if r.FormValue("display") == "true" {
+		w.Header().Add("Content-Type", "text/"+strings.ToLower(string(l.FileFormat)))
+	} else {
+		setDownloadFilename(w, l)
+	}
+
+	//Write out the license file.
+	err = f.Write(w)
+	if err != nil {
+		output.Error(err, "Could not present license.", w)
+		return
+	}
+}
+
+// writeDetached writes either a license's detached signature, when detached=true,
+// or the data that was signed, when payload=true. The payload is the license file's
+// data without the signature, in the exact format that was signed, so that it can be
+// verified with the detached signature using licensefile.VerifyDetached().
+//
+// The detached signature includes the ID of the public key that verifies it, see
+// licensefile.PublicKeyID().
+func writeDetached(w http.ResponseWriter, r *http.Request, l db.License, f licensefile.File) {
+	filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+
+	var b []byte
+	if r.FormValue("payload") == "true" {
+		var err error
+		b, err = f.Payload()
+		if err != nil {
+			output.Error(err, "Could not build license data.", w)
+			return
+		}
+
+		filename += ".payload"
+	} else {
+		d, err := f.SignatureOnly()
+		if err != nil {
+			output.Error(err, "Could not get license signature.", w)
+			return
+		}
+
+		kp, err := db.GetKeyPairByID(r.Context(), l.KeyPairID)
+		if err != nil {
+			output.Error(err, "Could not look up key pair for license.", w)
+			return
+		}
+		d.KeyID, err = licensefile.PublicKeyID([]byte(kp.PublicKey))
+		if err != nil {
+			output.Error(err, "Could not identify public key for license.", w)
+			return
+		}
+
+		b, err = d.Marshal(l.FileFormat)
+		if err != nil {
+			output.Error(err, "Could not build detached signature.", w)
+			return
+		}
+
+		filename += ".sig"
+	}
+
+	w.Header().Add("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.Write(b)
+}
 
+// getDownloadableLicense looks up a license and builds the license's file, with the
+// already calculated signature, for downloading. An error message is returned if the
+// license cannot be downloaded, for example if the license is expired.
+func getDownloadableLicense(ctx context.Context, licenseID int64) (l db.License, f licensefile.File, errMsg string, err error) {
 	cols := sqldb.Columns{
 		db.TableLicenses + ".*",
-		"julianday(" + db.TableLicenses + ".ExpireDate) < julianday('now') AS Expired",
+		db.LicenseExpiredColumn,
+		db.TableApps + ".ID AS AppID",
 		db.TableApps + ".Name AS AppName",
 		db.TableApps + ".DownloadFilename AS AppDownloadFilename",
 		db.TableApps + ".FileFormat AS AppFileFormat",
+		db.TableApps + ".FileHeaderText AS AppFileHeaderText",
 	}
-	l, err := db.GetLicense(r.Context(), licenseID, cols)
+	l, err = db.GetLicense(ctx, licenseID, cols)
 	if err == sql.ErrNoRows {
-		output.ErrorInputInvalid("The license ID provided does not exist.", w)
-		return
+		return l, f, "The license ID provided does not exist.", nil
 	} else if err != nil {
-		output.Error(err, "Could not look up license data.", w)
+		errMsg = "Could not look up license data."
 		return
 	} else if l.Expired {
-		output.ErrorInputInvalid("This license is expired and cannot be downloaded.", w)
+		errMsg = "This license is expired and cannot be downloaded."
 		return
 	} else if !l.Active {
-		output.ErrorInputInvalid("This license is disabled and cannot be downloaded.", w)
+		errMsg = "This license is disabled and cannot be downloaded."
 		return
 	} else if !l.Verified {
-		output.ErrorInputInvalid("This license has not been verified and therefore cannot be downloaded. This is a serious error and should be investigated by an administrator.", w)
+		errMsg = "This license has not been verified and therefore cannot be downloaded. This is a serious error and should be investigated by an administrator."
 		return
 	}
 
 	//Get custom fields for license.
-	cfr, err := db.GetCustomFieldResults(r.Context(), licenseID)
+	cfr, err := db.GetCustomFieldResults(ctx, licenseID)
 	if err != nil {
-		output.Error(err, "Could not look up custom fields for license.", w)
+		errMsg = "Could not look up custom fields for license."
 		return
 	}
 
 	//Build the license file.
-	f, err := buildLicense(l, cfr)
+	f, err = buildLicense(l, cfr)
 	if err != nil {
-		output.Error(err, "Could not build license.", w)
+		errMsg = "Could not build license."
 		return
 	}
 
 	//Add signature to license. The signature was already created when license was
 	//created so we don't need to recalculate it each time the license is downloaded.
 	f.Signature = l.Signature
+	f.Signatures = splitSignatures(l.Signatures)
 
-	//Save download history.
+	return
+}
+
+// saveDownloadHistory records that a license was downloaded. A license downloaded
+// via a download link was not downloaded by a user or API key so both IDs are 0.
+func saveDownloadHistory(ctx context.Context, licenseID, userID, apiKeyID int64) {
 	h := db.DownloadHistory{
 		DatetimeCreated:  timestamps.YMDHMS(),
 		TimestampCreated: time.Now().UnixNano(),
 		LicenseID:        licenseID,
 	}
-
-	userID, apiKeyID, err := getCreatedBy(r)
-	if err != nil {
-		output.Error(err, "Could not determine who made this request.", w)
-		return
-	}
 	if userID > 0 {
 		h.CreatedByUserID = null.IntFrom(userID)
 	} else if apiKeyID > 0 {
 		h.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
 	}
 
-	err = h.Insert(r.Context())
+	err := h.Insert(ctx)
 	if err != nil {
 		//not exiting on error since this isn't an end of the world event
-		log.Println("license.Download", "could not save download history", err)
-	}
-
-	//Replace any placeholders in the download filename. Placeholders are special
-	//words wrapped in {} characters provided for the license's app.
-	filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l.ID, l.AppName, l.AppFileFormat)
-
-	//Diagnostic info.
-	d, _ := f.ExpiresIn()
-	w.Header().Add("X-Days-Until-Expired", strconv.FormatFloat(math.Floor(d.Hours()/24), 'f', 0, 64))
-
-	//If the license file is just being displayed, a rarely used by helpful diagnostic
-	//function in the GUI, don't mark the returned data as a file for the browser to
-	//download. But, add the correct content type for the browser.
-	if r.FormValue("display") == "true" {
-		w.Header().Add("Content-Type", "text/"+strings.ToLower(string(l.FileFormat)))
-	} else {
-		w.Header().Add("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		log.Println("license.saveDownloadHistory", "could not save download history", err)
 	}
+}
 
-	//Write out the license file.
-	err = f.Write(w)
-	if err != nil {
-		output.Error(err, "Could not present license.", w)
-		return
-	}
+// setDownloadFilename marks the response as a file for the browser to download. Any
+// placeholders in the app's download filename are replaced. Placeholders are special
+// words wrapped in {} characters.
+func setDownloadFilename(w http.ResponseWriter, l db.License) {
+	filename := replaceFilenamePlaceholders(l.AppDownloadFilename, l, l.AppName, l.AppFileFormat)
+	w.Header().Add("Content-Disposition", "attachment; filename=\""+filename+"\"")
 }
 
 // buildLicense builds the File with the required data. The resulting File would need
@@ -662,15 +978,31 @@ func buildLicense(l db.License, cfr []db.CustomFieldResult) (f licensefile.File,
 		IssueDate:      l.IssueDate,
 		IssueTimestamp: l.IssueTimestamp,
 		ExpireDate:     l.ExpireDate,
+		ExpireDatetime: l.ExpireDatetime,
+		ValidFrom:      l.ValidFrom,
+		Features:       splitFeatures(l.Features),
+
+		LicenseSchemaVersion: l.SchemaVersion,
+
+		//These are overwritten when the license is signed. When downloading a
+		//license these must match what was signed.
+		Algorithm:     l.SignatureAlgorithm,
+		FormatVersion: l.FormatVersion,
 	}
 
 	//these fields are just used for the signing process
 	f.SetFileFormat(l.FileFormat)
 
+	//The header is not signed, it is just written above the data.
+	f.SetHeader(l.AppFileHeaderText)
+
 	//Set optional fields.
 	if l.ShowLicenseID {
 		f.LicenseID = l.ID
 	}
+	if l.FriendlyID != "" {
+		f.FriendlyID = l.FriendlyID
+	}
 	if l.ShowAppName {
 		f.AppName = l.AppName
 	}
@@ -683,7 +1015,7 @@ func buildLicense(l db.License, cfr []db.CustomFieldResult) (f licensefile.File,
 			metadata[f.CustomFieldName] = f.IntegerValue.Int64
 		case db.CustomFieldTypeDecimal:
 			metadata[f.CustomFieldName] = f.DecimalValue.Float64
-		case db.CustomFieldTypeText:
+		case db.CustomFieldTypeText, db.CustomFieldTypeEmail, db.CustomFieldTypeURL, db.CustomFieldTypeFileHash:
 			metadata[f.CustomFieldName] = f.TextValue.String
 		case db.CustomFieldTypeBoolean:
 			metadata[f.CustomFieldName] = f.BoolValue.Bool
@@ -701,7 +1033,9 @@ func buildLicense(l db.License, cfr []db.CustomFieldResult) (f licensefile.File,
 	return
 }
 
-// Disable marks a license as inactive.
+// Disable marks a license as inactive. A note explaining why the license is being
+// disabled is required if the RequireDisableReason app setting is enabled. The note is
+// saved in the same transaction as disabling the license.
 func Disable(w http.ResponseWriter, r *http.Request) {
 	//Get inputs and validate.
 	licenseID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
@@ -711,13 +1045,26 @@ func Disable(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("Could not determine which license you want to disable.", w)
 		return
 	}
-	if note == "" {
-		output.ErrorInputInvalid("You must provide a note describing why you are disabling this license.", w)
+	db.SetActivityLogLicenseID(r.Context(), licenseID)
+
+	//Check if a reason for disabling the license is required.
+	as, err := db.GetAppSettings(r.Context())
+	if err != nil {
+		output.Error(err, "Could not determine if a reason is required to disable a license.", w)
+		return
+	}
+	if as.RequireDisableReason && note == "" {
+		output.ErrorInputInvalid("A reason is required to disable a license. Please provide a note describing why you are disabling this license.", w)
 		return
 	}
 
 	//Check if this license is already disabled.
-	cols := sqldb.Columns{db.TableLicenses + ".Active"}
+	cols := sqldb.Columns{
+		db.TableLicenses + ".ID",
+		db.TableLicenses + ".Active",
+		db.TableLicenses + ".CompanyName",
+		db.TableLicenses + ".AppName",
+	}
 	l, err := db.GetLicense(r.Context(), licenseID, cols)
 	if err != nil {
 		output.Error(err, "Could not verify if license is already disabled.", w)
@@ -747,6 +1094,9 @@ func Disable(w http.ResponseWriter, r *http.Request) {
 		LicenseID: licenseID,
 		Note:      note + " (License was disabled).",
 	}
+	if note == "" {
+		n.Note = "License was disabled."
+	}
 
 	//Get info about who or what is disabling this license.
 	userID, apiKeyID, err := getCreatedBy(r)
@@ -772,6 +1122,8 @@ func Disable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	notifyLicenseEvent(r.Context(), config.NotificationEventLicenseDisabled, l, userID, apiKeyID)
+
 	output.UpdateOK(w)
 }
 
@@ -779,6 +1131,11 @@ func Disable(w http.ResponseWriter, r *http.Request) {
 // date set. This creates a copy of the existing license's common data and custom
 // field results. The renewal relationship is also saved to link the licenses together.
 //
+// Custom field results are carried over from the existing license by default, but
+// individual fields can be overridden by providing a "fields" object of
+// field-name:value pairs. Carried over values are revalidated against the fields
+// currently defined for the app.
+//
 // The original license is disabled so it cannot be mistakenly downloaded.
 func Renew(w http.ResponseWriter, r *http.Request) {
 	//Get inputs and validate.
@@ -790,6 +1147,7 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("Could not determine which license you want to renew.", w)
 		return
 	}
+	db.SetActivityLogLicenseID(r.Context(), fromLicenseID)
 	if newExpireDateStr == "" {
 		output.ErrorInputInvalid("You must provide the new expiration date.", w)
 		return
@@ -799,11 +1157,22 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		output.Error(err, "You must provide a new expiration date in YYYY-MM-DD format.", w)
 		return
 	}
+	errMsg := db.ValidateMaxLicenseDuration(r.Context(), newExpireDateStr)
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
+	}
 
 	//Look up existing license data so we can confirm it hasn't been disabled and
-	//that the new expiration date is after the current expiration date.
+	//that the new expiration date is after the current expiration date. The app ID
+	//is needed to look up the app's custom fields and to check if an API key is
+	//allowed to create licenses for the app. The app's current license schema version
+	//is used for the renewed license since the renewed license uses the app's
+	//current custom fields.
 	cols := sqldb.Columns{
 		db.TableLicenses + ".*",
+		db.TableApps + ".ID AS AppID",
+		db.TableApps + ".LicenseSchemaVersion AS AppLicenseSchemaVersion",
 	}
 	fromLicense, err := db.GetLicense(r.Context(), fromLicenseID, cols)
 	if err != nil {
@@ -835,6 +1204,56 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//Build the custom field results for the renewed license. Results are carried over
+	//from the existing license so they don't need to be provided again. Overrides for
+	//individual fields can be provided as an object of field-name:value pairs, the
+	//same as when adding a license via the API. The QueryUnescape is needed since the
+	//value for this field is a url encoded JSON object.
+	rawOverrides, err := url.QueryUnescape(r.FormValue("fields"))
+	if err != nil {
+		output.Error(err, "Could not parse custom fields.", w)
+		return
+	}
+
+	var overrides map[string]interface{}
+	err = json.Unmarshal([]byte(rawOverrides), &overrides)
+	if err != nil && len(rawOverrides) > 0 {
+		//We check the length to handle times when the request didn't provide any
+		//overrides.
+		output.Error(err, "Could not parse custom fields for overriding.", w)
+		return
+	}
+
+	fromResults, err := db.GetCustomFieldResults(r.Context(), fromLicenseID)
+	if err != nil {
+		output.Error(err, "Could not look up existing license's custom field results.", w)
+		return
+	}
+
+	ff, droppedFields, invalidOverride, err := carryOverCustomFieldResults(r.Context(), fromLicense.AppID, fromResults, overrides)
+	if err != nil {
+		output.Error(err, "Could not build custom field results for renewed license.", w)
+		return
+	} else if invalidOverride != "" {
+		output.ErrorInputInvalid("The value for the "+invalidOverride+" field is not the correct type.", w)
+		return
+	}
+
+	//Revalidate the custom field results since a field's rules may have changed
+	//since the existing license was created. Any carried over value that is no
+	//longer valid must be overridden.
+	errMsg, err = ff.Validate(r.Context(), fromLicense.AppID, newExpireDateStr, false)
+	if err != nil && errMsg != "" {
+		output.Error(err, errMsg, w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not validate custom field results for renewed license.", w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid("A custom field value from the existing license is no longer valid, please provide a new value. "+errMsg, w)
+		return
+	}
+
 	//Get a copy of the "from" license's data to use for the "to" license.
 	toLicense := fromLicense
 
@@ -842,6 +1261,10 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 	toLicense.CreatedByAPIKeyID = null.IntFrom(0)
 	toLicense.CreatedByUserID = null.IntFrom(0)
 
+	//The "to" license is created, and signed, by this app even if the "from" license
+	//was imported.
+	toLicense.Imported = false
+
 	//Get info about who or what is renewing this license.
 	userID, apiKeyID, err := getCreatedBy(r)
 	if err != nil {
@@ -854,6 +1277,25 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		toLicense.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
 	}
 
+	//Make sure the API key, if this license is being renewed via the API, is allowed
+	//to create licenses for this app.
+	if apiKeyID > 0 {
+		allowed, err := apiKeyAllowedApp(r.Context(), apiKeyID, toLicense.AppID)
+		if err != nil {
+			output.Error(err, "Could not determine if this API key can create licenses for this app.", w)
+			return
+		} else if !allowed {
+			output.ErrorInputInvalid("This API key is not allowed to create licenses for this app.", w)
+			return
+		}
+	}
+
+	//Require the user to provide their 2FA token again, if needed, since renewing a
+	//license creates a new license.
+	if !checkLicenseCreationStepUp(w, r, userID) {
+		return
+	}
+
 	//Get DatetimeCreated value. This way we will have the exact same value for the
 	//license, custom field results, and renewal relationship.
 	datetimeCreated := timestamps.YMDHMS()
@@ -861,10 +1303,14 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 	//Modify existing license data for new license.
 	toLicense.ID = 0                             //this will be populated with the new license's ID in Insert().
 	toLicense.ExpireDate = newExpireDateStr      //new user provided date.
+	toLicense.ExpireDatetime = ""                //renewals only use a date, not a specific time.
+	toLicense.ValidFrom = ""                     //renewals are valid immediately.
 	toLicense.DatetimeModified = ""              //license hasn't been modified, so unset this to reduce confusion.
 	toLicense.IssueDate = timestamps.YMD()       //
 	toLicense.IssueTimestamp = time.Now().Unix() //
 	toLicense.Signature = ""                     //will be set later...
+	toLicense.IdempotencyKey = ""                //only set for licenses created via the API, see AddViaAPI().
+	toLicense.SchemaVersion = fromLicense.AppLicenseSchemaVersion
 	toLicense.DatetimeCreated = datetimeCreated
 
 	//Start transaction since we are saving multiple things.
@@ -885,13 +1331,7 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Copy each custom field result.
-	ff, err := db.GetCustomFieldResults(r.Context(), fromLicenseID)
-	if err != nil {
-		output.Error(err, "Could not look up existing license's custom field results.", w)
-		return
-	}
-
+	//Save each custom field result.
 	for _, f := range ff {
 		if userID > 0 {
 			f.CreatedByUserID = null.IntFrom(userID)
@@ -930,6 +1370,26 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//Save a note to the renewed license listing any custom fields that were not
+	//carried over since they are no longer defined for the app.
+	if len(droppedFields) > 0 {
+		dn := db.LicenseNote{
+			LicenseID: toLicense.ID,
+			Note:      "Custom fields were not carried over from the renewed license since they are no longer defined: " + strings.Join(droppedFields, ", ") + ".",
+		}
+		if userID > 0 {
+			dn.CreatedByUserID = null.IntFrom(userID)
+		} else if apiKeyID > 0 {
+			dn.CreatedByAPIKeyID = null.IntFrom(apiKeyID)
+		}
+
+		err = dn.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not add note about custom fields not carried over.", w)
+			return
+		}
+	}
+
 	//Disable the "renewed-from" license so that it cannot be mistakenly downloaded.
 	err = db.DisableLicense(r.Context(), fromLicenseID, tx)
 	if err != nil {
@@ -969,8 +1429,9 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		output.Error(err, "Could not look up signature details.", w)
 		return
 	}
-	if !kp.Active {
-		output.ErrorInputInvalid("This key pair used for the original license is no longer active. This license cannot be renewed.", w)
+	errMsg = checkSigningKeyPair(kp, "the original license", "renewed")
+	if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
 		return
 	}
 
@@ -981,27 +1442,25 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Decrypt the private key, if needed.
-	privateKey := []byte(kp.PrivateKey)
-	if kp.PrivateKeyEncrypted {
-		encKey := config.Data().PrivateKeyEncryptionKey
-
-		pk, err := hex.DecodeString(kp.PrivateKey)
-		if err != nil {
-			output.Error(err, "Could not decrypt private key to sign license data (1).", w)
-			return
-		}
+	//Get the keys to sign the license with. The license is co-signed, the same as
+	//when a license is created, if the app requires more than one signature.
+	a, err := db.GetAppByID(r.Context(), toLicense.AppID)
+	if err != nil {
+		output.Error(err, "Could not look up app data.", w)
+		return
+	}
 
-		decryptedPrivKey, err := keypairs.DecryptPrivateKey(encKey, pk)
-		if err != nil {
-			output.Error(err, "Could not decrypt private key to sign license data (2).", w)
-			return
-		}
-		privateKey = decryptedPrivKey
+	keys, errMsg, err := getLicenseSigningKeys(r.Context(), a, kp)
+	if err != nil {
+		output.Error(err, errMsg, w)
+		return
+	} else if errMsg != "" {
+		output.ErrorInputInvalid(errMsg, w)
+		return
 	}
 
 	//Sign the license file.
-	err = f.Sign(privateKey, kp.AlgorithmType)
+	err = keys.sign(&f)
 	if err != nil {
 		output.Error(err, "Could not generate signature.", w)
 		return
@@ -1009,6 +1468,9 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 
 	//Save the signature
 	toLicense.Signature = f.Signature
+	toLicense.Signatures = joinSignatures(f.Signatures)
+	toLicense.SignatureAlgorithm = f.Algorithm
+	toLicense.FormatVersion = f.FormatVersion
 	err = toLicense.SaveSignature(r.Context(), tx)
 	if err != nil {
 		output.Error(err, "Could not save signature.", w)
@@ -1027,7 +1489,7 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 	//complete license file with signature and then "reads" it like a third-party app
 	//would to verify the signature with a public key. This is done to confirm the
 	//signature is valid.
-	err = writeReadVerify(f, kp.AlgorithmType, []byte(kp.PublicKey))
+	err = keys.verify(f)
 	if err == licensefile.ErrBadSignature {
 		output.Error(licensefile.ErrBadSignature, "Renewed license could not be verified and therefore cannot be used. Please contact an administrator and have them investigate this error.", w)
 		return
@@ -1044,20 +1506,18 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordLicenseCreated(apiKeyID)
+	notifyLicenseEvent(r.Context(), config.NotificationEventLicenseCreated, toLicense, userID, apiKeyID)
+
 	//Check if user wants the actual license returned. This is typically only for
 	//public API requests and is done so that a second request to get the license file
 	//isn't needed.
 	if r.FormValue("returnLicenseFile") == "true" {
 		//Set suggested filename.
-		a, err := db.GetAppByID(r.Context(), toLicense.AppID)
-		if err != nil {
-			output.Error(err, "Could not look up app data to build license filename.", w)
-			return
-		}
-
-		filename := replaceFilenamePlaceholders(a.DownloadFilename, toLicense.ID, a.Name, a.FileFormat)
+		filename := replaceFilenamePlaceholders(a.DownloadFilename, toLicense, a.Name, a.FileFormat)
 		w.Header().Add("Content-Disposition", "inline; filename=\""+filename+"\"")
 
+		f.SetHeader(a.FileHeaderText)
 		err = f.Write(w)
 		if err != nil {
 			output.Error(err, "Could not return license file.", w)
@@ -1116,6 +1576,94 @@ func getCreatedBy(r *http.Request) (userID, apiKeyID int64, err error) {
 // that made a request. See getCreatedBy().
 var errUnknownCreatedByID = errors.New("license: unknown creator")
 
+// checkLicenseCreationStepUp requires a user to provide their 2FA token, or use their
+// passkey, again before creating a license if the Require2FAForLicenseCreation app
+// setting is enabled. This is used for each way a license is created (add, renew,
+// transfer, import) so the setting cannot be bypassed. This does not apply to
+// licenses created via the API, when userID is 0. If false is returned, a response
+// has already been sent.
+func checkLicenseCreationStepUp(w http.ResponseWriter, r *http.Request, userID int64) (ok bool) {
+	if userID < 1 {
+		return true
+	}
+
+	as, err := db.GetAppSettings(r.Context())
+	if err != nil {
+		output.Error(err, "Could not look up app settings.", w)
+		return false
+	}
+
+	if !as.Allow2FactorAuth || !as.Require2FAForLicenseCreation {
+		return true
+	}
+
+	return users.Check2FAStepUp(w, r, userID)
+}
+
+// checkSigningKeyPair checks if the key pair used for an existing license can still
+// be used to sign the license again, or data for the license. The returned errMsg
+// describes why the key pair cannot be used. The license is described by which, ex.:
+// "the original license", and action is what is being done, ex.: "renewed".
+func checkSigningKeyPair(kp db.KeyPair, which, action string) (errMsg string) {
+	if !kp.Active {
+		return "This key pair used for " + which + " is no longer active. This license cannot be " + action + "."
+	}
+	if kp.Compromised {
+		return "This key pair used for " + which + " is marked as compromised. This license cannot be " + action + "."
+	}
+	if !keypairs.MeetsMinimum(kp.AlgorithmType) {
+		return "This key pair used for " + which + " uses " + string(kp.AlgorithmType) + " which is weaker than the minimum allowed algorithm, " + string(config.Data().MinimumKeyPairAlgorithm) + ". This license cannot be " + action + "."
+	}
+
+	return ""
+}
+
+// apiKeyAllowedApp checks if an API key can create licenses for an app. An API key
+// that isn't restricted to specific apps can create licenses for any app.
+func apiKeyAllowedApp(ctx context.Context, apiKeyID, appID int64) (allowed bool, err error) {
+	cols := sqldb.Columns{
+		db.TableAPIKeys + ".ID",
+		db.TableAPIKeys + ".RestrictToApps",
+	}
+	k, err := db.GetAPIKeyByID(ctx, apiKeyID, cols)
+	if err != nil {
+		return
+	}
+	if !k.RestrictToApps {
+		return true, nil
+	}
+
+	_, err = db.GetAPIKeyApp(ctx, apiKeyID, appID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return
+	}
+
+	return true, nil
+}
+
+// notifyLicenseEvent posts a notification about an event that occured to a license.
+func notifyLicenseEvent(ctx context.Context, event string, l db.License, userID, apiKeyID int64) {
+	target := l.CompanyName + ", license " + strconv.FormatInt(l.ID, 10)
+	if l.AppName != "" {
+		target += " (" + l.AppName + ")"
+	}
+
+	notifications.Notify(ctx, event, userID, apiKeyID, target)
+}
+
+// recordLicenseCreated records a created license for metrics based on if the license
+// was created by a user or via an API key.
+func recordLicenseCreated(apiKeyID int64) {
+	source := metrics.SourceUser
+	if apiKeyID > 0 {
+		source = metrics.SourceAPIKey
+	}
+
+	metrics.LicenseCreated(source)
+}
+
 // writeReadVerify is used to verify a just created license data and signature. This
 // performs the same "read and verify" that a third-party app would.
 func writeReadVerify(f licensefile.File, keyPairAlgo licensefile.KeyPairAlgoType, publicKey []byte) (err error) {
@@ -1144,12 +1692,50 @@ func writeReadVerify(f licensefile.File, keyPairAlgo licensefile.KeyPairAlgoType
 // replaceFilenamePlaceholders replaces placeholders in filename that was defined for
 // an app with the correct associated data. This generates the actual filename a license
 // will be downloaded as.
-func replaceFilenamePlaceholders(filename string, licenseID int64, appName string, format licensefile.FileFormat) string {
-	filename = strings.ReplaceAll(filename, "{licenseID}", strconv.FormatInt(licenseID, 10))
+//
+// If a placeholder's value is blank, the placeholder is removed. The resulting
+// filename is sanitized so that it is safe to use on common filesystems.
+func replaceFilenamePlaceholders(filename string, l db.License, appName string, format licensefile.FileFormat) string {
+	r := strings.NewReplacer(
+		"{licenseID}", strconv.FormatInt(l.ID, 10),
+		"{appName}", appName,
+		"{company}", l.CompanyName,
+		"{expireDate}", l.ExpireDate,
+		"{ext}", string(format),
+	)
+	filename = r.Replace(filename)
+
+	filename = sanitizeFilename(filename)
+	if filename == "" || strings.HasPrefix(filename, ".") {
+		filename = "license" + filename
+	}
 
-	filename = strings.ReplaceAll(filename, "{appName}", appName)
+	return filename
+}
+
+// filenameRepeatedSeparators matches runs of separator characters. These are left
+// behind when a placeholder is replaced with a blank value or when invalid characters
+// are replaced.
+var filenameRepeatedSeparators = regexp.MustCompile(`([_\-. ])[_\-. ]*([_\-. ])`)
+
+// sanitizeFilename replaces characters that are not allowed in filenames on common
+// filesystems, and control characters, with underscores. Repeated separators are
+// collapsed and leading or trailing separators are removed.
+func sanitizeFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, filename)
 
-	filename = strings.ReplaceAll(filename, "{ext}", string(format))
+	filename = filenameRepeatedSeparators.ReplaceAllStringFunc(filename, func(m string) string {
+		//Keep the period before a file extension.
+		if strings.HasSuffix(m, ".") {
+			return "."
+		}
+		return m[:1]
+	})
 
-	return filename
+	return strings.Trim(filename, "_- ")
 }
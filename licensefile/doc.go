@@ -10,10 +10,29 @@ fields in the license key file. The signature authenticates the data in the lice
 key file; if any data in the license key file is changed, or the signature is changed,
 validation will fail.
 
+A license key file can optionally start with a human readable header, for example
+legal text or support contact info. Each line of the header starts with "#" (see
+HeaderCommentPrefix). The header is not signed and is removed before the data is
+unmarshalled, so it can be changed without invalidating the license.
+
+The Algorithm and FormatVersion fields record the key pair algorithm and the version
+of the signing scheme a license key file was signed with. Both fields are signed. An
+app verifying a license key file can leave the algorithm blank when calling
+VerifySignature() to use the file's Algorithm. License key files signed before these
+fields existed verify as before, using DefaultKeyPairAlgo when no algorithm is
+provided.
+
+CurrentFormatVersion is only incremented when a change would prevent an older version
+of this package from verifying a license key file, such as a change to hashing or
+signature encoding. Adding an optional field that is omitted when empty does not
+require a new version. License key files with a newer FormatVersion than this package
+supports are rejected with ErrUnsupportedFormatVersion so that the app knows it must
+be updated.
+
 # Creating and Signing a License Key File
 
 The process of creating a license key file and signing it is as follows:
- 1. A File's fields are populated.
+ 1. A File's fields are populated, including Algorithm and FormatVersion.
  2. The File is marshalled, using FileFormat, to a byte slice.
  3. The bytes are hashed.
  4. The hash is signed via a previously defined private key.
@@ -31,6 +50,26 @@ The process of reading and verifying a license key file is as follows:
  4. The File is marshalled and the resulting bytes are hashed.
  5. The decoded signature is compared against the hash using a public key.
  6. If the signature is valid, the license key file's data can be used.
- 7. Check that the license isn't expired.
+ 7. Check that the license isn't expired, and is valid yet if ValidFrom is set.
+ 8. Check which features are enabled for the license using HasFeature().
+ 9. Check that the license is for the running build using MatchesArtifact(), if the license is bound to a build.
+ 10. Read the license's custom fields, using SchemaVersion() to handle licenses created before the fields changed.
+
+# Detached Signatures
+
+The signature can also be distributed separately from the license data, for example
+when the data is stored in a customer's configuration system. Payload() returns the
+exact bytes that were signed, the marshalled File without its signature, and
+SignatureOnly() returns the signature along with the Algorithm and FormatVersion
+needed to verify it. VerifyDetached() hashes the payload as is, without unmarshalling
+it, so the payload must be stored byte for byte; reformatting it will cause
+verification to fail.
+
+# Compact Licenses
+
+WriteCompact() writes a File as a single line of gzipped, base64 encoded data for
+clients that prefer not to handle a multi-line file. FromCompact() decodes the data
+back into a File. The decoded data is exactly the license key file that was written,
+so the File is verified the same as a license key file that was read with Read().
 */
 package licensefile
@@ -0,0 +1,270 @@
+package licensefile
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+//This file handles offline activation of a license for air-gapped machines. The
+//process is as follows:
+// 1. Your app builds an ActivationRequest with NewActivationRequest() and shows the
+//    request code, from Code(), to the customer.
+// 2. The customer sends the request code to you, typically via email.
+// 3. You paste the request code into the license server which returns a response
+//    code signed with the license's private key.
+// 4. The customer enters the response code into your app which verifies it with
+//    VerifyResponse() using the same ActivationRequest from step 1.
+//
+//The response is tied to the machine ID and nonce in the request, so a response
+//cannot be reused on a different machine or for a different request. Your app must
+//store the ActivationRequest, or at least the nonce, between steps 1 and 4.
+//
+//A response is not signed as a File. The signed data is an activationPayload which
+//starts with a fixed purpose and a version. Since the request code is provided by the
+//customer, signing a File built from it would allow a customer to get the private
+//key's signature of data they control. The purpose ensures a signed response can
+//never be used in place of a license key file, or any other signed data, and vice
+//versa.
+
+// Errors when handling offline activation.
+var (
+	// ErrInvalidActivationCode is returned when a request or response code cannot be
+	// decoded or is missing required data.
+	ErrInvalidActivationCode = errors.New("invalid activation code")
+
+	// ErrActivationMismatch is returned from VerifyResponse() when a response was
+	// generated for a different license, machine, or request.
+	ErrActivationMismatch = errors.New("activation response does not match request")
+
+	// ErrUnsupportedActivationVersion is returned from VerifyResponse() when a
+	// response was signed using a newer version of the activation payload than this
+	// package supports.
+	ErrUnsupportedActivationVersion = errors.New("activation response version is not supported")
+)
+
+// activationPurpose is the first field in each signed activation payload. This is
+// checked when verifying a response so that a signature of any other data is never
+// accepted as an activation response.
+const activationPurpose = "licensekeys offline activation response"
+
+// activationVersion is the version of the activation payload that is signed. This
+// is incremented if the payload changes.
+const activationVersion = 1
+
+// ActivationMachineIDMaxLength is the maximum number of characters in the machine
+// ID of an activation request.
+const ActivationMachineIDMaxLength = 255
+
+// activationNonceLength is the number of random bytes in a nonce, before hex
+// encoding.
+const activationNonceLength = 16
+
+// ActivationRequest is the data your app sends to the license server to activate a
+// license offline.
+type ActivationRequest struct {
+	LicenseID int64
+	MachineID string //an identifier unique to the machine your app is running on.
+	Nonce     string //random, hex encoded, so that each request gets a unique response.
+}
+
+// ActivationResponse is the data the license server returns to your app to activate
+// a license offline. The Signature is a signature of the other fields using the
+// license's private key.
+type ActivationResponse struct {
+	Version   int
+	LicenseID int64
+	MachineID string
+	Nonce     string
+	Signature string
+}
+
+// activationPayload is the data signed for an activation response. This is encoded
+// as JSON, with the fields in the order listed, before signing.
+type activationPayload struct {
+	Purpose   string
+	Version   int
+	LicenseID int64
+	MachineID string
+	Nonce     string
+}
+
+// NewActivationRequest returns a request to activate a license on a machine with a
+// new random nonce.
+func NewActivationRequest(licenseID int64, machineID string) (ar ActivationRequest, err error) {
+	if licenseID < 1 || !validActivationMachineID(machineID) {
+		err = ErrInvalidActivationCode
+		return
+	}
+
+	nonce := make([]byte, activationNonceLength)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+
+	ar = ActivationRequest{
+		LicenseID: licenseID,
+		MachineID: machineID,
+		Nonce:     hex.EncodeToString(nonce),
+	}
+	return
+}
+
+// Code returns the request encoded as text that a customer can copy and send to you.
+func (ar ActivationRequest) Code() (code string, err error) {
+	return encodeActivationCode(ar)
+}
+
+// ParseActivationRequest decodes a request code, from Code(), into an
+// ActivationRequest.
+func ParseActivationRequest(code string) (ar ActivationRequest, err error) {
+	err = decodeActivationCode(code, &ar)
+	if err != nil {
+		return
+	}
+
+	err = ar.validate()
+	return
+}
+
+// validate checks that a request has a license ID, a machine ID that isn't too long,
+// and a nonce generated by NewActivationRequest().
+func (ar ActivationRequest) validate() error {
+	if ar.LicenseID < 1 || !validActivationMachineID(ar.MachineID) || !validActivationNonce(ar.Nonce) {
+		return ErrInvalidActivationCode
+	}
+
+	return nil
+}
+
+// validActivationMachineID checks that a machine ID is provided and isn't too long.
+func validActivationMachineID(machineID string) bool {
+	return strings.TrimSpace(machineID) != "" && len(machineID) <= ActivationMachineIDMaxLength
+}
+
+// validActivationNonce checks that a nonce is hex encoded and the length generated
+// by NewActivationRequest().
+func validActivationNonce(nonce string) bool {
+	if len(nonce) != hex.EncodedLen(activationNonceLength) {
+		return false
+	}
+
+	_, err := hex.DecodeString(nonce)
+	return err == nil
+}
+
+// SignActivation signs a response to an activation request and returns the response
+// code. The private key must be decrypted, if needed, prior to being provided and
+// must be the private key the license was signed with.
+func SignActivation(ar ActivationRequest, privateKey []byte, keyPairAlgo KeyPairAlgoType) (code string, err error) {
+	err = ar.validate()
+	if err != nil {
+		return
+	}
+
+	err = keyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+
+	payload, err := activationPayloadBytes(activationVersion, ar.LicenseID, ar.MachineID, ar.Nonce)
+	if err != nil {
+		return
+	}
+
+	sig, err := signBytes(payload, privateKey, keyPairAlgo)
+	if err != nil {
+		return
+	}
+
+	return encodeActivationCode(ActivationResponse{
+		Version:   activationVersion,
+		LicenseID: ar.LicenseID,
+		MachineID: ar.MachineID,
+		Nonce:     ar.Nonce,
+		Signature: sig,
+	})
+}
+
+// VerifyResponse checks if a response code is valid for the request. The response
+// must have been generated for this request's license, machine ID, and nonce, and
+// the response's signature must be valid for the public key.
+func (ar ActivationRequest) VerifyResponse(code string, publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	var resp ActivationResponse
+	err = decodeActivationCode(code, &resp)
+	if err != nil {
+		return
+	}
+
+	if resp.Version != activationVersion {
+		err = ErrUnsupportedActivationVersion
+		return
+	}
+
+	if resp.LicenseID != ar.LicenseID || resp.MachineID != ar.MachineID || resp.Nonce != ar.Nonce {
+		err = ErrActivationMismatch
+		return
+	}
+
+	err = keyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+
+	//Rebuild the payload, with the purpose, so that only a signature of an
+	//activation payload is accepted.
+	payload, err := activationPayloadBytes(resp.Version, resp.LicenseID, resp.MachineID, resp.Nonce)
+	if err != nil {
+		return
+	}
+
+	err = verifyBytes(payload, resp.Signature, publicKey, keyPairAlgo)
+	return
+}
+
+// activationPayloadBytes returns the data that is signed, or verified, for an
+// activation response. JSON is always used so that the signed data doesn't depend on
+// the license's FileFormat.
+func activationPayloadBytes(version int, licenseID int64, machineID, nonce string) (b []byte, err error) {
+	return json.Marshal(activationPayload{
+		Purpose:   activationPurpose,
+		Version:   version,
+		LicenseID: licenseID,
+		MachineID: machineID,
+		Nonce:     nonce,
+	})
+}
+
+// encodeActivationCode marshals a request or response to JSON and encodes it as
+// base64 so that it can be easily copied and pasted.
+func encodeActivationCode(v any) (code string, err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	code = base64.RawURLEncoding.EncodeToString(b)
+	return
+}
+
+// decodeActivationCode decodes a request or response code into v. Whitespace is
+// removed first since codes are typically pasted from an email.
+func decodeActivationCode(code string, v any) (err error) {
+	code = strings.Join(strings.Fields(code), "")
+
+	b, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil {
+		return ErrInvalidActivationCode
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return ErrInvalidActivationCode
+	}
+
+	return
+}
@@ -0,0 +1,245 @@
+package licensefile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestActivation(t *testing.T) {
+	//generate key pair
+	private, public, err := GenerateKeyPairECDSA(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//build the request, as the client would, and pass it to the server as a code
+	ar, err := NewActivationRequest(10001, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	requestCode, err := ar.Code()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//parse the request and sign a response, as the server would
+	parsed, err := ParseActivationRequest(requestCode)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if parsed != ar {
+		t.Fatalf("Request mismatch, got %+v, expected %+v", parsed, ar)
+		return
+	}
+
+	responseCode, err := SignActivation(parsed, private, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//verify the response, as the client would
+	err = ar.VerifyResponse(responseCode, public, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal("Response should have verified", err)
+		return
+	}
+
+	//a response for one machine should not verify on another machine
+	other := ar
+	other.MachineID = "machine-b"
+	err = other.VerifyResponse(responseCode, public, KeyPairAlgoECDSAP256)
+	if err != ErrActivationMismatch {
+		t.Fatal("Response for a different machine should not verify", err)
+		return
+	}
+
+	//a response for one request should not verify for a new request
+	newRequest, err := NewActivationRequest(10001, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = newRequest.VerifyResponse(responseCode, public, KeyPairAlgoECDSAP256)
+	if err != ErrActivationMismatch {
+		t.Fatal("Response for a different nonce should not verify", err)
+		return
+	}
+
+	//a response with an altered machine ID should not verify
+	altered, err := encodeActivationCode(ActivationResponse{
+		Version:   activationVersion,
+		LicenseID: other.LicenseID,
+		MachineID: other.MachineID,
+		Nonce:     other.Nonce,
+		Signature: activationSignature(t, responseCode),
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = other.VerifyResponse(altered, public, KeyPairAlgoECDSAP256)
+	if err != ErrBadSignature {
+		t.Fatal("Altered response should not verify", err)
+		return
+	}
+
+	//a response from a newer version should not verify
+	newer, err := encodeActivationCode(ActivationResponse{
+		Version:   activationVersion + 1,
+		LicenseID: ar.LicenseID,
+		MachineID: ar.MachineID,
+		Nonce:     ar.Nonce,
+		Signature: activationSignature(t, responseCode),
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = ar.VerifyResponse(newer, public, KeyPairAlgoECDSAP256)
+	if err != ErrUnsupportedActivationVersion {
+		t.Fatal("Response from newer version should not verify", err)
+		return
+	}
+
+	//garbage should not parse
+	_, err = ParseActivationRequest("not a code")
+	if err != ErrInvalidActivationCode {
+		t.Fatal("Invalid code should not parse", err)
+		return
+	}
+}
+
+func TestActivationNotLicense(t *testing.T) {
+	//generate key pair
+	private, public, err := GenerateKeyPairECDSA(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	ar, err := NewActivationRequest(10001, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	responseCode, err := SignActivation(ar, private, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//the signature of a response should not verify as a license file built from the
+	//same customer provided data
+	f := File{
+		LicenseID: ar.LicenseID,
+		Metadata: map[string]any{
+			"ActivationMachineID": ar.MachineID,
+			"ActivationNonce":     ar.Nonce,
+		},
+		fileFormat: FileFormatJSON,
+		Signature:  activationSignature(t, responseCode),
+	}
+	err = f.VerifySignature(public, KeyPairAlgoECDSAP256)
+	if err != ErrBadSignature {
+		t.Fatal("Response signature should not verify as a license file", err)
+		return
+	}
+
+	//the signature of a license file should not verify as a response
+	f.Signature = ""
+	err = f.Sign(private, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	forged, err := encodeActivationCode(ActivationResponse{
+		Version:   activationVersion,
+		LicenseID: ar.LicenseID,
+		MachineID: ar.MachineID,
+		Nonce:     ar.Nonce,
+		Signature: f.Signature,
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = ar.VerifyResponse(forged, public, KeyPairAlgoECDSAP256)
+	if err != ErrBadSignature {
+		t.Fatal("License file signature should not verify as a response", err)
+		return
+	}
+}
+
+func TestActivationRequestBounds(t *testing.T) {
+	ar, err := NewActivationRequest(10001, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	tt := []struct {
+		name      string
+		machineID string
+		nonce     string
+		valid     bool
+	}{
+		{"valid", ar.MachineID, ar.Nonce, true},
+		{"max length machine ID", strings.Repeat("a", ActivationMachineIDMaxLength), ar.Nonce, true},
+		{"machine ID too long", strings.Repeat("a", ActivationMachineIDMaxLength+1), ar.Nonce, false},
+		{"blank machine ID", " ", ar.Nonce, false},
+		{"nonce too long", ar.MachineID, ar.Nonce + "00", false},
+		{"nonce too short", ar.MachineID, ar.Nonce[2:], false},
+		{"nonce not hex", ar.MachineID, strings.Repeat("z", len(ar.Nonce)), false},
+	}
+
+	private, _, err := GenerateKeyPairECDSA(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, tc := range tt {
+		req := ActivationRequest{
+			LicenseID: ar.LicenseID,
+			MachineID: tc.machineID,
+			Nonce:     tc.nonce,
+		}
+
+		code, err := req.Code()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		_, err = ParseActivationRequest(code)
+		if (err == nil) != tc.valid {
+			t.Fatal("ParseActivationRequest mismatch", tc.name, err)
+			return
+		}
+
+		_, err = SignActivation(req, private, KeyPairAlgoECDSAP256)
+		if (err == nil) != tc.valid {
+			t.Fatal("SignActivation mismatch", tc.name, err)
+			return
+		}
+	}
+}
+
+// activationSignature returns the signature from a response code.
+func activationSignature(t *testing.T, code string) string {
+	var resp ActivationResponse
+	err := decodeActivationCode(code, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.Signature
+}
@@ -0,0 +1,75 @@
+package licensefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// This file handles binding a license to a specific build of a third-party app. A
+// "File Hash" custom field stores the SHA-256 hash of a build, an "artifact", in the
+// license's Metadata. The third-party app hashes its own executable, see
+// HashArtifact(), and checks that the hash is listed in the license, see
+// MatchesArtifact().
+
+// MatchesArtifact returns if the given hex encoded SHA-256 hash matches the value of
+// any Metadata field in a license File. This is used to check that a license is for
+// the build of the app that is running. Hashes are compared case-insensitively. False
+// is returned if the given hash is not a SHA-256 hash.
+//
+// Any Metadata field is checked, not just a specifically named field, so that a
+// license can be bound to multiple builds, for example a build per operating system,
+// using multiple "File Hash" fields.
+//
+// You should only call this AFTER calling VerifySignature() otherwise the Metadata in
+// the File is untrustworthy and could have been modified.
+func (f *File) MatchesArtifact(hash string) bool {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if !validSHA256(hash) {
+		return false
+	}
+
+	for _, v := range f.Metadata {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		if strings.ToLower(strings.TrimSpace(s)) == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HashArtifact returns the hex encoded SHA-256 hash of the file at the given path.
+// This is typically used with os.Executable() to get the hash of the running app for
+// use with MatchesArtifact().
+func HashArtifact(path string) (hash string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// validSHA256 checks if s is a lowercase hex encoded SHA-256 hash.
+func validSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,89 @@
+package licensefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchesArtifact(t *testing.T) {
+	sum := sha256.Sum256([]byte("build"))
+	hash := hex.EncodeToString(sum[:])
+
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+		Metadata: map[string]any{
+			"MaxUsers":   10,
+			"BuildHash":  hash,
+			"SupportURL": "https://example.com",
+		},
+	}
+
+	//Matching hash, in any case.
+	if !f.MatchesArtifact(hash) {
+		t.Fatal("Hash should match.")
+		return
+	}
+	if !f.MatchesArtifact(strings.ToUpper(hash)) {
+		t.Fatal("Hash should match case-insensitively.")
+		return
+	}
+
+	//Different hash.
+	other := sha256.Sum256([]byte("other build"))
+	if f.MatchesArtifact(hex.EncodeToString(other[:])) {
+		t.Fatal("Different hash should not match.")
+		return
+	}
+
+	//Not a hash, even if it matches a field's value.
+	if f.MatchesArtifact("https://example.com") {
+		t.Fatal("Value that isn't a hash should not match.")
+		return
+	}
+	if f.MatchesArtifact("") {
+		t.Fatal("Blank hash should not match.")
+		return
+	}
+
+	//No metadata.
+	f.Metadata = nil
+	if f.MatchesArtifact(hash) {
+		t.Fatal("License without metadata should not match.")
+		return
+	}
+}
+
+func TestHashArtifact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app")
+	err := os.WriteFile(path, []byte("build"), 0600)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	hash, err := HashArtifact(path)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	sum := sha256.Sum256([]byte("build"))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Fatal("Hash mismatch.", hash)
+		return
+	}
+
+	//File doesn't exist.
+	_, err = HashArtifact(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("Error should have occured for missing file.")
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package licensefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// This file handles the compact form of a license key file. The compact form is the
+// license key file, exactly as written by Write(), gzipped and base64 encoded to a
+// single line. This is useful for embedded clients, or clients that store the license
+// in an environment variable or configuration value, where a multi-line file with many
+// custom fields is inconvenient.
+//
+// The compact form is only an encoding for transport. The decompressed bytes are the
+// same bytes as the license key file, so the signature is verified exactly the same
+// as when reading a license key file.
+
+// ErrInvalidCompact is returned when a compact license cannot be decoded.
+var ErrInvalidCompact = errors.New("invalid compact license")
+
+// compactMaxSize is the largest a decompressed compact license can be. This prevents a
+// maliciously crafted compact license from using excessive memory when decompressed.
+// License key files are only a few KB, even with many custom fields.
+const compactMaxSize = 1 << 20 //1 MB
+
+// WriteCompact writes a File to out as a single line of gzipped, base64 encoded data.
+// The data is the same as written by Write(), including the header, if any. Use
+// FromCompact() to decode the data.
+func (f *File) WriteCompact(out io.Writer) (err error) {
+	//Write the license key file as normal.
+	var raw bytes.Buffer
+	err = f.Write(&raw)
+	if err != nil {
+		return
+	}
+
+	//Compress.
+	var compressed bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return
+	}
+	_, err = zw.Write(raw.Bytes())
+	if err != nil {
+		return
+	}
+	err = zw.Close()
+	if err != nil {
+		return
+	}
+
+	//Encode and write.
+	_, err = io.WriteString(out, base64.StdEncoding.EncodeToString(compressed.Bytes()))
+	return
+}
+
+// FromCompact decodes a compact license, see WriteCompact(), into a File. The File's
+// FileFormat is determined from the decompressed data, see DetectFileFormat(). Whitespace is removed first
+// since a compact license may be copied with line breaks.
+//
+// This DOES NOT check if the File's signature is valid nor if the license is expired,
+// the same as Read(). You should call VerifySignature() and Expired() on the returned
+// File immediately after calling this func.
+func FromCompact(s string) (f File, err error) {
+	s = strings.Join(strings.Fields(s), "")
+
+	compressed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return f, ErrInvalidCompact
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return f, ErrInvalidCompact
+	}
+	defer zr.Close()
+
+	//Read one more byte than allowed to detect data that is too large.
+	raw, err := io.ReadAll(io.LimitReader(zr, compactMaxSize+1))
+	if err != nil || len(raw) > compactMaxSize {
+		return f, ErrInvalidCompact
+	}
+
+	return Unmarshal(raw, DetectFileFormat(raw))
+}
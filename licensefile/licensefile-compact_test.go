@@ -0,0 +1,115 @@
+package licensefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	for _, format := range []FileFormat{FileFormatJSON, FileFormatYAML} {
+		f := File{
+			CompanyName: "CompanyName",
+			ExpireDate:  "2006-01-02",
+			Features:    []string{"reporting"},
+			Metadata: map[string]any{
+				"Seats": 10,
+				"Build": "abc",
+			},
+			fileFormat: format,
+		}
+		f.SetHeader("Support: support@example.com")
+
+		priv, pub, err := GenerateKeyPair(KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		err = f.Sign(priv, KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		//Compact form should be a single line.
+		var compact bytes.Buffer
+		err = f.WriteCompact(&compact)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if strings.ContainsAny(compact.String(), "\r\n ") {
+			t.Fatal("Compact form should be a single line", compact.String())
+			return
+		}
+
+		//Decoded File should be the same as the original, byte for byte, and verify.
+		decoded, err := FromCompact(compact.String())
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if decoded.FileFormat() != format {
+			t.Fatal("FileFormat not detected", format, decoded.FileFormat())
+			return
+		}
+
+		var original, roundTrip bytes.Buffer
+		if err := f.Write(&original); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err := decoded.Write(&roundTrip); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if !bytes.Equal(original.Bytes(), roundTrip.Bytes()) {
+			t.Fatal("Round trip mismatch", original.String(), roundTrip.String())
+			return
+		}
+
+		err = decoded.VerifySignature(pub, KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal("Decoded File should verify", format, err)
+			return
+		}
+
+		//Line breaks, as when copied from an email, should be ignored.
+		s := compact.String()
+		wrapped := s[:len(s)/2] + "\n" + s[len(s)/2:]
+		_, err = FromCompact(wrapped)
+		if err != nil {
+			t.Fatal("Line breaks should be ignored", err)
+			return
+		}
+	}
+}
+
+func TestFromCompactInvalid(t *testing.T) {
+	//Not base64.
+	_, err := FromCompact("not base64!")
+	if err != ErrInvalidCompact {
+		t.Fatal("ErrInvalidCompact should have been returned", err)
+		return
+	}
+
+	//Not gzipped.
+	_, err = FromCompact(base64.StdEncoding.EncodeToString([]byte("CompanyName: x")))
+	if err != ErrInvalidCompact {
+		t.Fatal("ErrInvalidCompact should have been returned", err)
+		return
+	}
+
+	//Too large once decompressed.
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write(bytes.Repeat([]byte("a"), compactMaxSize+1))
+	zw.Close()
+	_, err = FromCompact(base64.StdEncoding.EncodeToString(b.Bytes()))
+	if err != ErrInvalidCompact {
+		t.Fatal("ErrInvalidCompact should have been returned", err)
+		return
+	}
+}
@@ -0,0 +1,173 @@
+package licensefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DetachedSignature is a File's signature distributed separately from the File's
+// data. This is used when the data is stored somewhere other than a license key
+// file, for example in a customer's configuration system, and the signature is
+// verified independently using VerifyDetached().
+//
+// The data that was signed, the payload, is returned by File.Payload().
+type DetachedSignature struct {
+	//Algorithm and FormatVersion are copied from the File. These are needed to know
+	//how to verify the signature.
+	Algorithm     KeyPairAlgoType `yaml:"Algorithm"`
+	FormatVersion int             `json:"FormatVersion,omitempty" yaml:"FormatVersion,omitempty"`
+
+	//KeyID identifies the public key needed to verify the signature, see
+	//PublicKeyID(). This is optional and is set by the caller of SignatureOnly()
+	//since a File does not know which key pair signed it.
+	KeyID string `json:"KeyID,omitempty" yaml:"KeyID,omitempty"`
+
+	//Signature and Signatures are copied from the File.
+	Signature  string   `yaml:"Signature"`
+	Signatures []string `json:"Signatures,omitempty" yaml:"Signatures,omitempty"`
+}
+
+// Payload returns the data of a File that is signed. This is the File marshalled,
+// per the File's FileFormat, without the Signature and Signatures fields. These
+// exact bytes must be provided to VerifyDetached(); reformatting the payload, for
+// example changing whitespace, will cause verification to fail.
+//
+// The header, see SetHeader(), is not included since it is not signed.
+func (f *File) Payload() (b []byte, err error) {
+	//Use a copy so the File's signatures are not removed.
+	c := *f
+	c.Signature = ""
+	c.Signatures = nil
+
+	return c.Marshal()
+}
+
+// SigningPayload returns the exact bytes Sign() will hash and sign with the provided
+// key pair algorithm, and the resulting hash, without signing the File. This is used
+// for debugging signature mismatches since a third party can compare these bytes,
+// and the hash, against the bytes and hash calculated by their own tooling.
+//
+// The File is not modified. The Algorithm and FormatVersion are set on a copy of the
+// File, the same as Sign() does, since they are part of the signed data.
+func (f *File) SigningPayload(keyPairAlgo KeyPairAlgoType) (payload, hash []byte, err error) {
+	err = keyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+
+	c := *f
+	c.Algorithm = keyPairAlgo
+	c.FormatVersion = CurrentFormatVersion
+
+	payload, err = c.Payload()
+	if err != nil {
+		return
+	}
+
+	hash, err = hashBytes(payload, keyPairAlgo)
+	return
+}
+
+// SignatureOnly returns a File's signature as a DetachedSignature. The returned
+// DetachedSignature's KeyID can be set using PublicKeyID() to help the verifying app
+// choose the correct public key.
+func (f *File) SignatureOnly() (d DetachedSignature, err error) {
+	if f.Signature == "" {
+		err = ErrNotSigned
+		return
+	}
+
+	d = DetachedSignature{
+		Algorithm:     f.SignatureAlgorithm(),
+		FormatVersion: f.FormatVersion,
+		Signature:     f.Signature,
+		Signatures:    f.Signatures,
+	}
+	return
+}
+
+// Marshal serializes a DetachedSignature to the provided format. This is used to
+// write the DetachedSignature to a file.
+func (d DetachedSignature) Marshal(format FileFormat) (b []byte, err error) {
+	err = format.Valid()
+	if err != nil {
+		return
+	}
+
+	switch format {
+	case FileFormatYAML:
+		b, err = yaml.Marshal(d)
+	case FileFormatJSON:
+		b, err = json.MarshalIndent(d, "", "  ")
+	}
+
+	return
+}
+
+// UnmarshalDetachedSignature deserializes a DetachedSignature from the provided
+// format. This is used when reading a detached signature file.
+func UnmarshalDetachedSignature(in []byte, format FileFormat) (d DetachedSignature, err error) {
+	err = format.Valid()
+	if err != nil {
+		return
+	}
+
+	switch format {
+	case FileFormatYAML:
+		err = yaml.Unmarshal(in, &d)
+	case FileFormatJSON:
+		err = json.Unmarshal(in, &d)
+	}
+
+	return
+}
+
+// VerifyDetached checks if sig is a valid signature of payload by checking it
+// against the publicKey. The payload must be the exact bytes returned by
+// File.Payload() when the signature was created.
+//
+// The DetachedSignature's Algorithm is used to choose how to verify the signature.
+// Only the Signature is verified, not any co-signatures in Signatures.
+//
+// Like VerifySignature(), this DOES NOT check if the license is expired. Unmarshal
+// the payload into a File, after verifying it, to check the expiration.
+func VerifyDetached(payload []byte, sig DetachedSignature, publicKey []byte) (err error) {
+	//Make sure this package knows how to verify the signature.
+	if sig.FormatVersion > CurrentFormatVersion {
+		return ErrUnsupportedFormatVersion
+	}
+
+	keyPairAlgo := sig.Algorithm
+	if keyPairAlgo == "" {
+		keyPairAlgo = DefaultKeyPairAlgo
+	}
+
+	err = keyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+
+	//Verify the payload as is, without unmarshalling and marshalling, so that the
+	//exact bytes are verified.
+	return verifyBytes(payload, sig.Signature, publicKey, keyPairAlgo)
+}
+
+// PublicKeyID returns a short identifier for a PEM encoded public key. This is the
+// first 8 bytes, hex encoded, of the SHA-256 hash of the key's DER bytes. This is
+// used to identify which public key verifies a DetachedSignature without including
+// the public key itself.
+func PublicKeyID(publicKey []byte) (id string, err error) {
+	pemBlock, _ := pem.Decode(publicKey)
+	if pemBlock == nil {
+		err = errors.New("could not decode public key")
+		return
+	}
+
+	h := sha256.Sum256(pemBlock.Bytes)
+	return hex.EncodeToString(h[:8]), nil
+}
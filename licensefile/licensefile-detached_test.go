@@ -0,0 +1,236 @@
+package licensefile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignatureOnly(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		ExpireDate:  "2006-01-02",
+		fileFormat:  FileFormatJSON,
+	}
+
+	//Not signed.
+	_, err := f.SignatureOnly()
+	if err != ErrNotSigned {
+		t.Fatal("ErrNotSigned should have been returned", err)
+		return
+	}
+
+	//Signed.
+	priv, _, err := GenerateKeyPair(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = f.Sign(priv, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	d, err := f.SignatureOnly()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if d.Signature != f.Signature {
+		t.Fatal("Signature mismatch")
+		return
+	}
+	if d.Algorithm != KeyPairAlgoECDSAP256 || d.FormatVersion != CurrentFormatVersion {
+		t.Fatal("Algorithm or FormatVersion not set", d.Algorithm, d.FormatVersion)
+		return
+	}
+
+	//Payload should not include the signature nor modify the File.
+	payload, err := f.Payload()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if bytes.Contains(payload, []byte(f.Signature)) {
+		t.Fatal("Payload should not contain the signature")
+		return
+	}
+	if f.Signature != d.Signature {
+		t.Fatal("Payload should not remove the File's signature")
+		return
+	}
+}
+
+func TestVerifyDetached(t *testing.T) {
+	algos := []KeyPairAlgoType{
+		KeyPairAlgoED25519,
+		KeyPairAlgoECDSAP384,
+		KeyPairAlgoRSA2048,
+	}
+
+	for _, algo := range algos {
+		for _, format := range []FileFormat{FileFormatJSON, FileFormatYAML} {
+			f := File{
+				CompanyName: "CompanyName",
+				ExpireDate:  "2006-01-02",
+				Features:    []string{"reporting"},
+				fileFormat:  format,
+			}
+
+			priv, pub, err := GenerateKeyPair(algo)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			err = f.Sign(priv, algo)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+
+			payload, err := f.Payload()
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			d, err := f.SignatureOnly()
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+
+			//Round trip the signature through a file.
+			b, err := d.Marshal(format)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			d, err = UnmarshalDetachedSignature(b, format)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+
+			err = VerifyDetached(payload, d, pub)
+			if err != nil {
+				t.Fatal("Detached signature should verify", algo, format, err)
+				return
+			}
+
+			//Modified payload, even just whitespace, should not verify.
+			modified := append(bytes.Clone(payload), '\n')
+			err = VerifyDetached(modified, d, pub)
+			if err != ErrBadSignature {
+				t.Fatal("Modified payload should not verify", algo, format, err)
+				return
+			}
+
+			//Newer format version.
+			d.FormatVersion = CurrentFormatVersion + 1
+			err = VerifyDetached(payload, d, pub)
+			if err != ErrUnsupportedFormatVersion {
+				t.Fatal("ErrUnsupportedFormatVersion should have been returned", err)
+				return
+			}
+		}
+	}
+}
+
+func TestPublicKeyID(t *testing.T) {
+	_, pub1, err := GenerateKeyPair(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	_, pub2, err := GenerateKeyPair(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	id1, err := PublicKeyID(pub1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(id1) != 16 {
+		t.Fatal("ID should be 16 characters", id1)
+		return
+	}
+
+	id2, err := PublicKeyID(pub2)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if id1 == id2 {
+		t.Fatal("IDs should be different for different keys")
+		return
+	}
+
+	_, err = PublicKeyID([]byte("bad"))
+	if err == nil {
+		t.Fatal("Error should be returned for bad key")
+		return
+	}
+}
+
+func TestSigningPayload(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		ExpireDate:  "2006-01-02",
+		fileFormat:  FileFormatYAML,
+	}
+
+	//Invalid algorithm.
+	_, _, err := f.SigningPayload(KeyPairAlgoType("MD5"))
+	if err == nil {
+		t.Fatal("Error should have been returned for invalid algorithm")
+		return
+	}
+
+	for _, k := range keyPairAlgoTypes {
+		payload, hash, err := f.SigningPayload(k)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if f.Algorithm != "" || f.FormatVersion != 0 {
+			t.Fatal("SigningPayload should not modify the File")
+			return
+		}
+
+		//The payload must be exactly what is signed.
+		priv, _, err := GenerateKeyPair(k)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		signed := f
+		err = signed.Sign(priv, k)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		signedPayload, err := signed.Payload()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if !bytes.Equal(payload, signedPayload) {
+			t.Fatal("Payload mismatch", k, string(payload), string(signedPayload))
+			return
+		}
+
+		signedHash, err := signed.hash(k)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if !bytes.Equal(hash, signedHash) {
+			t.Fatal("Hash mismatch", k)
+			return
+		}
+	}
+}
@@ -81,15 +81,7 @@ func (f *File) SignECDSA(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err er
 	}
 
 	//Sign the hash.
-	//Decode the private key.
-	pemBlock, _ := pem.Decode(privateKey)
-	x509Key, err := x509.ParseECPrivateKey(pemBlock.Bytes)
-	if err != nil {
-		return
-	}
-
-	//Generate signature.
-	sig, err := ecdsa.SignASN1(rand.Reader, x509Key, h[:])
+	sig, err := signHashECDSA(privateKey, h)
 	if err != nil {
 		return
 	}
@@ -100,6 +92,19 @@ func (f *File) SignECDSA(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err er
 	return
 }
 
+// signHashECDSA signs the hash h using the ECDSA private key.
+func signHashECDSA(privateKey, h []byte) (sig []byte, err error) {
+	//Decode the private key.
+	pemBlock, _ := pem.Decode(privateKey)
+	x509Key, err := x509.ParseECPrivateKey(pemBlock.Bytes)
+	if err != nil {
+		return
+	}
+
+	//Generate signature.
+	return ecdsa.SignASN1(rand.Reader, x509Key, h[:])
+}
+
 // VerifySignatureECDSA checks if a File's signature is valid by checking it against
 // the ECDSA public key.
 //
@@ -129,6 +134,21 @@ func (f File) VerifySignatureECDSA(publicKey []byte, keyPairAlgo KeyPairAlgoType
 		return
 	}
 
+	return verifyHashECDSA(publicKey, h, decodedSig)
+}
+
+// VerifyECDSA calls VerifySignatureECDSA().
+//
+// Deprecated: This func is here just for legacy situations since the old
+// VerifyECDSA() func was renamed to VerifySignatureECDSA() for better clarity.
+// Use VerifySignatureECDSA() instead.
+func (f *File) VerifyECDSA(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	return f.VerifySignatureECDSA(publicKey, keyPairAlgo)
+}
+
+// verifyHashECDSA checks if sig is a valid signature of the hash h using the ECDSA
+// public key.
+func verifyHashECDSA(publicKey, h, sig []byte) (err error) {
 	//Decode the public key.
 	pemBlock, _ := pem.Decode(publicKey)
 	x509Key, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
@@ -138,19 +158,10 @@ func (f File) VerifySignatureECDSA(publicKey []byte, keyPairAlgo KeyPairAlgoType
 
 	//Verify signature.
 	//Note type conversion for x509Key. ParsePKIXPublicKey returns an interface.
-	valid := ecdsa.VerifyASN1(x509Key.(*ecdsa.PublicKey), h[:], decodedSig)
+	valid := ecdsa.VerifyASN1(x509Key.(*ecdsa.PublicKey), h[:], sig)
 	if !valid {
 		err = ErrBadSignature
 	}
 
 	return
 }
-
-// VerifyECDSA calls VerifySignatureECDSA().
-//
-// Deprecated: This func is here just for legacy situations since the old
-// VerifyECDSA() func was renamed to VerifySignatureECDSA() for better clarity.
-// Use VerifySignatureECDSA() instead.
-func (f *File) VerifyECDSA(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
-	return f.VerifySignatureECDSA(publicKey, keyPairAlgo)
-}
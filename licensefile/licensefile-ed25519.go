@@ -55,6 +55,19 @@ func (f *File) SignED25519(privateKey []byte) (err error) {
 	}
 
 	//Sign the hash.
+	sig, err := signHashED25519(privateKey, h)
+	if err != nil {
+		return
+	}
+
+	//Encode the signature and set to the Signature field.
+	f.encodeSignature(sig)
+
+	return
+}
+
+// signHashED25519 signs the hash h using the ED25519 private key.
+func signHashED25519(privateKey, h []byte) (sig []byte, err error) {
 	//Decode the private key.
 	pemBlock, _ := pem.Decode(privateKey)
 	x509Key, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
@@ -63,11 +76,7 @@ func (f *File) SignED25519(privateKey []byte) (err error) {
 	}
 
 	//Generate signature.
-	sig := ed25519.Sign(x509Key.(ed25519.PrivateKey), h[:])
-
-	//Encode the signature and set to the Signature field.
-	f.encodeSignature(sig)
-
+	sig = ed25519.Sign(x509Key.(ed25519.PrivateKey), h[:])
 	return
 }
 
@@ -97,6 +106,21 @@ func (f File) VerifySignatureED25519(publicKey []byte) (err error) {
 		return
 	}
 
+	return verifyHashED25519(publicKey, h, decodedSig)
+}
+
+// VerifyED25519 calls VerifySignatureED25519().
+//
+// Deprecated: This func is here just for legacy situations since the old
+// VerifyED25519() func was renamed to VerifySignatureED25519() for better clarity.
+// Use VerifySignatureED25519() instead.
+func (f *File) VerifyED25519(publicKey []byte) (err error) {
+	return f.VerifySignatureED25519(publicKey)
+}
+
+// verifyHashED25519 checks if sig is a valid signature of the hash h using the
+// ED25519 public key.
+func verifyHashED25519(publicKey, h, sig []byte) (err error) {
 	//Decode the public key.
 	pemBlock, _ := pem.Decode(publicKey)
 	x509Key, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
@@ -106,19 +130,10 @@ func (f File) VerifySignatureED25519(publicKey []byte) (err error) {
 
 	//Verify signature.
 	//Note type conversion for x509Key. ParsePKIXPublicKey returns an interface.
-	valid := ed25519.Verify(x509Key.(ed25519.PublicKey), h[:], decodedSig)
+	valid := ed25519.Verify(x509Key.(ed25519.PublicKey), h[:], sig)
 	if !valid {
 		err = ErrBadSignature
 	}
 
 	return
 }
-
-// VerifyED25519 calls VerifySignatureED25519().
-//
-// Deprecated: This func is here just for legacy situations since the old
-// VerifyED25519() func was renamed to VerifySignatureED25519() for better clarity.
-// Use VerifySignatureED25519() instead.
-func (f *File) VerifyED25519(publicKey []byte) (err error) {
-	return f.VerifySignatureED25519(publicKey)
-}
@@ -1,6 +1,7 @@
 package licensefile
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -32,6 +33,15 @@ func (f FileFormat) Valid() error {
 }
 
 // Marshal serializes a File to the format specified in the File's FileFormat.
+//
+// The output is deterministic since it is hashed when signing and verifying a File;
+// the same File must always produce the same bytes, and the same hash, no matter
+// which Go version or platform built the app. Struct fields are always output in the
+// order they are defined in File. Map keys, in Metadata and any maps nested within
+// Metadata, are always output sorted since both encoding/json and yaml.v2 sort map
+// keys when marshalling. Do not change the encoders, or how they are configured,
+// without making sure the output is byte-for-byte identical, otherwise existing
+// license files will fail verification. See TestMarshalDeterministic.
 func (f *File) Marshal() (b []byte, err error) {
 	err = f.fileFormat.Valid()
 	if err != nil {
@@ -56,12 +66,18 @@ func (f *File) Marshal() (b []byte, err error) {
 // verifying it/the signature. If unmarshalling is successful, the format is saved to
 // the File's FileFormat field. It is typically easier to call Read() instead since it
 // handles reading a file from a path and deserializing it.
+//
+// Any header is removed before deserializing, see SetHeader(), and is available via
+// the File's Header() func.
 func Unmarshal(in []byte, format FileFormat) (f File, err error) {
 	err = format.Valid()
 	if err != nil {
 		return
 	}
 
+	//Remove the header since it isn't part of the data and isn't valid JSON.
+	header, in := splitHeader(in)
+
 	switch format {
 	case FileFormatYAML:
 		err = yaml.Unmarshal(in, &f)
@@ -75,11 +91,25 @@ func Unmarshal(in []byte, format FileFormat) (f File, err error) {
 	//correctly prior to verifying the signature.
 	if err == nil {
 		f.fileFormat = format
+		f.header = header
 	}
 
 	return
 }
 
+// DetectFileFormat determines the file format of data read from a license file, or
+// elsewhere, for use with Unmarshal(). This is used when the format is not known
+// ahead of time, for example when a license file is uploaded. Marshalled JSON always
+// starts with a brace, after any header, and YAML never does.
+func DetectFileFormat(in []byte) FileFormat {
+	_, data := splitHeader(in)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return FileFormatJSON
+	}
+
+	return FileFormatYAML
+}
+
 // SetFileFormat populates the fileFormat field. This func is needed since the
 // fileFormat field is not exported since it is not distributed/written in a license
 // file.
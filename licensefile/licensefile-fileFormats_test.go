@@ -1,6 +1,11 @@
 package licensefile
 
-import "testing"
+import (
+	"bytes"
+	"crypto/sha256"
+	"strconv"
+	"testing"
+)
 
 func TestValidFileFormat(t *testing.T) {
 	//Provide a valid option.
@@ -94,3 +99,197 @@ func TestUnmarshal(t *testing.T) {
 		return
 	}
 }
+
+func TestDetectFileFormat(t *testing.T) {
+	f := File{
+		CompanyName: "test1",
+	}
+	f.SetHeader("Licensed to test1.\n{not json}")
+
+	for _, ff := range fileFormats {
+		f.fileFormat = ff
+
+		b := bytes.Buffer{}
+		err := f.Write(&b)
+		if err != nil {
+			t.Fatal("Write encountered error", err)
+			return
+		}
+
+		detected := DetectFileFormat(b.Bytes())
+		if detected != ff {
+			t.Fatal("DetectFileFormat returned wrong format", detected, ff)
+			return
+		}
+
+		out, err := Unmarshal(b.Bytes(), detected)
+		if err != nil {
+			t.Fatal("Unmarshal encountered error", err)
+			return
+		}
+		if out.CompanyName != f.CompanyName {
+			t.Fatal("Unmarshal error, CompanyName mismatch")
+			return
+		}
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	//Build the same logical File twice, adding the Metadata keys in opposite orders.
+	const numKeys = 100
+	newFile := func(reverse bool) File {
+		f := File{
+			CompanyName: "test1",
+			ContactName: "test2",
+			ExpireDate:  "2030-01-01",
+			Metadata:    map[string]any{},
+			Features:    []string{"a", "b"},
+		}
+		for i := 0; i < numKeys; i++ {
+			n := i
+			if reverse {
+				n = numKeys - 1 - i
+			}
+			f.Metadata["key"+strconv.Itoa(n)] = n
+		}
+		f.Metadata["nested"] = map[string]any{
+			"z": 1,
+			"a": "string",
+			"m": true,
+		}
+		return f
+	}
+
+	for _, ff := range fileFormats {
+		f1 := newFile(false)
+		f1.fileFormat = ff
+		f2 := newFile(true)
+		f2.fileFormat = ff
+
+		first, err := f1.Marshal()
+		if err != nil {
+			t.Fatal("Marshal encountered error", err)
+			return
+		}
+		want := sha256.Sum256(first)
+
+		//Marshal repeatedly, Go randomizes map iteration order so a non-sorted
+		//output would show up here.
+		for i := 0; i < 50; i++ {
+			b, err := f1.Marshal()
+			if err != nil {
+				t.Fatal("Marshal encountered error", err)
+				return
+			}
+			if sha256.Sum256(b) != want {
+				t.Fatal("Fingerprint mismatch on repeated Marshal", ff)
+				return
+			}
+
+			b, err = f2.Marshal()
+			if err != nil {
+				t.Fatal("Marshal encountered error", err)
+				return
+			}
+			if sha256.Sum256(b) != want {
+				t.Fatal("Fingerprint mismatch for File built in a different order", ff)
+				return
+			}
+		}
+
+		//Reading a File and marshalling it again, as is done when verifying, must
+		//produce the same output.
+		read, err := Unmarshal(first, ff)
+		if err != nil {
+			t.Fatal("Unmarshal encountered error", err)
+			return
+		}
+		b, err := read.Marshal()
+		if err != nil {
+			t.Fatal("Marshal encountered error", err)
+			return
+		}
+		if sha256.Sum256(b) != want {
+			t.Fatal("Fingerprint mismatch after Unmarshal", ff)
+			return
+		}
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	//The exact output must never change otherwise existing license files will fail
+	//verification. If this test fails after updating Go or a dependency, the encoder
+	//output changed.
+	f := File{
+		LicenseID:      10001,
+		CompanyName:    "ACME",
+		ContactName:    "Jane",
+		PhoneNumber:    "123",
+		Email:          "jane@example.com",
+		IssueDate:      "2024-01-01",
+		IssueTimestamp: 1704067200,
+		ExpireDate:     "2025-01-01",
+		Metadata: map[string]any{
+			"zeta":  true,
+			"alpha": 1,
+			"mid":   map[string]any{"y": "b", "x": "a"},
+		},
+	}
+
+	f.fileFormat = FileFormatJSON
+	b, err := f.Marshal()
+	if err != nil {
+		t.Fatal("Marshal encountered error", err)
+		return
+	}
+	wantJSON := `{
+  "LicenseID": 10001,
+  "CompanyName": "ACME",
+  "ContactName": "Jane",
+  "PhoneNumber": "123",
+  "Email": "jane@example.com",
+  "IssueDate": "2024-01-01",
+  "IssueTimestamp": 1704067200,
+  "ExpireDate": "2025-01-01",
+  "Metadata": {
+    "alpha": 1,
+    "mid": {
+      "x": "a",
+      "y": "b"
+    },
+    "zeta": true
+  },
+  "Signature": ""
+}`
+	if string(b) != wantJSON {
+		t.Fatalf("JSON output changed, got:\n%s", b)
+		return
+	}
+
+	f.fileFormat = FileFormatYAML
+	b, err = f.Marshal()
+	if err != nil {
+		t.Fatal("Marshal encountered error", err)
+		return
+	}
+	wantYAML := `LicenseID: 10001
+CompanyName: ACME
+ContactName: Jane
+PhoneNumber: "123"
+Email: jane@example.com
+IssueDate: "2024-01-01"
+IssueTimestamp: 1704067200
+ExpireDate: "2025-01-01"
+Metadata:
+  alpha: 1
+  mid:
+    x: a
+    "y": b
+  zeta: true
+Signature: ""
+`
+	if string(b) != wantYAML {
+		t.Fatalf("YAML output changed, got:\n%s", b)
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package licensefile
+
+import (
+	"bytes"
+	"strings"
+)
+
+// This file handles the optional header of a license key file. The header is human
+// readable text, for example legal text or support contact info, written above the
+// marshalled data. The header is not part of the signed data, so it can be changed
+// without invalidating the license.
+//
+// Each line of the header is prefixed with HeaderCommentPrefix. For YAML files this is
+// a native comment so the header is ignored by any YAML parser. JSON does not support
+// comments so the header lines must be removed before the data is parsed, which
+// Unmarshal() and Read() do. The header is made up of all the lines at the top of a
+// license key file that start with HeaderCommentPrefix (blank lines are allowed), the
+// data starts at the first line that does not. Neither YAML nor JSON marshalled data
+// ever starts with HeaderCommentPrefix so the header and data are always separated
+// reliably.
+
+// HeaderCommentPrefix is the delimiter each line of a license key file's header starts
+// with.
+const HeaderCommentPrefix = "#"
+
+// SetHeader sets the text written above the marshalled data when a File is written.
+// Line endings are normalized to \n and trailing whitespace is removed. The header is
+// not signed.
+func (f *File) SetHeader(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	f.header = strings.TrimRight(text, " \t\n")
+}
+
+// Header returns the header text of a File. When a File was read from a license key
+// file, this is the header with HeaderCommentPrefix removed from each line.
+func (f *File) Header() string {
+	return f.header
+}
+
+// encodeHeader returns the header text with each line prefixed as a comment, ending in
+// a newline, ready to be written above the marshalled data.
+func encodeHeader(text string) string {
+	lines := strings.Split(text, "\n")
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(HeaderCommentPrefix)
+		if l != "" {
+			b.WriteString(" ")
+			b.WriteString(strings.TrimRight(l, " \t"))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// splitHeader separates the header from the marshalled data of a license key file.
+// The returned header has the HeaderCommentPrefix, and following space, removed from
+// each line. If the data does not have a header, the header is blank and data is
+// returned as is.
+func splitHeader(in []byte) (header string, data []byte) {
+	var lines []string
+	data = in
+	for len(data) > 0 {
+		//Get the next line.
+		line := data
+		next := []byte{}
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i]
+			next = data[i+1:]
+		}
+		trimmed := strings.TrimSpace(string(line))
+
+		//Stop at the first line that isn't part of the header.
+		if trimmed != "" && !strings.HasPrefix(trimmed, HeaderCommentPrefix) {
+			break
+		}
+
+		if trimmed != "" {
+			l := strings.TrimPrefix(trimmed, HeaderCommentPrefix)
+			l = strings.TrimPrefix(l, " ")
+			lines = append(lines, l)
+		}
+		data = next
+	}
+
+	header = strings.Join(lines, "\n")
+	return
+}
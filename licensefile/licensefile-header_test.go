@@ -0,0 +1,115 @@
+package licensefile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	//generate key pair
+	private, public, err := GenerateKeyPairED25519()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, ff := range fileFormats {
+		//build fake File with a header
+		f := File{
+			CompanyName: "CompanyName",
+			PhoneNumber: "123-123-1234",
+			Email:       "test@example.com",
+			fileFormat:  ff,
+			Metadata: map[string]any{
+				"exists": true,
+			},
+		}
+		f.SetHeader("This license is subject to the terms of use.\r\n\r\nSupport: support@example.com\n")
+
+		err = f.SignED25519(private)
+		if err != nil {
+			t.Fatal("Error with signing", err)
+			return
+		}
+
+		//write
+		b := bytes.Buffer{}
+		err = f.Write(&b)
+		if err != nil {
+			t.Fatal("Error with writing", err)
+			return
+		}
+
+		written := b.String()
+		if !strings.HasPrefix(written, "# This license is subject to the terms of use.\n#\n# Support: support@example.com\n") {
+			t.Fatal("Header not written as expected", written)
+			return
+		}
+
+		//read back and verify, header should be removed before unmarshalling
+		out, err := Unmarshal(b.Bytes(), ff)
+		if err != nil {
+			t.Fatal("Unmarshal encountered error", ff, err)
+			return
+		}
+		if out.Header() != f.Header() {
+			t.Fatalf("Header mismatch, got %q, expected %q", out.Header(), f.Header())
+			return
+		}
+
+		err = out.VerifySignatureED25519(public)
+		if err != nil {
+			t.Fatal("Signature should be valid", ff, err)
+			return
+		}
+
+		//Changing the header should not invalidate the signature.
+		edited := strings.Replace(written, "support@example.com", "help@example.com", 1)
+		out, err = Unmarshal([]byte(edited), ff)
+		if err != nil {
+			t.Fatal("Unmarshal encountered error", ff, err)
+			return
+		}
+		err = out.VerifySignatureED25519(public)
+		if err != nil {
+			t.Fatal("Signature should be valid after editing header", ff, err)
+			return
+		}
+
+		//Changing the data should invalidate the signature.
+		edited = strings.Replace(written, "CompanyName\"", "CompanyNam\"", 1)
+		edited = strings.Replace(edited, "CompanyName: CompanyName", "CompanyName: CompanyNam", 1)
+		out, err = Unmarshal([]byte(edited), ff)
+		if err != nil {
+			t.Fatal("Unmarshal encountered error", ff, err)
+			return
+		}
+		err = out.VerifySignatureED25519(public)
+		if err != ErrBadSignature {
+			t.Fatal("Signature should be invalid after editing data", ff, err)
+			return
+		}
+	}
+}
+
+func TestSplitHeader(t *testing.T) {
+	//No header.
+	in := []byte("{\n  \"CompanyName\": \"test\"\n}")
+	header, data := splitHeader(in)
+	if header != "" || !bytes.Equal(data, in) {
+		t.Fatal("Data without header should be returned as is.")
+		return
+	}
+
+	//Header with blank lines before data.
+	header, data = splitHeader([]byte("#line 1\r\n# line 2\n\nCompanyName: test\n# not a header\n"))
+	if header != "line 1\nline 2" {
+		t.Fatalf("Header not parsed correctly, got %q", header)
+		return
+	}
+	if string(data) != "CompanyName: test\n# not a header\n" {
+		t.Fatalf("Data not separated correctly, got %q", data)
+		return
+	}
+}
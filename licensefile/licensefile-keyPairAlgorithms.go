@@ -18,6 +18,10 @@ const (
 	KeyPairAlgoED25519   = KeyPairAlgoType("ED25519")
 )
 
+// DefaultKeyPairAlgo is the key pair algorithm assumed for a File that does not have
+// an Algorithm set, see File.SignatureAlgorithm().
+const DefaultKeyPairAlgo = KeyPairAlgoED25519
+
 var keyPairAlgoTypes = []KeyPairAlgoType{
 	KeyPairAlgoECDSAP256,
 	KeyPairAlgoECDSAP384,
@@ -47,3 +51,53 @@ func (k KeyPairAlgoType) Valid() error {
 
 	return fmt.Errorf("invalid key pair algorithm, should be one of '%s', got '%s'", keyPairAlgoTypes, k)
 }
+
+// KeyPairAlgoTypes returns the supported key pair algorithms.
+func KeyPairAlgoTypes() []KeyPairAlgoType {
+	return slices.Clone(keyPairAlgoTypes)
+}
+
+// keyPairAlgoStrengths is the approximate security strength, in bits, of each key
+// pair algorithm per NIST SP 800-57 Part 1. RSA 4096-bit falls between the listed
+// strengths for 3072-bit (128) and 7680-bit (192) keys.
+var keyPairAlgoStrengths = map[KeyPairAlgoType]int{
+	KeyPairAlgoRSA2048:   112,
+	KeyPairAlgoECDSAP256: 128,
+	KeyPairAlgoED25519:   128,
+	KeyPairAlgoRSA4096:   140,
+	KeyPairAlgoECDSAP384: 192,
+	KeyPairAlgoECDSAP521: 256,
+}
+
+// Strength returns the approximate security strength, in bits, of a key pair
+// algorithm. This is used to compare algorithms, a larger value is stronger. 0 is
+// returned for an invalid algorithm.
+func (k KeyPairAlgoType) Strength() int {
+	return keyPairAlgoStrengths[k]
+}
+
+// keyPairAlgoHashes is the name of the hash algorithm used to hash a File's data before
+// signing with each key pair algorithm. This must match hashBytes().
+var keyPairAlgoHashes = map[KeyPairAlgoType]string{
+	KeyPairAlgoECDSAP256: "SHA-256",
+	KeyPairAlgoECDSAP384: "SHA-384",
+	KeyPairAlgoECDSAP521: "SHA-512",
+	KeyPairAlgoRSA2048:   "SHA-1",
+	KeyPairAlgoRSA4096:   "SHA-1",
+	KeyPairAlgoED25519:   "SHA-512",
+}
+
+// HashAlgorithm returns the name of the hash algorithm used to hash a File's data
+// before signing with a key pair algorithm. This is used so that a third party can
+// reproduce the hash with their own tooling. A blank string is returned for an
+// invalid algorithm.
+func (k KeyPairAlgoType) HashAlgorithm() string {
+	return keyPairAlgoHashes[k]
+}
+
+// AtLeast returns true if a key pair algorithm is as strong as, or stronger than, the
+// provided minimum algorithm. Algorithms with the same strength, such as ED25519 and
+// ECDSA (P256), are considered equal.
+func (k KeyPairAlgoType) AtLeast(minimum KeyPairAlgoType) bool {
+	return k.Strength() >= minimum.Strength()
+}
@@ -18,3 +18,43 @@ func TestValidKeyPairAlgos(t *testing.T) {
 		return
 	}
 }
+
+func TestKeyPairAlgoStrength(t *testing.T) {
+	//Every supported algorithm must have a strength.
+	for _, k := range keyPairAlgoTypes {
+		if k.Strength() < 1 {
+			t.Fatal("Strength not defined for algorithm", k)
+			return
+		}
+	}
+
+	for _, k := range keyPairAlgoTypes {
+		if k.HashAlgorithm() == "" {
+			t.Fatal("HashAlgorithm not defined for algorithm", k)
+			return
+		}
+	}
+
+	if KeyPairAlgoType("MD5").Strength() != 0 {
+		t.Fatal("Strength should be 0 for an invalid algorithm")
+		return
+	}
+
+	//Compare algorithms.
+	if !KeyPairAlgoECDSAP521.AtLeast(KeyPairAlgoED25519) {
+		t.Fatal("ECDSA (P521) should be at least as strong as ED25519")
+		return
+	}
+	if KeyPairAlgoRSA2048.AtLeast(KeyPairAlgoED25519) {
+		t.Fatal("RSA (2048-bit) should be weaker than ED25519")
+		return
+	}
+	if !KeyPairAlgoECDSAP256.AtLeast(KeyPairAlgoED25519) || !KeyPairAlgoED25519.AtLeast(KeyPairAlgoECDSAP256) {
+		t.Fatal("ECDSA (P256) and ED25519 should be equal")
+		return
+	}
+	if KeyPairAlgoType("MD5").AtLeast(KeyPairAlgoRSA2048) {
+		t.Fatal("An invalid algorithm should never meet a minimum")
+		return
+	}
+}
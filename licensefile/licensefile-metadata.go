@@ -81,3 +81,11 @@ func (f *File) MetadataAsBool(name string) (b bool, err error) {
 
 	return
 }
+
+// SchemaVersion returns the version of the app's license data schema the File was
+// created with. Use this to determine how to read the Metadata fields when the
+// meaning of a field has changed between versions. 0 is returned if the app's schema
+// was not versioned when the File was created.
+func (f *File) SchemaVersion() int {
+	return f.LicenseSchemaVersion
+}
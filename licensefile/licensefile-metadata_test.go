@@ -1,6 +1,9 @@
 package licensefile
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestMetadataAsInt(t *testing.T) {
 	//build fake File with file format, hash type, and encoding type set
@@ -189,3 +192,77 @@ func TestMetadataAsBool(t *testing.T) {
 		return
 	}
 }
+
+func TestSchemaVersion(t *testing.T) {
+	for _, format := range []FileFormat{FileFormatJSON, FileFormatYAML} {
+		//build fake File with file format set
+		f := File{
+			CompanyName: "CompanyName",
+			ExpireDate:  "2006-01-02",
+			fileFormat:  format,
+		}
+
+		//unversioned schema should be omitted so previously signed data is unchanged
+		b, err := f.Marshal()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if bytes.Contains(b, []byte("SchemaVersion")) {
+			t.Fatal("SchemaVersion should be omitted when 0", string(b))
+			return
+		}
+		if f.SchemaVersion() != 0 {
+			t.Fatal("Expected 0, got", f.SchemaVersion())
+			return
+		}
+
+		//versioned schema should be signed and read back
+		f.LicenseSchemaVersion = 2
+		priv, pub, err := GenerateKeyPair(KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		err = f.Sign(priv, KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		var out bytes.Buffer
+		err = f.Write(&out)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if !bytes.Contains(out.Bytes(), []byte("SchemaVersion")) {
+			t.Fatal("SchemaVersion should be written", out.String())
+			return
+		}
+
+		read, err := Unmarshal(out.Bytes(), format)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if read.SchemaVersion() != 2 {
+			t.Fatal("Expected 2, got", read.SchemaVersion())
+			return
+		}
+
+		err = read.VerifySignature(pub, KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal("File should verify", err)
+			return
+		}
+
+		//changing the schema version should invalidate the signature
+		read.LicenseSchemaVersion = 1
+		err = read.VerifySignature(pub, KeyPairAlgoED25519)
+		if err == nil {
+			t.Fatal("File should not verify after SchemaVersion was changed")
+			return
+		}
+	}
+}
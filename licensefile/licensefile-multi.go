@@ -0,0 +1,126 @@
+package licensefile
+
+// This file handles co-signing a license key file with more than one private key.
+// Some licenses, typically high-value licenses, require signatures from multiple
+// independent key pairs so that a single leaked private key cannot be used to create
+// a valid license.
+//
+// Each signature is generated from the same hash, the File's data without any
+// signatures, so the signatures are independent of each other and the order the
+// private keys are provided in.
+
+// SigningKey is a private key, and its algorithm, used to sign a File.
+type SigningKey struct {
+	PrivateKey  []byte
+	KeyPairAlgo KeyPairAlgoType
+}
+
+// VerifyingKey is a public key, and its algorithm, used to verify a File's signature.
+type VerifyingKey struct {
+	PublicKey   []byte
+	KeyPairAlgo KeyPairAlgoType
+}
+
+// SignMulti creates a signature for a license file with each of the private keys. The
+// signatures are set in the File's Signatures field, in the same order as the keys,
+// and the first signature is also set in the File's Signature field. The private keys
+// must be decrypted, if needed, prior to being provided.
+//
+// If only one key is provided, this is the same as calling Sign() and Signatures is
+// left empty.
+//
+// The File's Algorithm is set to the first key's algorithm since the first signature
+// is the one set in Signature.
+func (f *File) SignMulti(keys ...SigningKey) (err error) {
+	if len(keys) == 0 {
+		return ErrNoPrivateKeys
+	}
+
+	err = keys[0].KeyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+	f.Algorithm = keys[0].KeyPairAlgo
+	f.FormatVersion = CurrentFormatVersion
+
+	sigs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		//Sign a copy so that each signature is generated from the same data and
+		//the File isn't modified if signing with a later key fails.
+		c := *f
+		err = c.sign(k.PrivateKey, k.KeyPairAlgo)
+		if err != nil {
+			return
+		}
+
+		sigs = append(sigs, c.Signature)
+	}
+
+	f.Signature = sigs[0]
+	f.Signatures = nil
+	if len(sigs) > 1 {
+		f.Signatures = sigs
+	}
+
+	return
+}
+
+// VerifyMulti checks that at least required of the File's signatures are valid, each
+// verified by a different one of the publicKeys. The number of valid signatures is
+// returned. A required value less than 1 is treated as 1.
+//
+// Files signed with one private key, that only have a Signature, can be verified
+// with this func as well, however only one signature can ever be valid.
+//
+// If fewer than required signatures are valid, ErrNotEnoughSignatures is returned.
+//
+// This DOES NOT check if a File is expired. You should call Expired() on the File
+// after calling this func.
+func (f *File) VerifyMulti(publicKeys []VerifyingKey, required int) (valid int, err error) {
+	if len(publicKeys) == 0 {
+		return 0, ErrNoPublicKeys
+	}
+	if required < 1 {
+		required = 1
+	}
+
+	sigs := f.Signatures
+	if len(sigs) == 0 {
+		sigs = []string{f.Signature}
+	}
+
+	//Each public key and each signature can only be counted once so that one key
+	//pair cannot satisfy the requirement by itself, for example by being provided
+	//twice or by signing the same data more than once.
+	usedSigs := make([]bool, len(sigs))
+	usedKeys := make(map[string]bool, len(publicKeys))
+	for _, k := range publicKeys {
+		if usedKeys[string(k.PublicKey)] {
+			continue
+		}
+		usedKeys[string(k.PublicKey)] = true
+
+		for i, s := range sigs {
+			if usedSigs[i] {
+				continue
+			}
+
+			c := *f
+			c.Signature = s
+			c.Signatures = nil
+			if c.VerifySignature(k.PublicKey, k.KeyPairAlgo) != nil {
+				continue
+			}
+
+			usedSigs[i] = true
+			valid++
+			break
+		}
+	}
+
+	if valid < required {
+		return valid, ErrNotEnoughSignatures
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,266 @@
+package licensefile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignMulti(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		ExpireDate:  "2006-01-02",
+		fileFormat:  FileFormatJSON,
+	}
+
+	//No keys.
+	err := f.SignMulti()
+	if err != ErrNoPrivateKeys {
+		t.Fatal("ErrNoPrivateKeys should have been returned", err)
+		return
+	}
+
+	//One key is the same as Sign().
+	priv1, _, err := GenerateKeyPair(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.SignMulti(SigningKey{priv1, KeyPairAlgoED25519})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if f.Signature == "" {
+		t.Fatal("Signature should be set")
+		return
+	}
+	if len(f.Signatures) != 0 {
+		t.Fatal("Signatures should not be set with one key", f.Signatures)
+		return
+	}
+
+	//Multiple keys, of different algorithms.
+	priv2, _, err := GenerateKeyPair(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.SignMulti(SigningKey{priv1, KeyPairAlgoED25519}, SigningKey{priv2, KeyPairAlgoECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(f.Signatures) != 2 {
+		t.Fatal("Signatures should have one signature per key", f.Signatures)
+		return
+	}
+	if f.Signature != f.Signatures[0] {
+		t.Fatal("Signature should be the first signature")
+		return
+	}
+
+	//Bad key.
+	err = f.SignMulti(SigningKey{priv1, KeyPairAlgoED25519}, SigningKey{[]byte("bad"), KeyPairAlgoType("bad")})
+	if err == nil {
+		t.Fatal("error should be returned for bad key")
+		return
+	}
+}
+
+func TestVerifyMulti(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		ExpireDate:  "2006-01-02",
+		fileFormat:  FileFormatJSON,
+	}
+
+	priv1, pub1, err := GenerateKeyPair(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	priv2, pub2, err := GenerateKeyPair(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	_, pub3, err := GenerateKeyPair(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.SignMulti(SigningKey{priv1, KeyPairAlgoED25519}, SigningKey{priv2, KeyPairAlgoECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	k1 := VerifyingKey{pub1, KeyPairAlgoED25519}
+	k2 := VerifyingKey{pub2, KeyPairAlgoECDSAP256}
+	k3 := VerifyingKey{pub3, KeyPairAlgoED25519}
+
+	//No keys.
+	_, err = f.VerifyMulti(nil, 1)
+	if err != ErrNoPublicKeys {
+		t.Fatal("ErrNoPublicKeys should have been returned", err)
+		return
+	}
+
+	//Both signatures, keys in any order.
+	valid, err := f.VerifyMulti([]VerifyingKey{k2, k3, k1}, 2)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if valid != 2 {
+		t.Fatal("wrong number of valid signatures", valid)
+		return
+	}
+
+	//Not enough signatures.
+	valid, err = f.VerifyMulti([]VerifyingKey{k1, k3}, 2)
+	if err != ErrNotEnoughSignatures {
+		t.Fatal("ErrNotEnoughSignatures should have been returned", err)
+		return
+	}
+	if valid != 1 {
+		t.Fatal("wrong number of valid signatures", valid)
+		return
+	}
+
+	//Same key provided twice only counts once.
+	_, err = f.VerifyMulti([]VerifyingKey{k1, k1}, 2)
+	if err != ErrNotEnoughSignatures {
+		t.Fatal("duplicate key should only be counted once", err)
+		return
+	}
+
+	//First signature can be verified by itself.
+	err = f.VerifySignature(pub1, KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal("first signature should verify with VerifySignature", err)
+		return
+	}
+
+	//Modified data.
+	c := f
+	c.CompanyName = "Modified"
+	_, err = c.VerifyMulti([]VerifyingKey{k1, k2}, 1)
+	if err != ErrNotEnoughSignatures {
+		t.Fatal("modified file should not verify", err)
+		return
+	}
+}
+
+func TestVerifyMultiSingleSignature(t *testing.T) {
+	//Files signed with one key, i.e.: before co-signing was supported, should still
+	//verify.
+	f := File{
+		CompanyName: "CompanyName",
+		ExpireDate:  "2006-01-02",
+		fileFormat:  FileFormatYAML,
+	}
+
+	priv, pub, err := GenerateKeyPair(KeyPairAlgoRSA2048)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.Sign(priv, KeyPairAlgoRSA2048)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	valid, err := f.VerifyMulti([]VerifyingKey{{pub, KeyPairAlgoRSA2048}}, 1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if valid != 1 {
+		t.Fatal("wrong number of valid signatures", valid)
+		return
+	}
+
+	_, err = f.VerifyMulti([]VerifyingKey{{pub, KeyPairAlgoRSA2048}}, 2)
+	if err != ErrNotEnoughSignatures {
+		t.Fatal("ErrNotEnoughSignatures should have been returned", err)
+		return
+	}
+}
+
+func TestWriteReadMulti(t *testing.T) {
+	for _, format := range fileFormats {
+		f := File{
+			CompanyName: "CompanyName",
+			ExpireDate:  "2006-01-02",
+			fileFormat:  format,
+		}
+
+		priv1, pub1, err := GenerateKeyPair(KeyPairAlgoED25519)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		priv2, pub2, err := GenerateKeyPair(KeyPairAlgoECDSAP384)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		err = f.SignMulti(SigningKey{priv1, KeyPairAlgoED25519}, SigningKey{priv2, KeyPairAlgoECDSAP384})
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		b := bytes.Buffer{}
+		err = f.Write(&b)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		reread, err := Unmarshal(b.Bytes(), format)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		_, err = reread.VerifyMulti([]VerifyingKey{{pub1, KeyPairAlgoED25519}, {pub2, KeyPairAlgoECDSAP384}}, 2)
+		if err != nil {
+			t.Fatal("could not verify reread file", format, err)
+			return
+		}
+	}
+}
+
+func TestHashExcludesSignatures(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		fileFormat:  FileFormatJSON,
+	}
+
+	h1, err := f.hash(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	f.Signature = "sig1"
+	f.Signatures = []string{"sig1", "sig2"}
+	h2, err := f.hash(KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if !bytes.Equal(h1, h2) {
+		t.Fatal("hash should not include signatures")
+		return
+	}
+}
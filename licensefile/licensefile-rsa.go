@@ -73,15 +73,7 @@ func (f *File) SignRSA(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err erro
 	}
 
 	//Sign the hash.
-	//Decode the private key.
-	pemBlock, _ := pem.Decode(privateKey)
-	x509Key, err := x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
-	if err != nil {
-		return
-	}
-
-	//Generate signature.
-	sig, err := rsa.SignPSS(rand.Reader, x509Key, crypto.SHA1, h[:], nil)
+	sig, err := signHashRSA(privateKey, h)
 	if err != nil {
 		return
 	}
@@ -92,6 +84,19 @@ func (f *File) SignRSA(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err erro
 	return
 }
 
+// signHashRSA signs the hash h using the RSA private key.
+func signHashRSA(privateKey, h []byte) (sig []byte, err error) {
+	//Decode the private key.
+	pemBlock, _ := pem.Decode(privateKey)
+	x509Key, err := x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
+	if err != nil {
+		return
+	}
+
+	//Generate signature.
+	return rsa.SignPSS(rand.Reader, x509Key, crypto.SHA1, h[:], nil)
+}
+
 // VerifySignatureRSA checks if the File's signature is valid by checking it against
 // the RSA public key.
 //
@@ -121,6 +126,21 @@ func (f File) VerifySignatureRSA(publicKey []byte, keyPairAlgo KeyPairAlgoType)
 		return
 	}
 
+	return verifyHashRSA(publicKey, h, decodedSig)
+}
+
+// VerifyRSA calls VerifySignatureRSA().
+//
+// Deprecated: This func is here just for legacy situations since the old
+// VerifyRSA() func was renamed to VerifySignatureRSA() for better clarity.
+// Use VerifySignatureRSA() instead.
+func (f *File) VerifyRSA(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	return f.VerifySignatureRSA(publicKey, keyPairAlgo)
+}
+
+// verifyHashRSA checks if sig is a valid signature of the hash h using the RSA
+// public key.
+func verifyHashRSA(publicKey, h, sig []byte) (err error) {
 	//Decode the public key.
 	pemBlock, _ := pem.Decode(publicKey)
 	x509Key, err := x509.ParsePKCS1PublicKey(pemBlock.Bytes)
@@ -131,19 +151,10 @@ func (f File) VerifySignatureRSA(publicKey []byte, keyPairAlgo KeyPairAlgoType)
 	//Verify signature.
 	//We translate the ErrVerification to ErrBadSignature so that we can return the
 	//same err as in the VerifyECDSA func.
-	err = rsa.VerifyPSS(x509Key, crypto.SHA1, h[:], decodedSig, nil)
+	err = rsa.VerifyPSS(x509Key, crypto.SHA1, h[:], sig, nil)
 	if err == rsa.ErrVerification {
 		err = ErrBadSignature
 	}
 
 	return
 }
-
-// VerifyRSA calls VerifySignatureRSA().
-//
-// Deprecated: This func is here just for legacy situations since the old
-// VerifyRSA() func was renamed to VerifySignatureRSA() for better clarity.
-// Use VerifySignatureRSA() instead.
-func (f *File) VerifyRSA(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
-	return f.VerifySignatureRSA(publicKey, keyPairAlgo)
-}
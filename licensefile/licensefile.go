@@ -27,8 +27,41 @@ var (
 	//should really never be returned since the only time these funcs are used are
 	//with an existing license's data.
 	ErrMissingExpireDate = errors.New("missing expire date")
+
+	// ErrNoPublicKeys is returned from VerifyAny() or VerifyMulti() when no public
+	// keys are provided.
+	ErrNoPublicKeys = errors.New("no public keys provided")
+
+	// ErrNoPrivateKeys is returned from SignMulti() when no private keys are provided.
+	ErrNoPrivateKeys = errors.New("no private keys provided")
+
+	// ErrNotEnoughSignatures is returned from VerifyMulti() when fewer than the
+	// required number of signatures are valid.
+	ErrNotEnoughSignatures = errors.New("not enough valid signatures")
+
+	// ErrNotSigned is returned from SignatureOnly() when a File does not have a
+	// Signature.
+	ErrNotSigned = errors.New("file is not signed")
+
+	// ErrUnsupportedFormatVersion is returned from VerifySignature() when a File's
+	// FormatVersion is newer than this package supports. The app verifying the File
+	// must be updated to a version of this package that supports the FormatVersion.
+	ErrUnsupportedFormatVersion = errors.New("unsupported license file format version")
 )
 
+// CurrentFormatVersion is the version of the signing scheme used by Sign(). This is
+// set in a File's FormatVersion field when the File is signed.
+//
+// The version is only incremented when a change is made that would prevent an older
+// version of this package from verifying a File, for example a change to how the
+// data is hashed or how the signature is encoded. Adding a new optional field that is
+// omitted when empty does not require incrementing the version. Files with a
+// FormatVersion newer than CurrentFormatVersion are rejected by VerifySignature().
+//
+// Files signed before the FormatVersion field existed do not have a FormatVersion
+// and are treated as version 1.
+const CurrentFormatVersion = 1
+
 // File defines the format of data stored in a license key file. This is the body of
 // the text file.
 //
@@ -40,11 +73,24 @@ var (
 // with common fields and store some non-marshalled license data. More simply, having
 // a struct is just nicer for interacting with.
 type File struct {
+	//Algorithm is the key pair algorithm the File's Signature was created with and
+	//FormatVersion is the version of the signing scheme, see CurrentFormatVersion.
+	//These are set by Sign() and are part of the signed data so they cannot be
+	//modified. VerifySignature() uses Algorithm to choose how to verify the File if
+	//an algorithm isn't provided.
+	//
+	//These are omitted when empty so that the data signed for Files created before
+	//these fields existed is unchanged. A File without an Algorithm is treated as
+	//using DefaultKeyPairAlgo.
+	Algorithm     KeyPairAlgoType `json:"Algorithm,omitempty" yaml:"Algorithm,omitempty"`
+	FormatVersion int             `json:"FormatVersion,omitempty" yaml:"FormatVersion,omitempty"`
+
 	//Optionally displayed fields per app. These are at the top of the struct
 	//definition so that they will be displayed at the top of the marshalled data just
 	//for ease of human reading of the license key file.
-	LicenseID int64  `json:"LicenseID,omitempty" yaml:"LicenseID,omitempty"`
-	AppName   string `json:"AppName,omitempty" yaml:"AppName,omitempty"`
+	LicenseID  int64  `json:"LicenseID,omitempty" yaml:"LicenseID,omitempty"`
+	FriendlyID string `json:"FriendlyID,omitempty" yaml:"FriendlyID,omitempty"` //human friendly license identifier, ex.: ACME-10001.
+	AppName    string `json:"AppName,omitempty" yaml:"AppName,omitempty"`
 
 	//This data copied from db-license.go and always included in each license key file.
 	CompanyName    string `yaml:"CompanyName"`
@@ -55,6 +101,31 @@ type File struct {
 	IssueTimestamp int64  `yaml:"IssueTimestamp"` //unix timestamp in seconds
 	ExpireDate     string `yaml:"ExpireDate"`     //YYYY-MM-DD, in UTC timezone for easiest comparison in DaysUntilExpired()
 
+	//ExpireDatetime is an optional, more precise, expiration. This is used when a
+	//license needs to expire at a specific time of day, for example with short trial
+	//licenses. When provided, this takes precedence over ExpireDate.
+	//
+	//This is omitted when empty so that the data signed for licenses without this
+	//field set is unchanged.
+	ExpireDatetime string `json:"ExpireDatetime,omitempty" yaml:"ExpireDatetime,omitempty"` //RFC3339, in UTC timezone.
+
+	//ValidFrom is an optional date before which a license is not valid yet. This is
+	//used when a license is sold in advance of when it can be used. See NotYetValid().
+	//
+	//This is omitted when empty so that the data signed for licenses without this
+	//field set is unchanged.
+	ValidFrom string `json:"ValidFrom,omitempty" yaml:"ValidFrom,omitempty"` //YYYY-MM-DD, in UTC timezone.
+
+	//LicenseSchemaVersion is the version of the app's license data schema, meaning
+	//what each Metadata field means, when the File was created. The version is
+	//incremented when an app's custom fields change in a way that is not backwards
+	//compatible so that your app can read Metadata accordingly. See SchemaVersion().
+	//This is written as SchemaVersion in the license key file.
+	//
+	//This is omitted when 0 so that the data signed for Files created before an app's
+	//schema was versioned is unchanged.
+	LicenseSchemaVersion int `json:"SchemaVersion,omitempty" yaml:"SchemaVersion,omitempty"`
+
 	//Metadata is any optional data that you want to store in a license file. This
 	//field can store anything, and is typically used for storing information that
 	//enables certain functionality within your app. For example, a maximum user
@@ -64,17 +135,35 @@ type File struct {
 	//"Extras" when interfacing with a license File. "Extras" just sounded ugly.
 	Metadata map[string]any `json:"Metadata,omitempty" yaml:"Metadata,omitempty"`
 
+	//Features is the list of keys of the features enabled for this license. Features
+	//are defined per app and are a simpler alternative to using boolean Metadata
+	//fields for enabling functionality in your app. See HasFeature().
+	//
+	//This is omitted when empty so that the data signed for licenses without any
+	//features is unchanged.
+	Features []string `json:"Features,omitempty" yaml:"Features,omitempty"`
+
 	//Signature is the result of signing the hash of File (all of the above fields)
 	//using the private key. The result is stored here and File is output to a text
 	//file known as the complete license key file. This file is distributed to and
 	//imported into your app by the end-user to allow the app's use.
 	Signature string `yaml:"Signature"`
 
+	//Signatures is the list of signatures when a File is co-signed by more than one
+	//private key, see SignMulti(). The first signature is also set in Signature so
+	//that apps that only check one signature, using VerifySignature(), can still
+	//verify the File with the first key pair's public key.
+	//
+	//This is omitted when empty so that the data of Files signed by one private key
+	//is unchanged.
+	Signatures []string `json:"Signatures,omitempty" yaml:"Signatures,omitempty"`
+
 	//Stuff used for signing or verifying a license file. These are never included in
 	//the license key file that is distributed.
 	//
 	//During verification, these fields are populated just for debugging.
 	fileFormat   FileFormat      //the format a file was unmarshaled from.
+	header       string          //human readable text written as comment lines above the marshalled data, not signed.
 	readFromPath string          //path a license file was read from.
 	publicKey    []byte          //the public key used to verify a license.
 	keyPairAlgo  KeyPairAlgoType //algorithm type of the public key.
@@ -107,12 +196,30 @@ func GenerateKeyPair(k KeyPairAlgoType) (private, public []byte, err error) {
 // Sign creates a signature for a license file. The signature is set in the provided
 // File's Signature field. The private key must be decrypted, if needed, prior to
 // being provided. The signature will be encoded per the File's EncodingType.
+//
+// The File's Algorithm and FormatVersion are set prior to signing so that they are
+// part of the signed data.
 func (f *File) Sign(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
 	err = keyPairAlgo.Valid()
 	if err != nil {
 		return
 	}
 
+	f.Algorithm = keyPairAlgo
+	f.FormatVersion = CurrentFormatVersion
+
+	return f.sign(privateKey, keyPairAlgo)
+}
+
+// sign creates a signature for a license file without setting the File's Algorithm
+// and FormatVersion. This is used by Sign(), and SignMulti() so that each signature
+// is generated from the same data.
+func (f *File) sign(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	err = keyPairAlgo.Valid()
+	if err != nil {
+		return
+	}
+
 	switch keyPairAlgo {
 	case KeyPairAlgoECDSAP256, KeyPairAlgoECDSAP384, KeyPairAlgoECDSAP521:
 		err = f.SignECDSA(privateKey, keyPairAlgo)
@@ -131,10 +238,11 @@ func (f *File) Sign(privateKey []byte, keyPairAlgo KeyPairAlgoType) (err error)
 // the license key file since we compare the hash against the signature with a public
 // key.
 func (f *File) hash(keyPairAlgo KeyPairAlgoType) (hash []byte, err error) {
-	//Make sure the Signature field is blank prior hashing since if the Signature
-	//field is present, it will add a source of randomness and will be replaced
-	//anyway by the signature generated within this func.
+	//Make sure the Signature and Signatures fields are blank prior hashing since if
+	//either field is present, it will add a source of randomness and will be
+	//replaced anyway by the signature generated within this func.
 	f.Signature = ""
+	f.Signatures = nil
 
 	//Encode the struct as bytes per the File's FileFormat. We reuse the FileFormat
 	//here since if a third-party app is validating a license file, it already has
@@ -151,6 +259,12 @@ func (f *File) hash(keyPairAlgo KeyPairAlgoType) (hash []byte, err error) {
 		return
 	}
 
+	return hashBytes(b, keyPairAlgo)
+}
+
+// hashBytes generates a checksum of b per the key pair algorithm that will be used
+// to sign, or verify, the hash.
+func hashBytes(b []byte, keyPairAlgo KeyPairAlgoType) (hash []byte, err error) {
 	//Calculate the hash. The hash algorithm is determined by the key pair algorithm.
 	err = keyPairAlgo.Valid()
 	if err != nil {
@@ -185,6 +299,56 @@ func (f *File) hash(keyPairAlgo KeyPairAlgoType) (hash []byte, err error) {
 	return
 }
 
+// signBytes signs b, as is, with the private key and returns the signature encoded
+// the same as a File's Signature. This is used for signing data that isn't a File.
+func signBytes(b, privateKey []byte, keyPairAlgo KeyPairAlgoType) (sig string, err error) {
+	h, err := hashBytes(b, keyPairAlgo)
+	if err != nil {
+		return
+	}
+
+	var s []byte
+	switch keyPairAlgo {
+	case KeyPairAlgoECDSAP256, KeyPairAlgoECDSAP384, KeyPairAlgoECDSAP521:
+		s, err = signHashECDSA(privateKey, h)
+	case KeyPairAlgoRSA2048, KeyPairAlgoRSA4096:
+		s, err = signHashRSA(privateKey, h)
+	case KeyPairAlgoED25519:
+		s, err = signHashED25519(privateKey, h)
+	}
+	if err != nil {
+		return
+	}
+
+	sig = base64.StdEncoding.EncodeToString(s)
+	return
+}
+
+// verifyBytes checks if sig, encoded the same as a File's Signature, is a valid
+// signature of b, as is, by checking it against the public key.
+func verifyBytes(b []byte, sig string, publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	decodedSig, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return
+	}
+
+	h, err := hashBytes(b, keyPairAlgo)
+	if err != nil {
+		return
+	}
+
+	switch keyPairAlgo {
+	case KeyPairAlgoECDSAP256, KeyPairAlgoECDSAP384, KeyPairAlgoECDSAP521:
+		err = verifyHashECDSA(publicKey, h, decodedSig)
+	case KeyPairAlgoRSA2048, KeyPairAlgoRSA4096:
+		err = verifyHashRSA(publicKey, h, decodedSig)
+	case KeyPairAlgoED25519:
+		err = verifyHashED25519(publicKey, h, decodedSig)
+	}
+
+	return
+}
+
 // encodeSignature returns the generated signature encoded as a string. The returned
 // value is the signature that will be set in the File's Signature field.
 //
@@ -194,6 +358,8 @@ func (f *File) encodeSignature(b []byte) {
 }
 
 // Write writes a File to out. This is used to output the complete license key file.
+// If a header is set, it is written as comment lines above the marshalled data. The
+// header is not part of the signed data.
 // This can be used to write the File to a buffer, as is done when creating a license
 // key file, write the File back to the browser as html, or write the File to an actual
 // filesystem file.
@@ -216,6 +382,14 @@ func (f *File) Write(out io.Writer) (err error) {
 		return
 	}
 
+	//Write the header, if any.
+	if f.header != "" {
+		_, err = io.WriteString(out, encodeHeader(f.header))
+		if err != nil {
+			return
+		}
+	}
+
 	//Write.
 	_, err = out.Write(b)
 	return
@@ -272,6 +446,11 @@ func (f *File) decodeSignature() (b []byte, err error) {
 // VerifySignature checks if a File's signature is valid by checking it against the
 // publicKey.
 //
+// If keyPairAlgo is blank, the File's Algorithm is used to choose how to verify the
+// signature. This allows an app to verify Files signed with different algorithms
+// without knowing the algorithm ahead of time. Files without an Algorithm, those
+// signed before the field existed, are verified using DefaultKeyPairAlgo.
+//
 // This DOES NOT check if a File is expired. You should call Expired() on the File
 // after calling this func.
 //
@@ -279,6 +458,16 @@ func (f *File) decodeSignature() (b []byte, err error) {
 // so that each step can be handled more deliberately with specific handling of
 // invalid states (i.e.: for more graceful handling).
 func (f *File) VerifySignature(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error) {
+	//Make sure this package knows how to verify the File.
+	if f.FormatVersion > CurrentFormatVersion {
+		return ErrUnsupportedFormatVersion
+	}
+
+	//Use the File's algorithm if one wasn't provided.
+	if keyPairAlgo == "" {
+		keyPairAlgo = f.SignatureAlgorithm()
+	}
+
 	//Make sure a valid algo type was provided.
 	err = keyPairAlgo.Valid()
 	if err != nil {
@@ -305,6 +494,16 @@ func (f *File) VerifySignature(publicKey []byte, keyPairAlgo KeyPairAlgoType) (e
 	return
 }
 
+// SignatureAlgorithm returns the key pair algorithm the File was signed with. This
+// returns DefaultKeyPairAlgo for Files signed before the Algorithm field existed.
+func (f *File) SignatureAlgorithm() KeyPairAlgoType {
+	if f.Algorithm == "" {
+		return DefaultKeyPairAlgo
+	}
+
+	return f.Algorithm
+}
+
 // Verify calls VerifySignature().
 //
 // Deprecated: This func is here just for legacy situations since the old Verify()
@@ -314,6 +513,31 @@ func (f *File) Verify(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error)
 	return f.VerifySignature(publicKey, keyPairAlgo)
 }
 
+// VerifyAny checks if a File's signature is valid by checking it against each of the
+// publicKeys, in order, until one successfully verifies the signature. The index of
+// the public key that verified the signature is returned.
+//
+// This is useful when rotating key pairs since your app can embed both the old and
+// new public keys and verify licenses signed with either. Each public key must be of
+// the same keyPairAlgo.
+//
+// If no public key verifies the signature, -1 is returned along with the error from
+// the last public key that was checked, typically ErrBadSignature.
+func (f *File) VerifyAny(publicKeys [][]byte, keyPairAlgo KeyPairAlgoType) (index int, err error) {
+	if len(publicKeys) == 0 {
+		return -1, ErrNoPublicKeys
+	}
+
+	for i, publicKey := range publicKeys {
+		err = f.VerifySignature(publicKey, keyPairAlgo)
+		if err == nil {
+			return i, nil
+		}
+	}
+
+	return -1, err
+}
+
 // Expired returns if a lincense File's expiration date is in the past.
 //
 // You should only call this AFTER calling VerifySignature() otherwise the expiration
@@ -323,42 +547,119 @@ func (f *File) Verify(publicKey []byte, keyPairAlgo KeyPairAlgoType) (err error)
 // so that each step can be handled more deliberately with specific handling of
 // invalid states (i.e.: for more graceful handling).
 func (f *File) Expired() (yes bool, err error) {
-	//Make sure a expiration data is provided. It should always be provided since
-	//you would call this func after reading a license file and verifying it's
-	//signature.
-	if strings.TrimSpace(f.ExpireDate) == "" {
-		return false, ErrMissingExpireDate
+	//Get the expiration date.
+	expDate, err := f.expiration()
+	if err != nil {
+		return
 	}
 
 	//Check if license is expired.
-	expDate, err := time.Parse("2006-01-02", f.ExpireDate)
+	yes = expDate.Before(time.Now())
+	return
+}
+
+// ExpiredWithGrace returns if a license File's expiration date, plus the grace
+// period, is in the past. This allows your app to keep working for a short period
+// of time after a license expires, for example while a renewal is being processed.
+//
+// You should only call this AFTER calling VerifySignature() otherwise the expiration
+// date in the File is untrustworthy and could have been modified.
+func (f *File) ExpiredWithGrace(grace time.Duration) (yes bool, err error) {
+	//Get the expiration date.
+	expDate, err := f.expiration()
 	if err != nil {
 		return
 	}
 
-	yes = expDate.Before(time.Now())
+	//Check if license is expired, including the grace period.
+	yes = expDate.Add(grace).Before(time.Now())
 	return
 }
 
-// ExpiresIn calculates duration until a license File expires. The returned duration
-// will be negative for an expired license.
+// InGracePeriod returns if a license File is expired but is still within the grace
+// period. This is useful for showing a "renew soon" warning in your app instead of
+// locking the user out of your app.
 //
 // You should only call this AFTER calling VerifySignature() otherwise the expiration
 // date in the File is untrustworthy and could have been modified.
-func (f *File) ExpiresIn() (d time.Duration, err error) {
+func (f *File) InGracePeriod(grace time.Duration) (yes bool, err error) {
+	//Get the expiration date.
+	expDate, err := f.expiration()
+	if err != nil {
+		return
+	}
+
+	//Check if license is expired but not past the grace period.
+	now := time.Now()
+	yes = expDate.Before(now) && !expDate.Add(grace).Before(now)
+	return
+}
+
+// expiration returns the parsed expiration date of a license File. This is used
+// so that each func that checks expiration handles a missing or invalid expiration
+// date the same way.
+//
+// If the more precise ExpireDatetime is provided, it is used instead of ExpireDate.
+func (f *File) expiration() (expDate time.Time, err error) {
+	//Use the more precise expiration, if provided.
+	if strings.TrimSpace(f.ExpireDatetime) != "" {
+		expDate, err = time.Parse(time.RFC3339, f.ExpireDatetime)
+		return
+	}
+
 	//Make sure a expiration data is provided. It should always be provided since
 	//you would call this func after reading a license file and verifying it's
 	//signature.
 	if strings.TrimSpace(f.ExpireDate) == "" {
-		return 0, ErrMissingExpireDate
+		return expDate, ErrMissingExpireDate
 	}
 
-	//Get duration until license is expired.
-	expDate, err := time.Parse("2006-01-02", f.ExpireDate)
+	//Parse the expiration date.
+	expDate, err = time.Parse("2006-01-02", f.ExpireDate)
+	return
+}
+
+// HasFeature returns if the feature with the given key is enabled for a license File.
+//
+// You should only call this AFTER calling VerifySignature() otherwise the list of
+// features in the File is untrustworthy and could have been modified.
+func (f *File) HasFeature(key string) bool {
+	return slices.Contains(f.Features, key)
+}
+
+// NotYetValid returns if a license File's ValidFrom date is in the future, meaning
+// the license cannot be used yet. A File without a ValidFrom date is always valid.
+// You would typically call this alongside Expired().
+//
+// You should only call this AFTER calling VerifySignature() otherwise the ValidFrom
+// date in the File is untrustworthy and could have been modified.
+func (f *File) NotYetValid() (yes bool, err error) {
+	if strings.TrimSpace(f.ValidFrom) == "" {
+		return
+	}
+
+	validFrom, err := time.Parse("2006-01-02", f.ValidFrom)
 	if err != nil {
 		return
 	}
 
+	yes = time.Now().Before(validFrom)
+	return
+}
+
+// ExpiresIn calculates duration until a license File expires. The returned duration
+// will be negative for an expired license.
+//
+// You should only call this AFTER calling VerifySignature() otherwise the expiration
+// date in the File is untrustworthy and could have been modified.
+func (f *File) ExpiresIn() (d time.Duration, err error) {
+	//Get the expiration date.
+	expDate, err := f.expiration()
+	if err != nil {
+		return
+	}
+
+	//Get duration until license is expired.
 	d = time.Until(expDate)
 	return
 }
@@ -222,13 +222,160 @@ func TestVerifySignature(t *testing.T) {
 	}
 
 	//Test with bad algo.
-	err = f.VerifySignature(pub, KeyPairAlgoType(""))
+	err = f.VerifySignature(pub, KeyPairAlgoType("bad"))
 	if err == nil {
 		t.Fatal("Error about bad key pair algo should have occured.")
 		return
 	}
 }
 
+func TestVerifySignatureFileAlgorithm(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+	}
+
+	priv, pub, err := GenerateKeyPairECDSA(KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.Sign(priv, KeyPairAlgoECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if f.Algorithm != KeyPairAlgoECDSAP256 {
+		t.Fatal("Algorithm not set when signing.", f.Algorithm)
+		return
+	}
+	if f.FormatVersion != CurrentFormatVersion {
+		t.Fatal("FormatVersion not set when signing.", f.FormatVersion)
+		return
+	}
+
+	//Test without providing an algorithm, the File's Algorithm should be used.
+	err = f.VerifySignature(pub, "")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Test with a modified Algorithm, which is part of the signed data.
+	c := f
+	c.Algorithm = KeyPairAlgoECDSAP384
+	err = c.VerifySignature(pub, KeyPairAlgoECDSAP256)
+	if err != ErrBadSignature {
+		t.Fatal("ErrBadSignature should have been returned.", err)
+		return
+	}
+
+	//Test with a newer FormatVersion.
+	c = f
+	c.FormatVersion = CurrentFormatVersion + 1
+	err = c.VerifySignature(pub, "")
+	if err != ErrUnsupportedFormatVersion {
+		t.Fatal("ErrUnsupportedFormatVersion should have been returned.", err)
+		return
+	}
+}
+
+func TestVerifySignatureLegacy(t *testing.T) {
+	//Files signed before Algorithm and FormatVersion existed don't have either field
+	//set and must still verify using the default algorithm.
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatYAML,
+	}
+
+	priv, pub, err := GenerateKeyPairED25519()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.sign(priv, KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if f.Algorithm != "" || f.FormatVersion != 0 {
+		t.Fatal("Algorithm and FormatVersion should not be set.")
+		return
+	}
+
+	err = f.VerifySignature(pub, "")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if f.SignatureAlgorithm() != DefaultKeyPairAlgo {
+		t.Fatal("Default algorithm not returned.", f.SignatureAlgorithm())
+		return
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+	}
+
+	//Generate an "old" and "new" key pair, sign with the new key pair.
+	_, oldPub, err := GenerateKeyPairED25519()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	newPriv, newPub, err := GenerateKeyPairED25519()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = f.Sign(newPriv, KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Test with the signing key pair's public key not first.
+	i, err := f.VerifyAny([][]byte{oldPub, newPub}, KeyPairAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if i != 1 {
+		t.Fatal("Wrong public key index returned.", i)
+		return
+	}
+
+	//Test without the signing key pair's public key.
+	i, err = f.VerifyAny([][]byte{oldPub}, KeyPairAlgoED25519)
+	if err != ErrBadSignature {
+		t.Fatal("ErrBadSignature should have been returned.", err)
+		return
+	}
+	if i != -1 {
+		t.Fatal("Index should be -1 when no public key verifies.", i)
+		return
+	}
+
+	//Test with no public keys.
+	_, err = f.VerifyAny(nil, KeyPairAlgoED25519)
+	if err != ErrNoPublicKeys {
+		t.Fatal("ErrNoPublicKeys should have been returned.", err)
+		return
+	}
+}
+
 func TestHash(t *testing.T) {
 	f := File{
 		CompanyName: "test1",
@@ -318,6 +465,230 @@ func TestExpired(t *testing.T) {
 	}
 }
 
+func TestExpiredWithGrace(t *testing.T) {
+	//Expired license, but still within grace period.
+	pastDate := time.Now().UTC().AddDate(0, 0, -2)
+
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+		ExpireDate:  pastDate.Format("2006-01-02"),
+	}
+
+	grace := 7 * 24 * time.Hour
+	expired, err := f.ExpiredWithGrace(grace)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if expired {
+		t.Fatal("License is within grace period and should not be noted as expired.")
+		return
+	}
+
+	inGrace, err := f.InGracePeriod(grace)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !inGrace {
+		t.Fatal("License should be noted as in grace period.")
+		return
+	}
+
+	//Expired license, past grace period.
+	pastDate = time.Now().UTC().AddDate(0, 0, -10)
+	f.ExpireDate = pastDate.Format("2006-01-02")
+
+	expired, err = f.ExpiredWithGrace(grace)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !expired {
+		t.Fatal("License is past grace period, but was not noted as expired.")
+		return
+	}
+
+	inGrace, err = f.InGracePeriod(grace)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if inGrace {
+		t.Fatal("License is past grace period, but was noted as in grace period.")
+		return
+	}
+
+	//Not expired license.
+	futureDate := time.Now().UTC().AddDate(0, 0, 10)
+	f.ExpireDate = futureDate.Format("2006-01-02")
+
+	inGrace, err = f.InGracePeriod(grace)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if inGrace {
+		t.Fatal("License is not expired, but was noted as in grace period.")
+		return
+	}
+
+	//Missing expiration date.
+	f.ExpireDate = ""
+	_, err = f.ExpiredWithGrace(grace)
+	if err != ErrMissingExpireDate {
+		t.Fatal("Error about missing expire date should have occured.")
+		return
+	}
+	_, err = f.InGracePeriod(grace)
+	if err != ErrMissingExpireDate {
+		t.Fatal("Error about missing expire date should have occured.")
+		return
+	}
+}
+
+func TestExpiredDatetime(t *testing.T) {
+	//Expire date is in the future, but the more precise expire datetime is in the
+	//past. Expire datetime should take precedence.
+	futureDate := time.Now().UTC().AddDate(0, 0, 10)
+	pastDatetime := time.Now().UTC().Add(-1 * time.Hour)
+
+	f := File{
+		CompanyName:    "CompanyName",
+		PhoneNumber:    "123-123-1234",
+		Email:          "test@example.com",
+		fileFormat:     FileFormatJSON,
+		ExpireDate:     futureDate.Format("2006-01-02"),
+		ExpireDatetime: pastDatetime.Format(time.RFC3339),
+	}
+
+	expired, err := f.Expired()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !expired {
+		t.Fatal("License is expired per expire datetime, but was not noted as such.")
+		return
+	}
+
+	diff, err := f.ExpiresIn()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if diff > 0 {
+		t.Fatal("Diff should be negative for expired license.", diff)
+		return
+	}
+
+	//Not expired per expire datetime.
+	futureDatetime := time.Now().UTC().Add(1 * time.Hour)
+	f.ExpireDatetime = futureDatetime.Format(time.RFC3339)
+
+	expired, err = f.Expired()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if expired {
+		t.Fatal("License is not expired per expire datetime.")
+		return
+	}
+
+	//Invalid expire datetime format.
+	f.ExpireDatetime = "2006-01-02 15:04"
+	_, err = f.Expired()
+	if err == nil {
+		t.Fatal("Error about incorrectly formatted expire datetime should have occured.")
+		return
+	}
+}
+
+func TestNotYetValid(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+		ExpireDate:  time.Now().UTC().AddDate(0, 0, 30).Format("2006-01-02"),
+	}
+
+	//No valid from date, license is always valid.
+	notYetValid, err := f.NotYetValid()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if notYetValid {
+		t.Fatal("License without valid from date should be valid.")
+		return
+	}
+
+	//Valid from date in the future.
+	f.ValidFrom = time.Now().UTC().AddDate(0, 0, 10).Format("2006-01-02")
+	notYetValid, err = f.NotYetValid()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !notYetValid {
+		t.Fatal("License with future valid from date should not be valid yet.")
+		return
+	}
+
+	//Valid from date in the past.
+	f.ValidFrom = time.Now().UTC().AddDate(0, 0, -10).Format("2006-01-02")
+	notYetValid, err = f.NotYetValid()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if notYetValid {
+		t.Fatal("License with past valid from date should be valid.")
+		return
+	}
+
+	//Invalid valid from date format.
+	f.ValidFrom = "01/02/2006"
+	_, err = f.NotYetValid()
+	if err == nil {
+		t.Fatal("Error about incorrectly formatted valid from date should have occured.")
+		return
+	}
+}
+
+func TestHasFeature(t *testing.T) {
+	f := File{
+		CompanyName: "CompanyName",
+		PhoneNumber: "123-123-1234",
+		Email:       "test@example.com",
+		fileFormat:  FileFormatJSON,
+	}
+
+	//No features.
+	if f.HasFeature("reporting") {
+		t.Fatal("License without features should not have feature.")
+		return
+	}
+
+	//Feature enabled.
+	f.Features = []string{"reporting", "sso"}
+	if !f.HasFeature("sso") {
+		t.Fatal("License should have feature.")
+		return
+	}
+
+	//Feature not enabled, keys are case sensitive.
+	if f.HasFeature("SSO") || f.HasFeature("export") {
+		t.Fatal("License should not have feature.")
+		return
+	}
+}
+
 func TestExpiresIn(t *testing.T) {
 	//Future expiration.
 	days := 10
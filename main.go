@@ -7,6 +7,7 @@ applications (obviously, golang only apps).
 package main
 
 import (
+	"context"
 	"embed"
 	"flag"
 	"fmt"
@@ -15,20 +16,29 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/c9845/hashfs"
 	"github.com/c9845/licensekeys/v3/activitylog"
 	"github.com/c9845/licensekeys/v3/apikeys"
 	"github.com/c9845/licensekeys/v3/apps"
 	"github.com/c9845/licensekeys/v3/appsettings"
+	"github.com/c9845/licensekeys/v3/backup"
 	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/customfields"
 	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/healthcheck"
 	"github.com/c9845/licensekeys/v3/keypairs"
 	"github.com/c9845/licensekeys/v3/license"
+	"github.com/c9845/licensekeys/v3/metrics"
 	"github.com/c9845/licensekeys/v3/middleware"
+	"github.com/c9845/licensekeys/v3/notifications"
 	"github.com/c9845/licensekeys/v3/pages"
+	"github.com/c9845/licensekeys/v3/reports"
 	"github.com/c9845/licensekeys/v3/users"
 	"github.com/c9845/licensekeys/v3/version"
 	"github.com/c9845/output"
@@ -66,6 +76,8 @@ func init() {
 	dbDeploySchema := flag.Bool("deploy-db", false, "Deploy a new database or add new tables to an existing database.")
 	dbUpdateSchema := flag.Bool("update-db", false, "Update an already deployed database.")
 	logFlags := flag.String("log-prefix", "ymdhms", "Format of logging prefix; none, ymdhms, or ymdhmsmicro.")
+	initialPassword := flag.String("initial-password", "", "Password for the initial user created when the database is deployed, instead of a random password.")
+	resetAdminPassword := flag.String("reset-admin-password", "", "Username of an administrator whose password will be reset to a random password.")
 	flag.Parse()
 
 	//Handle setting logging prefix. This is useful for handling differences in systems
@@ -179,6 +191,7 @@ func init() {
 	pageConfig := pages.Config{
 		Development:   config.Data().Development,
 		UseLocalFiles: config.Data().UseLocalFiles,
+		BaseURLPath:   config.Data().BaseURLPath,
 		TemplateFiles: templateFilesFS,
 		StaticFiles:   staticFilesHashFS,
 	}
@@ -219,6 +232,9 @@ func init() {
 
 	sqldb.Use(cfg)
 
+	//Use the provided password for the initial user, if the database is deployed.
+	db.InitialUserProvidedPassword = *initialPassword
+
 	//Deploy the database if requested by --deploy-db flag.
 	if *dbDeploySchema {
 		err := sqldb.DeploySchema(nil)
@@ -231,7 +247,7 @@ func init() {
 		if db.InitialUserPassword != "" {
 			log.Println("*********************************************")
 			log.Println("Initial User Credentials:")
-			log.Println(" Username:", db.InitialUserUsername)
+			log.Println(" Username:", config.Data().InitialUserUsername)
 			log.Println(" Password:", db.InitialUserPassword)
 			log.Println("*********************************************")
 
@@ -290,7 +306,7 @@ func init() {
 		if db.InitialUserPassword != "" {
 			log.Println("*********************************************")
 			log.Println("Initial User Credentials:")
-			log.Println(" Username:", db.InitialUserUsername)
+			log.Println(" Username:", config.Data().InitialUserUsername)
 			log.Println(" Password:", db.InitialUserPassword)
 			log.Println("*********************************************")
 
@@ -311,6 +327,31 @@ func init() {
 		return
 	}
 
+	//Reset an administrator's password if requested by the --reset-admin-password
+	//flag. This is used to recover access to the app if the only administrator lost
+	//their password. This is only available via a flag, not in the GUI or API, so
+	//that direct access to the server is required.
+	//
+	//Always exit after resetting, similar to the deploy and update flags, so that the
+	//flag isn't hardcoded and a password reset every time the app is started.
+	if *resetAdminPassword != "" {
+		password, err := users.ResetAdminPassword(context.Background(), *resetAdminPassword)
+		if err != nil {
+			log.Fatalln("Could not reset administrator password.", err)
+			return
+		}
+
+		log.Println("*********************************************")
+		log.Println("Reset Administrator Credentials:")
+		log.Println(" Username:", strings.ToLower(strings.TrimSpace(*resetAdminPassword)))
+		log.Println(" Password:", password)
+		log.Println("*********************************************")
+		log.Println("The user has been logged out of all sessions.")
+
+		os.Exit(0)
+		return
+	}
+
 	//Enable logging of HTTP response errorrs.
 	output.Debug(true)
 }
@@ -320,15 +361,26 @@ func main() {
 
 	//Define middleware.
 	secHeaders := alice.New(middleware.SecHeaders)
-	auth := secHeaders.Append(middleware.Auth, middleware.LogActivity2)
+	auth := secHeaders.Append(middleware.Auth, middleware.LogActivity2, middleware.ViewOnlyImpersonation)
 	admin := auth.Append(middleware.Administrator)
 	createLics := auth.Append(middleware.CreateLicenses)
 	viewLics := auth.Append(middleware.ViewLicenses)
+	auditor := auth.Append(middleware.Auditor)
+
+	//Register collectors for metrics exposed to Prometheus.
+	metrics.Register()
 
 	//Start the router.
 	r := mux.NewRouter()
 	r.StrictSlash(true)
 
+	//Record the duration of every request for metrics.
+	r.Use(middleware.Metrics)
+
+	//Limit the size of request bodies to prevent memory exhaustion. See
+	//MaxRequestBodyMB in the config file.
+	r.Use(middleware.MaxBodySize)
+
 	//Handle pages.
 	//**login & logout.
 	//  Using HandleFunc here instead of Handle with http.HandlerFunc, as below routes,
@@ -343,7 +395,7 @@ func main() {
 	a.Handle("/user-profile/", auth.ThenFunc(pages.UserProfile)).Methods("GET")
 
 	l := a.PathPrefix("/licensing").Subrouter()
-	l.Handle("/apps/", admin.ThenFunc(pages.Page)).Methods("GET")
+	l.Handle("/apps/", admin.ThenFunc(pages.Apps)).Methods("GET")
 	l.Handle("/licenses/", viewLics.ThenFunc(pages.Page)).Methods("GET")
 	l.Handle("/create-license/", createLics.ThenFunc(pages.Page)).Methods("GET")
 	l.Handle("/license/", viewLics.ThenFunc(pages.License)).Methods("GET")
@@ -351,20 +403,39 @@ func main() {
 	//*admin stuff and settings
 	adm := a.PathPrefix("/administration").Subrouter()
 	adm.Handle("/users/", admin.ThenFunc(pages.Users)).Methods("GET")
-	adm.Handle("/app-settings/", admin.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/app-settings/", auditor.ThenFunc(pages.Page)).Methods("GET")
 	adm.Handle("/api-keys/", admin.ThenFunc(pages.Page)).Methods("GET")
-	adm.Handle("/user-logins/", admin.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/user-logins/", auditor.ThenFunc(pages.Page)).Methods("GET")
 	adm.Handle("/tools/", admin.ThenFunc(pages.Page)).Methods("GET")
 
-	adm.Handle("/activity-log/", admin.ThenFunc(pages.Page)).Methods("GET")
-	adm.Handle("/activity-log/activity-over-time-of-day/", admin.ThenFunc(pages.Page)).Methods("GET")
-	adm.Handle("/activity-log/max-and-avg-duration-by-month/", admin.ThenFunc(pages.Page)).Methods("GET")
-	adm.Handle("/activity-log/duration-of-latest-requests/", admin.ThenFunc(pages.Page)).Methods("GET")
-	adm.Handle("/activity-log/duration-by-endpoint/", admin.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/activity-log/", auditor.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/activity-log/activity-over-time-of-day/", auditor.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/activity-log/max-and-avg-duration-by-month/", auditor.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/activity-log/duration-of-latest-requests/", auditor.ThenFunc(pages.Page)).Methods("GET")
+	adm.Handle("/activity-log/duration-by-endpoint/", auditor.ThenFunc(pages.Page)).Methods("GET")
 
 	//**diagnostic stuff, accessible without logging in so not on "app" path.
 	r.Handle("/diagnostics/", secHeaders.ThenFunc(pages.Diagnostics)).Methods("GET")
 	r.HandleFunc("/healthcheck/", healthcheckHandler)
+	r.HandleFunc("/livez/", healthcheck.Live).Methods("GET")
+	r.HandleFunc("/readyz/", healthcheck.Ready).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	//**public license verification, accessible without logging in. Rate limited to
+	//  prevent enumeration of license public IDs.
+	verify := secHeaders.Append(middleware.RateLimit(30, time.Minute))
+	r.Handle("/verify/{publicID}/", verify.ThenFunc(pages.Verify)).Methods("GET")
+
+	//**license download links, accessible without logging in so customers can
+	//  download their license from a link that was emailed to them. Rate limited to
+	//  prevent guessing of link signatures.
+	downloadLinks := secHeaders.Append(middleware.RateLimit(30, time.Minute))
+	r.Handle("/download-link/", downloadLinks.ThenFunc(pages.DownloadLink)).Methods("GET")
+
+	//**public keys for an app, accessible without logging in so third-party apps can
+	//  fetch the current set of public keys when key pairs are rotated.
+	publicKeys := secHeaders.Append(middleware.RateLimit(60, time.Minute))
+	r.Handle("/public-keys/{appID}/", publicKeys.ThenFunc(keypairs.PublicKeys)).Methods("GET")
 
 	//**help docs
 	help := r.PathPrefix("/help").Subrouter()
@@ -376,82 +447,173 @@ func main() {
 	//API calls (internal to the app, not accesible with api key or outside of app).
 	api := r.PathPrefix("/api").Subrouter()
 
+	//Reject requests that make changes while the app is in maintenance mode. This is
+	//applied to each subrouter, instead of to api, so that the tools subrouter is
+	//exempt and an administrator can still back up the database and turn off
+	//maintenance mode. See MaintenanceMode in the config file.
+
 	//**users
+	//Impersonation is not blocked in maintenance mode since it only changes the
+	//administrator's session, the same as logging in. This is registered before the
+	//users subrouter so that it isn't matched by the users subrouter's path prefix.
+	imp := api.PathPrefix("/users/impersonate").Subrouter()
+	imp.Handle("/", admin.ThenFunc(users.Impersonate)).Methods("POST")
+	imp.Handle("/end/", auth.ThenFunc(users.EndImpersonation)).Methods("POST") //Not admin since permissions are checked as the impersonated user, checked in func.
+
 	u := api.PathPrefix("/users/").Subrouter()
+	u.Use(middleware.Maintenance)
 	u.Handle("/", auth.ThenFunc(users.GetAll)).Methods("GET")
 	u.Handle("/add/", admin.ThenFunc(users.Add)).Methods("POST")
 	u.Handle("/update/", admin.ThenFunc(users.Update)).Methods("POST")
+	u.Handle("/import/", admin.ThenFunc(users.Import)).Methods("POST")
 	u.Handle("/change-password/", admin.ThenFunc(users.ChangePassword)).Methods("POST")
 	u.Handle("/2fa/get-qr-code/", admin.ThenFunc(users.Get2FABarcode)).Methods("GET")
 	u.Handle("/2fa/verify/", admin.ThenFunc(users.Validate2FACode)).Methods("POST")
 	u.Handle("/2fa/deactivate/", admin.ThenFunc(users.Deactivate2FA)).Methods("POST")
+	u.Handle("/passkeys/", auth.ThenFunc(users.GetPasskeys)).Methods("GET")                                //Admin or self, checked in func.
+	u.Handle("/passkeys/register/begin/", auth.ThenFunc(users.BeginPasskeyRegistration)).Methods("POST")   //Admin or self, checked in func.
+	u.Handle("/passkeys/register/finish/", auth.ThenFunc(users.FinishPasskeyRegistration)).Methods("POST") //Admin or self, checked in func.
+	u.Handle("/passkeys/delete/", auth.ThenFunc(users.DeletePasskey)).Methods("POST")                      //Admin or self, checked in func.
 	u.Handle("/force-logout/", admin.ThenFunc(users.ForceLogout)).Methods("POST")
 	u.Handle("/login-history/clear/", admin.ThenFunc(users.ClearLoginHistory)).Methods("POST")
 
 	u1 := api.PathPrefix("/user").Subrouter()
-	u1.Handle("/", auth.ThenFunc(users.GetOne)).Methods("GET") //For user profile page.
+	u1.Use(middleware.Maintenance)
+	u1.Handle("/", auth.ThenFunc(users.GetOne)).Methods("GET")                         //For user profile page.
+	u1.Handle("/timezone/", auth.ThenFunc(users.SetTimezone)).Methods("POST")          //For user profile page.
+	u1.Handle("/sessions/", auth.ThenFunc(users.Sessions)).Methods("GET")              //For user profile page.
+	u1.Handle("/sessions/revoke/", auth.ThenFunc(users.RevokeSession)).Methods("POST") //For user profile page.
 
 	//**app settings
 	as := api.PathPrefix("/app-settings").Subrouter()
-	as.Handle("/", admin.ThenFunc(appsettings.Get)).Methods("GET")
+	as.Use(middleware.Maintenance)
+	as.Handle("/", auditor.ThenFunc(appsettings.Get)).Methods("GET")
 	as.Handle("/update/", admin.ThenFunc(appsettings.Update)).Methods("POST")
 
 	//**api keys
 	ak := api.PathPrefix("/api-keys").Subrouter()
+	ak.Use(middleware.Maintenance)
 	ak.Handle("/", admin.ThenFunc(apikeys.GetAll)).Methods("GET")
 	ak.Handle("/generate/", admin.ThenFunc(apikeys.Generate)).Methods("POST")
 	ak.Handle("/revoke/", admin.ThenFunc(apikeys.Revoke)).Methods("POST")
 	ak.Handle("/update/", admin.ThenFunc(apikeys.Update)).Methods("POST")
+	ak.Handle("/apps/assign/", admin.ThenFunc(apikeys.AssignApp)).Methods("POST")
+	ak.Handle("/apps/unassign/", admin.ThenFunc(apikeys.UnassignApp)).Methods("POST")
+	ak.Handle("/allowed-ips/", admin.ThenFunc(apikeys.SetAllowedIPs)).Methods("POST")
 
 	//**activity log
 	act := api.PathPrefix("/activity-log").Subrouter()
+	act.Use(middleware.Maintenance)
 	act.Handle("/clear/", admin.ThenFunc(activitylog.Clear)).Methods("POST")
-	act.Handle("/latest/", admin.ThenFunc(activitylog.GetLatest)).Methods("GET")
-	act.Handle("/latest/filter-by-endpoints/", admin.ThenFunc(activitylog.GetLatestEndpoints)).Methods("GET")
-	act.Handle("/over-time-of-day/", admin.ThenFunc(activitylog.OverTimeOfDay)).Methods("GET")
-	act.Handle("/max-and-avg-monthly-duration/", admin.ThenFunc(activitylog.MaxAndAvgMonthlyDuration)).Methods("GET")
-	act.Handle("/latest-requests-duration/", admin.ThenFunc(activitylog.LatestRequestsDuration)).Methods("GET")
-	act.Handle("/duration-by-endpoint/", admin.ThenFunc(activitylog.DurationByEndpoint)).Methods("GET")
+	act.Handle("/latest/", auditor.ThenFunc(activitylog.GetLatest)).Methods("GET")
+	act.Handle("/latest/filter-by-endpoints/", auditor.ThenFunc(activitylog.GetLatestEndpoints)).Methods("GET")
+	act.Handle("/over-time-of-day/", auditor.ThenFunc(activitylog.OverTimeOfDay)).Methods("GET")
+	act.Handle("/max-and-avg-monthly-duration/", auditor.ThenFunc(activitylog.MaxAndAvgMonthlyDuration)).Methods("GET")
+	act.Handle("/latest-requests-duration/", auditor.ThenFunc(activitylog.LatestRequestsDuration)).Methods("GET")
+	act.Handle("/duration-by-endpoint/", auditor.ThenFunc(activitylog.DurationByEndpoint)).Methods("GET")
+
+	//**tools
+	tools := api.PathPrefix("/tools").Subrouter()
+	tools.Handle("/backup/", admin.ThenFunc(backup.Backup)).Methods("POST")
+	tools.Handle("/maintenance/", admin.ThenFunc(appsettings.MaintenanceMode)).Methods("GET")
+	tools.Handle("/maintenance/", admin.ThenFunc(appsettings.SetMaintenanceMode)).Methods("POST")
+	tools.Handle("/announcement/", admin.ThenFunc(appsettings.SetAnnouncement)).Methods("POST")
+	tools.Handle("/test-smtp/", admin.ThenFunc(reports.SendTestEmail)).Methods("POST")
+	tools.Handle("/test-webhook/", admin.ThenFunc(notifications.SendTest)).Methods("POST")
 
 	//**user logins
 	ulg := api.PathPrefix("/user-logins").Subrouter()
-	ulg.Handle("/latest/", admin.ThenFunc(users.LatestLogins)).Methods("GET")
+	ulg.Handle("/latest/", auditor.ThenFunc(users.LatestLogins)).Methods("GET")
+
+	//**security events
+	sev := api.PathPrefix("/security-events").Subrouter()
+	sev.Handle("/latest/", admin.ThenFunc(users.SecurityEvents)).Methods("GET")
 
 	//**apps
 	app := api.PathPrefix("/apps").Subrouter()
+	app.Use(middleware.Maintenance)
 	app.Handle("/", viewLics.ThenFunc(apps.Get)).Methods("GET") //Users need to view app to sort created licenses, and to create a new license.
 	app.Handle("/add/", admin.ThenFunc(apps.Add)).Methods("POST")
 	app.Handle("/update/", admin.ThenFunc(apps.Update)).Methods("POST")
+	app.Handle("/clone/", admin.ThenFunc(apps.Clone)).Methods("POST")
+	app.Handle("/export/", admin.ThenFunc(apps.Export)).Methods("GET")
+	app.Handle("/import/", admin.ThenFunc(apps.Import)).Methods("POST")
+	app.Handle("/license-schema-version/bump/", admin.ThenFunc(apps.BumpLicenseSchemaVersion)).Methods("POST")
+	app.Handle("/features/", createLics.ThenFunc(apps.GetFeatures)).Methods("GET") //When creating a license, a user needs to be able to choose the features to enable.
+	app.Handle("/features/add/", admin.ThenFunc(apps.AddFeature)).Methods("POST")
+	app.Handle("/features/update/", admin.ThenFunc(apps.UpdateFeature)).Methods("POST")
+	app.Handle("/features/delete/", admin.ThenFunc(apps.DeleteFeature)).Methods("POST")
+	app.Handle("/{appID}/public-key/", publicKeys.ThenFunc(keypairs.DefaultPublicKey)).Methods("GET") //Public, see public keys above.
 
 	//**keypairs
 	kp := api.PathPrefix("/key-pairs").Subrouter()
+	kp.Use(middleware.Maintenance)
 	kp.Handle("/", createLics.ThenFunc(keypairs.Get)).Methods("GET") //When creating a license, a user needs to be able to view the apps to create licenses for.
 	kp.Handle("/add/", admin.ThenFunc(keypairs.Add)).Methods("POST")
+	kp.Handle("/import/", admin.ThenFunc(keypairs.Import)).Methods("POST")
 	kp.Handle("/delete/", admin.ThenFunc(keypairs.Delete)).Methods("POST")
 	kp.Handle("/set-default/", admin.ThenFunc(keypairs.Default)).Methods("POST")
+	kp.Handle("/mark-compromised/", admin.ThenFunc(keypairs.MarkCompromised)).Methods("POST")
+	kp.Handle("/export/", admin.ThenFunc(keypairs.Export)).Methods("POST")
+	kp.Handle("/health/", admin.ThenFunc(keypairs.Health)).Methods("GET")
+	kp.Handle("/encryption-key/", admin.ThenFunc(keypairs.CheckEncryptionKey)).Methods("GET")
 
 	//**custom fields
 	cf := api.PathPrefix("/custom-fields").Subrouter()
+	cf.Use(middleware.Maintenance)
 	cfd := cf.PathPrefix("/defined").Subrouter()
 	cfd.Handle("/", createLics.ThenFunc(customfields.GetDefined)).Methods("GET") //When creating a license, a user needs to be able to view the apps to create licenses for.
 	cfd.Handle("/add/", admin.ThenFunc(customfields.Add)).Methods("POST")
 	cfd.Handle("/update/", admin.ThenFunc(customfields.Update)).Methods("POST")
+	cfd.Handle("/reorder/", admin.ThenFunc(customfields.Reorder)).Methods("POST")
 	cfd.Handle("/delete/", admin.ThenFunc(customfields.DeleteDefined)).Methods("POST")
+	cfd.Handle("/purge/", admin.ThenFunc(customfields.PurgeDefined)).Methods("POST")
 
 	cfr := cf.PathPrefix("/results").Subrouter()
 	cfr.Handle("/", viewLics.ThenFunc(customfields.GetResults)).Methods("GET")
 
 	//**licenses
 	lics := api.PathPrefix("/licenses").Subrouter()
+	lics.Use(middleware.Maintenance)
 	lics.Handle("/", viewLics.ThenFunc(license.One)).Queries("id", "").Methods("GET")
 	lics.Handle("/", viewLics.ThenFunc(license.All)).Methods("GET")
 	lics.Handle("/add/", createLics.ThenFunc(license.Add)).Methods("POST")
+	lics.Handle("/preview/", createLics.ThenFunc(license.Preview)).Methods("POST")
+	lics.Handle("/preview/signing-payload/", admin.ThenFunc(license.PreviewSigningPayload)).Methods("POST")
+	lics.Handle("/templates/", createLics.ThenFunc(license.Templates)).Methods("GET")
+	lics.Handle("/templates/apply/", createLics.ThenFunc(license.ApplyTemplate)).Methods("GET")
+	lics.Handle("/templates/add/", admin.ThenFunc(license.AddTemplate)).Methods("POST")
+	lics.Handle("/templates/delete/", admin.ThenFunc(license.DeleteTemplate)).Methods("POST")
 	lics.Handle("/download/", viewLics.ThenFunc(license.Download)).Methods("GET")
+	lics.Handle("/download-link/", viewLics.ThenFunc(license.CreateDownloadLink)).Methods("POST")
+	lics.Handle("/qr/", viewLics.ThenFunc(license.QRCode)).Methods("GET")
+	lics.Handle("/download-company/", viewLics.ThenFunc(license.DownloadCompany)).Methods("GET")
+	lics.Handle("/email/preview/", viewLics.ThenFunc(license.PreviewEmail)).Methods("GET")
+	lics.Handle("/email/send/", viewLics.ThenFunc(license.EmailLicense)).Methods("POST")
 	lics.Handle("/history/", viewLics.ThenFunc(license.History)).Methods("GET")
+	lics.Handle("/report/", viewLics.ThenFunc(license.Report)).Methods("GET")
+	lics.Handle("/activity/", auditor.ThenFunc(license.ActivityLog)).Methods("GET")
+	lics.Handle("/diff/", viewLics.ThenFunc(license.Diff)).Methods("GET")
 	lics.Handle("/notes/", viewLics.ThenFunc(license.Notes)).Methods("GET")
 	lics.Handle("/notes/add/", createLics.ThenFunc(license.AddNote)).Methods("POST")
+	lics.Handle("/attachments/", viewLics.ThenFunc(license.Attachments)).Methods("GET")
+	lics.Handle("/attachments/add/", createLics.Append(middleware.RequireContentType("multipart/form-data")).ThenFunc(license.AddAttachment)).Methods("POST")
+	lics.Handle("/attachments/download/", viewLics.ThenFunc(license.DownloadAttachment)).Methods("GET")
+	lics.Handle("/attachments/delete/", createLics.ThenFunc(license.DeleteAttachment)).Methods("POST")
+	lics.Handle("/update-metadata/", createLics.ThenFunc(license.UpdateMetadata)).Methods("POST")
 	lics.Handle("/disable/", createLics.ThenFunc(license.Disable)).Methods("POST")
+	lics.Handle("/disable-bulk/", createLics.ThenFunc(license.DisableBulk)).Methods("POST")
+	lics.Handle("/schedule-disable/", createLics.ThenFunc(license.ScheduleDisable)).Methods("POST")
+	lics.Handle("/schedule-disable/cancel/", createLics.ThenFunc(license.CancelScheduledDisable)).Methods("POST")
 	lics.Handle("/renew/", createLics.ThenFunc(license.Renew)).Methods("POST")
+	lics.Handle("/extend/", createLics.ThenFunc(license.Extend)).Methods("POST")
+	lics.Handle("/transfer/", createLics.ThenFunc(license.Transfer)).Methods("POST")
+	lics.Handle("/activate-offline/", createLics.ThenFunc(license.ActivateOffline)).Methods("POST")
+	lics.Handle("/activations/", viewLics.ThenFunc(license.Activations)).Methods("GET")
+	lics.Handle("/activations/reset/", admin.ThenFunc(license.ResetActivations)).Methods("POST")
+	lics.Handle("/rebuild/", admin.ThenFunc(license.Rebuild)).Methods("POST")
+	lics.Handle("/import/", admin.Append(middleware.RequireContentType("application/x-www-form-urlencoded", "multipart/form-data")).ThenFunc(license.Import)).Methods("POST")
+	lics.Handle("/verify-batch/", admin.Append(middleware.RequireContentType("application/x-www-form-urlencoded", "multipart/form-data")).ThenFunc(license.VerifyBatch)).Methods("POST")
 
 	//Handle public API endpoints.
 	//
@@ -461,12 +623,26 @@ func main() {
 	//
 	//This list of endpoints must match the list defined in middleware-externalAPI.go
 	//that checks permissions for the API key being used to access the endpoint.
+	//
+	//OPTIONS is allowed on each endpoint for CORS preflight requests from browser-based
+	//clients, see APIAllowedOrigins in the config file.
 	externalAPI := alice.New(middleware.ExternalAPI, middleware.LogActivity2)
 	extAPI := api.PathPrefix("/v1").Subrouter()
-	extAPI.Handle("/licenses/add/", externalAPI.ThenFunc(license.AddViaAPI)).Methods("POST")
-	extAPI.Handle("/licenses/download/", externalAPI.ThenFunc(license.Download)).Methods("GET")
-	extAPI.Handle("/licenses/renew/", externalAPI.ThenFunc(license.Renew)).Methods("POST")
-	extAPI.Handle("/licenses/disable/", externalAPI.ThenFunc(license.Disable)).Methods("POST")
+	extAPI.Use(middleware.Maintenance)
+	extAPI.Handle("/licenses/add/", externalAPI.ThenFunc(license.AddViaAPI)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/download/", externalAPI.ThenFunc(license.Download)).Methods("GET", "OPTIONS")
+	extAPI.Handle("/licenses/renew/", externalAPI.ThenFunc(license.Renew)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/disable/", externalAPI.ThenFunc(license.Disable)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/checkout/", externalAPI.ThenFunc(license.CheckOut)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/checkin/", externalAPI.ThenFunc(license.CheckIn)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/activate/", externalAPI.ThenFunc(license.Activate)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/heartbeat/", externalAPI.ThenFunc(license.Heartbeat)).Methods("POST", "OPTIONS")
+	extAPI.Handle("/licenses/status-batch/", externalAPI.ThenFunc(license.StatusBatch)).Methods("POST", "OPTIONS")
+
+	//The OpenAPI document describing the public API is accessible without an API key
+	//so that it can be fetched by code generation tools. This must be updated when
+	//the list of public API endpoints above is changed.
+	extAPI.Handle("/openapi.json", secHeaders.ThenFunc(license.OpenAPI)).Methods("GET")
 
 	//Handle static files served off the root directory. This is typically for robots.txt,
 	//favicon, etc. {file} is placeholder that isn't used, it is there just so that the
@@ -479,6 +655,28 @@ func main() {
 	//See pages-templateFuncMap.go's static() func for more info.
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", hashfs.FileServer(staticFilesHashFS)))
 
+	//Make sure the private keys can be decrypted with the PrivateKeyEncryptionKey in
+	//the config file so that a changed key is noticed before licenses are created.
+	keypairs.LogEncryptionKeyCheck()
+
+	//Start automatic database backups, if enabled in config file.
+	go backup.StartScheduler()
+
+	//Start reclaiming license seats with expired leases.
+	go license.StartSeatSweeper()
+
+	//Start disabling licenses whose scheduled disable date has arrived.
+	go license.StartScheduledDisabler()
+
+	//Start posting notifications to the webhook, if enabled in config file.
+	go notifications.StartSender()
+
+	//Start deleting old user logins, if enabled in config file.
+	go users.StartLoginPurger()
+
+	//Start emailing license activity reports, if enabled in config file.
+	go reports.StartScheduler()
+
 	//Listen and serve.
 	//
 	//Windows:
@@ -496,17 +694,178 @@ func main() {
 	//Linux Server:
 	//  - Listening on 127.0.0.1 will work without warnings.
 	//  - Set host to 0.0.0.0, or exact IP of remote server, to allow access to app by ip:port without a proxy.
-	port := config.Data().Port
-	host := config.Data().Host
+	//
+	//If a TLS certificate and key are provided, serve HTTPS directly instead of
+	//relying on a terminating proxy. The certificate and key were already validated
+	//when the config file was read. An optional HTTP listener redirects to HTTPS.
+	//
+	//Timeouts are set on each server to prevent slow clients from holding connections
+	//open and exhausting resources.
+	cfg := config.Data()
+	port := cfg.Port
+	host := cfg.Host
 	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
-	log.Printf("Listening on: %s:%d", host, port)
-	log.Fatal(http.ListenAndServe(hostPort, r))
+
+	//If the app is served at a subpath behind a proxy, the base URL path is removed
+	//from each request's path before routing so that the routes above, and the code
+	//handling each route, do not need to know about it.
+	var h http.Handler = r
+	if cfg.BaseURLPath != "" {
+		h = baseURLPathHandler(cfg.BaseURLPath, r)
+	}
+
+	srv := newServer(hostPort, h)
+
+	var redirectSrv *http.Server
+	if cfg.TLSEnabled() && cfg.TLSRedirectPort > 0 {
+		redirectHostPort := net.JoinHostPort(host, strconv.Itoa(cfg.TLSRedirectPort))
+		redirectSrv = newServer(redirectHostPort, httpsRedirectHandler(port))
+
+		log.Printf("Redirecting HTTP to HTTPS on: %s:%d", host, cfg.TLSRedirectPort)
+		go func() {
+			err := redirectSrv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		if cfg.TLSEnabled() {
+			log.Printf("Listening on: %s:%d (HTTPS)", host, port)
+			err = srv.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath)
+		} else {
+			log.Printf("Listening on: %s:%d", host, port)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	//Wait for a signal to stop the app, then stop accepting new requests and let
+	//in-flight requests finish before returning. Returning closes the database via
+	//the deferred sqldb.Close() above.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop() //a second signal will now stop the app immediately.
+
+	log.Println("Shutting down, waiting for in-flight requests to finish...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if redirectSrv != nil {
+		err := redirectSrv.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Println("Could not gracefully shut down HTTP to HTTPS redirect.", err)
+		}
+	}
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Println("Could not gracefully shut down, in-flight requests may have been interrupted.", err)
+	}
+
+	log.Println("Shut down.")
+}
+
+// shutdownTimeout is the maximum time to wait for in-flight requests to finish when
+// the app is stopped.
+const shutdownTimeout = 30 * time.Second
+
+// newServer returns an HTTP server with the timeouts set in the config file.
+func newServer(addr string, h http.Handler) *http.Server {
+	cfg := config.Data()
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutSeconds) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+// httpsRedirectHandler redirects HTTP requests to the same host and path using HTTPS
+// on the port the app is serving HTTPS on.
+func httpsRedirectHandler(tlsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		target := "https://" + host
+		if tlsPort != 443 {
+			target = "https://" + net.JoinHostPort(host, strconv.Itoa(tlsPort))
+		}
+		target += r.URL.RequestURI()
+
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// baseURLPathHandler removes the base URL path from the path of each request before
+// passing the request to h. Requests not under the base URL path are not found.
+// Redirects to paths within the app, including the router's trailing slash redirects,
+// are prefixed with the base URL path.
+func baseURLPathHandler(base string, h http.Handler) http.Handler {
+	stripped := http.StripPrefix(base, h)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == base {
+			http.Redirect(w, r, base+"/", http.StatusFound)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, base+"/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		stripped.ServeHTTP(&baseURLPathWriter{ResponseWriter: w, base: base}, r)
+	})
+}
+
+// baseURLPathWriter prefixes the Location header of redirects with the base URL path.
+// Only paths within the app, that start with a single slash, are prefixed.
+type baseURLPathWriter struct {
+	http.ResponseWriter
+	base string
+}
+
+// WriteHeader prefixes the Location header, if needed, before writing the status.
+func (bw *baseURLPathWriter) WriteHeader(statusCode int) {
+	loc := bw.Header().Get("Location")
+	if strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		bw.Header().Set("Location", bw.base+loc)
+	}
+
+	bw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap returns the underlying http.ResponseWriter for use with
+// http.ResponseController.
+func (bw *baseURLPathWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
 }
 
 // healthcheckHandler is used to send back a response when an infrastructure
 // monitoring tool is checking if this app is running/alive. The sent back
 // data could probably be more simple, something like w.Write([]byte("alive")).
+// This does not check any dependencies so that it stays lightweight for load
+// balancers, see the healthcheck package for liveness and readiness checks.
+//
+// The X-Maintenance-Mode header is set while the app is in maintenance mode so that
+// monitoring tools know when changes are being rejected without the response data
+// changing.
 func healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	if config.MaintenanceMode() {
+		w.Header().Set("X-Maintenance-Mode", "true")
+	}
+
 	output.DataFound("alive", w)
 }
 
@@ -0,0 +1,154 @@
+/*
+Package metrics handles recording app metrics and exposing them for Prometheus to
+scrape. Metrics are recorded in-memory and reset when the app restarts, Prometheus
+handles counters resetting.
+
+Collectors are registered with a registry specific to this app, instead of the
+Prometheus default registry, so that only the metrics defined here (plus Go runtime
+and process metrics) are exposed.
+*/
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace is prepended to each metric name.
+const namespace = "licensekeys"
+
+// Label values for where a license was created from.
+const (
+	SourceUser   = "user"
+	SourceAPIKey = "api_key"
+)
+
+// Label values for the result of a public license verification.
+const (
+	VerifyResultValid    = "valid"
+	VerifyResultInvalid  = "invalid"
+	VerifyResultNotFound = "not_found"
+)
+
+// Label values for the reason a login failed.
+const (
+	LoginFailureBadCredentials = "bad_credentials"
+	LoginFailureInactive       = "inactive"
+	LoginFailureBad2FA         = "bad_2fa"
+)
+
+var registry = prometheus.NewRegistry()
+
+// Collectors.
+var (
+	licensesCreated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "licenses_created_total",
+			Help:      "Number of licenses created, including renewals.",
+		},
+		[]string{"source"},
+	)
+
+	licensesVerified = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "licenses_verified_total",
+			Help:      "Number of licenses looked up on the public verification page.",
+		},
+		[]string{"result"},
+	)
+
+	loginSuccesses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_successes_total",
+			Help:      "Number of successful user logins.",
+		},
+	)
+
+	loginFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Number of failed user logins.",
+		},
+		[]string{"reason"},
+	)
+
+	externalAPIRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "external_api_requests_total",
+			Help:      "Number of requests to the external, API key authenticated, API.",
+		},
+		[]string{"endpoint"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to respond to HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+)
+
+// Register registers the collectors. This should be called once when the app starts.
+func Register() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		licensesCreated,
+		licensesVerified,
+		loginSuccesses,
+		loginFailures,
+		externalAPIRequests,
+		requestDuration,
+	)
+}
+
+// Handler returns the http.Handler that serves the metrics for Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// LicenseCreated records that a license was created. Source is one of the Source...
+// constants.
+func LicenseCreated(source string) {
+	licensesCreated.WithLabelValues(source).Inc()
+}
+
+// LicenseVerified records that a license was looked up on the public verification
+// page. Result is one of the VerifyResult... constants.
+func LicenseVerified(result string) {
+	licensesVerified.WithLabelValues(result).Inc()
+}
+
+// LoginSucceeded records a successful login.
+func LoginSucceeded() {
+	loginSuccesses.Inc()
+}
+
+// LoginFailed records a failed login. Reason is one of the LoginFailure... constants.
+func LoginFailed(reason string) {
+	loginFailures.WithLabelValues(reason).Inc()
+}
+
+// ExternalAPIRequest records a request to an external API endpoint.
+func ExternalAPIRequest(endpoint string) {
+	externalAPIRequests.WithLabelValues(endpoint).Inc()
+}
+
+// ObserveRequestDuration records the time taken to respond to a request. Route should
+// be the route's path template, not the request's path, to prevent an unbounded number
+// of label values.
+func ObserveRequestDuration(method, route string, d time.Duration) {
+	requestDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
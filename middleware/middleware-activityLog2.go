@@ -24,7 +24,9 @@ or endpoint visited so we can investigate user activity as needed.
 
 // skippedEndpoints2 are endpoints we don't need to log to the activity log since they
 // would just clog up the log.
-var skippedEndpoints2 = []string{}
+var skippedEndpoints2 = []string{
+	"/api/v1/licenses/heartbeat/", //sent periodically by every client that opts-in to sending heartbeats.
+}
 
 // LogActivity2 saves the activity the user performed to the database.
 //
@@ -64,7 +66,9 @@ func LogActivity2(next http.Handler) http.Handler {
 		//Start timer to get duration it took for server to response.
 		timer := time.Now()
 
-		//Serve the actual page/endpoint.
+		//Serve the actual page/endpoint. The handler can record the license the
+		//request was made for so the activity can be looked up by license.
+		r = r.WithContext(db.WithActivityLogLicenseID(r.Context()))
 		next.ServeHTTP(w, r)
 
 		//ServerHTTP will cause the context to be closed, therefore we won't be able
@@ -110,6 +114,7 @@ func LogActivity2(next http.Handler) http.Handler {
 			TimeDuration:   time.Since(timer).Nanoseconds() / 1000000, //milliseconds
 			PostFormValues: jStrToSave,
 			Referrer:       referrerPath, //function name is misspelled, not our field name.
+			LicenseID:      db.GetActivityLogLicenseID(ctx),
 		}
 
 		//Determine if this request was made via an API key or a user and save the
@@ -120,6 +125,7 @@ func LogActivity2(next http.Handler) http.Handler {
 		//  - userID is set in middleware.Auth().
 		apiKeyID := ctx.Value(apikeys.APIKeyContextKey)
 		userID := ctx.Value(users.UserIDContextKey)
+		impersonatorID := ctx.Value(users.ImpersonatorUserIDContextKey) //set when an administrator is viewing the app as another user.
 
 		if apiKeyID != nil {
 			activity.CreatedByAPIKeyID = null.IntFrom(apiKeyID.(int64))
@@ -131,6 +137,9 @@ func LogActivity2(next http.Handler) http.Handler {
 
 		if userID != nil {
 			activity.CreatedByUserID = null.IntFrom(userID.(int64))
+			if impersonatorID != nil {
+				activity.ImpersonatorUserID = null.IntFrom(impersonatorID.(int64))
+			}
 			err = activity.Insert(ctx)
 			if err != nil {
 				log.Println("middleware.LogActivity2", "could not save user access to log", r.URL.Path, err)
@@ -164,6 +173,15 @@ func getRequestDataAsJSON2(formVals url.Values) (output string, err error) {
 		if strings.Contains(strings.ToLower(k), "twofactorauthsecret") {
 			vFirst = "****************"
 		}
+		if strings.Contains(strings.ToLower(k), "passphrase") {
+			vFirst = "****************"
+		}
+		if strings.Contains(strings.ToLower(k), "privatekey") {
+			vFirst = "****************"
+		}
+		if strings.Contains(strings.ToLower(k), "twofatoken") {
+			vFirst = "****************"
+		}
 
 		if vFirst == "" {
 			jStr2[k] = vFirst
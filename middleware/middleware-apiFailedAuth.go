@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/output"
+)
+
+/*
+This file handles blocking IP addresses that make too many requests to the public API
+with an invalid API key. This slows down guessing, or enumerating, API keys and stops
+clients that keep using a revoked key from hammering the app.
+
+A request counts as a failed authentication if the API key is not formatted correctly,
+does not exist, is inactive, or is used from an IP address the API key is not allowed
+to be used from. Requests that don't provide an API key at all are not counted since
+these are not attempts at guessing a key.
+
+Failures are tracked in memory, per client IP address, using a fixed window, the same
+as RateLimit(). The threshold, window, and block duration are set in the config file.
+The client IP address is determined by clientIP() so that a client cannot avoid being
+blocked, or get another client blocked, by setting the X-Forwarded-For header.
+
+Old failures are removed periodically, not on each request, so that a flood of
+requests from many IP addresses doesn't make each request slower.
+*/
+
+// apiFailedAuthSweepInterval is how often failures whose window, and block, has ended
+// are removed.
+const apiFailedAuthSweepInterval = time.Minute
+
+// apiFailedAuthVisitor tracks the failed authentications from a client.
+type apiFailedAuthVisitor struct {
+	windowStart  time.Time
+	count        int
+	blockedUntil time.Time
+}
+
+// apiFailedAuthTracker tracks the failed authentications from each client.
+type apiFailedAuthTracker struct {
+	mu       sync.Mutex
+	visitors map[string]*apiFailedAuthVisitor
+
+	sweeper sync.Once
+}
+
+// apiFailedAuths is the tracker used for requests to the public API.
+var apiFailedAuths = &apiFailedAuthTracker{
+	visitors: make(map[string]*apiFailedAuthVisitor),
+}
+
+// blocked returns how much longer a client is blocked for. 0 is returned if the
+// client is not blocked.
+func (t *apiFailedAuthTracker) blocked(ip string) (remaining time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	v, ok := t.visitors[ip]
+	if !ok {
+		return 0
+	}
+
+	remaining = time.Until(v.blockedUntil)
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
+
+// fail records a failed authentication from a client and blocks the client if the
+// threshold was reached within the window. True is returned if the client was
+// blocked due to this failure.
+func (t *apiFailedAuthTracker) fail(ip string, threshold int, window, block time.Duration) (blocked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	v, ok := t.visitors[ip]
+	if !ok || now.Sub(v.windowStart) > window {
+		v = &apiFailedAuthVisitor{
+			windowStart: now,
+		}
+		t.visitors[ip] = v
+	}
+
+	v.count++
+	if v.count < threshold {
+		return false
+	}
+
+	//Start a new window once blocked so the client isn't blocked again as soon as
+	//the block ends.
+	v.blockedUntil = now.Add(block)
+	v.windowStart = now
+	v.count = 0
+	return true
+}
+
+// sweep removes visitors whose window, and block, has ended so the map doesn't grow
+// forever.
+func (t *apiFailedAuthTracker) sweep(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for k, v := range t.visitors {
+		if now.Sub(v.windowStart) > window && now.After(v.blockedUntil) {
+			delete(t.visitors, k)
+		}
+	}
+}
+
+// startSweeper removes old visitors on a ticker. This is started when the first
+// failure is recorded, and only once, since the public API may never be used.
+func (t *apiFailedAuthTracker) startSweeper(window time.Duration) {
+	t.sweeper.Do(func() {
+		go func() {
+			ticker := time.NewTicker(apiFailedAuthSweepInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				t.sweep(window)
+			}
+		}()
+	})
+}
+
+// apiClientBlocked checks if the client making a request to the public API is blocked
+// due to too many failed authentications. The ip must be from clientIP(). If the client is blocked, a 429 Too Many
+// Requests error is returned and true is returned.
+func apiClientBlocked(w http.ResponseWriter, ip string) (blocked bool) {
+	if config.Data().APIFailedAuthThreshold == 0 {
+		return false
+	}
+
+	remaining := apiFailedAuths.blocked(ip)
+	if remaining == 0 {
+		return false
+	}
+
+	p := output.Payload{
+		OK:   false,
+		Type: "tooManyRequests",
+		ErrorData: output.ErrorPayload{
+			Error:   "too many failed api key attempts",
+			Message: "Too many requests with an invalid API key were made from this IP address. Please try again later.",
+		},
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+	output.Send(p, w, http.StatusTooManyRequests)
+	return true
+}
+
+// recordAPIFailedAuth records a failed authentication to the public API from a client.
+// When the client is blocked, the block is logged for security review. The ip must be
+// from clientIP().
+func recordAPIFailedAuth(ip, reason string) {
+	cfg := config.Data()
+	if cfg.APIFailedAuthThreshold == 0 {
+		return
+	}
+
+	window := time.Duration(cfg.APIFailedAuthWindowMinutes) * time.Minute
+	block := time.Duration(cfg.APIFailedAuthBlockMinutes) * time.Minute
+	apiFailedAuths.startSweeper(window)
+	if !apiFailedAuths.fail(ip, cfg.APIFailedAuthThreshold, window, block) {
+		return
+	}
+
+	log.Println("middleware.recordAPIFailedAuth", "WARNING!", "Blocked IP address from the public API.", "IP:", ip, "Failures:", cfg.APIFailedAuthThreshold, "within", window, "Blocked for:", block, "Last failure:", reason)
+}
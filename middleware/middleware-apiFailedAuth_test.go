@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIFailedAuthTracker(t *testing.T) {
+	tr := &apiFailedAuthTracker{
+		visitors: make(map[string]*apiFailedAuthVisitor),
+	}
+
+	//Client is blocked once the threshold is reached, other clients aren't.
+	for i := 0; i < 2; i++ {
+		if tr.fail("203.0.113.5", 3, time.Minute, time.Minute) {
+			t.Fatal("blocked before threshold was reached")
+		}
+	}
+	if !tr.fail("203.0.113.5", 3, time.Minute, time.Minute) {
+		t.Fatal("not blocked when threshold was reached")
+	}
+	if tr.blocked("203.0.113.5") == 0 {
+		t.Fatal("client should be blocked")
+	}
+	if tr.blocked("198.51.100.1") != 0 {
+		t.Fatal("other client should not be blocked")
+	}
+
+	//Sweeping keeps blocked clients and removes clients whose window has ended.
+	tr.fail("198.51.100.1", 3, time.Minute, time.Minute)
+	tr.visitors["198.51.100.1"].windowStart = time.Now().Add(-2 * time.Minute)
+	tr.sweep(time.Minute)
+
+	if _, ok := tr.visitors["198.51.100.1"]; ok {
+		t.Fatal("expired client was not removed")
+	}
+	if _, ok := tr.visitors["203.0.113.5"]; !ok {
+		t.Fatal("blocked client was removed")
+	}
+}
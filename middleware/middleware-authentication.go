@@ -185,7 +185,11 @@ func Auth(next http.Handler) http.Handler {
 
 		//Look up user data and make sure user is still active. An admin could have
 		//marked a user as inactive while a session was still active.
-		cols := sqldb.Columns{db.TableUsers + ".Active"}
+		cols := sqldb.Columns{
+			db.TableUsers + ".Active",
+			db.TableUsers + ".Administrator",
+			db.TableUsers + ".Timezone",
+		}
 		u, err := db.GetUserByID(r.Context(), ul.UserID, cols)
 		if err != nil {
 
@@ -248,6 +252,20 @@ func Auth(next http.Handler) http.Handler {
 			}
 		}
 
+		//Check if an administrator is viewing the app as another user. If so, the
+		//rest of the request is handled as the impersonated user, using the user's
+		//permissions and timezone, with the administrator recorded as well.
+		userID := ul.UserID
+		var impersonatorID int64
+		if ul.ImpersonatingUserID > 0 {
+			target, ok := getImpersonatedUser(r.Context(), ul, u)
+			if ok {
+				userID = target.ID
+				impersonatorID = ul.UserID
+				u.Timezone = target.Timezone
+			}
+		}
+
 		//User and login/session has been validated. Extend the session expiration to
 		//keep user logged in (expiration time resets each time user visits a new page
 		//in the app). Extending the expiration updates the database, our source of
@@ -272,10 +290,54 @@ func Auth(next http.Handler) http.Handler {
 
 		//Save user ID to context for use further in this request. For example, this
 		//is used to save to the activity log when this request is completed.
-		ctx := context.WithValue(r.Context(), users.UserIDContextKey, ul.UserID)
+		ctx := context.WithValue(r.Context(), users.UserIDContextKey, userID)
+		if impersonatorID > 0 {
+			ctx = context.WithValue(ctx, users.ImpersonatorUserIDContextKey, impersonatorID)
+		}
+
+		//Save the user's chosen timezone to context so that dates and times are
+		//displayed in the user's timezone. If the user hasn't chosen a timezone, or the
+		//timezone is invalid, the timezone from the config file is used.
+		if u.Timezone != "" {
+			loc, err := time.LoadLocation(u.Timezone)
+			if err != nil {
+				log.Println("middleware.Auth", "could not load user's timezone, using config timezone", u.Timezone, err)
+			} else {
+				ctx = config.SetLocationInContext(ctx, loc)
+			}
+		}
+
 		r = r.WithContext(ctx)
 
 		//Move to next middleware or handler.
 		next.ServeHTTP(w, r)
 	})
 }
+
+// getImpersonatedUser looks up the user an administrator is viewing the app as and
+// makes sure impersonation is still allowed. Impersonation is ended if the
+// administrator is no longer an administrator, or the impersonated user is no longer
+// active or has become an administrator, so that a stale impersonation cannot be used
+// to access the app. False is returned if the impersonation was ended.
+func getImpersonatedUser(ctx context.Context, ul db.UserLogin, admin db.User) (target db.User, ok bool) {
+	cols := sqldb.Columns{
+		db.TableUsers + ".ID",
+		db.TableUsers + ".Active",
+		db.TableUsers + ".Administrator",
+		db.TableUsers + ".Timezone",
+	}
+	target, err := db.GetUserByID(ctx, ul.ImpersonatingUserID, cols)
+	if err == nil && admin.Administrator && target.Active && !target.Administrator {
+		return target, true
+	}
+
+	//End the impersonation.
+	log.Println("middleware.Auth", "ending impersonation that is no longer allowed", ul.UserID, ul.ImpersonatingUserID, err)
+
+	err = db.SetImpersonatingUser(ctx, ul.ID, 0)
+	if err != nil {
+		log.Println("middleware.Auth", "could not end impersonation", err)
+	}
+
+	return target, false
+}
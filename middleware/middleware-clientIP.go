@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+)
+
+/*
+This file handles determining the IP address of the client making a request. This is
+used for rate limiting, blocking clients with too many failed API key attempts, and
+checking API key IP allowlists, so the IP address must not be settable by a client.
+
+The X-Forwarded-For header can be set by anyone, so it is only used when the request
+came from one of the TrustedProxies in the config file. Each proxy appends the IP
+address it received the request from, so the header is read from right to left, and
+the first IP address that isn't a trusted proxy is the client. Anything to the left
+of that was provided by the client and is ignored.
+*/
+
+// clientIP returns the IP address of the client making a request.
+func clientIP(r *http.Request) (ip string) {
+	return resolveClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"), config.IsTrustedProxy)
+}
+
+// resolveClientIP returns the IP address of the client given the address the request
+// was received from, the X-Forwarded-For headers, and a func to check if an IP
+// address is a trusted proxy. This is separate from clientIP() for testing.
+func resolveClientIP(remoteAddr string, xff []string, trusted func(netip.Addr) bool) (ip string) {
+	ip = remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !trusted(addr) {
+		return ip
+	}
+
+	//Multiple headers are treated as one comma separated list, per RFC 7230.
+	hops := strings.Split(strings.Join(xff, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.Trim(strings.TrimSpace(hops[i]), "[]")
+		if hop == "" {
+			continue
+		}
+
+		hopAddr, err := netip.ParseAddr(hop)
+		if err != nil {
+			//A trusted proxy would not add an invalid IP address, so this was
+			//provided by the client. Use the last IP address we can trust.
+			return ip
+		}
+
+		ip = hopAddr.Unmap().String()
+		if !trusted(hopAddr) {
+			return ip
+		}
+	}
+
+	//Every hop is a trusted proxy, use the leftmost one.
+	return ip
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	//A proxy on the same server and a load balancer in a private network.
+	trusted := func(a netip.Addr) bool {
+		return a.IsLoopback() || netip.MustParsePrefix("10.0.0.0/8").Contains(a)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"no proxy", "203.0.113.5:5000", nil, "203.0.113.5"},
+		{"untrusted client sets xff", "203.0.113.5:5000", []string{"198.51.100.1"}, "203.0.113.5"},
+		{"trusted proxy", "127.0.0.1:5000", []string{"203.0.113.5"}, "203.0.113.5"},
+		{"spoofed leftmost xff", "127.0.0.1:5000", []string{"198.51.100.1, 203.0.113.5"}, "203.0.113.5"},
+		{"spoofed leftmost xff, multiple headers", "127.0.0.1:5000", []string{"198.51.100.1", "203.0.113.5"}, "203.0.113.5"},
+		{"chain of trusted proxies", "127.0.0.1:5000", []string{"198.51.100.1, 203.0.113.5, 10.0.0.2"}, "203.0.113.5"},
+		{"trusted proxy, no xff", "127.0.0.1:5000", nil, "127.0.0.1"},
+		{"invalid hop", "127.0.0.1:5000", []string{"203.0.113.5, not-an-ip"}, "127.0.0.1"},
+		{"all hops trusted", "127.0.0.1:5000", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"ipv6", "[::1]:5000", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		got := resolveClientIP(tt.remoteAddr, tt.xff, trusted)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/c9845/licensekeys/v3/config"
+)
+
+/*
+This file handles Cross-Origin Resource Sharing (CORS) for the public API so that
+browser-based clients on other origins can call the public API. CORS headers are only
+sent when the request's origin is listed in the config file.
+*/
+
+// corsMaxAge is how long, in seconds, a browser can cache the result of a preflight
+// request.
+const corsMaxAge = "600"
+
+// handleCORS sets the CORS headers on the response if the request's origin is
+// allowed. The request's origin is echoed back, rather than "*", since requests
+// include an API key in the Authorization header.
+//
+// True is returned if the request is a preflight request. Preflight requests have
+// been responded to and no further handling should be done.
+func handleCORS(w http.ResponseWriter, r *http.Request) (preflight bool) {
+	preflight = r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	//The response differs based on the request's origin so caches need to know not
+	//to reuse a response for a different origin.
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	allowed := config.Data().APIAllowedOrigins
+	if origin != "" && (slices.Contains(allowed, origin) || slices.Contains(allowed, "*")) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+		}
+	}
+
+	//Preflight requests don't include the API key so they cannot be passed along to
+	//further checks or the handler.
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return
+}
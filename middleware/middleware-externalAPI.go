@@ -10,6 +10,7 @@ import (
 
 	"github.com/c9845/licensekeys/v3/apikeys"
 	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/metrics"
 	"github.com/c9845/output"
 	"github.com/c9845/sqldb/v3"
 )
@@ -33,9 +34,29 @@ var (
 // being made with a valid API key. If the API key is valid, the request is redirected
 // to the next HTTP handler, otherwise an error message is returned.
 //
-// This func should be called upon every publically accessible endpoint.
+// This func should be called upon every publically accessible endpoint. Each endpoint
+// must also accept OPTIONS requests for CORS preflight requests to be handled.
 func ExternalAPI(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Set CORS headers for browser-based clients and respond to preflight
+		//requests. This is done first since preflight requests never include an
+		//API key.
+		if handleCORS(w, r) {
+			return
+		}
+
+		//Record the request for metrics. This is done before checking the API key
+		//so that requests with invalid API keys are counted too.
+		metrics.ExternalAPIRequest(r.URL.Path)
+
+		//Reject requests from clients that made too many requests with an invalid
+		//API key. This is done before looking up anything in the database to reduce
+		//the load a blocked client can cause.
+		ip := clientIP(r)
+		if apiClientBlocked(w, ip) {
+			return
+		}
+
 		//Check if the public API is enabled in the App Settings.
 		as, err := db.GetAppSettings(r.Context())
 		if err != nil {
@@ -110,6 +131,8 @@ func ExternalAPI(next http.Handler) http.Handler {
 			}
 
 			if len(k) != apikeys.KeyLength() {
+				recordAPIFailedAuth(ip, "invalid api key format")
+
 				p := output.Payload{
 					OK: false,
 					// Type: "unauthorized",
@@ -136,6 +159,8 @@ func ExternalAPI(next http.Handler) http.Handler {
 		cols := sqldb.Columns{db.TableAPIKeys + ".*"}
 		keyData, err := db.GetAPIKeyByKey(r.Context(), key, cols)
 		if err == sql.ErrNoRows {
+			recordAPIFailedAuth(ip, "api key does not exist")
+
 			p := output.Payload{
 				OK:   false,
 				Type: "unauthorized",
@@ -154,6 +179,8 @@ func ExternalAPI(next http.Handler) http.Handler {
 
 		//Make sure the API Key is active.
 		if !keyData.Active {
+			recordAPIFailedAuth(ip, "api key inactive")
+
 			p := output.Payload{
 				OK:   false,
 				Type: "unauthorized",
@@ -167,6 +194,25 @@ func ExternalAPI(next http.Handler) http.Handler {
 			return
 		}
 
+		//Make sure the request is from an IP address the API key is allowed to be
+		//used from. A blank list of allowed IPs allows any IP address.
+		if !apikeys.IPAllowed(keyData.AllowedIPs, ip) {
+			recordAPIFailedAuth(ip, "ip address not allowed")
+
+			p := output.Payload{
+				OK:   false,
+				Type: "forbidden",
+				ErrorData: output.ErrorPayload{
+					Error:   "ip address not allowed",
+					Message: "The API key you provided cannot be used from this IP address.",
+				},
+			}
+			output.Send(p, w, http.StatusForbidden)
+
+			log.Println("middleware.ExternalAPI", "API key used from IP address not in allowlist.", "Key:", keyData.Description, "IP:", ip)
+			return
+		}
+
 		//Make sure request is for a valid public endpoint and that the API key has
 		//permission for the endpoint.
 		//
@@ -186,6 +232,11 @@ func ExternalAPI(next http.Handler) http.Handler {
 		case "/api/v1/licenses/download/":
 		case "/api/v1/licenses/renew/":
 		case "/api/v1/licenses/disable/":
+		case "/api/v1/licenses/checkout/":
+		case "/api/v1/licenses/checkin/":
+		case "/api/v1/licenses/activate/":
+		case "/api/v1/licenses/heartbeat/":
+		case "/api/v1/licenses/status-batch/":
 		default:
 			output.Error(errNonPublicEndpoint, "You cannot access this endpoint via the public API.", w)
 			return
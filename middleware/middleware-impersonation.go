@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// ViewOnlyImpersonation rejects requests that make changes, anything other than GET,
+// HEAD, or OPTIONS requests, while an administrator is viewing the app as another
+// user. This prevents an administrator from making changes, or changing credentials,
+// as another user. The only change allowed is ending impersonation.
+//
+// This must be used after Auth, which determines if the request is made while
+// impersonating, and after LogActivity2 so that rejected requests are still recorded
+// in the activity log.
+func ViewOnlyImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if users.GetImpersonatorUserIDFromRequest(r) == 0 || r.URL.Path == users.EndImpersonationPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := output.Payload{
+			OK:   false,
+			Type: "impersonating",
+			ErrorData: output.ErrorPayload{
+				Error:   "impersonating",
+				Message: "Changes cannot be made while viewing the app as another user. Please return to your own account first.",
+			},
+		}
+		output.Send(p, w, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/output"
+)
+
+// maintenanceRetryAfterSeconds is sent in the Retry-After header when a request is
+// rejected because the app is in maintenance mode.
+const maintenanceRetryAfterSeconds = "300"
+
+// Maintenance rejects requests that make changes, anything other than GET, HEAD, or
+// OPTIONS requests, with a 503 status while the app is in maintenance mode. Requests
+// that only read data are always allowed so that the app can still be used to look
+// up licenses during maintenance.
+//
+// This should be applied to each subrouter with endpoints that make changes. Do not
+// apply this to the endpoint used to turn maintenance mode off!
+func Maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !config.MaintenanceMode() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := output.Payload{
+			OK:   false,
+			Type: "maintenance",
+			ErrorData: output.ErrorPayload{
+				Error:   "maintenance mode",
+				Message: "The app is in maintenance mode and changes cannot be made right now. Please try again later.",
+			},
+		}
+		w.Header().Set("Retry-After", maintenanceRetryAfterSeconds)
+		output.Send(p, w, http.StatusServiceUnavailable)
+	})
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+)
+
+// This file handles limiting the size of request bodies and validating the type of
+// request bodies. This prevents a client from sending a huge request body that would
+// be read into memory when handlers call r.FormValue().
+
+// MaxBodySize limits the size of a request's body to the size set in the config file.
+// Requests with a body larger than the limit are rejected with a 413 status.
+//
+// Form values are parsed here, for url encoded forms, so that a request that is too
+// large is rejected here with a clear error, instead of the handler's call to
+// r.FormValue() silently returning blank values.
+func MaxBodySize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Requests without a body don't need to be limited.
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		maxMB := config.Data().MaxRequestBodyMB
+		limit := int64(maxMB) << 20
+		tooLarge := "Request body is too large. The maximum size is " + strconv.Itoa(maxMB) + " MB."
+
+		//Reject early if the size of the body is known.
+		if r.ContentLength > limit {
+			http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/x-www-form-urlencoded" {
+			err := r.ParseForm()
+
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireContentType checks that a request's body is one of the given media types,
+// for example "multipart/form-data". This should be used for endpoints that expect an
+// upload so that unexpected payloads are rejected with a 415 status before the body
+// is read.
+func RequireContentType(mediaTypes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err == nil {
+				for _, m := range mediaTypes {
+					if strings.EqualFold(mediaType, m) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			http.Error(w, "Unsupported content type. Expected one of: "+strings.Join(mediaTypes, ", ")+".", http.StatusUnsupportedMediaType)
+		})
+	}
+}
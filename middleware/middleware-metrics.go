@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/metrics"
+	"github.com/gorilla/mux"
+)
+
+// This file records metrics about each request for exposing to Prometheus. This is
+// separate from the activity log since the activity log can be disabled in the App
+// Settings.
+
+// Metrics records the time taken to respond to a request. Requests are grouped by the
+// route's path template so that routes with path variables (ex.: /verify/{publicID}/)
+// don't create a new group for each request.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timer := time.Now()
+		next.ServeHTTP(w, r)
+
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tmpl, err := cr.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		metrics.ObserveRequestDuration(r.Method, route, time.Since(timer))
+	})
+}
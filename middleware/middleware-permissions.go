@@ -37,11 +37,12 @@ func refuseAccess(w http.ResponseWriter, r *http.Request, permission string, u d
 		return
 	}
 
-	pd := pages.PageData{
-		UserData: u,
-		Data:     msg,
+	ep := pages.ErrorPage{
+		PageTitle:   "Permission Denied",
+		Topic:       msg,
+		ShowLinkBtn: true,
 	}
-	pages.Show(w, "/app/permission-error.html", pd)
+	pages.ShowError(w, r, ep)
 }
 
 // verifyAccessError returns an error when an error occurs when trying to verify a
@@ -131,3 +132,24 @@ func ViewLicenses(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Auditor checks if the user has this permission. Administrators are always allowed
+// since an administrator can already view and change everything an auditor can view.
+func Auditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const p = "Auditor"
+
+		u, err := users.GetUserDataFromRequest(r)
+		if err != nil {
+			verifyAccessError(w, r, p, err)
+			return
+		}
+
+		if !u.Auditor && !u.Administrator {
+			refuseAccess(w, r, p, u)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
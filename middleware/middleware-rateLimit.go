@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+This file handles limiting the number of requests a client can make to an endpoint
+within a period of time. This is used for public, non-authenticated, endpoints to
+prevent brute forcing or enumeration of data.
+
+Requests are tracked in memory, per client IP address, using a fixed window. This is
+simple, and is not shared between multiple instances of this app, but this app is
+designed to run as a single instance anyway. The client IP address is determined by
+clientIP() so that a client cannot avoid the limit by setting the X-Forwarded-For
+header.
+
+Visitors whose window has ended are removed periodically, not on each request, so
+that a flood of requests from many IP addresses doesn't make each request slower.
+*/
+
+// rateLimitVisitor tracks the requests a client has made within the current window.
+type rateLimitVisitor struct {
+	windowStart time.Time
+	count       int
+}
+
+// rateLimiter tracks the requests made by each client.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	visitors map[string]*rateLimitVisitor
+}
+
+// allow returns true if the client has not exceeded the limit for the current
+// window.
+func (rl *rateLimiter) allow(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+
+	v, ok := rl.visitors[ip]
+	if !ok || now.Sub(v.windowStart) > rl.window {
+		rl.visitors[ip] = &rateLimitVisitor{
+			windowStart: now,
+			count:       1,
+		}
+		return true
+	}
+
+	v.count++
+	return v.count <= rl.limit
+}
+
+// sweep removes visitors whose window has ended so the map doesn't grow forever.
+func (rl *rateLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	for k, v := range rl.visitors {
+		if now.Sub(v.windowStart) > rl.window {
+			delete(rl.visitors, k)
+		}
+	}
+}
+
+// startSweeper removes old visitors on a ticker.
+//
+// This should be called in a goroutine since it never returns.
+func (rl *rateLimiter) startSweeper() {
+	ticker := time.NewTicker(rl.window)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.sweep()
+	}
+}
+
+// RateLimit limits the number of requests a client can make within the window. When
+// the limit is exceeded, a 429 Too Many Requests error is returned.
+//
+// Each call to RateLimit tracks requests separately, so you should call this once per
+// group of endpoints to limit.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	rl := &rateLimiter{
+		limit:    limit,
+		window:   window,
+		visitors: make(map[string]*rateLimitVisitor),
+	}
+	go rl.startSweeper()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rl.allow(clientIP(r)) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(window.Seconds())))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := &rateLimiter{
+		limit:    2,
+		window:   time.Minute,
+		visitors: make(map[string]*rateLimitVisitor),
+	}
+
+	if !rl.allow("203.0.113.5") || !rl.allow("203.0.113.5") {
+		t.Fatal("requests within limit were not allowed")
+	}
+	if rl.allow("203.0.113.5") {
+		t.Fatal("request over limit was allowed")
+	}
+	if !rl.allow("198.51.100.1") {
+		t.Fatal("other client was limited")
+	}
+
+	//A new window starts once the old window ends, even before the visitor is swept.
+	rl.visitors["203.0.113.5"].windowStart = time.Now().Add(-2 * time.Minute)
+	if !rl.allow("203.0.113.5") {
+		t.Fatal("request in new window was not allowed")
+	}
+
+	rl.visitors["198.51.100.1"].windowStart = time.Now().Add(-2 * time.Minute)
+	rl.sweep()
+	if _, ok := rl.visitors["198.51.100.1"]; ok {
+		t.Fatal("expired visitor was not removed")
+	}
+	if _, ok := rl.visitors["203.0.113.5"]; !ok {
+		t.Fatal("current visitor was removed")
+	}
+}
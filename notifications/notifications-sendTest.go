@@ -0,0 +1,57 @@
+package notifications
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/output"
+)
+
+// This file handles posting a test message to the webhook so that an administrator
+// can confirm notifications are configured correctly without waiting for an event.
+
+// TestResult is the result of posting a test message. This is returned to the GUI
+// for troubleshooting. The webhook URL is never included since it typically
+// contains a secret token.
+type TestResult struct {
+	OK         bool   //true if the webhook responded with a 2xx status code.
+	StatusCode int    //status code returned by the webhook, 0 if no response was received.
+	Message    string //response body from the webhook, or the error if no response was received.
+}
+
+// SendTest posts a test message to the webhook set in the config file and returns
+// the webhook's response. The message is posted immediately, not queued, and only
+// once so that the result can be shown to the administrator. The message is posted
+// regardless of the events enabled in the config file.
+//
+// A failure to post the message is not returned as an error since the response is
+// what the administrator needs to troubleshoot the webhook.
+func SendTest(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Data()
+	if cfg.NotificationWebhookURL == "" {
+		output.ErrorInputInvalid("Notifications are not enabled. Set NotificationWebhookURL in the config file.", w)
+		return
+	}
+
+	m := message{
+		Text: fmt.Sprintf(
+			"Test notification\nThis is a test message to confirm notifications are configured correctly.\nAt: %s",
+			time.Now().UTC().Format(time.RFC3339),
+		),
+	}
+
+	status, body, err := postWithResponse(cfg.NotificationWebhookURL, m)
+	if err != nil {
+		output.DataFound(TestResult{Message: err.Error()}, w)
+		return
+	}
+
+	result := TestResult{
+		OK:         status >= 200 && status <= 299,
+		StatusCode: status,
+		Message:    body,
+	}
+	output.DataFound(result, w)
+}
@@ -0,0 +1,213 @@
+/*
+Package notifications handles posting messages to a chat channel, via an incoming
+webhook, when certain events occur. For example, when a license is created. The
+webhook URL and the events that cause a message to be posted are set in the config
+file.
+
+The message body is a JSON object with a "text" field which is supported by both
+Slack and Microsoft Teams incoming webhooks.
+
+Messages are queued and posted in the background so that a slow, or down, webhook
+does not slow down or break the request that caused the event. Messages that cannot
+be posted after a few attempts are logged and dropped.
+*/
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/sqldb/v3"
+)
+
+// Titles of each event, used as the first line of each message.
+var eventTitles = map[string]string{
+	config.NotificationEventLicenseCreated:  "License created",
+	config.NotificationEventLicenseDisabled: "License disabled",
+	config.NotificationEventAdminUserAdded:  "Administrator user added",
+	config.NotificationEventFailedLogins:    "Too many failed logins",
+}
+
+// queueSize is the number of messages that can be waiting to be posted. Messages are
+// dropped when the queue is full, for example when the webhook has been down for a
+// while, so that memory use doesn't grow unbounded.
+const queueSize = 100
+
+// Delivery settings.
+const (
+	postTimeout  = 10 * time.Second
+	postAttempts = 3
+	retryDelay   = 5 * time.Second //multiplied by the attempt number.
+
+	//maxResponseBodyLength is the most of a webhook's response that is read. This is
+	//only used for troubleshooting so the full response is not needed.
+	maxResponseBodyLength = 500
+
+	//maintenanceCheckInterval is how often to check if maintenance mode was turned
+	//off while messages are being held.
+	maintenanceCheckInterval = time.Minute
+)
+
+// queue holds messages waiting to be posted by StartSender().
+var queue = make(chan message, queueSize)
+
+// httpClient is used to post messages. A timeout is set so that a webhook that never
+// responds doesn't block the sender forever.
+var httpClient = &http.Client{Timeout: postTimeout}
+
+// message is the body posted to the webhook.
+type message struct {
+	Text string `json:"text"`
+}
+
+// Notify queues a message about an event to be posted to the webhook. The actor is
+// the user or API key that caused the event, only one ID should be provided. The
+// target describes what the event affected, for example a license or user.
+//
+// This returns immediately and never returns an error since notifications are not
+// critical. Nothing is done if notifications are disabled or the event is not enabled
+// in the config file.
+func Notify(ctx context.Context, event string, userID, apiKeyID int64, target string) {
+	cfg := config.Data()
+	if cfg.NotificationWebhookURL == "" || !slices.Contains(cfg.NotificationEvents, event) {
+		return
+	}
+
+	title, ok := eventTitles[event]
+	if !ok {
+		title = event
+	}
+
+	m := message{
+		Text: fmt.Sprintf(
+			"%s\nBy: %s\nTarget: %s\nAt: %s",
+			title,
+			actor(ctx, userID, apiKeyID),
+			target,
+			time.Now().UTC().Format(time.RFC3339),
+		),
+	}
+
+	select {
+	case queue <- m:
+	default:
+		log.Println("notifications.Notify", "queue is full, dropping notification for event", event)
+	}
+}
+
+// actor returns a description of the user or API key that caused an event. A generic
+// description is returned if the user or API key cannot be looked up since a
+// notification is still useful without knowing the actor.
+func actor(ctx context.Context, userID, apiKeyID int64) string {
+	if userID > 0 {
+		u, err := db.GetUserByID(ctx, userID, sqldb.Columns{"Username"})
+		if err != nil {
+			return fmt.Sprintf("user ID %d", userID)
+		}
+
+		return u.Username
+	}
+
+	if apiKeyID > 0 {
+		k, err := db.GetAPIKeyByID(ctx, apiKeyID, sqldb.Columns{"Description"})
+		if err != nil {
+			return fmt.Sprintf("API key ID %d", apiKeyID)
+		}
+
+		return "API key: " + k.Description
+	}
+
+	return "unknown"
+}
+
+// StartSender posts queued messages to the webhook. Each message is posted a few
+// times, with a delay between attempts, before it is logged and dropped. This does
+// nothing if notifications are disabled.
+//
+// This should be called in a goroutine since it never returns when notifications are
+// enabled.
+func StartSender() {
+	cfg := config.Data()
+	if cfg.NotificationWebhookURL == "" {
+		return
+	}
+
+	log.Println("Posting notifications for events:", cfg.NotificationEvents)
+
+	for m := range queue {
+		//Hold messages while the app is in maintenance mode. Messages queued in the
+		//meantime are dropped once the queue is full, the same as when the webhook
+		//is down.
+		for config.MaintenanceMode() {
+			time.Sleep(maintenanceCheckInterval)
+		}
+
+		var err error
+		for attempt := 1; attempt <= postAttempts; attempt++ {
+			err = post(cfg.NotificationWebhookURL, m)
+			if err == nil {
+				break
+			}
+
+			if attempt < postAttempts {
+				time.Sleep(time.Duration(attempt) * retryDelay)
+			}
+		}
+		if err != nil {
+			log.Println("notifications.StartSender", "could not post notification", err)
+		}
+	}
+}
+
+// post sends a message to the webhook.
+func post(url string, m message) (err error) {
+	status, _, err := postWithResponse(url, m)
+	if err != nil {
+		return
+	}
+
+	if status < 200 || status > 299 {
+		return fmt.Errorf("unexpected response status %d %s", status, http.StatusText(status))
+	}
+
+	return
+}
+
+// postWithResponse sends a message to the webhook and returns the status code and,
+// truncated, body of the response. The webhook URL is removed from any returned
+// error since the URL typically contains a secret token and errors are logged and
+// may be shown in the GUI.
+func postWithResponse(webhookURL string, m message) (status int, respBody string, err error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+
+	resp, err := httpClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLength))
+	if err != nil {
+		return
+	}
+
+	return resp.StatusCode, string(b), nil
+}
@@ -0,0 +1,42 @@
+package pages
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/keypairs"
+)
+
+//This file specifically handles the apps page. This functionality was broken out
+//into a separate file to handle the key pair algorithms allowed per the config file.
+
+// Apps shows the page to manage apps and their key pairs.
+//
+// This does not use Page() since we need to get the key pair algorithms that can be
+// used to build the GUI with.
+func Apps(w http.ResponseWriter, r *http.Request) {
+	//Get data to build gui.
+	pd, err := getPageConfigData(r)
+	if err != nil {
+		log.Println("Error getting page config data", err)
+		return
+	}
+
+	//Get the algorithms that can be chosen when generating a key pair. This is
+	//encoded as JSON so that it can be read client side.
+	algorithms, err := json.Marshal(keypairs.AllowedAlgorithms())
+	if err != nil {
+		log.Println("Error encoding key pair algorithms", err)
+		return
+	}
+
+	data := struct {
+		KeyPairAlgorithms       string
+		DefaultKeyPairAlgorithm string
+	}{string(algorithms), string(keypairs.DefaultAlgorithm())}
+	pd.Data = data
+
+	//Show page.
+	Show(w, "/app/licensing/apps.html", pd)
+}
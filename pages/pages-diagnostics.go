@@ -75,17 +75,74 @@ func Diagnostics(w http.ResponseWriter, r *http.Request) {
 
 	d.set("DBPath", cfg.DBPath)
 	d.set("DBJournalMode", cfg.DBJournalMode)
+	d.set("AppSettingsCacheSeconds", cfg.AppSettingsCacheSeconds)
+	d.set("BackupPath", cfg.BackupPath)
+	d.set("BackupIntervalHours", cfg.BackupIntervalHours)
+	d.set("BackupRetentionDays", cfg.BackupRetentionDays)
 
 	d.set("WebFilesStore", cfg.WebFilesStore)
 	d.set("WebFilesPath", cfg.WebFilesPath)
 	d.set("UseLocalFiles", cfg.UseLocalFiles)
 	d.set("Port", cfg.Port)
+	d.set("BaseURLPath", cfg.BaseURLPath)
+	d.set("ExternalURL", cfg.ExternalURL)
+	d.set("MaxRequestBodyMB", cfg.MaxRequestBodyMB)
+	d.set("TrustedProxies", cfg.TrustedProxies)
+	d.set("MaintenanceMode", cfg.MaintenanceMode)
+	d.set("MaintenanceMode (current)", config.MaintenanceMode())
+	d.set("ReadHeaderTimeoutSeconds", cfg.ReadHeaderTimeoutSeconds)
+	d.set("ReadTimeoutSeconds", cfg.ReadTimeoutSeconds)
+	d.set("WriteTimeoutSeconds", cfg.WriteTimeoutSeconds)
+	d.set("IdleTimeoutSeconds", cfg.IdleTimeoutSeconds)
+	d.set("TLSCertPath", cfg.TLSCertPath)
+	d.set("TLSKeyPath", cfg.TLSKeyPath)
+	d.set("TLSRedirectPort", cfg.TLSRedirectPort)
+	d.set("CookieSecure", cfg.CookieSecure)
+	d.set("CookieSecure (enabled)", cfg.CookieSecureEnabled())
+	d.set("CookieSameSite", cfg.CookieSameSite)
+	d.set("APIAllowedOrigins", cfg.APIAllowedOrigins)
+	d.set("APIIdempotencyKeyRetentionHours", cfg.APIIdempotencyKeyRetentionHours)
+	d.set("APIFailedAuthThreshold", cfg.APIFailedAuthThreshold)
+	d.set("APIFailedAuthWindowMinutes", cfg.APIFailedAuthWindowMinutes)
+	d.set("APIFailedAuthBlockMinutes", cfg.APIFailedAuthBlockMinutes)
+	d.set("LicenseSeatsFieldName", cfg.LicenseSeatsFieldName)
+	d.set("LicenseSeatLeaseMinutes", cfg.LicenseSeatLeaseMinutes)
+	d.set("LicenseActivationsFieldName", cfg.LicenseActivationsFieldName)
+	d.set("LicenseNoteCategories", cfg.LicenseNoteCategories)
+	d.set("SigningConcurrency", cfg.SigningConcurrency)
+	d.set("MaxLicenseDurationDays", cfg.MaxLicenseDurationDays)
+	d.set("DownloadLinkLifetimeHours", cfg.DownloadLinkLifetimeHours)
+	d.set("AttachmentsPath", cfg.AttachmentsPath)
+	d.set("AttachmentMaxSizeMB", cfg.AttachmentMaxSizeMB)
+	d.set("AttachmentContentTypes", cfg.AttachmentContentTypes)
+	d.set("NotificationWebhookURL (set)", cfg.NotificationWebhookURL != "")
+	d.set("NotificationEvents", cfg.NotificationEvents)
+	d.set("SMTPHost", cfg.SMTPHost)
+	d.set("SMTPPort", cfg.SMTPPort)
+	d.set("SMTPUsername", cfg.SMTPUsername)
+	d.set("SMTPPassword (set)", cfg.SMTPPassword != "")
+	d.set("SMTPFrom", cfg.SMTPFrom)
+	d.set("ActivityReportIntervalDays", cfg.ActivityReportIntervalDays)
+	d.set("ActivityReportRecipients", cfg.ActivityReportRecipients)
+
+	d.set("InitialUserUsername", cfg.InitialUserUsername)
+	d.set("AllowedUserEmailDomains", cfg.AllowedUserEmailDomains)
 
 	d.set("LoginLifetimeHours", cfg.LoginLifetimeHours)
 	d.set("TwoFactorAuthLifetimeDays", cfg.TwoFactorAuthLifetimeDays)
+	d.set("UserLoginRetentionDays", cfg.UserLoginRetentionDays)
+
+	d.set("TwoFactorAuthDigits", cfg.TwoFactorAuthDigits)
+	d.set("TwoFactorAuthPeriodSeconds", cfg.TwoFactorAuthPeriodSeconds)
+	d.set("TwoFactorAuthAlgorithm", cfg.TwoFactorAuthAlgorithm)
+	d.set("PasskeyRPID", cfg.PasskeyRPID)
+
+	d.set("FailedLoginAlertThreshold", cfg.FailedLoginAlertThreshold)
+	d.set("FailedLoginAlertWindowMinutes", cfg.FailedLoginAlertWindowMinutes)
 
 	//timezone is in TIMEZONE section below
 	d.set("MinPasswordLength", cfg.MinPasswordLength)
+	d.set("MinimumKeyPairAlgorithm", cfg.MinimumKeyPairAlgorithm)
 
 	//Database diagnostics...
 	d.set("**DB Diagnostics**", "******************************")
@@ -0,0 +1,32 @@
+package pages
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/license"
+)
+
+//This file specifically handles downloading a license via a signed download link.
+//This is accessible without logging in so that customers can download their license
+//from a link that was emailed to them.
+
+// DownloadLink serves a license file via a signed download link. If the link is
+// invalid or expired, an error page is shown.
+func DownloadLink(w http.ResponseWriter, r *http.Request) {
+	errMsg, err := license.ServeDownloadLink(w, r)
+	if err != nil {
+		log.Println("pages.DownloadLink", "could not serve license via download link", err)
+	}
+	if errMsg == "" {
+		return
+	}
+
+	e := ErrorPage{
+		PageTitle: "Download License",
+		Topic:     "This license could not be downloaded.",
+		Message:   errMsg,
+		Solution:  "Please contact the person who sent you this link.",
+	}
+	ShowError(w, r, e)
+}
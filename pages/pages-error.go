@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
 )
 
@@ -43,6 +44,8 @@ func ShowError(w http.ResponseWriter, r *http.Request, ep ErrorPage) {
 	pd := PageData{
 		AppSettings: as,
 		Data:        ep,
+
+		MaintenanceMode: config.MaintenanceMode(),
 	}
 
 	//Show the page.
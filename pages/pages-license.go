@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/sqldb/v3"
 )
@@ -64,10 +65,15 @@ func License(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Send back the license ID to embed in HTML hidden input so it can be read
-	//by Vue to use in api calls.
+	//by Vue to use in api calls. The license note categories are used to build the
+	//options for choosing and filtering notes by category.
 	//
-	//TODO: remove this, just get license ID from URL via JS.
-	pd.Data = licenseID
+	//TODO: remove the license ID, just get license ID from URL via JS.
+	data := struct {
+		LicenseID             int64
+		LicenseNoteCategories []string
+	}{licenseID, config.Data().LicenseNoteCategories}
+	pd.Data = data
 
 	//show page
 	Show(w, "/app/licensing/license.html", pd)
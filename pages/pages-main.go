@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/sqldb/v3"
 )
@@ -25,23 +26,76 @@ func Main(w http.ResponseWriter, r *http.Request) {
 	//Check if default admin user is enabled. This user should not be enable since
 	//the password is set to a default value when app is deployed. This password
 	//should be changed, user should be disabled, and all permissions should be
-	//turned off. Display warning for users to do this.
-	cols := sqldb.Columns{db.TableUsers + ".Active"}
-	u, err := db.GetUserByUsername(r.Context(), db.InitialUserUsername, cols)
-	if err != sql.ErrNoRows && err != nil {
-		log.Println("pages.Main", "could not look up default initial user to verify user is disabled, ignoring error", err)
-		//ignore error since this isn't the end of the world, plus we told user in docs and install to disable this user
-		//u.Active will be false (the default value) so warning won't be shown in gui
+	//turned off. Display warning for users to do this. The initial user's username
+	//may have been set in the config file, however the default username is always
+	//checked as well since the config file could have been changed after the app
+	//was deployed.
+	cols := sqldb.Columns{
+		db.TableUsers + ".Active",
+		db.TableUsers + ".Username",
+		db.TableUsers + ".Administrator",
+		db.TableUsers + ".CreateLicenses",
+		db.TableUsers + ".ViewLicenses",
 	}
+	usernames := []string{config.Data().InitialUserUsername}
+	if config.Data().InitialUserUsername != config.DefaultInitialUserUsername {
+		usernames = append(usernames, config.DefaultInitialUserUsername)
+	}
+
+	var initialUser db.User
+	for _, username := range usernames {
+		u, err := db.GetUserByUsername(r.Context(), username, cols)
+		if err != sql.ErrNoRows && err != nil {
+			log.Println("pages.Main", "could not look up default initial user to verify user is disabled, ignoring error", err)
+			//ignore error since this isn't the end of the world, plus we told user in docs and install to disable this user
+			//u.Active will be false (the default value) so warning won't be shown in gui
+			continue
+		}
+
+		//Use the first active user found, preferring the unchanged default user
+		//since its warning cannot be dismissed.
+		if isUnchangedDefaultInitialUser(u) || (u.Active && !initialUser.Active) {
+			initialUser = u
+		}
+	}
+
+	//The warning can be dismissed, via app settings, by admins who intentionally
+	//kept the initial user. The warning is always shown if the initial user is still
+	//the unchanged default user since that is a real security issue that should not
+	//be hidden.
+	var dismissed bool
+	as, ok := pd.AppSettings.(db.AppSettings)
+	if ok {
+		dismissed = as.DismissInitialUserWarning
+	}
+
+	isUnchangedDefault := isUnchangedDefaultInitialUser(initialUser)
+	showWarning := initialUser.Active && (!dismissed || isUnchangedDefault)
 
 	//Build data to return.
 	data := struct {
-		IsInitialDefaultUserActive bool
+		IsInitialDefaultUserActive   bool
+		InitialUserUsername          string
+		CanDismissInitialUserWarning bool
 	}{
-		IsInitialDefaultUserActive: u.Active,
+		IsInitialDefaultUserActive:   showWarning,
+		InitialUserUsername:          initialUser.Username,
+		CanDismissInitialUserWarning: !isUnchangedDefault,
 	}
 	pd.Data = data
 
 	//Serve the HTML template.
 	Show(w, "/app.html", pd)
 }
+
+// isUnchangedDefaultInitialUser returns true if a user is the initial user created
+// with the default username and still has the permissions it was created with. The
+// Auditor permission is not checked since it was added after the initial user was
+// created in older installs.
+func isUnchangedDefaultInitialUser(u db.User) bool {
+	return u.Active &&
+		u.Username == config.DefaultInitialUserUsername &&
+		u.Administrator &&
+		u.CreateLicenses &&
+		u.ViewLicenses
+}
@@ -17,6 +17,7 @@ import (
 // templates. This var will be used in Func() when templates.Parse... is called.
 var funcMap = template.FuncMap{
 	"static": static,
+	"url":    url,
 }
 
 // static is used to translate filenames from a standard "styles.min.css" to the
@@ -35,5 +36,17 @@ func static(originalPath string) string {
 
 	//Have to prefix the path with "/static/" since it was stripped to look up the
 	//file in the hashfs but the path expected by this app's server starts with /static/.
-	return path.Join("/", "static", hashFSPath)
+	//
+	//The base URL path is added so the file can be found when the app is served at a
+	//subpath behind a proxy.
+	return path.Join("/", cfg.BaseURLPath, "static", hashFSPath)
+}
+
+// url is used to prefix a path to a page, or API endpoint, within this app with the
+// base URL path from the config file. This is needed when the app is served at a
+// subpath behind a proxy, otherwise links would point at the proxy's root.
+//
+// Use as {{url "/app/"}}.
+func url(p string) string {
+	return cfg.BaseURLPath + p
 }
@@ -0,0 +1,47 @@
+package pages
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/c9845/licensekeys/v3/license"
+	"github.com/c9845/licensekeys/v3/metrics"
+	"github.com/gorilla/mux"
+)
+
+//This file specifically handles the public license verification page. This page is
+//accessible without logging in so that customers can check the status of their
+//license. Only non-sensitive data about a license is shown.
+
+// Verify shows the status of a license looked up by its public ID.
+func Verify(w http.ResponseWriter, r *http.Request) {
+	publicID := mux.Vars(r)["publicID"]
+
+	s, err := license.GetPublicStatus(r.Context(), publicID)
+	if err != nil {
+		log.Println("pages.Verify", "could not look up license status", err)
+
+		e := ErrorPage{
+			PageTitle: "Verify License",
+			Topic:     "An error occured while looking up this license's status.",
+			Solution:  "Please try again later.",
+		}
+		ShowError(w, r, e)
+		return
+	}
+
+	//Record the verification for metrics.
+	switch {
+	case !s.Found:
+		metrics.LicenseVerified(metrics.VerifyResultNotFound)
+	case s.ValidSignature && s.Active && !s.Expired:
+		metrics.LicenseVerified(metrics.VerifyResultValid)
+	default:
+		metrics.LicenseVerified(metrics.VerifyResultInvalid)
+	}
+
+	pd := PageData{
+		Data: s,
+	}
+	Show(w, "/verify.html", pd)
+}
@@ -24,8 +24,10 @@ import (
 	"strings"
 
 	"github.com/c9845/hashfs"
+	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
 	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/sqldb/v3"
 )
 
 // Config is the set of configuration settings for working with HTML templates. This
@@ -47,6 +49,13 @@ type Config struct {
 	//This is typically set via config file field.
 	UseLocalFiles bool
 
+	//BaseURLPath is prepended to the paths of links and static files in templates
+	//when the app is served at a subpath behind a proxy. This is blank when the app
+	//is served at the root. See the url() and static() template funcs.
+	//
+	//This is typically set via config file field.
+	BaseURLPath string
+
 	//Extension is the extension you use for your template files. The default is
 	//".html".
 	Extension string
@@ -200,10 +209,12 @@ func Show(w http.ResponseWriter, templateName string, injectedData any) {
 	data := struct {
 		Development   bool
 		UseLocalFiles bool
+		BaseURLPath   string
 		InjectedData  any
 	}{
 		Development:   cfg.Development,
 		UseLocalFiles: cfg.UseLocalFiles,
+		BaseURLPath:   cfg.BaseURLPath,
 		InjectedData:  injectedData,
 	}
 
@@ -310,6 +321,12 @@ type PageData struct {
 	UserData    any //username, permissions, etc.
 	AppSettings any //whether certain features are used or enabled.
 	Data        any //misc. actual data we want to show in the gui.
+
+	MaintenanceMode bool //whether the app is in maintenance mode, shown in the header.
+
+	//The username of the administrator viewing the app as the user in UserData,
+	//shown in a banner. Blank if the administrator is not impersonating a user.
+	ImpersonatorUsername string
 }
 
 // getPageConfigData gets the common data needed to build pages. This retrieves the
@@ -337,8 +354,20 @@ func getPageConfigData(r *http.Request) (pd PageData, err error) {
 		return
 	}
 
+	//Get the administrator viewing the app as this user, if any.
+	var impersonator db.User
+	if impersonatorID := users.GetImpersonatorUserIDFromRequest(r); impersonatorID > 0 {
+		impersonator, err = db.GetUserByID(r.Context(), impersonatorID, sqldb.Columns{db.TableUsers + ".Username"})
+		if err != nil {
+			log.Println("pages.getPageConfigData", "Could not look up impersonating administrator.", err)
+			return
+		}
+	}
+
 	//Save data to build page.
 	pd.UserData = u
 	pd.AppSettings = as
+	pd.MaintenanceMode = config.MaintenanceMode()
+	pd.ImpersonatorUsername = impersonator.Username
 	return
 }
@@ -0,0 +1,82 @@
+package reports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/users"
+	"github.com/c9845/output"
+)
+
+// This file handles sending a test email so that an administrator can confirm the
+// SMTP settings are correct without waiting for a report to be sent.
+
+// TestResult is the result of sending a test email. This is returned to the GUI for
+// troubleshooting. The SMTP password is never included.
+type TestResult struct {
+	OK         bool   //true if the email was accepted by the SMTP server.
+	To         string //who the email was sent to.
+	StatusCode int    //SMTP reply code if the server returned an error, 0 otherwise.
+	Message    string //error returned by the SMTP server or while connecting.
+}
+
+// SendTestEmail sends a test email, using the SMTP settings from the config file, to
+// the logged in user. The user's username is their email address.
+//
+// A failure to send the email is not returned as an error since the error from the
+// SMTP server is what the administrator needs to troubleshoot the settings.
+func SendTestEmail(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Data()
+	if !cfg.SMTPEnabled() {
+		output.ErrorInputInvalid("Email is not enabled. Set SMTPHost in the config file.", w)
+		return
+	}
+
+	u, err := users.GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	result := TestResult{
+		To: u.Username,
+	}
+
+	msg := testMessage(cfg.SMTPFrom, u.Username)
+	err = Send(cfg, []string{u.Username}, msg)
+	if err != nil {
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) {
+			result.StatusCode = tpErr.Code
+			result.Message = tpErr.Msg
+		} else {
+			result.Message = err.Error()
+		}
+
+		output.DataFound(result, w)
+		return
+	}
+
+	result.OK = true
+	output.DataFound(result, w)
+}
+
+// testMessage builds a plain text test email ready to be sent.
+func testMessage(from, to string) []byte {
+	var m bytes.Buffer
+	fmt.Fprintf(&m, "From: %s\r\n", from)
+	fmt.Fprintf(&m, "To: %s\r\n", to)
+	fmt.Fprintf(&m, "Subject: Test email\r\n")
+	fmt.Fprintf(&m, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&m, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&m, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(&m, "\r\n")
+	fmt.Fprintf(&m, "This is a test email to confirm the SMTP settings are configured correctly.\r\n")
+
+	return m.Bytes()
+}
@@ -0,0 +1,254 @@
+/*
+Package reports handles emailing a periodic report of license activity so that
+management can keep track of licensing without logging in to the app. The report
+lists, for each app, the licenses created, renewed, and disabled since the previous
+report, the licenses expiring soon, and the total active licenses.
+
+The interval and recipients are set in the config file. Email is sent using the SMTP
+settings in the config file; the report is skipped if email is not configured.
+*/
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+)
+
+// expiringSoonDays is the number of days from when the report is built that a
+// license is considered to be expiring soon.
+const expiringSoonDays = 30
+
+// report is the data used to build the email.
+type report struct {
+	Since      time.Time //start of the period activity is counted from.
+	Until      time.Time //when the report was built.
+	ExpiringBy string    //YYYY-MM-DD.
+	Apps       []db.LicenseActivity
+	Totals     db.LicenseActivity
+}
+
+// StartScheduler emails a report of license activity on the interval set in the
+// config file. The first report is sent one interval after the app starts so that
+// restarting the app does not send extra reports. This does nothing if the report is
+// disabled.
+//
+// This should be called in a goroutine since it never returns when the report is
+// enabled.
+func StartScheduler() {
+	cfg := config.Data()
+	if cfg.ActivityReportIntervalDays <= 0 {
+		return
+	}
+
+	log.Printf("Emailing license activity report every %d days to: %s", cfg.ActivityReportIntervalDays, strings.Join(cfg.ActivityReportRecipients, ", "))
+
+	interval := time.Duration(cfg.ActivityReportIntervalDays) * 24 * time.Hour
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runScheduled(cfg.ActivityReportIntervalDays)
+	}
+}
+
+// runScheduled builds and emails the report. Errors are logged, not returned, since
+// there is nothing to return them to and we want the next scheduled report to still
+// be sent.
+func runScheduled(intervalDays int) {
+	//Skip while the app is in maintenance mode.
+	if config.MaintenanceMode() {
+		log.Println("reports.runScheduled", "skipped since app is in maintenance mode")
+		return
+	}
+
+	cfg := config.Data()
+	if !cfg.SMTPEnabled() {
+		log.Println("WARNING! reports.runScheduled", "SMTPHost is not set in the config file, skipping license activity report.")
+		return
+	}
+
+	rpt, err := build(context.Background(), intervalDays)
+	if err != nil {
+		log.Println("reports.runScheduled", "could not build license activity report", err)
+		return
+	}
+
+	msg, err := rpt.message(cfg.SMTPFrom, cfg.ActivityReportRecipients)
+	if err != nil {
+		log.Println("reports.runScheduled", "could not build license activity report email", err)
+		return
+	}
+
+	err = Send(cfg, cfg.ActivityReportRecipients, msg)
+	if err != nil {
+		log.Println("reports.runScheduled", "could not send license activity report", err)
+		return
+	}
+
+	log.Println("reports.runScheduled", "license activity report sent")
+}
+
+// build looks up the license activity for the period ending now.
+func build(ctx context.Context, intervalDays int) (rpt report, err error) {
+	now := time.Now().UTC()
+	rpt = report{
+		Since:      now.AddDate(0, 0, -intervalDays),
+		Until:      now,
+		ExpiringBy: now.AddDate(0, 0, expiringSoonDays).Format("2006-01-02"),
+	}
+
+	rpt.Apps, err = db.GetLicenseActivityByApp(ctx, rpt.Since.Format("2006-01-02 15:04:05"), rpt.ExpiringBy)
+	if err != nil {
+		return
+	}
+
+	rpt.Totals.AppName = "Total"
+	for _, a := range rpt.Apps {
+		rpt.Totals.Created += a.Created
+		rpt.Totals.Renewed += a.Renewed
+		rpt.Totals.Disabled += a.Disabled
+		rpt.Totals.ExpiringSoon += a.ExpiringSoon
+		rpt.Totals.Active += a.Active
+	}
+
+	return
+}
+
+// subject returns the subject line for the email.
+func (rpt report) subject() string {
+	return fmt.Sprintf("License activity report, %s to %s", rpt.Since.Format("2006-01-02"), rpt.Until.Format("2006-01-02"))
+}
+
+// text renders the report as plain text.
+func (rpt report) text() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, rpt.subject())
+	fmt.Fprintf(&b, "Expiring soon is licenses expiring on or before %s.\n\n", rpt.ExpiringBy)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "App\tCreated\tRenewed\tDisabled\tExpiring Soon\tActive")
+	for _, a := range slices.Concat(rpt.Apps, []db.LicenseActivity{rpt.Totals}) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", a.AppName, a.Created, a.Renewed, a.Disabled, a.ExpiringSoon, a.Active)
+	}
+	tw.Flush()
+
+	return b.String()
+}
+
+// htmlTemplate is used to render the report as HTML. Styles are inline since many
+// email clients ignore <style> elements.
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("report").Parse(`<!DOCTYPE html>
+<html>
+	<body style="font-family: sans-serif;">
+		<h3>License activity report, {{.Since.Format "2006-01-02"}} to {{.Until.Format "2006-01-02"}}</h3>
+		<p>Expiring soon is licenses expiring on or before {{.ExpiringBy}}.</p>
+		<table style="border-collapse: collapse;" cellpadding="4" border="1">
+			<thead>
+				<tr><th>App</th><th>Created</th><th>Renewed</th><th>Disabled</th><th>Expiring Soon</th><th>Active</th></tr>
+			</thead>
+			<tbody>
+				{{range .Apps}}
+				<tr><td>{{.AppName}}</td><td>{{.Created}}</td><td>{{.Renewed}}</td><td>{{.Disabled}}</td><td>{{.ExpiringSoon}}</td><td>{{.Active}}</td></tr>
+				{{end}}
+				{{with .Totals}}
+				<tr style="font-weight: bold;"><td>{{.AppName}}</td><td>{{.Created}}</td><td>{{.Renewed}}</td><td>{{.Disabled}}</td><td>{{.ExpiringSoon}}</td><td>{{.Active}}</td></tr>
+				{{end}}
+			</tbody>
+		</table>
+	</body>
+</html>
+`))
+
+// message builds the email, with plain text and HTML versions of the report, ready
+// to be sent.
+func (rpt report) message(from string, to []string) (msg []byte, err error) {
+	var html bytes.Buffer
+	err = htmlTemplate.Execute(&html, rpt)
+	if err != nil {
+		return
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", rpt.text()},
+		{"text/html; charset=UTF-8", html.String()},
+	}
+	for _, p := range parts {
+		pw, innerErr := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {p.contentType}})
+		if innerErr != nil {
+			return nil, innerErr
+		}
+
+		_, innerErr = pw.Write([]byte(p.content))
+		if innerErr != nil {
+			return nil, innerErr
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return
+	}
+
+	var m bytes.Buffer
+	fmt.Fprintf(&m, "From: %s\r\n", from)
+	fmt.Fprintf(&m, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&m, "Subject: %s\r\n", rpt.subject())
+	fmt.Fprintf(&m, "Date: %s\r\n", rpt.Until.Format(time.RFC1123Z))
+	fmt.Fprintf(&m, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&m, "Content-Type: multipart/alternative; boundary=%s\r\n", mw.Boundary())
+	fmt.Fprintf(&m, "\r\n")
+	m.Write(body.Bytes())
+
+	return m.Bytes(), nil
+}
+
+// Send emails msg using the SMTP settings from the config file. Recipients may be
+// provided with names, i.e.: "Name <name@example.com>". msg must be a complete email,
+// with headers. This is exported so that other packages can send email, i.e.: to
+// email a license to a customer.
+func Send(cfg config.File, to []string, msg []byte) (err error) {
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return
+	}
+
+	recipients := make([]string, 0, len(to))
+	for _, t := range to {
+		a, innerErr := mail.ParseAddress(t)
+		if innerErr != nil {
+			return innerErr
+		}
+
+		recipients = append(recipients, a.Address)
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	return smtp.SendMail(addr, auth, from.Address, recipients, msg)
+}
@@ -5,6 +5,8 @@ for verifying a password matches a stored hash.
 package pwds
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 
 	"golang.org/x/crypto/bcrypt"
@@ -28,6 +30,19 @@ func Create(password string) (string, error) {
 	return string(hash), err
 }
 
+// Random generates a random password. This is used when a password is needed but one
+// was not provided, for example when users are imported. The password is longer than
+// MinLength.
+func Random() (string, error) {
+	b := make([]byte, 21)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
 // IsValid validates a cleartext password against its possible hash.
 func IsValid(password, hash string) (bool, error) {
 	passwordByte := []byte(password)
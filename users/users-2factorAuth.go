@@ -2,6 +2,7 @@ package users
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"image/png"
 	"log"
@@ -33,8 +34,37 @@ const (
 	max2FABadAttemps = 4
 )
 
-// easier reuse, and easier identifying of what variable is for.
-var twoFATokenLength = otp.DigitsSix
+// twoFAAlgorithms maps the algorithm names used in the config file, and saved for each
+// user, to the algorithms used for generating and validating 2FA tokens.
+var twoFAAlgorithms = map[string]otp.Algorithm{
+	config.TwoFactorAuthAlgorithmSHA1:   otp.AlgorithmSHA1,
+	config.TwoFactorAuthAlgorithmSHA256: otp.AlgorithmSHA256,
+	config.TwoFactorAuthAlgorithmSHA512: otp.AlgorithmSHA512,
+}
+
+// twoFAValidateOpts returns the parameters for validating a user's 2FA tokens. The
+// parameters saved when the user enrolled are used, not the config file's, so that
+// changing the config file doesn't break 2FA for users that already enrolled. The
+// user must have been looked up with the TwoFactorAuthDigits, TwoFactorAuthPeriodSeconds,
+// and TwoFactorAuthAlgorithm columns.
+func twoFAValidateOpts(u db.User) totp.ValidateOpts {
+	return totp.ValidateOpts{
+		Period:    uint(u.TwoFactorAuthPeriodSeconds),
+		Skew:      1, //same as totp.Validate(), allows for some clock drift.
+		Digits:    otp.Digits(u.TwoFactorAuthDigits),
+		Algorithm: twoFAAlgorithms[u.TwoFactorAuthAlgorithm],
+	}
+}
+
+// twoFAColumns are the columns needed to validate a user's 2FA tokens.
+var twoFAColumns = sqldb.Columns{
+	db.TableUsers + ".ID",
+	db.TableUsers + ".TwoFactorAuthSecret",
+	db.TableUsers + ".TwoFactorAuthDigits",
+	db.TableUsers + ".TwoFactorAuthPeriodSeconds",
+	db.TableUsers + ".TwoFactorAuthAlgorithm",
+	db.TableUsers + ".TwoFactorAuthLastTimeStep",
+}
 
 // Get2FABarcode generates a QR code for enrolling a user in 2FA. This returns the QR
 // code as a base64 string that will be embedded into an <img> tag using "data:" type
@@ -103,12 +133,16 @@ func Get2FABarcode(w http.ResponseWriter, r *http.Request) {
 	//
 	//key is the URL to be encoded in a QR code.
 	//secret is the shared secret used to validate 2FA codes. It is stored in our db.
+	//The digits, period, and algorithm are encoded in the key so that authenticator
+	//apps generate tokens with the same parameters. Note that some apps, such as
+	//Google Authenticator as of 10/2023, only support the default SHA1.
+	cfg := config.Data()
 	keyOpts := totp.GenerateOpts{
 		Issuer:      issuer,
 		AccountName: user.Username,
-		Digits:      twoFATokenLength,
-		Algorithm:   otp.AlgorithmSHA1,
-		// Algorithm:   otp.AlgorithmSHA512, //Google Authenticator as of 10/2023 (I imagine since the newest major update, doesn't work with anything by SHA1)
+		Period:      uint(cfg.TwoFactorAuthPeriodSeconds),
+		Digits:      otp.Digits(cfg.TwoFactorAuthDigits),
+		Algorithm:   twoFAAlgorithms[cfg.TwoFactorAuthAlgorithm],
 	}
 	key, err := totp.Generate(keyOpts)
 	if err != nil {
@@ -135,8 +169,8 @@ func Get2FABarcode(w http.ResponseWriter, r *http.Request) {
 	//data back as text that is used in src.
 	imgBytes := base64.StdEncoding.EncodeToString(b.Bytes())
 
-	//Save the secret for the user.
-	err = db.Save2FASecret(r.Context(), userID, key.Secret())
+	//Save the secret, and the parameters, for the user.
+	err = db.Save2FASecret(r.Context(), userID, key.Secret(), cfg.TwoFactorAuthDigits, cfg.TwoFactorAuthPeriodSeconds, cfg.TwoFactorAuthAlgorithm)
 	if err != nil {
 		output.Error(err, "Could not save secret data for 2 Factor Authentication. Please ask an administrator to investigate the logs.", w)
 		return
@@ -145,7 +179,7 @@ func Get2FABarcode(w http.ResponseWriter, r *http.Request) {
 	output.InsertOKWithData(imgBytes, w)
 }
 
-// Validate2FACode takes the 6 character 1-time code provided by a user and checks if
+// Validate2FACode takes the 1-time code provided by a user and checks if
 // it is valid given the 2FA info we have saved for the user. This is used to make
 // sure that enrollment in 2FA is successful.
 func Validate2FACode(w http.ResponseWriter, r *http.Request) {
@@ -162,10 +196,6 @@ func Validate2FACode(w http.ResponseWriter, r *http.Request) {
 		output.ErrorInputInvalid("Validation code must be numbers only.", w)
 		return
 	}
-	if len(token) != twoFATokenLength.Length() {
-		output.ErrorInputInvalid("Validation codes are exactly "+strconv.Itoa(twoFATokenLength.Length())+" characters long.", w)
-		return
-	}
 
 	//Check if this user can manage this user's 2FA enrollment. Admins can manage any
 	//users' enrollment, but non-admins can only manager their own enrollment.
@@ -179,16 +209,24 @@ func Validate2FACode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Look up the 2FA secret for this user.
-	cols := sqldb.Columns{"TwoFactorAuthSecret"}
-	user, err := db.GetUserByID(r.Context(), userID, cols)
+	//Look up the 2FA secret, and parameters, for this user.
+	user, err := db.GetUserByID(r.Context(), userID, twoFAColumns)
 	if err != nil {
 		output.Error(err, "Could not look up users data.", w)
 		return
 	}
 
 	//Validate 2FA code.
-	valid := validate2FA(token, user.TwoFactorAuthSecret)
+	if len(token) != user.TwoFactorAuthDigits {
+		output.ErrorInputInvalid("Validation codes are exactly "+strconv.Itoa(user.TwoFactorAuthDigits)+" characters long.", w)
+		return
+	}
+
+	valid, err := validate2FA(r.Context(), token, user)
+	if err != nil {
+		output.Error(err, "Could not validate the provided Validation Code.", w)
+		return
+	}
 	if !valid {
 		output.ErrorInputInvalid("The provided Validation Code is not valid. Please try again or refresh the page and generate a new QR code.", w)
 		return
@@ -204,11 +242,42 @@ func Validate2FACode(w http.ResponseWriter, r *http.Request) {
 	output.UpdateOK(w)
 }
 
-// validate2FA performs validation of a given 2FA token against a user's secret. This
-// performs the actual checking if the token is correct.
-func validate2FA(token, secret string) (valid bool) {
-	valid = totp.Validate(token, secret)
-	return
+// validate2FA performs validation of a given 2FA token against a user's secret, using
+// the parameters saved when the user enrolled. This performs the actual checking if
+// the token is correct. The user must have been looked up with twoFAColumns.
+//
+// A token can only be used once. The time step the token was generated for is saved
+// when the token is accepted and tokens for the same, or an earlier, time step are
+// rejected. This prevents a token that was seen by someone else from being reused
+// while it is still valid.
+func validate2FA(ctx context.Context, token string, u db.User) (valid bool, err error) {
+	//Check each time step the token could have been generated for, allowing for some
+	//clock drift, individually so we know which time step the token matched.
+	opts := twoFAValidateOpts(u)
+	skew := int64(opts.Skew)
+	opts.Skew = 0
+
+	period := int64(opts.Period)
+	if period == 0 {
+		period = 30 //same as totp.ValidateCustom().
+	}
+
+	now := time.Now().UTC()
+	for i := -skew; i <= skew; i++ {
+		t := now.Add(time.Duration(i*period) * time.Second)
+		if ok, _ := totp.ValidateCustom(token, u.TwoFactorAuthSecret, t, opts); !ok {
+			continue
+		}
+
+		timeStep := t.Unix() / period
+		if timeStep <= u.TwoFactorAuthLastTimeStep {
+			return false, nil
+		}
+
+		return db.Use2FATimeStep(ctx, u.ID, timeStep)
+	}
+
+	return false, nil
 }
 
 // Deactivate2FA turns 2FA off for a user.
@@ -257,12 +326,12 @@ const browserIDCookieName = "browser_id"
 // frequently.
 func Set2FABrowserIDCookie(w http.ResponseWriter, browserID string, expiration time.Time) (err error) {
 	cookie := http.Cookie{
-		Name:     browserIDCookieName,  //
-		HttpOnly: true,                 //cookie cannot be modified by client-side browser javascript.
-		Secure:   false,                //this needs to be false for the demo to run since demo will most likely run on http.
-		Path:     "/",                  //needed when Domain field is missing.
-		SameSite: http.SameSiteLaxMode, //SameSiteStrictMode breaks browsing from history in chrome.
-		Value:    browserID,            //
+		Name:     browserIDCookieName,                 //
+		HttpOnly: true,                                //cookie cannot be modified by client-side browser javascript.
+		Secure:   config.Data().CookieSecureEnabled(), //per CookieSecure in the config file.
+		Path:     config.Data().URL("/"),              //needed when Domain field is missing.
+		SameSite: config.Data().CookieSameSiteMode(),  //per CookieSameSite in the config file.
+		Value:    browserID,                           //
 	}
 
 	//Only set expiration if needed. If expiration is zero, this cookie will expire
@@ -0,0 +1,178 @@
+package users
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles requiring a user to provide a 2FA token, or use their passkey,
+// again, even though they are already logged in, before performing a sensitive
+// action. This is known as "step-up" authentication and is used, for example, when
+// creating, renewing, transferring, or importing a license if the
+// Require2FAForLicenseCreation app setting is enabled.
+//
+// Only users enrolled in 2FA tokens, or who have registered a passkey, are required
+// to step-up. Users without 2FA are not prompted.
+
+// MsgType2FAStepUpRequired is the response type sent when a 2FA token or passkey must
+// be provided, or the provided token or passkey is invalid, to perform an action. The
+// GUI uses this to prompt the user for a token, or their passkey, and then retry the
+// request with the token provided in the "twoFAToken" form value or the passkey
+// assertion provided in the same form values as when logging in. If the user has a
+// passkey registered, the options for prompting for the passkey are provided as the
+// response's data.
+const MsgType2FAStepUpRequired = "2FAStepUpRequired"
+
+// Check2FAStepUp checks the 2FA token, or passkey assertion, provided in a request for
+// a sensitive action when the user is enrolled in 2FA tokens or has a passkey
+// registered. True is returned if the request can continue, either because the token
+// or passkey is valid or the user is not enrolled in 2FA. If false is returned, a
+// response has already been sent.
+//
+// Invalid tokens and passkeys are counted and delayed the same as when logging in to
+// slow down brute force attempts.
+func Check2FAStepUp(w http.ResponseWriter, r *http.Request, userID int64) (ok bool) {
+	//Look up the user's 2FA enrollment and passkeys. A passkey can be used in place
+	//of a 2FA token, the same as when logging in.
+	cols := append(sqldb.Columns{
+		db.TableUsers + ".TwoFactorAuthEnabled",
+		db.TableUsers + ".TwoFactorAuthBadAttempts",
+	}, twoFAColumns...)
+	u, err := db.GetUserByID(r.Context(), userID, cols)
+	if err != nil {
+		output.Error(err, "Could not look up your 2 Factor Authentication enrollment.", w)
+		return false
+	}
+
+	passkeys, err := db.GetUserPasskeys(r.Context(), userID, true)
+	if err != nil {
+		output.Error(err, "Could not look up your passkeys for 2 Factor Authentication.", w)
+		return false
+	}
+
+	if !u.TwoFactorAuthEnabled && len(passkeys) == 0 {
+		return true
+	}
+
+	//Get inputs.
+	token := strings.TrimSpace(r.FormValue("twoFAToken"))
+	passkeyCredentialID := r.FormValue("passkeyCredentialID")
+	passkeyClientDataJSON := r.FormValue("passkeyClientDataJSON")
+	passkeyAuthenticatorData := r.FormValue("passkeyAuthenticatorData")
+	passkeySignature := r.FormValue("passkeySignature")
+
+	//Request a token or passkey if neither was provided.
+	if token == "" && passkeyCredentialID == "" {
+		send2FAStepUpRequired(r, u, passkeys, "Please provide your 2 Factor Authentication code, or use your passkey, to continue.", w)
+		return false
+	}
+
+	//Make sure user is enrolled in TOTP if a token was provided, a user may only have
+	//a passkey.
+	if passkeyCredentialID == "" && !u.TwoFactorAuthEnabled {
+		send2FAStepUpRequired(r, u, passkeys, "You are not enrolled in 2 Factor Authentication codes. Please use your passkey.", w)
+		return false
+	}
+
+	//Delay as needed. This helps prevent brute force attempts of the 2FA token.
+	if u.TwoFactorAuthBadAttempts > 0 {
+		delay := time.Second * time.Duration(2*u.TwoFactorAuthBadAttempts)
+		log.Println("users.Check2FAStepUp", "delaying 2FA auth for:", delay)
+		time.Sleep(delay)
+	}
+
+	//Validate the passkey or token.
+	if passkeyCredentialID != "" {
+		err := verifyPasskeyAssertion(r, passkeyCeremonyStepUp, userID, passkeyCredentialID, passkeyClientDataJSON, passkeyAuthenticatorData, passkeySignature)
+		if err != nil {
+			log.Println("users.Check2FAStepUp", "could not verify passkey", err)
+			increment2FAStepUpBadAttempts(r, u)
+			send2FAStepUpRequired(r, u, passkeys, "Your passkey could not be verified. Please try again.", w)
+			return false
+		}
+	} else {
+		if len(token) != u.TwoFactorAuthDigits {
+			send2FAStepUpRequired(r, u, passkeys, "The 2 Factor Authentication code you provided is not the correct length. It must be exactly "+strconv.Itoa(u.TwoFactorAuthDigits)+" numbers long.", w)
+			return false
+		}
+		if _, err := strconv.Atoi(token); err != nil {
+			send2FAStepUpRequired(r, u, passkeys, "The 2 Factor Authentication code is not valid. It must be numbers only.", w)
+			return false
+		}
+
+		valid, err := validate2FA(r.Context(), token, u)
+		if err != nil {
+			output.Error(err, "Could not validate the 2 Factor Authentication code.", w)
+			return false
+		}
+		if !valid {
+			increment2FAStepUpBadAttempts(r, u)
+			send2FAStepUpRequired(r, u, passkeys, "The 2 Factor Authentication code you provided is invalid. Please try again.", w)
+			return false
+		}
+	}
+
+	//Reset bad 2FA token counter.
+	if u.TwoFactorAuthBadAttempts > 0 {
+		err := db.Set2FABadAttempts(r.Context(), userID, 0)
+		if err != nil {
+			log.Println("users.Check2FAStepUp", "could not reset 2fa bad attempts", err)
+			//not returning since this isn't an end of the world situation
+		}
+	}
+
+	return true
+}
+
+// increment2FAStepUpBadAttempts counts an invalid 2FA token or passkey, up to the max,
+// to increase the delay before the next token or passkey is checked.
+func increment2FAStepUpBadAttempts(r *http.Request, u db.User) {
+	if u.TwoFactorAuthBadAttempts >= max2FABadAttemps {
+		return
+	}
+
+	err := db.Set2FABadAttempts(r.Context(), u.ID, u.TwoFactorAuthBadAttempts+1)
+	if err != nil {
+		log.Println("users.Check2FAStepUp", "could not increment 2fa bad attempts", err)
+		//not returning since this isn't an end of the world situation
+	}
+}
+
+// send2FAStepUpRequired responds to a request asking for a 2FA token or passkey. This
+// is sent as an error, with a 403 status code since the user is logged in but must
+// authenticate again, so that the GUI and API clients don't treat the request as
+// successful, with a specific type so that the GUI knows to prompt for a token. If the
+// user has a passkey registered, a new challenge is generated and returned so the
+// browser can prompt for the passkey.
+func send2FAStepUpRequired(r *http.Request, u db.User, passkeys []db.UserPasskey, msg string, w http.ResponseWriter) {
+	p := output.Payload{
+		OK:   false,
+		Type: MsgType2FAStepUpRequired,
+		ErrorData: output.ErrorPayload{
+			Error:   "2fa token required",
+			Message: msg,
+		},
+	}
+
+	if len(passkeys) > 0 {
+		//A 2FA token can still be used if passkeys cannot be used.
+		opts, err := getPasskeyAssertionOptions(passkeyCeremonyStepUp, u, passkeys)
+		if err == errPasskeyNotConfigured && u.TwoFactorAuthEnabled {
+			log.Println("users.Check2FAStepUp", "passkeys not configured, requiring 2fa token")
+		} else if err != nil {
+			output.Error(err, "Could not generate passkey challenge.", w)
+			return
+		} else {
+			p.Data = opts
+		}
+	}
+
+	output.Send(p, w, http.StatusForbidden)
+}
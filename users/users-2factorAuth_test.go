@@ -0,0 +1,117 @@
+package users
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/sqldb/v3"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestValidate2FAReuse(t *testing.T) {
+	err := config.Read(filepath.Join(t.TempDir(), "licensekeys.conf"), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	c := &sqldb.Config{
+		Type:          sqldb.DBTypeSQLite,
+		SQLitePath:    filepath.Join(t.TempDir(), "test.db"),
+		MapperFunc:    sqldb.DefaultMapperFunc,
+		LoggingLevel:  sqldb.LogLevelNone,
+		DeployQueries: db.DeployQueries,
+		DeployFuncs:   db.DeployFuncs,
+	}
+	sqldb.Use(c)
+
+	err = sqldb.DeploySchema(&sqldb.DeploySchemaOptions{CloseConnection: false})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	t.Cleanup(func() { sqldb.Close() })
+
+	//Enroll the initial user in 2FA.
+	ctx := context.Background()
+	const userID = 1
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: defaultIssuer, AccountName: "test@example.com"})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = db.Save2FASecret(ctx, userID, key.Secret(), 6, 30, config.TwoFactorAuthAlgorithmSHA1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	u, err := db.GetUserByID(ctx, userID, twoFAColumns)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	token, err := totp.GenerateCodeCustom(key.Secret(), time.Now().UTC(), twoFAValidateOpts(u))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The token is valid the first time it is used.
+	valid, err := validate2FA(ctx, token, u)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !valid {
+		t.Fatal("expected token to be valid")
+		return
+	}
+
+	//The token cannot be used again, even by a request that looked up the user
+	//before the token was used.
+	valid, err = validate2FA(ctx, token, u)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if valid {
+		t.Fatal("expected reused token to be invalid for a stale user lookup")
+		return
+	}
+
+	u, err = db.GetUserByID(ctx, userID, twoFAColumns)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	valid, err = validate2FA(ctx, token, u)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if valid {
+		t.Fatal("expected reused token to be invalid")
+		return
+	}
+
+	//A token for an earlier time step cannot be used either.
+	token, err = totp.GenerateCodeCustom(key.Secret(), time.Now().UTC().Add(-30*time.Second), twoFAValidateOpts(u))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	valid, err = validate2FA(ctx, token, u)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if valid {
+		t.Fatal("expected token for earlier time step to be invalid")
+		return
+	}
+}
@@ -0,0 +1,111 @@
+package users
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/notifications"
+	"github.com/c9845/output"
+)
+
+// This file handles detecting an account being brute forced. Each failed login is
+// saved and, when a user has failed to log in too many times within a window of time,
+// a security event is recorded and a notification is posted. The number of failed
+// logins and the window are set in the config file.
+
+// Reasons a login failed.
+const (
+	failedLoginBadPassword = "bad password"
+	failedLoginBad2FA      = "bad 2fa token"
+	failedLoginBadPasskey  = "bad passkey"
+)
+
+// recordFailedLogin saves a failed login for a user and, if the user has reached the
+// threshold of failed logins within the window set in the config file, records a
+// security event and posts a notification. Only one security event is recorded per
+// user per window so that continued attempts don't cause a flood of notifications.
+//
+// Errors are logged, not returned, since a failed login is already being returned to
+// the user and alerting is not critical.
+func recordFailedLogin(ctx context.Context, u db.User, ip, ua, reason string) {
+	f := db.UserFailedLogin{
+		UserID:    u.ID,
+		RemoteIP:  ip,
+		UserAgent: ua,
+		Reason:    reason,
+	}
+	err := f.Insert(ctx)
+	if err != nil {
+		log.Println("users.recordFailedLogin", "could not save failed login", err)
+		return
+	}
+
+	cfg := config.Data()
+	if cfg.FailedLoginAlertThreshold == 0 {
+		return
+	}
+
+	//Check if the threshold was reached.
+	window := time.Duration(cfg.FailedLoginAlertWindowMinutes) * time.Minute
+	since := time.Now().UTC().Add(-window).Format("2006-01-02 15:04:05")
+
+	count, err := db.CountUserFailedLoginsSince(ctx, u.ID, since)
+	if err != nil {
+		log.Println("users.recordFailedLogin", "could not count failed logins", err)
+		return
+	}
+	if count < int64(cfg.FailedLoginAlertThreshold) {
+		return
+	}
+
+	//Check if an event was already recorded for this window.
+	existing, err := db.CountSecurityEventsSince(ctx, db.SecurityEventFailedLoginThreshold, u.ID, since)
+	if err != nil {
+		log.Println("users.recordFailedLogin", "could not look up existing security events", err)
+		return
+	}
+	if existing > 0 {
+		return
+	}
+
+	//Record event and notify.
+	e := db.SecurityEvent{
+		EventType: db.SecurityEventFailedLoginThreshold,
+		UserID:    u.ID,
+		RemoteIP:  ip,
+		Details:   strconv.FormatInt(count, 10) + " failed logins within " + strconv.Itoa(cfg.FailedLoginAlertWindowMinutes) + " minutes, the last due to a " + reason + ".",
+	}
+	err = e.Insert(ctx)
+	if err != nil {
+		log.Println("users.recordFailedLogin", "could not save security event", err)
+		return
+	}
+
+	log.Println("users.recordFailedLogin", "WARNING!", u.Username, e.Details, "Last attempt from", ip)
+	notifications.Notify(ctx, config.NotificationEventFailedLogins, 0, 0, u.Username+" ("+e.Details+" Last attempt from "+ip+".)")
+}
+
+// SecurityEvents retrieves the list of the latest security events.
+func SecurityEvents(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	rows, _ := strconv.ParseInt(r.FormValue("rows"), 10, 64)
+
+	//Validate. Use defaults if not valid.
+	if rows < 0 {
+		rows = 50
+	}
+
+	//Get results.
+	events, err := db.GetSecurityEvents(r.Context(), uint16(rows))
+	if err != nil {
+		output.Error(err, "Could not look up list of security events.", w)
+		return
+	}
+
+	output.DataFound(events, w)
+}
@@ -0,0 +1,182 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles an administrator viewing the app as another user. This is used
+// when a user reports they cannot see something so that an administrator can see
+// exactly what the user sees with the user's permissions.
+//
+// Impersonation is stored on the administrator's session, the user's own sessions are
+// not used or changed. While impersonating, permissions are checked using the user
+// being impersonated and the request is recorded in the activity log with both the
+// administrator and the user. Impersonation is view only; requests that make changes
+// are rejected in middleware.ViewOnlyImpersonation() so that an administrator cannot
+// make changes, or change credentials, as another user. Starting and ending
+// impersonation are recorded as security events.
+
+type impersonatorUserIDContextKeyType string
+
+// ImpersonatorUserIDContextKey is the name of the key that stores the ID of the
+// administrator viewing the app as another user in the request context. When set,
+// UserIDContextKey stores the ID of the user being impersonated.
+const ImpersonatorUserIDContextKey impersonatorUserIDContextKeyType = "impersonator-user-id"
+
+// EndImpersonationPath is the endpoint used to stop impersonating a user. This is the
+// only endpoint that can make changes while impersonating.
+const EndImpersonationPath = "/api/users/impersonate/end/"
+
+// GetImpersonatorUserIDFromRequest returns the ID of the administrator viewing the
+// app as another user. 0 is returned if the request was not made while impersonating.
+func GetImpersonatorUserIDFromRequest(r *http.Request) (userID int64) {
+	id := r.Context().Value(ImpersonatorUserIDContextKey)
+	if id == nil {
+		return 0
+	}
+
+	return id.(int64)
+}
+
+// Impersonate starts viewing the app as another user for the logged in administrator's
+// session. Administrators cannot be impersonated since an administrator can already
+// see everything.
+func Impersonate(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	userID, _ := strconv.ParseInt(r.FormValue("userID"), 10, 64)
+
+	//Validate.
+	if userID <= 0 {
+		output.ErrorInputInvalid("Could not determine which user you want to view the app as.", w)
+		return
+	}
+
+	adminID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	if adminID == userID {
+		output.ErrorInputInvalid("You cannot view the app as yourself.", w)
+		return
+	}
+
+	cols := sqldb.Columns{
+		db.TableUsers + ".Username",
+		db.TableUsers + ".Active",
+		db.TableUsers + ".Administrator",
+	}
+	u, err := db.GetUserByID(r.Context(), userID, cols)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("The user you want to view the app as does not exist.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not look up user.", w)
+		return
+	}
+	if !u.Active {
+		output.ErrorInputInvalid("You cannot view the app as an inactive user.", w)
+		return
+	}
+	if u.Administrator {
+		output.ErrorInputInvalid("You cannot view the app as another administrator.", w)
+		return
+	}
+
+	//Get the administrator's session.
+	cv, err := GetUserSessionIDFromCookie(r)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+	ul, err := db.GetLoginByCookieValue(r.Context(), cv)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+
+	//Start impersonating.
+	err = db.SetImpersonatingUser(r.Context(), ul.ID, userID)
+	if err != nil {
+		output.Error(err, "Could not start viewing the app as this user.", w)
+		return
+	}
+
+	recordImpersonationEvent(r.Context(), db.SecurityEventImpersonationStarted, adminID, getIPFormatted(r), "Started viewing the app as "+u.Username+".")
+
+	output.UpdateOK(w)
+}
+
+// EndImpersonation stops viewing the app as another user and returns the session to
+// the administrator.
+func EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	adminID := GetImpersonatorUserIDFromRequest(r)
+	if adminID == 0 {
+		output.ErrorInputInvalid("You are not viewing the app as another user.", w)
+		return
+	}
+
+	//Get the username of the user being impersonated for the security event.
+	userID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user you are viewing the app as.", w)
+		return
+	}
+	u, err := db.GetUserByID(r.Context(), userID, sqldb.Columns{db.TableUsers + ".Username"})
+	if err != nil {
+		output.Error(err, "Could not look up user.", w)
+		return
+	}
+
+	//Get the administrator's session.
+	cv, err := GetUserSessionIDFromCookie(r)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+	ul, err := db.GetLoginByCookieValue(r.Context(), cv)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+
+	//Stop impersonating.
+	err = db.SetImpersonatingUser(r.Context(), ul.ID, 0)
+	if err != nil {
+		output.Error(err, "Could not stop viewing the app as this user.", w)
+		return
+	}
+
+	recordImpersonationEvent(r.Context(), db.SecurityEventImpersonationEnded, adminID, getIPFormatted(r), "Stopped viewing the app as "+u.Username+".")
+
+	output.UpdateOK(w)
+}
+
+// recordImpersonationEvent saves a security event for an administrator starting or
+// ending impersonation.
+//
+// Errors are logged, not returned, since the impersonation was already started or
+// ended and every request made while impersonating is recorded in the activity log.
+func recordImpersonationEvent(ctx context.Context, eventType string, adminID int64, ip, details string) {
+	e := db.SecurityEvent{
+		EventType: eventType,
+		UserID:    adminID,
+		RemoteIP:  ip,
+		Details:   details,
+	}
+	err := e.Insert(ctx)
+	if err != nil {
+		log.Println("users.recordImpersonationEvent", "could not save security event", err)
+		return
+	}
+
+	log.Println("users.recordImpersonationEvent", adminID, details)
+}
@@ -0,0 +1,250 @@
+package users
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/notifications"
+	"github.com/c9845/licensekeys/v3/users/pwds"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles importing many users at once. This is used when setting up a new
+// deployment for a team so that each user doesn't have to be added one-by-one.
+//
+// Users can be provided as a JSON array of objects or as CSV with a header row. Each
+// user is given a random password that is returned once, in the response, and is not
+// stored in plaintext. The administrator must distribute the passwords to each user
+// since this app does not send email.
+//
+// All users are validated before any are saved. If any user is invalid, no users are
+// saved so that the import can be fixed and retried without creating duplicates.
+
+// maxImportUsers is the maximum number of users that can be imported at once. This is
+// limited since hashing each user's password is slow by design.
+const maxImportUsers = 100
+
+// importCSVColumns is the header row expected for CSV imports. Columns not listed
+// here, for example a user's first or last name, are ignored since they are not
+// stored.
+var importCSVColumns = []string{
+	"Username",
+	"Administrator",
+	"CreateLicenses",
+	"ViewLicenses",
+	"Auditor",
+}
+
+// importedUser is the data returned for each imported user.
+type importedUser struct {
+	UserID   int64
+	Username string
+	Password string //random password, shown only once.
+}
+
+// Import saves a batch of new users. Users are provided in the "data" form value,
+// either as a JSON array of users or as CSV.
+func Import(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	raw := strings.TrimSpace(r.FormValue("data"))
+	if raw == "" {
+		output.ErrorInputInvalid("You must provide the users to import.", w)
+		return
+	}
+
+	//Parse into structs.
+	var uu []db.User
+	var err error
+	if strings.HasPrefix(raw, "[") {
+		err = json.Unmarshal([]byte(raw), &uu)
+	} else {
+		uu, err = parseImportCSV(raw)
+	}
+	if err != nil {
+		output.ErrorInputInvalid("Could not parse the users to import. "+err.Error(), w)
+		return
+	}
+
+	if len(uu) == 0 {
+		output.ErrorInputInvalid("You must provide at least one user to import.", w)
+		return
+	} else if len(uu) > maxImportUsers {
+		output.ErrorInputInvalid("You can import at most "+strconv.Itoa(maxImportUsers)+" users at once.", w)
+		return
+	}
+
+	//Get user ID of user making this request.
+	loggedInUserID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Validate every user before saving any users. Validate() checks for users that
+	//already exist and sets permissions that are implied by other permissions. The
+	//list of usernames is checked for duplicates since Validate() only checks for
+	//users that were already saved.
+	seen := map[string]int{}
+	for i := range uu {
+		u := &uu[i]
+		rowNum := strconv.Itoa(i + 1)
+
+		u.ID = 0
+		u.Active = true
+		u.CreatedByUserID = loggedInUserID
+
+		errMsg, err := u.Validate(r.Context())
+		if err != nil && errMsg != "" {
+			output.Error(err, "User "+rowNum+": "+errMsg, w)
+			return
+		} else if err != nil {
+			output.Error(err, "Could not validate data about user "+rowNum+".", w)
+			return
+		} else if errMsg != "" {
+			output.ErrorInputInvalid("User "+rowNum+" ("+u.Username+"): "+errMsg, w)
+			return
+		}
+
+		if prev, exists := seen[u.Username]; exists {
+			output.ErrorInputInvalid("User "+rowNum+" ("+u.Username+") has the same username as user "+strconv.Itoa(prev)+".", w)
+			return
+		}
+		seen[u.Username] = i + 1
+	}
+
+	//Generate a password for each user.
+	passwords := make([]string, len(uu))
+	for i := range uu {
+		password, err := pwds.Random()
+		if err != nil {
+			output.Error(err, "Could not generate password for user "+strconv.Itoa(i+1)+".", w)
+			return
+		}
+
+		hashedPwd, err := pwds.Create(password)
+		if err != nil {
+			output.Error(err, "Could not add user "+strconv.Itoa(i+1)+" because of a password issue.", w)
+			return
+		}
+
+		passwords[i] = password
+		uu[i].Password = hashedPwd
+	}
+
+	//Save.
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save imported users (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	imported := make([]importedUser, 0, len(uu))
+	for i := range uu {
+		u := &uu[i]
+
+		err = u.Insert(r.Context(), tx)
+		if err != nil {
+			output.Error(err, "Could not save user "+strconv.Itoa(i+1)+" ("+u.Username+").", w)
+			return
+		}
+
+		imported = append(imported, importedUser{
+			UserID:   u.ID,
+			Username: u.Username,
+			Password: passwords[i],
+		})
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save imported users (2).", w)
+		return
+	}
+
+	for _, u := range uu {
+		if u.Administrator {
+			notifications.Notify(r.Context(), config.NotificationEventAdminUserAdded, loggedInUserID, 0, u.Username)
+		}
+	}
+
+	output.InsertOKWithData(imported, w)
+}
+
+// parseImportCSV parses CSV data into users. The first row must be a header row that
+// names the columns, in any order, matching importCSVColumns. Column names are
+// matched case-insensitively. Permission columns accept any value that
+// strconv.ParseBool() accepts, and a blank value means false.
+func parseImportCSV(raw string) (uu []db.User, err error) {
+	cr := csv.NewReader(strings.NewReader(raw))
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return
+	}
+
+	//Map each known column to its position in the CSV.
+	positions := map[string]int{}
+	for i, h := range header {
+		for _, col := range importCSVColumns {
+			if strings.EqualFold(strings.TrimSpace(h), col) {
+				positions[col] = i
+			}
+		}
+	}
+	if _, ok := positions["Username"]; !ok {
+		return nil, errors.New("missing Username column in header row")
+	}
+
+	for row := 2; ; row++ {
+		record, innerErr := cr.Read()
+		if innerErr == io.EOF {
+			break
+		} else if innerErr != nil {
+			return nil, innerErr
+		}
+
+		u := db.User{
+			Username: record[positions["Username"]],
+		}
+
+		perms := map[string]*bool{
+			"Administrator":  &u.Administrator,
+			"CreateLicenses": &u.CreateLicenses,
+			"ViewLicenses":   &u.ViewLicenses,
+			"Auditor":        &u.Auditor,
+		}
+		for col, field := range perms {
+			i, ok := positions[col]
+			if !ok {
+				continue
+			}
+
+			v := strings.TrimSpace(record[i])
+			if v == "" {
+				continue
+			}
+
+			b, innerErr := strconv.ParseBool(v)
+			if innerErr != nil {
+				return nil, fmt.Errorf("invalid %s value %q on row %d", col, v, row)
+			}
+			*field = b
+		}
+
+		uu = append(uu, u)
+	}
+
+	return
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/metrics"
 	"github.com/c9845/licensekeys/v3/users/cookieutils"
 	"github.com/c9845/licensekeys/v3/users/pwds"
 	"github.com/c9845/output"
@@ -35,12 +36,20 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 	twoFAToken := strings.TrimSpace(r.FormValue("twoFAToken"))
 
+	//Passkey assertion, only provided when user is logging in with a passkey in place
+	//of a 2FA token.
+	passkeyCredentialID := r.FormValue("passkeyCredentialID")
+	passkeyClientDataJSON := r.FormValue("passkeyClientDataJSON")
+	passkeyAuthenticatorData := r.FormValue("passkeyAuthenticatorData")
+	passkeySignature := r.FormValue("passkeySignature")
+
 	//Validation.
 	if len(username) < 5 {
 		output.ErrorInputInvalid("You must provide an email address as a username.", w)
 		return
 	}
 	if len(password) < config.Data().MinPasswordLength {
+		metrics.LoginFailed(metrics.LoginFailureBadCredentials)
 		output.ErrorInputInvalid("The credentials you provided are invalid.", w)
 		return
 	}
@@ -50,7 +59,11 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	//We will use this delay login if user has provided incorrect password a few
 	//times, check if user account is active, and check if password provided is valid.
 	u, err := db.GetUserByUsername(r.Context(), username, sqldb.Columns{"*"})
-	if err != nil {
+	if err == sql.ErrNoRows {
+		metrics.LoginFailed(metrics.LoginFailureBadCredentials)
+		output.Error(err, "Could not determine if a user with your username exists.", w)
+		return
+	} else if err != nil {
 		output.Error(err, "Could not determine if a user with your username exists.", w)
 		return
 	}
@@ -64,6 +77,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	//Check if user is active.
 	if !u.Active {
+		metrics.LoginFailed(metrics.LoginFailureInactive)
 		output.ErrorInputInvalid("Your account is inactive. Please contact your administrator.", w)
 		return
 	}
@@ -87,7 +101,9 @@ func Login(w http.ResponseWriter, r *http.Request) {
 				//not returning since this isn't an end of the world situation
 			}
 		}
+		recordFailedLogin(r.Context(), u, getIPFormatted(r), r.UserAgent(), failedLoginBadPassword)
 
+		metrics.LoginFailed(metrics.LoginFailureBadCredentials)
 		output.Error(err, "Could not verify your username and password. Please make sure both are correct.", w)
 		return
 	}
@@ -114,15 +130,53 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	//Define custom response message types. These message types are used client side
 	//in login.ts for handling what should happen next in the GUI.
 	const (
-		msgType2FAForced        = "login2FAForced"
-		msgType2FATokenRequired = "login2FATokenRequired"
-		msgTypeLoginOK          = "loginOK"
+		msgType2FAForced          = "login2FAForced"
+		msgType2FATokenRequired   = "login2FATokenRequired"
+		msgType2FAPasskeyRequired = "login2FAPasskeyRequired"
+		msgTypeLoginOK            = "loginOK"
 	)
 
+	//Look up the passkeys the user has registered. A passkey can be used in place of
+	//a 2FA token, so a user with a passkey is treated as having 2FA enabled even if
+	//they haven't enrolled in TOTP.
+	var passkeys []db.UserPasskey
+	if as.Allow2FactorAuth {
+		passkeys, err = db.GetUserPasskeys(r.Context(), u.ID, true)
+		if err != nil {
+			output.Error(err, "Could not look up passkeys for 2 Factor Authentication.", w)
+			return
+		}
+	}
+	twoFAEnabled := u.TwoFactorAuthEnabled || len(passkeys) > 0
+	passkeyProvided := passkeyCredentialID != ""
+
+	//request2FA responds to the request asking the user for a 2FA token or passkey.
+	//If the user has a passkey registered, a challenge is generated and returned so
+	//the browser can prompt for the passkey.
+	request2FA := func() {
+		if len(passkeys) == 0 {
+			output.Success(msgType2FATokenRequired, nil, w)
+			return
+		}
+
+		//Fall back to a 2FA token if passkeys cannot be used and the user has one.
+		opts, err := getPasskeyAssertionOptions(passkeyCeremonyLogin, u, passkeys)
+		if err == errPasskeyNotConfigured && u.TwoFactorAuthEnabled {
+			log.Println("users.Login", "passkeys not configured, requiring 2fa token")
+			output.Success(msgType2FATokenRequired, nil, w)
+			return
+		} else if err != nil {
+			output.Error(err, "Could not generate passkey challenge.", w)
+			return
+		}
+
+		output.Success(msgType2FAPasskeyRequired, opts, w)
+	}
+
 	//Check if 2FA is forced upon users and this user doesn't have 2FA enabled. In
 	//this case, user has to see an administrator first to enable 2FA before being
 	//able to log in.
-	if as.Allow2FactorAuth && as.Force2FactorAuth && !u.TwoFactorAuthEnabled {
+	if as.Allow2FactorAuth && as.Force2FactorAuth && !twoFAEnabled {
 		log.Println("users.Login", "2FA is required but not enabled for user")
 		output.Success(msgType2FAForced, "Two Factor Authentication (2FA) is required but not enabled for your user account. Please see an administrator to enable 2FA.", w)
 		return
@@ -131,12 +185,61 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	//Check if 2FA is enabled for app and user. If so, we need to check if the user's
 	//browser is already remembered/authenticated (a 2FA token was provided recently).
 	//If not, we need to request the 2FA token.
-	if as.Allow2FactorAuth && u.TwoFactorAuthEnabled {
+	if as.Allow2FactorAuth && twoFAEnabled {
 		//Handle when 2FA token isn't provided. Either user is logging in to app in
 		//this browser for the first time, in which case we will request 2FA from user,
 		//or browser is rememebered by a previously provided 2FA, in which case we
 		//will need to validate remembered browser.
-		if twoFAToken == "" {
+		if passkeyProvided {
+			//User provided a passkey in place of a 2FA token.
+
+			//Delay as needed, the same as for a 2FA token, since passkey failures
+			//count toward the same bad attempts.
+			if u.TwoFactorAuthBadAttempts > 0 {
+				delay := time.Second * time.Duration(2*u.TwoFactorAuthBadAttempts)
+				log.Println("users.Login", "delaying passkey auth for:", delay)
+				time.Sleep(delay)
+			}
+
+			err := verifyPasskeyAssertion(r, passkeyCeremonyLogin, u.ID, passkeyCredentialID, passkeyClientDataJSON, passkeyAuthenticatorData, passkeySignature)
+			if err != nil {
+				log.Println("users.Login", "could not verify passkey", err)
+
+				if u.TwoFactorAuthBadAttempts < max2FABadAttemps {
+					newBadAttempts := u.TwoFactorAuthBadAttempts + 1
+					err := db.Set2FABadAttempts(r.Context(), u.ID, newBadAttempts)
+					if err != nil {
+						log.Println("users.Login", "could not increment 2fa bad attempts", err)
+						//not returning since this isn't an end of the world situation
+					}
+				}
+				recordFailedLogin(r.Context(), u, ip, ua, failedLoginBadPasskey)
+
+				metrics.LoginFailed(metrics.LoginFailureBad2FA)
+				output.ErrorInputInvalid("Your passkey could not be verified. Please try again.", w)
+				return
+			}
+
+			//Remember this browser, the same as when a 2FA token is provided.
+			rememberBrowser(w, r, u.ID, ip, ua)
+
+			//Reset bad 2FA counter.
+			if u.TwoFactorAuthBadAttempts > 0 {
+				err = db.Set2FABadAttempts(r.Context(), u.ID, 0)
+				if err != nil {
+					log.Println("users.Login", "could not reset 2fa bad attempts", err)
+					//not returning since this isn't an end of the world situation
+				}
+			}
+
+			//
+			//At this point, we know that the user is active, that the provided
+			//username and password match and are correct, and the passkey was
+			//verified. Now we just need to record the login/session and redirect
+			//the user to the main logged in page.
+			//
+
+		} else if twoFAToken == "" {
 			//User did not provide 2FA token.
 
 			//Look up cookie to see if this browser is remembered. If cookie cannot be
@@ -144,7 +247,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 			browserID, err := Get2FABrowserIDFromCookie(r)
 			if err != nil {
 				log.Println("users.Login", "could not find browser id cookie, requiring 2fa")
-				output.Success(msgType2FATokenRequired, nil, w)
+				request2FA()
 				return
 			}
 
@@ -152,11 +255,11 @@ func Login(w http.ResponseWriter, r *http.Request) {
 			authedBrowser, err := db.GetAuthorizedBrowser(r.Context(), u.ID, ip, browserID, true)
 			if err == sql.ErrNoRows {
 				log.Println("users.Login", "could not find browser for given cookie, this is odd and should never happen", err)
-				output.Success(msgType2FATokenRequired, nil, w)
+				request2FA()
 				return
 			} else if err != nil {
 				log.Println("users.Login", "could not verify if browser authorized, requiring 2fa", err)
-				output.Success(msgType2FATokenRequired, nil, w)
+				request2FA()
 				return
 			}
 
@@ -169,7 +272,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 			if authedBrowserAge > maxBrowserAge {
 				log.Println("users.Login", "removing expired browser id cookie")
 				Delete2FABrowserIDCookie(w)
-				output.Success(msgType2FATokenRequired, nil, w)
+				request2FA()
 				return
 			}
 
@@ -184,6 +287,12 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		} else {
 			//User provided 2FA token.
 
+			//Make sure user is enrolled in TOTP, a user may only have a passkey.
+			if !u.TwoFactorAuthEnabled {
+				output.ErrorInputInvalid("You are not enrolled in 2 Factor Authentication codes. Please use your passkey.", w)
+				return
+			}
+
 			//Delay as needed. This helps prevent brute force attempts of the 2FA token.
 			if u.TwoFactorAuthBadAttempts > 0 {
 				delay := time.Second * time.Duration(2*u.TwoFactorAuthBadAttempts)
@@ -193,17 +302,22 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 			//Validate the 2FA token.
 			//First, we do some simple checks for format since we know the 2FA token
-			//is 6 numbers. Then, we verify the token itself. If token is not valid,
+			//is the number of digits the user enrolled with. Then, we verify the token itself. If token is not valid,
 			//return an error telling user to try again.
-			if len(twoFAToken) != twoFATokenLength.Length() {
-				output.ErrorInputInvalid("The 2 Factor Authentication code you provided is not the correct length. It must be exactly "+strconv.Itoa(twoFATokenLength.Length())+" numbers long.", w)
+			if len(twoFAToken) != u.TwoFactorAuthDigits {
+				output.ErrorInputInvalid("The 2 Factor Authentication code you provided is not the correct length. It must be exactly "+strconv.Itoa(u.TwoFactorAuthDigits)+" numbers long.", w)
 				return
 			}
 			if _, err := strconv.Atoi(twoFAToken); err != nil {
 				output.Error(err, "The 2 Factor Authentication code is not valid. It must be numbers only.", w)
 				return
 			}
-			if valid := validate2FA(twoFAToken, u.TwoFactorAuthSecret); !valid {
+			valid, err := validate2FA(r.Context(), twoFAToken, u)
+			if err != nil {
+				output.Error(err, "Could not validate the 2 Factor Authentication code.", w)
+				return
+			}
+			if !valid {
 				if u.TwoFactorAuthBadAttempts < max2FABadAttemps {
 					newBadAttempts := u.TwoFactorAuthBadAttempts + 1
 					err := db.Set2FABadAttempts(r.Context(), u.ID, newBadAttempts)
@@ -212,60 +326,19 @@ func Login(w http.ResponseWriter, r *http.Request) {
 						//not returning since this isn't an end of the world situation
 					}
 				}
+				recordFailedLogin(r.Context(), u, ip, ua, failedLoginBad2FA)
 
+				metrics.LoginFailed(metrics.LoginFailureBad2FA)
 				output.ErrorInputInvalid("The 2 Factor Authentication code you provided is invalid.  Please try again.", w)
 				return
 			}
 
-			//
-			//At this point we know the 2FA token is valid. We need to save the
-			//authorized browser for referencing in the future to reduce the number
-			//of times a user has to provide a 2FA token.
-			//
-
-			//Get a unique, random identifier for this browser. This is used in
-			//place of a numeric ID (ex: from authorized_browsers table) to make it
-			//harder to guess.
-			const length = 32
-			b := make([]byte, length)
-			_, err = rand.Read(b)
-			if err != nil {
-				log.Println("users.Login", "could not generate browser ID", err)
-				//not returned on error since this isn't an end of the world situation,
-				//user will just need to provide 2FA token upon next login.
-			}
-			browserID := base64.StdEncoding.EncodeToString(b)
-
-			//Set the cookie that identifies this browser.
-			//
-			//This cookie will be used to prevent a user from having to provide their
-			//2FA token upon every login to reduce user friction when logging in.
-			//
-			//This cookie has a set expiration, based on config file setting. The
-			//expiration does not get extended each time a user logs in.
-			expiration := time.Time{}
-			if config.Data().TwoFactorAuthLifetimeDays > 0 {
-				expiration = time.Now().AddDate(0, 0, config.Data().TwoFactorAuthLifetimeDays)
-			}
-			Set2FABrowserIDCookie(w, browserID, expiration)
-
-			//Record that 2FA was provided to our database.
-			ab := db.AuthorizedBrowser{
-				UserID:    u.ID,
-				RemoteIP:  ip,
-				UserAgent: ua,
-				Timestamp: time.Now().Unix(),
-				Cookie:    browserID,
-			}
-			err = ab.Insert(r.Context())
-			if err != nil {
-				log.Println("users.Login", "could not save browser ID", err)
-				//not returning since this isn't an end of the world situation, user
-				//will just have to provid e 2FA token upon next login.
-			}
+			//Remember this browser to reduce the number of times a user has to
+			//provide a 2FA token.
+			rememberBrowser(w, r, u.ID, ip, ua)
 
 			//Reset bad 2FA token counter.
-			err := db.Set2FABadAttempts(r.Context(), u.ID, 0)
+			err = db.Set2FABadAttempts(r.Context(), u.ID, 0)
 			if err != nil {
 				log.Println("users.Login", "could not reset 2fa bad attempts", err)
 				//not returning since this isn't an end of the world situation
@@ -326,7 +399,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	SetUserSessionIDCookie(w, sessionID, expiration)
 
 	//Record the session to our database.
-	twoFATokenProvided := as.Allow2FactorAuth && u.TwoFactorAuthEnabled && twoFAToken != ""
+	twoFATokenProvided := as.Allow2FactorAuth && twoFAEnabled && (twoFAToken != "" || passkeyProvided)
 
 	ul := db.UserLogin{
 		UserID:             u.ID,
@@ -353,9 +426,55 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	//Respond successfully to request. This will cause the JS code that made this
 	//request to redirect the user to the main logged in page.
+	metrics.LoginSucceeded()
 	output.Success(msgTypeLoginOK, nil, w)
 }
 
+// rememberBrowser saves the authorized browser after a user provided a valid 2FA
+// token or passkey. This is referenced in the future to reduce the number of times a
+// user has to provide a 2FA token or passkey.
+//
+// Errors are logged, not returned, since the user will just need to provide their
+// 2FA token or passkey again upon their next login.
+func rememberBrowser(w http.ResponseWriter, r *http.Request, userID int64, ip, ua string) {
+	//Get a unique, random identifier for this browser. This is used in place of a
+	//numeric ID (ex: from authorized_browsers table) to make it harder to guess.
+	const length = 32
+	b := make([]byte, length)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Println("users.rememberBrowser", "could not generate browser ID", err)
+		return
+	}
+	browserID := base64.StdEncoding.EncodeToString(b)
+
+	//Set the cookie that identifies this browser.
+	//
+	//This cookie will be used to prevent a user from having to provide their 2FA
+	//token upon every login to reduce user friction when logging in.
+	//
+	//This cookie has a set expiration, based on config file setting. The expiration
+	//does not get extended each time a user logs in.
+	expiration := time.Time{}
+	if config.Data().TwoFactorAuthLifetimeDays > 0 {
+		expiration = time.Now().AddDate(0, 0, config.Data().TwoFactorAuthLifetimeDays)
+	}
+	Set2FABrowserIDCookie(w, browserID, expiration)
+
+	//Record that 2FA was provided to our database.
+	ab := db.AuthorizedBrowser{
+		UserID:    userID,
+		RemoteIP:  ip,
+		UserAgent: ua,
+		Timestamp: time.Now().Unix(),
+		Cookie:    browserID,
+	}
+	err = ab.Insert(r.Context())
+	if err != nil {
+		log.Println("users.rememberBrowser", "could not save browser ID", err)
+	}
+}
+
 // getIPFormatted formats the IP in an http request. This is used in Login to make sure
 // we always get the same format for the IP to check if user has already authorized
 // browser via 2fa. Note that user's real IP is probably being put in header since
@@ -398,13 +517,13 @@ const sessionIDCookieName = "session_id"
 // expiration should match the value saved to the database.
 func SetUserSessionIDCookie(w http.ResponseWriter, sessionID string, expiration time.Time) (err error) {
 	cookie := http.Cookie{
-		Name:     sessionIDCookieName,  //
-		HttpOnly: true,                 //cookie cannot be modified by client-side browser javascript.
-		Secure:   false,                //this needs to be false for the demo to run since demo will most likely run on http.
-		Path:     "/",                  //needed when Domain field is missing.
-		SameSite: http.SameSiteLaxMode, //SameSiteStrictMode breaks browsing from history in chrome.
-		Value:    sessionID,            //
-		Expires:  expiration,           //
+		Name:     sessionIDCookieName,                 //
+		HttpOnly: true,                                //cookie cannot be modified by client-side browser javascript.
+		Secure:   config.Data().CookieSecureEnabled(), //per CookieSecure in the config file.
+		Path:     config.Data().URL("/"),              //needed when Domain field is missing.
+		SameSite: config.Data().CookieSameSiteMode(),  //per CookieSameSite in the config file.
+		Value:    sessionID,                           //
+		Expires:  expiration,                          //
 	}
 	cookieutils.Set(w, cookie)
 	return
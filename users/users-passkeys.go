@@ -0,0 +1,476 @@
+package users
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users/webauthn"
+	"github.com/c9845/output"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles enrolling a user in 2 Factor Authentication using a WebAuthn
+// credential (a passkey or a hardware security key) and verifying a passkey when a
+// user logs in. See the webauthn package for the actual verification.
+//
+// A passkey can be used in place of a TOTP token. Users who don't register a passkey
+// continue to use TOTP as before.
+
+// passkeyChallengeLifetime is how long a user has to complete a registration or login
+// with a passkey after the challenge was generated.
+const passkeyChallengeLifetime = 5 * time.Minute
+
+// passkeyChallenge is a challenge provided to the browser for a registration or login.
+type passkeyChallenge struct {
+	challenge string
+	expires   time.Time
+}
+
+// passkeyChallenges stores the challenges that have been provided to browsers but
+// not yet used. Challenges are stored in memory since they are short lived and only
+// one instance of this app is run.
+var passkeyChallenges = struct {
+	sync.Mutex
+	m map[string]passkeyChallenge
+}{
+	m: make(map[string]passkeyChallenge),
+}
+
+// Ceremony types, used for namespacing stored challenges.
+const (
+	passkeyCeremonyRegister = "register"
+	passkeyCeremonyLogin    = "login"
+	passkeyCeremonyStepUp   = "step-up" //see Check2FAStepUp().
+)
+
+// errPasskeyChallengeNotFound is returned when a challenge was never generated, was
+// already used, or has expired.
+var errPasskeyChallengeNotFound = errors.New("users: passkey challenge not found or expired")
+
+// savePasskeyChallenge generates and stores a new challenge for a user. Any previous
+// challenge for the same user and ceremony is replaced.
+func savePasskeyChallenge(ceremony string, userID int64) (challenge string, err error) {
+	challenge, err = webauthn.NewChallenge()
+	if err != nil {
+		return
+	}
+
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+
+	//Remove expired challenges so the map doesn't grow forever.
+	now := time.Now()
+	for k, v := range passkeyChallenges.m {
+		if now.After(v.expires) {
+			delete(passkeyChallenges.m, k)
+		}
+	}
+
+	passkeyChallenges.m[passkeyChallengeKey(ceremony, userID)] = passkeyChallenge{
+		challenge: challenge,
+		expires:   now.Add(passkeyChallengeLifetime),
+	}
+	return
+}
+
+// usePasskeyChallenge retrieves and removes a stored challenge. A challenge can only
+// be used once.
+func usePasskeyChallenge(ceremony string, userID int64) (challenge string, err error) {
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+
+	key := passkeyChallengeKey(ceremony, userID)
+	c, ok := passkeyChallenges.m[key]
+	delete(passkeyChallenges.m, key)
+
+	if !ok || time.Now().After(c.expires) {
+		return "", errPasskeyChallengeNotFound
+	}
+
+	return c.challenge, nil
+}
+
+// passkeyChallengeKey returns the key used to store a challenge.
+func passkeyChallengeKey(ceremony string, userID int64) string {
+	return ceremony + "-" + strconv.FormatInt(userID, 10)
+}
+
+// errPasskeyNotConfigured is returned when passkeys cannot be used because the URL
+// the app is served at is not set in the config file.
+var errPasskeyNotConfigured = errors.New("users: passkeys cannot be used because ExternalURL is not set in the config file")
+
+// passkeyRelyingParty returns the WebAuthn relying party ID and the origin passkeys
+// must be used from, based on the config file. These are not taken from the request
+// since the Host header is provided by the client. Passkeys are bound to the relying
+// party ID so changing it requires users to register their passkeys again.
+func passkeyRelyingParty() (rpID, origin string, err error) {
+	cfg := config.Data()
+	if cfg.ExternalURL == "" {
+		return "", "", errPasskeyNotConfigured
+	}
+
+	u, err := url.Parse(cfg.ExternalURL)
+	if err != nil {
+		return
+	}
+
+	rpID = cfg.PasskeyRPID
+	if rpID == "" {
+		rpID = u.Hostname()
+	}
+
+	return rpID, cfg.ExternalURL, nil
+}
+
+// passkeyRegistrationOptions is the data needed by the browser to call
+// navigator.credentials.create().
+type passkeyRegistrationOptions struct {
+	Challenge            string
+	RPID                 string
+	RPName               string
+	UserHandle           string //base64url encoded, the user's ID.
+	Username             string
+	Algorithms           []int64
+	ExcludeCredentialIDs []string //passkeys already registered, so they aren't registered twice.
+}
+
+// passkeyLoginOptions is the data needed by the browser to call
+// navigator.credentials.get().
+type passkeyLoginOptions struct {
+	Challenge          string
+	RPID               string
+	AllowCredentialIDs []string
+	TOTPAllowed        bool //true if user can provide a TOTP token instead.
+}
+
+// BeginPasskeyRegistration starts registering a passkey for a user. This returns the
+// options the browser needs to create a credential.
+func BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	userID, _ := strconv.ParseInt(r.FormValue("userID"), 10, 64)
+
+	//Validate.
+	if userID < 1 {
+		output.ErrorInputInvalid("Could not determine which user you are trying to register a passkey for.", w)
+		return
+	}
+
+	//Check if this user can manage this user's 2FA enrollment. Admins can manage any
+	//users' enrollment, but non-admins can only manager their own enrollment.
+	loggedInUserData, err := GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	if !loggedInUserData.Administrator && loggedInUserData.ID != userID {
+		output.ErrorInputInvalid("You cannot manage the 2FA enrollment of another user.", w)
+		return
+	}
+
+	//Check if 2FA is allowed.
+	as, err := db.GetAppSettings(r.Context())
+	if err != nil {
+		output.Error(err, "Could not look up app settings.", w)
+		return
+	}
+	if !as.Allow2FactorAuth {
+		output.Error(db.ErrAppSettingDisabled, "2 Factor Authentication is not enabled in App Settings.", w)
+		return
+	}
+
+	//Look up the user.
+	cols := sqldb.Columns{
+		"ID",
+		"Username",
+		"Active",
+	}
+	user, err := db.GetUserByID(r.Context(), userID, cols)
+	if err != nil {
+		output.Error(err, "Could not look up users data.", w)
+		return
+	}
+	if !user.Active {
+		output.ErrorInputInvalid("This user is not active.", w)
+		return
+	}
+
+	//Get the user's existing passkeys so the same authenticator isn't registered
+	//twice.
+	existing, err := db.GetUserPasskeys(r.Context(), userID, true)
+	if err != nil {
+		output.Error(err, "Could not look up existing passkeys.", w)
+		return
+	}
+
+	excludeIDs := make([]string, 0, len(existing))
+	for _, p := range existing {
+		excludeIDs = append(excludeIDs, p.CredentialID)
+	}
+
+	//Make sure passkeys can be verified before the user creates one.
+	rpID, _, err := passkeyRelyingParty()
+	if err != nil {
+		output.Error(err, "Passkeys cannot be registered because ExternalURL is not set in the config file. Please ask an administrator to set it.", w)
+		return
+	}
+
+	//Generate and save the challenge.
+	challenge, err := savePasskeyChallenge(passkeyCeremonyRegister, userID)
+	if err != nil {
+		output.Error(err, "Could not generate passkey challenge.", w)
+		return
+	}
+
+	userHandle := binary.BigEndian.AppendUint64(nil, uint64(userID))
+
+	opts := passkeyRegistrationOptions{
+		Challenge:            challenge,
+		RPID:                 rpID,
+		RPName:               defaultIssuer,
+		UserHandle:           webauthn.Encode(userHandle),
+		Username:             user.Username,
+		Algorithms:           webauthn.SupportedAlgorithms,
+		ExcludeCredentialIDs: excludeIDs,
+	}
+	output.DataFound(opts, w)
+}
+
+// FinishPasskeyRegistration verifies the credential created by the browser and saves
+// it for the user.
+func FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	userID, _ := strconv.ParseInt(r.FormValue("userID"), 10, 64)
+	name := strings.TrimSpace(r.FormValue("name"))
+	rawClientDataJSON := r.FormValue("clientDataJSON")
+	rawAttestationObject := r.FormValue("attestationObject")
+
+	//Validate.
+	if userID < 1 {
+		output.ErrorInputInvalid("Could not determine which user you are trying to register a passkey for.", w)
+		return
+	}
+	if name == "" {
+		output.ErrorInputInvalid("You must provide a name for this passkey so you can identify it later.", w)
+		return
+	}
+
+	clientDataJSON, err := webauthn.Decode(rawClientDataJSON)
+	if err != nil {
+		output.Error(err, "Could not parse passkey client data.", w)
+		return
+	}
+	attestationObject, err := webauthn.Decode(rawAttestationObject)
+	if err != nil {
+		output.Error(err, "Could not parse passkey attestation.", w)
+		return
+	}
+
+	//Check if this user can manage this user's 2FA enrollment.
+	loggedInUserData, err := GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	if !loggedInUserData.Administrator && loggedInUserData.ID != userID {
+		output.ErrorInputInvalid("You cannot manage the 2FA enrollment of another user.", w)
+		return
+	}
+
+	//Verify the credential.
+	challenge, err := usePasskeyChallenge(passkeyCeremonyRegister, userID)
+	if err != nil {
+		output.Error(err, "The passkey registration expired. Please try again.", w)
+		return
+	}
+
+	rpID, origin, err := passkeyRelyingParty()
+	if err != nil {
+		output.Error(err, "Passkeys cannot be registered because ExternalURL is not set in the config file. Please ask an administrator to set it.", w)
+		return
+	}
+
+	c, err := webauthn.VerifyRegistration(rpID, origin, challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		output.Error(err, "The passkey could not be verified. Please try again.", w)
+		return
+	}
+
+	//Save the passkey.
+	p := db.UserPasskey{
+		UserID:       userID,
+		Name:         name,
+		CredentialID: webauthn.Encode(c.ID),
+		PublicKey:    webauthn.Encode(c.PublicKey),
+		Algorithm:    c.Algorithm,
+		SignCount:    int64(c.SignCount),
+	}
+	err = p.Insert(r.Context())
+	if err != nil {
+		output.Error(err, "Could not save passkey.", w)
+		return
+	}
+
+	output.InsertOK(p.ID, w)
+}
+
+// GetPasskeys looks up the list of passkeys registered for a user.
+func GetPasskeys(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	userID, _ := strconv.ParseInt(r.FormValue("userID"), 10, 64)
+
+	//Validate.
+	if userID < 1 {
+		output.ErrorInputInvalid("Could not determine which user you want to look up passkeys for.", w)
+		return
+	}
+
+	//Check if this user can view this user's 2FA enrollment.
+	loggedInUserData, err := GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	if !loggedInUserData.Administrator && loggedInUserData.ID != userID {
+		output.ErrorInputInvalid("You cannot view the 2FA enrollment of another user.", w)
+		return
+	}
+
+	//Get data.
+	pp, err := db.GetUserPasskeys(r.Context(), userID, true)
+	if err != nil {
+		output.Error(err, "Could not look up passkeys.", w)
+		return
+	}
+
+	output.DataFound(pp, w)
+}
+
+// DeletePasskey marks a passkey as inactive so it can no longer be used to log in.
+func DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	userID, _ := strconv.ParseInt(r.FormValue("userID"), 10, 64)
+
+	//Validate.
+	if id < 1 || userID < 1 {
+		output.ErrorInputInvalid("Could not determine which passkey you want to remove.", w)
+		return
+	}
+
+	//Check if this user can manage this user's 2FA enrollment.
+	loggedInUserData, err := GetUserDataFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+	if !loggedInUserData.Administrator && loggedInUserData.ID != userID {
+		output.ErrorInputInvalid("You cannot manage the 2FA enrollment of another user.", w)
+		return
+	}
+
+	err = db.DisableUserPasskey(r.Context(), id, userID)
+	if err != nil {
+		output.Error(err, "Could not remove passkey.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
+// getPasskeyAssertionOptions generates a challenge for a user logging in, or stepping
+// up, with a passkey and returns the options the browser needs to call
+// navigator.credentials.get().
+func getPasskeyAssertionOptions(ceremony string, u db.User, passkeys []db.UserPasskey) (opts passkeyLoginOptions, err error) {
+	rpID, _, err := passkeyRelyingParty()
+	if err != nil {
+		return
+	}
+
+	challenge, err := savePasskeyChallenge(ceremony, u.ID)
+	if err != nil {
+		return
+	}
+
+	allowIDs := make([]string, 0, len(passkeys))
+	for _, p := range passkeys {
+		allowIDs = append(allowIDs, p.CredentialID)
+	}
+
+	opts = passkeyLoginOptions{
+		Challenge:          challenge,
+		RPID:               rpID,
+		AllowCredentialIDs: allowIDs,
+		TOTPAllowed:        u.TwoFactorAuthEnabled,
+	}
+	return
+}
+
+// verifyPasskeyAssertion verifies the passkey assertion provided when a user is
+// logging in, or stepping up. The challenge must have been provided, for the same
+// ceremony, via getPasskeyAssertionOptions().
+func verifyPasskeyAssertion(r *http.Request, ceremony string, userID int64, credentialID, rawClientDataJSON, rawAuthenticatorData, rawSignature string) (err error) {
+	clientDataJSON, err := webauthn.Decode(rawClientDataJSON)
+	if err != nil {
+		return
+	}
+	authenticatorData, err := webauthn.Decode(rawAuthenticatorData)
+	if err != nil {
+		return
+	}
+	signature, err := webauthn.Decode(rawSignature)
+	if err != nil {
+		return
+	}
+
+	challenge, err := usePasskeyChallenge(ceremony, userID)
+	if err != nil {
+		return
+	}
+
+	ctx := r.Context()
+	p, err := db.GetUserPasskeyByCredentialID(ctx, userID, credentialID)
+	if err == sql.ErrNoRows {
+		return errors.New("users: passkey not registered for user")
+	} else if err != nil {
+		return
+	}
+
+	publicKey, err := webauthn.Decode(p.PublicKey)
+	if err != nil {
+		return
+	}
+	c := webauthn.Credential{
+		PublicKey: publicKey,
+		Algorithm: p.Algorithm,
+		SignCount: uint32(p.SignCount),
+	}
+
+	rpID, origin, err := passkeyRelyingParty()
+	if err != nil {
+		return
+	}
+
+	signCount, err := webauthn.VerifyAssertion(rpID, origin, challenge, c, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		return
+	}
+
+	err = db.UpdatePasskeyUsed(ctx, p.ID, int64(signCount))
+	if err != nil {
+		log.Println("users.verifyPasskeyAssertion", "could not save passkey usage", err)
+		//not returning since this isn't an end of the world situation
+		err = nil
+	}
+
+	return
+}
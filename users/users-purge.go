@@ -0,0 +1,75 @@
+package users
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/c9845/licensekeys/v3/config"
+	"github.com/c9845/licensekeys/v3/db"
+)
+
+// This file handles automatically deleting old user logins based on the retention
+// set in the config file. This keeps the user logins table from growing forever
+// without an administrator having to clear the login history manually.
+
+// loginPurgeInterval is how often old user logins are deleted.
+const loginPurgeInterval = 24 * time.Hour
+
+// StartLoginPurger deletes inactive or expired user logins older than the retention
+// set in the config file on a ticker. Old logins are deleted once at startup so that
+// a deployment that is restarted often still has old logins deleted. This does
+// nothing if the retention is 0.
+//
+// This should be called in a goroutine since it never returns when automatic deletion
+// is enabled.
+func StartLoginPurger() {
+	retentionDays := config.Data().UserLoginRetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+
+	log.Printf("Deleting inactive user logins older than %d days.", retentionDays)
+
+	purgeLogins(retentionDays)
+
+	ticker := time.NewTicker(loginPurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purgeLogins(retentionDays)
+	}
+}
+
+// purgeLogins deletes inactive or expired user logins older than the given number of
+// days. Errors are logged, not returned, since there is nothing to return them to.
+func purgeLogins(retentionDays int) {
+	//Skip while the app is in maintenance mode since changes should not be made.
+	if config.MaintenanceMode() {
+		log.Println("users.purgeLogins", "skipped since app is in maintenance mode")
+		return
+	}
+
+	priorToDate := time.Now().UTC().AddDate(0, 0, -retentionDays).Format("2006-01-02 15:04:05")
+
+	deleted, err := db.PurgeUserLogins(context.Background(), priorToDate)
+	if err != nil {
+		log.Println("users.purgeLogins", "could not delete old user logins", err)
+		return
+	}
+	if deleted > 0 {
+		log.Println("users.purgeLogins", "deleted", strconv.FormatInt(deleted, 10), "old user logins")
+	}
+
+	//Failed logins are only used for detecting brute force attempts within a short
+	//window of time, so they are deleted with old user logins.
+	deleted, err = db.PurgeUserFailedLogins(context.Background(), priorToDate)
+	if err != nil {
+		log.Println("users.purgeLogins", "could not delete old failed logins", err)
+		return
+	}
+	if deleted > 0 {
+		log.Println("users.purgeLogins", "deleted", strconv.FormatInt(deleted, 10), "old failed logins")
+	}
+}
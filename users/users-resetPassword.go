@@ -0,0 +1,85 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/users/pwds"
+	"github.com/c9845/sqldb/v3"
+)
+
+// This file handles resetting an administrator's password from the command line. This
+// is used to recover access to the app when the only administrator has lost their
+// password. This is purposely not available via an HTTP endpoint since it must
+// require direct access to the server running the app.
+
+// Errors returned when an administrator's password cannot be reset.
+var (
+	errResetUserNotFound    = errors.New("users: user does not exist")
+	errResetUserNotAdmin    = errors.New("users: user is not an administrator")
+	errResetUserNotActive   = errors.New("users: user is not active")
+	errResetUsernameMissing = errors.New("users: username not provided")
+)
+
+// ResetAdminPassword sets a new, random, password for the administrator with the
+// given username and logs the user out of all sessions. The new password is returned
+// so that it can be provided to the user. Bad password attempts are also reset so
+// that a user who was locked out can log in.
+func ResetAdminPassword(ctx context.Context, username string) (password string, err error) {
+	//Validate.
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		return "", errResetUsernameMissing
+	}
+
+	cols := sqldb.Columns{
+		"ID",
+		"Active",
+		"Administrator",
+	}
+	u, err := db.GetUserByUsername(ctx, username, cols)
+	if err == sql.ErrNoRows {
+		return "", errResetUserNotFound
+	} else if err != nil {
+		return
+	}
+	if !u.Administrator {
+		return "", errResetUserNotAdmin
+	}
+	if !u.Active {
+		return "", errResetUserNotActive
+	}
+
+	//Generate password.
+	password, err = pwds.Random()
+	if err != nil {
+		return
+	}
+
+	hashedPwd, err := pwds.Create(password)
+	if err != nil {
+		return "", err
+	}
+
+	//Save.
+	err = db.SetNewPassword(ctx, u.ID, hashedPwd)
+	if err != nil {
+		return "", err
+	}
+
+	err = db.SetPasswordBadAttempts(ctx, u.ID, 0)
+	if err != nil {
+		return "", err
+	}
+
+	//Inactivate all existing active user logins/sessions for security.
+	err = db.DisableLoginsForUser(ctx, u.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return
+}
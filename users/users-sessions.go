@@ -0,0 +1,95 @@
+package users
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/output"
+)
+
+// This file handles a user viewing and revoking their own sessions. This lets a user
+// see where they are logged in, and log out a session on another device, without
+// asking an administrator to force them to log out of every session.
+
+// Sessions returns the logged in user's active, non-expired, sessions. The session
+// the request was made with is flagged as the current session.
+func Sessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	currentCookieValue, err := GetUserSessionIDFromCookie(r)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+
+	sessions, err := db.GetActiveLoginsForUser(r.Context(), userID)
+	if err != nil {
+		output.Error(err, "Could not look up your sessions.", w)
+		return
+	}
+
+	//Flag the current session and remove each session's cookie value so that it
+	//cannot be used to impersonate the session.
+	for i := range sessions {
+		s := &sessions[i]
+		s.Current = s.CookieValue == currentCookieValue
+		s.CookieValue = ""
+	}
+
+	output.DataFound(sessions, w)
+}
+
+// RevokeSession marks one of the logged in user's sessions as inactive. This causes
+// subsequent requests made with the session to fail. The current session cannot be
+// revoked, the user should log out instead.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	sessionID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	//Validate.
+	if sessionID < 1 {
+		output.ErrorInputInvalid("Could not determine which session you want to revoke.", w)
+		return
+	}
+
+	userID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	currentCookieValue, err := GetUserSessionIDFromCookie(r)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+
+	current, err := db.GetLoginByCookieValue(r.Context(), currentCookieValue)
+	if err != nil {
+		output.Error(err, "Could not determine your current session.", w)
+		return
+	}
+	if current.ID == sessionID {
+		output.ErrorInputInvalid("You cannot revoke your current session. Please log out instead.", w)
+		return
+	}
+
+	//Revoke. The user ID is checked so that a user can only revoke their own
+	//sessions.
+	err = db.DisableLogin(r.Context(), sessionID, userID)
+	if err == sql.ErrNoRows {
+		output.ErrorInputInvalid("This session does not exist or has already been revoked.", w)
+		return
+	} else if err != nil {
+		output.Error(err, "Could not revoke session.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
@@ -10,24 +10,63 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/c9845/licensekeys/v3/config"
 	"github.com/c9845/licensekeys/v3/db"
+	"github.com/c9845/licensekeys/v3/notifications"
 	"github.com/c9845/licensekeys/v3/users/pwds"
 	"github.com/c9845/output"
 	"github.com/c9845/sqldb/v3"
 )
 
-// GetAll gets a list of all users optionally filtered by users that are active.
+// usersResult is the data returned when the list of users is paginated.
+type usersResult struct {
+	Users []db.User
+	Total int64 //number of users matching the filters, ignoring limit and offset.
+}
+
+// GetAll gets a list of all users optionally filtered by users that are active, a
+// search term matched against the username, and a role.
+//
+// Pagination is opt-in. When a limit is provided the users are returned along with
+// the total number of matching users, otherwise just the list of users is returned
+// as it always has been.
 func GetAll(w http.ResponseWriter, r *http.Request) {
 	activeOnly, _ := strconv.ParseBool(r.FormValue("activeOnly"))
+	search := strings.TrimSpace(r.FormValue("search"))
+	role := strings.TrimSpace(r.FormValue("role"))
+	limit, _ := strconv.ParseInt(r.FormValue("limit"), 10, 64)
+	offset, _ := strconv.ParseInt(r.FormValue("offset"), 10, 64)
+
+	//Validate.
+	if !db.ValidUserRole(role) {
+		output.ErrorInputInvalid("Please choose a role from the provided options.", w)
+		return
+	}
+	if limit < 0 {
+		output.ErrorInputInvalid("The limit cannot be negative.", w)
+		return
+	}
+	if offset < 0 {
+		output.ErrorInputInvalid("The offset cannot be negative.", w)
+		return
+	}
 
-	users, err := db.GetUsers(r.Context(), activeOnly)
+	users, total, err := db.GetUsers(r.Context(), activeOnly, search, role, limit, offset)
 	if err != nil {
 		output.Error(err, "Could not get list of users.", w)
 		return
 	}
 
+	if limit > 0 {
+		output.DataFound(usersResult{
+			Users: users,
+			Total: total,
+		}, w)
+		return
+	}
+
 	output.DataFound(users, w)
 }
 
@@ -89,12 +128,30 @@ func Add(w http.ResponseWriter, r *http.Request) {
 	u.CreatedByUserID = loggedInUserID
 
 	//Save.
-	err = u.Insert(r.Context())
+	c := sqldb.Connection()
+	tx, err := c.BeginTxx(r.Context(), nil)
+	if err != nil {
+		output.Error(err, "Could not save new user (1).", w)
+		return
+	}
+	defer tx.Rollback()
+
+	err = u.Insert(r.Context(), tx)
 	if err != nil {
-		output.Error(err, "Could not save new user.", w)
+		output.Error(err, "Could not save new user (2).", w)
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		output.Error(err, "Could not save new user (3).", w)
+		return
+	}
+
+	if u.Administrator {
+		notifications.Notify(r.Context(), config.NotificationEventAdminUserAdded, loggedInUserID, 0, u.Username)
+	}
+
 	output.InsertOK(u.ID, w)
 }
 
@@ -226,6 +283,40 @@ func GetOne(w http.ResponseWriter, r *http.Request) {
 	output.DataFound(u, w)
 }
 
+// SetTimezone saves the timezone the logged in user wants dates and times displayed
+// in. This only changes how dates and times are displayed, dates and times are always
+// stored in UTC. A blank timezone resets the user to the timezone from the config
+// file.
+func SetTimezone(w http.ResponseWriter, r *http.Request) {
+	//Get inputs.
+	timezone := strings.TrimSpace(r.FormValue("timezone"))
+
+	//Validate.
+	if timezone != "" {
+		_, err := time.LoadLocation(timezone)
+		if err != nil {
+			output.ErrorInputInvalid("The timezone provided is not valid. Please provide a timezone in IANA format (i.e.: America/New_York).", w)
+			return
+		}
+	}
+
+	//Get the user making this request. Users can only set their own timezone.
+	userID, err := GetUserIDFromRequest(r)
+	if err != nil {
+		output.Error(err, "Could not determine the user making this request.", w)
+		return
+	}
+
+	//Save.
+	err = db.SetTimezone(r.Context(), userID, timezone)
+	if err != nil {
+		output.Error(err, "Could not save timezone.", w)
+		return
+	}
+
+	output.UpdateOK(w)
+}
+
 // ClearLoginHistory deletes rows in the user logins table before a certain date. This
 // is only done from the admin tools page and is done to clean up the database since
 // the user login history table can get very big if you have a lot of users and/or a
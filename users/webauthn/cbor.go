@@ -0,0 +1,225 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// This file implements decoding of the subset of CBOR (RFC 8949) that is used by
+// WebAuthn for attestation objects and COSE public keys. Authenticators encode data
+// using CTAP2 canonical CBOR which never uses indefinite length items, so they are not
+// supported.
+
+// CBOR major types.
+const (
+	cborUnsignedInt = 0
+	cborNegativeInt = 1
+	cborByteString  = 2
+	cborTextString  = 3
+	cborArray       = 4
+	cborMap         = 5
+	cborTag         = 6
+	cborSimple      = 7
+)
+
+// maxCBORDepth limits how deeply nested arrays and maps can be to prevent malicious
+// input from exhausting the stack.
+const maxCBORDepth = 16
+
+// Errors.
+var (
+	errCBORTruncated  = errors.New("webauthn: cbor data truncated")
+	errCBORUnsupport  = errors.New("webauthn: unsupported cbor data")
+	errCBORTooDeep    = errors.New("webauthn: cbor data nested too deeply")
+	errCBORInvalidKey = errors.New("webauthn: unsupported cbor map key")
+)
+
+// decodeCBOR decodes the first CBOR item in b and returns the item and any data after
+// the item. Decoded items are returned as:
+//   - unsigned and negative integers: int64.
+//   - byte strings: []byte.
+//   - text strings: string.
+//   - arrays: []any.
+//   - maps: map[any]any, with int64 or string keys.
+//   - booleans: bool.
+//   - null and undefined: nil.
+//   - floats: float64.
+func decodeCBOR(b []byte) (v any, rest []byte, err error) {
+	return decodeCBORItem(b, 0)
+}
+
+// decodeCBORItem decodes a single CBOR item, tracking nesting depth.
+func decodeCBORItem(b []byte, depth int) (v any, rest []byte, err error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errCBORTooDeep
+	}
+	if len(b) < 1 {
+		return nil, nil, errCBORTruncated
+	}
+
+	majorType := b[0] >> 5
+	info := b[0] & 0x1f
+	b = b[1:]
+
+	//Floats and simple values use the additional info differently than other major
+	//types so handle them first.
+	if majorType == cborSimple {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22, 23:
+			return nil, b, nil
+		case 25:
+			if len(b) < 2 {
+				return nil, nil, errCBORTruncated
+			}
+			return float16ToFloat64(binary.BigEndian.Uint16(b)), b[2:], nil
+		case 26:
+			if len(b) < 4 {
+				return nil, nil, errCBORTruncated
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), b[4:], nil
+		case 27:
+			if len(b) < 8 {
+				return nil, nil, errCBORTruncated
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+		default:
+			return nil, nil, errCBORUnsupport
+		}
+	}
+
+	//Get the argument, which is a value or a length depending on the major type.
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(b) < 1 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(b[0])
+		b = b[1:]
+	case info == 25:
+		if len(b) < 2 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	case info == 26:
+		if len(b) < 4 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint32(b))
+		b = b[4:]
+	case info == 27:
+		if len(b) < 8 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = binary.BigEndian.Uint64(b)
+		b = b[8:]
+	default:
+		//Indefinite length items and reserved values.
+		return nil, nil, errCBORUnsupport
+	}
+
+	switch majorType {
+	case cborUnsignedInt:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBORUnsupport
+		}
+		return int64(arg), b, nil
+
+	case cborNegativeInt:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBORUnsupport
+		}
+		return -1 - int64(arg), b, nil
+
+	case cborByteString, cborTextString:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBORTruncated
+		}
+		data := b[:arg]
+		if majorType == cborTextString {
+			return string(data), b[arg:], nil
+		}
+		return append([]byte{}, data...), b[arg:], nil
+
+	case cborArray:
+		//Each item is at least 1 byte, so this catches bogus lengths before
+		//allocating.
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBORTruncated
+		}
+
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item any
+			item, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, b, nil
+
+	case cborMap:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBORTruncated
+		}
+
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value any
+			key, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBORInvalidKey
+			}
+
+			value, b, err = decodeCBORItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, b, nil
+
+	case cborTag:
+		//Tags are ignored and the tagged item is returned as is.
+		return decodeCBORItem(b, depth+1)
+	}
+
+	return nil, nil, errCBORUnsupport
+}
+
+// float16ToFloat64 converts an IEEE 754 half precision float to a float64.
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int((h >> 10) & 0x1f)
+	frac := float64(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
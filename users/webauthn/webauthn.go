@@ -0,0 +1,404 @@
+/*
+Package webauthn implements verification of WebAuthn (passkey and hardware security
+key) registrations and assertions. This is used as a form of 2 Factor Authentication
+in addition to, or in place of, TOTP tokens.
+
+Only the parts of WebAuthn needed by this app are implemented:
+  - Attestation statements are not verified. Registration requests "none" attestation
+    since we only need to know a credential's public key, not the make and model of
+    the authenticator.
+  - ES256 (ECDSA P-256), RS256 (RSA PKCS#1 v1.5 with SHA-256), and EdDSA (Ed25519)
+    public keys are supported. These cover all common authenticators and passkey
+    providers.
+
+The browser side of the ceremonies uses navigator.credentials.create() and
+navigator.credentials.get(). The binary values returned by the browser must be
+base64url encoded (without padding) when sent to the server.
+*/
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers for the supported public key types.
+// https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms is the list of COSE algorithms, in order of preference, that
+// can be used when registering a credential. This is provided to the browser as the
+// pubKeyCredParams.
+var SupportedAlgorithms = []int64{
+	AlgES256,
+	AlgEdDSA,
+	AlgRS256,
+}
+
+// challengeLength is the number of random bytes in a challenge. The spec requires at
+// least 16.
+const challengeLength = 32
+
+// Authenticator data flags.
+const (
+	flagUserPresent            = 0x01
+	flagAttestedCredentialData = 0x40
+)
+
+// Errors.
+var (
+	ErrInvalidClientData        = errors.New("webauthn: client data invalid")
+	ErrChallengeMismatch        = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch           = errors.New("webauthn: origin does not match")
+	ErrRPIDMismatch             = errors.New("webauthn: relying party ID does not match")
+	ErrUserNotPresent           = errors.New("webauthn: user presence flag not set")
+	ErrInvalidAuthenticatorData = errors.New("webauthn: authenticator data invalid")
+	ErrInvalidAttestation       = errors.New("webauthn: attestation object invalid")
+	ErrUnsupportedKey           = errors.New("webauthn: unsupported public key type")
+	ErrBadSignature             = errors.New("webauthn: signature invalid")
+	ErrSignCount                = errors.New("webauthn: signature counter did not increase, authenticator may be cloned")
+)
+
+// Credential is a registered credential. The PublicKey is stored PKIX, ASN.1 DER,
+// encoded.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	Algorithm int64
+	SignCount uint32
+}
+
+// NewChallenge returns a new random challenge, base64url encoded. A new challenge
+// must be used for each registration or assertion.
+func NewChallenge() (challenge string, err error) {
+	b := make([]byte, challengeLength)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+
+	challenge = base64.RawURLEncoding.EncodeToString(b)
+	return
+}
+
+// Encode base64url encodes data, without padding, the same as the browser side code.
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode decodes base64url data, with or without padding.
+func Decode(s string) ([]byte, error) {
+	s = trimPadding(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// trimPadding removes base64 padding characters.
+func trimPadding(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// clientData is the data, in clientDataJSON, provided by the browser.
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// verifyClientData checks the client data provided by the browser is for the
+// expected ceremony, challenge, and origin.
+func verifyClientData(clientDataJSON []byte, ceremony, origin, challenge string) (err error) {
+	var cd clientData
+	err = json.Unmarshal(clientDataJSON, &cd)
+	if err != nil {
+		return ErrInvalidClientData
+	}
+
+	if cd.Type != ceremony {
+		return ErrInvalidClientData
+	}
+	if trimPadding(cd.Challenge) != trimPadding(challenge) {
+		return ErrChallengeMismatch
+	}
+
+	//The origin must be exactly the origin the app is configured to be served at, not
+	//just any origin the relying party ID is valid for, so that a passkey used on
+	//another site under the same domain cannot be replayed to this app.
+	if cd.Origin != origin {
+		return ErrOriginMismatch
+	}
+
+	return nil
+}
+
+// authenticatorData is the parsed authenticator data.
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	//Only set when flagAttestedCredentialData is set, during registration.
+	credentialID        []byte
+	credentialPublicKey map[any]any
+}
+
+// parseAuthenticatorData parses the binary authenticator data.
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+func parseAuthenticatorData(b []byte) (ad authenticatorData, err error) {
+	const minLength = 32 + 1 + 4
+	if len(b) < minLength {
+		return ad, ErrInvalidAuthenticatorData
+	}
+
+	ad.rpIDHash = b[:32]
+	ad.flags = b[32]
+	ad.signCount = binary.BigEndian.Uint32(b[33:37])
+
+	if ad.flags&flagAttestedCredentialData == 0 {
+		return
+	}
+
+	//Attested credential data: aaguid (16), credential ID length (2), credential ID,
+	//and the COSE encoded public key.
+	rest := b[minLength:]
+	if len(rest) < 18 {
+		return ad, ErrInvalidAuthenticatorData
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return ad, ErrInvalidAuthenticatorData
+	}
+	ad.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	key, _, err := decodeCBOR(rest)
+	if err != nil {
+		return ad, ErrInvalidAuthenticatorData
+	}
+	m, ok := key.(map[any]any)
+	if !ok {
+		return ad, ErrInvalidAuthenticatorData
+	}
+	ad.credentialPublicKey = m
+
+	return
+}
+
+// coseKeyToPublicKey converts a COSE encoded public key to a Go public key and
+// returns the key's COSE algorithm.
+// https://www.rfc-editor.org/rfc/rfc9053.html
+func coseKeyToPublicKey(m map[any]any) (pub crypto.PublicKey, alg int64, err error) {
+	kty, _ := m[int64(1)].(int64)
+	alg, _ = m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrUnsupportedKey
+		}
+
+		k, innerErr := ecdsaPublicKey(x, y)
+		if innerErr != nil {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return k, alg, nil
+
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrUnsupportedKey
+		}
+
+		exp := 0
+		for _, v := range e {
+			exp = exp<<8 | int(v)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, alg, nil
+
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), alg, nil
+	}
+
+	return nil, 0, ErrUnsupportedKey
+}
+
+// ecdsaPublicKey builds a P-256 public key from its coordinates, validating the
+// point is on the curve.
+func ecdsaPublicKey(x, y []byte) (*ecdsa.PublicKey, error) {
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+
+	//MarshalPKIXPublicKey returns an error if the point is not on the curve.
+	_, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// VerifyRegistration verifies the response from navigator.credentials.create() and
+// returns the credential to save for the user. The challenge must be the challenge
+// provided to the browser when the registration was started. The rpID is the domain
+// passkeys are registered to and the origin is the scheme and host the app is served
+// at, i.e.: https://licenses.example.com.
+func VerifyRegistration(rpID, origin, challenge string, clientDataJSON, attestationObject []byte) (c Credential, err error) {
+	err = verifyClientData(clientDataJSON, "webauthn.create", origin, challenge)
+	if err != nil {
+		return
+	}
+
+	//Parse the attestation object. The attestation statement itself is not verified,
+	//see package comment.
+	v, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return c, ErrInvalidAttestation
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return c, ErrInvalidAttestation
+	}
+	rawAuthData, ok := m["authData"].([]byte)
+	if !ok {
+		return c, ErrInvalidAttestation
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return c, ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return c, ErrUserNotPresent
+	}
+	if ad.flags&flagAttestedCredentialData == 0 || len(ad.credentialID) == 0 {
+		return c, ErrInvalidAttestation
+	}
+
+	pub, alg, err := coseKeyToPublicKey(ad.credentialPublicKey)
+	if err != nil {
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return
+	}
+
+	c = Credential{
+		ID:        ad.credentialID,
+		PublicKey: der,
+		Algorithm: alg,
+		SignCount: ad.signCount,
+	}
+	return
+}
+
+// VerifyAssertion verifies the response from navigator.credentials.get() using a
+// previously registered credential. The new signature counter is returned and should
+// be saved for the credential. The rpID and origin are the same as when the
+// credential was registered, see VerifyRegistration().
+func VerifyAssertion(rpID, origin, challenge string, c Credential, clientDataJSON, authenticatorDataBytes, signature []byte) (signCount uint32, err error) {
+	err = verifyClientData(clientDataJSON, "webauthn.get", origin, challenge)
+	if err != nil {
+		return
+	}
+
+	ad, err := parseAuthenticatorData(authenticatorDataBytes)
+	if err != nil {
+		return
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return 0, ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return 0, ErrUserNotPresent
+	}
+
+	//The signature is over the authenticator data and the hash of the client data.
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorDataBytes...), clientDataHash[:]...)
+
+	pub, err := x509.ParsePKIXPublicKey(c.PublicKey)
+	if err != nil {
+		return
+	}
+
+	switch c.Algorithm {
+	case AlgES256:
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return 0, ErrUnsupportedKey
+		}
+		h := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(k, h[:], signature) {
+			return 0, ErrBadSignature
+		}
+
+	case AlgRS256:
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return 0, ErrUnsupportedKey
+		}
+		h := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], signature) != nil {
+			return 0, ErrBadSignature
+		}
+
+	case AlgEdDSA:
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return 0, ErrUnsupportedKey
+		}
+		if !ed25519.Verify(k, signed, signature) {
+			return 0, ErrBadSignature
+		}
+
+	default:
+		return 0, ErrUnsupportedKey
+	}
+
+	//Authenticators that don't support a counter always return 0, otherwise the
+	//counter must always increase.
+	if (ad.signCount != 0 || c.SignCount != 0) && ad.signCount <= c.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return ad.signCount, nil
+}
@@ -0,0 +1,262 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+// cborHead encodes a CBOR major type and argument. This, and the other cbor helpers,
+// are just enough to build test data like an authenticator would.
+func cborHead(majorType byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{majorType<<5 | byte(n)}
+	case n < 256:
+		return []byte{majorType<<5 | 24, byte(n)}
+	default:
+		return []byte{majorType<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(i int) []byte {
+	if i < 0 {
+		return cborHead(cborNegativeInt, -1-i)
+	}
+	return cborHead(cborUnsignedInt, i)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(cborByteString, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(cborTextString, len(s)), s...)
+}
+
+// testAuthenticator holds a key pair for signing like an authenticator would.
+type testAuthenticator struct {
+	credentialID []byte
+	coseKey      []byte
+	sign         func(data []byte) []byte
+}
+
+func newTestAuthenticatorES256(t *testing.T) testAuthenticator {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := priv.PublicKey.X.FillBytes(make([]byte, 32))
+	y := priv.PublicKey.Y.FillBytes(make([]byte, 32))
+
+	var key []byte
+	key = append(key, cborHead(cborMap, 5)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(2)...)
+	key = append(key, cborInt(3)...)
+	key = append(key, cborInt(int(AlgES256))...)
+	key = append(key, cborInt(-1)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(-2)...)
+	key = append(key, cborBytes(x)...)
+	key = append(key, cborInt(-3)...)
+	key = append(key, cborBytes(y)...)
+
+	return testAuthenticator{
+		credentialID: []byte("es256-credential"),
+		coseKey:      key,
+		sign: func(data []byte) []byte {
+			h := sha256.Sum256(data)
+			sig, err := ecdsa.SignASN1(rand.Reader, priv, h[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		},
+	}
+}
+
+func newTestAuthenticatorEdDSA(t *testing.T) testAuthenticator {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key []byte
+	key = append(key, cborHead(cborMap, 4)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(3)...)
+	key = append(key, cborInt(int(AlgEdDSA))...)
+	key = append(key, cborInt(-1)...)
+	key = append(key, cborInt(6)...)
+	key = append(key, cborInt(-2)...)
+	key = append(key, cborBytes(pub)...)
+
+	return testAuthenticator{
+		credentialID: []byte("eddsa-credential"),
+		coseKey:      key,
+		sign: func(data []byte) []byte {
+			return ed25519.Sign(priv, data)
+		},
+	}
+}
+
+func (a testAuthenticator) authData(rpID string, flags byte, signCount uint32, attested bool) []byte {
+	h := sha256.Sum256([]byte(rpID))
+
+	b := append([]byte{}, h[:]...)
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, signCount)
+
+	if attested {
+		b = append(b, make([]byte, 16)...) //aaguid
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.credentialID)))
+		b = append(b, a.credentialID...)
+		b = append(b, a.coseKey...)
+	}
+
+	return b
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge, origin string) []byte {
+	b, err := json.Marshal(clientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func attestationObject(authData []byte) []byte {
+	var b []byte
+	b = append(b, cborHead(cborMap, 3)...)
+	b = append(b, cborText("fmt")...)
+	b = append(b, cborText("none")...)
+	b = append(b, cborText("attStmt")...)
+	b = append(b, cborHead(cborMap, 0)...)
+	b = append(b, cborText("authData")...)
+	b = append(b, cborBytes(authData)...)
+	return b
+}
+
+func TestRegisterAndAssert(t *testing.T) {
+	const rpID = "licenses.example.com"
+	const origin = "https://licenses.example.com"
+
+	authenticators := map[string]testAuthenticator{
+		"ES256": newTestAuthenticatorES256(t),
+		"EdDSA": newTestAuthenticatorEdDSA(t),
+	}
+
+	for name, a := range authenticators {
+		t.Run(name, func(t *testing.T) {
+			//Register.
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cdj := clientDataJSON(t, "webauthn.create", challenge, origin)
+			ao := attestationObject(a.authData(rpID, flagUserPresent|flagAttestedCredentialData, 0, true))
+
+			c, err := VerifyRegistration(rpID, origin, challenge, cdj, ao)
+			if err != nil {
+				t.Fatal("registration failed", err)
+			}
+			if string(c.ID) != string(a.credentialID) {
+				t.Fatal("credential ID mismatch")
+			}
+
+			//Assert.
+			challenge, err = NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cdj = clientDataJSON(t, "webauthn.get", challenge, origin)
+			ad := a.authData(rpID, flagUserPresent, 1, false)
+			cdjHash := sha256.Sum256(cdj)
+			sig := a.sign(append(append([]byte{}, ad...), cdjHash[:]...))
+
+			signCount, err := VerifyAssertion(rpID, origin, challenge, c, cdj, ad, sig)
+			if err != nil {
+				t.Fatal("assertion failed", err)
+			}
+			if signCount != 1 {
+				t.Fatal("sign count not returned", signCount)
+			}
+
+			//Wrong challenge.
+			_, err = VerifyAssertion(rpID, origin, "bad-challenge", c, cdj, ad, sig)
+			if err != ErrChallengeMismatch {
+				t.Fatal("expected challenge mismatch", err)
+			}
+
+			//Wrong relying party.
+			_, err = VerifyAssertion("example.org", "https://example.org", challenge, c, clientDataJSON(t, "webauthn.get", challenge, "https://example.org"), ad, sig)
+			if err != ErrRPIDMismatch {
+				t.Fatal("expected rp id mismatch", err)
+			}
+
+			//Modified signature.
+			badSig := append([]byte{}, sig...)
+			badSig[len(badSig)-1] ^= 0xff
+			_, err = VerifyAssertion(rpID, origin, challenge, c, cdj, ad, badSig)
+			if err != ErrBadSignature {
+				t.Fatal("expected bad signature", err)
+			}
+
+			//Counter did not increase.
+			c.SignCount = 1
+			_, err = VerifyAssertion(rpID, origin, challenge, c, cdj, ad, sig)
+			if err != ErrSignCount {
+				t.Fatal("expected sign count error", err)
+			}
+		})
+	}
+}
+
+func TestVerifyClientDataOrigin(t *testing.T) {
+	tests := []struct {
+		expected string
+		origin   string
+		err      error
+	}{
+		{"https://licenses.example.com", "https://licenses.example.com", nil},
+		{"https://licenses.example.com", "https://other.example.com", ErrOriginMismatch},
+		{"https://licenses.example.com", "https://example.com", ErrOriginMismatch},
+		{"https://licenses.example.com", "http://licenses.example.com", ErrOriginMismatch},
+		{"https://licenses.example.com", "https://licenses.example.com:8443", ErrOriginMismatch},
+		{"http://localhost:8007", "http://localhost:8007", nil},
+	}
+
+	for _, tt := range tests {
+		cdj := clientDataJSON(t, "webauthn.get", "abc", tt.origin)
+		err := verifyClientData(cdj, "webauthn.get", tt.expected, "abc")
+		if err != tt.err {
+			t.Fatal("unexpected result", tt.expected, tt.origin, err)
+		}
+	}
+}
+
+func TestDecodeCBORTruncated(t *testing.T) {
+	_, _, err := decodeCBOR(cborHead(cborByteString, 10))
+	if err != errCBORTruncated {
+		t.Fatal("expected truncated error", err)
+	}
+
+	_, _, err = decodeCBOR([]byte{cborArray<<5 | 31})
+	if err != errCBORUnsupport {
+		t.Fatal("expected unsupported error", err)
+	}
+}
@@ -38,8 +38,14 @@ if (document.getElementById("manageAPIKeys")) {
             keyData: {
                 Description: "",
                 K: "",
+                RestrictToApps: false,
+                AllowedIPs: "",
             } as apiKey,
 
+            //List of apps for assigning to an API key.
+            apps: [] as app[],
+            appSelectedID: 0,
+
             //Handle confirmation of revoke, so a single click cannot revoke an API
             //key.
             showRevokeConfirm: false,
@@ -55,6 +61,10 @@ if (document.getElementById("manageAPIKeys")) {
                 generate: "/api/api-keys/generate/",
                 revoke: "/api/api-keys/revoke/",
                 update: "/api/api-keys/update/",
+                getApps: "/api/apps/",
+                assignApp: "/api/api-keys/apps/assign/",
+                unassignApp: "/api/api-keys/apps/unassign/",
+                allowedIPs: "/api/api-keys/allowed-ips/",
             }
         },
         computed: {
@@ -137,6 +147,8 @@ if (document.getElementById("manageAPIKeys")) {
                 this.keyData = {
                     Description: "",
                     K: "",
+                    RestrictToApps: false,
+                    AllowedIPs: "",
                 } as apiKey;
                 this.apiKeySelectedID = 0;
                 this.showRevokeConfirm = false;
@@ -405,11 +417,182 @@ if (document.getElementById("manageAPIKeys")) {
                         manageAPIKeys.submitting = false;
                         return;
                     });
-            }
+            },
+
+            //getApps looks up the list of apps that can be assigned to an API key.
+            getApps: function () {
+                let data: Object = {};
+                fetch(get(this.urls.getApps, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageAPIKeys.msgLoad = err;
+                            manageAPIKeys.msgLoadType = msgTypes.danger;
+                            return;
+                        }
+
+                        manageAPIKeys.apps = j.Data || [];
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageAPIKeys.msgLoad = 'An unknown error occurred. Please try again.';
+                        manageAPIKeys.msgLoadType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //assignApp adds the chosen app to the list of apps the selected API key
+            //can create licenses for.
+            assignApp: function () {
+                this.changeApp(this.urls.assignApp, this.appSelectedID);
+                return;
+            },
+
+            //unassignApp removes an app from the list of apps the selected API key
+            //can create licenses for.
+            unassignApp: function (appID: number) {
+                this.changeApp(this.urls.unassignApp, appID);
+                return;
+            },
+
+            //changeApp handles assigning or unassigning an app to the selected API
+            //key and then refreshes the list of API keys so the list of assigned apps
+            //is updated.
+            changeApp: function (url: string, appID: number) {
+                //Make sure data isn't already being saved.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate.
+                this.msgSaveType = msgTypes.danger;
+                if (this.keyData.ID < 1) {
+                    this.msgSave = "Could not determine which API Key you want to change apps for.";
+                    return;
+                }
+                if (appID < 1) {
+                    this.msgSave = "Could not determine which app you chose.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Saving...";
+                this.submitting = true;
+
+                //Make API request.
+                let data: Object = {
+                    apiKeyID: this.keyData.ID,
+                    appID: appID,
+                };
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageAPIKeys.msgSave = err;
+                            manageAPIKeys.msgSaveType = msgTypes.danger;
+                            manageAPIKeys.submitting = false;
+                            return;
+                        }
+
+                        //Refresh the list of keys so the assigned apps are updated.
+                        manageAPIKeys.msgSaveType = "";
+                        manageAPIKeys.msgSave = "";
+                        manageAPIKeys.submitting = false;
+                        manageAPIKeys.appSelectedID = 0;
+
+                        manageAPIKeys.getKeys();
+                        setTimeout(function () {
+                            manageAPIKeys.showAPIKey();
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageAPIKeys.msgSave = 'An unknown error occurred. Please try again.';
+                        manageAPIKeys.msgSaveType = msgTypes.danger;
+                        manageAPIKeys.submitting = false;
+                        return;
+                    });
+            },
+
+            //saveAllowedIPs saves the list of IP addresses and CIDR ranges the
+            //selected API key can be used from.
+            saveAllowedIPs: function () {
+                //Make sure data isn't already being saved.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate.
+                this.msgSaveType = msgTypes.danger;
+                if (this.keyData.ID < 1) {
+                    this.msgSave = "Could not determine which API Key you want to set allowed IPs for.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Saving...";
+                this.submitting = true;
+
+                //Make API request.
+                let data: Object = {
+                    id: this.keyData.ID,
+                    allowedIPs: this.keyData.AllowedIPs,
+                };
+                fetch(post(this.urls.allowedIPs, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageAPIKeys.msgSave = err;
+                            manageAPIKeys.msgSaveType = msgTypes.danger;
+                            manageAPIKeys.submitting = false;
+                            return;
+                        }
+
+                        //Show the list as saved, which may be reformatted.
+                        manageAPIKeys.keyData.AllowedIPs = j.Data;
+
+                        //Show success.
+                        manageAPIKeys.msgSaveType = msgTypes.primary;
+                        manageAPIKeys.msgSave = "Changes saved!";
+                        setTimeout(function () {
+                            manageAPIKeys.msgSaveType = "";
+                            manageAPIKeys.msgSave = "";
+                            manageAPIKeys.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageAPIKeys.msgSave = 'An unknown error occurred. Please try again.';
+                        manageAPIKeys.msgSaveType = msgTypes.danger;
+                        manageAPIKeys.submitting = false;
+                        return;
+                    });
+            },
         },
         mounted() {
             //Get list of existing API keys on page load.
             this.getKeys();
+            this.getApps();
             return;
         }
     });
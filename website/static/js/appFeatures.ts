@@ -0,0 +1,387 @@
+/**
+ * appFeatures.ts
+ * This file handles adding, viewing, editing, and deleting the features defined for an
+ * app. Features are chosen when creating a license and the key of each enabled feature
+ * is stored in the license file.
+ */
+
+/// <reference path="common.ts" />
+/// <reference path="fetch.ts" />
+/// <reference path="types.ts" />
+
+if (document.getElementById("listAppFeatures")) {
+    //listAppFeatures handles displaying the list of features for an app. This does not
+    //handle adding a new feature which is done in a modal.
+    //@ts-ignore cannot find name Vue
+    var listAppFeatures = new Vue({
+        name: 'listAppFeatures',
+        delimiters: ['[[', ']]'],
+        el: '#listAppFeatures',
+        data: {
+            //App to look up features for. This is populated by setAppID.
+            appSelectedID: 0,
+
+            //List of features for this app.
+            features: [] as appFeature[],
+            featuresRetrieved: false,
+
+            //errors
+            msgLoad: '',
+            msgLoadType: '',
+
+            collapseUI: false, //collapse the card to take up less screen space.
+
+            //endpoints
+            urls: {
+                get: "/api/apps/features/",
+            }
+        },
+        methods: {
+            //setAppID sets the appSelectedID value in this vue object. This is called from
+            //manageApps.setAppInOtherVueObjects() when an app is chosen from the list of
+            //defined apps. This then retrieves the list of features for this app.
+            setAppID: function (appID: number) {
+                this.appSelectedID = appID;
+
+                //handle adding a new app.
+                if (appID === 0) {
+                    this.features = [];
+                    this.msgLoad = "";
+                    return;
+                }
+
+                this.getFeatures();
+                return;
+            },
+
+            //getFeatures gets the list of features that have been defined for this app.
+            getFeatures: function () {
+                let data: Object = {
+                    appID: this.appSelectedID,
+                    activeOnly: true,
+                };
+                fetch(get(this.urls.get, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            listAppFeatures.msgLoad = err;
+                            listAppFeatures.msgLoadType = msgTypes.danger;
+                            return;
+                        }
+
+                        //save data to display in gui
+                        listAppFeatures.features = j.Data || [];
+                        listAppFeatures.featuresRetrieved = true;
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        listAppFeatures.msgLoad = 'An unknown error occured. Please try again.';
+                        listAppFeatures.msgLoadType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //passToModal handles the clicking of buttons/icons that open the add/edit
+            //feature modal. When adding a new feature, 'undefined' is passed along. When
+            //editing a feature, the feature's data is passed along so we don't need to
+            //retrieve it again.
+            //
+            //Note that this does not open the modal. Opening of the modal is handled through
+            //bootstrap data-toggle and data-target attributes.
+            passToModal: function (item: appFeature | undefined) {
+                modalAppFeature.setModalData(item);
+                return
+            },
+        }
+    })
+}
+
+if (document.getElementById("modal-appFeature")) {
+    //modalAppFeature handles adding a new feature, editing a feature's name, and
+    //deleting a feature.
+    //@ts-ignore cannot find name Vue
+    var modalAppFeature = new Vue({
+        name: 'modalAppFeature',
+        delimiters: ['[[', ']]'],
+        el: '#modal-appFeature',
+        data: {
+            //App the feature is for. This is populated by setAppID. This is mostly used
+            //for setting the modal form to a default state for adding a new feature.
+            appSelectedID: 0,
+
+            //feature data. Populated by setModalData with either blank data when adding a
+            //new feature or data about an existing feature when editing.
+            featureData: {} as appFeature,
+
+            //errors
+            submitting: false,
+            msgSave: '',
+            msgSaveType: '',
+
+            //endpoints
+            urls: {
+                add: "/api/apps/features/add/",
+                update: "/api/apps/features/update/",
+                delete: "/api/apps/features/delete/",
+            }
+        },
+        computed: {
+            //adding is set to true when the user is adding a new feature. This is used to
+            //modify what the GUI displays and to prevent changing the key of a feature.
+            adding: function () {
+                if (this.featureData.ID === undefined || this.featureData.ID < 1) {
+                    return true;
+                }
+
+                return false;
+            },
+        },
+        methods: {
+            //setAppID sets the appSelectedID value in this vue object. This is called from
+            //manageApps.setAppInOtherVueObjects() when an app is chosen from the list of
+            //defined apps.
+            setAppID: function (appID: number) {
+                this.appSelectedID = appID;
+                return;
+            },
+
+            //setModalData is used to populate the modal with data from the clicked feature
+            //in the list of features. This is also used to reset the modal to a clean state
+            //when adding a new feature.
+            setModalData: function (item: appFeature | undefined) {
+                //Always reset.
+                this.resetModal();
+
+                //User wants to add a new feature.
+                if (item === undefined) {
+                    return;
+                }
+
+                //User is editing a feature. Copy the data so that changes aren't shown
+                //in the list of features until they are saved.
+                this.featureData = Object.assign({}, item);
+                return;
+            },
+
+            //resetModal sets the modal back to a clean state for adding a new feature.
+            resetModal: function () {
+                this.featureData = {
+                    ID: 0,
+                    DatetimeCreated: "", //wont be set, just to match type.
+                    DatetimeModified: "", //" " "
+                    CreatedByUserID: 0,  //" " "
+                    Active: true,
+                    AppID: this.appSelectedID,
+                    Name: "",
+                    FeatureKey: "",
+                } as appFeature;
+
+                this.submitting = false;
+                this.msgSave = "";
+                this.msgSaveType = "";
+                return;
+            },
+
+            //addOrUpdate validates the data in the form and then calls the correct
+            //function to save a new feature or update an existing feature.
+            addOrUpdate: function () {
+                //validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.featureData.Name.trim() === "") {
+                    this.msgSave = "You must provide a name for this feature.";
+                    return;
+                }
+                if (this.featureData.FeatureKey.trim() === "") {
+                    this.msgSave = "You must provide a key for this feature.";
+                    return;
+                }
+
+                //call correct function
+                if (this.adding) {
+                    this.add();
+                }
+                else {
+                    this.update();
+                }
+
+                return;
+            },
+
+            //add saves a new feature. The modal will then be reset to allow adding another
+            //feature. The list of features will also be updated (in parent card). This is
+            //called from addOrUpdate().
+            add: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Adding...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    data: JSON.stringify(this.featureData),
+                };
+                fetch(post(this.urls.add, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalAppFeature.msgSave = err;
+                            modalAppFeature.msgSaveType = msgTypes.danger;
+                            modalAppFeature.submitting = false;
+                            return;
+                        }
+
+                        //Refresh the list of features so that this new feature is shown.
+                        listAppFeatures.getFeatures();
+
+                        //Show success message briefly and reset the modal to an empty
+                        //state so user can add another feature.
+                        modalAppFeature.msgSave = "Added!";
+                        modalAppFeature.msgSaveType = msgTypes.success;
+                        setTimeout(function () {
+                            modalAppFeature.resetModal();
+                            modalAppFeature.msgSave = '';
+                            modalAppFeature.msgSaveType = '';
+                            modalAppFeature.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalAppFeature.msgSave = 'An unknown error occured. Please try again.';
+                        modalAppFeature.msgSaveType = msgTypes.danger;
+                        modalAppFeature.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //update saves changes to an existing feature. Only the name can be changed.
+            //This is called from addOrUpdate().
+            update: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Saving...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    data: JSON.stringify(this.featureData),
+                };
+                fetch(post(this.urls.update, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalAppFeature.msgSave = err;
+                            modalAppFeature.msgSaveType = msgTypes.danger;
+                            modalAppFeature.submitting = false;
+                            return;
+                        }
+
+                        //Refresh the list of features so the new name is shown.
+                        listAppFeatures.getFeatures();
+
+                        modalAppFeature.msgSave = "Changes saved!";
+                        modalAppFeature.msgSaveType = msgTypes.success;
+                        setTimeout(function () {
+                            modalAppFeature.msgSave = '';
+                            modalAppFeature.msgSaveType = '';
+                            modalAppFeature.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalAppFeature.msgSave = 'An unknown error occured. Please try again.';
+                        modalAppFeature.msgSaveType = msgTypes.danger;
+                        modalAppFeature.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //remove marks a feature as inactive. Inactive features will no longer show up
+            //in the list of features or be available when creating a new license.
+            remove: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //Make sure we know what feature we are deleting.
+                if (isNaN(this.featureData.ID) || this.featureData.ID < 1) {
+                    this.msgSave = "Could not determine which feature you are trying to delete. Please refresh the page and try again.";
+                    this.msgSaveType = msgTypes.danger;
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Deleting...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    id: this.featureData.ID,
+                };
+                fetch(post(this.urls.delete, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalAppFeature.msgSave = err;
+                            modalAppFeature.msgSaveType = msgTypes.danger;
+                            return;
+                        }
+
+                        //refresh the list of features in table. The modal will be
+                        //closed by the data-dismiss on the button clicked.
+                        listAppFeatures.getFeatures();
+                        modalAppFeature.submitting = false;
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalAppFeature.msgSave = 'An unknown error occured. Please try again.';
+                        modalAppFeature.msgSaveType = msgTypes.danger;
+                        modalAppFeature.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        }
+    })
+}
@@ -56,7 +56,7 @@ if (document.getElementById("manageApp")) {
                         //@ts-ignore Vue not found
                         Vue.nextTick(function () {
                             for (let key in manageApp.settings) {
-                                if (key === "ID" || key === "DatetimeModified") {
+                                if (key === "ID" || key === "DatetimeModified" || key.startsWith("Announcement")) {
                                     continue;
                                 }
                                 setToggle(key, manageApp.settings[key]);
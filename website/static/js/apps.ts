@@ -47,6 +47,8 @@ if (document.getElementById("manageApps")) {
                 get: "/api/apps/",
                 add: "/api/apps/add/",
                 update: "/api/apps/update/",
+                export: "/api/apps/export/",
+                bumpLicenseSchemaVersion: "/api/apps/license-schema-version/bump/",
             },
         },
         computed: {
@@ -76,6 +78,15 @@ if (document.getElementById("manageApps")) {
                     DaysToExpiration: 365,
                     FileFormat: this.defaultFileFormat,
                     DownloadFilename: "",
+                    FileHeaderText: "",
+                    FriendlyIDFormat: "",
+                    FriendlyIDPrefix: "",
+                    AppLicenseNumbering: false,
+                    LastAppLicenseNumber: 0,
+                    RequiredSignatures: 1,
+                    LicenseSchemaVersion: 0,
+                    LicenseEmailSubject: "",
+                    LicenseEmailBody: "",
                     ShowLicenseID: true,
                     ShowAppName: true,
                     Active: true,
@@ -86,6 +97,7 @@ if (document.getElementById("manageApps")) {
                 Vue.nextTick(function () {
                     setToggle('ShowLicenseID', true);
                     setToggle('ShowAppName', true);
+                    setToggle('AppLicenseNumbering', false);
                     setToggle('Active', true);
                 });
 
@@ -183,6 +195,7 @@ if (document.getElementById("manageApps")) {
                     Vue.nextTick(function () {
                         setToggle('ShowLicenseID', a.ShowLicenseID);
                         setToggle('ShowAppName', a.ShowAppName);
+                        setToggle('AppLicenseNumbering', a.AppLicenseNumbering);
                         setToggle('Active', a.Active);
                     });
 
@@ -204,6 +217,9 @@ if (document.getElementById("manageApps")) {
                 listCustomFieldsDefined.setAppID(appID);
                 modalCustomFieldDefined.setAppID(appID);
 
+                listAppFeatures.setAppID(appID);
+                modalAppFeature.setAppID(appID);
+
                 return;
             },
 
@@ -330,6 +346,24 @@ if (document.getElementById("manageApps")) {
                 return;
             },
 
+            //passToCloneModal sets the app to clone in the clone modal.
+            passToCloneModal: function () {
+                modalCloneApp.sourceAppID = this.appData.ID;
+                modalCloneApp.sourceAppName = this.appData.Name;
+                modalCloneApp.name = this.appData.Name + " (copy)";
+                modalCloneApp.msgSave = "";
+                modalCloneApp.msgSaveType = "";
+                return;
+            },
+
+            //passToImportModal resets the import modal.
+            passToImportModal: function () {
+                modalImportApp.name = "";
+                modalImportApp.msgSave = "";
+                modalImportApp.msgSaveType = "";
+                return;
+            },
+
             //update saves changes to an existing app. This is called from addOrUpdate().
             update: function () {
                 //make sure data isn't already being submitted
@@ -388,10 +422,268 @@ if (document.getElementById("manageApps")) {
                 return;
 
             },
+
+            //bumpLicenseSchemaVersion increments the app's license schema version. This is
+            //saved immediately, separately from other changes to the app, since it is not
+            //editable.
+            bumpLicenseSchemaVersion: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //Make sure we know what app we are updating.
+                if (isNaN(this.appData.ID) || this.appData.ID === '' || this.appData.ID < 1) {
+                    this.msgSave = "Could not determine which app you are trying to update. Please refresh the page and try again.";
+                    this.msgSaveType = msgTypes.danger;
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Bumping license schema version...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    appID: this.appData.ID,
+                };
+                fetch(post(this.urls.bumpLicenseSchemaVersion, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageApps.msgSave = err;
+                            manageApps.msgSaveType = msgTypes.danger;
+                            manageApps.submitting = false;
+                            return;
+                        }
+
+                        manageApps.appData.LicenseSchemaVersion = j.Data;
+
+                        manageApps.msgSave = "License schema version bumped! New licenses will use version " + j.Data + ".";
+                        manageApps.msgSaveType = msgTypes.success;
+                        setTimeout(function () {
+                            manageApps.msgSave = '';
+                            manageApps.msgSaveType = '';
+                            manageApps.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageApps.msgSave = 'An unknown error occured. Please try again.';
+                        manageApps.msgSaveType = msgTypes.danger;
+                        manageApps.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
         },
         mounted() {
             this.getApps();
             return;
         }
     })
-}
\ No newline at end of file
+}
+
+if (document.getElementById("modal-cloneApp")) {
+    //@ts-ignore cannot find name Vue
+    var modalCloneApp = new Vue({
+        name: 'modalCloneApp',
+        delimiters: ['[[', ']]'],
+        el: '#modal-cloneApp',
+        data: {
+            sourceAppID: 0,      //set in manageApps.passToCloneModal().
+            sourceAppName: "",   //""
+            name: "",            //name of new app.
+            generateKeyPair: true,
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                clone: "/api/apps/clone/",
+            },
+        },
+        methods: {
+            //clone creates a new app with the settings and custom fields of an
+            //existing app. Once cloned, the new app is shown.
+            clone: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.sourceAppID < 1) {
+                    this.msgSave = "Could not determine which app you want to clone.";
+                    return;
+                }
+                if (this.name === "") {
+                    this.msgSave = "You must provide the name for the new app.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Cloning...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    appID: this.sourceAppID,
+                    name: this.name,
+                    generateKeyPair: this.generateKeyPair,
+                };
+                fetch(post(this.urls.clone, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalCloneApp.msgSave = err;
+                            modalCloneApp.msgSaveType = msgTypes.danger;
+                            modalCloneApp.submitting = false;
+                            return;
+                        }
+
+                        //Refresh the list of apps and show the new app.
+                        manageApps.getApps();
+
+                        modalCloneApp.msgSave = "Cloned!";
+                        modalCloneApp.msgSaveType = msgTypes.success;
+                        setTimeout(function () {
+                            //@ts-ignore cannot find modal.
+                            $('#modal-cloneApp').modal('hide');
+
+                            manageApps.appSelectedID = j.Data;
+                            manageApps.showApp();
+
+                            modalCloneApp.msgSave = '';
+                            modalCloneApp.msgSaveType = '';
+                            modalCloneApp.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalCloneApp.msgSave = 'An unknown error occured. Please try again.';
+                        modalCloneApp.msgSaveType = msgTypes.danger;
+                        modalCloneApp.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-importApp")) {
+    //@ts-ignore cannot find name Vue
+    var modalImportApp = new Vue({
+        name: 'modalImportApp',
+        delimiters: ['[[', ']]'],
+        el: '#modal-importApp',
+        data: {
+            name: "", //optional, overrides the name of the exported app.
+            generateKeyPair: true,
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                import: "/api/apps/import/",
+            },
+        },
+        methods: {
+            //importApp creates a new app from an exported app configuration file. Once
+            //imported, the new app is shown.
+            importApp: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Make sure a file was chosen.
+                let input: HTMLInputElement = this.$refs.importFile;
+                if (!input.files || input.files.length === 0) {
+                    this.msgSave = "You must choose an exported app configuration file.";
+                    this.msgSaveType = msgTypes.danger;
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Importing...";
+                this.submitting = true;
+
+                //Read the file and perform api call.
+                input.files[0].text()
+                    .then(function (contents: string) {
+                        let data: Object = {
+                            data: contents,
+                            name: modalImportApp.name,
+                            generateKeyPair: modalImportApp.generateKeyPair,
+                        };
+                        return fetch(post(modalImportApp.urls.import, data));
+                    })
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalImportApp.msgSave = err;
+                            modalImportApp.msgSaveType = msgTypes.danger;
+                            modalImportApp.submitting = false;
+                            return;
+                        }
+
+                        //Refresh the list of apps and show the new app.
+                        manageApps.getApps();
+
+                        modalImportApp.msgSave = "Imported!";
+                        modalImportApp.msgSaveType = msgTypes.success;
+                        setTimeout(function () {
+                            //@ts-ignore cannot find modal.
+                            $('#modal-importApp').modal('hide');
+
+                            manageApps.appSelectedID = j.Data;
+                            manageApps.showApp();
+
+                            modalImportApp.msgSave = '';
+                            modalImportApp.msgSaveType = '';
+                            modalImportApp.submitting = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalImportApp.msgSave = 'An unknown error occured. Please try again.';
+                        modalImportApp.msgSaveType = msgTypes.danger;
+                        modalImportApp.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
@@ -202,4 +202,41 @@ function isValidDate(dateString: string): boolean {
     //issues, or other errors that would make the date invalid.
     var regEx: RegExp = /^\d{4}-\d{2}-\d{2}$/;
     return dateString.match(regEx) != null;
-}
\ No newline at end of file
+}
+
+//base64URLToBuffer decodes a base64url string, as used by WebAuthn and returned by
+//the server, into an ArrayBuffer that can be passed to navigator.credentials.
+/**
+ * @param {string} s - A base64url encoded string without padding.
+ * @returns {ArrayBuffer} - The decoded bytes.
+ */
+function base64URLToBuffer(s: string): ArrayBuffer {
+    let b64: string = s.replace(/-/g, "+").replace(/_/g, "/");
+    while (b64.length % 4 !== 0) {
+        b64 += "=";
+    }
+
+    let raw: string = atob(b64);
+    let bytes: Uint8Array = new Uint8Array(raw.length);
+    for (let i = 0; i < raw.length; i++) {
+        bytes[i] = raw.charCodeAt(i);
+    }
+
+    return bytes.buffer;
+}
+
+//bufferToBase64URL encodes bytes returned by navigator.credentials into a base64url 
+//string, without padding, for sending to the server.
+/**
+ * @param {ArrayBuffer} b - The bytes to encode.
+ * @returns {string} - A base64url encoded string without padding.
+ */
+function bufferToBase64URL(b: ArrayBuffer): string {
+    let bytes: Uint8Array = new Uint8Array(b);
+    let raw: string = "";
+    for (let i = 0; i < bytes.length; i++) {
+        raw += String.fromCharCode(bytes[i]);
+    }
+
+    return btoa(raw).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
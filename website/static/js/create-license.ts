@@ -36,6 +36,11 @@ if (document.getElementById("createLicense")) {
             fields: [],
             fieldsRetrieved: false,
 
+            //loading any features, based on app chosen, and the keys of the features
+            //the user has enabled for this license.
+            features: [] as appFeature[],
+            featuresSelected: [] as string[],
+
             //need these for v-if in html
             customFieldTypeInteger: customFieldTypeInteger,
             customFieldTypeDecimal: customFieldTypeDecimal,
@@ -43,9 +48,27 @@ if (document.getElementById("createLicense")) {
             customFieldTypeBoolean: customFieldTypeBoolean,
             customFieldTypeMultiChoice: customFieldTypeMultiChoice,
             customFieldTypeDate: customFieldTypeDate,
+            customFieldTypeEmail: customFieldTypeEmail,
+            customFieldTypeURL: customFieldTypeURL,
+            customFieldTypeFileHash: customFieldTypeFileHash,
 
             separator: ";", //separator for multichoice options
 
+            //templates the license can be created from. Choosing a template fills in
+            //the app, key pair, expiration, custom fields, and features.
+            templates: [] as licenseTemplate[],
+            templatesRetrieved: false,
+            templateSelectedID: 0,
+            templateApplied: null as licenseTemplateApplied | null,
+            confirmDeleteTemplate: false,
+
+            //saving the chosen app, key pair, etc. as a new template.
+            showSaveTemplate: false,
+            templateName: "",
+            submittingTemplate: false,
+            msgTemplate: "",
+            msgTemplateType: "",
+
             //data for license being created
             licenseData: {
                 KeyPairID: 0, //from chosen/default keypair for app
@@ -54,8 +77,30 @@ if (document.getElementById("createLicense")) {
                 PhoneNumber: "123-555-1212",
                 Email: "wyle@example.com",
                 ExpireDate: "", //by default, this is set to "today" plus the app's DaysToExpiration
+                ExpireDatetime: "", //optional, set from ExpireDate and expireTime when the license is created.
+                ValidFrom: "", //optional
+                OrderReference: "",
+                InternalNotes: "",
             } as license,
 
+            //optional time, HH:MM in the browser's timezone, the license expires at on
+            //the expiration date. Leave blank for the license to expire at the end of
+            //the expiration date.
+            expireTime: "",
+
+            //preview of license file, set when user previews the license before
+            //creating it.
+            previewData: null as licensePreview,
+
+            //2FA token or passkey, when the Require2FAForLicenseCreation app setting
+            //is enabled and the user is enrolled in 2FA. The input is only shown once
+            //the server responds that a token is required and the user can provide a
+            //token. Users with a passkey are prompted for their passkey.
+            twoFATokenRequired: false,
+            twoFAToken: "",
+            passkeyOptions: null as passkeyLoginOptions,
+            passkeyAssertion: {} as Object,
+
             //errors when loading data or creating license
             submitting: false,
             msg: '',
@@ -66,8 +111,14 @@ if (document.getElementById("createLicense")) {
                 getApps: "/api/apps/",
                 getKeyPairs: "/api/key-pairs/",
                 getCustomFields: "/api/custom-fields/defined/",
+                getFeatures: "/api/apps/features/",
                 add: "/api/licenses/add/",
+                preview: "/api/licenses/preview/",
                 getAPIKeys: "/api/api-keys/",
+                getTemplates: "/api/licenses/templates/",
+                applyTemplate: "/api/licenses/templates/apply/",
+                addTemplate: "/api/licenses/templates/add/",
+                deleteTemplate: "/api/licenses/templates/delete/",
             },
 
             //Used for displaying the API builder. This shows a GUI of the API
@@ -148,12 +199,58 @@ if (document.getElementById("createLicense")) {
                         //set the default keypair in the select menu and the
                         //license data to save
                         for (let kp of (createLicense.keyPairs as keyPair[])) {
-                            if (kp.IsDefault) {
+                            if (kp.IsDefault && !kp.Compromised) {
                                 createLicense.licenseData.KeyPairID = kp.ID;
                                 break;
                             }
                         }
 
+                        //use the template's key pair, if a template was chosen.
+                        if (createLicense.templateApplied !== null && createLicense.templateApplied.License.KeyPairID > 0) {
+                            createLicense.licenseData.KeyPairID = createLicense.templateApplied.License.KeyPairID;
+                        }
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msg = 'An unknown error occured. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //getFeatures gets the list of features that have been defined for the chosen
+            //app. Any previously enabled features are cleared since features are per-app.
+            getFeatures: function () {
+                this.featuresSelected = [];
+
+                let data: Object = {
+                    appID: this.appSelectedID,
+                    activeOnly: true,
+                };
+                fetch(get(this.urls.getFeatures, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msg = err;
+                            createLicense.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        //save data to display in gui
+                        createLicense.features = j.Data || [];
+
+                        //enable the template's features, if a template was chosen.
+                        if (createLicense.templateApplied !== null) {
+                            createLicense.featuresSelected = createLicense.templateApplied.Features || [];
+                        }
+
                         return;
                     })
                     .catch(function (err) {
@@ -199,6 +296,9 @@ if (document.getElementById("createLicense")) {
                                     f.DecimalValue = f.DecimalDefaultValue;
                                     break;
                                 case customFieldTypeText:
+                                case customFieldTypeEmail:
+                                case customFieldTypeURL:
+                                case customFieldTypeFileHash:
                                     f.TextValue = f.TextDefaultValue;
                                     break;
                                 case customFieldTypeBoolean:
@@ -222,6 +322,9 @@ if (document.getElementById("createLicense")) {
                             } //end switch: set default for field type.
                         } //end for: loop through each field setting default value.
 
+                        //use the template's values, if a template was chosen.
+                        createLicense.setTemplateFieldValues();
+
                         return;
                     })
                     .catch(function (err) {
@@ -234,6 +337,292 @@ if (document.getElementById("createLicense")) {
                 return;
             },
 
+            //getTemplates gets the list of templates a license can be created from.
+            getTemplates: function () {
+                let data: Object = {};
+                fetch(get(this.urls.getTemplates, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msg = err;
+                            createLicense.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        //save data to display in gui
+                        createLicense.templates = j.Data || [];
+                        createLicense.templatesRetrieved = true;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msg = 'An unknown error occured. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //useTemplate fills in the app, key pair, expiration, custom fields, and
+            //features from the chosen template. The template's values are set once the
+            //app's key pairs, custom fields, and features are retrieved.
+            useTemplate: function () {
+                if (this.templateSelectedID < 1) {
+                    this.templateApplied = null;
+                    return;
+                }
+
+                let data: Object = {
+                    id: this.templateSelectedID,
+                };
+                fetch(get(this.urls.applyTemplate, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msg = err;
+                            createLicense.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        let t: licenseTemplateApplied = j.Data;
+                        createLicense.templateApplied = t;
+                        createLicense.appSelectedID = t.License.AppID;
+                        createLicense.licenseData.ExpireDate = t.License.ExpireDate;
+
+                        createLicense.getKeyPairs();
+                        createLicense.getCustomFields();
+                        createLicense.getFeatures();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msg = 'An unknown error occured. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //clearTemplate unsets the chosen template. This is called when the user
+            //chooses a different app since the template no longer applies.
+            clearTemplate: function () {
+                this.templateSelectedID = 0;
+                this.templateApplied = null;
+                return;
+            },
+
+            //setTemplateFieldValues sets the value of each custom field the chosen
+            //template has a value for. This is called after the custom fields for the
+            //app are retrieved and default values are set.
+            setTemplateFieldValues: function () {
+                if (this.templateApplied === null) {
+                    return;
+                }
+
+                for (let tf of (this.templateApplied.CustomFields || [])) {
+                    for (let f of (this.fields as customFieldDefined[])) {
+                        if (f.ID !== tf.CustomFieldDefinedID || f.Type !== tf.CustomFieldType) {
+                            continue;
+                        }
+
+                        switch (f.Type) {
+                            case customFieldTypeInteger:
+                                f.IntegerValue = tf.IntegerValue;
+                                break;
+                            case customFieldTypeDecimal:
+                                f.DecimalValue = tf.DecimalValue;
+                                break;
+                            case customFieldTypeText:
+                            case customFieldTypeEmail:
+                            case customFieldTypeURL:
+                            case customFieldTypeFileHash:
+                                f.TextValue = tf.TextValue;
+                                break;
+                            case customFieldTypeBoolean:
+                                f.BoolValue = tf.BoolValue;
+                                //@ts-ignore cannot find Vue
+                                Vue.nextTick(function () {
+                                    let elemID: string = 'cf_bool_id_' + f.ID.toString();
+                                    setToggle(elemID, tf.BoolValue);
+                                });
+                                break;
+                            case customFieldTypeMultiChoice:
+                                f.MultiChoiceValue = tf.MultiChoiceValue;
+                                break;
+                            case customFieldTypeDate:
+                                f.DateValue = tf.DateValue;
+                                break;
+                        }
+                    }
+                }
+
+                return;
+            },
+
+            //saveTemplate saves the chosen app, key pair, license period, custom field
+            //values, and features as a new template. The license period is the number
+            //of days from today until the chosen expiration date.
+            saveTemplate: function () {
+                //validate
+                this.msgTemplateType = msgTypes.danger;
+                if (this.appSelectedID < 1) {
+                    this.msgTemplate = "You must choose an app.";
+                    return;
+                }
+                if (this.templateName.trim() === "") {
+                    this.msgTemplate = "You must provide a name for this template.";
+                    return;
+                }
+
+                //make sure data isn't already being submitted
+                if (this.submittingTemplate) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //Use the app's default key pair, instead of the chosen key pair, if the
+                //default key pair was chosen. This way the template follows any change
+                //to the app's default key pair.
+                let keyPairID: number = this.licenseData.KeyPairID;
+                for (let kp of (this.keyPairs as keyPair[])) {
+                    if (kp.ID === keyPairID && kp.IsDefault) {
+                        keyPairID = 0;
+                        break;
+                    }
+                }
+
+                //Days from today until the chosen expiration date. Both dates are parsed
+                //as UTC so the difference is a whole number of days.
+                let days: number = Math.round((Date.parse(this.licenseData.ExpireDate) - Date.parse(this.today())) / 86400000);
+                if (isNaN(days) || days < 1) {
+                    days = 0;
+                }
+
+                //Build the custom field values. The defined field's ID may have already
+                //been moved if creating a license was attempted.
+                let fields: Object[] = [];
+                for (let cf of this.fields) {
+                    fields.push({
+                        CustomFieldDefinedID: (cf.ID > 0) ? cf.ID : cf.CustomFieldDefinedID,
+                        IntegerValue: cf.IntegerValue,
+                        DecimalValue: cf.DecimalValue,
+                        TextValue: cf.TextValue,
+                        BoolValue: cf.BoolValue,
+                        MultiChoiceValue: cf.MultiChoiceValue,
+                        DateValue: cf.DateValue,
+                    });
+                }
+
+                //validation ok
+                this.msgTemplate = "Saving...";
+                this.msgTemplateType = msgTypes.primary;
+                this.submittingTemplate = true;
+
+                //perform api call
+                let t: Object = {
+                    Name: this.templateName.trim(),
+                    AppID: this.appSelectedID,
+                    KeyPairID: keyPairID,
+                    DurationDays: days,
+                    CustomFields: JSON.stringify(fields),
+                };
+                let data: Object = {
+                    data: JSON.stringify(t),
+                    features: JSON.stringify(this.featuresSelected),
+                };
+                fetch(post(this.urls.addTemplate, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msgTemplate = err;
+                            createLicense.msgTemplateType = msgTypes.danger;
+                            createLicense.submittingTemplate = false;
+                            return;
+                        }
+
+                        //Refresh the list of templates so this new template can be chosen.
+                        createLicense.getTemplates();
+
+                        createLicense.msgTemplate = "Template saved!";
+                        createLicense.msgTemplateType = msgTypes.success;
+                        setTimeout(function () {
+                            createLicense.msgTemplate = '';
+                            createLicense.msgTemplateType = '';
+                            createLicense.templateName = '';
+                            createLicense.showSaveTemplate = false;
+                            createLicense.submittingTemplate = false;
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msgTemplate = 'An unknown error occured. Please try again.';
+                        createLicense.msgTemplateType = msgTypes.danger;
+                        createLicense.submittingTemplate = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //handleDeleteTemplateConfirm shows the "confirm" button for deleting the
+            //chosen template. The "confirm" button is reverted after a short amount of
+            //time so that a user must perform the "confirm" quickly.
+            handleDeleteTemplateConfirm: function () {
+                this.confirmDeleteTemplate = true;
+
+                setTimeout(function () {
+                    createLicense.confirmDeleteTemplate = false;
+                }, 3000);
+
+                return;
+            },
+
+            //deleteTemplate removes the chosen template. Licenses already created from
+            //the template are not changed.
+            deleteTemplate: function () {
+                let data: Object = {
+                    id: this.templateSelectedID,
+                };
+                fetch(post(this.urls.deleteTemplate, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msg = err;
+                            createLicense.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        createLicense.clearTemplate();
+                        createLicense.confirmDeleteTemplate = false;
+                        createLicense.getTemplates();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msg = 'An unknown error occured. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
             //setExpireDate sets the default expiration date for the license to 
             //today's date plus the app's DaysToExpiration value. This is called when 
             //an app is chosen from the select menu.
@@ -275,7 +664,10 @@ if (document.getElementById("createLicense")) {
             //signature is created and the data is saved to the db. Upon success, the
             //user will be redirected to a new page to view the details of this license
             //and download the license file.
-            create: function () {
+            //
+            //If preview is true, the data is validated and the license file is built
+            //and shown to the user, but nothing is saved.
+            create: function (preview: boolean) {
                 //validate
                 //common fields
                 this.msgType = msgTypes.danger;
@@ -311,6 +703,10 @@ if (document.getElementById("createLicense")) {
                     this.msg = "You must provide an expiration date for the license.";
                     return;
                 }
+                if (this.licenseData.ValidFrom !== "" && this.licenseData.ValidFrom >= this.licenseData.ExpireDate) {
+                    this.msg = "The valid from date must be before the expiration date.";
+                    return;
+                }
 
                 //custom fields
                 for (let i = 0; i < this.fields.length; i++) {
@@ -343,7 +739,36 @@ if (document.getElementById("createLicense")) {
                             break;
 
                         case customFieldTypeText:
-                            //blank values are acceptable for text fields.
+                            //blank values are acceptable for text fields, unless the
+                            //field has a validation regex. The regex is validated
+                            //server side as well.
+                            if (cf.TextValidationRegex !== "") {
+                                try {
+                                    let rx: RegExp = new RegExp(cf.TextValidationRegex);
+                                    if (!rx.test((cf.TextValue || "").trim())) {
+                                        this.msg = "The value for the " + cf.Name + " field is not in the required format.";
+                                        return;
+                                    }
+                                } catch (e) {
+                                    //Regex syntax isn't supported in the browser, rely
+                                    //on the server to validate.
+                                }
+                            }
+                            break;
+
+                        case customFieldTypeEmail:
+                        case customFieldTypeURL:
+                            //blank values are acceptable. The format of a provided
+                            //value is validated server side.
+                            break;
+
+                        case customFieldTypeFileHash:
+                            //a value is required since the license is bound to a
+                            //specific build.
+                            if (!/^[0-9a-fA-F]{64}$/.test((cf.TextValue || "").trim())) {
+                                this.msg = "The value for the " + cf.Name + " field must be a SHA-256 hash, 64 hexadecimal characters.";
+                                return;
+                            }
                             break;
 
                         case customFieldTypeBoolean:
@@ -364,6 +789,10 @@ if (document.getElementById("createLicense")) {
                                 this.msg = "You must choose a date for the " + cf.Name + "field.";
                                 return;
                             }
+                            if (cf.CannotExceedLicenseExpiration && cf.DateValue > this.licenseData.ExpireDate) {
+                                this.msg = "The date for the " + cf.Name + " field cannot be after the license's expiration date, " + this.licenseData.ExpireDate + ".";
+                                return;
+                            }
                             break;
 
                         default:
@@ -404,6 +833,13 @@ if (document.getElementById("createLicense")) {
                     return;
                 }
 
+                //Set the more precise expiration if a time was provided.
+                this.licenseData.ExpireDatetime = this.getExpireDatetime();
+                if (this.expireTime !== "" && this.licenseData.ExpireDatetime === "") {
+                    this.msg = "The expiration time you provided is not valid.";
+                    return;
+                }
+
                 //validation ok
                 this.msg = "Saving...";
                 this.msgType = msgTypes.primary;
@@ -413,11 +849,36 @@ if (document.getElementById("createLicense")) {
                 let data: Object = {
                     licenseData: JSON.stringify(this.licenseData),
                     customFields: JSON.stringify(this.fields),
+                    features: JSON.stringify(this.featuresSelected),
                 };
+
+                if (preview) {
+                    this.msg = "Building preview...";
+                    this.getPreview(data);
+                    return;
+                }
+
+                if (this.twoFAToken !== "") {
+                    data["twoFAToken"] = this.twoFAToken;
+                }
+                data = { ...data, ...this.passkeyAssertion };
+
                 fetch(post(this.urls.add, data))
                     .then(handleRequestErrors)
                     .then(getJSON)
                     .then(function (j) {
+                        //check if the server requires a 2FA token to create the license,
+                        //see users.MsgType2FAStepUpRequired.
+                        if (j.Type === "2FAStepUpRequired") {
+                            createLicense.passkeyOptions = j.Data || null;
+                            createLicense.twoFATokenRequired = createLicense.passkeyOptions === null || createLicense.passkeyOptions.TOTPAllowed;
+                            createLicense.twoFAToken = "";
+                            createLicense.msg = j.ErrorData.Message;
+                            createLicense.msgType = msgTypes.warning;
+                            createLicense.submitting = false;
+                            return;
+                        }
+
                         //check if response is an error from the server
                         let err: string = handleAPIErrors(j);
                         if (err !== '') {
@@ -438,7 +899,7 @@ if (document.getElementById("createLicense")) {
                         createLicense.msg = "License created! Redirecting to license...";
                         createLicense.msgType = msgTypes.primary;
                         setTimeout(function () {
-                            window.location.href = "/app/licensing/license/?id=" + licenseID;
+                            window.location.href = withBaseURLPath("/app/licensing/license/?id=" + licenseID);
                         }, defaultTimeout);
 
                         return;
@@ -454,6 +915,80 @@ if (document.getElementById("createLicense")) {
                 return;
             },
 
+            //usePasskey prompts the user for their passkey using the challenge provided
+            //by the server and then creates the license again with the signed
+            //challenge. This is used in place of a 2FA token.
+            usePasskey: function () {
+                let opts: PublicKeyCredentialRequestOptions = {
+                    challenge: base64URLToBuffer(this.passkeyOptions.Challenge),
+                    rpId: this.passkeyOptions.RPID,
+                    allowCredentials: this.passkeyOptions.AllowCredentialIDs.map(function (id: string) {
+                        return { type: "public-key", id: base64URLToBuffer(id) } as PublicKeyCredentialDescriptor;
+                    }),
+                    userVerification: "discouraged",
+                };
+
+                navigator.credentials.get({ publicKey: opts })
+                    .then(function (cred) {
+                        let c = cred as PublicKeyCredential;
+                        let res = c.response as AuthenticatorAssertionResponse;
+
+                        createLicense.passkeyAssertion = {
+                            passkeyCredentialID: bufferToBase64URL(c.rawId),
+                            passkeyClientDataJSON: bufferToBase64URL(res.clientDataJSON),
+                            passkeyAuthenticatorData: bufferToBase64URL(res.authenticatorData),
+                            passkeySignature: bufferToBase64URL(res.signature),
+                        };
+                        createLicense.create(false);
+
+                        //Clear the assertion since it can only be used once.
+                        createLicense.passkeyAssertion = {};
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("navigator.credentials.get() error: >>", err, "<<");
+                        createLicense.msg = 'Your passkey could not be used. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //getPreview builds the license file without saving it and shows it to the
+            //user. This is called from create() after the data was validated.
+            getPreview: function (data: Object) {
+                fetch(post(this.urls.preview, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            createLicense.msg = err;
+                            createLicense.msgType = msgTypes.danger;
+                            createLicense.submitting = false;
+                            return;
+                        }
+
+                        //show preview
+                        createLicense.previewData = j.Data;
+                        createLicense.msg = "";
+                        createLicense.msgType = "";
+                        createLicense.submitting = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        createLicense.msg = 'An unknown error occured. Please try again.';
+                        createLicense.msgType = msgTypes.danger;
+                        createLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
             //getAPIKeys gets the list of API keys. This is only done if a user clicks
             //the showAPIBuilder button and the list of API keys hasn't already been
             //looked up. We need these to more completely build the API request to 
@@ -490,6 +1025,22 @@ if (document.getElementById("createLicense")) {
                 return;
             },
 
+            //getExpireDatetime returns the more precise expiration, RFC3339 in UTC, from
+            //the expiration date and time. A blank string is returned if a time was not
+            //provided or the date and time are not valid.
+            getExpireDatetime: function (): string {
+                if (this.expireTime === "") {
+                    return "";
+                }
+
+                let expires: Date = new Date(this.licenseData.ExpireDate + "T" + this.expireTime);
+                if (isNaN(expires.getTime())) {
+                    return "";
+                }
+
+                return expires.toISOString().replace(/\.\d{3}Z$/, "Z");
+            },
+
             //buildAPIExample builds the curl request to create an API key from the
             //data the user chosen/provided. This is done to show the user an example
             //of an API call to create a license.
@@ -519,6 +1070,9 @@ if (document.getElementById("createLicense")) {
                             cf[f.Name] = f.DecimalValue;
                             break;
                         case customFieldTypeText:
+                        case customFieldTypeEmail:
+                        case customFieldTypeURL:
+                        case customFieldTypeFileHash:
                             cf[f.Name] = f.TextValue;
                             break;
                         case customFieldTypeBoolean:
@@ -540,7 +1094,7 @@ if (document.getElementById("createLicense")) {
 
                 //Build the lines of the example.
                 let lines: string[] = [
-                    "curl '" + proto + "//" + host + "/api/v1/licenses/add/'",
+                    "curl '" + proto + "//" + host + baseURLPath + "/api/v1/licenses/add/'",
                     "-X POST",
                     "-H 'Content-type: application/x-www-form-urlencoded'",
                     "-H 'Authorization: Bearer " + this.apiKeySelected + "'",
@@ -554,6 +1108,17 @@ if (document.getElementById("createLicense")) {
                     "-d fields=" + cfEncoded + "",
                 ];
 
+                let expireDatetime: string = this.getExpireDatetime();
+                if (expireDatetime !== "") {
+                    lines.splice(lines.length - 1, 0, "-d expireDatetime='" + expireDatetime + "'");
+                }
+                if (this.licenseData.ValidFrom !== "") {
+                    lines.splice(lines.length - 1, 0, "-d validFrom='" + this.licenseData.ValidFrom + "'");
+                }
+                if (this.featuresSelected.length > 0) {
+                    lines.splice(lines.length - 1, 0, "-d features=" + encodeURIComponent(JSON.stringify(this.featuresSelected)));
+                }
+
                 if (this.returnLicenseFile) {
                     lines.push("-d returnLicenseFile=true");
                 }
@@ -568,6 +1133,9 @@ if (document.getElementById("createLicense")) {
             //Load the apps the user can choose from.
             this.getApps();
 
+            //Load the templates the user can create a license from.
+            this.getTemplates();
+
             //Set some default stuff.
             setToggle("returnLicenseFile", false);
 
@@ -33,16 +33,23 @@ if (document.getElementById("listCustomFieldsDefined")) {
             customFieldTypeBoolean: customFieldTypeBoolean,
             customFieldTypeMultiChoice: customFieldTypeMultiChoice,
             customFieldTypeDate: customFieldTypeDate,
+            customFieldTypeEmail: customFieldTypeEmail,
+            customFieldTypeURL: customFieldTypeURL,
+            customFieldTypeFileHash: customFieldTypeFileHash,
 
             //errors
             msgLoad: '',
             msgLoadType: '',
+            msgReorder: '',
+            msgReorderType: '',
 
             collapseUI: false, //collapse the card to take up less screen space.
+            reordering: false, //disable reorder buttons while order is being saved.
 
             //endpoints
             urls: {
                 get: "/api/custom-fields/defined/",
+                reorder: "/api/custom-fields/defined/reorder/",
             }
         },
         methods: {
@@ -98,6 +105,59 @@ if (document.getElementById("listCustomFieldsDefined")) {
                 return;
             },
 
+            //move moves a field up (-1) or down (1) in the order fields are shown in when
+            //creating a license. The new order is saved immediately.
+            move: function (index: number, direction: number) {
+                let newIndex: number = index + direction;
+                if (newIndex < 0 || newIndex >= this.fields.length) {
+                    return;
+                }
+
+                //Swap the fields, saving the original order in case saving fails.
+                let original: customFieldDefined[] = this.fields.slice();
+                let reordered: customFieldDefined[] = this.fields.slice();
+                reordered[index] = this.fields[newIndex];
+                reordered[newIndex] = this.fields[index];
+                this.fields = reordered;
+
+                //Save.
+                this.reordering = true;
+                this.msgReorder = "";
+                this.msgReorderType = "";
+
+                let data: Object = {
+                    appID: this.appSelectedID,
+                    ids: JSON.stringify(reordered.map(function (f: customFieldDefined) { return f.ID; })),
+                };
+                fetch(post(this.urls.reorder, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            listCustomFieldsDefined.fields = original;
+                            listCustomFieldsDefined.msgReorder = err;
+                            listCustomFieldsDefined.msgReorderType = msgTypes.danger;
+                            listCustomFieldsDefined.reordering = false;
+                            return;
+                        }
+
+                        listCustomFieldsDefined.reordering = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        listCustomFieldsDefined.fields = original;
+                        listCustomFieldsDefined.msgReorder = 'An unknown error occured. Please try again.';
+                        listCustomFieldsDefined.msgReorderType = msgTypes.danger;
+                        listCustomFieldsDefined.reordering = false;
+                        return;
+                    });
+
+                return;
+            },
+
             //passToModal handles the clicking of buttons/icons that open the add/edit custom
             //field modal. When adding a new custom field, 'undefined' is simply passed along. 
             //But when editing the details of a custom field (full data was already retrieved 
@@ -142,6 +202,9 @@ if (document.getElementById("modal-customFieldDefined")) {
             customFieldTypeBoolean: customFieldTypeBoolean,
             customFieldTypeMultiChoice: customFieldTypeMultiChoice,
             customFieldTypeDate: customFieldTypeDate,
+            customFieldTypeEmail: customFieldTypeEmail,
+            customFieldTypeURL: customFieldTypeURL,
+            customFieldTypeFileHash: customFieldTypeFileHash,
 
             separator: ";", //separator for multichoice options
 
@@ -268,6 +331,8 @@ if (document.getElementById("modal-customFieldDefined")) {
                     NumberMinValue: 0,
                     NumberMaxValue: 0,
                     MultiChoiceOptions: "",
+                    TextValidationRegex: "",
+                    CannotExceedLicenseExpiration: false,
                 } as customFieldDefined;
 
                 this.submitting = false;
@@ -358,6 +423,31 @@ if (document.getElementById("modal-customFieldDefined")) {
                             this.msgSave = "You must provide a default value for this field.";
                             return;
                         }
+
+                        //The regex is validated server side as well since the server
+                        //uses a slightly different regex syntax.
+                        if (this.fieldData.TextValidationRegex !== undefined && this.fieldData.TextValidationRegex.trim() !== "") {
+                            let rx: RegExp;
+                            try {
+                                rx = new RegExp(this.fieldData.TextValidationRegex.trim());
+                            } catch (e) {
+                                this.msgSave = "The validation regex is invalid.";
+                                return;
+                            }
+
+                            if (!rx.test(this.fieldData.TextDefaultValue.trim())) {
+                                this.msgSave = "The default value does not match the validation regex.";
+                                return;
+                            }
+                        }
+                        break;
+
+                    case customFieldTypeEmail:
+                    case customFieldTypeURL:
+                    case customFieldTypeFileHash:
+                        //A default is optional. The format of a provided default is
+                        //validated server side.
+                        this.fieldData.TextValidationRegex = "";
                         break;
 
                     case customFieldTypeBoolean:
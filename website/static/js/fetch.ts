@@ -37,6 +37,21 @@ function objectToString(obj: Object): string {
     return params;
 }
 
+//baseURLPath is the path the app is served under when behind a proxy at a subpath,
+//or blank when the app is served at the root. This is set in html_scripts.html from
+//the BaseURLPath config file field.
+declare const baseURLPath: string;
+
+//withBaseURLPath prefixes a path within the app with baseURLPath. Paths to other
+//hosts are returned as-is.
+function withBaseURLPath(url: string): string {
+    if (url.startsWith("/")) {
+        return baseURLPath + url;
+    }
+
+    return url;
+}
+
 //removeDoubleSlash removes a double slash in a url replacing it with a single
 //slash. Double slashes are a typo mistake and should be fixed.
 function removeDoubleSlash(url: string): string {
@@ -54,6 +69,7 @@ function removeDoubleSlash(url: string): string {
 function get(url: string, formValues: Object): Request {
     //make sure url doesn't have double slashes by mistake
     url = removeDoubleSlash(url);
+    url = withBaseURLPath(url);
 
     //build the request
     let r: RequestInit = {
@@ -80,6 +96,7 @@ function get(url: string, formValues: Object): Request {
 function post(url: string, formValues: Object): Request {
     //make sure url doesn't have double slashes by mistake
     url = removeDoubleSlash(url);
+    url = withBaseURLPath(url);
 
     //build the request
     let r: RequestInit = {
@@ -107,6 +124,7 @@ function post(url: string, formValues: Object): Request {
 function postFile(url: string, fileData: FormData): Request {
     //make sure url doesn't have double slashes by mistake
     url = removeDoubleSlash(url);
+    url = withBaseURLPath(url);
 
     //build the request config
     let r: RequestInit = {
@@ -124,10 +142,15 @@ function postFile(url: string, fileData: FormData): Request {
 //this does *not* handle errors returned from the server such as validation errors or other server issues
 //our server should only return:
 // - 200 when requests complete successfully
+// - 403 when a user must authenticate again, or isn't allowed, to perform an action
 // - 500 when an error occured on the server (validation issue, db issue, etc.)
 //Handle any other status codes (page not found, server unavailable, network issue, etc) via .catch().
 function handleRequestErrors(response: Response): Response {
-    let serverResponseCodes: number[] = [200, 500];
+    //503 is sent, with an error message, when the app is in maintenance mode.
+    //403 is sent, with an error message, when a logged in user must authenticate
+    //again to perform an action, see users.MsgType2FAStepUpRequired, or an action
+    //isn't allowed while impersonating a user.
+    let serverResponseCodes: number[] = [200, 403, 500, 503];
     if (serverResponseCodes.indexOf(response.status) == -1) {
         //console.log("fetch request error: bad status");
         //fetch().catch will handle this...
@@ -1,6 +1,7 @@
 /**
  * header.ts
- * This deals with toggling the username to/from the Logout text.
+ * This deals with toggling the username to/from the Logout text, dismissing the
+ * announcement shown below the header, and ending viewing the app as another user.
 */
 
 /// <reference path="common.ts" />
@@ -16,3 +17,106 @@ if (document.getElementById("headerBtns")) {
         },
     });
 }
+
+if (document.getElementById("announcement")) {
+    //announcement handles dismissing the announcement set by an administrator. The
+    //announcement stays dismissed until the browser is closed, or a different
+    //announcement is set, using a cookie that stores a hash of the announcement.
+    //@ts-ignore cannot find name Vue
+    var announcement = new Vue({
+        name: 'announcement',
+        delimiters: ['[[', ']]'],
+        el: '#announcement',
+        data: {
+            hash: '',
+            dismissed: false,
+
+            cookieName: "announcement_dismissed",
+        },
+        methods: {
+            //dismiss hides the announcement and saves the cookie so the announcement
+            //isn't shown on other pages.
+            dismiss: function () {
+                this.dismissed = true;
+                document.cookie = this.cookieName + "=" + this.hash + "; path=/; SameSite=Strict";
+                return;
+            },
+
+            //hashText returns a short, non-cryptographic, hash of the announcement so
+            //that the announcement itself isn't stored in the cookie.
+            hashText: function (text: string): string {
+                let h: number = 5381;
+                for (let i = 0; i < text.length; i++) {
+                    h = ((h << 5) + h + text.charCodeAt(i)) | 0;
+                }
+
+                return (h >>> 0).toString(16);
+            },
+        },
+        created() {
+            let elem = document.getElementById("announcementText") as HTMLElement;
+            this.hash = this.hashText(elem.dataset.text || "");
+
+            let cookies: string[] = document.cookie.split("; ");
+            this.dismissed = cookies.includes(this.cookieName + "=" + this.hash);
+            return;
+        },
+    });
+}
+
+if (document.getElementById("impersonation")) {
+    //impersonation handles an administrator returning to their own account after
+    //viewing the app as another user.
+    //@ts-ignore cannot find name Vue
+    var impersonation = new Vue({
+        name: 'impersonation',
+        delimiters: ['[[', ']]'],
+        el: '#impersonation',
+        data: {
+            submitting: false,
+            msg: '',
+
+            //endpoints
+            urls: {
+                end: "/api/users/impersonate/end/",
+                users: "/app/administration/users/",
+            }
+        },
+        methods: {
+            //end stops viewing the app as another user and returns the administrator
+            //to the users page.
+            end: function () {
+                if (this.submitting) {
+                    return;
+                }
+
+                this.submitting = true;
+                this.msg = '';
+
+                fetch(post(this.urls.end, {}))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            impersonation.msg = err;
+                            impersonation.submitting = false;
+                            return;
+                        }
+
+                        window.location.href = withBaseURLPath(impersonation.urls.users);
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        impersonation.msg = 'An unknown error occured.  Please try again.';
+                        impersonation.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
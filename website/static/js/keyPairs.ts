@@ -122,11 +122,21 @@ if (document.getElementById("modal-keyPair")) {
             //public key.
             keyPairData: {} as keyPair,
 
-            //options to choose from when adding and default
+            //options to choose from when adding and default, set from the algorithms
+            //allowed per the config file in mounted().
             algorithmTypes: keyPairAlgoTypes,
             defaultAlgorithmType: keyPairAlgoED25519,
 
             showPublicKey: false, //true upon button click to show public key in textarea for copying
+            exportPassphrase: "", //used to encrypt the private key when exporting, never stored.
+
+            //used when importing a key pair generated outside of this app instead of
+            //generating a new key pair.
+            importing: false,
+            importPrivateKey: "",
+            importPublicKey: "",
+            importPassphrase: "", //only needed for a private key exported from this app, never stored.
+            baseURLPath: baseURLPath, //prefix for links built in template.
 
             //errors
             submitting: false,
@@ -136,8 +146,11 @@ if (document.getElementById("modal-keyPair")) {
             //endpoints
             urls: {
                 add: "/api/key-pairs/add/",
+                import: "/api/key-pairs/import/",
                 delete: "/api/key-pairs/delete/",
                 setDefault: "/api/key-pairs/set-default/",
+                markCompromised: "/api/key-pairs/mark-compromised/",
+                export: "/api/key-pairs/export/",
             }
         },
         computed: {
@@ -206,9 +219,15 @@ if (document.getElementById("modal-keyPair")) {
                     AlgorithmType: this.defaultAlgorithmType,
                     PublicKey: "",
                     IsDefault: false,
+                    Compromised: false,
                 } as keyPair;
 
                 this.showPublicKey = false;
+                this.exportPassphrase = "";
+                this.importing = false;
+                this.importPrivateKey = "";
+                this.importPublicKey = "";
+                this.importPassphrase = "";
 
                 this.submitting = false;
                 this.msgSave = "";
@@ -237,17 +256,38 @@ if (document.getElementById("modal-keyPair")) {
                     this.msgSave = "Please choose an algorithm from the provided options";
                     return;
                 }
+                if (this.importing && this.importPrivateKey === "") {
+                    this.msgSave = "You must provide the private key.";
+                    return;
+                }
+                if (this.importing && this.importPublicKey === "") {
+                    this.msgSave = "You must provide the public key.";
+                    return;
+                }
 
                 //validation ok
                 this.msgSave = "Generating key pair...";
+                if (this.importing) {
+                    this.msgSave = "Importing key pair...";
+                }
                 this.msgSaveType = msgTypes.primary;
                 this.submitting = true;
 
                 //perform api call
+                let url: string = this.urls.add;
                 let data: Object = {
                     data: JSON.stringify(this.keyPairData),
                 };
-                fetch(post(this.urls.add, data))
+                if (this.importing) {
+                    url = this.urls.import;
+                    data = {
+                        data: JSON.stringify(this.keyPairData),
+                        privateKey: this.importPrivateKey,
+                        publicKey: this.importPublicKey,
+                        passphrase: this.importPassphrase,
+                    };
+                }
+                fetch(post(url, data))
                     .then(handleRequestErrors)
                     .then(getJSON)
                     .then(function (j) {
@@ -267,6 +307,13 @@ if (document.getElementById("modal-keyPair")) {
                         //call to retrieve the data.
                         modalKeyPair.keyPairData = j.Data;
 
+                        //Clear the imported keys so that the private key isn't kept
+                        //in the page.
+                        modalKeyPair.importing = false;
+                        modalKeyPair.importPrivateKey = "";
+                        modalKeyPair.importPublicKey = "";
+                        modalKeyPair.importPassphrase = "";
+
                         //Refresh the list of keypairs so that this new keypair is shown.
                         listKeyPairs.getKeyPairs();
 
@@ -401,9 +448,154 @@ if (document.getElementById("modal-keyPair")) {
                         return;
                     });
                 return;
-            }
+            },
+
+            //markCompromised marks this keypair as compromised. A compromised keypair
+            //cannot be used to sign new licenses or be set as the default. This cannot
+            //be undone.
+            markCompromised: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //Make sure we know what keypair we are marking.
+                if (isNaN(this.keyPairData.ID) || this.keyPairData.ID === '' || this.keyPairData.ID < 1) {
+                    this.msgSave = "Could not determine which key pair you want to mark as compromised. Please refresh the page and try again.";
+                    this.msgSaveType = msgTypes.danger;
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Marking as compromised...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    id: this.keyPairData.ID,
+                };
+                fetch(post(this.urls.markCompromised, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalKeyPair.msgSave = err;
+                            modalKeyPair.msgSaveType = msgTypes.danger;
+                            modalKeyPair.submitting = false;
+                            return;
+                        }
+
+                        //update gui and refresh the list of keypairs to show the
+                        //compromised tag next to the correct keypair.
+                        listKeyPairs.getKeyPairs();
+                        modalKeyPair.keyPairData.Compromised = true;
+                        modalKeyPair.keyPairData.IsDefault = false;
+                        modalKeyPair.submitting = false;
+                        modalKeyPair.msgSave = "";
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalKeyPair.msgSave = 'An unknown error occured. Please try again.';
+                        modalKeyPair.msgSaveType = msgTypes.danger;
+                        modalKeyPair.submitting = false;
+                        return;
+                    });
+                return;
+            },
+
+            //exportPrivateKey downloads this keypair's private key encrypted with the
+            //provided passphrase. The passphrase must be given to whomever will use the
+            //private key, separately, so they can decrypt it.
+            exportPrivateKey: function () {
+                //make sure data isn't already being submitted
+                if (this.submitting) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                //validate
+                this.msgSaveType = msgTypes.danger;
+                if (isNaN(this.keyPairData.ID) || this.keyPairData.ID === '' || this.keyPairData.ID < 1) {
+                    this.msgSave = "Could not determine which key pair you want to export. Please refresh the page and try again.";
+                    return;
+                }
+                if (this.exportPassphrase === "") {
+                    this.msgSave = "You must provide a passphrase to encrypt the private key with.";
+                    return;
+                }
+
+                //validation ok
+                this.msgSave = "Exporting...";
+                this.msgSaveType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    id: this.keyPairData.ID,
+                    passphrase: this.exportPassphrase,
+                };
+                fetch(post(this.urls.export, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalKeyPair.msgSave = err;
+                            modalKeyPair.msgSaveType = msgTypes.danger;
+                            modalKeyPair.submitting = false;
+                            return;
+                        }
+
+                        //Save the exported private key as a file.
+                        let exported: keyPairExport = j.Data;
+                        let blob: Blob = new Blob([exported.PrivateKey], { type: "application/x-pem-file" });
+                        let a: HTMLAnchorElement = document.createElement("a");
+                        a.href = URL.createObjectURL(blob);
+                        a.download = exported.Filename;
+                        a.click();
+                        URL.revokeObjectURL(a.href);
+
+                        modalKeyPair.exportPassphrase = "";
+                        modalKeyPair.msgSave = "Exported!";
+                        modalKeyPair.msgSaveType = msgTypes.success;
+                        modalKeyPair.submitting = false;
+                        setTimeout(function () {
+                            modalKeyPair.msgSave = '';
+                            modalKeyPair.msgSaveType = '';
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalKeyPair.msgSave = 'An unknown error occured. Please try again.';
+                        modalKeyPair.msgSaveType = msgTypes.danger;
+                        modalKeyPair.submitting = false;
+                        return;
+                    });
+                return;
+            },
         },
         mounted() {
+            //Get the algorithms that can be used, and the default, per the minimum
+            //algorithm set in the config file. These are saved in hidden inputs.
+            let algorithms: string = (document.getElementById("keyPairAlgorithms") as HTMLInputElement).value;
+            if (algorithms !== "") {
+                this.algorithmTypes = JSON.parse(algorithms);
+            }
+
+            let defaultAlgorithm: string = (document.getElementById("defaultKeyPairAlgorithm") as HTMLInputElement).value;
+            if (defaultAlgorithm !== "") {
+                this.defaultAlgorithmType = defaultAlgorithm;
+            }
+
             //this is used to set the object storing keypair data to a default state
             this.resetModal();
 
@@ -20,6 +20,11 @@ if (document.getElementById("licenses")) {
             appSelectedID: 0,
             rowLimit: 20, //just a default value
             activeOnly: false, //not settable in gui (yet)
+            search: '',
+            appLicenseNumber: 0, //only used when an app is chosen.
+            expireStart: '', //yyyy-mm-dd
+            expireEnd: '',   //" "
+            baseURLPath: baseURLPath, //prefix for links built in template.
 
             //retrieved data
             licenses: [] as license[],
@@ -28,6 +33,8 @@ if (document.getElementById("licenses")) {
             submitting: false,
             msg: '',
             msgType: '',
+            msgLoad: '',
+            msgLoadType: '',
 
             //endpoints
             urls: {
@@ -75,11 +82,21 @@ if (document.getElementById("licenses")) {
                 if (this.appSelectedID < 0) {
                     this.appSelectedID = 0;
                 }
+                if (this.expireStart !== '' && this.expireEnd !== '' && this.expireStart > this.expireEnd) {
+                    this.msgLoad = "The expiration start date must be before the end date.";
+                    this.msgLoadType = msgTypes.danger;
+                    return;
+                }
+                this.msgLoad = '';
 
                 let data: Object = {
                     appID: this.appSelectedID,
                     limit: this.rowLimit,
                     activeOnly: this.activeOnly,
+                    search: this.search.trim(),
+                    appLicenseNumber: this.appSelectedID > 0 ? this.appLicenseNumber : 0,
+                    expireStart: this.expireStart,
+                    expireEnd: this.expireEnd,
                 };
                 fetch(get(this.urls.getLicenses, data))
                     .then(handleRequestErrors)
@@ -9,6 +9,10 @@
  * If no, user is shown 2FA token input. The user then provides the token and all info 
  * is submitted again which is again (username and password plus 2FA token).
  * 
+ * If the user has registered a passkey, the server instead returns a challenge and the
+ * browser prompts the user for their passkey. The signed challenge is then submitted
+ * along with the username and password in place of the 2FA token.
+ * 
  * Login with bad password or bad 2FA code adds latency to reduce brute force attempts 
  * of a password or code.
  */
@@ -35,6 +39,10 @@ if (document.getElementById("login")) {
             show2FAInput: false,
             twoFAVerificationCode: '',
 
+            //passkey
+            passkeyOptions: {} as passkeyLoginOptions,
+            passkeyAssertion: {} as Object,
+
             //endpoints
             urls: {
                 loginAuth: "/login/",
@@ -63,8 +71,8 @@ if (document.getElementById("login")) {
                     this.msgType = msgTypes.danger;
                     return;
                 }
-                if (this.show2FAInput && this.twoFAVerificationCode.length !== 6) {
-                    this.msg = 'Your 2FA code must be exactly 6 numeric characters.';
+                if (this.show2FAInput && !('passkeyCredentialID' in this.passkeyAssertion) && (this.twoFAVerificationCode.length < 6 || this.twoFAVerificationCode.length > 8)) {
+                    this.msg = 'Your 2FA code must be 6 to 8 numeric characters.';
                     this.msgType = msgTypes.danger;
                     return
                 }
@@ -81,6 +89,7 @@ if (document.getElementById("login")) {
                     username: this.username,
                     password: this.password,
                     twoFAToken: this.twoFAVerificationCode,
+                    ...this.passkeyAssertion,
                 };
                 fetch(post(this.urls.loginAuth, data))
                     .then(handleRequestErrors)
@@ -109,6 +118,15 @@ if (document.getElementById("login")) {
 
                             return;
                         }
+                        else if (resType === "login2FAPasskeyRequired") {
+                            //Prompt user for their passkey. If the user can also use a
+                            //2FA token, show the input as a fallback in case the user 
+                            //doesn't have their passkey with them.
+                            login.passkeyOptions = j.Data;
+                            login.show2FAInput = login.passkeyOptions.TOTPAllowed;
+                            login.usePasskey();
+                            return;
+                        }
                         else if (resType === "login2FAForced") {
                             //Show user error message that they need to have admin set up 
                             //2fa for them, 2fa is required and this user does not have
@@ -124,7 +142,7 @@ if (document.getElementById("login")) {
                             //and 2fa token.
                             //
                             //Redirect user to main logged in page.
-                            window.location.href = login.urls.mainApp;
+                            window.location.href = withBaseURLPath(login.urls.mainApp);
                             return;
                         }
                         else {
@@ -143,7 +161,54 @@ if (document.getElementById("login")) {
 
                 this.submitting = false;
                 return;
-            }
+            },
+
+            //usePasskey prompts the user for their passkey using the challenge provided
+            //by the server and then submits the login again with the signed challenge.
+            usePasskey: function () {
+                this.msg = 'Please use your passkey to log in...';
+                this.msgType = msgTypes.primary;
+
+                let opts: PublicKeyCredentialRequestOptions = {
+                    challenge: base64URLToBuffer(this.passkeyOptions.Challenge),
+                    rpId: this.passkeyOptions.RPID,
+                    allowCredentials: this.passkeyOptions.AllowCredentialIDs.map(function (id: string) {
+                        return { type: "public-key", id: base64URLToBuffer(id) } as PublicKeyCredentialDescriptor;
+                    }),
+                    userVerification: "discouraged",
+                };
+
+                navigator.credentials.get({ publicKey: opts })
+                    .then(function (cred) {
+                        let c = cred as PublicKeyCredential;
+                        let res = c.response as AuthenticatorAssertionResponse;
+
+                        login.passkeyAssertion = {
+                            passkeyCredentialID: bufferToBase64URL(c.rawId),
+                            passkeyClientDataJSON: bufferToBase64URL(res.clientDataJSON),
+                            passkeyAuthenticatorData: bufferToBase64URL(res.authenticatorData),
+                            passkeySignature: bufferToBase64URL(res.signature),
+                        };
+                        login.login();
+
+                        //Clear the assertion since it can only be used once.
+                        login.passkeyAssertion = {};
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("navigator.credentials.get() error: >>", err, "<<");
+                        if (login.passkeyOptions.TOTPAllowed) {
+                            login.msg = 'Your passkey could not be used. Please provide your 2FA code instead.';
+                        }
+                        else {
+                            login.msg = 'Your passkey could not be used. Please try again.';
+                        }
+                        login.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
         },
         mounted() {
             //Set cursor to username input on page load.
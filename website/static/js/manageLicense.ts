@@ -21,6 +21,16 @@ if (document.getElementById("manageLicense")) {
             customFieldResults: [] as customFieldResults[],
             downloadHistory: [] as downloadHistory[],
             notes: [] as licenseNote[],
+            notesCategory: "", //filter for notes, blank shows notes in all categories.
+            attachments: [] as licenseAttachment[],
+            activations: [] as licenseActivation[],
+
+            //Internal reference data, copied from licenseData so that changes
+            //aren't shown elsewhere until saved.
+            metadata: {
+                OrderReference: "",
+                InternalNotes: "",
+            },
 
             msgLicenseData: '',
             msgLicenseDataType: '',
@@ -28,8 +38,32 @@ if (document.getElementById("manageLicense")) {
             msgHistoryType: '',
             msgNotes: '',
             msgNotesType: '',
+            msgAttachments: '',
+            msgAttachmentsType: '',
+            msgActivations: '',
+            msgActivationsType: '',
+            msgMetadata: '',
+            msgMetadataType: '',
+
+            //Handle confirmation of resetting activations, so a single click cannot
+            //reset activations.
+            showResetActivationsConfirm: false,
+            submittingActivations: false,
+            submittingMetadata: false,
+            submittingAttachment: false,
+
+            //Handle confirmation of canceling a scheduled disable, so a single click
+            //cannot cancel it.
+            showCancelScheduledDisableConfirm: false,
+            submittingScheduledDisable: false,
+            msgScheduledDisable: '',
+
+            //Handle confirmation of deleting an attachment, so a single click cannot
+            //delete an attachment. This is the ID of the attachment to confirm.
+            confirmDeleteAttachmentID: 0,
 
             showAdvancedInfo: false, //set by button click
+            baseURLPath: baseURLPath, //prefix for links built in template.
 
             //need these for v-if in html
             customFieldTypeInteger: customFieldTypeInteger,
@@ -38,6 +72,9 @@ if (document.getElementById("manageLicense")) {
             customFieldTypeBoolean: customFieldTypeBoolean,
             customFieldTypeMultiChoice: customFieldTypeMultiChoice,
             customFieldTypeDate: customFieldTypeDate,
+            customFieldTypeEmail: customFieldTypeEmail,
+            customFieldTypeURL: customFieldTypeURL,
+            customFieldTypeFileHash: customFieldTypeFileHash,
 
             //endpoints
             urls: {
@@ -45,6 +82,13 @@ if (document.getElementById("manageLicense")) {
                 getCustomFields: "/api/custom-fields/results/",
                 getHistory: "/api/licenses/history/",
                 getNotes: "/api/licenses/notes/",
+                getAttachments: "/api/licenses/attachments/",
+                addAttachment: "/api/licenses/attachments/add/",
+                deleteAttachment: "/api/licenses/attachments/delete/",
+                getActivations: "/api/licenses/activations/",
+                resetActivations: "/api/licenses/activations/reset/",
+                updateMetadata: "/api/licenses/update-metadata/",
+                cancelScheduledDisable: "/api/licenses/schedule-disable/cancel/",
                 //download license file used href, not url defined here.
             }
         },
@@ -70,6 +114,8 @@ if (document.getElementById("manageLicense")) {
 
                         manageLicense.licenseData = j.Data;
                         manageLicense.licenseDataRetrieved = true;
+                        manageLicense.metadata.OrderReference = j.Data.OrderReference;
+                        manageLicense.metadata.InternalNotes = j.Data.InternalNotes;
 
                         //pass license data to other Vue objects.
                         manageLicense.passData();
@@ -166,6 +212,7 @@ if (document.getElementById("manageLicense")) {
             getNotes: function () {
                 let data: Object = {
                     licenseID: this.licenseID,
+                    category: this.notesCategory,
                 };
                 fetch(get(this.urls.getNotes, data))
                     .then(handleRequestErrors)
@@ -192,6 +239,303 @@ if (document.getElementById("manageLicense")) {
                 return;
             },
 
+            //getAttachments looks up the files attached to this license.
+            //We assume license ID is valid since it was validated when
+            //this page was loaded.
+            getAttachments: function () {
+                let data: Object = {
+                    licenseID: this.licenseID,
+                };
+                fetch(get(this.urls.getAttachments, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgAttachments = err;
+                            manageLicense.msgAttachmentsType = msgTypes.danger;
+                            return;
+                        }
+
+                        manageLicense.attachments = j.Data || [];
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgAttachments = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgAttachmentsType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //addAttachment uploads the chosen file and attaches it to this license.
+            addAttachment: function () {
+                //Make sure data isn't already being saved.
+                if (this.submittingAttachment) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Make sure a file was chosen.
+                let input: HTMLInputElement = this.$refs.attachmentFile;
+                if (!input.files || input.files.length === 0) {
+                    this.msgAttachments = "You must choose a file to attach.";
+                    this.msgAttachmentsType = msgTypes.danger;
+                    return;
+                }
+
+                this.msgAttachmentsType = msgTypes.primary;
+                this.msgAttachments = "Uploading...";
+                this.submittingAttachment = true;
+
+                let data: FormData = new FormData();
+                data.append("licenseID", this.licenseID.toString());
+                data.append("file", input.files[0]);
+                fetch(postFile(this.urls.addAttachment, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgAttachments = err;
+                            manageLicense.msgAttachmentsType = msgTypes.danger;
+                            manageLicense.submittingAttachment = false;
+                            return;
+                        }
+
+                        manageLicense.msgAttachments = "";
+                        manageLicense.msgAttachmentsType = "";
+                        manageLicense.submittingAttachment = false;
+                        input.value = "";
+
+                        manageLicense.getAttachments();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgAttachments = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgAttachmentsType = msgTypes.danger;
+                        manageLicense.submittingAttachment = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //handleDeleteAttachmentConfirm shows the "confirm" button for deleting an
+            //attachment. The "confirm" button is reverted after a short amount of time
+            //so that a user must perform the "confirm" quickly.
+            handleDeleteAttachmentConfirm: function (id: number) {
+                this.confirmDeleteAttachmentID = id;
+
+                setTimeout(function () {
+                    manageLicense.confirmDeleteAttachmentID = 0;
+                }, 3000);
+
+                return;
+            },
+
+            //deleteAttachment removes a file attached to this license.
+            deleteAttachment: function (id: number) {
+                //Make sure data isn't already being saved.
+                if (this.submittingAttachment) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                this.msgAttachmentsType = msgTypes.primary;
+                this.msgAttachments = "Deleting...";
+                this.submittingAttachment = true;
+
+                let data: Object = {
+                    id: id,
+                };
+                fetch(post(this.urls.deleteAttachment, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgAttachments = err;
+                            manageLicense.msgAttachmentsType = msgTypes.danger;
+                            manageLicense.submittingAttachment = false;
+                            return;
+                        }
+
+                        manageLicense.msgAttachments = "";
+                        manageLicense.msgAttachmentsType = "";
+                        manageLicense.submittingAttachment = false;
+                        manageLicense.confirmDeleteAttachmentID = 0;
+
+                        manageLicense.getAttachments();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgAttachments = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgAttachmentsType = msgTypes.danger;
+                        manageLicense.submittingAttachment = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //getActivations looks up the machines this license has been activated on.
+            //We assume license ID is valid since it was validated when this page was
+            //loaded.
+            getActivations: function () {
+                let data: Object = {
+                    licenseID: this.licenseID,
+                };
+                fetch(get(this.urls.getActivations, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgActivations = err;
+                            manageLicense.msgActivationsType = msgTypes.danger;
+                            return;
+                        }
+
+                        manageLicense.activations = j.Data || [];
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgActivations = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgActivationsType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //handleResetActivationsConfirm shows the "confirm" button for resetting
+            //activations. The "confirm" button is reverted after a short amount of 
+            //time so that a user must perform the "confirm" quickly.
+            handleResetActivationsConfirm: function () {
+                this.showResetActivationsConfirm = true;
+
+                setTimeout(function () {
+                    manageLicense.showResetActivationsConfirm = false;
+                }, 3000);
+
+                return;
+            },
+
+            //resetActivations removes every machine this license has been activated
+            //on so that the license can be activated on new machines.
+            resetActivations: function () {
+                //Make sure data isn't already being saved.
+                if (this.submittingActivations) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                this.msgActivationsType = msgTypes.primary;
+                this.msgActivations = "Resetting...";
+                this.submittingActivations = true;
+
+                let data: Object = {
+                    licenseID: this.licenseID,
+                };
+                fetch(post(this.urls.resetActivations, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgActivations = err;
+                            manageLicense.msgActivationsType = msgTypes.danger;
+                            manageLicense.submittingActivations = false;
+                            return;
+                        }
+
+                        manageLicense.msgActivations = "";
+                        manageLicense.msgActivationsType = "";
+                        manageLicense.submittingActivations = false;
+                        manageLicense.showResetActivationsConfirm = false;
+
+                        //Refresh activations and notes since a note is saved about
+                        //the reset.
+                        manageLicense.getActivations();
+                        manageLicense.getNotes();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgActivations = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgActivationsType = msgTypes.danger;
+                        manageLicense.submittingActivations = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //saveMetadata saves the order reference and internal notes. This data
+            //is not part of the license file so the license is not re-signed.
+            saveMetadata: function () {
+                //Make sure data isn't already being saved.
+                if (this.submittingMetadata) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                this.msgMetadataType = msgTypes.primary;
+                this.msgMetadata = "Saving...";
+                this.submittingMetadata = true;
+
+                let data: Object = {
+                    licenseID: this.licenseID,
+                    orderReference: this.metadata.OrderReference,
+                    internalNotes: this.metadata.InternalNotes,
+                };
+                fetch(post(this.urls.updateMetadata, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgMetadata = err;
+                            manageLicense.msgMetadataType = msgTypes.danger;
+                            manageLicense.submittingMetadata = false;
+                            return;
+                        }
+
+                        manageLicense.licenseData.OrderReference = manageLicense.metadata.OrderReference;
+                        manageLicense.licenseData.InternalNotes = manageLicense.metadata.InternalNotes;
+
+                        manageLicense.msgMetadata = "Saved!";
+                        manageLicense.msgMetadataType = msgTypes.success;
+                        setTimeout(function () {
+                            manageLicense.msgMetadata = "";
+                            manageLicense.msgMetadataType = "";
+                            manageLicense.submittingMetadata = false;
+                        }, defaultTimeout);
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgMetadata = 'An unknown error occured.  Please try again.';
+                        manageLicense.msgMetadataType = msgTypes.danger;
+                        manageLicense.submittingMetadata = false;
+                        return;
+                    });
+
+                return;
+            },
+
             //setNoteModal is called when a user clicks the button to open the note
             //modal, either for adding a new note or viewing details of an existing
             //note. When clicking the add button, the input is undefined. When
@@ -202,6 +546,64 @@ if (document.getElementById("manageLicense")) {
                 return;
             },
 
+            //cancelScheduledDisable removes the date this license is scheduled to be
+            //disabled on so that it is not disabled automatically.
+            cancelScheduledDisable: function () {
+                //Make sure we aren't already submitting.
+                if (this.submittingScheduledDisable) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                this.msgScheduledDisable = "Canceling...";
+                this.submittingScheduledDisable = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                };
+                fetch(post(this.urls.cancelScheduledDisable, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageLicense.msgScheduledDisable = err;
+                            manageLicense.submittingScheduledDisable = false;
+                            return;
+                        }
+
+                        //Update page to remove the scheduled disable.
+                        manageLicense.licenseData.ScheduledDisableDate = "";
+                        manageLicense.licenseData.ScheduledDisableNote = "";
+                        manageLicense.msgScheduledDisable = "";
+                        manageLicense.showCancelScheduledDisableConfirm = false;
+                        manageLicense.submittingScheduledDisable = false;
+                        manageLicense.passData();
+                        manageLicense.getNotes();
+
+                        //Allow scheduling again.
+                        modalScheduleDisableLicense.scheduled = false;
+                        modalScheduleDisableLicense.msgSave = "";
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageLicense.msgScheduledDisable = 'An unknown error occured. Please try again.';
+                        manageLicense.submittingScheduledDisable = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //compare shows the differences between two licenses in a modal.
+            compare: function (fromID: number, toID: number) {
+                modalLicenseDiff.getDiff(fromID, toID);
+                return;
+            },
+
             //passData passes license data to other Vue objects. Other Vue objects
             //may need certain license data and this function centralizes all the
             //data passed from thie Vue object to other Vue objects. This is called
@@ -209,11 +611,31 @@ if (document.getElementById("manageLicense")) {
             passData: function () {
                 modalDisableLicense.licenseID = this.licenseID;
 
+                modalScheduleDisableLicense.licenseID = this.licenseID;
+                modalScheduleDisableLicense.currentScheduledDisableDate = this.licenseData.ScheduledDisableDate;
+
                 modalNote.licenseID = this.licenseID;
 
                 modalRenewLicense.licenseID = this.licenseID;
                 modalRenewLicense.currentExpireDate = this.licenseData.ExpireDate;
 
+                modalExtendLicense.licenseID = this.licenseID;
+                modalExtendLicense.currentExpireDate = this.licenseData.ExpireDate;
+
+                modalActivateOffline.licenseID = this.licenseID;
+
+                modalTransferLicense.licenseID = this.licenseID;
+                modalTransferLicense.currentCompanyName = this.licenseData.CompanyName;
+
+                //Rebuild modal is only shown to administrators.
+                if (modalRebuildLicense !== undefined) {
+                    modalRebuildLicense.licenseID = this.licenseID;
+                }
+                modalDownloadLink.licenseID = this.licenseID;
+
+                modalEmailLicense.licenseID = this.licenseID;
+                modalEmailLicense.email = this.licenseData.Email;
+
                 return;
             },
         },
@@ -227,6 +649,8 @@ if (document.getElementById("manageLicense")) {
             this.getCustomFieldResults();
             this.getDownloadHistory();
             this.getNotes();
+            this.getAttachments();
+            this.getActivations();
 
             return;
         }
@@ -271,10 +695,8 @@ if (document.getElementById("modal-disableLicense")) {
                     this.msgSave = "Could not determine which license you want to disabled.";
                     return;
                 }
-                if (this.note.trim() === "") {
-                    this.msgSave = "You must provide a note describing why you are disabling this license.";
-                    return;
-                }
+                //A note may be required per the app settings, this is checked server
+                //side since non-administrators cannot look up the app settings.
 
                 //Validation ok.
                 this.msgSaveType = msgTypes.primary;
@@ -324,21 +746,18 @@ if (document.getElementById("modal-disableLicense")) {
     });
 }
 
-if (document.getElementById("modal-note")) {
+if (document.getElementById("modal-scheduleDisableLicense")) {
     //@ts-ignore cannot find name Vue
-    var modalNote = new Vue({
-        name: 'modalNote',
+    var modalScheduleDisableLicense = new Vue({
+        name: 'modalScheduleDisableLicense',
         delimiters: ['[[', ']]'],
-        el: '#modal-note',
+        el: '#modal-scheduleDisableLicense',
         data: {
             licenseID: 0, //set in manageLicense.passData().
-
-            noteData: {
-                LicenseID: 0,  //set when data is submitted to server from licenseID.
-                Note: "", //populated by user when adding or by initialize when viewing an existing note.
-            } as licenseNote,
-
-            addingNew: false, //set in initialize based on if user is viewing an exiting note or adding a new note.
+            currentScheduledDisableDate: "", //set in manageLicense.passData().
+            date: "",
+            note: "", //details about why license is being disabled
+            scheduled: false, //set to true upon successful schedule api call.
 
             submitting: false,
             msgSave: "",
@@ -346,40 +765,147 @@ if (document.getElementById("modal-note")) {
 
             //endpoint
             urls: {
-                add: "/api/licenses/notes/add/",
+                schedule: "/api/licenses/schedule-disable/",
             },
         },
         computed: {
-            //modalTitle sets the title of the modal.
-            modalTitle: function () {
-                if (this.addingNew) {
-                    return "Add Note";
-                }
-
-                return "Note Details";
+            //minDate creates the min value for the date picker. The date must be in
+            //the future, a license is disabled immediately via the disable modal.
+            minDate: function () {
+                return todayPlus(1);
             },
         },
         methods: {
-            //initialize is called when a user clicks on a button to open the modal
-            //by the manageLicenses.setNoteModal function. When adding a new note
-            //this resets the modal to a clean state. When viewing an existing modal
-            //this populates the Vue object with the chosen note's data.
-            initialize: function (n: licenseNote | undefined) {
-                //Always reset, even when viewing an existing note, so that we can
-                //be sure no old data is present.
-                this.resetModal();
-
-                //User wants to add a note.
-                if (n === undefined) {
-                    this.addingNew = true;
+            //schedule makes the API call to set the date the license will be disabled
+            //on. The license's page is updated to show the scheduled date.
+            schedule: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
                     return;
                 }
 
-                //User wants to view an existing note.
-                this.noteData = n;
-                this.addingNew = false;
-
-                return;
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to schedule to be disabled.";
+                    return;
+                }
+                if (this.date === "") {
+                    this.msgSave = "You must provide the date to disable the license on.";
+                    return;
+                }
+                //A note may be required per the app settings, this is checked server
+                //side since non-administrators cannot look up the app settings.
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Scheduling...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                    date: this.date,
+                    note: this.note,
+                };
+                fetch(post(this.urls.schedule, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalScheduleDisableLicense.msgSave = err;
+                            modalScheduleDisableLicense.msgSaveType = msgTypes.danger;
+                            modalScheduleDisableLicense.submitting = false;
+                            return;
+                        }
+
+                        //Update page to show the scheduled date.
+                        manageLicense.licenseData.ScheduledDisableDate = modalScheduleDisableLicense.date;
+                        manageLicense.licenseData.ScheduledDisableNote = modalScheduleDisableLicense.note;
+                        manageLicense.getNotes();
+
+                        //Show success message for modal.
+                        modalScheduleDisableLicense.msgSave = "License scheduled to be disabled!";
+                        modalScheduleDisableLicense.msgSaveType = msgTypes.primary;
+                        modalScheduleDisableLicense.scheduled = true;
+                        modalScheduleDisableLicense.submitting = false;
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalScheduleDisableLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalScheduleDisableLicense.msgSaveType = msgTypes.danger;
+                        modalScheduleDisableLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-note")) {
+    //@ts-ignore cannot find name Vue
+    var modalNote = new Vue({
+        name: 'modalNote',
+        delimiters: ['[[', ']]'],
+        el: '#modal-note',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+
+            noteData: {
+                LicenseID: 0,  //set when data is submitted to server from licenseID.
+                Note: "", //populated by user when adding or by initialize when viewing an existing note.
+                Category: "uncategorized",
+            } as licenseNote,
+
+            addingNew: false, //set in initialize based on if user is viewing an exiting note or adding a new note.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                add: "/api/licenses/notes/add/",
+            },
+        },
+        computed: {
+            //modalTitle sets the title of the modal.
+            modalTitle: function () {
+                if (this.addingNew) {
+                    return "Add Note";
+                }
+
+                return "Note Details";
+            },
+        },
+        methods: {
+            //initialize is called when a user clicks on a button to open the modal
+            //by the manageLicenses.setNoteModal function. When adding a new note
+            //this resets the modal to a clean state. When viewing an existing modal
+            //this populates the Vue object with the chosen note's data.
+            initialize: function (n: licenseNote | undefined) {
+                //Always reset, even when viewing an existing note, so that we can
+                //be sure no old data is present.
+                this.resetModal();
+
+                //User wants to add a note.
+                if (n === undefined) {
+                    this.addingNew = true;
+                    return;
+                }
+
+                //User wants to view an existing note.
+                this.noteData = n;
+                this.addingNew = false;
+
+                return;
             },
 
             //resetModal sets a modal to a blank state. This function is called
@@ -389,6 +915,7 @@ if (document.getElementById("modal-note")) {
                     ID: 0,
                     LicenseID: 0,
                     Note: "",
+                    Category: "uncategorized",
                     CreatedByUsername: "",
                     DatetimeCreated: "",
                 } as licenseNote;
@@ -484,6 +1011,12 @@ if (document.getElementById("modal-renewLicense")) {
             newExpireDate: "",
             renewed: false, //set to true upon successful renewal api call.
 
+            //2FA step-up, see users.MsgType2FAStepUpRequired.
+            twoFATokenRequired: false,
+            twoFAToken: "",
+            passkeyOptions: null as passkeyLoginOptions,
+            passkeyAssertion: {} as Object,
+
             submitting: false,
             msgSave: "",
             msgSaveType: "",
@@ -532,10 +1065,27 @@ if (document.getElementById("modal-renewLicense")) {
                     id: this.licenseID,
                     newExpireDate: this.newExpireDate,
                 };
+                if (this.twoFAToken !== "") {
+                    data["twoFAToken"] = this.twoFAToken;
+                }
+                data = { ...data, ...this.passkeyAssertion };
+
                 fetch(post(this.urls.renew, data))
                     .then(handleRequestErrors)
                     .then(getJSON)
                     .then(function (j) {
+                        //check if the server requires a 2FA token to renew the license,
+                        //see users.MsgType2FAStepUpRequired.
+                        if (j.Type === "2FAStepUpRequired") {
+                            modalRenewLicense.passkeyOptions = j.Data || null;
+                            modalRenewLicense.twoFATokenRequired = modalRenewLicense.passkeyOptions === null || modalRenewLicense.passkeyOptions.TOTPAllowed;
+                            modalRenewLicense.twoFAToken = "";
+                            modalRenewLicense.msgSave = j.ErrorData.Message;
+                            modalRenewLicense.msgSaveType = msgTypes.warning;
+                            modalRenewLicense.submitting = false;
+                            return;
+                        }
+
                         //check if response is an error from the server
                         let err: string = handleAPIErrors(j);
                         if (err !== '') {
@@ -557,7 +1107,7 @@ if (document.getElementById("modal-renewLicense")) {
                             return;
                         }
                         setTimeout(function () {
-                            window.location.href = "/app/licensing/license/?id=" + licenseID;
+                            window.location.href = withBaseURLPath("/app/licensing/license/?id=" + licenseID);
                         }, defaultTimeout);
 
                         return;
@@ -572,6 +1122,46 @@ if (document.getElementById("modal-renewLicense")) {
 
                 return;
             },
+
+            //usePasskey prompts the user for their passkey using the challenge provided
+            //by the server and then renews the license again with the signed
+            //challenge. This is used in place of a 2FA token.
+            usePasskey: function () {
+                let opts: PublicKeyCredentialRequestOptions = {
+                    challenge: base64URLToBuffer(this.passkeyOptions.Challenge),
+                    rpId: this.passkeyOptions.RPID,
+                    allowCredentials: this.passkeyOptions.AllowCredentialIDs.map(function (id: string) {
+                        return { type: "public-key", id: base64URLToBuffer(id) } as PublicKeyCredentialDescriptor;
+                    }),
+                    userVerification: "discouraged",
+                };
+
+                navigator.credentials.get({ publicKey: opts })
+                    .then(function (cred) {
+                        let c = cred as PublicKeyCredential;
+                        let res = c.response as AuthenticatorAssertionResponse;
+
+                        modalRenewLicense.passkeyAssertion = {
+                            passkeyCredentialID: bufferToBase64URL(c.rawId),
+                            passkeyClientDataJSON: bufferToBase64URL(res.clientDataJSON),
+                            passkeyAuthenticatorData: bufferToBase64URL(res.authenticatorData),
+                            passkeySignature: bufferToBase64URL(res.signature),
+                        };
+                        modalRenewLicense.renew();
+
+                        //Clear the assertion since it can only be used once.
+                        modalRenewLicense.passkeyAssertion = {};
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("navigator.credentials.get() error: >>", err, "<<");
+                        modalRenewLicense.msgSave = 'Your passkey could not be used. Please try again.';
+                        modalRenewLicense.msgSaveType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
         },
         mounted() {
             //get license ID from input.
@@ -581,4 +1171,741 @@ if (document.getElementById("modal-renewLicense")) {
             return;
         }
     });
-}
\ No newline at end of file
+}
+
+if (document.getElementById("modal-transferLicense")) {
+    //@ts-ignore cannot find name Vue
+    var modalTransferLicense = new Vue({
+        name: 'modalTransferLicense',
+        delimiters: ['[[', ']]'],
+        el: '#modal-transferLicense',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            currentCompanyName: "", //set in manageLicense.passData().
+            companyName: "",
+            contactName: "",
+            phoneNumber: "",
+            email: "",
+            transferred: false, //set to true upon successful transfer api call.
+
+            //2FA step-up, see users.MsgType2FAStepUpRequired.
+            twoFATokenRequired: false,
+            twoFAToken: "",
+            passkeyOptions: null as passkeyLoginOptions,
+            passkeyAssertion: {} as Object,
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                transfer: "/api/licenses/transfer/",
+            },
+        },
+        methods: {
+            //transfer handles transferring a license to a new company. This
+            //license's data is copied to a new license with the new company and
+            //contact details.
+            transfer: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to transfer.";
+                    return;
+                }
+                if (this.companyName === "") {
+                    this.msgSave = "You must provide the company name for which this license is for.";
+                    return;
+                }
+                if (this.contactName === "") {
+                    this.msgSave = "You must provide the contact name of who requested this license.";
+                    return;
+                }
+                if (this.phoneNumber === "") {
+                    this.msgSave = "You must provide a phone number.";
+                    return;
+                }
+                if (this.email === "") {
+                    this.msgSave = "You must provide an email address.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Transferring license...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                    companyName: this.companyName,
+                    contactName: this.contactName,
+                    phoneNumber: this.phoneNumber,
+                    email: this.email,
+                };
+                if (this.twoFAToken !== "") {
+                    data["twoFAToken"] = this.twoFAToken;
+                }
+                data = { ...data, ...this.passkeyAssertion };
+
+                fetch(post(this.urls.transfer, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if the server requires a 2FA token to transfer the license,
+                        //see users.MsgType2FAStepUpRequired.
+                        if (j.Type === "2FAStepUpRequired") {
+                            modalTransferLicense.passkeyOptions = j.Data || null;
+                            modalTransferLicense.twoFATokenRequired = modalTransferLicense.passkeyOptions === null || modalTransferLicense.passkeyOptions.TOTPAllowed;
+                            modalTransferLicense.twoFAToken = "";
+                            modalTransferLicense.msgSave = j.ErrorData.Message;
+                            modalTransferLicense.msgSaveType = msgTypes.warning;
+                            modalTransferLicense.submitting = false;
+                            return;
+                        }
+
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalTransferLicense.msgSave = err;
+                            modalTransferLicense.msgSaveType = msgTypes.danger;
+                            modalTransferLicense.submitting = false;
+                            return;
+                        }
+
+                        //Show success message for modal.
+                        modalTransferLicense.msgSave = "License Transferred!";
+                        modalTransferLicense.transferred = true;
+
+                        //Redirect user to transferred license's page.
+                        let result: licenseTransfer = j.Data;
+                        if (result === undefined || result.ToLicenseID === undefined) {
+                            modalTransferLicense.msgSave = "Could not determine where to redirect you. This is an odd error...";
+                            modalTransferLicense.msgSaveType = msgTypes.warning;
+                            return;
+                        }
+                        setTimeout(function () {
+                            window.location.href = withBaseURLPath("/app/licensing/license/?id=" + result.ToLicenseID);
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalTransferLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalTransferLicense.msgSaveType = msgTypes.danger;
+                        modalTransferLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //usePasskey prompts the user for their passkey using the challenge provided
+            //by the server and then transfers the license again with the signed
+            //challenge. This is used in place of a 2FA token.
+            usePasskey: function () {
+                let opts: PublicKeyCredentialRequestOptions = {
+                    challenge: base64URLToBuffer(this.passkeyOptions.Challenge),
+                    rpId: this.passkeyOptions.RPID,
+                    allowCredentials: this.passkeyOptions.AllowCredentialIDs.map(function (id: string) {
+                        return { type: "public-key", id: base64URLToBuffer(id) } as PublicKeyCredentialDescriptor;
+                    }),
+                    userVerification: "discouraged",
+                };
+
+                navigator.credentials.get({ publicKey: opts })
+                    .then(function (cred) {
+                        let c = cred as PublicKeyCredential;
+                        let res = c.response as AuthenticatorAssertionResponse;
+
+                        modalTransferLicense.passkeyAssertion = {
+                            passkeyCredentialID: bufferToBase64URL(c.rawId),
+                            passkeyClientDataJSON: bufferToBase64URL(res.clientDataJSON),
+                            passkeyAuthenticatorData: bufferToBase64URL(res.authenticatorData),
+                            passkeySignature: bufferToBase64URL(res.signature),
+                        };
+                        modalTransferLicense.transfer();
+
+                        //Clear the assertion since it can only be used once.
+                        modalTransferLicense.passkeyAssertion = {};
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("navigator.credentials.get() error: >>", err, "<<");
+                        modalTransferLicense.msgSave = 'Your passkey could not be used. Please try again.';
+                        modalTransferLicense.msgSaveType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-extendLicense")) {
+    //@ts-ignore cannot find name Vue
+    var modalExtendLicense = new Vue({
+        name: 'modalExtendLicense',
+        delimiters: ['[[', ']]'],
+        el: '#modal-extendLicense',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            currentExpireDate: "", //set in manageLicense.passData().
+            newExpireDate: "",
+            fingerprint: "", //set upon successful extend api call.
+            extended: false, //set to true upon successful extend api call.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                extend: "/api/licenses/extend/",
+            },
+        },
+        computed: {
+            //minDate creates the min value for the date picker for the new expiration
+            //date. We want the min value a user can pick to be after the current
+            //expiration date.
+            minDate: function () {
+                return dateAdd(this.currentExpireDate, 1);
+            },
+        },
+        methods: {
+            //extend handles updating a license's expiration date in place. The
+            //license is re-signed with the new expiration date.
+            extend: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to extend.";
+                    return;
+                }
+                if (this.newExpireDate === "") {
+                    this.msgSave = "You must provide the new expiration date.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Extending license...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                    newExpireDate: this.newExpireDate,
+                };
+                fetch(post(this.urls.extend, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalExtendLicense.msgSave = err;
+                            modalExtendLicense.msgSaveType = msgTypes.danger;
+                            modalExtendLicense.submitting = false;
+                            return;
+                        }
+
+                        let result: licenseExtend = j.Data;
+                        modalExtendLicense.fingerprint = result.Fingerprint;
+                        modalExtendLicense.extended = true;
+                        modalExtendLicense.submitting = false;
+                        modalExtendLicense.msgSave = "License extended to " + result.ExpireDate + "!";
+                        modalExtendLicense.msgSaveType = msgTypes.success;
+
+                        //Refresh license data and notes to show the new expiration.
+                        manageLicense.getLicense();
+                        manageLicense.getNotes();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalExtendLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalExtendLicense.msgSaveType = msgTypes.danger;
+                        modalExtendLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-activateOffline")) {
+    //@ts-ignore cannot find name Vue
+    var modalActivateOffline = new Vue({
+        name: 'modalActivateOffline',
+        delimiters: ['[[', ']]'],
+        el: '#modal-activateOffline',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            requestCode: "",
+            responseCode: "", //set upon successful activate api call.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                activate: "/api/licenses/activate-offline/",
+            },
+        },
+        methods: {
+            //activate handles generating a signed response code for a request code
+            //generated by the customer's app.
+            activate: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to activate.";
+                    return;
+                }
+                if (this.requestCode === "") {
+                    this.msgSave = "You must provide the request code.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Generating response code...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                    requestCode: this.requestCode,
+                };
+                fetch(post(this.urls.activate, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalActivateOffline.msgSave = err;
+                            modalActivateOffline.msgSaveType = msgTypes.danger;
+                            modalActivateOffline.submitting = false;
+                            return;
+                        }
+
+                        let result: licenseActivateOffline = j.Data;
+                        modalActivateOffline.responseCode = result.ResponseCode;
+                        modalActivateOffline.submitting = false;
+                        modalActivateOffline.msgSave = "Response code generated for machine " + result.MachineID + ". Send the response code to the customer.";
+                        modalActivateOffline.msgSaveType = msgTypes.success;
+
+                        //Refresh notes to show the activation.
+                        manageLicense.getNotes();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalActivateOffline.msgSave = 'An unknown error occured. Please try again.';
+                        modalActivateOffline.msgSaveType = msgTypes.danger;
+                        modalActivateOffline.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-rebuildLicense")) {
+    //@ts-ignore cannot find name Vue
+    var modalRebuildLicense = new Vue({
+        name: 'modalRebuildLicense',
+        delimiters: ['[[', ']]'],
+        el: '#modal-rebuildLicense',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            fingerprint: "", //set upon successful rebuild api call.
+            rebuilt: false, //set to true upon successful rebuild api call.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                rebuild: "/api/licenses/rebuild/",
+            },
+        },
+        methods: {
+            //rebuild handles rebuilding, re-signing, and verifying a license's file
+            //from the license's stored data.
+            rebuild: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to rebuild.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Rebuilding license...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                };
+                fetch(post(this.urls.rebuild, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalRebuildLicense.msgSave = err;
+                            modalRebuildLicense.msgSaveType = msgTypes.danger;
+                            modalRebuildLicense.submitting = false;
+                            return;
+                        }
+
+                        let result: licenseRebuild = j.Data;
+                        modalRebuildLicense.fingerprint = result.Fingerprint;
+                        modalRebuildLicense.rebuilt = true;
+                        modalRebuildLicense.submitting = false;
+
+                        if (result.Verified) {
+                            modalRebuildLicense.msgSave = "License rebuilt and verified!";
+                            modalRebuildLicense.msgSaveType = msgTypes.success;
+                        }
+                        else {
+                            modalRebuildLicense.msgSave = "License rebuilt but could not be verified. See the license's notes.";
+                            modalRebuildLicense.msgSaveType = msgTypes.warning;
+                        }
+
+                        //Refresh license data and notes to show the new status.
+                        manageLicense.getLicense();
+                        manageLicense.getNotes();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalRebuildLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalRebuildLicense.msgSaveType = msgTypes.danger;
+                        modalRebuildLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-licenseDiff")) {
+    //@ts-ignore cannot find name Vue
+    var modalLicenseDiff = new Vue({
+        name: 'modalLicenseDiff',
+        delimiters: ['[[', ']]'],
+        el: '#modal-licenseDiff',
+        data: {
+            diff: {} as licenseDiff,
+            diffRetrieved: false,
+
+            msgLoad: "",
+            msgLoadType: "",
+
+            //endpoint
+            urls: {
+                getDiff: "/api/licenses/diff/",
+            },
+        },
+        methods: {
+            //getDiff looks up the differences between two licenses.
+            getDiff: function (fromID: number, toID: number) {
+                this.diffRetrieved = false;
+                this.msgLoad = "Loading...";
+                this.msgLoadType = msgTypes.primary;
+
+                let data: Object = {
+                    fromID: fromID,
+                    toID: toID,
+                };
+                fetch(get(this.urls.getDiff, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalLicenseDiff.msgLoad = err;
+                            modalLicenseDiff.msgLoadType = msgTypes.danger;
+                            return;
+                        }
+
+                        modalLicenseDiff.diff = j.Data;
+                        modalLicenseDiff.diffRetrieved = true;
+                        modalLicenseDiff.msgLoad = "";
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalLicenseDiff.msgLoad = 'An unknown error occured. Please try again.';
+                        modalLicenseDiff.msgLoadType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-downloadLink")) {
+    //@ts-ignore cannot find name Vue
+    var modalDownloadLink = new Vue({
+        name: 'modalDownloadLink',
+        delimiters: ['[[', ']]'],
+        el: '#modal-downloadLink',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            link: {
+                URL: "",
+                Expires: "",
+            } as licenseDownloadLink, //set upon successful api call.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoint
+            urls: {
+                create: "/api/licenses/download-link/",
+            },
+        },
+        methods: {
+            //create generates a link the customer can use to download the license
+            //without logging in.
+            create: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to create a download link for.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Creating link...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                };
+                fetch(post(this.urls.create, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalDownloadLink.msgSave = err;
+                            modalDownloadLink.msgSaveType = msgTypes.danger;
+                            modalDownloadLink.submitting = false;
+                            return;
+                        }
+
+                        modalDownloadLink.link = j.Data;
+                        modalDownloadLink.msgSave = "";
+                        modalDownloadLink.submitting = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalDownloadLink.msgSave = 'An unknown error occured. Please try again.';
+                        modalDownloadLink.msgSaveType = msgTypes.danger;
+                        modalDownloadLink.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("modal-emailLicense")) {
+    //@ts-ignore cannot find name Vue
+    var modalEmailLicense = new Vue({
+        name: 'modalEmailLicense',
+        delimiters: ['[[', ']]'],
+        el: '#modal-emailLicense',
+        data: {
+            licenseID: 0, //set in manageLicense.passData().
+            email: "", //set in manageLicense.passData().
+            emailData: {
+                Subject: "",
+                Body: "",
+            } as licenseEmail, //set upon successful preview api call.
+            sent: false, //set to true upon successful send api call so the email isn't sent twice by mistake.
+
+            submitting: false,
+            msgSave: "",
+            msgSaveType: "",
+
+            //endpoints
+            urls: {
+                preview: "/api/licenses/email/preview/",
+                send: "/api/licenses/email/send/",
+            },
+        },
+        methods: {
+            //preview shows the email that will be sent, built from the app's email
+            //templates.
+            preview: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to preview the email for.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Building email...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                };
+                fetch(get(this.urls.preview, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalEmailLicense.msgSave = err;
+                            modalEmailLicense.msgSaveType = msgTypes.danger;
+                            modalEmailLicense.submitting = false;
+                            return;
+                        }
+
+                        modalEmailLicense.emailData = j.Data;
+                        modalEmailLicense.msgSave = "";
+                        modalEmailLicense.submitting = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalEmailLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalEmailLicense.msgSaveType = msgTypes.danger;
+                        modalEmailLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //send emails the license to the license's contact.
+            send: function () {
+                //Make sure we aren't already submitting.
+                if (this.submitting) {
+                    console.log("already submitting");
+                    return;
+                }
+
+                //Validate
+                this.msgSaveType = msgTypes.danger;
+                if (this.licenseID < 1) {
+                    this.msgSave = "Could not determine which license you want to email.";
+                    return;
+                }
+
+                //Validation ok.
+                this.msgSaveType = msgTypes.primary;
+                this.msgSave = "Sending email...";
+                this.submitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    id: this.licenseID,
+                };
+                fetch(post(this.urls.send, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            modalEmailLicense.msgSave = err;
+                            modalEmailLicense.msgSaveType = msgTypes.danger;
+                            modalEmailLicense.submitting = false;
+                            return;
+                        }
+
+                        modalEmailLicense.msgSave = "Email sent!";
+                        modalEmailLicense.msgSaveType = msgTypes.success;
+                        modalEmailLicense.sent = true;
+                        modalEmailLicense.submitting = false;
+
+                        //Refresh download history since the license file was sent.
+                        manageLicense.refreshDownloadHistory();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        modalEmailLicense.msgSave = 'An unknown error occured. Please try again.';
+                        modalEmailLicense.msgSaveType = msgTypes.danger;
+                        modalEmailLicense.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
@@ -150,4 +150,610 @@ if (document.getElementById("toolsClearLogins")) {
             },
         },
     });
-}
\ No newline at end of file
+}
+
+if (document.getElementById("toolsPurgeCustomFields")) {
+    //toolsPurgeCustomFields is used to permanently remove custom fields that were
+    //deleted and never used in a license. Deleted fields are otherwise kept forever.
+    //@ts-ignore cannot find name Vue
+    var toolsPurgeCustomFields = new Vue({
+        name: 'toolsPurgeCustomFields',
+        delimiters: ['[[', ']]'],
+        el: '#toolsPurgeCustomFields',
+        data: {
+            skipped: [] as referencedCustomFieldDefined[],
+            showConfirm: false,
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            //handleConfirm shows the "confirm" button. The "confirm" button is
+            //reverted after a short amount of time so that a user must perform the
+            //"confirm" quickly.
+            handleConfirm: function () {
+                this.showConfirm = true;
+
+                setTimeout(function () {
+                    toolsPurgeCustomFields.showConfirm = false;
+                }, 3000);
+
+                return;
+            },
+
+            //purge removes the deleted custom fields that were never used.
+            purge: function () {
+                this.msg = 'Working...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+                this.showConfirm = false;
+                this.skipped = [];
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/custom-fields/defined/purge/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsPurgeCustomFields.msg = err;
+                            toolsPurgeCustomFields.msgType = msgTypes.danger;
+                            toolsPurgeCustomFields.submitting = false;
+                            return;
+                        }
+
+                        toolsPurgeCustomFields.skipped = j.Data.Skipped || [];
+                        toolsPurgeCustomFields.msg = "Done! Fields removed: " + j.Data.Deleted;
+                        toolsPurgeCustomFields.msgType = msgTypes.success;
+                        toolsPurgeCustomFields.submitting = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsPurgeCustomFields.msg = 'An unknown error occured. Please try again.';
+                        toolsPurgeCustomFields.msgType = msgTypes.danger;
+                        toolsPurgeCustomFields.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("toolsBackup")) {
+    //toolsBackup is used to make a backup of the database on demand. The backup is
+    //saved on the server to the directory set in the config file.
+    //@ts-ignore cannot find name Vue
+    var toolsBackup = new Vue({
+        name: 'toolsBackup',
+        delimiters: ['[[', ']]'],
+        el: '#toolsBackup',
+        data: {
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            backup: function () {
+                this.msg = 'Working...  This can take a while.';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/tools/backup/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsBackup.msg = err;
+                            toolsBackup.msgType = msgTypes.danger;
+                            toolsBackup.submitting = false;
+                            return;
+                        }
+
+                        //Don't hide the message automatically so user can copy the 
+                        //path to the backup.
+                        toolsBackup.msg = "Done! Saved to " + j.Data.Path + " (" + j.Data.Size + " bytes).";
+                        toolsBackup.msgType = msgTypes.success;
+                        toolsBackup.submitting = false;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsBackup.msg = 'An unknown error occured. Please try again.';
+                        toolsBackup.msgType = msgTypes.danger;
+                        toolsBackup.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("toolsKeyPairHealth")) {
+    //toolsKeyPairHealth is used to check that each app's default key pair can be
+    //used to sign and verify a license.
+    //@ts-ignore cannot find name Vue
+    var toolsKeyPairHealth = new Vue({
+        name: 'toolsKeyPairHealth',
+        delimiters: ['[[', ']]'],
+        el: '#toolsKeyPairHealth',
+        data: {
+            results: [] as keyPairHealth[],
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            check: function () {
+                this.msg = 'Checking...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+                this.results = [];
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/key-pairs/health/";
+                fetch(get(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsKeyPairHealth.msg = err;
+                            toolsKeyPairHealth.msgType = msgTypes.danger;
+                            toolsKeyPairHealth.submitting = false;
+                            return;
+                        }
+
+                        let results: keyPairHealth[] = j.Data || [];
+                        toolsKeyPairHealth.results = results;
+                        toolsKeyPairHealth.submitting = false;
+
+                        let failed: number = results.filter(r => !r.OK).length;
+                        if (results.length === 0) {
+                            toolsKeyPairHealth.msg = "No active apps to check.";
+                            toolsKeyPairHealth.msgType = msgTypes.primary;
+                        }
+                        else if (failed > 0) {
+                            toolsKeyPairHealth.msg = failed + " of " + results.length + " apps failed.";
+                            toolsKeyPairHealth.msgType = msgTypes.danger;
+                        }
+                        else {
+                            toolsKeyPairHealth.msg = "All apps passed.";
+                            toolsKeyPairHealth.msgType = msgTypes.success;
+                        }
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsKeyPairHealth.msg = 'An unknown error occured. Please try again.';
+                        toolsKeyPairHealth.msgType = msgTypes.danger;
+                        toolsKeyPairHealth.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("toolsEncryptionKey")) {
+    //toolsEncryptionKey is used to check that the PrivateKeyEncryptionKey in the
+    //config file can decrypt the stored private keys.
+    //@ts-ignore cannot find name Vue
+    var toolsEncryptionKey = new Vue({
+        name: 'toolsEncryptionKey',
+        delimiters: ['[[', ']]'],
+        el: '#toolsEncryptionKey',
+        data: {
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            check: function () {
+                this.msg = 'Checking...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/key-pairs/encryption-key/";
+                fetch(get(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsEncryptionKey.msg = err;
+                            toolsEncryptionKey.msgType = msgTypes.danger;
+                            toolsEncryptionKey.submitting = false;
+                            return;
+                        }
+
+                        let result: encryptionKeyCheck = j.Data;
+                        toolsEncryptionKey.submitting = false;
+
+                        if (result.Checked === 0) {
+                            toolsEncryptionKey.msg = "No active key pairs have an encrypted private key.";
+                            toolsEncryptionKey.msgType = msgTypes.primary;
+                        }
+                        else if (!result.OK) {
+                            toolsEncryptionKey.msg = result.Error + " " + result.Decrypted + " of " + result.Checked + " private keys were decrypted.";
+                            toolsEncryptionKey.msgType = msgTypes.danger;
+                        }
+                        else {
+                            toolsEncryptionKey.msg = "All " + result.Checked + " private keys were decrypted.";
+                            toolsEncryptionKey.msgType = msgTypes.success;
+                        }
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsEncryptionKey.msg = 'An unknown error occured. Please try again.';
+                        toolsEncryptionKey.msgType = msgTypes.danger;
+                        toolsEncryptionKey.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("toolsMaintenanceMode")) {
+    //toolsMaintenanceMode is used to turn maintenance mode on or off. While in
+    //maintenance mode, any changes are rejected but data can still be viewed.
+    //@ts-ignore cannot find name Vue
+    var toolsMaintenanceMode = new Vue({
+        name: 'toolsMaintenanceMode',
+        delimiters: ['[[', ']]'],
+        el: '#toolsMaintenanceMode',
+        data: {
+            enabled: false,
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            //getState looks up if maintenance mode is currently on.
+            getState: function () {
+                let data: Object = {};
+                const url: string = "/api/tools/maintenance/";
+                fetch(get(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsMaintenanceMode.msg = err;
+                            toolsMaintenanceMode.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        toolsMaintenanceMode.enabled = j.Data;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsMaintenanceMode.msg = 'An unknown error occured. Please try again.';
+                        toolsMaintenanceMode.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //toggle turns maintenance mode on if it is off, or off if it is on.
+            toggle: function () {
+                this.msg = 'Saving...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    enabled: !this.enabled,
+                };
+                const url: string = "/api/tools/maintenance/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsMaintenanceMode.msg = err;
+                            toolsMaintenanceMode.msgType = msgTypes.danger;
+                            toolsMaintenanceMode.submitting = false;
+                            return;
+                        }
+
+                        //Reload the page so the header shows the new state.
+                        toolsMaintenanceMode.enabled = j.Data;
+                        toolsMaintenanceMode.msg = "Saved! Reloading...";
+                        toolsMaintenanceMode.msgType = msgTypes.success;
+                        setTimeout(function () {
+                            window.location.reload();
+                        }, defaultTimeout);
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsMaintenanceMode.msg = 'An unknown error occured. Please try again.';
+                        toolsMaintenanceMode.msgType = msgTypes.danger;
+                        toolsMaintenanceMode.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+        mounted() {
+            //Get the current state on page load.
+            this.getState();
+            return;
+        },
+    });
+}
+
+if (document.getElementById("toolsAnnouncement")) {
+    //toolsAnnouncement is used to set the announcement shown at the top of each page
+    //to logged-in users.
+    //@ts-ignore cannot find name Vue
+    var toolsAnnouncement = new Vue({
+        name: 'toolsAnnouncement',
+        delimiters: ['[[', ']]'],
+        el: '#toolsAnnouncement',
+        data: {
+            active: false,
+            text: '',
+            severity: 'info',
+            retrieved: false,
+
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            //getAnnouncement looks up the current announcement.
+            getAnnouncement: function () {
+                let data: Object = {};
+                const url: string = "/api/app-settings/";
+                fetch(get(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsAnnouncement.msg = err;
+                            toolsAnnouncement.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        let settings: appSettings = j.Data;
+                        toolsAnnouncement.active = settings.AnnouncementActive;
+                        toolsAnnouncement.text = settings.AnnouncementText;
+                        toolsAnnouncement.severity = settings.AnnouncementSeverity;
+                        toolsAnnouncement.retrieved = true;
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsAnnouncement.msg = 'An unknown error occured. Please try again.';
+                        toolsAnnouncement.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //save saves the announcement and shows or hides it.
+            save: function (active: boolean) {
+                //validate
+                if (active && this.text.trim() === '') {
+                    this.msg = "You must provide the text of the announcement.";
+                    this.msgType = msgTypes.danger;
+                    return;
+                }
+
+                this.msg = 'Saving...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {
+                    active: active,
+                    text: this.text,
+                    severity: this.severity,
+                };
+                const url: string = "/api/tools/announcement/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsAnnouncement.msg = err;
+                            toolsAnnouncement.msgType = msgTypes.danger;
+                            toolsAnnouncement.submitting = false;
+                            return;
+                        }
+
+                        //Reload the page so the announcement is shown or hidden.
+                        toolsAnnouncement.active = active;
+                        toolsAnnouncement.msg = "Saved! Reloading...";
+                        toolsAnnouncement.msgType = msgTypes.success;
+                        setTimeout(function () {
+                            window.location.reload();
+                        }, defaultTimeout);
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsAnnouncement.msg = 'An unknown error occured. Please try again.';
+                        toolsAnnouncement.msgType = msgTypes.danger;
+                        toolsAnnouncement.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+        mounted() {
+            //Get the current announcement on page load.
+            this.getAnnouncement();
+            return;
+        },
+    });
+}
+
+if (document.getElementById("toolsTestEmail")) {
+    //toolsTestEmail is used to send a test email to the logged in user to check the
+    //SMTP settings in the config file.
+    //@ts-ignore cannot find name Vue
+    var toolsTestEmail = new Vue({
+        name: 'toolsTestEmail',
+        delimiters: ['[[', ']]'],
+        el: '#toolsTestEmail',
+        data: {
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            send: function () {
+                this.msg = 'Sending...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/tools/test-smtp/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsTestEmail.msg = err;
+                            toolsTestEmail.msgType = msgTypes.danger;
+                            toolsTestEmail.submitting = false;
+                            return;
+                        }
+
+                        let result: testEmailResult = j.Data;
+                        toolsTestEmail.submitting = false;
+
+                        if (result.OK) {
+                            toolsTestEmail.msg = "Test email sent to " + result.To + ".";
+                            toolsTestEmail.msgType = msgTypes.success;
+                        }
+                        else if (result.StatusCode > 0) {
+                            toolsTestEmail.msg = "Could not send test email. The SMTP server returned " + result.StatusCode + ": " + result.Message;
+                            toolsTestEmail.msgType = msgTypes.danger;
+                        }
+                        else {
+                            toolsTestEmail.msg = "Could not send test email. " + result.Message;
+                            toolsTestEmail.msgType = msgTypes.danger;
+                        }
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsTestEmail.msg = 'An unknown error occured. Please try again.';
+                        toolsTestEmail.msgType = msgTypes.danger;
+                        toolsTestEmail.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
+
+if (document.getElementById("toolsTestWebhook")) {
+    //toolsTestWebhook is used to post a test message to the notification webhook set
+    //in the config file.
+    //@ts-ignore cannot find name Vue
+    var toolsTestWebhook = new Vue({
+        name: 'toolsTestWebhook',
+        delimiters: ['[[', ']]'],
+        el: '#toolsTestWebhook',
+        data: {
+            msg: '',
+            msgType: '',
+            submitting: false,
+        },
+        methods: {
+            send: function () {
+                this.msg = 'Sending...';
+                this.msgType = msgTypes.primary;
+                this.submitting = true;
+
+                //perform api call
+                let data: Object = {};
+                const url: string = "/api/tools/test-webhook/";
+                fetch(post(url, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            toolsTestWebhook.msg = err;
+                            toolsTestWebhook.msgType = msgTypes.danger;
+                            toolsTestWebhook.submitting = false;
+                            return;
+                        }
+
+                        let result: testWebhookResult = j.Data;
+                        toolsTestWebhook.submitting = false;
+
+                        if (result.StatusCode === 0) {
+                            toolsTestWebhook.msg = "Could not post test message. " + result.Message;
+                            toolsTestWebhook.msgType = msgTypes.danger;
+                        }
+                        else if (result.OK) {
+                            toolsTestWebhook.msg = "Test message posted. The webhook returned " + result.StatusCode + ": " + result.Message;
+                            toolsTestWebhook.msgType = msgTypes.success;
+                        }
+                        else {
+                            toolsTestWebhook.msg = "Could not post test message. The webhook returned " + result.StatusCode + ": " + result.Message;
+                            toolsTestWebhook.msgType = msgTypes.danger;
+                        }
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        toolsTestWebhook.msg = 'An unknown error occured. Please try again.';
+                        toolsTestWebhook.msgType = msgTypes.danger;
+                        toolsTestWebhook.submitting = false;
+                        return;
+                    });
+
+                return;
+            },
+        },
+    });
+}
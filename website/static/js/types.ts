@@ -21,6 +21,7 @@ interface activityLog {
 
     //JOINed fields
     Username: string,
+    ImpersonatorUsername: string,
     APIKeyDescription: string,
     APIKeyK: string,
 }
@@ -35,8 +36,27 @@ interface apiKey {
     Description: string, //so user can identify what the api key is used for
     K: string, //the actual api key
 
+    //Permissions.
+    RestrictToApps: boolean, //if true, key can only create licenses for apps in Apps.
+    AllowedIPs: string, //comma separated IPs and CIDR ranges the key can be used from, blank allows any IP.
+
     //JOINed fields
     CreatedByUsername: string,
+
+    //Calculated fields
+    Apps: apiKeyApp[],
+}
+
+interface apiKeyApp {
+    ID: number,
+    DatetimeCreated: string,
+    CreatedByUserID: number,
+
+    APIKeyID: number,
+    AppID: number,
+
+    //JOINed fields
+    AppName: string,
 }
 
 interface app {
@@ -52,6 +72,15 @@ interface app {
     ShowLicenseID: boolean, //if the ID field of a created license file will be populated/non-zero.
     ShowAppName: boolean, //if the Application field of a created license file will be populated/non-blank.
     DownloadFilename: string,
+    FileHeaderText: string, //optional text written as comments above the license data, not signed.
+    FriendlyIDFormat: string, //"", Sequential, or Code; see db-apps.go.
+    FriendlyIDPrefix: string, //optional text prepended to each license's friendly ID.
+    AppLicenseNumbering: boolean, //number licenses sequentially within the app, starting at 1.
+    LastAppLicenseNumber: number, //the number given to the most recently created license.
+    RequiredSignatures: number, //number of key pairs that must sign each license, 1 unless co-signing.
+    LicenseSchemaVersion: number, //version of the custom fields' meaning, saved in each license; 0 if never bumped.
+    LicenseEmailSubject: string, //optional template for the subject of the email a license is sent in.
+    LicenseEmailBody: string, //optional template for the body of the email a license is sent in.
 }
 
 //This must match the formats defined in keyfile-fileFormats.go.
@@ -71,6 +100,13 @@ interface appSettings {
     Allow2FactorAuth: boolean, //if 2 factor authentication can be used
     Force2FactorAuth: boolean, //if all users are required to have 2 factor auth enabled prior to logging in (check if at least one user has 2fa enabled first to prevent lock out!)
     ForceSingleSession: boolean, //user can only be logged into the app in one browser at a time. used as a security tool.
+    RequireDisableReason: boolean, //a note must be provided explaining why a license is being disabled.
+    DismissInitialUserWarning: boolean, //hide the warning shown when the initial user is still active, unless the user is still the unchanged default user.
+    Require2FAForLicenseCreation: boolean, //users enrolled in 2fa tokens must provide a token each time they create a license, even though they are logged in.
+
+    AnnouncementActive: boolean, //whether or not the announcement is shown.
+    AnnouncementText: string, //plain text, HTML is escaped when shown.
+    AnnouncementSeverity: string, //info, warning, or danger.
 }
 
 interface customFieldDefined {
@@ -95,6 +131,9 @@ interface customFieldDefined {
     NumberMinValue: number,
     NumberMaxValue: number,
     MultiChoiceOptions: string,
+    TextValidationRegex: string, //optional regex a text field's value must match.
+    CannotExceedLicenseExpiration: boolean, //date field's value must be on or before the license's expiration date.
+    SortOrder: number, //position of field when creating a license, lower values are shown first.
 
     //When saving a license, we retrieve the defined fields for an app 
     //and set the value for each field using the same list of objects 
@@ -116,6 +155,9 @@ const customFieldTypeText: string = "Text";
 const customFieldTypeBoolean: string = "Boolean";
 const customFieldTypeMultiChoice: string = "Multi-Choice";
 const customFieldTypeDate: string = "Date";
+const customFieldTypeEmail: string = "Email";
+const customFieldTypeURL: string = "URL";
+const customFieldTypeFileHash: string = "File Hash";
 const customFieldTypes: string[] = [
     customFieldTypeInteger,
     customFieldTypeDecimal,
@@ -123,6 +165,9 @@ const customFieldTypes: string[] = [
     customFieldTypeMultiChoice,
     customFieldTypeBoolean,
     customFieldTypeDate,
+    customFieldTypeEmail,
+    customFieldTypeURL,
+    customFieldTypeFileHash,
 ];
 
 interface customFieldResults {
@@ -147,6 +192,44 @@ interface customFieldResults {
     DateValue: string,
 }
 
+interface appFeature {
+    ID: number,
+    DatetimeCreated: string,
+    DatetimeModified: string,
+    CreatedByUserID: number,
+    Active: boolean,
+
+    AppID: number,
+    Name: string,
+    FeatureKey: string, //the value stored in the license file, cannot be changed once saved.
+}
+
+interface licenseTemplate {
+    ID: number,
+    DatetimeCreated: string,
+    DatetimeModified: string,
+    CreatedByUserID: number,
+    Active: boolean,
+
+    Name: string,
+    AppID: number,
+    KeyPairID: number, //0 to use the app's default key pair.
+    DurationDays: number, //0 to use the app's DaysToExpiration.
+    CustomFields: string, //JSON encoded list of custom field results.
+    Features: string,
+
+    //JOINed fields
+    AppName: string,
+}
+
+//licenseTemplateApplied is the data returned to fill in the GUI when creating a
+//license from a template.
+interface licenseTemplateApplied {
+    License: license, //only AppID, KeyPairID, and ExpireDate are set.
+    CustomFields: customFieldResults[],
+    Features: string[],
+}
+
 interface keyPair {
     ID: number,
     DatetimeCreated: string,
@@ -161,6 +244,51 @@ interface keyPair {
     AlgorithmType: string, //ecdsa, rsa, etc.
     PrivateKeyEncrypted: boolean, //whether the private key is stored in plaintext or encrypted
     IsDefault: boolean, //if this is the default keypair for the app
+    Compromised: boolean, //if the private key was leaked, cannot be used to sign new licenses
+}
+
+interface keyPairExport {
+    KeyPairID: number,
+    Filename: string,
+    PrivateKey: string, //PEM encoded, encrypted with passphrase.
+}
+
+interface referencedCustomFieldDefined {
+    ID: number,
+    AppID: number,
+    AppName: string,
+    Name: string,
+    NumResults: number, //the number of licenses that used this field.
+}
+
+interface keyPairHealth {
+    AppID: number,
+    AppName: string,
+    KeyPairID: number, //0 if the app does not have a default key pair.
+    KeyPairName: string,
+    AlgorithmType: string,
+    OK: boolean,
+    Error: string, //why the check failed, blank if OK.
+}
+
+interface encryptionKeyCheck {
+    OK: boolean, //true if every encrypted private key could be decrypted.
+    Checked: number, //number of active key pairs with an encrypted private key.
+    Decrypted: number, //number of private keys that were decrypted.
+    Error: string,
+}
+
+interface testEmailResult {
+    OK: boolean, //true if the email was accepted by the SMTP server.
+    To: string,
+    StatusCode: number, //SMTP reply code, 0 if no reply code was returned.
+    Message: string,
+}
+
+interface testWebhookResult {
+    OK: boolean, //true if the webhook responded with a 2xx status code.
+    StatusCode: number, //HTTP status code, 0 if no response was received.
+    Message: string,
 }
 
 const keyPairAlgoECDSAP256: string = "ECDSA (P256)";
@@ -182,6 +310,9 @@ interface license {
     ID: number,
     DatetimeCreated: string,
     Active: boolean,
+    PublicID: string, //random identifier for looking up a license publicly, see /verify/.
+    FriendlyID: string, //optional human friendly identifier, per the app's format.
+    AppLicenseNumber: number, //sequential number within the app, 0 if the app doesn't number licenses.
 
     CreatedByUserID: number,
     CreatedByAPIKeyID: number,
@@ -194,10 +325,25 @@ interface license {
     IssueDate: string, //yyyy-mm-dd
     IssueTimestamp: number, //unix timestamp in seconds
     ExpireDate: string, //yyyy-mm-dd
+    ExpireDatetime: string, //optional, RFC3339 in UTC, for licenses that expire at a specific time.
+    ValidFrom: string, //optional, yyyy-mm-dd, license cannot be used before this date.
+
+    OrderReference: string, //optional internal order or quote number, never included in the license file.
+    InternalNotes: string, //optional internal notes, never included in the license file.
+    Features: string, //keys of the enabled features, newline separated.
+
+    LastHeartbeat: string, //yyyy-mm-dd hh:mm:ss in UTC, blank if a heartbeat was never received.
+    LastHeartbeatMachineID: string, //optional, machine that sent the last heartbeat.
+
+    ScheduledDisableDate: string, //yyyy-mm-dd in the config file's timezone, blank if a disable is not scheduled.
+    ScheduledDisableNote: string, //optional, reason provided when the disable was scheduled.
+    ScheduledDisableByUserID: number, //user who scheduled the disable.
 
     Signature: string, //the encoded signature generated using the private key from the keypair, so we don't have to regernate it each time we want to redownload the license
+    Signatures: string, //every signature, newline separated, if the license was co-signed.
 
     Verified: boolean, //after a license is generated, we "read" it like a client app would and make sure it is valid before allowing it to be downloaded
+    Imported: boolean, //license was imported from another system, signature was not generated by this app.
 
     AppName: string, //a copy of the value of this field at the time the license was created since it is part of the signature and we need it to redownload a license.
     FileFormat: string, //yaml, json, etc.; copied from app when license is created
@@ -206,6 +352,7 @@ interface license {
 
     //Calculated fields
     Expired: boolean, //used when showing license data so we don't need to compare dates client side
+    LastHeartbeatInTZ: string, //LastHeartbeat converted to the timezone per config file.
 
     //JOINed fields
     KeyPairAlgoType: string,
@@ -214,8 +361,66 @@ interface license {
     AppID: number,
     AppFileFormat: string,
     AppDownloadFilename: string,
+    AppFileHeaderText: string,
     RenewedFromLicenseID: number | null, //null when this license wasn't created by a renewal.
     RenewedToLicenseID: number | null, //null when license hasn't been renewed.
+    TransferredFromLicenseID: number | null, //null when this license wasn't created by a transfer.
+    TransferredToLicenseID: number | null, //null when license hasn't been transferred.
+}
+
+interface licenseTransfer {
+    FromLicenseID: number,
+    ToLicenseID: number,
+}
+
+interface licenseDiffField {
+    Name: string,
+    From: string, //blank if field is not set.
+    To: string, //blank if field is not set.
+    Change: string, //unchanged, added, removed, changed.
+}
+
+interface licenseDiff {
+    FromLicenseID: number,
+    ToLicenseID: number,
+    Relationship: string, //renewal, transfer, or blank if not related.
+    CommonFields: licenseDiffField[],
+    CustomFields: licenseDiffField[],
+}
+
+interface licenseRebuild {
+    LicenseID: number,
+    Fingerprint: string, //SHA-256 hash of the license file's data, without the signature, hex encoded.
+    Verified: boolean,
+}
+
+interface licenseExtend {
+    LicenseID: number,
+    ExpireDate: string, //YYYY-MM-DD
+    Fingerprint: string, //SHA-256 hash of the license file's data, without the signature, hex encoded.
+}
+
+interface licenseActivateOffline {
+    LicenseID: number,
+    MachineID: string,
+    ResponseCode: string,
+}
+
+interface licenseDownloadLink {
+    URL: string,
+    Expires: string, //YYYY-MM-DD HH:MM:SS, UTC.
+}
+
+interface licenseEmail {
+    Subject: string,
+    Body: string,
+}
+
+interface licensePreview {
+    Preview: boolean,
+    FileFormat: string,
+    File: string, //signed with a temporary key, not usable.
+    Fingerprint: string,
 }
 
 interface user {
@@ -232,10 +437,71 @@ interface user {
     Administrator: boolean,
     CreateLicenses: boolean,
     ViewLicenses: boolean,
+    Auditor: boolean,
 
     TwoFactorAuthEnabled: boolean,
     TwoFactorAuthSecret: string,
     TwoFactorAuthBadAttempts: number,
+
+    Timezone: string, //IANA timezone for displaying dates and times, blank uses the config file's timezone.
+}
+
+interface userPasskey {
+    ID: number,
+    DatetimeCreated: string,
+    DatetimeModified: string,
+    Active: boolean,
+    UserID: number,
+
+    Name: string,
+    CredentialID: string,
+    PublicKey: string,
+    Algorithm: number,
+    SignCount: number,
+    LastUsed: string,
+
+    //Calculated fields
+    DatetimeCreatedInTZ: string,
+}
+
+//userLogin is a session for a user, see db-userLogins.go. This is used when a user
+//views their own active sessions.
+interface userLogin {
+    ID: number,
+    UserID: number,
+    DatetimeCreated: string,
+    DatetimeModified: string, //when the session was last used.
+    RemoteIP: string,
+    UserAgent: string,
+    TwoFATokenProvided: boolean,
+    Active: boolean,
+    Expiration: number, //unix timestamp in seconds.
+
+    //Calculated fields
+    DatetimeCreatedInTZ: string,
+    DatetimeModifiedInTZ: string,
+    Current: boolean, //true for the session being used to view the list.
+}
+
+//passkeyRegistrationOptions is returned when beginning registering a passkey, it is 
+//not stored in the database.
+interface passkeyRegistrationOptions {
+    Challenge: string,
+    RPID: string,
+    RPName: string,
+    UserHandle: string,
+    Username: string,
+    Algorithms: number[],
+    ExcludeCredentialIDs: string[],
+}
+
+//passkeyLoginOptions is returned when logging in and a passkey is required, it is 
+//not stored in the database.
+interface passkeyLoginOptions {
+    Challenge: string,
+    RPID: string,
+    AllowCredentialIDs: string[],
+    TOTPAllowed: boolean,
 }
 
 interface downloadHistory {
@@ -252,6 +518,25 @@ interface downloadHistory {
     CreatedByUsername: string,
 }
 
+interface licenseActivation {
+    ID: number,
+    DatetimeCreated: string, //when the license was first activated on the machine.
+    DatetimeModified: string, //when the license was last activated on the machine.
+
+    CreatedByAPIKeyID: number,
+
+    LicenseID: number,
+    MachineID: string,
+    ActivationCount: number, //the number of times the license was activated on the machine.
+
+    //JOINed fields
+    CreatedByAPIKeyDescription: string,
+
+    //Calculated fields
+    DatetimeCreatedInTZ: string,
+    DatetimeModifiedInTZ: string,
+}
+
 interface licenseNote {
     ID: number,
     DatetimeCreated: string,
@@ -262,6 +547,25 @@ interface licenseNote {
 
     LicenseID: number,
     Note: string,
+    Category: string, //one of the categories set in the config file, "uncategorized" if not chosen.
+
+    //JOINed fields
+    CreatedByUsername: string,
+}
+
+interface licenseAttachment {
+    ID: number,
+    DatetimeCreated: string,
+    CreatedByUserID: number,
+    Active: boolean,
+
+    LicenseID: number,
+    Filename: string,
+    ContentType: string,
+    Size: number, //bytes.
+
+    //Calculated fields
+    DatetimeCreatedInTZ: string,
 
     //JOINed fields
     CreatedByUsername: string,
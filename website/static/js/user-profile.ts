@@ -22,9 +22,30 @@ if (document.getElementById("userProfile")) {
             userData: {} as user,
             userDataRetrieved: false,
 
+            //Passkeys registered for user, used in place of 2FA token.
+            passkeys: [] as userPasskey[],
+            passkeyName: '',
+            registeringPasskey: false,
+
+            //Timezone dates and times are displayed in. The list of timezones is
+            //provided by the browser, if supported, for choosing from.
+            timezone: '',
+            timezones: [] as string[],
+            savingTimezone: false,
+
+            //Sessions, where the user is currently logged in.
+            sessions: [] as userLogin[],
+
             //Endpoints.
             urls: {
                 getUserData: "/api/user/",
+                getPasskeys: "/api/users/passkeys/",
+                beginPasskeyRegistration: "/api/users/passkeys/register/begin/",
+                finishPasskeyRegistration: "/api/users/passkeys/register/finish/",
+                deletePasskey: "/api/users/passkeys/delete/",
+                setTimezone: "/api/user/timezone/",
+                getSessions: "/api/user/sessions/",
+                revokeSession: "/api/user/sessions/revoke/",
             }
         },
         methods: {
@@ -46,11 +67,16 @@ if (document.getElementById("userProfile")) {
                         //Save data for building GUI with.
                         userProfile.userData = j.Data || [];
                         userProfile.userDataRetrieved = true;
+                        userProfile.timezone = userProfile.userData.Timezone || '';
 
                         //Pass user ID to other Vue objects for handling changing
                         //password and 2FA stuff.
                         userProfile.populateUserIDInOtherVueObjects();
 
+                        //Get the user's passkeys and sessions.
+                        userProfile.getPasskeys();
+                        userProfile.getSessions();
+
                         return;
                     })
                     .catch(function (err) {
@@ -86,11 +112,292 @@ if (document.getElementById("userProfile")) {
                 modalActivate2FA.resetModal();
                 return;
             },
+
+            //getPasskeys looks up the passkeys the user has registered.
+            getPasskeys: function () {
+                let data: Object = {
+                    userID: this.userData.ID,
+                };
+                fetch(get(this.urls.getPasskeys, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        userProfile.passkeys = j.Data || [];
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        userProfile.msg = 'An unknown error occured. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //registerPasskey registers a new passkey for the user. This gets a 
+            //challenge from the server, has the browser create a credential, and then
+            //sends the credential to the server to be verified and saved.
+            registerPasskey: function () {
+                //Validation.
+                if (this.passkeyName === '') {
+                    this.msg = 'Please provide a name for this passkey.';
+                    this.msgType = msgTypes.danger;
+                    return;
+                }
+
+                this.msg = 'Please follow your browser\'s prompts to create a passkey...';
+                this.msgType = msgTypes.primary;
+                this.registeringPasskey = true;
+
+                let data: Object = {
+                    userID: this.userData.ID,
+                };
+                fetch(post(this.urls.beginPasskeyRegistration, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            userProfile.registeringPasskey = false;
+                            return;
+                        }
+
+                        //Create the credential.
+                        let o: passkeyRegistrationOptions = j.Data;
+                        let opts: PublicKeyCredentialCreationOptions = {
+                            challenge: base64URLToBuffer(o.Challenge),
+                            rp: { id: o.RPID, name: o.RPName },
+                            user: {
+                                id: base64URLToBuffer(o.UserHandle),
+                                name: o.Username,
+                                displayName: o.Username,
+                            },
+                            pubKeyCredParams: o.Algorithms.map(function (alg: number) {
+                                return { type: "public-key", alg: alg } as PublicKeyCredentialParameters;
+                            }),
+                            excludeCredentials: o.ExcludeCredentialIDs.map(function (id: string) {
+                                return { type: "public-key", id: base64URLToBuffer(id) } as PublicKeyCredentialDescriptor;
+                            }),
+                            attestation: "none",
+                            timeout: 5 * 60 * 1000,
+                        };
+
+                        return navigator.credentials.create({ publicKey: opts });
+                    })
+                    .then(function (cred) {
+                        if (!cred) {
+                            return;
+                        }
+
+                        //Send the credential to the server to be verified and saved.
+                        let c = cred as PublicKeyCredential;
+                        let res = c.response as AuthenticatorAttestationResponse;
+
+                        let data: Object = {
+                            userID: userProfile.userData.ID,
+                            name: userProfile.passkeyName,
+                            clientDataJSON: bufferToBase64URL(res.clientDataJSON),
+                            attestationObject: bufferToBase64URL(res.attestationObject),
+                        };
+                        return fetch(post(userProfile.urls.finishPasskeyRegistration, data))
+                            .then(handleRequestErrors)
+                            .then(getJSON)
+                            .then(function (j) {
+                                //Check if response is an error from the server.
+                                let err: string = handleAPIErrors(j);
+                                if (err !== '') {
+                                    userProfile.msg = err;
+                                    userProfile.msgType = msgTypes.danger;
+                                    userProfile.registeringPasskey = false;
+                                    return;
+                                }
+
+                                userProfile.msg = 'Passkey added!';
+                                userProfile.msgType = msgTypes.success;
+                                userProfile.passkeyName = '';
+                                userProfile.registeringPasskey = false;
+                                userProfile.getPasskeys();
+
+                                setTimeout(function () {
+                                    userProfile.msg = '';
+                                    userProfile.msgType = '';
+                                }, defaultTimeout);
+                                return;
+                            });
+                    })
+                    .catch(function (err) {
+                        console.log("passkey registration error: >>", err, "<<");
+                        userProfile.msg = 'The passkey could not be created. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        userProfile.registeringPasskey = false;
+                        return;
+                    });
+
+                return;
+            },
+
+            //deletePasskey removes a passkey so it can no longer be used to log in.
+            deletePasskey: function (id: number) {
+                let data: Object = {
+                    id: id,
+                    userID: this.userData.ID,
+                };
+                fetch(post(this.urls.deletePasskey, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        userProfile.getPasskeys();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        userProfile.msg = 'An unknown error occured. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //getSessions looks up the user's active sessions.
+            getSessions: function () {
+                let data: Object = {};
+                fetch(get(this.urls.getSessions, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        userProfile.sessions = j.Data || [];
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        userProfile.msg = 'An unknown error occured. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //revokeSession logs out one of the user's sessions, for example on
+            //another device. The current session cannot be revoked.
+            revokeSession: function (id: number) {
+                let data: Object = {
+                    id: id,
+                };
+                fetch(post(this.urls.revokeSession, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            return;
+                        }
+
+                        userProfile.getSessions();
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        userProfile.msg = 'An unknown error occured. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        return;
+                    });
+
+                return;
+            },
+
+            //saveTimezone saves the timezone the user wants dates and times displayed
+            //in. A blank timezone uses the timezone set in the config file.
+            saveTimezone: function () {
+                if (this.savingTimezone) {
+                    console.log("already submitting...");
+                    return;
+                }
+
+                this.msg = "Saving timezone...";
+                this.msgType = msgTypes.primary;
+                this.savingTimezone = true;
+
+                let data: Object = {
+                    timezone: this.timezone.trim(),
+                };
+                fetch(post(this.urls.setTimezone, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //Check if response is an error from the server.
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            userProfile.msg = err;
+                            userProfile.msgType = msgTypes.danger;
+                            userProfile.savingTimezone = false;
+                            return;
+                        }
+
+                        userProfile.msg = "Timezone saved!";
+                        userProfile.msgType = msgTypes.success;
+                        userProfile.savingTimezone = false;
+                        setTimeout(function () {
+                            userProfile.msg = '';
+                            userProfile.msgType = '';
+                        }, defaultTimeout);
+
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        userProfile.msg = 'An unknown error occured. Please try again.';
+                        userProfile.msgType = msgTypes.danger;
+                        userProfile.savingTimezone = false;
+                        return;
+                    });
+
+                return;
+            },
         },
         mounted() {
             //Get the data for the logged in user to build GUI with.
             this.getUserData();
 
+            //Get list of timezones to choose from, if the browser supports it.
+            //@ts-ignore supportedValuesOf may not exist in older browsers.
+            if (typeof Intl.supportedValuesOf === "function") {
+                //@ts-ignore supportedValuesOf may not exist in older browsers.
+                this.timezones = Intl.supportedValuesOf("timeZone");
+            }
+
             //Get min password length from app settings saved in hidden input and used
             //when saving new user.
             let minPwdLen: string = (document.getElementById("minPasswordLength") as HTMLInputElement).value;
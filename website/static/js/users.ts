@@ -25,6 +25,10 @@ if (document.getElementById("manageUsers")) {
             msgLoad: '',
             msgLoadType: '',
 
+            //filters for the list of users
+            search: '',
+            role: '',
+
             //list of users
             users: [] as user[],
             usersRetrieved: false,
@@ -49,6 +53,7 @@ if (document.getElementById("manageUsers")) {
             //forcing logout
             forceLogoutBtnText: "Force Logout",
             forceLogoutSubmitting: false,
+            impersonateSubmitting: false,
 
             //endpoints
             urls: {
@@ -56,6 +61,7 @@ if (document.getElementById("manageUsers")) {
                 add: "/api/users/add/",
                 update: "/api/users/update/",
                 forceLogout: "/api/users/force-logout/",
+                impersonate: "/api/users/impersonate/",
             }
         },
         computed: {
@@ -73,7 +79,10 @@ if (document.getElementById("manageUsers")) {
         methods: {
             //getUsers gets the list of users
             getUsers: function () {
-                let data: Object = {};
+                let data: Object = {
+                    search: this.search.trim(),
+                    role: this.role,
+                };
                 fetch(get(this.urls.get, data))
                     .then(handleRequestErrors)
                     .then(getJSON)
@@ -87,6 +96,19 @@ if (document.getElementById("manageUsers")) {
                         }
 
                         manageUsers.users = j.Data || [];
+
+                        //Clear the chosen user if they were filtered out.
+                        let found: boolean = false;
+                        for (let u of (manageUsers.users as user[])) {
+                            if (u.ID === manageUsers.userSelectedID) {
+                                found = true;
+                                break;
+                            }
+                        }
+                        if (!found) {
+                            manageUsers.userSelectedID = 0;
+                        }
+
                         manageUsers.usersRetrieved = true;
                         return;
                     })
@@ -124,6 +146,7 @@ if (document.getElementById("manageUsers")) {
                 if (fieldName === "Administrator" && value) {
                     this.userData.CreateLicenses = true;
                     this.userData.ViewLicenses = true;
+                    this.userData.Auditor = true;
                 }
                 if (fieldName === "CreateLicenses" && value) {
                     this.userData.ViewLicenses = true;
@@ -177,6 +200,7 @@ if (document.getElementById("manageUsers")) {
                     Administrator: false,
                     CreateLicenses: false,
                     ViewLicenses: false,
+                    Auditor: false,
 
                     PasswordInput1: "",
                     PasswordInput2: "",
@@ -447,6 +471,47 @@ if (document.getElementById("manageUsers")) {
                 return;
             },
 
+            //impersonate starts viewing the app as the chosen user. The administrator
+            //is taken to the main app page, as the user, and a banner is shown with a
+            //button to return to the administrator's own account.
+            impersonate: function () {
+                if (this.impersonateSubmitting) {
+                    return;
+                }
+
+                this.impersonateSubmitting = true;
+
+                //Perform api call.
+                let data: Object = {
+                    userID: this.userData.ID,
+                };
+                fetch(post(this.urls.impersonate, data))
+                    .then(handleRequestErrors)
+                    .then(getJSON)
+                    .then(function (j) {
+                        //check if response is an error from the server
+                        let err: string = handleAPIErrors(j);
+                        if (err !== '') {
+                            manageUsers.msgSave = err;
+                            manageUsers.msgSaveType = msgTypes.danger;
+                            manageUsers.impersonateSubmitting = false;
+                            return;
+                        }
+
+                        window.location.href = withBaseURLPath("/app/");
+                        return;
+                    })
+                    .catch(function (err) {
+                        console.log("fetch() error: >>", err, "<<");
+                        manageUsers.msgSave = 'An unknown error occured.  Please try again.';
+                        manageUsers.msgSaveType = msgTypes.danger;
+                        manageUsers.impersonateSubmitting = false;
+                        return;
+                    });
+
+                return;
+            },
+
             //resetChangePasswordModal calls the modalChangePassword.resetModal 
             //function to clear the inputs in the modal. This function is called when 
             //user clicks button top change a users password and open modal. This is 
@@ -623,7 +688,7 @@ if (document.getElementById("modal-activate2FA")) {
 
             //stuff used to handle setting up 2FA
             twoFABarcode: '', //store as base64 png
-            twoFAVerificationCode: '', //a 6 digit number but stored as text to make sure leading zeros aren't removed
+            twoFAVerificationCode: '', //a 6 to 8 digit number but stored as text to make sure leading zeros aren't removed
             show2FAInfoOnly: true, //show info, not the enrollment QR code.  so user can understand what 2fa is.
             retrievingBarcode: false, //true when making request to get the enrollment qr code
 